@@ -10,6 +10,7 @@ import { api } from './services/api';
 import RefreshIcon from '@mui/icons-material/Refresh';
 import PropertyMap from './components/PropertyMap';
 import PropertyStats from './components/PropertyStats';
+import MarketPhase from './components/MarketPhase';
 import PropertyCharts from './components/PropertyCharts';
 import MetropolitanAreaList from './components/MetropolitanAreaList';
 import MetropolitanAreaSelector from './components/MetropolitanAreaSelector';
@@ -124,6 +125,13 @@ const DashboardPage = () => {
                 <PropertyStats dateRange={dateRange} metropolitanAreaId={selectedMetroArea} />
             </StyledSection>
 
+            <StyledSection>
+                <Typography variant="h4" gutterBottom>
+                    Market Phase
+                </Typography>
+                <MarketPhase />
+            </StyledSection>
+
             <StyledSection>
                 <Typography variant="h4" gutterBottom>
                     Property Map
@@ -1,7 +1,7 @@
 import { TelegramConfig, TelegramFilters } from '../types/telegram';
 import axios from 'axios';
 
-const API_BASE_URL = 'http://localhost:5250/api';
+const API_BASE_URL = 'http://localhost:5250/api/v1';
 
 export async function getTelegramConfig(): Promise<TelegramConfig> {
     const response = await axios.get(`${API_BASE_URL}/telegram/config`);
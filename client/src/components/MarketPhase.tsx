@@ -0,0 +1,109 @@
+import React, { useEffect, useState } from 'react';
+import { MarketPhase as Phase, MarketPhaseComponent, MarketPhaseName } from '../types/property';
+import { api } from '../services/api';
+import { Card, CardContent, Typography, Grid, Chip } from '@mui/material';
+import { styled } from '@mui/material/styles';
+
+const StyledCard = styled(Card)(() => ({
+    minWidth: 275,
+    margin: '20px 0',
+}));
+
+const TitleTypography = styled(Typography)(() => ({
+    fontSize: 14,
+    color: '#666',
+}));
+
+const phaseLabels: Record<MarketPhaseName, string> = {
+    sellers: "Seller's market",
+    balanced: 'Balanced market',
+    buyers: "Buyer's market",
+    unknown: 'Not enough data',
+};
+
+const phaseColors: Record<MarketPhaseName, 'error' | 'default' | 'success'> = {
+    sellers: 'error',
+    balanced: 'default',
+    buyers: 'success',
+    unknown: 'default',
+};
+
+const formatPercent = (value: number, signed: boolean) =>
+    `${signed && value > 0 ? '+' : ''}${(value * 100).toFixed(1)}%`;
+
+const describeComponent = (component: MarketPhaseComponent, windowDays: number) => {
+    if (component.value === null) {
+        return null;
+    }
+    switch (component.component) {
+        case 'inventory_trend':
+            return `Listings on the market: ${formatPercent(component.value, true)} in ${windowDays} days`;
+        case 'days_to_sell':
+            return `Median days to sell: ${component.value.toFixed(0)}`;
+        case 'over_asking_share':
+            return `Sold over asking: ${formatPercent(component.value, false)}`;
+        case 'price_momentum':
+            return `Sold price per m²: ${formatPercent(component.value, true)} in ${windowDays} days`;
+    }
+};
+
+const MarketPhase: React.FC = () => {
+    const [phases, setPhases] = useState<Phase[]>([]);
+    const [loading, setLoading] = useState(true);
+
+    useEffect(() => {
+        const fetchPhases = async () => {
+            try {
+                setLoading(true);
+                setPhases(await api.getMarketPhases());
+            } catch (error) {
+                console.error('Failed to fetch market phases:', error);
+            } finally {
+                setLoading(false);
+            }
+        };
+
+        fetchPhases();
+    }, []);
+
+    if (loading) {
+        return <div>Loading market phase...</div>;
+    }
+
+    if (phases.length === 0) {
+        return <div>No market phase available</div>;
+    }
+
+    return (
+        <Grid container spacing={3}>
+            {phases.map((phase) => (
+                <Grid item xs={12} sm={6} md={4} key={phase.city}>
+                    <StyledCard>
+                        <CardContent>
+                            <TitleTypography color="textSecondary" gutterBottom>
+                                {phase.city}
+                            </TitleTypography>
+                            <Chip
+                                label={phase.score === null
+                                    ? phaseLabels[phase.phase]
+                                    : `${phaseLabels[phase.phase]} (${phase.score > 0 ? '+' : ''}${phase.score.toFixed(2)})`}
+                                color={phaseColors[phase.phase]}
+                                sx={{ mb: 1 }}
+                            />
+                            {phase.components.map((component) => {
+                                const description = describeComponent(component, phase.window_days);
+                                return description && (
+                                    <Typography variant="body2" key={component.component}>
+                                        {description}
+                                    </Typography>
+                                );
+                            })}
+                        </CardContent>
+                    </StyledCard>
+                </Grid>
+            ))}
+        </Grid>
+    );
+};
+
+export default MarketPhase;
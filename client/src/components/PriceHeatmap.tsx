@@ -2,6 +2,7 @@ import React, { useEffect, useState, useRef, useCallback } from 'react';
 import { MapContainer, TileLayer, GeoJSON } from 'react-leaflet';
 import 'leaflet/dist/leaflet.css';
 import { Property } from '../types/property';
+import { api } from '../services/api';
 import * as d3 from 'd3';
 import { Box, Typography, Paper, ButtonGroup, Button } from '@mui/material';
 
@@ -17,6 +18,7 @@ const formatValue = (value: number, type: 'price' | 'price_per_sqm' | 'count') =
 interface PriceHeatmapProps {
     properties: Property[];
     metric?: 'price' | 'price_per_sqm' | 'density';  // Optional prop to set initial view
+    asOf?: string;  // Date (YYYY-MM-DD) whose district boundaries are drawn, the current ones when unset
 }
 
 interface DistrictData {
@@ -133,7 +135,7 @@ const DensityLegend: React.FC<{ min: number; max: number }> = ({ min, max }) =>
     );
 };
 
-const PriceHeatmap: React.FC<PriceHeatmapProps> = ({ properties, metric = 'price' }) => {
+const PriceHeatmap: React.FC<PriceHeatmapProps> = ({ properties, metric = 'price', asOf }) => {
     const [currentView, setCurrentView] = useState<MapView>(metric);
     const [districtData, setDistrictData] = useState<Map<string, DistrictData>>(new Map());
     const [geoJsonData, setGeoJsonData] = useState<any>(null);
@@ -188,11 +190,10 @@ const PriceHeatmap: React.FC<PriceHeatmapProps> = ({ properties, metric = 'price
     }, [getTooltipContent]);
 
     useEffect(() => {
-        fetch('/district_hulls.geojson')
-            .then(response => response.json())
+        api.getDistrictHulls(asOf)
             .then(data => setGeoJsonData(data))
             .catch(error => console.error('Error loading district hulls:', error));
-    }, []);
+    }, [asOf]);
 
     useEffect(() => {
         // Calculate price statistics for each district
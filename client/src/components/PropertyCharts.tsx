@@ -345,7 +345,10 @@ const PropertyCharts: React.FC<PropertyChartsProps> = ({ metropolitanAreaId }) =
             <Grid container spacing={3}>
                 {/* Price Heatmap */}
                 <Grid item xs={12}>
-                    <PriceHeatmap properties={filteredPropertiesMemo} />
+                    <PriceHeatmap
+                        properties={filteredPropertiesMemo}
+                        asOf={deferredFilters.endDate ? deferredFilters.endDate.format('YYYY-MM-DD') : undefined}
+                    />
                 </Grid>
 
                 {/* Charts */}
@@ -1,9 +1,10 @@
 import axios from 'axios';
-import { Property, PropertyStats, AreaStats, DateRange } from '../types/property';
+import type { FeatureCollection } from 'geojson';
+import { Property, PropertyList, PropertyStats, AreaStats, DateRange, MapBounds, NearbyProperty, PropertyBatch, PropertyDetail, MarketPhase, PostalCodeStats, DistrictReport } from '../types/property';
 import { MetropolitanArea, MetropolitanAreaFormData } from '../types/metropolitan';
 
 // Get the API URL from environment variables, fallback to localhost if not set
-const API_BASE_URL = process.env.REACT_APP_API_URL || 'http://localhost:5250/api';
+const API_BASE_URL = process.env.REACT_APP_API_URL || 'http://localhost:5250/api/v1';
 
 // Create axios instance with default config
 const axiosInstance = axios.create({
@@ -24,20 +25,58 @@ async function handleResponse(response: Response) {
 
 export const api = {
     getAllProperties: async (dateRange: DateRange, metropolitanAreaId?: number | null): Promise<Property[]> => {
-        const response = await axiosInstance.get('/properties', {
+        const response = await axiosInstance.get<PropertyList>('/properties', {
             params: {
                 ...dateRange,
                 metropolitanAreaId
             }
         });
+        return response.data.properties;
+    },
+
+    getPropertiesInBounds: async (bounds: MapBounds, dateRange: DateRange, status?: 'active' | 'sold'): Promise<Property[]> => {
+        const response = await axiosInstance.get<Property[]>('/properties/bounds', {
+            params: {
+                min_lat: bounds.minLat,
+                min_lng: bounds.minLng,
+                max_lat: bounds.maxLat,
+                max_lng: bounds.maxLng,
+                ...dateRange,
+                status
+            }
+        });
+        return response.data;
+    },
+
+    getPropertiesNear: async (lat: number, lng: number, radius: number, dateRange: DateRange, status?: 'active' | 'sold'): Promise<NearbyProperty[]> => {
+        const response = await axiosInstance.get<NearbyProperty[]>('/properties/nearby', {
+            params: {
+                lat,
+                lng,
+                radius,
+                ...dateRange,
+                status
+            }
+        });
         return response.data;
     },
 
-    getPropertyStats: async (dateRange: DateRange, metropolitanAreaId?: number | null): Promise<PropertyStats> => {
+    getPropertiesBatch: async (ids: number[], urls: string[] = []): Promise<PropertyBatch> => {
+        const response = await axiosInstance.post<PropertyBatch>('/properties/batch', { ids, urls });
+        return response.data;
+    },
+
+    getProperty: async (id: number): Promise<PropertyDetail> => {
+        const response = await axiosInstance.get<PropertyDetail>(`/properties/${id}`);
+        return response.data;
+    },
+
+    getPropertyStats: async (dateRange: DateRange, metropolitanAreaId?: number | null, asOf?: string): Promise<PropertyStats> => {
         const response = await axiosInstance.get('/properties/stats', {
             params: {
                 ...dateRange,
-                metropolitanAreaId
+                metropolitanAreaId,
+                asOf
             }
         });
         return response.data;
@@ -53,6 +92,35 @@ export const api = {
         return response.data;
     },
 
+    getMarketPhases: async (city?: string): Promise<MarketPhase[]> => {
+        const response = await axiosInstance.get<MarketPhase[]>('/stats/market-phase', {
+            params: { city }
+        });
+        return response.data;
+    },
+
+    // The browser revalidates the boundaries with their ETag, so an unchanged
+    // version is not downloaded again. asOf (YYYY-MM-DD) asks for the
+    // boundaries in force at that date.
+    getDistrictHulls: async (asOf?: string): Promise<FeatureCollection> => {
+        const response = await axiosInstance.get<FeatureCollection>('/districts/hulls', {
+            params: { asOf }
+        });
+        return response.data;
+    },
+
+    getDistrictReport: async (district: string): Promise<DistrictReport> => {
+        const response = await axiosInstance.get<DistrictReport>(`/districts/${district}/report`);
+        return response.data;
+    },
+
+    getPostalCodeStats: async (postalCode: string, dateRange: DateRange): Promise<PostalCodeStats> => {
+        const response = await axiosInstance.get(`/properties/area/${encodeURIComponent(postalCode)}`, {
+            params: dateRange
+        });
+        return response.data;
+    },
+
     getRecentSales: async (limit: number = 10, dateRange: DateRange, metropolitanAreaId?: number | null): Promise<Property[]> => {
         const response = await axiosInstance.get('/properties/recent', {
             params: { 
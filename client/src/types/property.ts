@@ -6,6 +6,7 @@ export interface Property {
     property_type: string;
     city: string;
     postal_code: string;
+    district: string;
     price: number;
     year_built: number;
     living_area: number;
@@ -19,6 +20,15 @@ export interface Property {
     longitude: number | null;
 }
 
+export interface PropertyList {
+    properties: Property[];
+    total: number;
+    limit: number;
+    offset: number;
+    sort_by: string;
+    order: 'asc' | 'desc';
+}
+
 export interface PropertyStats {
     total_properties: number;
     average_price: number;
@@ -27,6 +37,11 @@ export interface PropertyStats {
     total_sold: number;
     total_active: number;
     price_per_sqm: number;
+    median_price_per_sqm: number;
+    active_median_price: number;
+    active_median_price_per_sqm: number;
+    sold_median_price: number;
+    sold_median_price_per_sqm: number;
 }
 
 export interface AreaStats {
@@ -37,7 +52,114 @@ export interface AreaStats {
     avg_price_per_sqm: number;
 }
 
+export interface PostalCodeStats {
+    postal_code: string;
+    district: string;
+    property_count: number;
+    active_count: number;
+    sold_count: number;
+    median_price: number | null;
+    median_price_per_sqm: number | null;
+    sold_median_price_per_sqm: number | null;
+    median_days_to_sell: number | null;
+}
+
 export interface DateRange {
     startDate: string | undefined;
     endDate: string | undefined;
-} 
\ No newline at end of file
+}
+
+export interface NearbyProperty extends Property {
+    distance_m: number;
+}
+
+export interface PropertyBatch {
+    properties: Property[];
+    missing_ids: number[];
+    missing_urls: string[];
+}
+
+export interface PropertyHistoryEntry {
+    id: number;
+    change_type: string;
+    status: string;
+    price: number | null;
+    previous_status?: string;
+    previous_price?: number;
+    price_change?: number;
+    price_change_pct?: number;
+    listing_date?: string;
+    changed_at: string;
+}
+
+export interface PropertyDetail extends Property {
+    geocode_status: 'located' | 'pending' | 'failed' | 'no_address';
+    history: PropertyHistoryEntry[];
+    comparables: NearbyProperty[];
+}
+
+export interface MapBounds {
+    minLat: number;
+    minLng: number;
+    maxLat: number;
+    maxLng: number;
+} 
+export type MarketPhaseName = 'sellers' | 'balanced' | 'buyers' | 'unknown';
+
+export interface MarketPhaseComponent {
+    component: 'inventory_trend' | 'days_to_sell' | 'over_asking_share' | 'price_momentum';
+    value: number | null;
+    score: number | null;
+}
+
+export interface MarketPhase {
+    city?: string;
+    district?: string;
+    phase: MarketPhaseName;
+    score: number | null;
+    window_days: number;
+    inventory: number;
+    sales: number;
+    components: MarketPhaseComponent[];
+}
+
+export interface DistrictReport {
+    district: string;
+    city: string;
+    generated_at: string;
+    prices: {
+        median_sold_price: number | null;
+        median_sold_price_per_sqm: number | null;
+        prior_sold_price_per_sqm: number | null;
+        yearly_change: number | null;
+        median_asking_price: number | null;
+        median_asking_price_per_sqm: number | null;
+    };
+    inventory: {
+        active: number;
+        new_listings: number;
+    };
+    velocity: {
+        sales: number;
+        sales_per_month: number;
+        median_days_to_sell: number | null;
+    };
+    energy_labels: {
+        label: string;
+        count: number;
+        share: number;
+    }[];
+    republishing: {
+        listings: number;
+        republished: number;
+        rate: number | null;
+    };
+    withdrawals: {
+        delisted: number;
+        sold: number;
+        withdrawn: number;
+        expired: number;
+        withdrawal_rate: number;
+    };
+    market_phase: MarketPhase;
+}
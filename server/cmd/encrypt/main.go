@@ -0,0 +1,93 @@
+package main
+
+import (
+	"flag"
+	"fundamental/server/config"
+	"fundamental/server/internal/database"
+	"os"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Command encrypt converts a plain SQLite database into an SQLCipher encrypted
+// copy. The key is read from DB_ENCRYPTION_KEY so it does not end up in the
+// shell history. Requires a build with -tags sqlcipher linked against SQLCipher.
+//
+// Usage:
+//
+//	DB_ENCRYPTION_KEY=... go run -tags sqlcipher cmd/encrypt/main.go [-db database/funda.db] [-out PATH] [-replace]
+func main() {
+	logger := logrus.New()
+	logger.SetFormatter(&logrus.JSONFormatter{})
+	logger.SetOutput(os.Stderr)
+
+	cfg := config.Load()
+	dbPath := flag.String("db", cfg.DatabasePath, "path to the plain SQLite database")
+	outPath := flag.String("out", "", "path of the encrypted copy (default: <db>.encrypted)")
+	replace := flag.Bool("replace", false, "replace the plain database with the encrypted copy, keeping a .plain.bak backup")
+	flag.Parse()
+
+	key := cfg.DatabaseKey
+	if key == "" {
+		logger.Fatal("DB_ENCRYPTION_KEY must be set")
+	}
+	if !database.CipherSupported {
+		logger.Fatal("This binary was built without SQLCipher support; rebuild with -tags sqlcipher")
+	}
+	if *outPath == "" {
+		*outPath = *dbPath + ".encrypted"
+	}
+	if _, err := os.Stat(*outPath); err == nil {
+		logger.WithField("path", *outPath).Fatal("Output file already exists")
+	}
+
+	if err := database.EncryptDatabase(*dbPath, *outPath, key); err != nil {
+		logger.WithError(err).Fatal("Failed to encrypt database")
+	}
+
+	// Check the copy opens with the key and holds the same properties
+	plainCount, err := countProperties(*dbPath, "")
+	if err != nil {
+		logger.WithError(err).Fatal("Failed to read plain database")
+	}
+	encryptedCount, err := countProperties(*outPath, key)
+	if err != nil {
+		logger.WithError(err).Fatal("Failed to verify encrypted database")
+	}
+	if plainCount != encryptedCount {
+		logger.WithFields(logrus.Fields{
+			"plain":     plainCount,
+			"encrypted": encryptedCount,
+		}).Fatal("Encrypted database does not match the plain database")
+	}
+	logger.WithFields(logrus.Fields{
+		"path":       *outPath,
+		"properties": encryptedCount,
+	}).Info("Encrypted database written")
+
+	if *replace {
+		backupPath := *dbPath + ".plain.bak"
+		if err := os.Rename(*dbPath, backupPath); err != nil {
+			logger.WithError(err).Fatal("Failed to back up plain database")
+		}
+		if err := os.Rename(*outPath, *dbPath); err != nil {
+			logger.WithError(err).Fatal("Failed to move encrypted database into place")
+		}
+		logger.WithField("backup", backupPath).Info("Replaced plain database; delete the backup once the server starts with the key")
+	}
+}
+
+// countProperties opens a database and returns the number of properties in it
+func countProperties(path, key string) (int, error) {
+	opts := database.DefaultOptions()
+	opts.Key = key
+	db, err := database.OpenWithOptions(database.DriverSQLite, path, opts)
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+
+	var count int
+	err = db.GetDB().QueryRow("SELECT COUNT(*) FROM properties").Scan(&count)
+	return count, err
+}
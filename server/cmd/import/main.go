@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fundamental/server/config"
+	"fundamental/server/internal/database"
+	"fundamental/server/internal/importer"
+	"os"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Command import loads historical property CSV exports into the database.
+//
+// Usage:
+//
+//	go run cmd/import/main.go -file archive.csv [-options options.json] [-db database/funda.db]
+func main() {
+	logger := logrus.New()
+	logger.SetFormatter(&logrus.JSONFormatter{})
+	logger.SetOutput(os.Stderr)
+
+	cfg := config.Load()
+	dbPath := flag.String("db", cfg.DatabasePath, "path to the SQLite database")
+	csvPath := flag.String("file", "", "CSV file to import")
+	optionsPath := flag.String("options", "", "optional JSON file with column mapping, delimiter and date format")
+	flag.Parse()
+
+	if *csvPath == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var opts importer.Options
+	if *optionsPath != "" {
+		data, err := os.ReadFile(*optionsPath)
+		if err != nil {
+			logger.WithError(err).Fatal("Failed to read options file")
+		}
+		if err := json.Unmarshal(data, &opts); err != nil {
+			logger.WithError(err).Fatal("Failed to parse options file")
+		}
+	}
+
+	cfg.DatabaseDriver = database.DriverSQLite
+	db, err := database.OpenFromConfig(cfg, *dbPath)
+	if err != nil {
+		logger.WithError(err).Fatal("Failed to initialize database")
+	}
+	defer db.Close()
+
+	if err := db.RunMigrations(); err != nil {
+		logger.WithError(err).Fatal("Failed to run database migrations")
+	}
+
+	file, err := os.Open(*csvPath)
+	if err != nil {
+		logger.WithError(err).Fatal("Failed to open CSV file")
+	}
+	defer file.Close()
+
+	report, err := importer.NewImporter(db, logger).ImportCSV(file, opts)
+	if err != nil {
+		logger.WithError(err).Fatal("Import failed")
+	}
+
+	// Print the per-row report to stdout so it can be redirected to a file
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		logger.WithError(err).Fatal("Failed to write import report")
+	}
+}
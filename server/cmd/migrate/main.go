@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fundamental/server/config"
+	"fundamental/server/internal/database"
+	"os"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Command migrate applies or reverts schema migrations and reports their status.
+// The driver, PostgreSQL connection string and SQLCipher key are read from
+// DB_DRIVER, DATABASE_URL and DB_ENCRYPTION_KEY, and the default database path
+// from DB_PATH.
+//
+// Usage:
+//
+//	go run cmd/migrate/main.go [-db database/funda.db] [-to VERSION] [-status]
+func main() {
+	logger := logrus.New()
+	logger.SetFormatter(&logrus.JSONFormatter{})
+	logger.SetOutput(os.Stderr)
+
+	cfg := config.Load()
+	dbPath := flag.String("db", cfg.DatabasePath, "path to the SQLite database")
+	target := flag.Int("to", -1, "schema version to migrate up or down to (default: latest)")
+	statusOnly := flag.Bool("status", false, "only print the migration status")
+	flag.Parse()
+
+	db, err := database.OpenFromConfig(cfg, *dbPath)
+	if err != nil {
+		logger.WithError(err).Fatal("Failed to initialize database")
+	}
+	defer db.Close()
+
+	if !*statusOnly {
+		version := *target
+		if version < 0 {
+			version = database.SchemaVersion
+		}
+		if err := db.MigrateTo(version); err != nil {
+			logger.WithError(err).Fatal("Migration failed")
+		}
+		logger.WithField("version", version).Info("Schema migrated")
+	}
+
+	status, err := db.MigrationStatus()
+	if err != nil {
+		logger.WithError(err).Fatal("Failed to read migration status")
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(status); err != nil {
+		logger.WithError(err).Fatal("Failed to write migration status")
+	}
+}
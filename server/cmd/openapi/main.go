@@ -0,0 +1,488 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Command openapi generates the documentation of the API handlers that
+// /api/openapi.json is built from. It reads the handlers of internal/api: the
+// doc comment of each handler becomes the summary and description of its
+// operations, and the query parameters it reads, whether it binds a JSON body
+// and the statuses it answers with are found in its code and in the helpers it
+// passes its *gin.Context to.
+//
+// Parameters whose names the code does not spell out, such as those built in
+// a loop, are declared with a directive in the doc comment of the handler or
+// helper reading them, a name per parameter with [] for repeated parameters
+// and [key] for parameters such as min_score[value]:
+//
+//	//openapi:query min_price max_price tag[] min_score[key]
+//
+// Usage, from internal/api through go generate:
+//
+//	go run ../../cmd/openapi [-dir .] [-out openapi_docs.go] [-check]
+//
+// -check reports an error instead of writing when the file is out of date.
+func main() {
+	dir := flag.String("dir", ".", "directory of the api package")
+	out := flag.String("out", "openapi_docs.go", "generated file, relative to -dir")
+	check := flag.Bool("check", false, "fail when the generated file is out of date instead of writing it")
+	flag.Parse()
+
+	source, err := generate(*dir, *out)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "openapi:", err)
+		os.Exit(1)
+	}
+
+	path := filepath.Join(*dir, *out)
+	if *check {
+		current, err := os.ReadFile(path)
+		if err != nil || !bytes.Equal(current, source) {
+			fmt.Fprintf(os.Stderr, "openapi: %s is out of date, run go generate ./internal/api\n", path)
+			os.Exit(1)
+		}
+		return
+	}
+	if err := os.WriteFile(path, source, 0644); err != nil {
+		fmt.Fprintln(os.Stderr, "openapi:", err)
+		os.Exit(1)
+	}
+}
+
+// statusCodes are the net/http status constants the handlers use
+var statusCodes = map[string]int{
+	"StatusOK":                    200,
+	"StatusCreated":               201,
+	"StatusAccepted":              202,
+	"StatusNoContent":             204,
+	"StatusNotModified":           304,
+	"StatusBadRequest":            400,
+	"StatusUnauthorized":          401,
+	"StatusForbidden":             403,
+	"StatusNotFound":              404,
+	"StatusConflict":              409,
+	"StatusGone":                  410,
+	"StatusPreconditionFailed":    412,
+	"StatusRequestEntityTooLarge": 413,
+	"StatusUnprocessableEntity":   422,
+	"StatusTooManyRequests":       429,
+	"StatusInternalServerError":   500,
+	"StatusNotImplemented":        501,
+	"StatusBadGateway":            502,
+	"StatusServiceUnavailable":    503,
+}
+
+// queryKinds maps the gin.Context methods reading query parameters to the
+// kind of parameter
+var queryKinds = map[string]string{
+	"Query":         "",
+	"DefaultQuery":  "",
+	"GetQuery":      "",
+	"QueryArray":    "array",
+	"GetQueryArray": "array",
+	"QueryMap":      "map",
+	"GetQueryMap":   "map",
+}
+
+// fileMethods are the gin.Context methods sending a file
+var fileMethods = map[string]bool{
+	"File":           true,
+	"FileAttachment": true,
+	"FileFromFS":     true,
+}
+
+// bindMethods are the gin.Context methods reading a JSON body
+var bindMethods = map[string]bool{"ShouldBindJSON": true, "BindJSON": true, "ShouldBind": true}
+
+// function is what a function of the api package does with its *gin.Context
+type function struct {
+	name     string
+	doc      string
+	handler  bool
+	query    map[string]string
+	body     bool
+	statuses map[int]bool
+	calls    []string // functions the context is passed to
+}
+
+// queryDirective declares query parameters in a doc comment
+const queryDirective = "//openapi:query "
+
+func generate(dir, out string) ([]byte, error) {
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, dir, func(info os.FileInfo) bool {
+		return !strings.HasSuffix(info.Name(), "_test.go") && info.Name() != out
+	}, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	pkg, ok := pkgs["api"]
+	if !ok {
+		return nil, fmt.Errorf("no api package in %s", dir)
+	}
+
+	forms := formFields(pkg)
+	functions := map[string]*function{}
+	for _, file := range pkg.Files {
+		for _, decl := range file.Decls {
+			if fd, ok := decl.(*ast.FuncDecl); ok && fd.Body != nil {
+				f := inspect(fd, forms)
+				functions[f.name] = f
+			}
+		}
+	}
+
+	var names []string
+	for name, f := range functions {
+		if f.handler {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var b bytes.Buffer
+	b.WriteString("// Code generated by go run ../../cmd/openapi; DO NOT EDIT.\n\n")
+	b.WriteString("package api\n\n")
+	b.WriteString("// handlerDocs documents the handlers by name, see openapi.go\n")
+	b.WriteString("var handlerDocs = map[string]handlerDoc{\n")
+	for _, name := range names {
+		f := functions[name]
+		query, body, statuses := map[string]string{}, false, map[int]bool{}
+		collect(functions, name, map[string]bool{}, query, &body, statuses)
+
+		summary, description := describe(f)
+		fmt.Fprintf(&b, "\t%q: {\n", name)
+		fmt.Fprintf(&b, "\t\tSummary: %q,\n", summary)
+		if description != "" {
+			fmt.Fprintf(&b, "\t\tDescription: %q,\n", description)
+		}
+		if len(query) > 0 {
+			params := make([]string, 0, len(query))
+			for param := range query {
+				params = append(params, param)
+			}
+			sort.Strings(params)
+			b.WriteString("\t\tQuery: []queryParam{")
+			for i, param := range params {
+				if i > 0 {
+					b.WriteString(", ")
+				}
+				fmt.Fprintf(&b, "{%q, %q}", param, query[param])
+			}
+			b.WriteString("},\n")
+		}
+		if body {
+			b.WriteString("\t\tBody: true,\n")
+		}
+		if len(statuses) > 0 {
+			codes := make([]int, 0, len(statuses))
+			for code := range statuses {
+				codes = append(codes, code)
+			}
+			sort.Ints(codes)
+			parts := make([]string, len(codes))
+			for i, code := range codes {
+				parts[i] = strconv.Itoa(code)
+			}
+			fmt.Fprintf(&b, "\t\tResponses: []int{%s},\n", strings.Join(parts, ", "))
+		}
+		b.WriteString("\t},\n")
+	}
+	b.WriteString("}\n")
+	return format.Source(b.Bytes())
+}
+
+// funcName names a function as gin reports handlers, such as
+// "(*Handler).GetAllProperties" for a method
+func funcName(fd *ast.FuncDecl) string {
+	if fd.Recv == nil || len(fd.Recv.List) == 0 {
+		return fd.Name.Name
+	}
+	switch t := fd.Recv.List[0].Type.(type) {
+	case *ast.StarExpr:
+		if id, ok := t.X.(*ast.Ident); ok {
+			return "(*" + id.Name + ")." + fd.Name.Name
+		}
+	case *ast.Ident:
+		return t.Name + "." + fd.Name.Name
+	}
+	return fd.Name.Name
+}
+
+// isGinContext reports whether a parameter type is *gin.Context
+func isGinContext(expr ast.Expr) bool {
+	star, ok := expr.(*ast.StarExpr)
+	if !ok {
+		return false
+	}
+	sel, ok := star.X.(*ast.SelectorExpr)
+	if !ok {
+		return false
+	}
+	pkg, ok := sel.X.(*ast.Ident)
+	return ok && pkg.Name == "gin" && sel.Sel.Name == "Context"
+}
+
+// formFields returns the query parameters of the struct types of the package
+// bound with ShouldBindQuery, from their form tags
+func formFields(pkg *ast.Package) map[string][]string {
+	forms := map[string][]string{}
+	for _, file := range pkg.Files {
+		ast.Inspect(file, func(n ast.Node) bool {
+			spec, ok := n.(*ast.TypeSpec)
+			if !ok {
+				return true
+			}
+			st, ok := spec.Type.(*ast.StructType)
+			if !ok {
+				return false
+			}
+			for _, field := range st.Fields.List {
+				if field.Tag == nil {
+					continue
+				}
+				tag, err := strconv.Unquote(field.Tag.Value)
+				if err != nil {
+					continue
+				}
+				name, _, _ := strings.Cut(reflect.StructTag(tag).Get("form"), ",")
+				if name != "" && name != "-" {
+					forms[spec.Name.Name] = append(forms[spec.Name.Name], name)
+				}
+			}
+			return false
+		})
+	}
+	return forms
+}
+
+// stringLiteral returns the value of a string literal
+func stringLiteral(expr ast.Expr) (string, bool) {
+	lit, ok := expr.(*ast.BasicLit)
+	if !ok || lit.Kind != token.STRING {
+		return "", false
+	}
+	value, err := strconv.Unquote(lit.Value)
+	return value, err == nil
+}
+
+// inspect records what a function does with its *gin.Context parameters
+func inspect(fd *ast.FuncDecl, forms map[string][]string) *function {
+	f := &function{
+		name:     funcName(fd),
+		doc:      fd.Doc.Text(),
+		query:    map[string]string{},
+		statuses: map[int]bool{},
+	}
+	if fd.Doc != nil {
+		for _, comment := range fd.Doc.List {
+			if params, ok := strings.CutPrefix(comment.Text, queryDirective); ok {
+				for _, param := range strings.Fields(params) {
+					switch name, key, _ := strings.Cut(param, "["); {
+					case key == "]":
+						f.query[name] = "array"
+					case key != "":
+						f.query[name] = "map"
+					default:
+						f.query[name] = ""
+					}
+				}
+			}
+		}
+	}
+
+	contexts := map[string]bool{}
+	params := 0
+	for _, field := range fd.Type.Params.List {
+		for _, name := range field.Names {
+			params++
+			if isGinContext(field.Type) {
+				contexts[name.Name] = true
+			}
+		}
+	}
+	if len(contexts) == 0 {
+		return f
+	}
+	f.handler = fd.Recv != nil && fd.Name.IsExported() && params == 1 && fd.Type.Results == nil
+
+	receiver := ""
+	if fd.Recv != nil && len(fd.Recv.List[0].Names) > 0 {
+		receiver = fd.Recv.List[0].Names[0].Name
+	}
+	method := func(name string) string {
+		if i := strings.LastIndex(f.name, "."); i >= 0 {
+			return f.name[:i+1] + name
+		}
+		return name
+	}
+
+	// Variables holding the parameters passed to the binding methods: the
+	// values of loops over string literals and the variables of struct types
+	// with form tags
+	loops := map[string][]string{}
+	vars := map[string]string{}
+	ast.Inspect(fd.Body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.RangeStmt:
+			value, ok := n.Value.(*ast.Ident)
+			list, isList := n.X.(*ast.CompositeLit)
+			if !ok || !isList {
+				break
+			}
+			for _, elt := range list.Elts {
+				if name, ok := stringLiteral(elt); ok {
+					loops[value.Name] = append(loops[value.Name], name)
+				}
+			}
+		case *ast.ValueSpec:
+			if t, ok := n.Type.(*ast.Ident); ok {
+				for _, name := range n.Names {
+					vars[name.Name] = t.Name
+				}
+			}
+		}
+		return true
+	})
+
+	ast.Inspect(fd.Body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.SelectorExpr:
+			if pkg, ok := n.X.(*ast.Ident); ok && pkg.Name == "http" {
+				if code, ok := statusCodes[n.Sel.Name]; ok {
+					f.statuses[code] = true
+				}
+			}
+			// c.Request.Body read directly
+			if req, ok := n.X.(*ast.SelectorExpr); ok && req.Sel.Name == "Request" && n.Sel.Name == "Body" {
+				if x, ok := req.X.(*ast.Ident); ok && contexts[x.Name] {
+					f.body = true
+				}
+			}
+		case *ast.CallExpr:
+			switch fun := n.Fun.(type) {
+			case *ast.SelectorExpr:
+				if x, ok := fun.X.(*ast.Ident); ok && contexts[x.Name] {
+					if kind, ok := queryKinds[fun.Sel.Name]; ok && len(n.Args) > 0 {
+						if name, ok := stringLiteral(n.Args[0]); ok {
+							f.query[name] = kind
+						} else if id, ok := n.Args[0].(*ast.Ident); ok {
+							for _, name := range loops[id.Name] {
+								f.query[name] = kind
+							}
+						}
+					}
+					if fun.Sel.Name == "ShouldBindQuery" && len(n.Args) > 0 {
+						if ref, ok := n.Args[0].(*ast.UnaryExpr); ok && ref.Op == token.AND {
+							if id, ok := ref.X.(*ast.Ident); ok {
+								for _, name := range forms[vars[id.Name]] {
+									f.query[name] = ""
+								}
+							}
+						}
+					}
+					if bindMethods[fun.Sel.Name] {
+						f.body = true
+					}
+					// Files are sent with 200
+					if fileMethods[fun.Sel.Name] {
+						f.statuses[200] = true
+					}
+				} else if ok && x.Name == receiver && passesContext(n, contexts) {
+					f.calls = append(f.calls, method(fun.Sel.Name))
+				}
+			case *ast.Ident:
+				if fun.Name == "abortWithError" {
+					f.statuses[500] = true
+				} else if passesContext(n, contexts) {
+					f.calls = append(f.calls, fun.Name)
+				}
+			}
+		}
+		return true
+	})
+	return f
+}
+
+// passesContext reports whether a call has one of the contexts as argument
+func passesContext(call *ast.CallExpr, contexts map[string]bool) bool {
+	for _, arg := range call.Args {
+		if id, ok := arg.(*ast.Ident); ok && contexts[id.Name] {
+			return true
+		}
+	}
+	return false
+}
+
+// collect merges what a function and the functions it passes its context to
+// read and answer
+func collect(functions map[string]*function, name string, seen map[string]bool, query map[string]string, body *bool, statuses map[int]bool) {
+	f, ok := functions[name]
+	if !ok || seen[name] {
+		return
+	}
+	seen[name] = true
+	for param, kind := range f.query {
+		query[param] = kind
+	}
+	*body = *body || f.body
+	for code := range f.statuses {
+		statuses[code] = true
+	}
+	for _, callee := range f.calls {
+		collect(functions, callee, seen, query, body, statuses)
+	}
+}
+
+// describe returns the summary of a handler, the first sentence of its doc
+// comment without the handler's name, and the rest of the comment
+func describe(f *function) (summary, description string) {
+	doc := strings.Join(strings.Fields(f.doc), " ")
+	name := f.name[strings.LastIndex(f.name, ".")+1:]
+	if doc == "" {
+		return splitWords(name), ""
+	}
+	doc = strings.TrimPrefix(doc, name+" ")
+
+	summary, description = doc, ""
+	if i := strings.Index(doc, ". "); i >= 0 {
+		summary, description = doc[:i], doc[i+2:]
+	}
+	summary = strings.TrimSuffix(summary, ".")
+	return capitalize(summary), description
+}
+
+// splitWords turns a handler name such as GetAllProperties into "Get all
+// properties"
+func splitWords(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		if i > 0 && unicode.IsUpper(r) {
+			b.WriteRune(' ')
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func capitalize(s string) string {
+	for i, r := range s {
+		return string(unicode.ToUpper(r)) + s[i+len(string(r)):]
+	}
+	return s
+}
@@ -1,16 +1,29 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"fundamental/server/config"
 	"fundamental/server/internal/api"
+	"fundamental/server/internal/backup"
+	"fundamental/server/internal/cdc"
 	"fundamental/server/internal/database"
+	"fundamental/server/internal/errorsink"
 	"fundamental/server/internal/geocoding"
+	"fundamental/server/internal/live"
+	"fundamental/server/internal/models"
 	"fundamental/server/internal/scheduler"
 	"fundamental/server/internal/scraping"
+	"fundamental/server/internal/selfcheck"
+	"fundamental/server/internal/tasks"
+	"fundamental/server/internal/warehouse"
+	"fundamental/server/internal/workspace"
+	"net/http"
 	"os"
 	"os/signal"
 	"path/filepath"
 	"syscall"
+	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
@@ -22,18 +35,58 @@ func main() {
 	logger.SetFormatter(&logrus.JSONFormatter{})
 	logger.SetOutput(os.Stdout)
 
-	// Get the current working directory
-	currentDir, err := os.Getwd()
+	// Load runtime configuration from the environment and command line
+	cfg, err := config.LoadArgs(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
 	if err != nil {
-		logger.WithError(err).Fatal("Failed to get current directory")
+		os.Exit(2) // the flag package has printed the error and usage
 	}
 
-	// Construct database path relative to the server directory
-	dbPath := filepath.Join(currentDir, "database", "funda.db")
-	logger.Infof("Using database at: %s", dbPath)
+	// Report error logs and panics to the configured error sink. The hook has to
+	// be attached before the per-module loggers below are derived from logger.
+	reporter, err := errorsink.New(errorsink.Config{
+		Enabled:     cfg.ErrorReportingEnabled,
+		SentryDSN:   cfg.SentryDSN,
+		WebhookURL:  cfg.ErrorWebhookURL,
+		Environment: cfg.Environment,
+	}, logger)
+	if err != nil {
+		logger.WithError(err).Fatal("Failed to configure error reporting")
+	}
+	errorsink.SetDefault(reporter)
+	if reporter.Configured() {
+		logger.AddHook(reporter)
+		logger.WithField("enabled", reporter.Enabled()).Info("Error reporting configured")
+	}
+
+	// Resolve the database path against the working directory; a PostgreSQL
+	// backend uses its connection string instead
+	dbPath, err := filepath.Abs(cfg.DatabasePath)
+	if err != nil {
+		logger.WithError(err).Fatal("Failed to resolve database path")
+	}
+	dialect, err := database.NewDialect(cfg.DatabaseDriver)
+	if err != nil {
+		logger.WithError(err).Fatal("Failed to select database driver")
+	}
+	postgres := dialect.Name() == database.DriverPostgres
+	if postgres {
+		logger.Info("Using PostgreSQL database")
+	} else {
+		logger.Infof("Using database at: %s", dbPath)
+	}
+
+	// Replace the database with a backup if a restore was requested
+	if !postgres {
+		if _, err := backup.RestorePending(cfg, dbPath, errorsink.WithModule(logger, "backup")); err != nil {
+			logger.WithError(err).Error("Failed to restore database backup")
+		}
+	}
 
 	// Initialize database
-	db, err := database.NewDatabase(dbPath)
+	db, err := database.OpenFromConfig(cfg, dbPath)
 	if err != nil {
 		logger.WithError(err).Fatal("Failed to initialize database")
 	}
@@ -45,12 +98,54 @@ func main() {
 		logger.WithError(err).Fatal("Failed to run database migrations")
 	}
 
+	// Fail fast when the schema or the database directory is not usable, and
+	// warn about the directories and external tools other features need
+	if cfg.SelfCheckEnabled {
+		dbDir := ""
+		if db.Dialect().Name() == database.DriverSQLite {
+			dbDir = filepath.Dir(dbPath)
+		}
+		report := selfcheck.Run(db, selfcheck.DefaultOptions(dbDir, cfg.GeocodeCacheDir(), cfg.OutputDir))
+		var fatal []models.SelfCheckResult
+		for _, result := range report.Results {
+			entry := logger.WithFields(logrus.Fields{
+				"category": result.Category,
+				"check":    result.Name,
+				"status":   result.Status,
+				"message":  result.Message,
+			})
+			switch {
+			case selfcheck.Fatal(result):
+				fatal = append(fatal, result)
+				entry.Error("Startup self-check")
+			case result.Status == models.CheckFailed:
+				entry.Warn("Startup self-check")
+			default:
+				entry.Info("Startup self-check")
+			}
+		}
+		if len(fatal) > 0 {
+			logger.WithField("failures", fatal).Fatal("Startup self-check failed")
+		}
+	}
+
+	// Take scheduled backups of an SQLite database
+	backups := backup.NewManager(db, cfg, logger)
+	if db.Dialect().Name() == database.DriverSQLite {
+		backups.Start()
+	}
+
 	// Initialize geocoder
-	cacheDir := filepath.Join(os.TempDir(), "fundamental", "geocode_cache")
-	geocoder := geocoding.NewGeocoder(logger, cacheDir)
+	geocoder := geocoding.NewGeocoder(errorsink.WithModule(logger, "geocoding"), cfg.GeocodeCacheDir())
 
 	// Initialize spider manager
-	spiderManager := scraping.NewSpiderManager(db, logger)
+	spiderManager := scraping.NewSpiderManager(db, cfg, errorsink.WithModule(logger, "scraping"))
+
+	// Columnar copy of the properties for analysis, see ANALYTICS_BACKEND
+	analyticsWarehouse, err := warehouse.New(db, cfg, logger)
+	if err != nil {
+		logger.WithError(err).Fatal("Invalid analytics backend")
+	}
 
 	// Initialize scheduler with cities from database
 	cityNames, err := config.GetCityNames(db)
@@ -58,33 +153,82 @@ func main() {
 		logger.WithError(err).Fatal("Failed to get city names for scheduler")
 	}
 	// Note: GetCityNames returns normalized city names suitable for Funda URLs
-	scheduler := scheduler.NewScheduler(spiderManager, db, logger, cityNames)
+	scheduler := scheduler.NewScheduler(spiderManager, db, cfg.OutputDir, errorsink.WithModule(logger, "scheduler"), cityNames)
+	scheduler.EnableArchiving(cfg.ArchiveAfterDays)
+	scheduler.EnableListingExpiry(cfg.ListingExpiryDays)
+	scheduler.EnableRetention(cfg.Retention, cfg.RetentionDryRun)
+	scheduler.EnableAnalyticsExport(analyticsWarehouse)
+	scheduler.EnableVacuum(cfg.MaintenanceVacuum)
+	scheduler.EnablePDOKCache(cfg.PDOKCacheTTL(), cfg.PDOKOffline)
+	for job, expr := range cfg.Schedules {
+		if err := scheduler.SetSchedule(job, expr); err != nil {
+			logger.WithError(err).Fatal("Invalid job schedule")
+		}
+	}
 
 	// Comment out scheduler auto-start - uncomment when needed
 	scheduler.Start()
 	logger.Info("Started scheduler for automated scraping")
 
-	// Start geocoding in a background goroutine
-	go func() {
-		logger.Info("Starting initial geocoding of properties without coordinates in background...")
-		if err := db.UpdateMissingCoordinates(geocoder); err != nil {
-			logger.WithError(err).Error("Failed to update coordinates")
-		}
-	}()
+	// Background tasks such as geocoding runs; the API registers the task kinds
+	taskManager := tasks.NewManager(db, cfg.TaskWorkers, errorsink.WithModule(logger, "tasks"))
+
+	// Mirror property changes to the external sink configured at /api/admin/cdc
+	streamer := cdc.NewStreamer(db, logger)
+	streamer.Start()
+
+	// Push newly scraped properties and job progress to the dashboards
+	// connected to /api/ws and /api/events
+	liveHub := live.NewHub(errorsink.WithModule(logger, "live"))
+	live.SetDefault(liveHub)
+	db.ObserveGeocoding(liveHub)
+
+	// Workspaces keep isolated datasets, each in a database of its own below
+	// WORKSPACES_DIR with its own spiders and schedules
+	workspaces := workspace.NewRegistry(db, cfg, startWorkspace(db, logger), errorsink.WithModule(logger, "workspace"))
+	if err := workspaces.StartAll(); err != nil {
+		logger.WithError(err).Fatal("Failed to start workspaces")
+	}
 
 	// Initialize router
-	router := gin.Default()
+	// Probes are not logged, orchestrators send them every few seconds
+	router := gin.New()
+	router.Use(gin.LoggerWithConfig(gin.LoggerConfig{SkipPaths: []string{"/healthz", "/readyz"}}), gin.Recovery())
+	router.Use(errorsink.GinRecovery())
+	router.Use(api.HandleErrors(logger))
 
 	// Configure CORS
 	corsConfig := cors.DefaultConfig()
 	corsConfig.AllowOrigins = []string{"http://localhost:3004"}
-	corsConfig.AllowMethods = []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}
-	corsConfig.AllowHeaders = []string{"Origin", "Content-Type"}
+	corsConfig.AllowMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}
+	corsConfig.AllowHeaders = []string{
+		"Origin", "Content-Type", "Authorization", "X-Session-ID", api.APIVersionHeader,
+		"If-None-Match", "If-Modified-Since", api.WorkspaceHeader,
+	}
+	corsConfig.ExposeHeaders = []string{api.APIVersionHeader, "ETag", "Last-Modified"}
 	router.Use(cors.New(corsConfig))
 
+	// Resolve admin and read-only API tokens before any route runs
+	router.Use(api.Authenticate(db, cfg, logger))
+	// Hand the requests of a workspace to its own router
+	router.Use(api.SelectWorkspace(workspaces, logger))
+
 	// Setup API routes
-	api.SetupRoutes(router, db)
+	api.SetupRoutes(router, db, cfg, taskManager, streamer, analyticsWarehouse, errorsink.WithModule(logger, "api"))
 	api.SetupMetropolitanRoutes(router, db, geocoder)
+	api.SetupSchedulerRoutes(router, scheduler)
+	api.SetupLiveRoutes(router, liveHub)
+	api.SetupHealthRoutes(router, db)
+	api.SetupWorkspaceRoutes(router, workspaces, db, cfg)
+	// Last, so the API documentation covers every route
+	api.SetupDocsRoutes(router)
+
+	// Start the task workers and queue the initial geocoding of properties
+	// without coordinates
+	taskManager.Start()
+	if _, err := taskManager.Submit("geocode", nil); err != nil {
+		logger.WithError(err).Error("Failed to queue initial geocoding")
+	}
 
 	// Setup graceful shutdown
 	quit := make(chan os.Signal, 1)
@@ -95,13 +239,20 @@ func main() {
 		logger.Info("Shutting down scheduler...")
 		scheduler.Stop()
 		logger.Info("Scheduler stopped")
+		drainNotifications(spiderManager, logger)
+		backups.Stop()
+		taskManager.Stop()
+		streamer.Stop()
+		workspaces.Close()
+		reporter.Close(5 * time.Second)
 		os.Exit(0)
 	}()
 
 	// Use port 5250
 	const port = "5250"
 	logger.Infof("Starting server on port %s", port)
-	if err := router.Run(":" + port); err != nil {
+	// Routes are served under /api/v1/ as well as /api/, see api.Versioned
+	if err := http.ListenAndServe(":"+port, api.Versioned(router)); err != nil {
 		logger.WithError(err).Fatal("Server failed to start")
 	}
 }
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"fundamental/server/internal/scraping"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// drainTimeout bounds how long a shutdown waits for the geocoding of stored
+// listings and their queued Telegram notifications
+const drainTimeout = 5 * time.Second
+
+// drainNotifications sends the Telegram notifications still queued by a
+// spider manager before a shutdown, giving up after drainTimeout
+func drainNotifications(manager *scraping.SpiderManager, logger logrus.FieldLogger) {
+	if !manager.Drain(drainTimeout) {
+		logger.WithField("timeout", drainTimeout).Warn("Shutting down before the queued Telegram notifications were sent")
+	}
+}
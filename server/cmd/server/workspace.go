@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"fundamental/server/config"
+	"fundamental/server/internal/api"
+	"fundamental/server/internal/cdc"
+	"fundamental/server/internal/database"
+	"fundamental/server/internal/errorsink"
+	"fundamental/server/internal/geocoding"
+	"fundamental/server/internal/live"
+	"fundamental/server/internal/models"
+	"fundamental/server/internal/scheduler"
+	"fundamental/server/internal/scraping"
+	"fundamental/server/internal/tasks"
+	"fundamental/server/internal/workspace"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// startWorkspace returns the builder of the workspaces, which starts the
+// services of a workspace the way main starts those of the main dataset: its
+// spiders, scheduler, background tasks, change data capture and live events,
+// and a router serving its API. Tokens are resolved against mainDB, where
+// they are kept. Workspaces have no analytics backend and no scheduled
+// backups.
+func startWorkspace(mainDB *database.Database, logger *logrus.Logger) workspace.Builder {
+	return func(ws models.Workspace, db *database.Database, cfg *config.Config) (http.Handler, func(), error) {
+		hub := live.NewHub(errorsink.WithModule(logger, "live"))
+		db.ObserveGeocoding(hub)
+
+		spiderManager := scraping.NewSpiderManager(db, cfg, errorsink.WithModule(logger, "scraping"))
+		spiderManager.SetLiveHub(hub)
+
+		cityNames, err := config.GetCityNames(db)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to get city names of workspace %s: %v", ws.Slug, err)
+		}
+		jobs := scheduler.NewScheduler(spiderManager, db, cfg.OutputDir, errorsink.WithModule(logger, "scheduler"), cityNames)
+		jobs.EnableArchiving(cfg.ArchiveAfterDays)
+		jobs.EnableListingExpiry(cfg.ListingExpiryDays)
+		jobs.EnableRetention(cfg.Retention, cfg.RetentionDryRun)
+		jobs.EnableVacuum(cfg.MaintenanceVacuum)
+		jobs.EnablePDOKCache(cfg.PDOKCacheTTL(), cfg.PDOKOffline)
+		for job, expr := range cfg.Schedules {
+			if err := jobs.SetSchedule(job, expr); err != nil {
+				return nil, nil, err
+			}
+		}
+
+		taskManager := tasks.NewManager(db, cfg.TaskWorkers, errorsink.WithModule(logger, "tasks"))
+		streamer := cdc.NewStreamer(db, logger)
+
+		router := gin.New()
+		router.Use(gin.Recovery(), errorsink.GinRecovery(), api.HandleErrors(logger))
+		router.Use(api.Authenticate(mainDB, cfg, logger))
+		handler := api.SetupRoutes(router, db, cfg, taskManager, streamer, nil, errorsink.WithModule(logger, "api"))
+		handler.SetLiveHub(hub)
+		api.SetupMetropolitanRoutes(router, db, geocoding.NewGeocoder(errorsink.WithModule(logger, "geocoding"), cfg.GeocodeCacheDir()))
+		api.SetupSchedulerRoutes(router, jobs)
+		api.SetupLiveRoutes(router, hub)
+
+		streamer.Start()
+		jobs.Start()
+		taskManager.Start()
+		logger.WithFields(logrus.Fields{"workspace": ws.Slug, "cities": len(cityNames)}).Info("Started workspace")
+
+		stop := func() {
+			jobs.Stop()
+			drainNotifications(spiderManager, logger.WithField("workspace", ws.Slug))
+			taskManager.Stop()
+			streamer.Stop()
+		}
+		return router, stop, nil
+	}
+}
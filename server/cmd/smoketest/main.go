@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"fundamental/server/config"
+	"fundamental/server/internal/database"
+	"fundamental/server/internal/scraping"
+	"fundamental/server/internal/smoketest"
+	"os"
+	"path/filepath"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Command smoketest replays recorded spider output through the ingestion
+// pipeline into a new database, with stubs for geocoding and Telegram, and
+// compares the outcome with a golden report. It exits with status 1 when they
+// differ. With -update the golden report is written instead.
+//
+// With -record it runs the live spider and records its output as a fixture.
+//
+// Usage:
+//
+//	go run ./cmd/smoketest -fixture testdata/smoke/amsterdam_active.jsonl [-golden testdata/smoke/amsterdam_active.golden.json] [-update]
+//	go run ./cmd/smoketest -record testdata/smoke/amsterdam_active.jsonl -spider active -place amsterdam -max-pages 1
+func main() {
+	logger := logrus.New()
+	logger.SetFormatter(&logrus.JSONFormatter{})
+	logger.SetOutput(os.Stderr)
+
+	fixturePath := flag.String("fixture", "", "recorded spider output to replay, one JSON message per line")
+	goldenPath := flag.String("golden", "", "golden report to compare the outcome with")
+	update := flag.Bool("update", false, "write the outcome to the golden report instead of comparing")
+	recordPath := flag.String("record", "", "run the live spider and record its output to this file")
+	spiderType := flag.String("spider", "active", "spider type of the run: active, sold or refresh")
+	place := flag.String("place", "amsterdam", "normalized place of the run")
+	maxPages := flag.Int("max-pages", 0, "result pages the recorded spider scrapes, 0 for all")
+	dbPath := flag.String("db", "", "database to store the replayed items in, kept afterwards; empty uses a new one")
+	verbose := flag.Bool("v", false, "log the pipeline at info level")
+	flag.Parse()
+
+	logger.SetLevel(logrus.WarnLevel)
+	if *verbose {
+		logger.SetLevel(logrus.InfoLevel)
+	}
+
+	cfg := config.Load()
+	params := scraping.SpiderParams{SpiderType: *spiderType, Place: *place}
+	if *maxPages > 0 {
+		params.MaxPages = maxPages
+	}
+
+	switch {
+	case *recordPath != "":
+		record(cfg, *recordPath, params, logger)
+	case *fixturePath != "":
+		replay(cfg, *fixturePath, *goldenPath, *update, *dbPath, params, logger)
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+}
+
+// record runs the live spider into a scratch database and writes its output
+// to path
+func record(cfg *config.Config, path string, params scraping.SpiderParams, logger *logrus.Logger) {
+	file, err := os.Create(path)
+	if err != nil {
+		logger.WithError(err).Fatal("Failed to create fixture")
+	}
+	defer file.Close()
+
+	dir, err := os.MkdirTemp("", "fundamental-record-*")
+	if err != nil {
+		logger.WithError(err).Fatal("Failed to create working directory")
+	}
+	defer os.RemoveAll(dir)
+	cfg.DatabaseDriver = database.DriverSQLite
+	db, err := database.OpenFromConfig(cfg, filepath.Join(dir, "record.db"))
+	if err != nil {
+		logger.WithError(err).Fatal("Failed to initialize database")
+	}
+	defer db.Close()
+	if err := db.RunMigrations(); err != nil {
+		logger.WithError(err).Fatal("Failed to run database migrations")
+	}
+
+	manager := scraping.NewSpiderManager(db, cfg, logger)
+	manager.RecordTo(file)
+	if err := manager.RunSpider(params); err != nil {
+		logger.WithError(err).Fatal("Spider failed")
+	}
+	manager.Wait()
+	logger.WithField("fixture", path).Warn("Recorded spider output")
+}
+
+// replay replays a fixture and compares or writes the golden report
+func replay(cfg *config.Config, fixturePath, goldenPath string, update bool, dbPath string, params scraping.SpiderParams, logger *logrus.Logger) {
+	fixture, err := os.Open(fixturePath)
+	if err != nil {
+		logger.WithError(err).Fatal("Failed to open fixture")
+	}
+	defer fixture.Close()
+
+	report, err := smoketest.Replay(cfg, fixture, smoketest.Options{Params: params, DatabasePath: dbPath}, logger)
+	if err != nil {
+		logger.WithError(err).Fatal("Smoke test failed")
+	}
+	outcome, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		logger.WithError(err).Fatal("Failed to encode report")
+	}
+	outcome = append(outcome, '\n')
+
+	switch {
+	case goldenPath == "":
+		os.Stdout.Write(outcome)
+	case update:
+		if err := os.WriteFile(goldenPath, outcome, 0644); err != nil {
+			logger.WithError(err).Fatal("Failed to write golden report")
+		}
+		fmt.Printf("Wrote %s\n", goldenPath)
+	default:
+		golden, err := os.ReadFile(goldenPath)
+		if err != nil {
+			logger.WithError(err).Fatal("Failed to read golden report")
+		}
+		if !bytes.Equal(golden, outcome) {
+			fmt.Printf("Outcome differs from %s\n--- golden\n%s--- outcome\n%s", goldenPath, golden, outcome)
+			os.Exit(1)
+		}
+		fmt.Printf("OK, outcome matches %s\n", goldenPath)
+	}
+}
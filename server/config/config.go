@@ -0,0 +1,372 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config holds runtime settings loaded from environment variables
+type Config struct {
+	// Where the server keeps its data. Relative paths are resolved against the
+	// working directory. DatabasePath is the SQLite file, CacheDir holds caches
+	// that can be rebuilt (geocoding, map tiles) and OutputDir the generated
+	// files served to the client, such as the district hulls.
+	DatabasePath string
+	CacheDir     string
+	OutputDir    string
+
+	// Storage backend: "sqlite" (default) or "postgres". DatabaseURL is the
+	// PostgreSQL connection string and is ignored for SQLite.
+	DatabaseDriver string
+	DatabaseURL    string
+
+	// SQLCipher key for an encrypted SQLite database; empty for a plain database
+	DatabaseKey string
+
+	// SQLite journal mode and lock wait, and connection pool limits
+	DatabaseJournalMode   string
+	DatabaseBusyTimeoutMs int
+	DatabaseMaxOpenConns  int
+	DatabaseMaxIdleConns  int
+
+	// Read replica for the API's GET requests: a second, read-only connection
+	// pool on the SQLite file, or the connection string of a PostgreSQL replica
+	DatabaseReadReplica bool
+	DatabaseReadURL     string
+
+	// Seconds the dashboard stats are cached while the properties do not
+	// change; 0 disables the cache
+	StatsCacheTTLSeconds int
+
+	// Seconds a query run for an API request may take before it is cancelled;
+	// 0 leaves queries to run until the request ends
+	DatabaseQueryTimeoutSeconds int
+
+	// Validate schema, directories and external tools on startup
+	SelfCheckEnabled bool
+
+	// Raw spider payload snapshots kept for auditing and debugging
+	SnapshotsEnabled       bool
+	SnapshotRetentionDays  int
+	SnapshotMaxPerProperty int
+
+	// SQLite backups: directory, hours between scheduled backups (0 disables
+	// the schedule) and number of backups kept
+	BackupDir           string
+	BackupIntervalHours int
+	BackupRetention     int
+
+	// Directory the export archives built by background tasks are written to
+	ExportDir string
+
+	// Directory the workspaces keep their databases and files in, one
+	// directory per workspace
+	WorkspacesDir string
+
+	// Days after which an inactive property that has not been updated is
+	// moved to the archive; 0 disables the nightly archiving job
+	ArchiveAfterDays int
+
+	// Days after which a listing that no spider has seen is marked as
+	// expired; 0 disables the nightly expiry job
+	ListingExpiryDays int
+
+	// Maximum age in days of the rows of a dataset, by dataset name, enforced
+	// by the nightly retention job; datasets without a rule are kept forever
+	Retention map[string]int
+
+	// Only report what the retention job would delete
+	RetentionDryRun bool
+
+	// Number of background tasks (geocoding runs, backups, ...) run at once
+	TaskWorkers int
+
+	// Vacuum the database during the scheduled maintenance
+	MaintenanceVacuum bool
+
+	// Columnar copy of the properties for analysis, refreshed nightly:
+	// "duckdb" or "clickhouse" (empty disables it). DuckDB files are written
+	// with the duckdb command-line tool; AnalyticsURL is the ClickHouse HTTP
+	// endpoint. AnalyticsRouteQueries answers the trend and time series
+	// endpoints from the copy.
+	AnalyticsBackend      string
+	AnalyticsURL          string
+	AnalyticsTable        string
+	AnalyticsDuckDBPath   string
+	DuckDBBinary          string
+	AnalyticsRouteQueries bool
+
+	// Read-only SQL endpoint for analysts (disabled by default), with the most
+	// rows a query returns and how long it may run
+	SQLQueryEnabled        bool
+	SQLQueryMaxRows        int
+	SQLQueryTimeoutSeconds int
+
+	// Cron expressions overriding the default schedule of scheduler jobs, by
+	// job name
+	Schedules map[string]string
+
+	// Upper bound on points returned by the scatter sampling endpoint
+	ScatterMaxPoints int
+
+	// Static map image attached to Telegram notifications, rendered from map
+	// tiles around the listing and cached on disk
+	TelegramStaticMaps bool
+	StaticMapTileURL   string
+	StaticMapCacheDir  string
+	StaticMapZoom      int
+
+	// Street-level photos attached to geocoded properties: "mapillary" or
+	// "google" (empty disables them), with the Mapillary access token or Google
+	// API key. TelegramStreetImages links the photo in notifications.
+	StreetImageProvider  string
+	StreetImageToken     string
+	TelegramStreetImages bool
+
+	// Add a suggested bid range to Telegram notifications of new listings
+	TelegramBidAdvice bool
+
+	// Directory the primary photos of listings are downscaled and cached in;
+	// empty disables the cache. TelegramListingPhotos sends the primary photo
+	// with notifications, from the cache when enabled.
+	ThumbnailCacheDir     string
+	TelegramListingPhotos bool
+
+	// External scoring model: the features of each new property are posted to
+	// ScoringURL (empty disables scoring), with ScoringToken as bearer token,
+	// and the returned scores stored for filtering and sorting. Each request
+	// may take ScoringTimeoutSeconds and is retried ScoringRetries times.
+	ScoringURL            string
+	ScoringToken          string
+	ScoringTimeoutSeconds int
+	ScoringRetries        int
+
+	// Spider runs covering less than this share of the listings Funda reports
+	// for a city are logged and sent to Telegram; 0 disables the alert
+	ScrapeCoverageAlert float64
+
+	// Days the PDOK points of a district are cached for the district hulls
+	// update (0 disables the cache), and whether the update uses cached
+	// points only, without requests to PDOK
+	PDOKCacheTTLDays int
+	PDOKOffline      bool
+
+	// Bearer token for the admin API; the admin routes are disabled when empty.
+	// AuthRequired rejects requests without a valid admin or API token.
+	AdminToken   string
+	AuthRequired bool
+
+	// Error reporting to Sentry and/or a JSON webhook; can be toggled at runtime
+	ErrorReportingEnabled bool
+	SentryDSN             string
+	ErrorWebhookURL       string
+	Environment           string
+}
+
+// pathFlags are the data locations set on the command line
+type pathFlags struct {
+	databasePath string
+	cacheDir     string
+	outputDir    string
+}
+
+// Load reads the configuration from the environment, falling back to defaults
+func Load() *Config {
+	return load(pathFlags{})
+}
+
+// LoadArgs reads the configuration like Load, with the -db, -cache-dir and
+// -output-dir command-line flags overriding DB_PATH, CACHE_DIR and OUTPUT_DIR
+func LoadArgs(args []string) (*Config, error) {
+	var paths pathFlags
+	flags := flag.NewFlagSet("server", flag.ContinueOnError)
+	flags.StringVar(&paths.databasePath, "db", "", "path to the SQLite database (default $DB_PATH or database/funda.db)")
+	flags.StringVar(&paths.cacheDir, "cache-dir", "", "directory of the geocoding and map caches (default $CACHE_DIR or <tmp>/fundamental)")
+	flags.StringVar(&paths.outputDir, "output-dir", "", "directory generated client files are written to (default $OUTPUT_DIR or ../client/public)")
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+	return load(paths), nil
+}
+
+func load(paths pathFlags) *Config {
+	databasePath := firstNonEmpty(paths.databasePath, getEnv("DB_PATH", filepath.Join("database", "funda.db")))
+	cacheDir := firstNonEmpty(paths.cacheDir, getEnv("CACHE_DIR", filepath.Join(os.TempDir(), "fundamental")))
+	return &Config{
+		DatabasePath:                databasePath,
+		CacheDir:                    cacheDir,
+		OutputDir:                   firstNonEmpty(paths.outputDir, getEnv("OUTPUT_DIR", filepath.Join("..", "client", "public"))),
+		DatabaseDriver:              getEnv("DB_DRIVER", "sqlite"),
+		DatabaseURL:                 getEnv("DATABASE_URL", ""),
+		DatabaseKey:                 os.Getenv("DB_ENCRYPTION_KEY"),
+		DatabaseJournalMode:         getEnv("DB_JOURNAL_MODE", "WAL"),
+		DatabaseBusyTimeoutMs:       getEnvInt("DB_BUSY_TIMEOUT_MS", 5000),
+		DatabaseMaxOpenConns:        getEnvInt("DB_MAX_OPEN_CONNS", 10),
+		DatabaseMaxIdleConns:        getEnvInt("DB_MAX_IDLE_CONNS", 5),
+		DatabaseReadReplica:         getEnvBool("DB_READ_REPLICA", false),
+		DatabaseReadURL:             getEnv("DATABASE_READ_URL", ""),
+		StatsCacheTTLSeconds:        getEnvInt("STATS_CACHE_TTL_SECONDS", 300),
+		DatabaseQueryTimeoutSeconds: getEnvInt("DB_QUERY_TIMEOUT_SECONDS", 30),
+		SelfCheckEnabled:            getEnvBool("SELF_CHECK_ENABLED", true),
+		SnapshotsEnabled:            getEnvBool("SNAPSHOTS_ENABLED", false),
+		SnapshotRetentionDays:       getEnvInt("SNAPSHOT_RETENTION_DAYS", 90),
+		SnapshotMaxPerProperty:      getEnvInt("SNAPSHOT_MAX_PER_PROPERTY", 20),
+		BackupDir:                   getEnv("BACKUP_DIR", filepath.Join(filepath.Dir(databasePath), "backups")),
+		BackupIntervalHours:         getEnvInt("BACKUP_INTERVAL_HOURS", 24),
+		BackupRetention:             getEnvInt("BACKUP_RETENTION", 7),
+		ExportDir:                   getEnv("EXPORT_DIR", filepath.Join(filepath.Dir(databasePath), "exports")),
+		WorkspacesDir:               getEnv("WORKSPACES_DIR", filepath.Join(filepath.Dir(databasePath), "workspaces")),
+		ArchiveAfterDays:            getEnvInt("ARCHIVE_AFTER_DAYS", 730),
+		ListingExpiryDays:           getEnvInt("LISTING_EXPIRY_DAYS", 0),
+		Retention:                   getRetention(),
+		RetentionDryRun:             getEnvBool("RETENTION_DRY_RUN", false),
+		TaskWorkers:                 getEnvInt("TASK_WORKERS", 2),
+		MaintenanceVacuum:           getEnvBool("MAINTENANCE_VACUUM", false),
+		AnalyticsBackend:            strings.ToLower(getEnv("ANALYTICS_BACKEND", "")),
+		AnalyticsURL:                os.Getenv("ANALYTICS_URL"),
+		AnalyticsTable:              getEnv("ANALYTICS_TABLE", "properties"),
+		AnalyticsDuckDBPath:         getEnv("ANALYTICS_DUCKDB_PATH", filepath.Join(filepath.Dir(databasePath), "analytics.duckdb")),
+		DuckDBBinary:                getEnv("DUCKDB_BINARY", "duckdb"),
+		AnalyticsRouteQueries:       getEnvBool("ANALYTICS_ROUTE_QUERIES", false),
+		SQLQueryEnabled:             getEnvBool("SQL_QUERY_ENABLED", false),
+		SQLQueryMaxRows:             getEnvInt("SQL_QUERY_MAX_ROWS", 10000),
+		SQLQueryTimeoutSeconds:      getEnvInt("SQL_QUERY_TIMEOUT_SECONDS", 10),
+		Schedules:                   getSchedules(),
+		ScatterMaxPoints:            getEnvInt("SCATTER_MAX_POINTS", 2000),
+		TelegramStaticMaps:          getEnvBool("TELEGRAM_STATIC_MAPS", false),
+		StaticMapTileURL:            getEnv("STATIC_MAP_TILE_URL", "https://tile.openstreetmap.org/{z}/{x}/{y}.png"),
+		StaticMapCacheDir:           getEnv("STATIC_MAP_CACHE_DIR", filepath.Join(cacheDir, "map_cache")),
+		StaticMapZoom:               getEnvInt("STATIC_MAP_ZOOM", 16),
+		StreetImageProvider:         strings.ToLower(getEnv("STREET_IMAGE_PROVIDER", "")),
+		StreetImageToken:            os.Getenv("STREET_IMAGE_TOKEN"),
+		TelegramStreetImages:        getEnvBool("TELEGRAM_STREET_IMAGES", false),
+		TelegramBidAdvice:           getEnvBool("TELEGRAM_BID_ADVICE", false),
+		ThumbnailCacheDir:           getEnv("THUMBNAIL_CACHE_DIR", ""),
+		TelegramListingPhotos:       getEnvBool("TELEGRAM_LISTING_PHOTOS", false),
+		ScoringURL:                  getEnv("SCORING_URL", ""),
+		ScoringToken:                os.Getenv("SCORING_TOKEN"),
+		ScoringTimeoutSeconds:       getEnvInt("SCORING_TIMEOUT_SECONDS", 10),
+		ScoringRetries:              getEnvInt("SCORING_RETRIES", 2),
+		ScrapeCoverageAlert:         getEnvFloat("SCRAPE_COVERAGE_ALERT", 0.8),
+		PDOKCacheTTLDays:            getEnvInt("PDOK_CACHE_TTL_DAYS", 90),
+		PDOKOffline:                 getEnvBool("PDOK_OFFLINE", false),
+		AdminToken:                  os.Getenv("ADMIN_TOKEN"),
+		AuthRequired:                getEnvBool("AUTH_REQUIRED", false),
+		ErrorReportingEnabled:       getEnvBool("ERROR_REPORTING_ENABLED", true),
+		SentryDSN:                   getEnv("SENTRY_DSN", ""),
+		ErrorWebhookURL:             getEnv("ERROR_WEBHOOK_URL", ""),
+		Environment:                 getEnv("ENVIRONMENT", "production"),
+	}
+}
+
+// scheduledJobs are the scheduler jobs whose schedule can be set with a
+// SCHEDULE_<JOB> environment variable
+var scheduledJobs = []string{"active", "sold", "refresh", "district_hulls", "expire_listings", "archive", "retention", "analytics_export", "maintenance", "integrity_check"}
+
+// getSchedules returns the cron expressions set for scheduler jobs by name
+func getSchedules() map[string]string {
+	schedules := make(map[string]string)
+	for _, job := range scheduledJobs {
+		if expr := os.Getenv("SCHEDULE_" + strings.ToUpper(job)); expr != "" {
+			schedules[job] = expr
+		}
+	}
+	return schedules
+}
+
+// retentionDatasets are the datasets whose maximum age can be set with a
+// RETENTION_<DATASET>_DAYS environment variable
+var retentionDatasets = []string{
+	"property_history", "property_history_archive", "price_changes", "audit_log",
+	"rejected_items", "watch_alerts", "tasks", "shared_views", "spider_runs",
+}
+
+// getRetention returns the maximum age in days set for datasets by name
+func getRetention() map[string]int {
+	rules := make(map[string]int)
+	for _, dataset := range retentionDatasets {
+		if days := getEnvInt("RETENTION_"+strings.ToUpper(dataset)+"_DAYS", 0); days > 0 {
+			rules[dataset] = days
+		}
+	}
+	return rules
+}
+
+// GeocodeCacheDir is the directory of the geocoding cache
+func (c *Config) GeocodeCacheDir() string {
+	return filepath.Join(c.CacheDir, "geocode_cache")
+}
+
+// ForWorkspace returns the configuration of a workspace: a copy of c that
+// keeps its SQLite database, backups, exports and generated files in the
+// directory of the workspace. Workspaces do not use a read replica or an
+// analytics backend.
+func (c *Config) ForWorkspace(slug string) *Config {
+	dir := filepath.Join(c.WorkspacesDir, slug)
+	workspace := *c
+	workspace.DatabasePath = filepath.Join(dir, "funda.db")
+	workspace.DatabaseDriver = "sqlite"
+	workspace.DatabaseReadReplica = false
+	workspace.OutputDir = filepath.Join(dir, "output")
+	workspace.BackupDir = filepath.Join(dir, "backups")
+	workspace.ExportDir = filepath.Join(dir, "exports")
+	workspace.AnalyticsBackend = ""
+	if c.ThumbnailCacheDir != "" {
+		workspace.ThumbnailCacheDir = filepath.Join(dir, "thumbnails")
+	}
+	return &workspace
+}
+
+// PDOKCacheTTL is how long cached PDOK points are used
+func (c *Config) PDOKCacheTTL() time.Duration {
+	return time.Duration(c.PDOKCacheTTLDays) * 24 * time.Hour
+}
+
+// firstNonEmpty returns the first of values that is not empty
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// getEnv returns the value of an environment variable or a fallback
+func getEnv(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok && strings.TrimSpace(value) != "" {
+		return strings.TrimSpace(value)
+	}
+	return fallback
+}
+
+// getEnvInt returns an integer environment variable or a fallback
+func getEnvInt(key string, fallback int) int {
+	value, err := strconv.Atoi(getEnv(key, ""))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+// getEnvFloat returns a decimal environment variable or a fallback
+func getEnvFloat(key string, fallback float64) float64 {
+	value, err := strconv.ParseFloat(getEnv(key, ""), 64)
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+// getEnvBool returns a boolean environment variable or a fallback
+func getEnvBool(key string, fallback bool) bool {
+	value, err := strconv.ParseBool(getEnv(key, ""))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
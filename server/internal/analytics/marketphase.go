@@ -0,0 +1,107 @@
+package analytics
+
+import (
+	"fundamental/server/internal/models"
+	"math"
+)
+
+// DefaultWindowDays is the period the market phase is measured over
+const DefaultWindowDays = 90
+
+// PhaseThreshold is the mean component score from which a market counts as a
+// seller's market, or below its negative as a buyer's market
+const PhaseThreshold = 0.25
+
+// MinComponents is the number of components with enough data a phase needs
+const MinComponents = 2
+
+// Component scales: the value at which a component scores 1 (seller's
+// market); the mirrored value scores -1
+const (
+	// Inventory shrinking by 20% over the window
+	inventoryScale = 0.20
+	// Median of 30 days to sell, against 90 for a buyer's market
+	daysToSellNeutral = 60.0
+	daysToSellScale   = 30.0
+	// 60% of homes selling over asking, against 20%
+	overAskingNeutral = 0.40
+	overAskingScale   = 0.20
+	// Median price per m² rising 5% over the window
+	momentumScale = 0.05
+)
+
+// MarketPhase scores the indicators of a city and combines the components
+// that have enough data into a phase, which stays unknown with fewer than
+// MinComponents of them. Values are rounded to three decimals;
+// trends and shares are fractions.
+func MarketPhase(in models.MarketIndicators) models.MarketPhase {
+	phase := models.MarketPhase{
+		City:       in.City,
+		District:   in.District,
+		Phase:      models.MarketPhaseUnknown,
+		WindowDays: in.WindowDays,
+		Inventory:  in.Inventory,
+		Sales:      in.Sales,
+	}
+
+	var inventoryTrend, momentum *float64
+	if in.PriorInventory > 0 {
+		inventoryTrend = float(float64(in.Inventory)/float64(in.PriorInventory) - 1)
+	}
+	if in.PricePerSqm != nil && in.PriorPricePerSqm != nil && *in.PriorPricePerSqm > 0 {
+		momentum = float(*in.PricePerSqm / *in.PriorPricePerSqm - 1)
+	}
+
+	components := []struct {
+		name  string
+		value *float64
+		score func(v float64) float64
+	}{
+		{models.MarketComponentInventory, inventoryTrend, func(v float64) float64 { return -v / inventoryScale }},
+		{models.MarketComponentDaysToSell, in.MedianDaysToSell, func(v float64) float64 { return (daysToSellNeutral - v) / daysToSellScale }},
+		{models.MarketComponentOverAsking, in.OverAskingShare, func(v float64) float64 { return (v - overAskingNeutral) / overAskingScale }},
+		{models.MarketComponentPriceMomentum, momentum, func(v float64) float64 { return v / momentumScale }},
+	}
+
+	var total float64
+	var scored int
+	for _, c := range components {
+		component := models.MarketPhaseComponent{Component: c.name}
+		if c.value != nil {
+			score := clamp(c.score(*c.value), -1, 1)
+			component.Value = float(round(*c.value))
+			component.Score = float(round(score))
+			total += score
+			scored++
+		}
+		phase.Components = append(phase.Components, component)
+	}
+	if scored < MinComponents {
+		return phase
+	}
+
+	score := total / float64(scored)
+	phase.Score = float(round(score))
+	switch {
+	case score >= PhaseThreshold:
+		phase.Phase = models.MarketPhaseSellers
+	case score <= -PhaseThreshold:
+		phase.Phase = models.MarketPhaseBuyers
+	default:
+		phase.Phase = models.MarketPhaseBalanced
+	}
+	return phase
+}
+
+func float(v float64) *float64 {
+	return &v
+}
+
+func round(v float64) float64 {
+	return math.Round(v*1000) / 1000
+}
+
+// clamp limits value to the range [low, high]
+func clamp(value, low, high float64) float64 {
+	return math.Max(low, math.Min(high, value))
+}
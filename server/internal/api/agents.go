@@ -0,0 +1,43 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListAgents returns the agents with the stats of their listings, the agents
+// with the most listings first
+func (h *Handler) ListAgents(c *gin.Context) {
+	agents, err := h.dbFor(c).ListAgentStats()
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to list agents")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list agents"})
+		return
+	}
+
+	c.JSON(http.StatusOK, agents)
+}
+
+// GetAgent returns an agent with the stats of its listings
+func (h *Handler) GetAgent(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid agent ID"})
+		return
+	}
+
+	agent, err := h.dbFor(c).GetAgentStats(id)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get agent")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get agent"})
+		return
+	}
+	if agent == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Agent not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, agent)
+}
@@ -0,0 +1,13 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetAnalyticsStatus returns the configured analytics backend and the outcome
+// of the last export to it. Submit an analytics_export task to export now.
+func (h *Handler) GetAnalyticsStatus(c *gin.Context) {
+	c.JSON(http.StatusOK, h.warehouse.Status())
+}
@@ -0,0 +1,36 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultArchiveDays is the archive threshold when ARCHIVE_AFTER_DAYS disables
+// the nightly job
+const defaultArchiveDays = 730
+
+// ArchiveProperties moves inactive properties that have not been updated for
+// days days (default ARCHIVE_AFTER_DAYS) to the archive now
+func (h *Handler) ArchiveProperties(c *gin.Context) {
+	fallback := h.cfg.ArchiveAfterDays
+	if fallback <= 0 {
+		fallback = defaultArchiveDays
+	}
+	days, err := strconv.Atoi(c.DefaultQuery("days", strconv.Itoa(fallback)))
+	if err != nil || days <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Days must be a positive number"})
+		return
+	}
+
+	archived, err := h.dbFor(c).ArchiveStaleProperties(days)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to archive properties")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to archive properties"})
+		return
+	}
+
+	h.logger.WithField("archived", archived).Info("Archived stale properties")
+	c.JSON(http.StatusOK, gin.H{"archived": archived, "days": days})
+}
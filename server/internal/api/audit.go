@@ -0,0 +1,103 @@
+package api
+
+import (
+	"fundamental/server/internal/models"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+)
+
+// auditFilter parses the audit log query parameters: field, source, run_id,
+// since (a date or RFC 3339 time), before_id and limit
+func auditFilter(c *gin.Context) (models.AuditFilter, bool) {
+	filter := models.AuditFilter{
+		Field:  c.Query("field"),
+		Source: c.Query("source"),
+		RunID:  c.Query("run_id"),
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultAuditLimit)))
+	if err != nil || limit <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Limit must be a positive number"})
+		return filter, false
+	}
+	filter.Limit = min(limit, maxAuditLimit)
+
+	if raw := c.Query("before_id"); raw != "" {
+		beforeID, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || beforeID <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "before_id must be a positive number"})
+			return filter, false
+		}
+		filter.BeforeID = beforeID
+	}
+
+	if raw := c.Query("since"); raw != "" {
+		since, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			since, err = time.Parse("2006-01-02", raw)
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "since must be a date (YYYY-MM-DD) or an RFC 3339 time"})
+			return filter, false
+		}
+		filter.Since = &since
+	}
+	return filter, true
+}
+
+// GetAuditLog returns the field changes made by spider runs and imports,
+// newest first. Pass the id of the last entry as before_id for the next page.
+func (h *Handler) GetAuditLog(c *gin.Context) {
+	filter, ok := auditFilter(c)
+	if !ok {
+		return
+	}
+	if raw := c.Query("property_id"); raw != "" {
+		id, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid property ID"})
+			return
+		}
+		filter.PropertyID = id
+	}
+
+	entries, err := h.dbFor(c).ListAuditLog(filter)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get audit log")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get audit log"})
+		return
+	}
+
+	c.JSON(http.StatusOK, entries)
+}
+
+// GetPropertyAuditLog returns the field changes of one property, newest first
+func (h *Handler) GetPropertyAuditLog(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid property ID"})
+		return
+	}
+	filter, ok := auditFilter(c)
+	if !ok {
+		return
+	}
+	filter.PropertyID = id
+
+	entries, err := h.dbFor(c).ListAuditLog(filter)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get property audit log")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get property audit log"})
+		return
+	}
+
+	c.JSON(http.StatusOK, entries)
+}
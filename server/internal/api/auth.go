@@ -0,0 +1,105 @@
+package api
+
+import (
+	"crypto/subtle"
+	"fundamental/server/config"
+	"fundamental/server/internal/database"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	adminContextKey    = "auth_admin"
+	apiTokenContextKey = "auth_api_token"
+)
+
+// bearerToken returns the token of an "Authorization: Bearer ..." header.
+// Browsers cannot set headers on WebSockets and EventSources, so their
+// requests may pass the token as ?access_token= instead.
+func bearerToken(c *gin.Context) string {
+	header := c.GetHeader("Authorization")
+	if len(header) > 7 && strings.EqualFold(header[:7], "Bearer ") {
+		return strings.TrimSpace(header[7:])
+	}
+	if strings.EqualFold(c.GetHeader("Upgrade"), "websocket") ||
+		strings.Contains(c.GetHeader("Accept"), "text/event-stream") {
+		return c.Query("access_token")
+	}
+	return ""
+}
+
+// readOnlyPostPrefixes are endpoints that take POST requests but never modify data
+var readOnlyPostPrefixes = []string{"/api/grafana/", "/api/query", "/api/properties/batch"}
+
+func isReadOnlyPost(path string) bool {
+	for _, prefix := range readOnlyPostPrefixes {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Authenticate resolves the bearer token of a request. The admin token grants
+// full access; read-only API tokens only allow GET requests, and POSTs to read-only
+// endpoints such as the Grafana datasource and read-only SQL, within their scopes.
+// Requests without a token pass through unless AUTH_REQUIRED is set.
+func Authenticate(db database.TokenStore, cfg *config.Config, logger *logrus.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := bearerToken(c)
+		if token == "" {
+			if cfg.AuthRequired && strings.HasPrefix(c.Request.URL.Path, "/api/") && !isDocsPath(c.Request.URL.Path) {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+				return
+			}
+			c.Next()
+			return
+		}
+
+		if cfg.AdminToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(cfg.AdminToken)) == 1 {
+			c.Set(adminContextKey, true)
+			c.Next()
+			return
+		}
+
+		apiToken, err := db.AuthenticateAPIToken(token)
+		if err != nil {
+			logger.WithError(err).Error("Failed to authenticate API token")
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to authenticate"})
+			return
+		}
+		if apiToken == nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
+			return
+		}
+
+		path := c.Request.URL.Path
+		readOnly := c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead ||
+			(c.Request.Method == http.MethodPost && isReadOnlyPost(path))
+		if !readOnly || strings.HasPrefix(path, "/api/admin") || !apiToken.Allows(path) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Token does not grant access to this endpoint"})
+			return
+		}
+
+		c.Set(apiTokenContextKey, apiToken)
+		c.Next()
+	}
+}
+
+// RequireAdmin only lets requests authenticated with the admin token through
+func RequireAdmin(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if cfg.AdminToken == "" {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Admin API is disabled; set ADMIN_TOKEN to enable it"})
+			return
+		}
+		if !c.GetBool(adminContextKey) {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Admin token required"})
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,71 @@
+package api
+
+import (
+	"fundamental/server/internal/database"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// backupsSupported rejects backup requests for a PostgreSQL backend
+func (h *Handler) backupsSupported(c *gin.Context) bool {
+	if _, ok := h.requireDatabase(c); !ok {
+		return false
+	}
+	if h.dbFor(c).Dialect().Name() != database.DriverSQLite {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "Backups are only supported for SQLite; use pg_dump for PostgreSQL"})
+		return false
+	}
+	return true
+}
+
+// ListBackups returns the backups in the backup directory, newest first
+func (h *Handler) ListBackups(c *gin.Context) {
+	if !h.backupsSupported(c) {
+		return
+	}
+	backups, err := h.backups.List()
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to list backups")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list backups"})
+		return
+	}
+	c.JSON(http.StatusOK, backups)
+}
+
+// CreateBackup takes a backup of the database now
+func (h *Handler) CreateBackup(c *gin.Context) {
+	if !h.backupsSupported(c) {
+		return
+	}
+	backup, err := h.backups.Create()
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to create backup")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create backup"})
+		return
+	}
+	h.logger.WithFields(logrus.Fields{
+		"backup": backup.Name,
+		"size":   backup.Size,
+	}).Info("Created backup")
+	c.JSON(http.StatusCreated, backup)
+}
+
+// RestoreBackup validates a backup and schedules it to replace the database
+// on the next start
+func (h *Handler) RestoreBackup(c *gin.Context) {
+	if !h.backupsSupported(c) {
+		return
+	}
+	name := c.Param("name")
+	if err := h.backups.RequestRestore(name, h.cfg.DatabaseKey); err != nil {
+		h.logger.WithError(err).WithField("backup", name).Warn("Rejected backup restore")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Backup cannot be restored: " + err.Error()})
+		return
+	}
+	h.logger.WithField("backup", name).Info("Scheduled backup restore")
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": "Backup " + name + " will be restored when the server restarts",
+	})
+}
@@ -0,0 +1,61 @@
+package api
+
+import (
+	"fundamental/server/internal/buyrent"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Sources of the purchase price of a buy-vs-rent comparison
+const (
+	priceSourceGiven    = "given"
+	priceSourceDistrict = "district_sales"
+)
+
+// buyRentResponse is a buy-vs-rent comparison with where its purchase price
+// came from
+type buyRentResponse struct {
+	*buyrent.Comparison
+	PriceSource string `json:"price_source"`
+	Comparables int    `json:"comparables,omitempty"`
+}
+
+// CompareBuyRent compares the long-run cost of buying and renting a home under
+// the given assumptions. Without a purchase price the price is estimated from
+// the district's sales of the past year.
+func (h *Handler) CompareBuyRent(c *gin.Context) {
+	var scenario buyrent.Scenario
+	if err := c.ShouldBindJSON(&scenario); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	scenario.District = strings.TrimSpace(scenario.District)
+
+	response := buyRentResponse{PriceSource: priceSourceGiven}
+	if scenario.PurchasePrice <= 0 && scenario.District != "" && scenario.LivingArea > 0 {
+		estimate, comparables, err := h.dbFor(c).EstimateDistrictPrice(scenario.District, scenario.LivingArea)
+		if err != nil {
+			h.logger.WithError(err).Error("Failed to estimate district price")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to estimate district price"})
+			return
+		}
+		if comparables == 0 {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Not enough recent sales in the district to estimate a price"})
+			return
+		}
+		scenario.PurchasePrice = float64(estimate)
+		response.PriceSource = priceSourceDistrict
+		response.Comparables = comparables
+	}
+
+	comparison, err := buyrent.Compare(scenario)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	response.Comparison = comparison
+
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,63 @@
+package api
+
+import (
+	"fundamental/server/internal/cdc"
+	"fundamental/server/internal/models"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetCDCSink returns the change data capture sink with its lag, with the
+// password of its URL masked
+func (h *Handler) GetCDCSink(c *gin.Context) {
+	status, err := h.cdc.Status()
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get CDC status")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get CDC status"})
+		return
+	}
+	if status == nil {
+		c.JSON(http.StatusOK, gin.H{"sink": nil})
+		return
+	}
+
+	status.Sink.URL = cdc.MaskURL(status.Sink.URL)
+	c.JSON(http.StatusOK, status)
+}
+
+// UpdateCDCSink configures the change data capture sink. Changing the kind,
+// URL or target mirrors all properties again from the start.
+func (h *Handler) UpdateCDCSink(c *gin.Context) {
+	var req models.CDCSinkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	req.Kind = strings.ToLower(strings.TrimSpace(req.Kind))
+	req.URL = strings.TrimSpace(req.URL)
+	req.Target = strings.TrimSpace(req.Target)
+
+	current, err := h.dbFor(c).GetCDCSink()
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get CDC sink")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update CDC sink"})
+		return
+	}
+	// The masked URL sent back unchanged keeps the stored one
+	if current != nil && req.URL == cdc.MaskURL(current.URL) {
+		req.URL = ""
+	}
+	if err := cdc.Validate(req, current != nil); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if _, err := h.cdc.Configure(req); err != nil {
+		h.logger.WithError(err).Error("Failed to update CDC sink")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update CDC sink"})
+		return
+	}
+	h.GetCDCSink(c)
+}
@@ -0,0 +1,88 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// etagEpoch is the start of the server process, part of every ETag so a new
+// release, which may answer differently from the same data, invalidates them
+var etagEpoch = time.Now().Unix()
+
+// notModified answers conditional GET requests of an endpoint reading the
+// given tables. The response gets a weak ETag made of the workspace, the API
+// version and the tables' change counter, and their last modification time; a request whose
+// If-None-Match matches the ETag, or without one whose If-Modified-Since is
+// not older than the modification time, is answered with 304 Not Modified.
+// The counter covers whole tables, so a change anywhere in them invalidates
+// the ETags of all queries on them.
+func (h *Handler) notModified(tables ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		modification, err := h.dbFor(c).LastModified(tables...)
+		if err != nil {
+			// Answer without validators rather than fail the request
+			h.logger.WithError(err).Warn("Failed to get last modification")
+			c.Next()
+			return
+		}
+		etag := workspaceETag(c, fmt.Sprintf("v%d-%d-%d", RequestAPIVersion(c), etagEpoch, modification.Version))
+		if conditionalGet(c, etag, modification.ModifiedAt) {
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// workspaceETag returns a weak ETag of value for the workspace serving the
+// request. Workspaces answer the same URLs from different databases whose
+// change counters may well be equal, so the slug keeps their ETags apart.
+func workspaceETag(c *gin.Context, value string) string {
+	if slug := RequestWorkspace(c); slug != "" {
+		value = slug + "-" + value
+	}
+	return `W/"` + value + `"`
+}
+
+// conditionalGet sets the validators of a response and answers 304 when the
+// request's conditions show the client holds the current representation. It
+// returns whether it answered. The token and the workspace header select the
+// workspace answering, so caches must keep a response per value of both.
+func conditionalGet(c *gin.Context, etag string, modifiedAt time.Time) bool {
+	c.Header("ETag", etag)
+	c.Writer.Header().Add("Vary", "Authorization, "+WorkspaceHeader)
+	// Clients may keep the response but revalidate it before each use
+	c.Header("Cache-Control", "no-cache")
+	if !modifiedAt.IsZero() {
+		c.Header("Last-Modified", modifiedAt.UTC().Format(http.TimeFormat))
+	}
+
+	if match := c.GetHeader("If-None-Match"); match != "" {
+		if !etagMatches(match, etag) {
+			return false
+		}
+	} else if since, err := http.ParseTime(c.GetHeader("If-Modified-Since")); err != nil ||
+		modifiedAt.IsZero() || modifiedAt.Truncate(time.Second).After(since) {
+		return false
+	}
+	c.Status(http.StatusNotModified)
+	c.Writer.WriteHeaderNow()
+	return true
+}
+
+// etagMatches compares an If-None-Match header with an ETag the weak way,
+// ignoring the W/ prefixes
+func etagMatches(header, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,146 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"fundamental/server/internal/geometry"
+	"fundamental/server/internal/models"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/paulmach/orb/geojson"
+)
+
+// maxBoundaryImportBytes bounds the size of an imported set of district
+// boundaries
+const maxBoundaryImportBytes = 64 << 20
+
+// GetDistrictHulls returns a version of the district boundaries as GeoJSON.
+// Without parameters it is the current version; asOf (YYYY-MM-DD) picks the
+// version in force at the end of that day, so historical maps show the
+// boundaries of their time, and version picks one by id. The metadata member
+// describes the version and its validity period. The ETag changes only when
+// a newer version ends the validity of the one served.
+func (h *Handler) GetDistrictHulls(c *gin.Context) {
+	var version *models.DistrictBoundaryVersion
+	var features []byte
+	var asOf string
+	var err error
+	if raw := c.Query("version"); raw != "" {
+		id, parseErr := strconv.ParseInt(raw, 10, 64)
+		if parseErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid boundary version"})
+			return
+		}
+		version, features, err = h.dbFor(c).GetDistrictBoundaries(id)
+		if err == nil && version == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "District boundary version not found"})
+			return
+		}
+	} else {
+		var at time.Time
+		if asOf = c.Query("asOf"); asOf != "" {
+			day, parseErr := time.Parse("2006-01-02", asOf)
+			if parseErr != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid asOf date, expected YYYY-MM-DD"})
+				return
+			}
+			at = day.AddDate(0, 0, 1).Add(-time.Nanosecond)
+		}
+		version, features, err = h.dbFor(c).DistrictBoundariesAt(at)
+	}
+	if err != nil {
+		abortWithError(c, err, "Failed to get district boundaries")
+		return
+	}
+	if version == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "District boundaries not found, run the district hulls update first"})
+		return
+	}
+
+	modifiedAt := version.ValidFrom
+	validTo := int64(0)
+	if version.ValidTo != nil {
+		modifiedAt, validTo = *version.ValidTo, version.ValidTo.Unix()
+	}
+	if conditionalGet(c, workspaceETag(c, fmt.Sprintf("b%d-%d", version.ID, validTo)), modifiedAt) {
+		return
+	}
+
+	metadata := gin.H{
+		"version":    version.ID,
+		"source":     version.Source,
+		"valid_from": version.ValidFrom,
+		"valid_to":   version.ValidTo,
+		"districts":  version.Districts,
+		"checksum":   version.Checksum,
+	}
+	if version.Parameters != nil {
+		metadata["parameters"] = version.Parameters
+	}
+	if asOf != "" {
+		metadata["as_of"] = asOf
+	}
+	body, err := json.Marshal(gin.H{
+		"type":     "FeatureCollection",
+		"features": json.RawMessage(features),
+		"metadata": metadata,
+	})
+	if err != nil {
+		abortWithError(c, err, "Failed to encode district boundaries")
+		return
+	}
+	c.Data(http.StatusOK, "application/geo+json", body)
+}
+
+// ListDistrictBoundaryVersions returns the versions of the district
+// boundaries with their validity periods, newest first
+func (h *Handler) ListDistrictBoundaryVersions(c *gin.Context) {
+	versions, err := h.dbFor(c).ListDistrictBoundaryVersions()
+	if err != nil {
+		abortWithError(c, err, "Failed to list district boundary versions")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"versions": versions})
+}
+
+// ImportDistrictBoundaries replaces the district boundaries by a GeoJSON
+// FeatureCollection, such as the official postal code areas, whose features
+// are polygons with a "district" property. The boundaries in force so far
+// are kept as an earlier version. A scheduled district hulls update replaces
+// imported boundaries by generated ones again.
+func (h *Handler) ImportDistrictBoundaries(c *gin.Context) {
+	if _, ok := h.requireDatabase(c); !ok {
+		return
+	}
+	var raw json.RawMessage
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBoundaryImportBytes)
+	if err := c.ShouldBindJSON(&raw); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid GeoJSON"})
+		return
+	}
+	fc, err := geojson.UnmarshalFeatureCollection(raw)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid GeoJSON: " + err.Error()})
+		return
+	}
+
+	version, created, err := h.districtManager.ImportBoundaries(fc)
+	if errors.Is(err, geometry.ErrInvalidBoundaries) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		abortWithError(c, err, "Failed to import district boundaries")
+		return
+	}
+	if !created {
+		// The same boundaries as the current version
+		c.JSON(http.StatusOK, version)
+		return
+	}
+	c.JSON(http.StatusCreated, version)
+}
@@ -0,0 +1,199 @@
+package api
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"fundamental/server/internal/geometry"
+	"fundamental/server/internal/tasks"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/paulmach/orb/geojson"
+)
+
+const (
+	// districtExportPrefix starts the names of the district export archives
+	districtExportPrefix = "fundamental-districts-"
+
+	// districtExportsKept bounds the district export archives kept in the
+	// export directory; older ones are deleted after each export
+	districtExportsKept = 10
+)
+
+// districtExportParams are the parameters of a district export task
+type districtExportParams struct {
+	City string `json:"city"`
+}
+
+// exportDistricts writes the district boundaries joined with the statistics
+// of v_district_stats to a zipped GeoJSON file in the export directory, for
+// GIS tools such as QGIS. Districts without listings or sales get empty
+// statistics. The result names the file and the link it is downloaded from.
+func (h *Handler) exportDistricts(ctx context.Context, task *tasks.Task, city string) (gin.H, error) {
+	hulls, err := os.ReadFile(filepath.Join(h.cfg.OutputDir, geometry.DistrictHullsFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, errors.New("the district boundaries have not been generated yet, run the district_hulls task first")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read district boundaries: %v", err)
+	}
+	boundaries, err := geojson.UnmarshalFeatureCollection(hulls)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse district boundaries: %v", err)
+	}
+
+	stats, err := h.districtStats(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	features := geojson.NewFeatureCollection()
+	for i, boundary := range boundaries.Features {
+		task.Progress(i+1, len(boundaries.Features), fmt.Sprintf("Joined %d of %d districts", i+1, len(boundaries.Features)))
+		if city != "" && !strings.EqualFold(boundary.Properties.MustString("city", ""), city) {
+			continue
+		}
+		district := boundary.Properties.MustString("district", "")
+		feature := geojson.NewFeature(boundary.Geometry)
+		feature.Properties = geojson.Properties{
+			"district":    district,
+			"city":        boundary.Properties.MustString("city", ""),
+			"point_count": boundary.Properties["point_count"],
+		}
+		for _, column := range districtStatsColumns {
+			feature.Properties[column] = stats[district][column]
+		}
+		features.Append(feature)
+	}
+	if len(features.Features) == 0 {
+		return nil, errors.New("no district boundaries to export")
+	}
+	generated := time.Now()
+	metadata := gin.H{
+		"generated":   generated.Format(time.RFC3339),
+		"description": "District boundaries with the listings and sales of the past twelve months",
+		"districts":   len(features.Features),
+	}
+	// The version of the boundaries the file holds, when it is recorded
+	if version, _, err := h.db.DistrictBoundariesAt(time.Time{}); err != nil {
+		return nil, err
+	} else if version != nil {
+		metadata["boundaries_version"] = version.ID
+		metadata["boundaries_valid_from"] = version.ValidFrom.Format(time.RFC3339)
+	}
+	features.ExtraMembers = geojson.Properties{"metadata": metadata}
+
+	name := districtExportPrefix + generated.Format("20060102-150405")
+	if city != "" {
+		name += "-" + strings.ToLower(strings.Join(strings.Fields(city), "-"))
+	}
+	name += ".zip"
+	if err := writeDistrictExport(filepath.Join(h.cfg.ExportDir, name), features); err != nil {
+		return nil, err
+	}
+	if err := pruneDistrictExports(h.cfg.ExportDir); err != nil {
+		h.logger.WithError(err).Warn("Failed to delete old district exports")
+	}
+
+	return gin.H{
+		"file":      name,
+		"districts": len(features.Features),
+		"download":  fmt.Sprintf("/api/v%d/tasks/%d/download", LatestAPIVersion, task.ID),
+	}, nil
+}
+
+// districtStatsColumns are the columns of v_district_stats added to the
+// exported districts
+var districtStatsColumns = []string{
+	"active_listings", "avg_asking_price", "avg_asking_price_per_sqm",
+	"sold_12m", "avg_sold_price", "avg_sold_price_per_sqm", "avg_days_to_sell",
+}
+
+// districtStats reads v_district_stats by district and column
+func (h *Handler) districtStats(ctx context.Context) (map[string]map[string]interface{}, error) {
+	it, err := h.db.IterateView(ctx, "v_district_stats")
+	if err != nil {
+		return nil, err
+	}
+	defer it.Close()
+
+	stats := make(map[string]map[string]interface{})
+	columns := it.Columns()
+	for it.Next() {
+		row := make(map[string]interface{}, len(columns))
+		for i, value := range it.Values() {
+			row[columns[i].Name] = value
+		}
+		if district, ok := row["district"].(*string); ok {
+			stats[*district] = row
+		}
+	}
+	if err := it.Err(); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// writeDistrictExport writes the districts as districts.geojson in a zip
+// archive at path. The archive is written under a temporary name first, so
+// a download never sees it half written.
+func writeDistrictExport(path string, features *geojson.FeatureCollection) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create export directory: %v", err)
+	}
+	file, err := os.CreateTemp(filepath.Dir(path), ".district-export-*")
+	if err != nil {
+		return fmt.Errorf("failed to create export file: %v", err)
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	archive := zip.NewWriter(file)
+	w, err := archive.CreateHeader(&zip.FileHeader{Name: "districts.geojson", Method: zip.Deflate, Modified: time.Now()})
+	if err != nil {
+		return fmt.Errorf("failed to write export archive: %v", err)
+	}
+	if err := json.NewEncoder(w).Encode(features); err != nil {
+		return fmt.Errorf("failed to encode GeoJSON: %v", err)
+	}
+	if err := archive.Close(); err != nil {
+		return fmt.Errorf("failed to write export archive: %v", err)
+	}
+	if err := file.Chmod(0644); err != nil {
+		return fmt.Errorf("failed to write export file: %v", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write export file: %v", err)
+	}
+	if err := os.Rename(file.Name(), path); err != nil {
+		return fmt.Errorf("failed to store export file: %v", err)
+	}
+	return nil
+}
+
+// pruneDistrictExports deletes the district export archives beyond the
+// newest districtExportsKept
+func pruneDistrictExports(dir string) error {
+	names, err := filepath.Glob(filepath.Join(dir, districtExportPrefix+"*.zip"))
+	if err != nil {
+		return err
+	}
+	// The timestamp in the names sorts them by age
+	sort.Sort(sort.Reverse(sort.StringSlice(names)))
+	for i, name := range names {
+		if i < districtExportsKept {
+			continue
+		}
+		if err := os.Remove(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
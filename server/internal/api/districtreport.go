@@ -0,0 +1,44 @@
+package api
+
+import (
+	"fundamental/server/internal/analytics"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// districtReportMaxAge is how long clients may cache a district report, in
+// seconds; its inputs change with the daily scrapes
+const districtReportMaxAge = "3600"
+
+// GetDistrictReport returns the scorecard of a 4-digit postal district: price
+// level and trend, inventory, sales velocity, energy label mix, republish and
+// withdrawal rates and market phase
+func (h *Handler) GetDistrictReport(c *gin.Context) {
+	district, ok := districtParam(c)
+	if !ok {
+		return
+	}
+
+	report, err := h.dbFor(c).GetDistrictReport(district)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get district report")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get district report"})
+		return
+	}
+	if report == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "District not found"})
+		return
+	}
+
+	indicators, err := h.dbFor(c).GetDistrictMarketIndicators(district, analytics.DefaultWindowDays)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get district market indicators")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get district report"})
+		return
+	}
+	report.MarketPhase = analytics.MarketPhase(*indicators)
+
+	c.Header("Cache-Control", "private, max-age="+districtReportMaxAge)
+	c.JSON(http.StatusOK, report)
+}
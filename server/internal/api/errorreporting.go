@@ -0,0 +1,41 @@
+package api
+
+import (
+	"fundamental/server/internal/errorsink"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetErrorReporting returns whether errors are being reported to the error sink
+func (h *Handler) GetErrorReporting(c *gin.Context) {
+	reporter := errorsink.Default()
+	c.JSON(http.StatusOK, gin.H{
+		"configured": reporter.Configured(),
+		"enabled":    reporter.Enabled(),
+	})
+}
+
+// SetErrorReporting switches error reporting on or off until the next restart
+func (h *Handler) SetErrorReporting(c *gin.Context) {
+	var req struct {
+		Enabled *bool `json:"enabled" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Request body must contain enabled"})
+		return
+	}
+
+	reporter := errorsink.Default()
+	if *req.Enabled && !reporter.Configured() {
+		c.JSON(http.StatusConflict, gin.H{"error": "No error sink configured; set SENTRY_DSN or ERROR_WEBHOOK_URL"})
+		return
+	}
+
+	reporter.SetEnabled(*req.Enabled)
+	h.logger.WithField("enabled", *req.Enabled).Info("Changed error reporting")
+	c.JSON(http.StatusOK, gin.H{
+		"configured": reporter.Configured(),
+		"enabled":    reporter.Enabled(),
+	})
+}
@@ -0,0 +1,61 @@
+package api
+
+import (
+	"errors"
+	"fundamental/server/internal/database"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// errorStatus returns the HTTP status of an error of the database layer
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, database.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, database.ErrConflict):
+		return http.StatusConflict
+	case errors.Is(err, database.ErrValidation):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// abortWithError hands the error of a failed request to HandleErrors. message
+// is the answer when the error is a failure of the server rather than of the
+// request, such as "Failed to get properties".
+func abortWithError(c *gin.Context, err error, message string) {
+	c.Error(err).SetMeta(message)
+	c.Abort()
+}
+
+// HandleErrors answers the requests whose handler failed with abortWithError.
+// Errors of a kind the database layer defines get its status, 404, 409 or
+// 400, and their own message; other errors are logged and answered with 500
+// and the handler's message.
+func HandleErrors(logger *logrus.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if c.Writer.Written() {
+			return
+		}
+		last := c.Errors.Last()
+		if last == nil {
+			return
+		}
+
+		status := errorStatus(last.Err)
+		if status != http.StatusInternalServerError {
+			c.JSON(status, gin.H{"error": last.Err.Error()})
+			return
+		}
+		message, _ := last.Meta.(string)
+		if message == "" {
+			message = http.StatusText(status)
+		}
+		logger.WithError(last.Err).WithField("path", c.FullPath()).Error(message)
+		c.JSON(status, gin.H{"error": message})
+	}
+}
@@ -0,0 +1,490 @@
+package api
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"fundamental/server/internal/database"
+	"fundamental/server/internal/format"
+	"fundamental/server/internal/i18n"
+	"fundamental/server/internal/models"
+	"fundamental/server/internal/parquet"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// csvFlushEvery is the number of CSV rows written between flushes to the client
+const csvFlushEvery = 1000
+
+// exportColumn is a column of the property export
+type exportColumn struct {
+	name  string
+	typ   parquet.Type
+	value func(p *models.Property) interface{}
+}
+
+// exportColumns are the columns of the property export, in order
+var exportColumns = []exportColumn{
+	{"id", parquet.Int64, func(p *models.Property) interface{} { return p.ID }},
+	{"url", parquet.String, func(p *models.Property) interface{} { return p.URL }},
+	{"street", parquet.String, func(p *models.Property) interface{} { return p.Street }},
+	{"neighborhood", parquet.String, func(p *models.Property) interface{} { return p.Neighborhood }},
+	{"property_type", parquet.String, func(p *models.Property) interface{} { return p.PropertyType }},
+	{"city", parquet.String, func(p *models.Property) interface{} { return p.City }},
+	{"postal_code", parquet.String, func(p *models.Property) interface{} { return p.PostalCode }},
+	{"district", parquet.String, func(p *models.Property) interface{} { return p.District }},
+	{"country", parquet.String, func(p *models.Property) interface{} { return p.Country }},
+	{"currency", parquet.String, func(p *models.Property) interface{} { return p.Currency }},
+	{"price", parquet.Int64, func(p *models.Property) interface{} { return p.Price }},
+	{"year_built", parquet.Int64, func(p *models.Property) interface{} { return p.YearBuilt }},
+	{"living_area", parquet.Int64, func(p *models.Property) interface{} { return p.LivingArea }},
+	{"num_rooms", parquet.Int64, func(p *models.Property) interface{} { return p.NumRooms }},
+	{"status", parquet.String, func(p *models.Property) interface{} { return p.Status }},
+	{"listing_date", parquet.Date, func(p *models.Property) interface{} { return p.ListingDate }},
+	{"selling_date", parquet.Date, func(p *models.Property) interface{} { return p.SellingDate }},
+	{"selling_date_precision", parquet.String, func(p *models.Property) interface{} { return p.SellingDatePrecision }},
+	{"scraped_at", parquet.Timestamp, func(p *models.Property) interface{} { return p.ScrapedAt }},
+	{"created_at", parquet.Timestamp, func(p *models.Property) interface{} { return p.CreatedAt }},
+	{"latitude", parquet.Double, func(p *models.Property) interface{} { return p.Latitude }},
+	{"longitude", parquet.Double, func(p *models.Property) interface{} { return p.Longitude }},
+	{"energy_label", parquet.String, func(p *models.Property) interface{} { return p.EnergyLabel }},
+	{"canonical_id", parquet.Int64, func(p *models.Property) interface{} { return p.CanonicalID }},
+	{"agent_id", parquet.Int64, func(p *models.Property) interface{} { return p.AgentID }},
+	{"delisting_reason", parquet.String, func(p *models.Property) interface{} { return p.DelistingReason }},
+	{"delisted_at", parquet.Timestamp, func(p *models.Property) interface{} { return p.DelistedAt }},
+	{"tags", parquet.String, func(p *models.Property) interface{} {
+		if len(p.Tags) == 0 {
+			return nil
+		}
+		return strings.Join(p.Tags, ", ")
+	}},
+}
+
+// csvValue formats an export value as a CSV field; nulls are empty
+func csvValue(typ parquet.Type, value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case *int:
+		if v == nil {
+			return ""
+		}
+		return strconv.Itoa(*v)
+	case *int64:
+		if v == nil {
+			return ""
+		}
+		return strconv.FormatInt(*v, 10)
+	case *float64:
+		if v == nil {
+			return ""
+		}
+		return strconv.FormatFloat(*v, 'f', -1, 64)
+	case *string:
+		if v == nil {
+			return ""
+		}
+		return *v
+	case *time.Time:
+		if v == nil {
+			return ""
+		}
+		return csvValue(typ, *v)
+	case time.Time:
+		if v.IsZero() {
+			return ""
+		}
+		if typ == parquet.Date {
+			return v.Format("2006-01-02")
+		}
+		return v.UTC().Format(time.RFC3339)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// csvLocale writes a localized CSV file: column headers in the language of a
+// locale, decimals with its decimal separator and fields separated by its list
+// separator, as spreadsheets of the locale expect. Amounts stay plain numbers
+// so spreadsheets can compute with them. The nil csvLocale writes the plain
+// CSV read by scripts and BI tools.
+type csvLocale struct {
+	format *format.Formatter
+	text   *i18n.Translator
+}
+
+// exportLocale returns the CSV locale a request asks for with the locale
+// parameter or the Accept-Language header, or nil when it asks for none
+func exportLocale(c *gin.Context) (*csvLocale, bool) {
+	locale, ok := requestLocale(c)
+	if !ok || locale == "" {
+		return nil, ok
+	}
+	return &csvLocale{format: format.New(format.Settings{Locale: locale}), text: i18n.New(locale)}, true
+}
+
+// writer returns a CSV writer using the list separator of the locale
+func (l *csvLocale) writer(out io.Writer) *csv.Writer {
+	w := csv.NewWriter(out)
+	if l != nil {
+		w.Comma = l.format.ListSeparator()
+	}
+	return w
+}
+
+// header returns the header of a column
+func (l *csvLocale) header(name string) string {
+	if l == nil {
+		return name
+	}
+	return l.text.T(name)
+}
+
+// value formats an export value as a CSV field
+func (l *csvLocale) value(typ parquet.Type, value interface{}) string {
+	if v, ok := value.(*float64); ok && v != nil && l != nil {
+		return l.format.Plain(*v)
+	}
+	return csvValue(typ, value)
+}
+
+// ExportProperties streams the properties matching the date range, city,
+// status and tag filters as CSV or, with format=parquet, as a Parquet file. Rows are
+// written as they are read from the database, so the export is not limited by
+// memory. An error after the first row leaves the file truncated. An export
+// larger than the export quota is refused with 403.
+func (h *Handler) ExportProperties(c *gin.Context) {
+	format := c.DefaultQuery("format", "csv")
+	if format != "csv" && format != "parquet" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Format must be csv or parquet"})
+		return
+	}
+	filter, ok := h.propertyFilter(c)
+	if !ok {
+		return
+	}
+	locale, ok := exportLocale(c)
+	if !ok {
+		return
+	}
+
+	db := h.dbFor(c)
+	if !h.checkExportQuota(c, func() (int, error) { return db.CountExportProperties(filter) }) {
+		return
+	}
+
+	properties, err := db.IterateProperties(c.Request.Context(), filter)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to export properties")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export properties"})
+		return
+	}
+	defer properties.Close()
+
+	filename := fmt.Sprintf("properties-%s.%s", time.Now().Format("20060102"), format)
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	if format == "parquet" {
+		c.Header("Content-Type", "application/vnd.apache.parquet")
+	} else {
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+	}
+	c.Status(http.StatusOK)
+
+	var count int
+	if format == "parquet" {
+		count, err = writeParquetExport(c, properties)
+	} else {
+		count, err = writeCSVExport(c, properties, locale)
+	}
+	if err != nil {
+		h.logger.WithError(err).WithField("rows", count).Error("Failed to stream property export")
+	}
+	h.recordExport(count)
+}
+
+// writeCSVExport writes the properties as CSV with a header row
+func writeCSVExport(c *gin.Context, properties *database.PropertyIterator, locale *csvLocale) (int, error) {
+	w := locale.writer(c.Writer)
+	record := make([]string, len(exportColumns))
+	for i, column := range exportColumns {
+		record[i] = locale.header(column.name)
+	}
+	if err := w.Write(record); err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for properties.Next() {
+		p := properties.Property()
+		for i, column := range exportColumns {
+			record[i] = locale.value(column.typ, column.value(&p))
+		}
+		if err := w.Write(record); err != nil {
+			return count, err
+		}
+		count++
+		if count%csvFlushEvery == 0 {
+			w.Flush()
+			if err := w.Error(); err != nil {
+				return count, err
+			}
+			c.Writer.Flush()
+		}
+	}
+	if err := properties.Err(); err != nil {
+		w.Flush()
+		return count, err
+	}
+	w.Flush()
+	return count, w.Error()
+}
+
+// writeParquetExport writes the properties as a Parquet file, one row group
+// at a time
+func writeParquetExport(c *gin.Context, properties *database.PropertyIterator) (int, error) {
+	columns := make([]parquet.Column, len(exportColumns))
+	for i, column := range exportColumns {
+		columns[i] = parquet.Column{Name: column.name, Type: column.typ}
+	}
+	out := bufio.NewWriter(c.Writer)
+	w := parquet.NewWriter(out, columns, parquet.DefaultRowGroupSize)
+
+	count := 0
+	row := make([]interface{}, len(exportColumns))
+	for properties.Next() {
+		p := properties.Property()
+		for i, column := range exportColumns {
+			row[i] = column.value(&p)
+		}
+		if err := w.Write(row); err != nil {
+			out.Flush()
+			return count, err
+		}
+		count++
+	}
+	if err := properties.Err(); err != nil {
+		out.Flush()
+		return count, err
+	}
+	if err := w.Close(); err != nil {
+		return count, err
+	}
+	return count, out.Flush()
+}
+
+// hiveDefaultPartition names the partition of rows without a city or year,
+// as Hive and the readers following its layout do
+const hiveDefaultPartition = "__HIVE_DEFAULT_PARTITION__"
+
+// historyExportColumns are the columns of the history dataset of the
+// partitioned export, in order
+var historyExportColumns = []struct {
+	name  string
+	typ   parquet.Type
+	value func(e *models.PropertyChangeEvent) interface{}
+}{
+	{"property_id", parquet.Int64, func(e *models.PropertyChangeEvent) interface{} { return e.PropertyID }},
+	{"url", parquet.String, func(e *models.PropertyChangeEvent) interface{} { return e.URL }},
+	{"street", parquet.String, func(e *models.PropertyChangeEvent) interface{} { return e.Street }},
+	{"change_type", parquet.String, func(e *models.PropertyChangeEvent) interface{} { return e.ChangeType }},
+	{"status", parquet.String, func(e *models.PropertyChangeEvent) interface{} { return e.Status }},
+	{"price", parquet.Int64, func(e *models.PropertyChangeEvent) interface{} { return e.Price }},
+	{"previous_status", parquet.String, func(e *models.PropertyChangeEvent) interface{} { return e.PreviousStatus }},
+	{"previous_price", parquet.Int64, func(e *models.PropertyChangeEvent) interface{} { return e.PreviousPrice }},
+	{"changed_at", parquet.Timestamp, func(e *models.PropertyChangeEvent) interface{} { return e.ChangedAt }},
+}
+
+// partitionWriter writes the rows of a dataset into a zip archive as one
+// Parquet file per city and year, in the directory layout of Hive
+// partitioning: <dataset>/city=<city>/year=<year>/part-0.parquet. The city is
+// not a column of the files, readers add it from the path. Rows must arrive
+// grouped by partition; a partition seen again gets another part file.
+type partitionWriter struct {
+	zip     *zip.Writer
+	dataset string
+	columns []parquet.Column
+
+	partition string
+	writer    *parquet.Writer
+	parts     map[string]int
+}
+
+func newPartitionWriter(archive *zip.Writer, dataset string, columns []parquet.Column) *partitionWriter {
+	return &partitionWriter{zip: archive, dataset: dataset, columns: columns, parts: make(map[string]int)}
+}
+
+// hivePartition returns the directory of a city and year
+func hivePartition(city string, year int) string {
+	cityValue, yearValue := hiveDefaultPartition, hiveDefaultPartition
+	if city != "" {
+		cityValue = url.PathEscape(city)
+	}
+	if year > 0 {
+		yearValue = strconv.Itoa(year)
+	}
+	return "city=" + cityValue + "/year=" + yearValue
+}
+
+// write adds a row to the file of its partition
+func (w *partitionWriter) write(city string, year int, row []interface{}) error {
+	partition := hivePartition(city, year)
+	if w.writer == nil || partition != w.partition {
+		if err := w.close(); err != nil {
+			return err
+		}
+		part := w.parts[partition]
+		w.parts[partition] = part + 1
+		file, err := w.zip.CreateHeader(&zip.FileHeader{
+			Name:     fmt.Sprintf("%s/%s/part-%d.parquet", w.dataset, partition, part),
+			Method:   zip.Deflate,
+			Modified: time.Now(),
+		})
+		if err != nil {
+			return err
+		}
+		w.partition = partition
+		w.writer = parquet.NewWriter(file, w.columns, parquet.DefaultRowGroupSize)
+	}
+	return w.writer.Write(row)
+}
+
+// close finishes the file of the current partition
+func (w *partitionWriter) close() error {
+	if w.writer == nil {
+		return nil
+	}
+	err := w.writer.Close()
+	w.writer = nil
+	return err
+}
+
+// ExportParquetDataset streams the properties and their price and status
+// history as a zip archive of Parquet files partitioned by city and year, which
+// pandas, Polars and DuckDB read as a dataset once unpacked. Properties are
+// partitioned by the year of their listing date, or of the scrape without one,
+// and history entries by the year of the change. The city parameter limits the
+// export to one city. An error after the first row leaves the archive truncated.
+// More properties than the export quota are refused with 403.
+func (h *Handler) ExportParquetDataset(c *gin.Context) {
+	city := c.Query("city")
+	ctx := c.Request.Context()
+
+	db := h.dbFor(c)
+	if !h.checkExportQuota(c, func() (int, error) { return db.CountPropertiesByCity(city) }) {
+		return
+	}
+
+	properties, err := db.IteratePropertiesByCity(ctx, city)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to export properties")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export properties"})
+		return
+	}
+	defer properties.Close()
+
+	filename := fmt.Sprintf("fundamental-parquet-%s.zip", time.Now().Format("20060102"))
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	c.Header("Content-Type", "application/zip")
+	c.Status(http.StatusOK)
+
+	archive := zip.NewWriter(c.Writer)
+	count, err := writePartitionedProperties(archive, properties)
+	properties.Close()
+	h.recordExport(count)
+	if err != nil {
+		h.logger.WithError(err).WithField("rows", count).Error("Failed to stream partitioned property export")
+		archive.Close()
+		return
+	}
+
+	history, err := db.IterateHistoryByCity(ctx, city)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to export property history")
+		archive.Close()
+		return
+	}
+	defer history.Close()
+	if count, err = writePartitionedHistory(archive, history); err != nil {
+		h.logger.WithError(err).WithField("rows", count).Error("Failed to stream partitioned history export")
+		archive.Close()
+		return
+	}
+	if err := archive.Close(); err != nil {
+		h.logger.WithError(err).Error("Failed to finish partitioned export")
+	}
+}
+
+// writePartitionedProperties writes the properties dataset of the partitioned
+// export: the columns of the property export without the city
+func writePartitionedProperties(archive *zip.Writer, properties *database.PropertyIterator) (int, error) {
+	var columns []parquet.Column
+	var values []func(p *models.Property) interface{}
+	for _, column := range exportColumns {
+		if column.name == "city" {
+			continue
+		}
+		columns = append(columns, parquet.Column{Name: column.name, Type: column.typ})
+		values = append(values, column.value)
+	}
+	w := newPartitionWriter(archive, "properties", columns)
+
+	count := 0
+	row := make([]interface{}, len(columns))
+	for properties.Next() {
+		p := properties.Property()
+		for i, value := range values {
+			row[i] = value(&p)
+		}
+		year := p.ScrapedAt.Year()
+		if !p.ListingDate.IsZero() {
+			year = p.ListingDate.Year()
+		} else if p.ScrapedAt.IsZero() {
+			year = 0
+		}
+		if err := w.write(p.City, year, row); err != nil {
+			return count, err
+		}
+		count++
+	}
+	if err := properties.Err(); err != nil {
+		return count, err
+	}
+	return count, w.close()
+}
+
+// writePartitionedHistory writes the history dataset of the partitioned export
+func writePartitionedHistory(archive *zip.Writer, history *database.HistoryIterator) (int, error) {
+	columns := make([]parquet.Column, len(historyExportColumns))
+	for i, column := range historyExportColumns {
+		columns[i] = parquet.Column{Name: column.name, Type: column.typ}
+	}
+	w := newPartitionWriter(archive, "history", columns)
+
+	count := 0
+	row := make([]interface{}, len(columns))
+	for history.Next() {
+		e := history.Event()
+		for i, column := range historyExportColumns {
+			row[i] = column.value(&e)
+		}
+		year := 0
+		if !e.ChangedAt.IsZero() {
+			year = e.ChangedAt.Year()
+		}
+		if err := w.write(e.City, year, row); err != nil {
+			return count, err
+		}
+		count++
+	}
+	if err := history.Err(); err != nil {
+		return count, err
+	}
+	return count, w.close()
+}
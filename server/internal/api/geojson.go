@@ -0,0 +1,79 @@
+package api
+
+import (
+	"bufio"
+	"encoding/json"
+	"fundamental/server/internal/models"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/geojson"
+)
+
+// geoJSONFlushEvery is the number of features written between flushes to the client
+const geoJSONFlushEvery = 500
+
+// GetPropertiesGeoJSON streams geocoded properties as a GeoJSON FeatureCollection.
+// Features are written as they are read from the database, so the response
+// size is not limited by memory. An error after the first feature leaves the
+// response truncated, which clients see as invalid JSON.
+func (h *Handler) GetPropertiesGeoJSON(c *gin.Context) {
+	var dateRange DateRange
+	if err := c.ShouldBindQuery(&dateRange); err != nil {
+		h.logger.WithError(err).Error("Failed to parse date range")
+	}
+	city := c.Query("city")
+
+	c.Header("Content-Type", "application/geo+json")
+	c.Status(http.StatusOK)
+	w := bufio.NewWriter(c.Writer)
+	w.WriteString(`{"type":"FeatureCollection","features":[`)
+
+	count := 0
+	err := h.dbFor(c).ForEachProperty(dateRange.StartDate, dateRange.EndDate, city, func(p models.Property) error {
+		if p.Latitude == nil || p.Longitude == nil {
+			return nil
+		}
+
+		feature := geojson.NewFeature(orb.Point{*p.Longitude, *p.Latitude})
+		feature.ID = p.ID
+		feature.Properties = geojson.Properties{
+			"url":          p.URL,
+			"street":       p.Street,
+			"city":         p.City,
+			"postal_code":  p.PostalCode,
+			"price":        p.Price,
+			"living_area":  p.LivingArea,
+			"status":       p.Status,
+			"energy_label": p.EnergyLabel,
+		}
+		data, err := json.Marshal(feature)
+		if err != nil {
+			return err
+		}
+
+		if count > 0 {
+			w.WriteByte(',')
+		}
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+		count++
+		if count%geoJSONFlushEvery == 0 {
+			if err := w.Flush(); err != nil {
+				return err
+			}
+			c.Writer.Flush()
+		}
+		return nil
+	})
+	if err != nil {
+		h.logger.WithError(err).WithField("features", count).Error("Failed to stream properties as GeoJSON")
+		w.Flush()
+		return
+	}
+
+	w.WriteString("]}")
+	w.Flush()
+}
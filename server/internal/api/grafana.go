@@ -0,0 +1,237 @@
+package api
+
+import (
+	"fmt"
+	"fundamental/server/internal/database"
+	"fundamental/server/internal/models"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Handlers implementing the Grafana JSON (SimpleJSON) datasource contract. In
+// Grafana, point a JSON datasource at <server>/api/grafana and authenticate
+// with a read-only API token scoped to "grafana".
+
+const maxGrafanaAnnotations = 1000
+
+// grafanaAnnotationKinds are the supported annotation queries
+var grafanaAnnotationKinds = map[string]struct {
+	changeTypes []string
+	matches     func(e models.PropertyChangeEvent) bool
+}{
+	"price_drops": {
+		changeTypes: []string{models.HistoryPriceChange, models.HistoryPriceAndStatusChange},
+		matches: func(e models.PropertyChangeEvent) bool {
+			return e.Price != nil && e.PreviousPrice != nil && *e.Price < *e.PreviousPrice
+		},
+	},
+	"price_increases": {
+		changeTypes: []string{models.HistoryPriceChange, models.HistoryPriceAndStatusChange},
+		matches: func(e models.PropertyChangeEvent) bool {
+			return e.Price != nil && e.PreviousPrice != nil && *e.Price > *e.PreviousPrice
+		},
+	},
+	"sold": {
+		changeTypes: []string{models.HistoryStatusChange, models.HistoryPriceAndStatusChange},
+		matches:     func(e models.PropertyChangeEvent) bool { return e.Status == "sold" },
+	},
+	"republished": {
+		changeTypes: []string{models.HistoryStatusChange, models.HistoryPriceAndStatusChange},
+		matches:     func(e models.PropertyChangeEvent) bool { return e.Status == "republished" },
+	},
+}
+
+// parseGrafanaTarget splits a target such as "avg_sold_price:Amsterdam" into
+// the metric and an optional city
+func parseGrafanaTarget(target string) (name, city string) {
+	name, city, _ = strings.Cut(strings.TrimSpace(target), ":")
+	return strings.TrimSpace(name), strings.TrimSpace(city)
+}
+
+// grafanaInterval buckets per month when daily points would exceed what the panel can show
+func grafanaInterval(req models.GrafanaQueryRequest) string {
+	days := int(req.Range.To.Sub(req.Range.From).Hours() / 24)
+	if req.IntervalMs >= int64(7*24*time.Hour/time.Millisecond) || (req.MaxDataPoints > 0 && days > req.MaxDataPoints) {
+		return database.IntervalMonth
+	}
+	return database.IntervalDay
+}
+
+// GrafanaTestConnection answers the datasource health check
+func (h *Handler) GrafanaTestConnection(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// GrafanaSearch lists the available metrics for the query editor
+func (h *Handler) GrafanaSearch(c *gin.Context) {
+	var req models.GrafanaSearchRequest
+	// Older Grafana versions send an empty body
+	_ = c.ShouldBindJSON(&req)
+
+	metrics := []string{}
+	for _, metric := range database.MarketMetrics() {
+		if strings.Contains(metric, strings.ToLower(req.Target)) {
+			metrics = append(metrics, metric)
+		}
+	}
+
+	c.JSON(http.StatusOK, metrics)
+}
+
+// GrafanaQuery returns market metrics as time series or tables
+func (h *Handler) GrafanaQuery(c *gin.Context) {
+	var req models.GrafanaQueryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid query request"})
+		return
+	}
+	if req.Range.From.IsZero() || req.Range.To.IsZero() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Query range is required"})
+		return
+	}
+
+	// An ad hoc city filter applies to targets without their own city
+	adhocCity := ""
+	for _, filter := range req.AdhocFilters {
+		if filter.Key == "city" && filter.Operator == "=" {
+			adhocCity = filter.Value
+		}
+	}
+
+	interval := grafanaInterval(req)
+	results := []interface{}{}
+	for _, target := range req.Targets {
+		metric, city := parseGrafanaTarget(target.Target)
+		if metric == "" {
+			continue
+		}
+		if !database.IsMarketMetric(metric) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Unknown metric %q", metric)})
+			return
+		}
+		if city == "" {
+			city = adhocCity
+		}
+
+		points, ok := h.warehouse.MarketTimeSeries(c.Request.Context(), metric, interval, req.Range.From, req.Range.To, city)
+		if !ok {
+			var err error
+			points, err = h.dbFor(c).GetMarketTimeSeries(metric, interval, req.Range.From, req.Range.To, city)
+			if err != nil {
+				h.logger.WithError(err).WithField("target", target.Target).Error("Failed to query Grafana target")
+				c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to query target %q", target.Target)})
+				return
+			}
+		}
+
+		if target.Type == "table" {
+			table := models.GrafanaTable{
+				Type: "table",
+				Columns: []models.GrafanaColumn{
+					{Text: "Time", Type: "time"},
+					{Text: target.Target, Type: "number"},
+				},
+				Rows: [][]interface{}{},
+			}
+			for _, point := range points {
+				table.Rows = append(table.Rows, []interface{}{point.Time.UnixMilli(), point.Value})
+			}
+			results = append(results, table)
+			continue
+		}
+
+		series := models.GrafanaTimeSeries{Target: target.Target, Datapoints: [][2]float64{}}
+		for _, point := range points {
+			series.Datapoints = append(series.Datapoints, [2]float64{point.Value, float64(point.Time.UnixMilli())})
+		}
+		results = append(results, series)
+	}
+
+	c.JSON(http.StatusOK, results)
+}
+
+// GrafanaAnnotations returns price changes and sales as graph annotations. The
+// annotation query is one of the kinds above, optionally followed by ":<city>".
+func (h *Handler) GrafanaAnnotations(c *gin.Context) {
+	var req models.GrafanaAnnotationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid annotation request"})
+		return
+	}
+
+	name, city := parseGrafanaTarget(req.Annotation.Query)
+	kind, ok := grafanaAnnotationKinds[name]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Annotation query must be price_drops, price_increases, sold or republished"})
+		return
+	}
+	f, _, ok := h.localization(c)
+	if !ok {
+		return
+	}
+
+	events, err := h.dbFor(c).GetPropertyChangeEvents(kind.changeTypes, req.Range.From, req.Range.To, city, maxGrafanaAnnotations)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get Grafana annotations")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get annotations"})
+		return
+	}
+
+	annotations := []models.GrafanaAnnotationEvent{}
+	for _, event := range events {
+		if !kind.matches(event) {
+			continue
+		}
+		text := event.URL
+		if event.Price != nil && event.PreviousPrice != nil && *event.PreviousPrice != 0 {
+			change := float64(*event.Price-*event.PreviousPrice) / float64(*event.PreviousPrice) * 100
+			text = fmt.Sprintf("%s → %s (%s)<br>%s",
+				f.Money(float64(*event.PreviousPrice)), f.Money(float64(*event.Price)), f.SignedPercent(change, 1), event.URL)
+		}
+		tags := []string{name}
+		if event.City != "" {
+			tags = append(tags, event.City)
+		}
+		annotations = append(annotations, models.GrafanaAnnotationEvent{
+			Annotation: req.Annotation,
+			Time:       event.ChangedAt.UnixMilli(),
+			Title:      strings.Trim(event.Street+", "+event.City, ", "),
+			Text:       text,
+			Tags:       tags,
+		})
+	}
+
+	c.JSON(http.StatusOK, annotations)
+}
+
+// GrafanaTagKeys lists the keys available for ad hoc filters
+func (h *Handler) GrafanaTagKeys(c *gin.Context) {
+	c.JSON(http.StatusOK, []models.GrafanaTagKey{{Type: "string", Text: "city"}})
+}
+
+// GrafanaTagValues lists the values of an ad hoc filter key
+func (h *Handler) GrafanaTagValues(c *gin.Context) {
+	var req struct {
+		Key string `json:"key"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || req.Key != "city" {
+		c.JSON(http.StatusOK, []models.GrafanaTagValue{})
+		return
+	}
+
+	cities, err := h.dbFor(c).GetCities()
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get cities")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get cities"})
+		return
+	}
+
+	values := make([]models.GrafanaTagValue, 0, len(cities))
+	for _, city := range cities {
+		values = append(values, models.GrafanaTagValue{Text: city})
+	}
+	c.JSON(http.StatusOK, values)
+}
@@ -3,16 +3,25 @@ package api
 import (
 	"fmt"
 	"fundamental/server/config"
+	"fundamental/server/internal/backup"
+	"fundamental/server/internal/cdc"
 	"fundamental/server/internal/database"
+	"fundamental/server/internal/errorsink"
+	"fundamental/server/internal/format"
 	"fundamental/server/internal/geocoding"
 	"fundamental/server/internal/geometry"
+	"fundamental/server/internal/live"
 	"fundamental/server/internal/models"
 	"fundamental/server/internal/scraping"
+	"fundamental/server/internal/tasks"
 	"fundamental/server/internal/telegram"
+	"fundamental/server/internal/thumbnail"
+	"fundamental/server/internal/warehouse"
+	"math"
 	"net/http"
 	"os"
-	"path/filepath"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 
@@ -20,15 +29,32 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
+// fullPostalCodeRegex matches a full Dutch (PC6) postal code, with or without
+// the space
+var fullPostalCodeRegex = regexp.MustCompile(`^\d{4}\s?[A-Za-z]{2}$`)
+
 type Handler struct {
-	db              *database.Database
+	db              database.APIStore
+	primary         database.APIStore // db, or the primary of the read replica db
+	cfg             *config.Config
 	logger          *logrus.Logger
 	geocoder        *geocoding.Geocoder
-	districtManager *geometry.DistrictManager
+	districtManager *geometry.DistrictManager // nil without a Database
 	spiderManager   *scraping.SpiderManager
 	telegramService *telegram.Service
+	backups         *backup.Manager  // nil without a Database
+	thumbnails      *thumbnail.Cache // nil when thumbnails are not cached
+	tasks           *tasks.Manager
+	cdc             *cdc.Streamer
+	warehouse       *warehouse.Warehouse // nil without an analytics backend
 }
 
+const (
+	minSearchQueryLength = 2
+	defaultSearchLimit   = 20
+	maxSearchLimit       = 100
+)
+
 type DateRange struct {
 	StartDate string `form:"startDate"`
 	EndDate   string `form:"endDate"`
@@ -41,20 +67,30 @@ type SpiderRequest struct {
 	Type      string `json:"type"` // 'active' or 'sold'
 }
 
-func NewHandler(db *database.Database, logger *logrus.Logger) *Handler {
+// NewHandler creates the handler of the API routes on db. The district hulls,
+// backups and imports work on the database file, so they are only available
+// when db is a Database; the handlers can be built on a fake store otherwise.
+func NewHandler(db database.APIStore, cfg *config.Config, taskManager *tasks.Manager, streamer *cdc.Streamer, analytics *warehouse.Warehouse, logger *logrus.Logger) *Handler {
 	if logger == nil {
 		logger = logrus.New()
 		logger.SetFormatter(&logrus.JSONFormatter{})
 		logger.SetOutput(os.Stdout)
 	}
 
-	cacheDir := filepath.Join(os.TempDir(), "fundamental", "geocode_cache")
-
-	// Initialize the district manager
-	districtManager := geometry.NewDistrictManager(db.GetDB(), logger)
+	// Initialize the district manager and the backups
+	var districtManager *geometry.DistrictManager
+	var backups *backup.Manager
+	if concrete, ok := db.(*database.Database); ok {
+		districtManager = geometry.NewDistrictManager(concrete, cfg.OutputDir, logger)
+		districtManager.EnablePDOKCache(cfg.PDOKCacheTTL(), cfg.PDOKOffline)
+		if err := districtManager.AdoptBoundaries(); err != nil {
+			logger.WithError(err).Warn("Failed to record the district boundaries")
+		}
+		backups = backup.NewManager(concrete, cfg, logger)
+	}
 
 	// Initialize the spider manager
-	spiderManager := scraping.NewSpiderManager(db, logger)
+	spiderManager := scraping.NewSpiderManager(db, cfg, logger)
 
 	// Initialize the telegram service
 	telegramService := telegram.NewService(logger)
@@ -65,14 +101,71 @@ func NewHandler(db *database.Database, logger *logrus.Logger) *Handler {
 		telegramService.UpdateConfig(config)
 	}
 
-	return &Handler{
+	var thumbnails *thumbnail.Cache
+	if cfg.ThumbnailCacheDir != "" {
+		thumbnails = thumbnail.NewCache(cfg.ThumbnailCacheDir)
+	}
+
+	handler := &Handler{
 		db:              db,
+		primary:         db,
+		cfg:             cfg,
 		logger:          logger,
-		geocoder:        geocoding.NewGeocoder(logger, cacheDir),
+		geocoder:        geocoding.NewGeocoder(logger, cfg.GeocodeCacheDir()),
 		districtManager: districtManager,
 		spiderManager:   spiderManager,
 		telegramService: telegramService,
+		backups:         backups,
+		thumbnails:      thumbnails,
+		tasks:           taskManager,
+		cdc:             streamer,
+		warehouse:       analytics,
+	}
+	handler.registerTaskKinds()
+	return handler
+}
+
+// requireDatabase returns the handler's database for the features that work
+// on the database file, answering 501 when the handler runs on another store
+func (h *Handler) requireDatabase(c *gin.Context) (*database.Database, bool) {
+	db, ok := h.db.(*database.Database)
+	if !ok {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "Not available without a database"})
+		return nil, false
+	}
+	return db, true
+}
+
+// updateDistrictHulls recomputes the district hulls, failing when the handler
+// runs on a store other than a database
+func (h *Handler) updateDistrictHulls() error {
+	if h.districtManager == nil {
+		return fmt.Errorf("district hulls are not available without a database")
 	}
+	return h.districtManager.UpdateDistrictHulls()
+}
+
+// withDatabase returns a copy of the handler that runs its queries on db
+func (h *Handler) withDatabase(db database.APIStore) *Handler {
+	reader := *h
+	reader.db = db
+	return &reader
+}
+
+// SetLiveHub publishes the progress and new properties of the spiders the API
+// starts to hub instead of the default hub
+func (h *Handler) SetLiveHub(hub *live.Hub) {
+	h.spiderManager.SetLiveHub(hub)
+}
+
+// dbFor returns the handler's store bound to the context of a request when it
+// supports it, as the database does, so its queries are cancelled when the
+// client goes away or the query timeout passes
+func (h *Handler) dbFor(c *gin.Context) database.APIStore {
+	if db, ok := h.db.(*database.Database); ok {
+		return db.WithContext(c.Request.Context())
+	}
+	return h.db
 }
 
 func (h *Handler) GetAllProperties(c *gin.Context) {
@@ -81,10 +174,204 @@ func (h *Handler) GetAllProperties(c *gin.Context) {
 		h.logger.WithError(err).Error("Failed to parse date range")
 	}
 
+	var opts models.PropertyListOptions
+	var err error
+	if opts.Limit, err = strconv.Atoi(c.DefaultQuery("limit", "0")); err != nil || opts.Limit < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Limit must be a non-negative number"})
+		return
+	}
+	if opts.Offset, err = strconv.Atoi(c.DefaultQuery("offset", "0")); err != nil || opts.Offset < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Offset must be a non-negative number"})
+		return
+	}
+	opts.SortBy = c.DefaultQuery("sort_by", "id")
+	if !slices.Contains(database.PropertySortFields(), opts.SortBy) && !database.IsScoreSort(opts.SortBy) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "sort_by must be one of " + strings.Join(database.PropertySortFields(), ", ") + " or score.<name>",
+		})
+		return
+	}
+	opts.Order = strings.ToLower(c.DefaultQuery("order", "asc"))
+	if opts.Order != "asc" && opts.Order != "desc" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Order must be asc or desc"})
+		return
+	}
+	opts.Tags = c.QueryArray("tag")
+	if !propertyListFilters(c, &opts) {
+		return
+	}
+
 	city := c.Query("city")
-	properties, err := h.db.GetAllProperties(dateRange.StartDate, dateRange.EndDate, city)
+	properties, err := h.dbFor(c).GetAllProperties(dateRange.StartDate, dateRange.EndDate, city, opts)
+	if err != nil {
+		abortWithError(c, err, "Failed to get properties")
+		return
+	}
+
+	c.JSON(http.StatusOK, properties)
+}
+
+// propertyListFilters reads the price, living area, room, score, energy
+// label, property type and status filters of the property list into opts. It writes
+// a 400 response and returns false when one is invalid.
+//
+//openapi:query min_price max_price min_living_area max_living_area min_rooms max_rooms min_score[name] max_score[name]
+func propertyListFilters(c *gin.Context, opts *models.PropertyListOptions) bool {
+	ranges := []struct {
+		name     string
+		min, max *int
+	}{
+		{"price", &opts.MinPrice, &opts.MaxPrice},
+		{"living_area", &opts.MinLivingArea, &opts.MaxLivingArea},
+		{"rooms", &opts.MinRooms, &opts.MaxRooms},
+	}
+	for _, r := range ranges {
+		for _, bound := range []struct {
+			param string
+			value *int
+		}{{"min_" + r.name, r.min}, {"max_" + r.name, r.max}} {
+			raw := c.Query(bound.param)
+			if raw == "" {
+				continue
+			}
+			value, err := strconv.Atoi(raw)
+			if err != nil || value < 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": bound.param + " must be a non-negative number"})
+				return false
+			}
+			*bound.value = value
+		}
+		if *r.min > 0 && *r.max > 0 && *r.min > *r.max {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "min_" + r.name + " must not exceed max_" + r.name})
+			return false
+		}
+	}
+
+	for _, bound := range []struct {
+		param  string
+		scores *map[string]float64
+	}{{"min_score", &opts.MinScores}, {"max_score", &opts.MaxScores}} {
+		for name, raw := range c.QueryMap(bound.param) {
+			if !database.ValidScoreName(name) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid score name " + strconv.Quote(name)})
+				return false
+			}
+			value, err := strconv.ParseFloat(raw, 64)
+			if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": bound.param + "[" + name + "] must be a number"})
+				return false
+			}
+			if *bound.scores == nil {
+				*bound.scores = make(map[string]float64)
+			}
+			(*bound.scores)[name] = value
+		}
+	}
+
+	opts.EnergyLabels = c.QueryArray("energy_label")
+	opts.PropertyTypes = c.QueryArray("property_type")
+	opts.Statuses = c.QueryArray("status")
+	for _, status := range opts.Statuses {
+		if status != "active" && status != "sold" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Status must be active or sold"})
+			return false
+		}
+	}
+	return true
+}
+
+// propertyFilter reads the date range, city, status and tag filters of a
+// request.
+// It writes a 400 response and returns false when the status is invalid.
+func (h *Handler) propertyFilter(c *gin.Context) (models.PropertyFilter, bool) {
+	var dateRange DateRange
+	if err := c.ShouldBindQuery(&dateRange); err != nil {
+		h.logger.WithError(err).Error("Failed to parse date range")
+	}
+
+	filter := models.PropertyFilter{
+		StartDate: dateRange.StartDate,
+		EndDate:   dateRange.EndDate,
+		City:      c.Query("city"),
+		Status:    c.Query("status"),
+		Tags:      c.QueryArray("tag"),
+	}
+	if filter.Status != "" && filter.Status != "active" && filter.Status != "sold" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Status must be active or sold"})
+		return filter, false
+	}
+	return filter, true
+}
+
+// GetPropertiesInBounds returns the properties inside the map viewport given by
+// min_lat, min_lng, max_lat and max_lng, filtered like the property list and
+// optionally by status
+func (h *Handler) GetPropertiesInBounds(c *gin.Context) {
+	var bounds [4]float64
+	for i, param := range []string{"min_lat", "min_lng", "max_lat", "max_lng"} {
+		value, err := strconv.ParseFloat(c.Query(param), 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "min_lat, min_lng, max_lat and max_lng must be numbers"})
+			return
+		}
+		bounds[i] = value
+	}
+	minLat, minLng, maxLat, maxLng := bounds[0], bounds[1], bounds[2], bounds[3]
+	if minLat > maxLat || minLng > maxLng {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Minimum coordinates must not exceed the maximum coordinates"})
+		return
+	}
+	if minLat < -90 || maxLat > 90 || minLng < -180 || maxLng > 180 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Coordinates are out of range"})
+		return
+	}
+
+	filter, ok := h.propertyFilter(c)
+	if !ok {
+		return
+	}
+
+	properties, err := h.dbFor(c).GetPropertiesInBounds(minLat, minLng, maxLat, maxLng, filter)
 	if err != nil {
-		h.logger.WithError(err).Error("Failed to get properties")
+		h.logger.WithError(err).Error("Failed to get properties in bounds")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get properties"})
+		return
+	}
+
+	c.JSON(http.StatusOK, properties)
+}
+
+// maxNearbyRadius is the largest radius in meters accepted by GetPropertiesNear
+const maxNearbyRadius = 50000
+
+// GetPropertiesNear returns the properties within radius meters (default 1000)
+// of lat and lng, nearest first, filtered like the property list
+func (h *Handler) GetPropertiesNear(c *gin.Context) {
+	lat, latErr := strconv.ParseFloat(c.Query("lat"), 64)
+	lng, lngErr := strconv.ParseFloat(c.Query("lng"), 64)
+	if latErr != nil || lngErr != nil || lat < -90 || lat > 90 || lng < -180 || lng > 180 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "lat and lng must be valid coordinates"})
+		return
+	}
+	radius, err := strconv.ParseFloat(c.DefaultQuery("radius", "1000"), 64)
+	if err != nil || radius <= 0 || radius > maxNearbyRadius {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Radius must be between 0 and %d meters", maxNearbyRadius)})
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Limit must be a non-negative number"})
+		return
+	}
+
+	filter, ok := h.propertyFilter(c)
+	if !ok {
+		return
+	}
+
+	properties, err := h.dbFor(c).GetPropertiesNear(lat, lng, radius, limit, filter)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get nearby properties")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get properties"})
 		return
 	}
@@ -98,26 +385,48 @@ func (h *Handler) GetPropertyStats(c *gin.Context) {
 		h.logger.WithError(err).Error("Failed to parse date range")
 	}
 
+	asOf, ok := statsAsOf(c)
+	if !ok {
+		return
+	}
+
 	city := c.Query("city")
-	stats, err := h.db.GetPropertyStats(dateRange.StartDate, dateRange.EndDate, city)
+	stats, err := h.dbFor(c).GetPropertyStats(dateRange.StartDate, dateRange.EndDate, city, asOf)
 	if err != nil {
-		h.logger.WithError(err).Error("Failed to get property stats")
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get property stats"})
+		abortWithError(c, err, "Failed to get property stats")
 		return
 	}
 
 	c.JSON(http.StatusOK, stats)
 }
 
+// GetAreaStats returns the stats of the properties whose postal code starts
+// with the given prefix, or of one street block for a full (PC6) postal code
+// such as 1012AB
 func (h *Handler) GetAreaStats(c *gin.Context) {
-	postalPrefix := c.Param("postal_prefix")
+	postalCode := c.Param("postal_code")
 	var dateRange DateRange
 	if err := c.ShouldBindQuery(&dateRange); err != nil {
 		h.logger.WithError(err).Error("Failed to parse date range")
 	}
+	asOf, ok := statsAsOf(c)
+	if !ok {
+		return
+	}
 
 	city := c.Query("city")
-	stats, err := h.db.GetAreaStats(postalPrefix, dateRange.StartDate, dateRange.EndDate, city)
+	if fullPostalCodeRegex.MatchString(postalCode) {
+		stats, err := h.dbFor(c).GetPostalCodeStats(postalCode, dateRange.StartDate, dateRange.EndDate, city, asOf)
+		if err != nil {
+			h.logger.WithError(err).Error("Failed to get postal code stats")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get area stats"})
+			return
+		}
+		c.JSON(http.StatusOK, stats)
+		return
+	}
+
+	stats, err := h.dbFor(c).GetAreaStats(postalCode, dateRange.StartDate, dateRange.EndDate, city, asOf)
 	if err != nil {
 		h.logger.WithError(err).Error("Failed to get area stats")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get area stats"})
@@ -140,7 +449,7 @@ func (h *Handler) GetRecentSales(c *gin.Context) {
 	}
 
 	city := c.Query("city")
-	sales, err := h.db.GetRecentSales(limit, dateRange.StartDate, dateRange.EndDate, city)
+	sales, err := h.dbFor(c).GetRecentSales(limit, dateRange.StartDate, dateRange.EndDate, city)
 	if err != nil {
 		h.logger.WithError(err).Error("Failed to get recent sales")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get recent sales"})
@@ -150,21 +459,39 @@ func (h *Handler) GetRecentSales(c *gin.Context) {
 	c.JSON(http.StatusOK, sales)
 }
 
+// UpdateCoordinates queues a geocode task for the properties without
+// coordinates
 func (h *Handler) UpdateCoordinates(c *gin.Context) {
-	err := h.db.UpdateMissingCoordinates(h.geocoder)
+	task, err := h.tasks.Submit("geocode", nil)
 	if err != nil {
 		h.logger.WithError(err).Error("Failed to update coordinates")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update coordinates"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
+	c.JSON(http.StatusAccepted, gin.H{
 		"status": "Coordinates update process started",
+		"task":   task,
 	})
 }
 
+// GetGeocodingStatus returns the number of properties waiting to be geocoded
+// and which server holds, or last held, the geocoding lease
+func (h *Handler) GetGeocodingStatus(c *gin.Context) {
+	status, err := h.dbFor(c).GetGeocodingStatus()
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get geocoding status")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get geocoding status"})
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
+
 func (h *Handler) UpdateDistrictHulls(c *gin.Context) {
-	err := h.districtManager.UpdateDistrictHulls()
+	if _, ok := h.requireDatabase(c); !ok {
+		return
+	}
+	err := h.updateDistrictHulls()
 	if err != nil {
 		h.logger.WithError(err).Error("Failed to update district hulls")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update district hulls"})
@@ -180,7 +507,7 @@ func (h *Handler) RunActiveSpider(c *gin.Context) {
 	var req SpiderRequest
 	if err := c.ShouldBindJSON(&req); err != nil || req.Place == "" {
 		// If no parameters provided or invalid JSON, use configured cities
-		cities, err := config.GetCityNames(h.db)
+		cities, err := config.GetCityNames(h.dbFor(c))
 		if err != nil {
 			h.logger.WithError(err).Error("Failed to get configured cities")
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get configured cities"})
@@ -189,6 +516,7 @@ func (h *Handler) RunActiveSpider(c *gin.Context) {
 
 		// Start a single goroutine that processes all cities sequentially
 		go func() {
+			defer errorsink.Recover("scraping")
 			for _, city := range cities {
 				normalizedCity := config.NormalizeCity(city)
 
@@ -216,7 +544,7 @@ func (h *Handler) RunActiveSpider(c *gin.Context) {
 
 			// Update district hulls after all spiders have completed
 			h.logger.Info("Starting district hulls update")
-			if err := h.districtManager.UpdateDistrictHulls(); err != nil {
+			if err := h.updateDistrictHulls(); err != nil {
 				h.logger.WithError(err).Error("Failed to update district hulls after spider completion")
 				return
 			}
@@ -236,6 +564,7 @@ func (h *Handler) RunActiveSpider(c *gin.Context) {
 
 	// If a specific place was provided, just process that one
 	go func() {
+		defer errorsink.Recover("scraping")
 		err := h.spiderManager.RunActiveSpider(req.Place, nil)
 		if err != nil {
 			h.logger.WithError(err).Error("Failed to run active spider")
@@ -252,7 +581,7 @@ func (h *Handler) RunActiveSpider(c *gin.Context) {
 
 		// Update district hulls after processing the specific place
 		h.logger.Info("Starting district hulls update")
-		if err := h.districtManager.UpdateDistrictHulls(); err != nil {
+		if err := h.updateDistrictHulls(); err != nil {
 			h.logger.WithError(err).Error("Failed to update district hulls after spider completion")
 			return
 		}
@@ -280,7 +609,7 @@ func (h *Handler) RunSpider(c *gin.Context) {
 	// Run the appropriate spider based on type
 	if req.Place == "" {
 		// If no place specified, run for all configured cities
-		cities, err := config.GetCityNames(h.db)
+		cities, err := config.GetCityNames(h.dbFor(c))
 		if err != nil {
 			h.logger.WithError(err).Error("Failed to get configured cities")
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get configured cities"})
@@ -289,6 +618,7 @@ func (h *Handler) RunSpider(c *gin.Context) {
 
 		// Start a single goroutine that processes all cities sequentially
 		go func() {
+			defer errorsink.Recover("scraping")
 			for _, city := range cities {
 				normalizedCity := config.NormalizeCity(city)
 
@@ -317,7 +647,7 @@ func (h *Handler) RunSpider(c *gin.Context) {
 
 			// Update district hulls after all spiders have completed
 			h.logger.Info("Starting district hulls update")
-			if err := h.districtManager.UpdateDistrictHulls(); err != nil {
+			if err := h.updateDistrictHulls(); err != nil {
 				h.logger.WithError(err).Error("Failed to update district hulls after spider completion")
 				return
 			}
@@ -337,6 +667,7 @@ func (h *Handler) RunSpider(c *gin.Context) {
 
 	// If a specific place was provided
 	go func() {
+		defer errorsink.Recover("scraping")
 		// Run active spider first if requested
 		if req.Type == "active" || req.QueueSold {
 			err := h.spiderManager.RunActiveSpider(normalizedCity, nil)
@@ -356,7 +687,7 @@ func (h *Handler) RunSpider(c *gin.Context) {
 
 		// Update district hulls after processing
 		h.logger.Info("Starting district hulls update")
-		if err := h.districtManager.UpdateDistrictHulls(); err != nil {
+		if err := h.updateDistrictHulls(); err != nil {
 			h.logger.WithError(err).Error("Failed to update district hulls after spider completion")
 			return
 		}
@@ -371,7 +702,7 @@ func (h *Handler) RunSpider(c *gin.Context) {
 
 // GetTelegramConfig returns the current Telegram configuration
 func (h *Handler) GetTelegramConfig(c *gin.Context) {
-	config, err := h.db.GetTelegramConfig()
+	config, err := h.dbFor(c).GetTelegramConfig()
 	if err != nil {
 		h.logger.WithError(err).Error("Failed to get Telegram config")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get Telegram config"})
@@ -400,9 +731,13 @@ func (h *Handler) UpdateTelegramConfig(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
 		return
 	}
+	if err := (format.Settings{Locale: req.Locale, Currency: req.Currency}).Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
 	// Get existing config
-	config, err := h.db.GetTelegramConfig()
+	config, err := h.dbFor(c).GetTelegramConfig()
 	if err != nil {
 		h.logger.WithError(err).Error("Failed to get existing config")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get existing configuration"})
@@ -410,7 +745,7 @@ func (h *Handler) UpdateTelegramConfig(c *gin.Context) {
 	}
 
 	// Update the configuration
-	if err := h.db.UpdateTelegramConfig(&req); err != nil {
+	if err := h.dbFor(c).UpdateTelegramConfig(&req); err != nil {
 		h.logger.WithError(err).Error("Failed to update config")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update configuration"})
 		return
@@ -422,11 +757,15 @@ func (h *Handler) UpdateTelegramConfig(c *gin.Context) {
 			IsEnabled: req.IsEnabled,
 			BotToken:  req.BotToken,
 			ChatID:    req.ChatID,
+			Locale:    req.Locale,
+			Currency:  req.Currency,
 		}
 	} else {
 		config.IsEnabled = req.IsEnabled
 		config.BotToken = req.BotToken
 		config.ChatID = req.ChatID
+		config.Locale = req.Locale
+		config.Currency = req.Currency
 	}
 	h.telegramService.UpdateConfig(config)
 
@@ -438,7 +777,7 @@ func (h *Handler) UpdateTelegramConfig(c *gin.Context) {
 
 // GetTelegramFilters returns the current notification filters
 func (h *Handler) GetTelegramFilters(c *gin.Context) {
-	filters, err := h.db.GetTelegramFilters()
+	filters, err := h.dbFor(c).GetTelegramFilters()
 	if err != nil {
 		h.logger.WithError(err).Error("Failed to get Telegram filters")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get Telegram filters"})
@@ -448,6 +787,12 @@ func (h *Handler) GetTelegramFilters(c *gin.Context) {
 	c.JSON(http.StatusOK, filters)
 }
 
+// ListRuleFields returns the fields notification and search rules can test,
+// with their kind
+func (h *Handler) ListRuleFields(c *gin.Context) {
+	c.JSON(http.StatusOK, models.RuleFields())
+}
+
 // UpdateTelegramFilters updates the notification filters
 func (h *Handler) UpdateTelegramFilters(c *gin.Context) {
 	var filters models.TelegramFilters
@@ -488,7 +833,12 @@ func (h *Handler) UpdateTelegramFilters(c *gin.Context) {
 		}
 	}
 
-	if err := h.db.UpdateTelegramFilters(&filters); err != nil {
+	if err := filters.Rules.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.dbFor(c).UpdateTelegramFilters(&filters); err != nil {
 		h.logger.WithError(err).Error("Failed to update Telegram filters")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save filters"})
 		return
@@ -503,7 +853,7 @@ func (h *Handler) UpdateTelegramFilters(c *gin.Context) {
 // TestTelegramConfig tests the Telegram configuration by sending a sample property notification
 func (h *Handler) TestTelegramConfig(c *gin.Context) {
 	// Get the current configuration from the database
-	config, err := h.db.GetTelegramConfig()
+	config, err := h.dbFor(c).GetTelegramConfig()
 	if err != nil {
 		h.logger.WithError(err).Error("Failed to get Telegram config")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get Telegram configuration"})
@@ -536,7 +886,7 @@ func (h *Handler) TestTelegramConfig(c *gin.Context) {
 	mockService.UpdateConfig(config)
 
 	// Get current filters and apply them to the mock service
-	if filters, err := h.db.GetTelegramFilters(); err == nil {
+	if filters, err := h.dbFor(c).GetTelegramFilters(); err == nil {
 		mockService.UpdateFilters(filters)
 	}
 
@@ -557,7 +907,7 @@ func (h *Handler) TestTelegramConfig(c *gin.Context) {
 
 // CheckInitialSetup checks if the database needs initial configuration
 func (h *Handler) CheckInitialSetup(c *gin.Context) {
-	areas, err := h.db.GetMetropolitanAreas()
+	areas, err := h.dbFor(c).GetMetropolitanAreas()
 	if err != nil {
 		h.logger.WithError(err).Error("Failed to check metropolitan areas")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check database state"})
@@ -569,3 +919,165 @@ func (h *Handler) CheckInitialSetup(c *gin.Context) {
 		"message":     "Database initialization check completed",
 	})
 }
+
+// SearchProperties finds properties by street, neighborhood, postal code or city
+func (h *Handler) SearchProperties(c *gin.Context) {
+	query := strings.TrimSpace(c.Query("q"))
+	if len([]rune(query)) < minSearchQueryLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Query must be at least 2 characters"})
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultSearchLimit)))
+	if err != nil || limit <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Limit must be a positive number"})
+		return
+	}
+	if limit > maxSearchLimit {
+		limit = maxSearchLimit
+	}
+
+	includeArchived, err := strconv.ParseBool(c.DefaultQuery("include_archived", "false"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "include_archived must be true or false"})
+		return
+	}
+
+	properties, mode, err := h.dbFor(c).SearchProperties(query, limit)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to search properties")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search properties"})
+		return
+	}
+
+	// Archived properties fill the remaining places
+	if includeArchived && len(properties) < limit {
+		archived, err := h.dbFor(c).SearchArchivedProperties(query, limit-len(properties))
+		if err != nil {
+			h.logger.WithError(err).Error("Failed to search archived properties")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search properties"})
+			return
+		}
+		properties = append(properties, archived...)
+	}
+
+	c.JSON(http.StatusOK, models.PropertySearchResult{
+		Query:      query,
+		Mode:       mode,
+		Properties: properties,
+	})
+}
+
+// GetProperty returns a property with its history and nearby comparables.
+// The geocode_status tells whether it is located, waits for geocoding, could
+// not be geocoded or lacks an address.
+func (h *Handler) GetProperty(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid property ID"})
+		return
+	}
+
+	detail, err := h.dbFor(c).GetPropertyDetail(id)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get property")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get property"})
+		return
+	}
+	if detail == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Property not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, detail)
+}
+
+// GetPropertyHistory returns the price and status transitions of a property
+func (h *Handler) GetPropertyHistory(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid property ID"})
+		return
+	}
+
+	history, err := h.dbFor(c).GetPropertyHistory(id)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get property history")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get property history"})
+		return
+	}
+
+	c.JSON(http.StatusOK, history)
+}
+
+// GetPropertyListings returns every listing of the same home, including the
+// ones under earlier URLs, first listing first
+func (h *Handler) GetPropertyListings(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid property ID"})
+		return
+	}
+
+	listings, err := h.dbFor(c).GetPropertyListings(id)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get property listings")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get property listings"})
+		return
+	}
+	if len(listings) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Property not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, listings)
+}
+
+// GetPropertyBidAdvice suggests a bid range for a property
+func (h *Handler) GetPropertyBidAdvice(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid property ID"})
+		return
+	}
+
+	advice, err := h.dbFor(c).GetBidAdvice(id)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get bid advice")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get bid advice"})
+		return
+	}
+	if advice == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Property not found"})
+		return
+	}
+	if advice.AskingPrice <= 0 {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Property has no asking price"})
+		return
+	}
+
+	c.JSON(http.StatusOK, advice)
+}
+
+// GetPropertySnapshots returns the raw spider payloads stored for a property
+func (h *Handler) GetPropertySnapshots(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid property ID"})
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if err != nil || limit <= 0 {
+		limit = 10
+	}
+
+	snapshots, err := h.dbFor(c).GetPropertySnapshots(id, limit)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get property snapshots")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get property snapshots"})
+		return
+	}
+
+	c.JSON(http.StatusOK, snapshots)
+}
@@ -0,0 +1,251 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"fundamental/server/config"
+	"fundamental/server/internal/database"
+	"fundamental/server/internal/models"
+	"fundamental/server/internal/tasks"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// fakeStore keeps the properties of a test in memory. It implements the
+// methods the tests reach; the embedded nil APIStore panics on any other, so
+// a handler using more of the store fails the test loudly.
+type fakeStore struct {
+	database.APIStore
+	properties  map[int64]models.PropertyDetail
+	preferences map[[3]string]json.RawMessage // by owner, namespace and key
+	sharedViews map[string]string             // tokens by definition
+}
+
+// fakeTaskStore backs the task manager of the tests, which start no tasks
+type fakeTaskStore struct {
+	database.TaskStore
+}
+
+func (f *fakeStore) GetTelegramConfig() (*models.TelegramConfig, error) {
+	return nil, nil
+}
+
+func (f *fakeStore) GetTelegramFilters() (*models.TelegramFilters, error) {
+	return &models.TelegramFilters{}, nil
+}
+
+func (f *fakeStore) GetPropertyDetail(propertyID int64) (*models.PropertyDetail, error) {
+	detail, ok := f.properties[propertyID]
+	if !ok {
+		return nil, nil
+	}
+	return &detail, nil
+}
+
+func (f *fakeStore) GetPropertyHistory(propertyID int64) ([]models.PropertyHistoryEntry, error) {
+	return f.properties[propertyID].History, nil
+}
+
+func (f *fakeStore) CountPreferences(owner string) (int, error) {
+	count := 0
+	for pref := range f.preferences {
+		if pref[0] == owner {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (f *fakeStore) HasPreference(owner, namespace, key string) (bool, error) {
+	_, ok := f.preferences[[3]string{owner, namespace, key}]
+	return ok, nil
+}
+
+func (f *fakeStore) SetPreference(owner, namespace, key string, value json.RawMessage) error {
+	f.preferences[[3]string{owner, namespace, key}] = value
+	return nil
+}
+
+func (f *fakeStore) CreateSharedView(definition json.RawMessage) (*models.SharedView, error) {
+	token, ok := f.sharedViews[string(definition)]
+	if !ok {
+		token = fmt.Sprintf("view%d", len(f.sharedViews)+1)
+		f.sharedViews[string(definition)] = token
+	}
+	return &models.SharedView{Token: token, Definition: definition}, nil
+}
+
+// newFakeRouter serves the API routes on a fake store with one located
+// property, ID 1, that was listed and then reduced in price
+func newFakeRouter(t *testing.T) *gin.Engine {
+	router, _ := newFakeStoreRouter(t)
+	return router
+}
+
+// newFakeStoreRouter is newFakeRouter that also returns its store
+func newFakeStoreRouter(t *testing.T) (*gin.Engine, *fakeStore) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	listed, reduced := 450000, 425000
+	store := &fakeStore{properties: map[int64]models.PropertyDetail{
+		1: {
+			Property:      models.Property{ID: 1, Street: "Keizersgracht 1", City: "Amsterdam", Price: reduced},
+			GeocodeStatus: models.GeocodeLocated,
+			History: []models.PropertyHistoryEntry{
+				{ID: 1, ChangeType: models.HistoryListed, Status: "active", Price: &listed},
+				{ID: 2, ChangeType: models.HistoryPriceChange, Status: "active", Price: &reduced, PreviousPrice: &listed},
+			},
+			Comparables: []models.NearbyProperty{},
+		},
+	}, preferences: map[[3]string]json.RawMessage{}, sharedViews: map[string]string{}}
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	dir := t.TempDir()
+	cfg := &config.Config{OutputDir: dir, CacheDir: dir}
+
+	router := gin.New()
+	SetupRoutes(router, store, cfg, tasks.NewManager(fakeTaskStore{}, 1, logger), nil, nil, logger)
+	return router, store
+}
+
+// serve answers a request with the router
+func serve(router *gin.Engine, method, path string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader("{}")))
+	return w
+}
+
+func TestGetPropertyOnFakeStore(t *testing.T) {
+	router := newFakeRouter(t)
+
+	tests := []struct {
+		name   string
+		path   string
+		status int
+	}{
+		{"existing property", "/api/properties/1", http.StatusOK},
+		{"unknown property", "/api/properties/2", http.StatusNotFound},
+		{"invalid ID", "/api/properties/first", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(router, http.MethodGet, tt.path)
+			if w.Code != tt.status {
+				t.Fatalf("GET %s answered %d, want %d: %s", tt.path, w.Code, tt.status, w.Body)
+			}
+		})
+	}
+
+	var detail models.PropertyDetail
+	if err := json.Unmarshal(serve(router, http.MethodGet, "/api/properties/1").Body.Bytes(), &detail); err != nil {
+		t.Fatalf("failed to decode property: %v", err)
+	}
+	if detail.ID != 1 || detail.GeocodeStatus != models.GeocodeLocated || len(detail.History) != 2 {
+		t.Errorf("got property %d, geocode status %q and %d history rows, want 1, %q and 2",
+			detail.ID, detail.GeocodeStatus, len(detail.History), models.GeocodeLocated)
+	}
+}
+
+func TestGetPropertyHistoryOnFakeStore(t *testing.T) {
+	router := newFakeRouter(t)
+
+	w := serve(router, http.MethodGet, "/api/properties/1/history")
+	if w.Code != http.StatusOK {
+		t.Fatalf("answered %d: %s", w.Code, w.Body)
+	}
+	var history []models.PropertyHistoryEntry
+	if err := json.Unmarshal(w.Body.Bytes(), &history); err != nil {
+		t.Fatalf("failed to decode history: %v", err)
+	}
+	if len(history) != 2 || history[1].PreviousPrice == nil || *history[1].PreviousPrice != 450000 {
+		t.Errorf("got history %+v, want the listing and the price change from 450000", history)
+	}
+}
+
+func TestDatabaseFileFeaturesOnFakeStore(t *testing.T) {
+	router := newFakeRouter(t)
+
+	for _, path := range []string{"/api/districts/update", "/api/import?format=json"} {
+		if w := serve(router, http.MethodPost, path); w.Code != http.StatusNotImplemented {
+			t.Errorf("POST %s answered %d, want %d", path, w.Code, http.StatusNotImplemented)
+		}
+	}
+}
+
+func TestSetPreferenceLimitOnFakeStore(t *testing.T) {
+	router, store := newFakeStoreRouter(t)
+	const owner = "session-full"
+	for i := 0; i < maxPreferencesPerOwner; i++ {
+		store.preferences[[3]string{owner, "charts", fmt.Sprintf("chart%d", i)}] = json.RawMessage(`true`)
+	}
+
+	tests := []struct {
+		name   string
+		owner  string
+		key    string
+		status int
+	}{
+		{"overwrite a stored preference", owner, "chart0", http.StatusOK},
+		{"new preference over the limit", owner, "new", http.StatusBadRequest},
+		{"new preference of another session", "session-empty", "new", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPut, "/api/preferences/charts/"+tt.key, strings.NewReader("false"))
+			req.Header.Set(sessionHeader, tt.owner)
+			router.ServeHTTP(w, req)
+			if w.Code != tt.status {
+				t.Fatalf("PUT %s answered %d, want %d: %s", tt.key, w.Code, tt.status, w.Body)
+			}
+		})
+	}
+	if got := string(store.preferences[[3]string{owner, "charts", "chart0"}]); got != "false" {
+		t.Errorf("stored chart0 = %s, want false", got)
+	}
+}
+
+func TestCreateSharedViewOnFakeStore(t *testing.T) {
+	router, _ := newFakeStoreRouter(t)
+	share := func(definition string) models.SharedView {
+		t.Helper()
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/share", strings.NewReader(definition)))
+		if w.Code != http.StatusCreated {
+			t.Fatalf("POST /api/share %s answered %d, want %d: %s", definition, w.Code, http.StatusCreated, w.Body)
+		}
+		var view models.SharedView
+		if err := json.Unmarshal(w.Body.Bytes(), &view); err != nil {
+			t.Fatalf("failed to decode shared view: %v", err)
+		}
+		return view
+	}
+
+	first := share(`{"city": "amsterdam", "min_price": 100000, "map": {"zoom": 12, "center": [52.37, 4.89]}}`)
+	reordered := share(`{"map":{"center":[52.37,4.89],"zoom":12},"min_price":100000,"city":"amsterdam"}`)
+	if reordered.Token != first.Token {
+		t.Errorf("reordered definition got token %q, want %q", reordered.Token, first.Token)
+	}
+	if want := `{"city":"amsterdam","map":{"center":[52.37,4.89],"zoom":12},"min_price":100000}`; string(first.Definition) != want {
+		t.Errorf("stored definition %s, want %s", first.Definition, want)
+	}
+	if other := share(`{"city": "amsterdam", "min_price": 200000}`); other.Token == first.Token {
+		t.Errorf("different definition got the same token %q", other.Token)
+	}
+
+	for _, definition := range []string{`{}`, `[1, 2]`, `{"city": "amsterdam"} {}`} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/share", strings.NewReader(definition)))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("POST /api/share %s answered %d, want %d", definition, w.Code, http.StatusBadRequest)
+		}
+	}
+}
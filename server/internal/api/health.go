@@ -0,0 +1,92 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"fundamental/server/internal/database"
+	"fundamental/server/internal/models"
+	"net/http"
+	"os/exec"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// readinessTimeout bounds the database queries of a readiness probe, so a
+// stuck database fails the probe instead of hanging it
+const readinessTimeout = 5 * time.Second
+
+// HealthHandler answers the probes of container orchestrators and uptime
+// monitors
+type HealthHandler struct {
+	db database.HealthStore
+}
+
+// NewHealthHandler creates a handler probing db
+func NewHealthHandler(db database.HealthStore) *HealthHandler {
+	return &HealthHandler{db: db}
+}
+
+// SetupHealthRoutes registers the liveness and readiness probes. They are
+// served outside /api/, without a token and without API versioning.
+func SetupHealthRoutes(router *gin.Engine, db database.HealthStore) {
+	handler := NewHealthHandler(db)
+
+	router.GET("/healthz", handler.GetLiveness)
+	router.HEAD("/healthz", handler.GetLiveness)
+	router.GET("/readyz", handler.GetReadiness)
+	router.HEAD("/readyz", handler.GetReadiness)
+}
+
+// isHealthPath reports whether a path serves the health probes
+func isHealthPath(path string) bool {
+	return path == "/healthz" || path == "/readyz"
+}
+
+// GetLiveness reports that the server process is up. It checks nothing else,
+// so an orchestrator restarts the server only when it stopped answering.
+func (h *HealthHandler) GetLiveness(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// GetReadiness reports whether the server can serve requests: the database is
+// reachable, its migrations are applied and python3, which runs the spiders
+// and the hull generation, is found. It answers 503 with the failed checks
+// otherwise.
+func (h *HealthHandler) GetReadiness(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), readinessTimeout)
+	defer cancel()
+	db := h.db
+	if concrete, ok := db.(*database.Database); ok {
+		db = concrete.WithContext(ctx)
+	}
+
+	report := &models.SelfCheckReport{OK: true}
+	err := db.Ping()
+	report.Add("database", h.db.Dialect().Name(), err)
+	if err == nil {
+		report.Add("schema", fmt.Sprintf("database schema (version %d)", database.SchemaVersion), checkSchemaVersion(db))
+	}
+	_, err = exec.LookPath("python3")
+	report.Add("prerequisite", "python3", err)
+
+	c.Header("Cache-Control", "no-store")
+	if !report.OK {
+		c.JSON(http.StatusServiceUnavailable, report)
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// checkSchemaVersion fails when the database is not migrated to the version
+// of this server
+func checkSchemaVersion(db database.HealthStore) error {
+	version, err := db.AppliedSchemaVersion()
+	if err != nil {
+		return err
+	}
+	if version != database.SchemaVersion {
+		return fmt.Errorf("schema version is %d, expected %d", version, database.SchemaVersion)
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetPropertyImages returns the photos of a property in listing order
+func (h *Handler) GetPropertyImages(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid property ID"})
+		return
+	}
+
+	images, err := h.dbFor(c).GetPropertyImages(id)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get property images")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get property images"})
+		return
+	}
+	if images == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Property not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, images)
+}
+
+// GetPropertyThumbnail serves the cached thumbnail of a property's primary
+// photo, downloading it on first use
+func (h *Handler) GetPropertyThumbnail(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid property ID"})
+		return
+	}
+	if h.thumbnails == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Thumbnails are not enabled"})
+		return
+	}
+
+	path, err := h.dbFor(c).CacheThumbnail(h.thumbnails, id)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get property thumbnail")
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to get property thumbnail"})
+		return
+	}
+	if path == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Property has no photos"})
+		return
+	}
+
+	c.Header("Cache-Control", "public, max-age=86400")
+	c.File(path)
+}
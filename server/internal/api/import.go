@@ -0,0 +1,121 @@
+package api
+
+import (
+	"encoding/json"
+	"fundamental/server/internal/importer"
+	"fundamental/server/internal/models"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ImportCSV imports historical properties from an uploaded CSV file.
+// The multipart form expects a "file" field and an optional "options" field
+// holding a JSON encoded importer.Options (column mapping, delimiter, date format).
+func (h *Handler) ImportCSV(c *gin.Context) {
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing CSV file"})
+		return
+	}
+
+	opts, ok := importOptions(c)
+	if !ok {
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to open uploaded CSV")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read uploaded file"})
+		return
+	}
+	defer file.Close()
+
+	h.runImport(c, "csv", file, opts)
+}
+
+// ImportProperties imports historical properties from CSV or a JSON array of
+// property objects, sent as the request body or as a multipart "file" field
+// with optional "options". The format comes from the format parameter, else
+// the file extension or Content-Type. Rows are validated and deduplicated like
+// spider items, and the response reports the outcome of every row.
+func (h *Handler) ImportProperties(c *gin.Context) {
+	format := strings.ToLower(c.Query("format"))
+	var body io.Reader = c.Request.Body
+	var opts importer.Options
+
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		fileHeader, err := c.FormFile("file")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Missing import file"})
+			return
+		}
+		var ok bool
+		if opts, ok = importOptions(c); !ok {
+			return
+		}
+		file, err := fileHeader.Open()
+		if err != nil {
+			h.logger.WithError(err).Error("Failed to open uploaded import file")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read uploaded file"})
+			return
+		}
+		defer file.Close()
+		body = file
+		if format == "" {
+			format = strings.TrimPrefix(strings.ToLower(filepath.Ext(fileHeader.Filename)), ".")
+		}
+	} else if format == "" {
+		switch c.ContentType() {
+		case "application/json":
+			format = "json"
+		case "text/csv":
+			format = "csv"
+		}
+	}
+
+	if format != "csv" && format != "json" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Format must be csv or json"})
+		return
+	}
+	h.runImport(c, format, body, opts)
+}
+
+// importOptions reads the optional JSON encoded "options" form field
+func importOptions(c *gin.Context) (importer.Options, bool) {
+	var opts importer.Options
+	if raw := c.PostForm("options"); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &opts); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid import options"})
+			return opts, false
+		}
+	}
+	return opts, true
+}
+
+// runImport imports the data in the given format and responds with the report
+func (h *Handler) runImport(c *gin.Context, format string, r io.Reader, opts importer.Options) {
+	db, ok := h.requireDatabase(c)
+	if !ok {
+		return
+	}
+	imp := importer.NewImporter(db, h.logger)
+	var report *models.ImportReport
+	var err error
+	if format == "json" {
+		report, err = imp.ImportJSON(r, opts)
+	} else {
+		report, err = imp.ImportCSV(r, opts)
+	}
+	if err != nil {
+		h.logger.WithError(err).WithField("format", format).Error("Failed to import properties")
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,106 @@
+package api
+
+import (
+	"fundamental/server/internal/live"
+	"io"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/net/websocket"
+)
+
+// sseKeepAlive is the time between comments sent on an idle event stream, so
+// proxies do not close it
+const sseKeepAlive = 30 * time.Second
+
+// LiveHandler serves the live feed of new properties and the stream of job
+// progress
+type LiveHandler struct {
+	hub *live.Hub
+}
+
+// NewLiveHandler creates a handler for the subscribers of a hub
+func NewLiveHandler(hub *live.Hub) *LiveHandler {
+	return &LiveHandler{hub: hub}
+}
+
+// SetupLiveRoutes registers the WebSocket of the live feed and the event
+// stream of job progress
+func SetupLiveRoutes(router *gin.Engine, hub *live.Hub) {
+	handler := NewLiveHandler(hub)
+
+	router.GET("/api/ws", handler.ServeLive)
+	router.GET("/api/events", handler.StreamEvents)
+}
+
+// ServeLive upgrades the request to a WebSocket and sends a JSON message
+// {"type": "new_property", "data": {...}} for every property the spiders
+// insert, optionally only those of ?city=. Messages from the client are
+// ignored. The connection is closed when the client falls behind; clients
+// reconnect and reload the property list to catch up.
+func (h *LiveHandler) ServeLive(c *gin.Context) {
+	city := c.Query("city")
+	server := websocket.Server{Handler: func(conn *websocket.Conn) {
+		sub := h.hub.Subscribe(city, live.EventNewProperty)
+		defer h.hub.Unsubscribe(sub)
+
+		// Reading notices the client going away
+		closed := make(chan struct{})
+		go func() {
+			defer close(closed)
+			io.Copy(io.Discard, conn)
+		}()
+
+		for {
+			select {
+			case <-closed:
+				return
+			case payload, ok := <-sub.Events:
+				if !ok {
+					conn.Close()
+					return
+				}
+				if err := websocket.Message.Send(conn, string(payload)); err != nil {
+					return
+				}
+			}
+		}
+	}}
+	server.ServeHTTP(c.Writer, c.Request)
+}
+
+// StreamEvents sends the progress of spider runs and geocoding passes as
+// Server-Sent Events. Each event is a JSON message {"type": ..., "data": ...}
+// with a type of live.ProgressEvents. A client that falls behind is
+// disconnected; EventSource reconnects by itself.
+func (h *LiveHandler) StreamEvents(c *gin.Context) {
+	sub := h.hub.Subscribe("", live.ProgressEvents...)
+	defer h.hub.Unsubscribe(sub)
+
+	// Answer right away, so the client sees the stream open before the
+	// first event
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	c.Writer.WriteHeaderNow()
+	c.Writer.Flush()
+
+	keepAlive := time.NewTicker(sseKeepAlive)
+	defer keepAlive.Stop()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-c.Request.Context().Done():
+			return false
+		case <-keepAlive.C:
+			_, err := io.WriteString(w, ": keep-alive\n\n")
+			return err == nil
+		case payload, ok := <-sub.Events:
+			if !ok {
+				return false
+			}
+			c.SSEvent("message", string(payload))
+			return true
+		}
+	})
+}
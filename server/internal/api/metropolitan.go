@@ -11,11 +11,11 @@ import (
 )
 
 type MetropolitanHandler struct {
-	db       *database.Database
+	db       database.MetroStore
 	geocoder *geocoding.Geocoder
 }
 
-func NewMetropolitanHandler(db *database.Database, geocoder *geocoding.Geocoder) *MetropolitanHandler {
+func NewMetropolitanHandler(db database.MetroStore, geocoder *geocoding.Geocoder) *MetropolitanHandler {
 	return &MetropolitanHandler{
 		db:       db,
 		geocoder: geocoder,
@@ -23,7 +23,7 @@ func NewMetropolitanHandler(db *database.Database, geocoder *geocoding.Geocoder)
 }
 
 // SetupMetropolitanRoutes adds metropolitan area routes to the router
-func SetupMetropolitanRoutes(router *gin.Engine, db *database.Database, geocoder *geocoding.Geocoder) {
+func SetupMetropolitanRoutes(router *gin.Engine, db database.MetroStore, geocoder *geocoding.Geocoder) {
 	handler := NewMetropolitanHandler(db, geocoder)
 
 	router.GET("/api/metropolitan", handler.ListMetropolitanAreas)
@@ -34,11 +34,20 @@ func SetupMetropolitanRoutes(router *gin.Engine, db *database.Database, geocoder
 	router.POST("/api/metropolitan/:name/geocode", handler.GeocodeMetropolitanArea)
 }
 
+// dbFor returns the store bound to the context of a request when it supports
+// it, as the database does
+func (h *MetropolitanHandler) dbFor(c *gin.Context) database.MetroStore {
+	if db, ok := h.db.(*database.Database); ok {
+		return db.WithContext(c.Request.Context())
+	}
+	return h.db
+}
+
 // ListMetropolitanAreas returns all metropolitan areas
 func (h *MetropolitanHandler) ListMetropolitanAreas(c *gin.Context) {
-	areas, err := h.db.GetMetropolitanAreas()
+	areas, err := h.dbFor(c).GetMetropolitanAreas()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		abortWithError(c, err, "Failed to list metropolitan areas")
 		return
 	}
 	c.JSON(http.StatusOK, areas)
@@ -47,9 +56,9 @@ func (h *MetropolitanHandler) ListMetropolitanAreas(c *gin.Context) {
 // GetMetropolitanArea returns a specific metropolitan area
 func (h *MetropolitanHandler) GetMetropolitanArea(c *gin.Context) {
 	name := c.Param("name")
-	area, err := h.db.GetMetropolitanAreaByName(name)
+	area, err := h.dbFor(c).GetMetropolitanAreaByName(name)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		abortWithError(c, err, "Failed to get metropolitan area")
 		return
 	}
 	if area == nil {
@@ -67,8 +76,8 @@ func (h *MetropolitanHandler) CreateMetropolitanArea(c *gin.Context) {
 		return
 	}
 
-	if err := h.db.UpdateMetropolitanArea(area); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := h.dbFor(c).UpdateMetropolitanArea(area); err != nil {
+		abortWithError(c, err, "Failed to create metropolitan area")
 		return
 	}
 
@@ -93,8 +102,8 @@ func (h *MetropolitanHandler) UpdateMetropolitanArea(c *gin.Context) {
 		return
 	}
 
-	if err := h.db.UpdateMetropolitanArea(area); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := h.dbFor(c).UpdateMetropolitanArea(area); err != nil {
+		abortWithError(c, err, "Failed to update metropolitan area")
 		return
 	}
 
@@ -107,8 +116,8 @@ func (h *MetropolitanHandler) UpdateMetropolitanArea(c *gin.Context) {
 // DeleteMetropolitanArea deletes a metropolitan area
 func (h *MetropolitanHandler) DeleteMetropolitanArea(c *gin.Context) {
 	name := c.Param("name")
-	if err := h.db.DeleteMetropolitanArea(name); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := h.dbFor(c).DeleteMetropolitanArea(name); err != nil {
+		abortWithError(c, err, "Failed to delete metropolitan area")
 		return
 	}
 
@@ -120,9 +129,9 @@ func (h *MetropolitanHandler) GeocodeMetropolitanArea(c *gin.Context) {
 	name := c.Param("name")
 
 	// Get the metropolitan area
-	area, err := h.db.GetMetropolitanAreaByName(name)
+	area, err := h.dbFor(c).GetMetropolitanAreaByName(name)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get metropolitan area"})
+		abortWithError(c, err, "Failed to get metropolitan area")
 		return
 	}
 
@@ -142,7 +151,7 @@ func (h *MetropolitanHandler) GeocodeMetropolitanArea(c *gin.Context) {
 		}
 
 		// Update the coordinates in the database
-		err = h.db.UpdateCityCoordinates(area.ID, city, result.Lat, result.Lng)
+		err = h.dbFor(c).UpdateCityCoordinates(area.ID, city, result.Lat, result.Lng)
 		if err != nil {
 			log.Printf("Failed to update coordinates for city %s: %v", city, err)
 			continue
@@ -150,9 +159,9 @@ func (h *MetropolitanHandler) GeocodeMetropolitanArea(c *gin.Context) {
 	}
 
 	// Get the updated metropolitan area
-	updatedArea, err := h.db.GetMetropolitanAreaByName(name)
+	updatedArea, err := h.dbFor(c).GetMetropolitanAreaByName(name)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get updated metropolitan area"})
+		abortWithError(c, err, "Failed to get updated metropolitan area")
 		return
 	}
 
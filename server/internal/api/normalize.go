@@ -0,0 +1,43 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// defaultNormalizeChanges is the number of changes listed in a normalization report
+const defaultNormalizeChanges = 500
+
+// NormalizeProperties re-applies the normalization rules to the stored
+// properties. It is a dry run that only reports the changes unless
+// dry_run=false is passed.
+func (h *Handler) NormalizeProperties(c *gin.Context) {
+	dryRun, err := strconv.ParseBool(c.DefaultQuery("dry_run", "true"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "dry_run must be true or false"})
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultNormalizeChanges)))
+	if err != nil || limit < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Limit must be a non-negative number"})
+		return
+	}
+
+	report, err := h.dbFor(c).NormalizeProperties(!dryRun, limit)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to normalize properties")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to normalize properties"})
+		return
+	}
+
+	h.logger.WithFields(logrus.Fields{
+		"dry_run":            report.DryRun,
+		"scanned":            report.Scanned,
+		"changed_properties": report.ChangedProperties,
+		"field_counts":       report.FieldCounts,
+	}).Info("Normalized properties")
+	c.JSON(http.StatusOK, report)
+}
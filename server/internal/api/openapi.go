@@ -0,0 +1,246 @@
+package api
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+//go:generate go run ../../cmd/openapi
+
+// handlerDoc documents a handler for the OpenAPI document. The docs are
+// generated from the handlers' doc comments and code into openapi_docs.go;
+// run go generate ./internal/api after changing a handler.
+type handlerDoc struct {
+	Summary     string
+	Description string
+	Query       []queryParam
+	Body        bool  // reads a JSON request body
+	Responses   []int // statuses the handler answers with
+}
+
+// queryParam is a query parameter read by a handler. Kind is "" for a single
+// value, "array" for a repeated parameter and "map" for a parameter with keys
+// such as min_score[value].
+type queryParam struct {
+	Name string
+	Kind string
+}
+
+// DocsHandler serves the OpenAPI document of the routes of a router and the
+// Swagger UI browsing it
+type DocsHandler struct {
+	router *gin.Engine
+
+	specOnce sync.Once
+	spec     gin.H
+}
+
+// NewDocsHandler creates a handler documenting the routes of router
+func NewDocsHandler(router *gin.Engine) *DocsHandler {
+	return &DocsHandler{router: router}
+}
+
+// SetupDocsRoutes registers the OpenAPI document and the Swagger UI. It is
+// called after the other routes are set up; the document is built from the
+// routes registered by the time of its first request.
+func SetupDocsRoutes(router *gin.Engine) {
+	handler := NewDocsHandler(router)
+
+	router.GET("/api/openapi.json", handler.GetOpenAPISpec)
+	router.GET("/api/docs", handler.GetSwaggerUI)
+}
+
+// GetOpenAPISpec returns the OpenAPI 3 document of the API
+func (h *DocsHandler) GetOpenAPISpec(c *gin.Context) {
+	h.specOnce.Do(func() {
+		h.spec = buildOpenAPISpec(h.router.Routes())
+	})
+	c.JSON(http.StatusOK, h.spec)
+}
+
+// GetSwaggerUI serves the Swagger UI page browsing /api/openapi.json
+func (h *DocsHandler) GetSwaggerUI(c *gin.Context) {
+	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(swaggerUIPage))
+}
+
+// isDocsPath reports whether a path serves the API documentation, which is
+// readable without a token
+func isDocsPath(path string) bool {
+	return path == "/api/openapi.json" || path == "/api/docs"
+}
+
+// handlerName returns the name of a route's handler as documented in
+// handlerDocs, such as "(*Handler).GetAllProperties"
+func handlerName(handler string) string {
+	handler = strings.TrimSuffix(handler, "-fm")
+	if i := strings.LastIndex(handler, "/"); i >= 0 {
+		handler = handler[i+1:]
+	}
+	if i := strings.Index(handler, "."); i >= 0 {
+		handler = handler[i+1:]
+	}
+	return handler
+}
+
+// openAPIPath converts a gin path such as /api/properties/:id to the OpenAPI
+// form /api/properties/{id}, returning the path parameters
+func openAPIPath(path string) (string, []string) {
+	segments := strings.Split(path, "/")
+	var params []string
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*") {
+			params = append(params, segment[1:])
+			segments[i] = "{" + segment[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/"), params
+}
+
+// routeTag groups a route by the first segment after /api/, or the second
+// for the admin routes; the docs and the health probes have their own tags
+func routeTag(path string) string {
+	if isDocsPath(path) {
+		return "docs"
+	}
+	if isHealthPath(path) {
+		return "health"
+	}
+	segments := strings.Split(strings.TrimPrefix(path, "/api/"), "/")
+	if segments[0] == "admin" && len(segments) > 1 && !strings.HasPrefix(segments[1], ":") {
+		return "admin/" + segments[1]
+	}
+	return segments[0]
+}
+
+// openAPIDescription introduces the API in the OpenAPI document
+const openAPIDescription = "Property data, market statistics and administration of the FundaMental server. " +
+	"The unversioned /api/... paths serve version 1 unless the API-Version header asks for another."
+
+// buildOpenAPISpec documents routes with the generated handler docs. Routes
+// of undocumented handlers are listed with a summary made of their path.
+func buildOpenAPISpec(routes gin.RoutesInfo) gin.H {
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Path != routes[j].Path {
+			return routes[i].Path < routes[j].Path
+		}
+		return routes[i].Method < routes[j].Method
+	})
+
+	paths := gin.H{}
+	operationIDs := map[string]int{}
+	for _, route := range routes {
+		// Documented under the latest version, see Versioned
+		path, pathParams := openAPIPath(route.Path)
+		if rest, ok := strings.CutPrefix(path, "/api/"); ok {
+			path = "/api/v" + strconv.Itoa(LatestAPIVersion) + "/" + rest
+		}
+		name := handlerName(route.Handler)
+		doc, ok := handlerDocs[name]
+		if !ok {
+			doc.Summary = route.Method + " " + path
+		}
+
+		// Handlers serving several routes get an operation id per route
+		operationID := name[strings.LastIndex(name, ".")+1:]
+		operationIDs[operationID]++
+		if n := operationIDs[operationID]; n > 1 {
+			operationID += strconv.Itoa(n)
+		}
+
+		parameters := []gin.H{}
+		for _, param := range pathParams {
+			parameters = append(parameters, gin.H{
+				"name": param, "in": "path", "required": true,
+				"schema": gin.H{"type": "string"},
+			})
+		}
+		for _, param := range doc.Query {
+			parameter := gin.H{"name": param.Name, "in": "query", "schema": gin.H{"type": "string"}}
+			switch param.Kind {
+			case "array":
+				parameter["schema"] = gin.H{"type": "array", "items": gin.H{"type": "string"}}
+				parameter["explode"] = true
+			case "map":
+				parameter["schema"] = gin.H{"type": "object", "additionalProperties": gin.H{"type": "string"}}
+				parameter["style"] = "deepObject"
+				parameter["explode"] = true
+			}
+			parameters = append(parameters, parameter)
+		}
+
+		responses := gin.H{}
+		for _, status := range doc.Responses {
+			responses[strconv.Itoa(status)] = gin.H{"description": http.StatusText(status)}
+		}
+		if len(responses) == 0 {
+			responses["200"] = gin.H{"description": http.StatusText(http.StatusOK)}
+		}
+
+		operation := gin.H{
+			"operationId": operationID,
+			"summary":     doc.Summary,
+			"tags":        []string{routeTag(route.Path)},
+			"parameters":  parameters,
+			"responses":   responses,
+		}
+		if doc.Description != "" {
+			operation["description"] = doc.Description
+		}
+		if doc.Body && route.Method != http.MethodGet {
+			operation["requestBody"] = gin.H{
+				"content": gin.H{"application/json": gin.H{"schema": gin.H{"type": "object"}}},
+			}
+		}
+
+		item, ok := paths[path].(gin.H)
+		if !ok {
+			item = gin.H{}
+			paths[path] = item
+		}
+		item[strings.ToLower(route.Method)] = operation
+	}
+
+	return gin.H{
+		"openapi": "3.0.3",
+		"info": gin.H{
+			"title":       "FundaMental API",
+			"description": openAPIDescription,
+			"version":     strconv.Itoa(LatestAPIVersion),
+		},
+		"paths": paths,
+		"components": gin.H{
+			"securitySchemes": gin.H{
+				"bearerAuth": gin.H{"type": "http", "scheme": "bearer"},
+			},
+		},
+		// A token is optional unless AUTH_REQUIRED is set
+		"security": []gin.H{{}, {"bearerAuth": []string{}}},
+	}
+}
+
+// swaggerUIPage loads Swagger UI from its CDN and points it at the document
+const swaggerUIPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>FundaMental API</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js" crossorigin></script>
+  <script>
+    window.ui = SwaggerUIBundle({
+      url: "/api/openapi.json",
+      dom_id: "#swagger-ui",
+      persistAuthorization: true,
+    });
+  </script>
+</body>
+</html>
+`
@@ -0,0 +1,708 @@
+// Code generated by go run ../../cmd/openapi; DO NOT EDIT.
+
+package api
+
+// handlerDocs documents the handlers by name, see openapi.go
+var handlerDocs = map[string]handlerDoc{
+	"(*DocsHandler).GetOpenAPISpec": {
+		Summary:   "Returns the OpenAPI 3 document of the API",
+		Responses: []int{200},
+	},
+	"(*DocsHandler).GetSwaggerUI": {
+		Summary:   "Serves the Swagger UI page browsing /api/openapi.json",
+		Responses: []int{200},
+	},
+	"(*Handler).AddWatchlistProperty": {
+		Summary:   "Puts a property on a watchlist and returns the watchlist",
+		Responses: []int{200, 400, 404, 500},
+	},
+	"(*Handler).ApplyRetention": {
+		Summary:   "Deletes the rows older than the configured retention rules now, rather than waiting for the nightly job",
+		Responses: []int{200, 400, 500},
+	},
+	"(*Handler).ApproveRejectedItem": {
+		Summary:   "Stores a reviewed rejected item as a property, with the optional {\"fields\": {...}} of the body corrected, and removes it from the quarantine",
+		Body:      true,
+		Responses: []int{200, 400, 500},
+	},
+	"(*Handler).ArchiveProperties": {
+		Summary:   "Moves inactive properties that have not been updated for days days (default ARCHIVE_AFTER_DAYS) to the archive now",
+		Query:     []queryParam{{"days", ""}},
+		Responses: []int{200, 400, 500},
+	},
+	"(*Handler).BacktestSavedSearch": {
+		Summary:   "Reports how many listings matching a saved search appeared per week over the past weeks (default 52), their prices and how fast they sold",
+		Query:     []queryParam{{"weeks", ""}},
+		Responses: []int{200, 400, 404, 500},
+	},
+	"(*Handler).BacktestSearchCriteria": {
+		Summary:   "Backtests criteria from the request body without saving them, to try filters out",
+		Query:     []queryParam{{"weeks", ""}},
+		Body:      true,
+		Responses: []int{200, 400, 500},
+	},
+	"(*Handler).CancelTask": {
+		Summary:   "Cancels a queued task or asks a running one to stop",
+		Responses: []int{202, 400, 403, 404, 409, 500},
+	},
+	"(*Handler).CheckInitialSetup": {
+		Summary:   "Checks if the database needs initial configuration",
+		Responses: []int{200, 500},
+	},
+	"(*Handler).CompareBuyRent": {
+		Summary:     "Compares the long-run cost of buying and renting a home under the given assumptions",
+		Description: "Without a purchase price the price is estimated from the district's sales of the past year.",
+		Body:        true,
+		Responses:   []int{200, 400, 422, 500},
+	},
+	"(*Handler).CreateAPIToken": {
+		Summary:     "Creates a read-only token; the secret is only returned in this response",
+		Description: "A token created with a workspace only reads that workspace.",
+		Body:        true,
+		Responses:   []int{201, 400, 500},
+	},
+	"(*Handler).CreateBackup": {
+		Summary:   "Takes a backup of the database now",
+		Responses: []int{201, 500, 501},
+	},
+	"(*Handler).CreateSavedSearch": {
+		Summary:   "Stores a named set of search criteria",
+		Body:      true,
+		Responses: []int{201, 400, 500},
+	},
+	"(*Handler).CreateSharedView": {
+		Summary:   "Stores a filter/view definition and returns a short token for sharing",
+		Body:      true,
+		Responses: []int{201, 400, 413, 500},
+	},
+	"(*Handler).CreateTag": {
+		Summary:   "Creates a tag",
+		Body:      true,
+		Responses: []int{201, 400, 500},
+	},
+	"(*Handler).CreateValidationRule": {
+		Summary:   "Adds a validation rule; it applies from the next batch of scraped or imported items",
+		Body:      true,
+		Responses: []int{201, 400, 500},
+	},
+	"(*Handler).CreateWatchlist": {
+		Summary:   "Creates an empty watchlist",
+		Body:      true,
+		Responses: []int{201, 400, 500},
+	},
+	"(*Handler).DeletePreference": {
+		Summary:   "Resets a preference to its default",
+		Responses: []int{204, 400, 500},
+	},
+	"(*Handler).DeleteRejectedItem": {
+		Summary:   "Discards a reviewed rejected item",
+		Responses: []int{204, 400, 404, 500},
+	},
+	"(*Handler).DeleteSavedSearch": {
+		Summary:   "Removes a saved search",
+		Responses: []int{204, 400, 404, 500},
+	},
+	"(*Handler).DeleteTag": {
+		Summary:   "Removes a tag from all properties and deletes it",
+		Responses: []int{204, 400, 404, 500},
+	},
+	"(*Handler).DeleteValidationRule": {
+		Summary:     "Removes a validation rule",
+		Description: "Items it quarantined stay in the rejected items.",
+		Responses:   []int{204, 400, 500},
+	},
+	"(*Handler).DeleteWatchlist": {
+		Summary:   "Removes a watchlist with its alerts",
+		Responses: []int{204, 400, 404, 500},
+	},
+	"(*Handler).DownloadTaskFile": {
+		Summary:   "Sends the file a finished task exported, such as the archive of a district export",
+		Responses: []int{200, 400, 404, 409, 410, 500},
+	},
+	"(*Handler).ExportParquetDataset": {
+		Summary:     "Streams the properties and their price and status history as a zip archive of Parquet files partitioned by city and year, which pandas, Polars and DuckDB read as a dataset once unpacked",
+		Description: "Properties are partitioned by the year of their listing date, or of the scrape without one, and history entries by the year of the change. The city parameter limits the export to one city. An error after the first row leaves the archive truncated. More properties than the export quota are refused with 403.",
+		Query:       []queryParam{{"city", ""}},
+		Responses:   []int{200, 403, 500},
+	},
+	"(*Handler).ExportProperties": {
+		Summary:     "Streams the properties matching the date range, city, status and tag filters as CSV or, with format=parquet, as a Parquet file",
+		Description: "Rows are written as they are read from the database, so the export is not limited by memory. An error after the first row leaves the file truncated. An export larger than the export quota is refused with 403.",
+		Query:       []queryParam{{"city", ""}, {"endDate", ""}, {"format", ""}, {"locale", ""}, {"startDate", ""}, {"status", ""}, {"tag", "array"}},
+		Responses:   []int{200, 400, 403, 500},
+	},
+	"(*Handler).ExportView": {
+		Summary:     "Streams the rows of a database view as CSV or, with format=parquet, as a Parquet file",
+		Description: "An error after the first row leaves the file truncated. A view with more rows than the export quota is refused with 403.",
+		Query:       []queryParam{{"format", ""}, {"locale", ""}},
+		Responses:   []int{200, 400, 403, 404, 500},
+	},
+	"(*Handler).GetAgent": {
+		Summary:   "Returns an agent with the stats of its listings",
+		Responses: []int{200, 400, 404, 500},
+	},
+	"(*Handler).GetAllProperties": {
+		Summary:   "Get all properties",
+		Query:     []queryParam{{"city", ""}, {"endDate", ""}, {"energy_label", "array"}, {"limit", ""}, {"max_living_area", ""}, {"max_price", ""}, {"max_rooms", ""}, {"max_score", "map"}, {"min_living_area", ""}, {"min_price", ""}, {"min_rooms", ""}, {"min_score", "map"}, {"offset", ""}, {"order", ""}, {"property_type", "array"}, {"sort_by", ""}, {"startDate", ""}, {"status", "array"}, {"tag", "array"}},
+		Responses: []int{200, 400, 500},
+	},
+	"(*Handler).GetAnalyticsStatus": {
+		Summary:     "Returns the configured analytics backend and the outcome of the last export to it",
+		Description: "Submit an analytics_export task to export now.",
+		Responses:   []int{200},
+	},
+	"(*Handler).GetAreaStats": {
+		Summary:   "Returns the stats of the properties whose postal code starts with the given prefix, or of one street block for a full (PC6) postal code such as 1012AB",
+		Query:     []queryParam{{"asOf", ""}, {"city", ""}, {"endDate", ""}, {"startDate", ""}},
+		Responses: []int{200, 400, 500},
+	},
+	"(*Handler).GetAuditLog": {
+		Summary:     "Returns the field changes made by spider runs and imports, newest first",
+		Description: "Pass the id of the last entry as before_id for the next page.",
+		Query:       []queryParam{{"before_id", ""}, {"field", ""}, {"limit", ""}, {"property_id", ""}, {"run_id", ""}, {"since", ""}, {"source", ""}},
+		Responses:   []int{200, 400, 500},
+	},
+	"(*Handler).GetCDCSink": {
+		Summary:   "Returns the change data capture sink with its lag, with the password of its URL masked",
+		Responses: []int{200, 500},
+	},
+	"(*Handler).GetDataQuality": {
+		Summary:   "Counts the properties with implausible or missing data and the items in quarantine, by source and failed check",
+		Responses: []int{200, 500},
+	},
+	"(*Handler).GetDistrictHulls": {
+		Summary:     "Returns a version of the district boundaries as GeoJSON",
+		Description: "Without parameters it is the current version; asOf (YYYY-MM-DD) picks the version in force at the end of that day, so historical maps show the boundaries of their time, and version picks one by id. The metadata member describes the version and its validity period. The ETag changes only when a newer version ends the validity of the one served.",
+		Query:       []queryParam{{"asOf", ""}, {"version", ""}},
+		Responses:   []int{200, 304, 400, 404, 500},
+	},
+	"(*Handler).GetDistrictReport": {
+		Summary:   "Returns the scorecard of a 4-digit postal district: price level and trend, inventory, sales velocity, energy label mix, republish and withdrawal rates and market phase",
+		Responses: []int{200, 400, 404, 500},
+	},
+	"(*Handler).GetDistrictSubscription": {
+		Summary:   "Returns the subscription to a district",
+		Responses: []int{200, 400, 404, 500},
+	},
+	"(*Handler).GetEnergyLabelStats": {
+		Summary:   "Returns the energy label mix of the homes sold over the past months per city, or per district with group_by=district, and the price per m² premium of each label over the baseline label",
+		Query:     []queryParam{{"baseline", ""}, {"city", ""}, {"group_by", ""}, {"months", ""}},
+		Responses: []int{200, 400, 500},
+	},
+	"(*Handler).GetErrorReporting": {
+		Summary:   "Returns whether errors are being reported to the error sink",
+		Responses: []int{200},
+	},
+	"(*Handler).GetGeocodingStatus": {
+		Summary:   "Returns the number of properties waiting to be geocoded and which server holds, or last held, the geocoding lease",
+		Responses: []int{200, 500},
+	},
+	"(*Handler).GetHullSettings": {
+		Summary:   "Returns the options the district boundaries are generated with",
+		Responses: []int{200, 500},
+	},
+	"(*Handler).GetMarketPhase": {
+		Summary:     "Returns whether each city, or the given city, is a buyer's or seller's market, with the components the phase is derived from",
+		Description: "days sets the window the components are measured over.",
+		Query:       []queryParam{{"city", ""}, {"days", ""}},
+		Responses:   []int{200, 400, 500},
+	},
+	"(*Handler).GetMonthlyTrends": {
+		Summary:   "Returns the median price, price per m², volume and days to sell of sold properties per month, optionally per district",
+		Query:     []queryParam{{"city", ""}, {"endDate", ""}, {"group_by", ""}, {"startDate", ""}},
+		Responses: []int{200, 400, 500},
+	},
+	"(*Handler).GetNeighborhoodStats": {
+		Summary:   "Returns the listings and sales of the past year per neighborhood, for every city or the city parameter",
+		Query:     []queryParam{{"city", ""}},
+		Responses: []int{200, 500},
+	},
+	"(*Handler).GetPDOKCacheStats": {
+		Summary:     "Returns the state and hit rate of the PDOK cache",
+		Description: "It lists the cached districts, the TTL and offline mode, and how many lookups of the district hulls updates since the server started were answered from the cache, fetched from PDOK or left without points.",
+		Responses:   []int{200, 500, 501},
+	},
+	"(*Handler).GetPreferences": {
+		Summary:   "Returns all preferences of the session, with defaults filled in",
+		Responses: []int{200, 400, 500},
+	},
+	"(*Handler).GetPriceDrops": {
+		Summary:     "Returns the listed properties whose asking price dropped the most over the past days (default 30), each with its largest drop",
+		Description: "min_pct leaves out smaller drops and city restricts the properties.",
+		Query:       []queryParam{{"city", ""}, {"days", ""}, {"limit", ""}, {"min_pct", ""}},
+		Responses:   []int{200, 400, 500},
+	},
+	"(*Handler).GetPriceHistogram": {
+		Summary:   "Returns property counts per price bucket for active and sold listings",
+		Query:     []queryParam{{"asOf", ""}, {"bucket", ""}, {"city", ""}, {"endDate", ""}, {"startDate", ""}},
+		Responses: []int{200, 400, 500},
+	},
+	"(*Handler).GetPropertiesBatch": {
+		Summary:     "Returns the properties with the given IDs or URLs",
+		Description: "It lets clients that keep a local watchlist refresh it in one request. At most 500 IDs and URLs are accepted; those matching no property are listed as missing.",
+		Body:        true,
+		Responses:   []int{200, 400, 500},
+	},
+	"(*Handler).GetPropertiesGeoJSON": {
+		Summary:     "Streams geocoded properties as a GeoJSON FeatureCollection",
+		Description: "Features are written as they are read from the database, so the response size is not limited by memory. An error after the first feature leaves the response truncated, which clients see as invalid JSON.",
+		Query:       []queryParam{{"city", ""}, {"endDate", ""}, {"startDate", ""}},
+		Responses:   []int{200},
+	},
+	"(*Handler).GetPropertiesInBounds": {
+		Summary:   "Returns the properties inside the map viewport given by min_lat, min_lng, max_lat and max_lng, filtered like the property list and optionally by status",
+		Query:     []queryParam{{"city", ""}, {"endDate", ""}, {"max_lat", ""}, {"max_lng", ""}, {"min_lat", ""}, {"min_lng", ""}, {"startDate", ""}, {"status", ""}, {"tag", "array"}},
+		Responses: []int{200, 400, 500},
+	},
+	"(*Handler).GetPropertiesNear": {
+		Summary:   "Returns the properties within radius meters (default 1000) of lat and lng, nearest first, filtered like the property list",
+		Query:     []queryParam{{"city", ""}, {"endDate", ""}, {"lat", ""}, {"limit", ""}, {"lng", ""}, {"radius", ""}, {"startDate", ""}, {"status", ""}, {"tag", "array"}},
+		Responses: []int{200, 400, 500},
+	},
+	"(*Handler).GetProperty": {
+		Summary:     "Returns a property with its history and nearby comparables",
+		Description: "The geocode_status tells whether it is located, waits for geocoding, could not be geocoded or lacks an address.",
+		Responses:   []int{200, 400, 404, 500},
+	},
+	"(*Handler).GetPropertyAuditLog": {
+		Summary:   "Returns the field changes of one property, newest first",
+		Query:     []queryParam{{"before_id", ""}, {"field", ""}, {"limit", ""}, {"run_id", ""}, {"since", ""}, {"source", ""}},
+		Responses: []int{200, 400, 500},
+	},
+	"(*Handler).GetPropertyBidAdvice": {
+		Summary:   "Suggests a bid range for a property",
+		Responses: []int{200, 400, 404, 422, 500},
+	},
+	"(*Handler).GetPropertyChanges": {
+		Summary:     "Returns the property changes after a cursor for an offline client",
+		Description: "Cursor 0 downloads a snapshot of the selected cities; clients then pass the returned cursor until has_more is false, and again later to fetch new changes.",
+		Query:       []queryParam{{"cities", ""}, {"cursor", ""}, {"limit", ""}},
+		Responses:   []int{200, 400, 500},
+	},
+	"(*Handler).GetPropertyHistory": {
+		Summary:   "Returns the price and status transitions of a property",
+		Responses: []int{200, 400, 500},
+	},
+	"(*Handler).GetPropertyImages": {
+		Summary:   "Returns the photos of a property in listing order",
+		Responses: []int{200, 400, 404, 500},
+	},
+	"(*Handler).GetPropertyListings": {
+		Summary:   "Returns every listing of the same home, including the ones under earlier URLs, first listing first",
+		Responses: []int{200, 400, 404, 500},
+	},
+	"(*Handler).GetPropertyProvenance": {
+		Summary:   "Returns which source and run last set each field of a property",
+		Responses: []int{200, 400, 404, 500},
+	},
+	"(*Handler).GetPropertySnapshots": {
+		Summary:   "Returns the raw spider payloads stored for a property",
+		Query:     []queryParam{{"limit", ""}},
+		Responses: []int{200, 400, 500},
+	},
+	"(*Handler).GetPropertyStats": {
+		Summary:   "Get property stats",
+		Query:     []queryParam{{"asOf", ""}, {"city", ""}, {"endDate", ""}, {"startDate", ""}},
+		Responses: []int{200, 400, 500},
+	},
+	"(*Handler).GetPropertyThumbnail": {
+		Summary:   "Serves the cached thumbnail of a property's primary photo, downloading it on first use",
+		Responses: []int{200, 400, 404, 502},
+	},
+	"(*Handler).GetPropertyTile": {
+		Summary:     "Returns the geocoded properties in the map tile z/x/y.mvt as a Mapbox Vector Tile, filtered like the property list and optionally by status",
+		Description: "Below zoom 14 the tile has a \"clusters\" layer with a point per grid cell holding the number of properties; from zoom 14 a \"properties\" layer with every property.",
+		Query:       []queryParam{{"city", ""}, {"endDate", ""}, {"startDate", ""}, {"status", ""}, {"tag", "array"}},
+		Responses:   []int{200, 400, 500},
+	},
+	"(*Handler).GetRecentSales": {
+		Summary:   "Get recent sales",
+		Query:     []queryParam{{"city", ""}, {"endDate", ""}, {"limit", ""}, {"startDate", ""}},
+		Responses: []int{200, 500},
+	},
+	"(*Handler).GetRetention": {
+		Summary:   "Reports how many rows the configured retention rules would delete now, without deleting them",
+		Responses: []int{200, 500},
+	},
+	"(*Handler).GetSavedSearch": {
+		Summary:   "Returns a saved search",
+		Responses: []int{200, 400, 404, 500},
+	},
+	"(*Handler).GetScatterSample": {
+		Summary:   "Returns a downsampled set of living area/price points for scatter plots",
+		Query:     []queryParam{{"city", ""}, {"endDate", ""}, {"limit", ""}, {"method", ""}, {"startDate", ""}},
+		Responses: []int{200, 400, 500},
+	},
+	"(*Handler).GetSharedView": {
+		Summary:   "Resolves a share token to its stored view definition",
+		Responses: []int{200, 404, 500},
+	},
+	"(*Handler).GetSyncStatus": {
+		Summary:   "Returns the newest change cursor and the number of properties in the selected cities",
+		Query:     []queryParam{{"cities", ""}},
+		Responses: []int{200, 500},
+	},
+	"(*Handler).GetTask": {
+		Summary:   "Returns a task with its progress and, once finished, its result",
+		Responses: []int{200, 400, 404, 500},
+	},
+	"(*Handler).GetTelegramConfig": {
+		Summary:   "Returns the current Telegram configuration",
+		Responses: []int{200, 500},
+	},
+	"(*Handler).GetTelegramFilters": {
+		Summary:   "Returns the current notification filters",
+		Responses: []int{200, 500},
+	},
+	"(*Handler).GetUsage": {
+		Summary:     "Returns the quotas of the workspace, or of the main dataset, and what was used of them",
+		Description: "It lists the cities the scheduled spiders scrape and skip, and the notifications sent and exports made today and in the last 30 days, with those refused for a quota.",
+		Responses:   []int{200, 500},
+	},
+	"(*Handler).GetWatchlist": {
+		Summary:   "Returns a watchlist with the ids of its properties",
+		Responses: []int{200, 400, 404, 500},
+	},
+	"(*Handler).GetWithdrawalStats": {
+		Summary:   "Returns per district how many listings left the market sold, withdrawn or expired, and the share that was withdrawn",
+		Query:     []queryParam{{"city", ""}, {"endDate", ""}, {"startDate", ""}},
+		Responses: []int{200, 400, 500},
+	},
+	"(*Handler).GrafanaAnnotations": {
+		Summary:     "Returns price changes and sales as graph annotations",
+		Description: "The annotation query is one of the kinds above, optionally followed by \":<city>\".",
+		Query:       []queryParam{{"locale", ""}},
+		Body:        true,
+		Responses:   []int{200, 400, 500},
+	},
+	"(*Handler).GrafanaQuery": {
+		Summary:   "Returns market metrics as time series or tables",
+		Body:      true,
+		Responses: []int{200, 400, 500},
+	},
+	"(*Handler).GrafanaSearch": {
+		Summary:   "Lists the available metrics for the query editor",
+		Body:      true,
+		Responses: []int{200},
+	},
+	"(*Handler).GrafanaTagKeys": {
+		Summary:   "Lists the keys available for ad hoc filters",
+		Responses: []int{200},
+	},
+	"(*Handler).GrafanaTagValues": {
+		Summary:   "Lists the values of an ad hoc filter key",
+		Body:      true,
+		Responses: []int{200, 500},
+	},
+	"(*Handler).GrafanaTestConnection": {
+		Summary:   "Answers the datasource health check",
+		Responses: []int{200},
+	},
+	"(*Handler).ImportCSV": {
+		Summary:     "Imports historical properties from an uploaded CSV file",
+		Description: "The multipart form expects a \"file\" field and an optional \"options\" field holding a JSON encoded importer.Options (column mapping, delimiter, date format).",
+		Responses:   []int{200, 400, 500, 501},
+	},
+	"(*Handler).ImportDistrictBoundaries": {
+		Summary:     "Replaces the district boundaries by a GeoJSON FeatureCollection, such as the official postal code areas, whose features are polygons with a \"district\" property",
+		Description: "The boundaries in force so far are kept as an earlier version. A scheduled district hulls update replaces imported boundaries by generated ones again.",
+		Body:        true,
+		Responses:   []int{200, 201, 400, 500, 501},
+	},
+	"(*Handler).ImportProperties": {
+		Summary:     "Imports historical properties from CSV or a JSON array of property objects, sent as the request body or as a multipart \"file\" field with optional \"options\"",
+		Description: "The format comes from the format parameter, else the file extension or Content-Type. Rows are validated and deduplicated like spider items, and the response reports the outcome of every row.",
+		Query:       []queryParam{{"format", ""}},
+		Body:        true,
+		Responses:   []int{200, 400, 500, 501},
+	},
+	"(*Handler).ListAPITokens": {
+		Summary:   "Returns all tokens with their expiry, last use and revocation",
+		Responses: []int{200, 500},
+	},
+	"(*Handler).ListAgents": {
+		Summary:   "Returns the agents with the stats of their listings, the agents with the most listings first",
+		Responses: []int{200, 500},
+	},
+	"(*Handler).ListBackups": {
+		Summary:   "Returns the backups in the backup directory, newest first",
+		Responses: []int{200, 500, 501},
+	},
+	"(*Handler).ListDistrictBoundaryVersions": {
+		Summary:   "Returns the versions of the district boundaries with their validity periods, newest first",
+		Responses: []int{200, 500},
+	},
+	"(*Handler).ListDistrictSubscriptions": {
+		Summary:   "Returns the subscribed districts with a summary of their listings and sales",
+		Responses: []int{200, 500},
+	},
+	"(*Handler).ListQueryableTables": {
+		Summary:   "Returns the tables read-only SQL queries may read",
+		Responses: []int{200},
+	},
+	"(*Handler).ListRejectedItems": {
+		Summary:   "Returns the scraped and imported items that failed validation, newest first, optionally of one source (spider or import)",
+		Query:     []queryParam{{"limit", ""}, {"offset", ""}, {"source", ""}},
+		Responses: []int{200, 400, 500},
+	},
+	"(*Handler).ListRuleFields": {
+		Summary:   "Returns the fields notification and search rules can test, with their kind",
+		Responses: []int{200},
+	},
+	"(*Handler).ListSavedSearches": {
+		Summary:   "Returns all saved searches",
+		Responses: []int{200, 500},
+	},
+	"(*Handler).ListSpiderRuns": {
+		Summary:   "Returns the newest spider runs with the share of Funda's results each covered, optionally only those of one city (place, as the spiders name it) or spider type",
+		Query:     []queryParam{{"limit", ""}, {"place", ""}, {"spider_type", ""}},
+		Responses: []int{200, 400, 500},
+	},
+	"(*Handler).ListTags": {
+		Summary:   "Returns all tags with their number of properties",
+		Responses: []int{200, 500},
+	},
+	"(*Handler).ListTaskKinds": {
+		Summary:   "Returns the kinds of tasks that can be submitted",
+		Responses: []int{200},
+	},
+	"(*Handler).ListTasks": {
+		Summary:   "Returns the newest tasks, optionally filtered by kind and status",
+		Query:     []queryParam{{"kind", ""}, {"limit", ""}, {"status", ""}},
+		Responses: []int{200, 400, 500},
+	},
+	"(*Handler).ListValidationRules": {
+		Summary:   "Returns the validation rules of scraped and imported items",
+		Responses: []int{200, 500},
+	},
+	"(*Handler).ListViews": {
+		Summary:   "Documents the database views external tools can read",
+		Responses: []int{200},
+	},
+	"(*Handler).ListWatchAlerts": {
+		Summary:   "Returns the comparables sold near the properties of a watchlist, newest first",
+		Query:     []queryParam{{"limit", ""}},
+		Responses: []int{200, 400, 404, 500},
+	},
+	"(*Handler).ListWatchlists": {
+		Summary:   "Returns all watchlists",
+		Responses: []int{200, 500},
+	},
+	"(*Handler).NormalizeProperties": {
+		Summary:     "Re-applies the normalization rules to the stored properties",
+		Description: "It is a dry run that only reports the changes unless dry_run=false is passed.",
+		Query:       []queryParam{{"dry_run", ""}, {"limit", ""}},
+		Responses:   []int{200, 400, 500},
+	},
+	"(*Handler).RemoveWatchlistProperty": {
+		Summary:   "Takes a property off a watchlist",
+		Responses: []int{204, 400, 404, 500},
+	},
+	"(*Handler).ResetFieldProvenance": {
+		Summary:   "Releases a field so that spider data may overwrite it again",
+		Responses: []int{204, 400, 500},
+	},
+	"(*Handler).RestoreBackup": {
+		Summary:   "Validates a backup and schedules it to replace the database on the next start",
+		Responses: []int{202, 400, 501},
+	},
+	"(*Handler).RevokeAPIToken": {
+		Summary:   "Revokes a token; revoked tokens stay listed for reference",
+		Responses: []int{200, 400, 404, 500},
+	},
+	"(*Handler).RunActiveSpider": {
+		Summary:   "Run active spider",
+		Body:      true,
+		Responses: []int{200, 500},
+	},
+	"(*Handler).RunQuery": {
+		Summary:     "Runs a read-only SQL query on the queryable tables and returns its columns and rows",
+		Description: "max_rows lowers the configured row limit; the query is cancelled after the configured timeout.",
+		Body:        true,
+		Responses:   []int{200, 400, 500},
+	},
+	"(*Handler).RunSpider": {
+		Summary:   "Run spider",
+		Body:      true,
+		Responses: []int{200, 400, 500},
+	},
+	"(*Handler).SearchProperties": {
+		Summary:   "Finds properties by street, neighborhood, postal code or city",
+		Query:     []queryParam{{"include_archived", ""}, {"limit", ""}, {"q", ""}},
+		Responses: []int{200, 400, 500},
+	},
+	"(*Handler).SetErrorReporting": {
+		Summary:   "Switches error reporting on or off until the next restart",
+		Body:      true,
+		Responses: []int{200, 400, 409},
+	},
+	"(*Handler).SetPreference": {
+		Summary:   "Stores a preference value; the request body is the raw JSON value",
+		Body:      true,
+		Responses: []int{200, 400, 413, 500},
+	},
+	"(*Handler).SubmitTask": {
+		Summary:   "Queues a task; poll GET /api/tasks/:id for its progress",
+		Body:      true,
+		Responses: []int{202, 400, 403, 500},
+	},
+	"(*Handler).SubscribeDistrict": {
+		Summary:     "Subscribes to all new listings in a district",
+		Description: "Subscribing again is a no-op.",
+		Responses:   []int{200, 201, 400, 500},
+	},
+	"(*Handler).TestTelegramConfig": {
+		Summary:   "Tests the Telegram configuration by sending a sample property notification",
+		Responses: []int{200, 400, 500},
+	},
+	"(*Handler).UnsubscribeDistrict": {
+		Summary:   "Removes the subscription to a district",
+		Responses: []int{204, 400, 404, 500},
+	},
+	"(*Handler).UpdateCDCSink": {
+		Summary:     "Configures the change data capture sink",
+		Description: "Changing the kind, URL or target mirrors all properties again from the start.",
+		Body:        true,
+		Responses:   []int{200, 400, 500},
+	},
+	"(*Handler).UpdateCoordinates": {
+		Summary:   "Queues a geocode task for the properties without coordinates",
+		Responses: []int{202, 500},
+	},
+	"(*Handler).UpdateDistrictHulls": {
+		Summary:   "Update district hulls",
+		Responses: []int{200, 500, 501},
+	},
+	"(*Handler).UpdateHullSettings": {
+		Summary:     "Changes the options the district boundaries are generated with",
+		Description: "Options left out of the body keep their value. They apply from the next district hulls update, which the district_hulls task runs right away.",
+		Body:        true,
+		Responses:   []int{200, 400, 500},
+	},
+	"(*Handler).UpdatePropertyFields": {
+		Summary:     "Applies manual corrections or enrichment values to a property",
+		Description: "Fields last set by a higher-precedence source are left untouched and reported as skipped.",
+		Body:        true,
+		Responses:   []int{200, 400, 500},
+	},
+	"(*Handler).UpdatePropertyTags": {
+		Summary:   "Adds and removes tags, by name, on many properties at once",
+		Body:      true,
+		Responses: []int{200, 400, 500},
+	},
+	"(*Handler).UpdateSavedSearch": {
+		Summary:   "Replaces the name and criteria of a saved search",
+		Body:      true,
+		Responses: []int{200, 400, 404, 500},
+	},
+	"(*Handler).UpdateTag": {
+		Summary:   "Renames or recolors a tag",
+		Body:      true,
+		Responses: []int{200, 400, 404, 500},
+	},
+	"(*Handler).UpdateTelegramConfig": {
+		Summary:   "Updates the Telegram configuration",
+		Body:      true,
+		Responses: []int{200, 400, 500},
+	},
+	"(*Handler).UpdateTelegramFilters": {
+		Summary:   "Updates the notification filters",
+		Body:      true,
+		Responses: []int{200, 400, 500},
+	},
+	"(*Handler).UpdateValidationRule": {
+		Summary:   "Replaces a validation rule",
+		Body:      true,
+		Responses: []int{200, 400, 500},
+	},
+	"(*Handler).UpdateWatchlist": {
+		Summary:   "Replaces the name and radius of a watchlist",
+		Body:      true,
+		Responses: []int{200, 400, 404, 500},
+	},
+	"(*HealthHandler).GetLiveness": {
+		Summary:     "Reports that the server process is up",
+		Description: "It checks nothing else, so an orchestrator restarts the server only when it stopped answering.",
+		Responses:   []int{200},
+	},
+	"(*HealthHandler).GetReadiness": {
+		Summary:     "Reports whether the server can serve requests: the database is reachable, its migrations are applied and python3, which runs the spiders and the hull generation, is found",
+		Description: "It answers 503 with the failed checks otherwise.",
+		Responses:   []int{200, 503},
+	},
+	"(*LiveHandler).ServeLive": {
+		Summary:     "Upgrades the request to a WebSocket and sends a JSON message {\"type\": \"new_property\", \"data\": {...}} for every property the spiders insert, optionally only those of ?city=",
+		Description: "Messages from the client are ignored. The connection is closed when the client falls behind; clients reconnect and reload the property list to catch up.",
+		Query:       []queryParam{{"city", ""}},
+	},
+	"(*LiveHandler).StreamEvents": {
+		Summary:     "Sends the progress of spider runs and geocoding passes as Server-Sent Events",
+		Description: "Each event is a JSON message {\"type\": ..., \"data\": ...} with a type of live.ProgressEvents. A client that falls behind is disconnected; EventSource reconnects by itself.",
+	},
+	"(*MetropolitanHandler).CreateMetropolitanArea": {
+		Summary:   "Creates a new metropolitan area",
+		Body:      true,
+		Responses: []int{201, 400, 500},
+	},
+	"(*MetropolitanHandler).DeleteMetropolitanArea": {
+		Summary:   "Deletes a metropolitan area",
+		Responses: []int{204, 500},
+	},
+	"(*MetropolitanHandler).GeocodeMetropolitanArea": {
+		Summary:   "Handles geocoding of cities in a metropolitan area",
+		Responses: []int{200, 404, 500},
+	},
+	"(*MetropolitanHandler).GetMetropolitanArea": {
+		Summary:   "Returns a specific metropolitan area",
+		Responses: []int{200, 404, 500},
+	},
+	"(*MetropolitanHandler).ListMetropolitanAreas": {
+		Summary:   "Returns all metropolitan areas",
+		Responses: []int{200, 500},
+	},
+	"(*MetropolitanHandler).UpdateMetropolitanArea": {
+		Summary:   "Updates an existing metropolitan area",
+		Body:      true,
+		Responses: []int{200, 400, 500},
+	},
+	"(*SchedulerHandler).ListScheduledJobs": {
+		Summary:   "Returns every scheduled job with its cron expression, the next runs (?runs=, default 5) and the outcome of its last run",
+		Query:     []queryParam{{"runs", ""}},
+		Responses: []int{200, 400},
+	},
+	"(*WorkspaceHandler).CreateWorkspace": {
+		Summary:     "Creates a workspace with an empty database",
+		Description: "The slug, of lowercase letters, digits and dashes, is sent in the X-Workspace header to select it.",
+		Body:        true,
+		Responses:   []int{201, 400, 500},
+	},
+	"(*WorkspaceHandler).DeleteWorkspace": {
+		Summary:     "Stops a workspace and revokes its tokens",
+		Description: "Its database is kept unless ?purge=true, so creating the workspace again restores it.",
+		Query:       []queryParam{{"purge", ""}},
+		Responses:   []int{204, 404, 500},
+	},
+	"(*WorkspaceHandler).GetWorkspace": {
+		Summary:   "Returns a workspace",
+		Responses: []int{200, 404, 500},
+	},
+	"(*WorkspaceHandler).GetWorkspaceUsage": {
+		Summary:   "Returns the quotas of a workspace and what it used of them, as GET /api/usage does for the workspace itself",
+		Responses: []int{200, 404, 500},
+	},
+	"(*WorkspaceHandler).ListWorkspaces": {
+		Summary:   "Returns all workspaces by slug",
+		Responses: []int{200, 500},
+	},
+	"(*WorkspaceHandler).RenameWorkspace": {
+		Summary:   "Changes the name of a workspace; its slug stays the same",
+		Body:      true,
+		Responses: []int{200, 400, 404, 500},
+	},
+	"(*WorkspaceHandler).UpdateWorkspaceQuotas": {
+		Summary:     "Replaces the quotas of a workspace",
+		Description: "A quota left out or null is unlimited. They apply to the next scheduled spider run, notification and export.",
+		Body:        true,
+		Responses:   []int{200, 400, 404, 500},
+	},
+}
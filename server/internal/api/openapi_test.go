@@ -0,0 +1,95 @@
+package api
+
+import (
+	"net/http"
+	"os/exec"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// pathParamRegex matches the parameters of an OpenAPI path
+var pathParamRegex = regexp.MustCompile(`\{([^}]+)\}`)
+
+// newDocumentedRouter registers every route the server does, on the fake
+// store and without the services, which the routes only keep for later
+func newDocumentedRouter(t *testing.T) *gin.Engine {
+	router := newFakeRouter(t)
+	SetupMetropolitanRoutes(router, nil, nil)
+	SetupSchedulerRoutes(router, nil)
+	SetupLiveRoutes(router, nil)
+	SetupHealthRoutes(router, nil)
+	SetupWorkspaceRoutes(router, nil, nil, nil)
+	SetupDocsRoutes(router)
+	return router
+}
+
+func TestOpenAPISpecDocumentsEveryRoute(t *testing.T) {
+	routes := newDocumentedRouter(t).Routes()
+	for _, route := range routes {
+		if _, ok := handlerDocs[handlerName(route.Handler)]; !ok {
+			t.Errorf("%s %s: handler %s is not in handlerDocs, run go generate ./internal/api",
+				route.Method, route.Path, route.Handler)
+		}
+	}
+
+	spec := buildOpenAPISpec(routes)
+	operationIDs := map[string]string{}
+	operations := 0
+	for path, item := range spec["paths"].(gin.H) {
+		for method, op := range item.(gin.H) {
+			operations++
+			operation := op.(gin.H)
+			where := strings.ToUpper(method) + " " + path
+
+			id, _ := operation["operationId"].(string)
+			if id == "" {
+				t.Errorf("%s has no operationId", where)
+			} else if other, ok := operationIDs[id]; ok {
+				t.Errorf("%s and %s share the operationId %s", where, other, id)
+			}
+			operationIDs[id] = where
+
+			declared := map[string]bool{}
+			for _, param := range operation["parameters"].([]gin.H) {
+				if param["in"] == "path" && param["required"] == true {
+					declared[param["name"].(string)] = true
+				}
+			}
+			for _, match := range pathParamRegex.FindAllStringSubmatch(path, -1) {
+				if !declared[match[1]] {
+					t.Errorf("%s does not declare its path parameter %s", where, match[1])
+				}
+			}
+
+			if len(operation["responses"].(gin.H)) == 0 {
+				t.Errorf("%s has no responses", where)
+			}
+		}
+	}
+	if operations != len(routes) {
+		t.Errorf("got %d operations for %d routes", operations, len(routes))
+	}
+}
+
+func TestOpenAPISpecServed(t *testing.T) {
+	w := serve(newDocumentedRouter(t), http.MethodGet, "/api/openapi.json")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"openapi":"3.0.3"`) {
+		t.Errorf("answered %d: %.200s", w.Code, w.Body)
+	}
+}
+
+// TestOpenAPIDocsUpToDate checks that the committed openapi_docs.go is what
+// the generator makes of the handlers now
+func TestOpenAPIDocsUpToDate(t *testing.T) {
+	goTool, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go command not found")
+	}
+	out, err := exec.Command(goTool, "run", "../../cmd/openapi", "-check").CombinedOutput()
+	if err != nil {
+		t.Errorf("openapi_docs.go does not match the handlers: %v\n%s", err, out)
+	}
+}
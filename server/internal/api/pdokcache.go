@@ -0,0 +1,23 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetPDOKCacheStats returns the state and hit rate of the PDOK cache. It
+// lists the cached districts, the TTL and offline mode, and how many lookups
+// of the district hulls updates since the server started were answered from
+// the cache, fetched from PDOK or left without points.
+func (h *Handler) GetPDOKCacheStats(c *gin.Context) {
+	if _, ok := h.requireDatabase(c); !ok {
+		return
+	}
+	stats, err := h.districtManager.PDOKCacheStats()
+	if err != nil {
+		abortWithError(c, err, "Failed to get PDOK cache statistics")
+		return
+	}
+	c.JSON(http.StatusOK, stats)
+}
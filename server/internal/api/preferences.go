@@ -0,0 +1,195 @@
+package api
+
+import (
+	"encoding/json"
+	"fundamental/server/internal/format"
+	"fundamental/server/internal/i18n"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// sessionHeader identifies the user or browser session owning preferences
+	sessionHeader = "X-Session-ID"
+
+	maxPreferenceValueBytes = 4096
+	maxPreferencesPerOwner  = 100
+)
+
+// preferenceNamespaces lists the namespaces clients may store preferences in
+var preferenceNamespaces = map[string]bool{
+	"dashboard": true,
+	"map":       true,
+	"format":    true,
+	"charts":    true,
+}
+
+var (
+	preferenceKeyRegex = regexp.MustCompile(`^[a-z0-9_.-]{1,64}$`)
+	sessionIDRegex     = regexp.MustCompile(`^[A-Za-z0-9_-]{8,128}$`)
+)
+
+// preferenceOwner resolves the owner of the request's preferences
+func preferenceOwner(c *gin.Context) (string, bool) {
+	owner := c.GetHeader(sessionHeader)
+	if !sessionIDRegex.MatchString(owner) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing or invalid " + sessionHeader + " header"})
+		return "", false
+	}
+	return owner, true
+}
+
+// requestLocale returns the locale a request asks for: its locale query
+// parameter or, without one, the best supported language of its
+// Accept-Language header; "" when it asks for none. An unsupported locale
+// parameter is answered with 400 Bad Request.
+func requestLocale(c *gin.Context) (string, bool) {
+	if tag := c.Query("locale"); tag != "" {
+		locale, ok := format.MatchLocale(tag)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported locale, use one of " + strings.Join(format.Locales(), ", ")})
+			return "", false
+		}
+		return locale, true
+	}
+	return format.Negotiate(c.GetHeader("Accept-Language")), true
+}
+
+// localization returns the number format and translations of the text a
+// request generates. The number format is that of the session's
+// format.currency preference, or the default format for requests without a
+// session, with the locale the request asks for; labels are in the language
+// of that locale, English when it asks for none.
+func (h *Handler) localization(c *gin.Context) (*format.Formatter, *i18n.Translator, bool) {
+	locale, ok := requestLocale(c)
+	if !ok {
+		return nil, nil, false
+	}
+	owner := c.GetHeader(sessionHeader)
+	if !sessionIDRegex.MatchString(owner) {
+		owner = ""
+	}
+	settings, err := h.dbFor(c).GetFormatSettings(owner)
+	if err != nil {
+		h.logger.WithError(err).Warn("Failed to load number format preference")
+	}
+	if locale != "" {
+		settings.Locale = locale
+	}
+	return format.New(settings), i18n.New(locale), true
+}
+
+// validPreferencePath validates the namespace and key URL parameters
+func validPreferencePath(c *gin.Context) bool {
+	if !preferenceNamespaces[c.Param("namespace")] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown preference namespace"})
+		return false
+	}
+	if key := c.Param("key"); key != "" && !preferenceKeyRegex.MatchString(key) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid preference key"})
+		return false
+	}
+	return true
+}
+
+// GetPreferences returns all preferences of the session, with defaults filled in
+func (h *Handler) GetPreferences(c *gin.Context) {
+	owner, ok := preferenceOwner(c)
+	if !ok {
+		return
+	}
+	if c.Param("namespace") != "" && !validPreferencePath(c) {
+		return
+	}
+
+	preferences, err := h.dbFor(c).GetPreferences(owner, c.Param("namespace"))
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get preferences")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get preferences"})
+		return
+	}
+
+	c.JSON(http.StatusOK, preferences)
+}
+
+// SetPreference stores a preference value; the request body is the raw JSON value
+func (h *Handler) SetPreference(c *gin.Context) {
+	owner, ok := preferenceOwner(c)
+	if !ok || !validPreferencePath(c) {
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxPreferenceValueBytes+1))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+		return
+	}
+	if len(body) > maxPreferenceValueBytes {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Preference value is too large"})
+		return
+	}
+	if !json.Valid(body) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Preference value must be valid JSON"})
+		return
+	}
+	if c.Param("namespace") == "format" && c.Param("key") == "currency" {
+		// The server formats notifications and exports with this preference
+		var settings format.Settings
+		if err := json.Unmarshal(body, &settings); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "format.currency must be an object with locale and currency"})
+			return
+		}
+		if err := settings.Validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	// The limit applies to new preferences; stored ones can always be changed
+	exists, err := h.dbFor(c).HasPreference(owner, c.Param("namespace"), c.Param("key"))
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to check preference")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save preference"})
+		return
+	}
+	if !exists {
+		count, err := h.dbFor(c).CountPreferences(owner)
+		if err != nil {
+			h.logger.WithError(err).Error("Failed to count preferences")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save preference"})
+			return
+		}
+		if count >= maxPreferencesPerOwner {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Too many preferences stored for this session"})
+			return
+		}
+	}
+
+	if err := h.dbFor(c).SetPreference(owner, c.Param("namespace"), c.Param("key"), body); err != nil {
+		h.logger.WithError(err).Error("Failed to save preference")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save preference"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Preference saved"})
+}
+
+// DeletePreference resets a preference to its default
+func (h *Handler) DeletePreference(c *gin.Context) {
+	owner, ok := preferenceOwner(c)
+	if !ok || !validPreferencePath(c) {
+		return
+	}
+
+	if err := h.dbFor(c).DeletePreference(owner, c.Param("namespace"), c.Param("key")); err != nil {
+		h.logger.WithError(err).Error("Failed to delete preference")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete preference"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,45 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultPriceDropDays  = 30
+	maxPriceDropDays      = 365
+	defaultPriceDropLimit = 50
+	maxPriceDropLimit     = 500
+)
+
+// GetPriceDrops returns the listed properties whose asking price dropped the
+// most over the past days (default 30), each with its largest drop. min_pct
+// leaves out smaller drops and city restricts the properties.
+func (h *Handler) GetPriceDrops(c *gin.Context) {
+	days, err := strconv.Atoi(c.DefaultQuery("days", strconv.Itoa(defaultPriceDropDays)))
+	if err != nil || days <= 0 || days > maxPriceDropDays {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "days must be between 1 and " + strconv.Itoa(maxPriceDropDays)})
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultPriceDropLimit)))
+	if err != nil || limit <= 0 || limit > maxPriceDropLimit {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and " + strconv.Itoa(maxPriceDropLimit)})
+		return
+	}
+	minPct, err := strconv.ParseFloat(c.DefaultQuery("min_pct", "0"), 64)
+	if err != nil || minPct < 0 || minPct >= 100 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "min_pct must be at least 0 and below 100"})
+		return
+	}
+
+	drops, err := h.dbFor(c).GetPriceDrops(days, minPct, c.Query("city"), limit)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get price drops")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get price drops"})
+		return
+	}
+
+	c.JSON(http.StatusOK, drops)
+}
@@ -0,0 +1,38 @@
+package api
+
+import (
+	"fundamental/server/internal/models"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetPropertiesBatch returns the properties with the given IDs or URLs. It
+// lets clients that keep a local watchlist refresh it in one request. At most
+// 500 IDs and URLs are accepted; those matching no property are listed as
+// missing.
+func (h *Handler) GetPropertiesBatch(c *gin.Context) {
+	var req models.PropertyBatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	urls := make([]string, 0, len(req.URLs))
+	for _, u := range req.URLs {
+		if u = strings.TrimSpace(u); u != "" {
+			urls = append(urls, u)
+		}
+	}
+	if len(req.IDs) == 0 && len(urls) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Request body must contain ids or urls"})
+		return
+	}
+
+	batch, err := h.dbFor(c).GetPropertiesBatch(req.IDs, urls)
+	if err != nil {
+		abortWithError(c, err, "Failed to get properties")
+		return
+	}
+	c.JSON(http.StatusOK, batch)
+}
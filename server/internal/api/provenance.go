@@ -0,0 +1,75 @@
+package api
+
+import (
+	"fundamental/server/internal/models"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetPropertyProvenance returns which source and run last set each field of a property
+func (h *Handler) GetPropertyProvenance(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid property ID"})
+		return
+	}
+
+	provenance, err := h.dbFor(c).GetPropertyProvenance(id)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get property provenance")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get property provenance"})
+		return
+	}
+	if provenance == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Property not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, provenance)
+}
+
+// UpdatePropertyFields applies manual corrections or enrichment values to a property.
+// Fields last set by a higher-precedence source are left untouched and reported as skipped.
+func (h *Handler) UpdatePropertyFields(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid property ID"})
+		return
+	}
+
+	var req models.FieldUpdateRequest
+	if err := c.ShouldBindJSON(&req); err != nil || len(req.Fields) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	if req.Source == models.SourceSpider || req.Source == models.SourceImport {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Source must be manual or enrichment"})
+		return
+	}
+
+	result, err := h.dbFor(c).UpdatePropertyFields(id, req)
+	if err != nil {
+		abortWithError(c, err, "Failed to update property fields")
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// ResetFieldProvenance releases a field so that spider data may overwrite it again
+func (h *Handler) ResetFieldProvenance(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid property ID"})
+		return
+	}
+
+	if err := h.dbFor(c).ResetFieldProvenance(id, c.Param("field")); err != nil {
+		abortWithError(c, err, "Failed to reset field provenance")
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,44 @@
+package api
+
+import (
+	"fundamental/server/internal/database"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListQueryableTables returns the tables read-only SQL queries may read
+func (h *Handler) ListQueryableTables(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"tables": database.QueryableTables()})
+}
+
+// RunQuery runs a read-only SQL query on the queryable tables and returns its
+// columns and rows. max_rows lowers the configured row limit; the query is
+// cancelled after the configured timeout.
+func (h *Handler) RunQuery(c *gin.Context) {
+	var req struct {
+		Query   string `json:"query" binding:"required"`
+		MaxRows int    `json:"max_rows"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A query is required"})
+		return
+	}
+	if req.MaxRows < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "max_rows must not be negative"})
+		return
+	}
+	maxRows := h.cfg.SQLQueryMaxRows
+	if req.MaxRows > 0 && req.MaxRows < maxRows {
+		maxRows = req.MaxRows
+	}
+
+	timeout := time.Duration(h.cfg.SQLQueryTimeoutSeconds) * time.Second
+	result, err := h.dbFor(c).ReadOnlyQuery(c.Request.Context(), req.Query, maxRows, timeout)
+	if err != nil {
+		abortWithError(c, err, "Failed to run query")
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
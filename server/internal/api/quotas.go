@@ -0,0 +1,64 @@
+package api
+
+import (
+	"fmt"
+	"fundamental/server/internal/database"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// checkExportQuota reports whether an export may be made, answering 403 when
+// the number of rows count returns exceeds the export quota. The count is only
+// taken when there is a quota.
+func (h *Handler) checkExportQuota(c *gin.Context, count func() (int, error)) bool {
+	quotas, err := h.dbFor(c).GetQuotas()
+	if err != nil {
+		abortWithError(c, err, "Failed to get export quota")
+		return false
+	}
+	if quotas.MaxExportRows == nil {
+		return true
+	}
+
+	rows, err := count()
+	if err != nil {
+		abortWithError(c, err, "Failed to count exported rows")
+		return false
+	}
+	if rows > *quotas.MaxExportRows {
+		h.recordUsage(database.UsageExportsRefused, 1)
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": fmt.Sprintf("The export holds %d rows, more than the export quota of %d; narrow the filters", rows, *quotas.MaxExportRows),
+		})
+		return false
+	}
+	return true
+}
+
+// recordExport counts an export and its rows
+func (h *Handler) recordExport(rows int) {
+	h.recordUsage(database.UsageExports, 1)
+	h.recordUsage(database.UsageExportRows, rows)
+}
+
+// recordUsage counts the use of a resource on the primary database, as the
+// exports run on the read replica. Failures are only logged.
+func (h *Handler) recordUsage(resource string, n int) {
+	if err := h.primary.RecordUsage(resource, n); err != nil {
+		h.logger.WithError(err).WithField("resource", resource).Error("Failed to record usage")
+	}
+}
+
+// GetUsage returns the quotas of the workspace, or of the main dataset, and
+// what was used of them. It lists the cities the scheduled spiders scrape and
+// skip, and the notifications sent and exports made today and in the last 30
+// days, with those refused for a quota.
+func (h *Handler) GetUsage(c *gin.Context) {
+	usage, err := h.dbFor(c).GetQuotaUsage()
+	if err != nil {
+		abortWithError(c, err, "Failed to get usage")
+		return
+	}
+	c.JSON(http.StatusOK, usage)
+}
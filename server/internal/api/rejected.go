@@ -0,0 +1,80 @@
+package api
+
+import (
+	"fundamental/server/internal/models"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListRejectedItems returns the scraped and imported items that failed
+// validation, newest first, optionally of one source (spider or import)
+func (h *Handler) ListRejectedItems(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Limit must be a positive number"})
+		return
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Offset must be a non-negative number"})
+		return
+	}
+
+	items, err := h.dbFor(c).ListRejectedItems(c.Query("source"), limit, offset)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to list rejected items")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list rejected items"})
+		return
+	}
+
+	c.JSON(http.StatusOK, items)
+}
+
+// DeleteRejectedItem discards a reviewed rejected item
+func (h *Handler) DeleteRejectedItem(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid rejected item ID"})
+		return
+	}
+
+	deleted, err := h.dbFor(c).DeleteRejectedItem(id)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to delete rejected item")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete rejected item"})
+		return
+	}
+	if !deleted {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Rejected item not found"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// ApproveRejectedItem stores a reviewed rejected item as a property, with the
+// optional {"fields": {...}} of the body corrected, and removes it from the
+// quarantine
+func (h *Handler) ApproveRejectedItem(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid rejected item ID"})
+		return
+	}
+	var req models.ApproveRequest
+	if err := c.ShouldBindJSON(&req); err != nil && err != io.EOF {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	approved, err := h.dbFor(c).ApproveRejectedItem(id, req.Fields)
+	if err != nil {
+		abortWithError(c, err, "Failed to approve rejected item")
+		return
+	}
+
+	c.JSON(http.StatusOK, approved)
+}
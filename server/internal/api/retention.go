@@ -0,0 +1,38 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetRetention reports how many rows the configured retention rules would
+// delete now, without deleting them
+func (h *Handler) GetRetention(c *gin.Context) {
+	report, err := h.dbFor(c).ApplyRetention(h.cfg.Retention, true)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to evaluate retention rules")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to evaluate retention rules"})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// ApplyRetention deletes the rows older than the configured retention rules
+// now, rather than waiting for the nightly job
+func (h *Handler) ApplyRetention(c *gin.Context) {
+	if len(h.cfg.Retention) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No retention rules are configured"})
+		return
+	}
+
+	report, err := h.dbFor(c).ApplyRetention(h.cfg.Retention, false)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to apply retention rules")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to apply retention rules"})
+		return
+	}
+
+	h.logger.WithField("deleted", report.Deleted).Info("Applied retention rules")
+	c.JSON(http.StatusOK, report)
+}
@@ -1,33 +1,196 @@
 package api
 
 import (
+	"fundamental/server/config"
+	"fundamental/server/internal/cdc"
 	"fundamental/server/internal/database"
+	"fundamental/server/internal/tasks"
+	"fundamental/server/internal/warehouse"
 
 	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
 )
 
-func SetupRoutes(router *gin.Engine, db *database.Database) {
-	handler := NewHandler(db, nil)
+// SetupRoutes adds the API routes to the router and returns their handler
+func SetupRoutes(router *gin.Engine, db database.APIStore, cfg *config.Config, taskManager *tasks.Manager, streamer *cdc.Streamer, analytics *warehouse.Warehouse, logger *logrus.Logger) *Handler {
+	handler := NewHandler(db, cfg, taskManager, streamer, analytics, logger)
+	// GET requests read from the read replica when one is configured, except
+	// the ones that write: thumbnail caching and shared view counts
+	reads := handler
+	if concrete, ok := db.(*database.Database); ok {
+		reads = handler.withDatabase(concrete.Reader())
+	}
+	// The property list and statistics answer conditional GETs with 304 until
+	// the tables they read change
+	propertiesUnchanged := reads.notModified("properties", "tags", "property_tags", "property_scores")
+	statsUnchanged := reads.notModified("properties", "property_history")
 
 	api := router.Group("/api")
 	{
-		api.GET("/setup/check", handler.CheckInitialSetup)
+		api.GET("/setup/check", reads.CheckInitialSetup)
 
-		api.GET("/properties", handler.GetAllProperties)
-		api.GET("/properties/stats", handler.GetPropertyStats)
-		api.GET("/properties/recent", handler.GetRecentSales)
-		api.GET("/properties/area/:postal_prefix", handler.GetAreaStats)
+		api.GET("/properties", propertiesUnchanged, reads.GetAllProperties)
+		api.GET("/properties/geojson", reads.GetPropertiesGeoJSON)
+		api.GET("/export", reads.ExportProperties)
+		api.GET("/export/parquet", reads.ExportParquetDataset)
+		api.GET("/export/views/:view", reads.ExportView)
+		api.GET("/views", reads.ListViews)
+		api.GET("/properties/bounds", reads.GetPropertiesInBounds)
+		api.GET("/tiles/:z/:x/:y", reads.GetPropertyTile)
+		api.GET("/properties/nearby", reads.GetPropertiesNear)
+		api.GET("/properties/search", reads.SearchProperties)
+		api.POST("/properties/batch", reads.GetPropertiesBatch)
+		api.GET("/properties/stats", statsUnchanged, reads.GetPropertyStats)
+		api.GET("/properties/recent", reads.GetRecentSales)
+		api.GET("/properties/price-drops", reads.GetPriceDrops)
+		api.GET("/properties/area/:postal_code", reads.GetAreaStats)
+		api.GET("/properties/:id", reads.GetProperty)
+		api.GET("/properties/:id/history", reads.GetPropertyHistory)
+		api.GET("/properties/:id/listings", reads.GetPropertyListings)
+		api.GET("/properties/:id/bid-advice", reads.GetPropertyBidAdvice)
+		api.GET("/properties/:id/snapshots", reads.GetPropertySnapshots)
+		api.GET("/properties/:id/images", handler.GetPropertyImages)
+		api.GET("/properties/:id/thumbnail", handler.GetPropertyThumbnail)
+		api.GET("/properties/:id/provenance", reads.GetPropertyProvenance)
+		api.GET("/properties/:id/audit-log", reads.GetPropertyAuditLog)
+		api.PATCH("/properties/:id/fields", handler.UpdatePropertyFields)
+		api.DELETE("/properties/:id/provenance/:field", handler.ResetFieldProvenance)
+		api.GET("/stats/price-histogram", statsUnchanged, reads.GetPriceHistogram)
+		api.GET("/stats/scatter", statsUnchanged, reads.GetScatterSample)
+		api.GET("/stats/trends", statsUnchanged, reads.GetMonthlyTrends)
+		api.GET("/stats/withdrawals", statsUnchanged, reads.GetWithdrawalStats)
+		api.GET("/stats/neighborhoods", statsUnchanged, reads.GetNeighborhoodStats)
+		api.GET("/stats/energy-labels", statsUnchanged, reads.GetEnergyLabelStats)
+		api.GET("/stats/market-phase", statsUnchanged, reads.GetMarketPhase)
+		api.GET("/stats/data-quality", reads.GetDataQuality)
+		api.GET("/districts/hulls", reads.GetDistrictHulls)
+		api.GET("/districts/hulls/versions", reads.ListDistrictBoundaryVersions)
+		api.GET("/districts/:district/report", reads.GetDistrictReport)
+		api.GET("/audit-log", reads.GetAuditLog)
+		api.GET("/usage", reads.GetUsage)
+		api.GET("/sync/status", reads.GetSyncStatus)
+		api.GET("/sync/changes", reads.GetPropertyChanges)
+		api.GET("/geocode/status", handler.GetGeocodingStatus)
 		api.POST("/geocode/update", handler.UpdateCoordinates)
 		api.POST("/districts/update", handler.UpdateDistrictHulls)
 		api.POST("/spider/run", handler.RunSpider)
 		api.POST("/spiders/active", handler.RunActiveSpider)
 		api.POST("/spiders/sold", handler.RunSpider)
+		api.POST("/import", handler.ImportProperties)
+		api.POST("/import/csv", handler.ImportCSV)
 
 		// Telegram configuration routes
-		api.GET("/telegram/config", handler.GetTelegramConfig)
+		api.GET("/telegram/config", reads.GetTelegramConfig)
 		api.POST("/telegram/config", handler.UpdateTelegramConfig)
 		api.POST("/telegram/config/test", handler.TestTelegramConfig)
-		api.GET("/telegram/filters", handler.GetTelegramFilters)
+		api.GET("/telegram/filters", reads.GetTelegramFilters)
 		api.POST("/telegram/filters", handler.UpdateTelegramFilters)
+		api.GET("/rules/fields", reads.ListRuleFields)
+
+		// UI preference routes, scoped by the X-Session-ID header
+		api.GET("/preferences", reads.GetPreferences)
+		api.GET("/preferences/:namespace", reads.GetPreferences)
+		api.PUT("/preferences/:namespace/:key", handler.SetPreference)
+		api.DELETE("/preferences/:namespace/:key", handler.DeletePreference)
+
+		// Saved search routes
+		api.GET("/searches", reads.ListSavedSearches)
+		api.POST("/searches", handler.CreateSavedSearch)
+		api.POST("/searches/backtest", handler.BacktestSearchCriteria)
+		api.GET("/searches/:id", reads.GetSavedSearch)
+		api.PUT("/searches/:id", handler.UpdateSavedSearch)
+		api.DELETE("/searches/:id", handler.DeleteSavedSearch)
+		api.GET("/searches/:id/backtest", reads.BacktestSavedSearch)
+
+		// District subscription routes
+		api.GET("/subscriptions/districts", reads.ListDistrictSubscriptions)
+		api.GET("/subscriptions/districts/:district", reads.GetDistrictSubscription)
+		api.PUT("/subscriptions/districts/:district", handler.SubscribeDistrict)
+		api.DELETE("/subscriptions/districts/:district", handler.UnsubscribeDistrict)
+
+		// Watchlist routes
+		api.GET("/watchlists", reads.ListWatchlists)
+		api.POST("/watchlists", handler.CreateWatchlist)
+		api.GET("/watchlists/:id", reads.GetWatchlist)
+		api.PUT("/watchlists/:id", handler.UpdateWatchlist)
+		api.DELETE("/watchlists/:id", handler.DeleteWatchlist)
+		api.PUT("/watchlists/:id/properties/:property_id", handler.AddWatchlistProperty)
+		api.DELETE("/watchlists/:id/properties/:property_id", handler.RemoveWatchlistProperty)
+		api.GET("/watchlists/:id/alerts", reads.ListWatchAlerts)
+
+		// Tag routes
+		api.GET("/tags", reads.ListTags)
+		api.POST("/tags", handler.CreateTag)
+		api.PUT("/tags/:id", handler.UpdateTag)
+		api.DELETE("/tags/:id", handler.DeleteTag)
+		api.POST("/properties/tags", handler.UpdatePropertyTags)
+
+		// Background task routes
+		api.GET("/tasks", reads.ListTasks)
+		api.POST("/tasks", handler.SubmitTask)
+		api.GET("/tasks/kinds", reads.ListTaskKinds)
+		api.GET("/tasks/:id", reads.GetTask)
+		api.GET("/tasks/:id/download", reads.DownloadTaskFile)
+		api.POST("/tasks/:id/cancel", handler.CancelTask)
+
+		// Spider run routes
+		api.GET("/spiders/runs", reads.ListSpiderRuns)
+
+		// Agent routes
+		api.GET("/agents", reads.ListAgents)
+		api.GET("/agents/:id", reads.GetAgent)
+
+		// Calculator routes
+		api.POST("/calculators/buy-vs-rent", handler.CompareBuyRent)
+
+		// Share link routes
+		api.POST("/share", handler.CreateSharedView)
+		api.GET("/share/:token", handler.GetSharedView)
+
+		// Grafana JSON datasource routes
+		api.GET("/grafana", reads.GrafanaTestConnection)
+		api.POST("/grafana/search", handler.GrafanaSearch)
+		api.POST("/grafana/query", handler.GrafanaQuery)
+		api.POST("/grafana/annotations", handler.GrafanaAnnotations)
+		api.POST("/grafana/tag-keys", handler.GrafanaTagKeys)
+		api.POST("/grafana/tag-values", handler.GrafanaTagValues)
+
+		// Read-only SQL routes
+		if cfg.SQLQueryEnabled {
+			api.GET("/query/tables", reads.ListQueryableTables)
+			api.POST("/query", reads.RunQuery)
+		}
+	}
+
+	// Admin routes, only reachable with ADMIN_TOKEN
+	admin := router.Group("/api/admin", RequireAdmin(cfg))
+	{
+		admin.GET("/tokens", reads.ListAPITokens)
+		admin.POST("/tokens", handler.CreateAPIToken)
+		admin.DELETE("/tokens/:id", handler.RevokeAPIToken)
+		admin.GET("/error-reporting", reads.GetErrorReporting)
+		admin.PUT("/error-reporting", handler.SetErrorReporting)
+		admin.GET("/cdc", handler.GetCDCSink)
+		admin.PUT("/cdc", handler.UpdateCDCSink)
+		admin.GET("/analytics", handler.GetAnalyticsStatus)
+		admin.POST("/normalize", handler.NormalizeProperties)
+		admin.POST("/archive", handler.ArchiveProperties)
+		admin.POST("/districts/boundaries", handler.ImportDistrictBoundaries)
+		admin.GET("/settings/hulls", reads.GetHullSettings)
+		admin.PUT("/settings/hulls", handler.UpdateHullSettings)
+		admin.GET("/pdok-cache", handler.GetPDOKCacheStats)
+		admin.GET("/retention", reads.GetRetention)
+		admin.POST("/retention", handler.ApplyRetention)
+		admin.GET("/rejected-items", reads.ListRejectedItems)
+		admin.DELETE("/rejected-items/:id", handler.DeleteRejectedItem)
+		admin.POST("/rejected-items/:id/approve", handler.ApproveRejectedItem)
+		admin.GET("/validation-rules", reads.ListValidationRules)
+		admin.POST("/validation-rules", handler.CreateValidationRule)
+		admin.PUT("/validation-rules/:id", handler.UpdateValidationRule)
+		admin.DELETE("/validation-rules/:id", handler.DeleteValidationRule)
+		admin.GET("/backups", reads.ListBackups)
+		admin.POST("/backups", handler.CreateBackup)
+		admin.POST("/backups/:name/restore", handler.RestoreBackup)
 	}
+	return handler
 }
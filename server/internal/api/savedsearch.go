@@ -0,0 +1,217 @@
+package api
+
+import (
+	"fundamental/server/internal/models"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultBacktestWeeks = 52
+	maxBacktestWeeks     = 260
+)
+
+// savedSearchID parses the id URL parameter
+func savedSearchID(c *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid saved search ID"})
+		return 0, false
+	}
+	return id, true
+}
+
+// bindSavedSearch parses and validates a saved search request body
+func bindSavedSearch(c *gin.Context) (models.SavedSearchRequest, bool) {
+	var req models.SavedSearchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return req, false
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Name is required"})
+		return req, false
+	}
+	if err := req.Criteria.RelativeCriteria.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return req, false
+	}
+	if err := req.Criteria.Rules.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return req, false
+	}
+	return req, true
+}
+
+// ListSavedSearches returns all saved searches
+func (h *Handler) ListSavedSearches(c *gin.Context) {
+	searches, err := h.dbFor(c).ListSavedSearches()
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to list saved searches")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list saved searches"})
+		return
+	}
+
+	c.JSON(http.StatusOK, searches)
+}
+
+// CreateSavedSearch stores a named set of search criteria
+func (h *Handler) CreateSavedSearch(c *gin.Context) {
+	req, ok := bindSavedSearch(c)
+	if !ok {
+		return
+	}
+
+	search, err := h.dbFor(c).CreateSavedSearch(req)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to create saved search")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create saved search"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, search)
+}
+
+// GetSavedSearch returns a saved search
+func (h *Handler) GetSavedSearch(c *gin.Context) {
+	id, ok := savedSearchID(c)
+	if !ok {
+		return
+	}
+
+	search, err := h.dbFor(c).GetSavedSearch(id)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get saved search")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get saved search"})
+		return
+	}
+	if search == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Saved search not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, search)
+}
+
+// UpdateSavedSearch replaces the name and criteria of a saved search
+func (h *Handler) UpdateSavedSearch(c *gin.Context) {
+	id, ok := savedSearchID(c)
+	if !ok {
+		return
+	}
+	req, ok := bindSavedSearch(c)
+	if !ok {
+		return
+	}
+
+	search, err := h.dbFor(c).UpdateSavedSearch(id, req)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to update saved search")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update saved search"})
+		return
+	}
+	if search == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Saved search not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, search)
+}
+
+// DeleteSavedSearch removes a saved search
+func (h *Handler) DeleteSavedSearch(c *gin.Context) {
+	id, ok := savedSearchID(c)
+	if !ok {
+		return
+	}
+
+	deleted, err := h.dbFor(c).DeleteSavedSearch(id)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to delete saved search")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete saved search"})
+		return
+	}
+	if !deleted {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Saved search not found"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// backtestWeeks parses the weeks query parameter
+func backtestWeeks(c *gin.Context) (int, bool) {
+	weeks, err := strconv.Atoi(c.DefaultQuery("weeks", strconv.Itoa(defaultBacktestWeeks)))
+	if err != nil || weeks <= 0 || weeks > maxBacktestWeeks {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "weeks must be between 1 and " + strconv.Itoa(maxBacktestWeeks)})
+		return 0, false
+	}
+	return weeks, true
+}
+
+// backtest replays criteria over the past weeks and writes the result
+func (h *Handler) backtest(c *gin.Context, criteria models.SearchCriteria, weeks int) {
+	result, err := h.dbFor(c).BacktestSearch(criteria, weeks)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to backtest search")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to backtest search"})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// BacktestSavedSearch reports how many listings matching a saved search
+// appeared per week over the past weeks (default 52), their prices and how
+// fast they sold
+func (h *Handler) BacktestSavedSearch(c *gin.Context) {
+	id, ok := savedSearchID(c)
+	if !ok {
+		return
+	}
+	weeks, ok := backtestWeeks(c)
+	if !ok {
+		return
+	}
+
+	search, err := h.dbFor(c).GetSavedSearch(id)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get saved search")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get saved search"})
+		return
+	}
+	if search == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Saved search not found"})
+		return
+	}
+
+	h.backtest(c, search.Criteria, weeks)
+}
+
+// BacktestSearchCriteria backtests criteria from the request body without
+// saving them, to try filters out
+func (h *Handler) BacktestSearchCriteria(c *gin.Context) {
+	weeks, ok := backtestWeeks(c)
+	if !ok {
+		return
+	}
+	var criteria models.SearchCriteria
+	if err := c.ShouldBindJSON(&criteria); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	if err := criteria.RelativeCriteria.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := criteria.Rules.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.backtest(c, criteria, weeks)
+}
@@ -0,0 +1,41 @@
+package api
+
+import (
+	"fundamental/server/internal/scheduler"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultScheduleRuns = 5
+	maxScheduleRuns     = 100
+)
+
+type SchedulerHandler struct {
+	scheduler *scheduler.Scheduler
+}
+
+func NewSchedulerHandler(s *scheduler.Scheduler) *SchedulerHandler {
+	return &SchedulerHandler{scheduler: s}
+}
+
+// SetupSchedulerRoutes adds the scheduler introspection routes to the router
+func SetupSchedulerRoutes(router *gin.Engine, s *scheduler.Scheduler) {
+	handler := NewSchedulerHandler(s)
+
+	router.GET("/api/scheduler/jobs", handler.ListScheduledJobs)
+}
+
+// ListScheduledJobs returns every scheduled job with its cron expression, the
+// next runs (?runs=, default 5) and the outcome of its last run
+func (h *SchedulerHandler) ListScheduledJobs(c *gin.Context) {
+	runs, err := strconv.Atoi(c.DefaultQuery("runs", strconv.Itoa(defaultScheduleRuns)))
+	if err != nil || runs <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Runs must be a positive number"})
+		return
+	}
+
+	c.JSON(http.StatusOK, h.scheduler.Jobs(min(runs, maxScheduleRuns)))
+}
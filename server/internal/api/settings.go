@@ -0,0 +1,46 @@
+package api
+
+import (
+	"fundamental/server/internal/geometry"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetHullSettings returns the options the district boundaries are generated
+// with
+func (h *Handler) GetHullSettings(c *gin.Context) {
+	options := geometry.DefaultHullOptions()
+	if _, err := h.dbFor(c).GetSetting(geometry.HullOptionsSetting, &options); err != nil {
+		abortWithError(c, err, "Failed to get hull settings")
+		return
+	}
+	c.JSON(http.StatusOK, options)
+}
+
+// UpdateHullSettings changes the options the district boundaries are
+// generated with. Options left out of the body keep their value. They apply
+// from the next district hulls update, which the district_hulls task runs
+// right away.
+func (h *Handler) UpdateHullSettings(c *gin.Context) {
+	options := geometry.DefaultHullOptions()
+	if _, err := h.dbFor(c).GetSetting(geometry.HullOptionsSetting, &options); err != nil {
+		abortWithError(c, err, "Failed to update hull settings")
+		return
+	}
+	if err := c.ShouldBindJSON(&options); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	if err := geometry.ValidateHullOptions(options); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.dbFor(c).SaveSetting(geometry.HullOptionsSetting, options); err != nil {
+		abortWithError(c, err, "Failed to update hull settings")
+		return
+	}
+	h.logger.WithField("options", options).Info("Changed hull settings")
+	c.JSON(http.StatusOK, options)
+}
@@ -0,0 +1,66 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+const maxSharedViewBytes = 16 * 1024
+
+// CreateSharedView stores a filter/view definition and returns a short token for sharing
+func (h *Handler) CreateSharedView(c *gin.Context) {
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxSharedViewBytes+1))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+		return
+	}
+	if len(body) > maxSharedViewBytes {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "View definition is too large"})
+		return
+	}
+
+	// Require a JSON object and re-encode it, which compacts it and sorts its
+	// keys, so equal definitions share a token whatever their key order.
+	// Numbers are kept as written.
+	var definition map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&definition); err != nil || len(definition) == 0 || decoder.More() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "View definition must be a non-empty JSON object"})
+		return
+	}
+	canonical, err := json.Marshal(definition)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid view definition"})
+		return
+	}
+
+	view, err := h.dbFor(c).CreateSharedView(canonical)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to create shared view")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create share link"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, view)
+}
+
+// GetSharedView resolves a share token to its stored view definition
+func (h *Handler) GetSharedView(c *gin.Context) {
+	view, err := h.dbFor(c).ResolveSharedView(c.Param("token"))
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to resolve shared view")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to resolve share link"})
+		return
+	}
+	if view == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Share link not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, view)
+}
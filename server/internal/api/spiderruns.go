@@ -0,0 +1,28 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListSpiderRuns returns the newest spider runs with the share of Funda's
+// results each covered, optionally only those of one city (place, as the
+// spiders name it) or spider type
+func (h *Handler) ListSpiderRuns(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Limit must be a positive number"})
+		return
+	}
+
+	runs, err := h.db.ListSpiderRuns(c.Query("place"), c.Query("spider_type"), limit)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to list spider runs")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list spider runs"})
+		return
+	}
+
+	c.JSON(http.StatusOK, runs)
+}
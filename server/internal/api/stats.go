@@ -0,0 +1,228 @@
+package api
+
+import (
+	"fundamental/server/internal/analytics"
+	"fundamental/server/internal/database"
+	"fundamental/server/internal/models"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultHistogramBucket = 25000
+	minHistogramBucket     = 1000
+)
+
+// statsAsOf parses the asOf query parameter, a past date (YYYY-MM-DD) to
+// reconstruct stats at; empty for the current state
+func statsAsOf(c *gin.Context) (string, bool) {
+	asOf := c.Query("asOf")
+	if asOf == "" {
+		return "", true
+	}
+	day, err := time.Parse("2006-01-02", asOf)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "asOf must be a date formatted as YYYY-MM-DD"})
+		return "", false
+	}
+	if day.After(time.Now()) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "asOf must not be in the future"})
+		return "", false
+	}
+	return asOf, true
+}
+
+// GetPriceHistogram returns property counts per price bucket for active and sold listings
+func (h *Handler) GetPriceHistogram(c *gin.Context) {
+	var dateRange DateRange
+	if err := c.ShouldBindQuery(&dateRange); err != nil {
+		h.logger.WithError(err).Error("Failed to parse date range")
+	}
+
+	bucketSize, err := strconv.Atoi(c.DefaultQuery("bucket", strconv.Itoa(defaultHistogramBucket)))
+	if err != nil || bucketSize < minHistogramBucket {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Bucket must be a number of at least 1000"})
+		return
+	}
+
+	asOf, ok := statsAsOf(c)
+	if !ok {
+		return
+	}
+
+	city := c.Query("city")
+	buckets, err := h.dbFor(c).GetPriceHistogram(bucketSize, dateRange.StartDate, dateRange.EndDate, city, asOf)
+	if err != nil {
+		abortWithError(c, err, "Failed to get price histogram")
+		return
+	}
+
+	c.JSON(http.StatusOK, models.PriceHistogram{
+		BucketSize: bucketSize,
+		Buckets:    buckets,
+	})
+}
+
+// GetScatterSample returns a downsampled set of living area/price points for scatter plots
+func (h *Handler) GetScatterSample(c *gin.Context) {
+	var dateRange DateRange
+	if err := c.ShouldBindQuery(&dateRange); err != nil {
+		h.logger.WithError(err).Error("Failed to parse date range")
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(h.cfg.ScatterMaxPoints)))
+	if err != nil || limit <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Limit must be a positive number"})
+		return
+	}
+	if limit > h.cfg.ScatterMaxPoints {
+		limit = h.cfg.ScatterMaxPoints
+	}
+
+	method := c.DefaultQuery("method", database.SampleReservoir)
+	if method != database.SampleReservoir && method != database.SampleGrid {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Method must be reservoir or grid"})
+		return
+	}
+
+	city := c.Query("city")
+	sample, err := h.dbFor(c).GetScatterSample(limit, method, dateRange.StartDate, dateRange.EndDate, city)
+	if err != nil {
+		abortWithError(c, err, "Failed to get scatter sample")
+		return
+	}
+
+	c.JSON(http.StatusOK, sample)
+}
+
+// GetMonthlyTrends returns the median price, price per m², volume and days to
+// sell of sold properties per month, optionally per district
+func (h *Handler) GetMonthlyTrends(c *gin.Context) {
+	var dateRange DateRange
+	if err := c.ShouldBindQuery(&dateRange); err != nil {
+		h.logger.WithError(err).Error("Failed to parse date range")
+	}
+
+	groupBy := c.Query("group_by")
+	if groupBy != "" && groupBy != "district" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "group_by must be district"})
+		return
+	}
+
+	city := c.Query("city")
+	if trends, ok := h.warehouse.MonthlyTrends(c.Request.Context(), dateRange.StartDate, dateRange.EndDate, city, groupBy == "district"); ok {
+		c.JSON(http.StatusOK, trends)
+		return
+	}
+	trends, err := h.dbFor(c).GetMonthlyTrends(dateRange.StartDate, dateRange.EndDate, city, groupBy == "district")
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get monthly trends")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get monthly trends"})
+		return
+	}
+
+	c.JSON(http.StatusOK, trends)
+}
+
+// GetNeighborhoodStats returns the listings and sales of the past year per
+// neighborhood, for every city or the city parameter
+func (h *Handler) GetNeighborhoodStats(c *gin.Context) {
+	stats, err := h.dbFor(c).GetNeighborhoodStats(c.Query("city"))
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get neighborhood stats")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get neighborhood stats"})
+		return
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
+
+// GetEnergyLabelStats returns the energy label mix of the homes sold over the
+// past months per city, or per district with group_by=district, and the price
+// per m² premium of each label over the baseline label
+func (h *Handler) GetEnergyLabelStats(c *gin.Context) {
+	groupBy := c.DefaultQuery("group_by", "city")
+	if groupBy != "city" && groupBy != "district" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "group_by must be city or district"})
+		return
+	}
+	months, err := strconv.Atoi(c.DefaultQuery("months", "12"))
+	if err != nil || months < 1 || months > 120 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "months must be between 1 and 120"})
+		return
+	}
+	baseline := strings.TrimSpace(c.DefaultQuery("baseline", "C"))
+	if baseline == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "baseline must be an energy label"})
+		return
+	}
+
+	stats, err := h.dbFor(c).GetEnergyLabelStats(c.Query("city"), groupBy == "district", baseline, months)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get energy label stats")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get energy label stats"})
+		return
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
+
+// GetWithdrawalStats returns per district how many listings left the market
+// sold, withdrawn or expired, and the share that was withdrawn
+func (h *Handler) GetWithdrawalStats(c *gin.Context) {
+	var dateRange DateRange
+	if err := c.ShouldBindQuery(&dateRange); err != nil {
+		h.logger.WithError(err).Error("Failed to parse date range")
+	}
+	for _, date := range []string{dateRange.StartDate, dateRange.EndDate} {
+		if _, err := time.Parse("2006-01-02", date); date != "" && err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Dates must be formatted as YYYY-MM-DD"})
+			return
+		}
+	}
+
+	stats, err := h.dbFor(c).GetDelistingStats(dateRange.StartDate, dateRange.EndDate, c.Query("city"))
+	if err != nil {
+		abortWithError(c, err, "Failed to get withdrawal stats")
+		return
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
+
+// GetMarketPhase returns whether each city, or the given city, is a buyer's or
+// seller's market, with the components the phase is derived from. days sets
+// the window the components are measured over.
+func (h *Handler) GetMarketPhase(c *gin.Context) {
+	days, err := strconv.Atoi(c.DefaultQuery("days", strconv.Itoa(analytics.DefaultWindowDays)))
+	if err != nil || days < 30 || days > 365 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "days must be between 30 and 365"})
+		return
+	}
+
+	cities := []string{c.Query("city")}
+	if cities[0] == "" {
+		if cities, err = h.dbFor(c).GetCities(); err != nil {
+			h.logger.WithError(err).Error("Failed to get cities")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get market phase"})
+			return
+		}
+	}
+
+	phases := []models.MarketPhase{}
+	for _, city := range cities {
+		indicators, err := h.dbFor(c).GetMarketIndicators(city, days)
+		if err != nil {
+			h.logger.WithError(err).WithField("city", city).Error("Failed to get market indicators")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get market phase"})
+			return
+		}
+		phases = append(phases, analytics.MarketPhase(*indicators))
+	}
+
+	c.JSON(http.StatusOK, phases)
+}
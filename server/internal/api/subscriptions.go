@@ -0,0 +1,102 @@
+package api
+
+import (
+	"fundamental/server/internal/country"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// districtParam parses the district URL parameter, a 4-digit postal
+// district
+func districtParam(c *gin.Context) (string, bool) {
+	district := c.Param("district")
+	if !country.ValidDistrict(district) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "District must be the 4 digits of a Dutch postal code, or a country code and postal code digits such as BE-1000"})
+		return "", false
+	}
+	return district, true
+}
+
+// ListDistrictSubscriptions returns the subscribed districts with a summary of
+// their listings and sales
+func (h *Handler) ListDistrictSubscriptions(c *gin.Context) {
+	subscriptions, err := h.dbFor(c).ListDistrictSubscriptions()
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to list district subscriptions")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list district subscriptions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, subscriptions)
+}
+
+// GetDistrictSubscription returns the subscription to a district
+func (h *Handler) GetDistrictSubscription(c *gin.Context) {
+	district, ok := districtParam(c)
+	if !ok {
+		return
+	}
+
+	subscription, err := h.dbFor(c).GetDistrictSubscription(district)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get district subscription")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get district subscription"})
+		return
+	}
+	if subscription == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "District is not subscribed"})
+		return
+	}
+
+	c.JSON(http.StatusOK, subscription)
+}
+
+// SubscribeDistrict subscribes to all new listings in a district. Subscribing
+// again is a no-op.
+func (h *Handler) SubscribeDistrict(c *gin.Context) {
+	district, ok := districtParam(c)
+	if !ok {
+		return
+	}
+
+	created, err := h.dbFor(c).SubscribeDistrict(district)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to subscribe to district")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to subscribe to district"})
+		return
+	}
+	subscription, err := h.dbFor(c).GetDistrictSubscription(district)
+	if err != nil || subscription == nil {
+		h.logger.WithError(err).Error("Failed to get district subscription")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get district subscription"})
+		return
+	}
+
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	c.JSON(status, subscription)
+}
+
+// UnsubscribeDistrict removes the subscription to a district
+func (h *Handler) UnsubscribeDistrict(c *gin.Context) {
+	district, ok := districtParam(c)
+	if !ok {
+		return
+	}
+
+	removed, err := h.dbFor(c).UnsubscribeDistrict(district)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to unsubscribe from district")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unsubscribe from district"})
+		return
+	}
+	if !removed {
+		c.JSON(http.StatusNotFound, gin.H{"error": "District is not subscribed"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,63 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Page sizes of GetPropertyChanges
+const (
+	defaultSyncLimit = 500
+	maxSyncLimit     = 5000
+)
+
+// syncCities reads the comma-separated cities parameter
+func syncCities(c *gin.Context) []string {
+	var cities []string
+	for _, city := range strings.Split(c.Query("cities"), ",") {
+		if city = strings.TrimSpace(city); city != "" {
+			cities = append(cities, city)
+		}
+	}
+	return cities
+}
+
+// GetSyncStatus returns the newest change cursor and the number of properties
+// in the selected cities
+func (h *Handler) GetSyncStatus(c *gin.Context) {
+	status, err := h.dbFor(c).GetSyncStatus(syncCities(c))
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get sync status")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get sync status"})
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+// GetPropertyChanges returns the property changes after a cursor for an offline
+// client. Cursor 0 downloads a snapshot of the selected cities; clients then
+// pass the returned cursor until has_more is false, and again later to fetch
+// new changes.
+func (h *Handler) GetPropertyChanges(c *gin.Context) {
+	cursor, err := strconv.ParseInt(c.DefaultQuery("cursor", "0"), 10, 64)
+	if err != nil || cursor < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cursor must be a non-negative number"})
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultSyncLimit)))
+	if err != nil || limit < 1 || limit > maxSyncLimit {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Limit must be between 1 and " + strconv.Itoa(maxSyncLimit)})
+		return
+	}
+
+	batch, err := h.dbFor(c).GetPropertyChanges(cursor, syncCities(c), limit)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get property changes")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get property changes"})
+		return
+	}
+	c.JSON(http.StatusOK, batch)
+}
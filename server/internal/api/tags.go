@@ -0,0 +1,154 @@
+package api
+
+import (
+	"fmt"
+	"fundamental/server/internal/models"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxTagUpdateProperties bounds the number of properties of a bulk tag update
+const maxTagUpdateProperties = 5000
+
+var tagColorRegex = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// tagID parses the id URL parameter
+func tagID(c *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tag ID"})
+		return 0, false
+	}
+	return id, true
+}
+
+// bindTag parses and validates a tag request body. Names cannot contain
+// commas, which separate the tags of a property in exports.
+func bindTag(c *gin.Context) (models.TagRequest, bool) {
+	var req models.TagRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return req, false
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Name is required"})
+		return req, false
+	}
+	if strings.Contains(req.Name, ",") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Name cannot contain commas"})
+		return req, false
+	}
+	if req.Color != "" && !tagColorRegex.MatchString(req.Color) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Color must be a hex color like #1e88e5"})
+		return req, false
+	}
+	return req, true
+}
+
+// ListTags returns all tags with their number of properties
+func (h *Handler) ListTags(c *gin.Context) {
+	tags, err := h.dbFor(c).ListTags()
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to list tags")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list tags"})
+		return
+	}
+
+	c.JSON(http.StatusOK, tags)
+}
+
+// CreateTag creates a tag
+func (h *Handler) CreateTag(c *gin.Context) {
+	req, ok := bindTag(c)
+	if !ok {
+		return
+	}
+
+	tag, err := h.dbFor(c).CreateTag(req)
+	if err != nil {
+		abortWithError(c, err, "Failed to create tag")
+		return
+	}
+
+	c.JSON(http.StatusCreated, tag)
+}
+
+// UpdateTag renames or recolors a tag
+func (h *Handler) UpdateTag(c *gin.Context) {
+	id, ok := tagID(c)
+	if !ok {
+		return
+	}
+	req, ok := bindTag(c)
+	if !ok {
+		return
+	}
+
+	tag, err := h.dbFor(c).UpdateTag(id, req)
+	if err != nil {
+		abortWithError(c, err, "Failed to update tag")
+		return
+	}
+	if tag == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tag not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, tag)
+}
+
+// DeleteTag removes a tag from all properties and deletes it
+func (h *Handler) DeleteTag(c *gin.Context) {
+	id, ok := tagID(c)
+	if !ok {
+		return
+	}
+
+	deleted, err := h.dbFor(c).DeleteTag(id)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to delete tag")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete tag"})
+		return
+	}
+	if !deleted {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tag not found"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// UpdatePropertyTags adds and removes tags, by name, on many properties at
+// once
+func (h *Handler) UpdatePropertyTags(c *gin.Context) {
+	var req models.PropertyTagsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	if len(req.PropertyIDs) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "property_ids is required"})
+		return
+	}
+	if len(req.PropertyIDs) > maxTagUpdateProperties {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("At most %d properties can be tagged at once", maxTagUpdateProperties)})
+		return
+	}
+	if len(req.Add) == 0 && len(req.Remove) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "add or remove is required"})
+		return
+	}
+
+	result, err := h.dbFor(c).UpdatePropertyTags(req)
+	if err != nil {
+		abortWithError(c, err, "Failed to update property tags")
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
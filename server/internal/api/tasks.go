@@ -0,0 +1,342 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"fundamental/server/internal/database"
+	"fundamental/server/internal/models"
+	"fundamental/server/internal/tasks"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// archiveTaskParams are the parameters of an archive task
+type archiveTaskParams struct {
+	Days int `json:"days"`
+}
+
+// spiderTaskParams are the parameters of a spider task
+type spiderTaskParams struct {
+	Place    string `json:"place"`
+	Type     string `json:"type"` // 'active' or 'sold'
+	MaxPages *int   `json:"max_pages"`
+}
+
+// decodeTaskParams decodes optional task parameters into v
+func decodeTaskParams(params json.RawMessage, v interface{}) error {
+	if len(params) == 0 || string(params) == "null" {
+		return nil
+	}
+	return json.Unmarshal(params, v)
+}
+
+// registerTaskKinds registers the background tasks the handler can run
+func (h *Handler) registerTaskKinds() {
+	h.tasks.Register(tasks.Kind{
+		Name:        "geocode",
+		Description: "Geocode properties without coordinates",
+		Run: func(ctx context.Context, task *tasks.Task) (interface{}, error) {
+			err := h.db.GeocodeMissingCoordinates(ctx, h.geocoder, func(done, total int) {
+				task.Progress(done, total, fmt.Sprintf("Geocoded %d of %d addresses", done, total))
+			})
+			if errors.Is(err, database.ErrGeocodingLeased) {
+				// Another server is geocoding the same properties
+				return gin.H{"skipped": err.Error()}, nil
+			}
+			return nil, err
+		},
+	})
+
+	h.tasks.Register(tasks.Kind{
+		Name:        "district_hulls",
+		Description: "Recompute the district boundaries",
+		Run: func(ctx context.Context, task *tasks.Task) (interface{}, error) {
+			return nil, h.updateDistrictHulls()
+		},
+	})
+
+	h.tasks.Register(tasks.Kind{
+		Name:        "spider",
+		Description: "Run the active or sold spider for a place",
+		Validate: func(params json.RawMessage) error {
+			var p spiderTaskParams
+			if err := decodeTaskParams(params, &p); err != nil {
+				return err
+			}
+			if p.Place == "" {
+				return errors.New("place is required")
+			}
+			if p.Type != "" && p.Type != "active" && p.Type != "sold" {
+				return errors.New("type must be 'active' or 'sold'")
+			}
+			return nil
+		},
+		Run: func(ctx context.Context, task *tasks.Task) (interface{}, error) {
+			var p spiderTaskParams
+			if err := decodeTaskParams(task.Params, &p); err != nil {
+				return nil, err
+			}
+			if p.Type == "sold" {
+				return nil, h.spiderManager.RunSoldSpider(p.Place, p.MaxPages)
+			}
+			return nil, h.spiderManager.RunActiveSpider(p.Place, p.MaxPages)
+		},
+	})
+
+	h.tasks.Register(tasks.Kind{
+		Name:        "backup",
+		Description: "Take a backup of the SQLite database",
+		Admin:       true,
+		Run: func(ctx context.Context, task *tasks.Task) (interface{}, error) {
+			if h.backups == nil {
+				return nil, errors.New("backups are not available without a database")
+			}
+			if h.db.Dialect().Name() != database.DriverSQLite {
+				return nil, errors.New("backups are only supported for SQLite")
+			}
+			return h.backups.Create()
+		},
+	})
+
+	h.tasks.Register(tasks.Kind{
+		Name:        "archive",
+		Description: "Archive inactive properties not updated for the given number of days",
+		Admin:       true,
+		Validate: func(params json.RawMessage) error {
+			var p archiveTaskParams
+			if err := decodeTaskParams(params, &p); err != nil {
+				return err
+			}
+			if p.Days < 0 {
+				return errors.New("days must be a positive number")
+			}
+			return nil
+		},
+		Run: func(ctx context.Context, task *tasks.Task) (interface{}, error) {
+			var p archiveTaskParams
+			if err := decodeTaskParams(task.Params, &p); err != nil {
+				return nil, err
+			}
+			if p.Days == 0 {
+				p.Days = h.cfg.ArchiveAfterDays
+			}
+			if p.Days <= 0 {
+				p.Days = defaultArchiveDays
+			}
+			archived, err := h.db.ArchiveStaleProperties(p.Days)
+			if err != nil {
+				return nil, err
+			}
+			return gin.H{"archived": archived, "days": p.Days}, nil
+		},
+	})
+
+	h.tasks.Register(tasks.Kind{
+		Name:        "district_export",
+		Description: "Export the district boundaries with their statistics as zipped GeoJSON, optionally for one city",
+		Validate: func(params json.RawMessage) error {
+			var p districtExportParams
+			return decodeTaskParams(params, &p)
+		},
+		Run: func(ctx context.Context, task *tasks.Task) (interface{}, error) {
+			var p districtExportParams
+			if err := decodeTaskParams(task.Params, &p); err != nil {
+				return nil, err
+			}
+			return h.exportDistricts(ctx, task, strings.TrimSpace(p.City))
+		},
+	})
+
+	if h.warehouse != nil {
+		h.tasks.Register(tasks.Kind{
+			Name:        "analytics_export",
+			Description: "Export the properties to the analytics backend",
+			Admin:       true,
+			Run: func(ctx context.Context, task *tasks.Task) (interface{}, error) {
+				return h.warehouse.Export(ctx)
+			},
+		})
+	}
+}
+
+// taskID parses the id URL parameter
+func taskID(c *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+		return 0, false
+	}
+	return id, true
+}
+
+// allowTaskKind rejects requests for admin-only task kinds without the admin
+// token
+func (h *Handler) allowTaskKind(c *gin.Context, kind tasks.Kind) bool {
+	if kind.Admin && !c.GetBool(adminContextKey) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admin token required for " + kind.Name + " tasks"})
+		return false
+	}
+	return true
+}
+
+// ListTaskKinds returns the kinds of tasks that can be submitted
+func (h *Handler) ListTaskKinds(c *gin.Context) {
+	c.JSON(http.StatusOK, h.tasks.Kinds())
+}
+
+// ListTasks returns the newest tasks, optionally filtered by kind and status
+func (h *Handler) ListTasks(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Limit must be a positive number"})
+		return
+	}
+
+	list, err := h.db.ListTasks(c.Query("kind"), c.Query("status"), limit)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to list tasks")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list tasks"})
+		return
+	}
+
+	c.JSON(http.StatusOK, list)
+}
+
+// GetTask returns a task with its progress and, once finished, its result
+func (h *Handler) GetTask(c *gin.Context) {
+	id, ok := taskID(c)
+	if !ok {
+		return
+	}
+
+	task, err := h.db.GetTask(id)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get task")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get task"})
+		return
+	}
+	if task == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, task)
+}
+
+// DownloadTaskFile sends the file a finished task exported, such as the
+// archive of a district export
+func (h *Handler) DownloadTaskFile(c *gin.Context) {
+	id, ok := taskID(c)
+	if !ok {
+		return
+	}
+
+	task, err := h.db.GetTask(id)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get task")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get task"})
+		return
+	}
+	if task == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+		return
+	}
+	if task.Status != models.TaskSucceeded {
+		c.JSON(http.StatusConflict, gin.H{"error": "Task has not succeeded"})
+		return
+	}
+	var result struct {
+		File string `json:"file"`
+	}
+	if len(task.Result) > 0 {
+		json.Unmarshal(task.Result, &result)
+	}
+	if result.File == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Task has no file to download"})
+		return
+	}
+
+	path := filepath.Join(h.cfg.ExportDir, filepath.Base(result.File))
+	if _, err := os.Stat(path); err != nil {
+		c.JSON(http.StatusGone, gin.H{"error": "The file of this task has been deleted"})
+		return
+	}
+	c.FileAttachment(path, filepath.Base(result.File))
+}
+
+// SubmitTask queues a task; poll GET /api/tasks/:id for its progress
+func (h *Handler) SubmitTask(c *gin.Context) {
+	var req models.TaskRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	req.Kind = strings.TrimSpace(req.Kind)
+	kind, ok := h.tasks.Kind(req.Kind)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown task kind: " + req.Kind})
+		return
+	}
+	if !h.allowTaskKind(c, kind) {
+		return
+	}
+
+	task, err := h.tasks.Submit(req.Kind, req.Params)
+	if errors.Is(err, tasks.ErrInvalidParams) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to submit task")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to submit task"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, task)
+}
+
+// CancelTask cancels a queued task or asks a running one to stop
+func (h *Handler) CancelTask(c *gin.Context) {
+	id, ok := taskID(c)
+	if !ok {
+		return
+	}
+
+	task, err := h.db.GetTask(id)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get task")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get task"})
+		return
+	}
+	if task == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+		return
+	}
+	if kind, ok := h.tasks.Kind(task.Kind); ok && !h.allowTaskKind(c, kind) {
+		return
+	}
+
+	task, err = h.tasks.Cancel(id)
+	if errors.Is(err, tasks.ErrTaskFinished) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Task has already finished"})
+		return
+	}
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to cancel task")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel task"})
+		return
+	}
+	if task == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, task)
+}
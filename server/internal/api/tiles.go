@@ -0,0 +1,117 @@
+package api
+
+import (
+	"fundamental/server/internal/models"
+	"fundamental/server/internal/vectortile"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/paulmach/orb/maptile"
+)
+
+const (
+	// maxTileZoom is the highest zoom level tiles are served for
+	maxTileZoom = 22
+	// tilePointZoom is the lowest zoom level whose tiles hold the individual
+	// properties; tiles below it hold clusters
+	tilePointZoom = 14
+	// tileClusterCells is the number of cluster cells along a side of a tile
+	tileClusterCells = 64
+	// tileBuffer is the share of a tile added around it for points, so markers
+	// near the edge are drawn by both tiles
+	tileBuffer = 1.0 / 16
+	// tileMaxAge is how long clients may cache a tile, in seconds
+	tileMaxAge = "300"
+)
+
+// GetPropertyTile returns the geocoded properties in the map tile z/x/y.mvt as
+// a Mapbox Vector Tile, filtered like the property list and optionally by
+// status. Below zoom 14 the tile has a "clusters" layer with a point per grid
+// cell holding the number of properties; from zoom 14 a "properties" layer
+// with every property.
+func (h *Handler) GetPropertyTile(c *gin.Context) {
+	z, zErr := strconv.ParseUint(c.Param("z"), 10, 32)
+	x, xErr := strconv.ParseUint(c.Param("x"), 10, 32)
+	yParam, isMVT := strings.CutSuffix(c.Param("y"), ".mvt")
+	y, yErr := strconv.ParseUint(yParam, 10, 32)
+	if !isMVT || zErr != nil || xErr != nil || yErr != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Tiles are requested as /api/tiles/{z}/{x}/{y}.mvt"})
+		return
+	}
+	if z > maxTileZoom || x >= 1<<z || y >= 1<<z {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Tile coordinates are out of range"})
+		return
+	}
+
+	filter, ok := h.propertyFilter(c)
+	if !ok {
+		return
+	}
+
+	tile := maptile.New(uint32(x), uint32(y), maptile.Zoom(z))
+	var layer vectortile.Layer
+	var err error
+	if z < tilePointZoom {
+		layer, err = h.clusterLayer(c, tile, filter)
+	} else {
+		layer, err = h.propertyLayer(c, tile, filter)
+	}
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get property tile")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get property tile"})
+		return
+	}
+
+	c.Header("Cache-Control", "private, max-age="+tileMaxAge)
+	c.Data(http.StatusOK, "application/vnd.mapbox-vector-tile", vectortile.Encode(layer))
+}
+
+// clusterLayer returns the clusters of the properties in a tile
+func (h *Handler) clusterLayer(c *gin.Context, tile maptile.Tile, filter models.PropertyFilter) (vectortile.Layer, error) {
+	bound := tile.Bound()
+	clusters, err := h.dbFor(c).GetPointClusters(bound.Min.Lat(), bound.Min.Lon(), bound.Max.Lat(), bound.Max.Lon(), tileClusterCells, filter)
+	if err != nil {
+		return vectortile.Layer{}, err
+	}
+
+	layer := vectortile.Layer{Name: "clusters", Extent: vectortile.DefaultExtent}
+	for _, cluster := range clusters {
+		px, py := vectortile.Project(cluster.Longitude, cluster.Latitude, uint32(tile.Z), tile.X, tile.Y, layer.Extent)
+		layer.Points = append(layer.Points, vectortile.Point{X: px, Y: py, Properties: map[string]interface{}{
+			"count":        cluster.Count,
+			"active_count": cluster.ActiveCount,
+			"avg_price":    cluster.AvgPrice,
+		}})
+	}
+	return layer, nil
+}
+
+// propertyLayer returns the properties in a tile and its buffer
+func (h *Handler) propertyLayer(c *gin.Context, tile maptile.Tile, filter models.PropertyFilter) (vectortile.Layer, error) {
+	bound := tile.Bound(tileBuffer)
+	properties, err := h.dbFor(c).GetPropertiesInBounds(bound.Min.Lat(), bound.Min.Lon(), bound.Max.Lat(), bound.Max.Lon(), filter)
+	if err != nil {
+		return vectortile.Layer{}, err
+	}
+
+	layer := vectortile.Layer{Name: "properties", Extent: vectortile.DefaultExtent}
+	for _, p := range properties {
+		px, py := vectortile.Project(*p.Longitude, *p.Latitude, uint32(tile.Z), tile.X, tile.Y, layer.Extent)
+		attributes := map[string]interface{}{
+			"url":          p.URL,
+			"street":       p.Street,
+			"city":         p.City,
+			"postal_code":  p.PostalCode,
+			"price":        p.Price,
+			"status":       p.Status,
+			"energy_label": p.EnergyLabel,
+		}
+		if p.LivingArea != nil {
+			attributes["living_area"] = *p.LivingArea
+		}
+		layer.Points = append(layer.Points, vectortile.Point{ID: uint64(p.ID), X: px, Y: py, Properties: attributes})
+	}
+	return layer, nil
+}
@@ -0,0 +1,107 @@
+package api
+
+import (
+	"fundamental/server/internal/models"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// apiTokenScopePattern matches a path below /api, e.g. "stats" or "properties/stats"
+var apiTokenScopePattern = regexp.MustCompile(`^[a-z0-9-]+(/[a-z0-9-]+)*$`)
+
+// CreateAPIToken creates a read-only token; the secret is only returned in this response.
+// A token created with a workspace only reads that workspace.
+func (h *Handler) CreateAPIToken(c *gin.Context) {
+	var req models.CreateAPITokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Name is required"})
+		return
+	}
+	for i, scope := range req.Scopes {
+		scope = strings.Trim(strings.TrimPrefix(strings.TrimSpace(scope), "/api"), "/")
+		if !apiTokenScopePattern.MatchString(scope) || strings.HasPrefix(scope, "admin") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid scope: " + req.Scopes[i]})
+			return
+		}
+		req.Scopes[i] = scope
+	}
+
+	req.Workspace = strings.TrimSpace(req.Workspace)
+	if req.Workspace != "" {
+		found, err := h.dbFor(c).GetWorkspace(req.Workspace)
+		if err != nil {
+			abortWithError(c, err, "Failed to get workspace")
+			return
+		}
+		if found == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown workspace: " + req.Workspace})
+			return
+		}
+	}
+
+	var expiresAt *time.Time
+	if req.ExpiresInDays != nil {
+		if *req.ExpiresInDays <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "expires_in_days must be positive"})
+			return
+		}
+		expiry := time.Now().UTC().AddDate(0, 0, *req.ExpiresInDays)
+		expiresAt = &expiry
+	}
+
+	token, err := h.dbFor(c).CreateAPIToken(req.Name, req.Scopes, req.Workspace, expiresAt)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to create API token")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create API token"})
+		return
+	}
+
+	h.logger.WithField("token_id", token.ID).Info("Created API token")
+	c.JSON(http.StatusCreated, token)
+}
+
+// ListAPITokens returns all tokens with their expiry, last use and revocation
+func (h *Handler) ListAPITokens(c *gin.Context) {
+	tokens, err := h.dbFor(c).ListAPITokens()
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to list API tokens")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list API tokens"})
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+// RevokeAPIToken revokes a token; revoked tokens stay listed for reference
+func (h *Handler) RevokeAPIToken(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid token ID"})
+		return
+	}
+
+	revoked, err := h.dbFor(c).RevokeAPIToken(id)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to revoke API token")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke API token"})
+		return
+	}
+	if !revoked {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Active token not found"})
+		return
+	}
+
+	h.logger.WithField("token_id", id).Info("Revoked API token")
+	c.JSON(http.StatusOK, gin.H{"message": "Token revoked"})
+}
@@ -0,0 +1,111 @@
+package api
+
+import (
+	"fundamental/server/internal/models"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// validationRuleID parses the id URL parameter
+func validationRuleID(c *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid validation rule ID"})
+		return 0, false
+	}
+	return id, true
+}
+
+// bindValidationRule parses a validation rule request body; the database
+// validates the rule
+func bindValidationRule(c *gin.Context) (models.ValidationRuleRequest, bool) {
+	var req models.ValidationRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return req, false
+	}
+	req.Field = strings.TrimSpace(req.Field)
+	req.Kind = strings.ToLower(strings.TrimSpace(req.Kind))
+	req.Message = strings.TrimSpace(req.Message)
+	return req, true
+}
+
+// ListValidationRules returns the validation rules of scraped and imported
+// items
+func (h *Handler) ListValidationRules(c *gin.Context) {
+	rules, err := h.dbFor(c).ListValidationRules()
+	if err != nil {
+		abortWithError(c, err, "Failed to list validation rules")
+		return
+	}
+
+	c.JSON(http.StatusOK, rules)
+}
+
+// CreateValidationRule adds a validation rule; it applies from the next batch
+// of scraped or imported items
+func (h *Handler) CreateValidationRule(c *gin.Context) {
+	req, ok := bindValidationRule(c)
+	if !ok {
+		return
+	}
+
+	rule, err := h.dbFor(c).CreateValidationRule(req)
+	if err != nil {
+		abortWithError(c, err, "Failed to create validation rule")
+		return
+	}
+
+	c.JSON(http.StatusCreated, rule)
+}
+
+// UpdateValidationRule replaces a validation rule
+func (h *Handler) UpdateValidationRule(c *gin.Context) {
+	id, ok := validationRuleID(c)
+	if !ok {
+		return
+	}
+	req, ok := bindValidationRule(c)
+	if !ok {
+		return
+	}
+
+	rule, err := h.dbFor(c).UpdateValidationRule(id, req)
+	if err != nil {
+		abortWithError(c, err, "Failed to update validation rule")
+		return
+	}
+
+	c.JSON(http.StatusOK, rule)
+}
+
+// DeleteValidationRule removes a validation rule. Items it quarantined stay
+// in the rejected items.
+func (h *Handler) DeleteValidationRule(c *gin.Context) {
+	id, ok := validationRuleID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.dbFor(c).DeleteValidationRule(id); err != nil {
+		abortWithError(c, err, "Failed to delete validation rule")
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// GetDataQuality counts the properties with implausible or missing data and
+// the items in quarantine, by source and failed check
+func (h *Handler) GetDataQuality(c *gin.Context) {
+	quality, err := h.dbFor(c).GetDataQuality()
+	if err != nil {
+		abortWithError(c, err, "Failed to get data quality")
+		return
+	}
+
+	c.JSON(http.StatusOK, quality)
+}
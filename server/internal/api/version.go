@@ -0,0 +1,104 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Versions of the API. Every route is served under /api/v1/..., and under
+// /api/... for the clients written before versioning. A breaking change to
+// response shapes ships in a new version while the earlier ones keep theirs;
+// handlers answer according to RequestAPIVersion.
+const (
+	APIVersion1      = 1
+	LatestAPIVersion = APIVersion1
+)
+
+// supportedAPIVersions are the versions the server answers
+var supportedAPIVersions = []int{APIVersion1}
+
+// APIVersionHeader is the request header a client may ask for a version with
+// on unversioned paths, and the response header naming the version served
+const APIVersionHeader = "API-Version"
+
+var (
+	// versionedPathRegex matches the version prefix of /api/v1/... paths
+	versionedPathRegex = regexp.MustCompile(`^/api/v(\d+)(/|$)`)
+	// versionMediaTypeRegex matches a version asked for in the Accept header,
+	// such as application/vnd.fundamental.v1+json
+	versionMediaTypeRegex = regexp.MustCompile(`application/vnd\.fundamental\.v(\d+)\+json`)
+)
+
+type apiVersionContextKey struct{}
+
+// RequestAPIVersion returns the API version a request was answered with
+func RequestAPIVersion(c *gin.Context) int {
+	if version, ok := c.Request.Context().Value(apiVersionContextKey{}).(int); ok {
+		return version
+	}
+	return APIVersion1
+}
+
+// Versioned negotiates the API version of requests before the router sees
+// them. The version is taken from the /api/vN/ prefix of the path, which is
+// removed so the routes match, else from the API-Version header or an Accept
+// media type application/vnd.fundamental.vN+json, and is 1 otherwise. An
+// unsupported version is answered with 404 for a versioned path and 406 for
+// a header. Requests outside /api/ are passed on unchanged.
+func Versioned(router http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := r.URL.Path
+		if path != "/api" && !strings.HasPrefix(path, "/api/") {
+			router.ServeHTTP(w, r)
+			return
+		}
+
+		version, status := APIVersion1, http.StatusNotAcceptable
+		if m := versionedPathRegex.FindStringSubmatch(path); m != nil {
+			version, status = parseAPIVersion(m[1]), http.StatusNotFound
+			// A copy of the URL, so the request keeps its original one
+			u := *r.URL
+			u.Path = "/api/" + strings.TrimPrefix(path, m[0])
+			u.RawPath = ""
+			r = r.Clone(r.Context())
+			r.URL = &u
+		} else if header := r.Header.Get(APIVersionHeader); header != "" {
+			version = parseAPIVersion(strings.TrimPrefix(strings.ToLower(strings.TrimSpace(header)), "v"))
+		} else if m := versionMediaTypeRegex.FindStringSubmatch(r.Header.Get("Accept")); m != nil {
+			version = parseAPIVersion(m[1])
+		}
+
+		if !slices.Contains(supportedAPIVersions, version) {
+			supported := make([]string, len(supportedAPIVersions))
+			for i, v := range supportedAPIVersions {
+				supported[i] = strconv.Itoa(v)
+			}
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			w.WriteHeader(status)
+			json.NewEncoder(w).Encode(gin.H{
+				"error": "Unsupported API version, supported versions are " + strings.Join(supported, ", "),
+			})
+			return
+		}
+
+		w.Header().Set(APIVersionHeader, strconv.Itoa(version))
+		router.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), apiVersionContextKey{}, version)))
+	})
+}
+
+// parseAPIVersion returns the version number of a requested version, or 0
+// when it is not a number
+func parseAPIVersion(raw string) int {
+	version, err := strconv.Atoi(raw)
+	if err != nil {
+		return 0
+	}
+	return version
+}
@@ -0,0 +1,146 @@
+package api
+
+import (
+	"bufio"
+	"fmt"
+	"fundamental/server/internal/database"
+	"fundamental/server/internal/models"
+	"fundamental/server/internal/parquet"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// viewParquetTypes maps the column types of the views to Parquet types
+var viewParquetTypes = map[string]parquet.Type{
+	models.ViewInteger: parquet.Int64,
+	models.ViewReal:    parquet.Double,
+	models.ViewText:    parquet.String,
+	models.ViewDate:    parquet.Date,
+}
+
+// ListViews documents the database views external tools can read
+func (h *Handler) ListViews(c *gin.Context) {
+	c.JSON(http.StatusOK, database.Views())
+}
+
+// ExportView streams the rows of a database view as CSV or, with
+// format=parquet, as a Parquet file. An error after the first row leaves the
+// file truncated. A view with more rows than the export quota is refused with
+// 403.
+func (h *Handler) ExportView(c *gin.Context) {
+	format := c.DefaultQuery("format", "csv")
+	if format != "csv" && format != "parquet" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Format must be csv or parquet"})
+		return
+	}
+
+	locale, ok := exportLocale(c)
+	if !ok {
+		return
+	}
+
+	name := c.Param("view")
+	db := h.dbFor(c)
+	if !h.checkExportQuota(c, func() (int, error) { return db.CountView(c.Request.Context(), name) }) {
+		return
+	}
+
+	rows, err := db.IterateView(c.Request.Context(), name)
+	if err != nil {
+		h.logger.WithError(err).WithField("view", name).Error("Failed to export view")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export view"})
+		return
+	}
+	if rows == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "View not found"})
+		return
+	}
+	defer rows.Close()
+
+	filename := fmt.Sprintf("%s-%s.%s", name, time.Now().Format("20060102"), format)
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	if format == "parquet" {
+		c.Header("Content-Type", "application/vnd.apache.parquet")
+	} else {
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+	}
+	c.Status(http.StatusOK)
+
+	var count int
+	if format == "parquet" {
+		count, err = writeParquetView(c, rows)
+	} else {
+		count, err = writeCSVView(c, rows, locale)
+	}
+	if err != nil {
+		h.logger.WithError(err).WithFields(logrus.Fields{"view": name, "rows": count}).Error("Failed to stream view export")
+	}
+	h.recordExport(count)
+}
+
+// writeCSVView writes the rows of a view as CSV with a header row
+func writeCSVView(c *gin.Context, rows *database.ViewIterator, locale *csvLocale) (int, error) {
+	columns := rows.Columns()
+	w := locale.writer(c.Writer)
+	record := make([]string, len(columns))
+	for i, column := range columns {
+		record[i] = locale.header(column.Name)
+	}
+	if err := w.Write(record); err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for rows.Next() {
+		for i, value := range rows.Values() {
+			record[i] = locale.value(viewParquetTypes[columns[i].Type], value)
+		}
+		if err := w.Write(record); err != nil {
+			return count, err
+		}
+		count++
+		if count%csvFlushEvery == 0 {
+			w.Flush()
+			if err := w.Error(); err != nil {
+				return count, err
+			}
+			c.Writer.Flush()
+		}
+	}
+	if err := rows.Err(); err != nil {
+		w.Flush()
+		return count, err
+	}
+	w.Flush()
+	return count, w.Error()
+}
+
+// writeParquetView writes the rows of a view as a Parquet file
+func writeParquetView(c *gin.Context, rows *database.ViewIterator) (int, error) {
+	columns := make([]parquet.Column, len(rows.Columns()))
+	for i, column := range rows.Columns() {
+		columns[i] = parquet.Column{Name: column.Name, Type: viewParquetTypes[column.Type]}
+	}
+	out := bufio.NewWriter(c.Writer)
+	w := parquet.NewWriter(out, columns, parquet.DefaultRowGroupSize)
+
+	count := 0
+	for rows.Next() {
+		if err := w.Write(rows.Values()); err != nil {
+			out.Flush()
+			return count, err
+		}
+		count++
+	}
+	if err := rows.Err(); err != nil {
+		out.Flush()
+		return count, err
+	}
+	if err := w.Close(); err != nil {
+		return count, err
+	}
+	return count, out.Flush()
+}
@@ -0,0 +1,251 @@
+package api
+
+import (
+	"fmt"
+	"fundamental/server/internal/models"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultWatchRadius = 1000
+	minWatchRadius     = 50
+	maxWatchRadius     = 10000
+)
+
+// watchlistID parses the id URL parameter
+func watchlistID(c *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid watchlist ID"})
+		return 0, false
+	}
+	return id, true
+}
+
+// bindWatchlist parses and validates a watchlist request body. The radius
+// defaults to 1 km.
+func bindWatchlist(c *gin.Context) (models.WatchlistRequest, bool) {
+	var req models.WatchlistRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return req, false
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Name is required"})
+		return req, false
+	}
+	if req.RadiusMeters == 0 {
+		req.RadiusMeters = defaultWatchRadius
+	}
+	if req.RadiusMeters < minWatchRadius || req.RadiusMeters > maxWatchRadius {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("radius_meters must be between %d and %d", minWatchRadius, maxWatchRadius)})
+		return req, false
+	}
+	return req, true
+}
+
+// ListWatchlists returns all watchlists
+func (h *Handler) ListWatchlists(c *gin.Context) {
+	watchlists, err := h.dbFor(c).ListWatchlists()
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to list watchlists")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list watchlists"})
+		return
+	}
+
+	c.JSON(http.StatusOK, watchlists)
+}
+
+// CreateWatchlist creates an empty watchlist
+func (h *Handler) CreateWatchlist(c *gin.Context) {
+	req, ok := bindWatchlist(c)
+	if !ok {
+		return
+	}
+
+	watchlist, err := h.dbFor(c).CreateWatchlist(req)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to create watchlist")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create watchlist"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, watchlist)
+}
+
+// GetWatchlist returns a watchlist with the ids of its properties
+func (h *Handler) GetWatchlist(c *gin.Context) {
+	id, ok := watchlistID(c)
+	if !ok {
+		return
+	}
+
+	watchlist, err := h.dbFor(c).GetWatchlist(id)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get watchlist")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get watchlist"})
+		return
+	}
+	if watchlist == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Watchlist not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, watchlist)
+}
+
+// UpdateWatchlist replaces the name and radius of a watchlist
+func (h *Handler) UpdateWatchlist(c *gin.Context) {
+	id, ok := watchlistID(c)
+	if !ok {
+		return
+	}
+	req, ok := bindWatchlist(c)
+	if !ok {
+		return
+	}
+
+	watchlist, err := h.dbFor(c).UpdateWatchlist(id, req)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to update watchlist")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update watchlist"})
+		return
+	}
+	if watchlist == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Watchlist not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, watchlist)
+}
+
+// DeleteWatchlist removes a watchlist with its alerts
+func (h *Handler) DeleteWatchlist(c *gin.Context) {
+	id, ok := watchlistID(c)
+	if !ok {
+		return
+	}
+
+	deleted, err := h.dbFor(c).DeleteWatchlist(id)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to delete watchlist")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete watchlist"})
+		return
+	}
+	if !deleted {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Watchlist not found"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// AddWatchlistProperty puts a property on a watchlist and returns the
+// watchlist
+func (h *Handler) AddWatchlistProperty(c *gin.Context) {
+	id, ok := watchlistID(c)
+	if !ok {
+		return
+	}
+	propertyID, err := strconv.ParseInt(c.Param("property_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid property ID"})
+		return
+	}
+
+	watchlist, err := h.dbFor(c).GetWatchlist(id)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get watchlist")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get watchlist"})
+		return
+	}
+	if watchlist == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Watchlist not found"})
+		return
+	}
+
+	added, err := h.dbFor(c).AddWatchlistProperty(id, propertyID)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to add watched property")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add watched property"})
+		return
+	}
+	if !added {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Property not found"})
+		return
+	}
+
+	watchlist, err = h.dbFor(c).GetWatchlist(id)
+	if err != nil || watchlist == nil {
+		h.logger.WithError(err).Error("Failed to get watchlist")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get watchlist"})
+		return
+	}
+
+	c.JSON(http.StatusOK, watchlist)
+}
+
+// RemoveWatchlistProperty takes a property off a watchlist
+func (h *Handler) RemoveWatchlistProperty(c *gin.Context) {
+	id, ok := watchlistID(c)
+	if !ok {
+		return
+	}
+	propertyID, err := strconv.ParseInt(c.Param("property_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid property ID"})
+		return
+	}
+
+	removed, err := h.dbFor(c).RemoveWatchlistProperty(id, propertyID)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to remove watched property")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove watched property"})
+		return
+	}
+	if !removed {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Property is not on the watchlist"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// ListWatchAlerts returns the comparables sold near the properties of a
+// watchlist, newest first
+func (h *Handler) ListWatchAlerts(c *gin.Context) {
+	id, ok := watchlistID(c)
+	if !ok {
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Limit must be a positive number"})
+		return
+	}
+
+	watchlist, err := h.dbFor(c).GetWatchlist(id)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get watchlist")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get watchlist"})
+		return
+	}
+	if watchlist == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Watchlist not found"})
+		return
+	}
+
+	alerts, err := h.dbFor(c).ListWatchAlerts(id, limit)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to list watch alerts")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list watch alerts"})
+		return
+	}
+
+	c.JSON(http.StatusOK, alerts)
+}
@@ -0,0 +1,252 @@
+package api
+
+import (
+	"context"
+	"fundamental/server/config"
+	"fundamental/server/internal/database"
+	"fundamental/server/internal/models"
+	"fundamental/server/internal/workspace"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// WorkspaceHeader selects the workspace of a request made with the admin
+// token, or without a token when AUTH_REQUIRED is not set. API tokens are
+// bound to the workspace they were created for.
+const WorkspaceHeader = "X-Workspace"
+
+// mainOnlyPrefixes are the paths served by the main router whatever the
+// workspace: the workspaces and the tokens live in the main database
+var mainOnlyPrefixes = []string{"/api/admin/workspaces", "/api/admin/tokens"}
+
+type workspaceContextKey struct{}
+
+// RequestWorkspace returns the slug of the workspace a request was handed to,
+// empty for the main dataset
+func RequestWorkspace(c *gin.Context) string {
+	slug, _ := c.Request.Context().Value(workspaceContextKey{}).(string)
+	return slug
+}
+
+// SelectWorkspace hands the requests of a workspace to its router. It runs
+// after Authenticate; requests without a workspace, outside /api/ or to the
+// workspace and token administration are served by the main router.
+func SelectWorkspace(workspaces *workspace.Registry, logger *logrus.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		path := c.Request.URL.Path
+		slug := strings.TrimSpace(c.GetHeader(WorkspaceHeader))
+		if token, ok := c.Get(apiTokenContextKey); ok {
+			bound := token.(*models.APIToken).Workspace
+			if slug != "" && slug != bound {
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Token does not grant access to this workspace"})
+				return
+			}
+			slug = bound
+		}
+		if slug == "" || !strings.HasPrefix(path, "/api/") || isDocsPath(path) || isMainOnlyPath(path) {
+			c.Next()
+			return
+		}
+
+		handler, err := workspaces.Handler(slug)
+		if err != nil {
+			logger.WithError(err).WithField("workspace", slug).Error("Failed to open workspace")
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to open workspace"})
+			return
+		}
+		if handler == nil {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "Workspace not found"})
+			return
+		}
+		handler.ServeHTTP(c.Writer, c.Request.WithContext(context.WithValue(c.Request.Context(), workspaceContextKey{}, slug)))
+		c.Abort()
+	}
+}
+
+func isMainOnlyPath(path string) bool {
+	for _, prefix := range mainOnlyPrefixes {
+		if path == prefix || strings.HasPrefix(path, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// WorkspaceHandler manages the workspaces
+type WorkspaceHandler struct {
+	workspaces *workspace.Registry
+	db         workspaceStore
+}
+
+// workspaceStore reads the workspace records of the main database
+type workspaceStore interface {
+	ListWorkspaces() ([]models.Workspace, error)
+	GetWorkspace(slug string) (*models.Workspace, error)
+	RenameWorkspace(slug, name string) (*models.Workspace, error)
+}
+
+// NewWorkspaceHandler creates a handler for the workspaces of a registry,
+// recorded in db
+func NewWorkspaceHandler(workspaces *workspace.Registry, db workspaceStore) *WorkspaceHandler {
+	return &WorkspaceHandler{workspaces: workspaces, db: db}
+}
+
+// SetupWorkspaceRoutes adds the workspace administration routes to the router
+func SetupWorkspaceRoutes(router *gin.Engine, workspaces *workspace.Registry, db workspaceStore, cfg *config.Config) {
+	handler := NewWorkspaceHandler(workspaces, db)
+
+	admin := router.Group("/api/admin/workspaces", RequireAdmin(cfg))
+	admin.GET("", handler.ListWorkspaces)
+	admin.POST("", handler.CreateWorkspace)
+	admin.GET("/:slug", handler.GetWorkspace)
+	admin.PUT("/:slug", handler.RenameWorkspace)
+	admin.DELETE("/:slug", handler.DeleteWorkspace)
+	admin.PUT("/:slug/quotas", handler.UpdateWorkspaceQuotas)
+	admin.GET("/:slug/usage", handler.GetWorkspaceUsage)
+}
+
+// ListWorkspaces returns all workspaces by slug
+func (h *WorkspaceHandler) ListWorkspaces(c *gin.Context) {
+	workspaces, err := h.db.ListWorkspaces()
+	if err != nil {
+		abortWithError(c, err, "Failed to list workspaces")
+		return
+	}
+	c.JSON(http.StatusOK, workspaces)
+}
+
+// CreateWorkspace creates a workspace with an empty database. The slug, of
+// lowercase letters, digits and dashes, is sent in the X-Workspace header to
+// select it.
+func (h *WorkspaceHandler) CreateWorkspace(c *gin.Context) {
+	var req models.WorkspaceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	req.Slug = strings.TrimSpace(req.Slug)
+	req.Name = strings.TrimSpace(req.Name)
+	if !workspace.ValidSlug(req.Slug) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Slug must be 1 to 40 lowercase letters, digits or dashes, starting with a letter or digit"})
+		return
+	}
+	if req.Name == "" {
+		req.Name = req.Slug
+	}
+
+	created, err := h.workspaces.Create(req.Slug, req.Name)
+	if err != nil {
+		abortWithError(c, err, "Failed to create workspace")
+		return
+	}
+	c.JSON(http.StatusCreated, created)
+}
+
+// GetWorkspace returns a workspace
+func (h *WorkspaceHandler) GetWorkspace(c *gin.Context) {
+	found, err := h.db.GetWorkspace(c.Param("slug"))
+	if err != nil {
+		abortWithError(c, err, "Failed to get workspace")
+		return
+	}
+	if found == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Workspace not found"})
+		return
+	}
+	c.JSON(http.StatusOK, found)
+}
+
+// RenameWorkspace changes the name of a workspace; its slug stays the same
+func (h *WorkspaceHandler) RenameWorkspace(c *gin.Context) {
+	var req models.WorkspaceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Name is required"})
+		return
+	}
+
+	renamed, err := h.db.RenameWorkspace(c.Param("slug"), req.Name)
+	if err != nil {
+		abortWithError(c, err, "Failed to rename workspace")
+		return
+	}
+	if renamed == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Workspace not found"})
+		return
+	}
+	c.JSON(http.StatusOK, renamed)
+}
+
+// DeleteWorkspace stops a workspace and revokes its tokens. Its database is
+// kept unless ?purge=true, so creating the workspace again restores it.
+func (h *WorkspaceHandler) DeleteWorkspace(c *gin.Context) {
+	deleted, err := h.workspaces.Delete(c.Param("slug"), c.Query("purge") == "true")
+	if err != nil {
+		abortWithError(c, err, "Failed to delete workspace")
+		return
+	}
+	if !deleted {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Workspace not found"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// workspaceDatabase returns the database of the workspace named in the path,
+// answering 404 when it does not exist
+func (h *WorkspaceHandler) workspaceDatabase(c *gin.Context) (*database.Database, bool) {
+	db, err := h.workspaces.Database(c.Param("slug"))
+	if err != nil {
+		abortWithError(c, err, "Failed to open workspace")
+		return nil, false
+	}
+	if db == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Workspace not found"})
+		return nil, false
+	}
+	return db.WithContext(c.Request.Context()), true
+}
+
+// UpdateWorkspaceQuotas replaces the quotas of a workspace. A quota left out
+// or null is unlimited. They apply to the next scheduled spider run,
+// notification and export.
+func (h *WorkspaceHandler) UpdateWorkspaceQuotas(c *gin.Context) {
+	var quotas models.WorkspaceQuotas
+	if err := c.ShouldBindJSON(&quotas); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	db, ok := h.workspaceDatabase(c)
+	if !ok {
+		return
+	}
+
+	if err := db.SaveQuotas(quotas); err != nil {
+		abortWithError(c, err, "Failed to save quotas")
+		return
+	}
+	c.JSON(http.StatusOK, quotas)
+}
+
+// GetWorkspaceUsage returns the quotas of a workspace and what it used of
+// them, as GET /api/usage does for the workspace itself
+func (h *WorkspaceHandler) GetWorkspaceUsage(c *gin.Context) {
+	db, ok := h.workspaceDatabase(c)
+	if !ok {
+		return
+	}
+
+	usage, err := db.GetQuotaUsage()
+	if err != nil {
+		abortWithError(c, err, "Failed to get usage")
+		return
+	}
+	c.JSON(http.StatusOK, usage)
+}
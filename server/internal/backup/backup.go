@@ -0,0 +1,279 @@
+package backup
+
+import (
+	"fmt"
+	"fundamental/server/config"
+	"fundamental/server/internal/database"
+	"fundamental/server/internal/errorsink"
+	"fundamental/server/internal/models"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// Backups are named funda-<UTC timestamp>.db; only these count towards retention
+	filePrefix = "funda-"
+	fileSuffix = ".db"
+	timeLayout = "20060102T150405Z"
+
+	// restoreRequestFile in the backup directory names the backup to restore on
+	// the next start
+	restoreRequestFile = "restore"
+)
+
+// backupMu allows one backup at a time, from the schedule or the admin API
+var backupMu sync.Mutex
+
+// Manager takes database backups and enforces their retention
+type Manager struct {
+	db        *database.Database
+	dir       string
+	retention int
+	interval  time.Duration
+	logger    *logrus.Logger
+	stopChan  chan struct{}
+	wg        sync.WaitGroup
+}
+
+// NewManager creates a backup manager writing to the configured backup directory
+func NewManager(db *database.Database, cfg *config.Config, logger *logrus.Logger) *Manager {
+	return &Manager{
+		db:        db,
+		dir:       cfg.BackupDir,
+		retention: cfg.BackupRetention,
+		interval:  time.Duration(cfg.BackupIntervalHours) * time.Hour,
+		logger:    errorsink.WithModule(logger, "backup"),
+		stopChan:  make(chan struct{}),
+	}
+}
+
+// Create writes a new timestamped backup and deletes the backups beyond the
+// retention count
+func (m *Manager) Create() (*models.Backup, error) {
+	backupMu.Lock()
+	defer backupMu.Unlock()
+
+	if err := os.MkdirAll(m.dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create backup directory: %v", err)
+	}
+	name := filePrefix + time.Now().UTC().Format(timeLayout) + fileSuffix
+	path := filepath.Join(m.dir, name)
+	if err := m.db.Backup(path); err != nil {
+		return nil, err
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read backup: %v", err)
+	}
+	backup := &models.Backup{Name: name, Size: info.Size(), CreatedAt: info.ModTime().UTC()}
+
+	if err := m.prune(); err != nil {
+		m.logger.WithError(err).Warn("Failed to delete old backups")
+	}
+	return backup, nil
+}
+
+// List returns the backups in the backup directory, newest first
+func (m *Manager) List() ([]models.Backup, error) {
+	entries, err := os.ReadDir(m.dir)
+	if os.IsNotExist(err) {
+		return []models.Backup{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read backup directory: %v", err)
+	}
+
+	backups := []models.Backup{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, fileSuffix) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		backups = append(backups, models.Backup{Name: name, Size: info.Size(), CreatedAt: info.ModTime().UTC()})
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].CreatedAt.After(backups[j].CreatedAt)
+	})
+	return backups, nil
+}
+
+// prune deletes the oldest scheduled and manual backups beyond the retention
+// count. Backups taken before a restore are kept.
+func (m *Manager) prune() error {
+	if m.retention <= 0 {
+		return nil
+	}
+	entries, err := os.ReadDir(m.dir)
+	if err != nil {
+		return err
+	}
+	var names []string
+	for _, entry := range entries {
+		if name := entry.Name(); strings.HasPrefix(name, filePrefix) && strings.HasSuffix(name, fileSuffix) {
+			names = append(names, name)
+		}
+	}
+	// Timestamps in the names sort chronologically
+	sort.Sort(sort.Reverse(sort.StringSlice(names)))
+	for _, name := range names[min(m.retention, len(names)):] {
+		if err := os.Remove(filepath.Join(m.dir, name)); err != nil {
+			return err
+		}
+		m.logger.WithField("backup", name).Info("Deleted old backup")
+	}
+	return nil
+}
+
+// RequestRestore validates a backup and records it to be restored on the next
+// start. The running server keeps using the current database.
+func (m *Manager) RequestRestore(name, key string) error {
+	if name != filepath.Base(name) || !strings.HasSuffix(name, fileSuffix) {
+		return fmt.Errorf("invalid backup name %q", name)
+	}
+	if _, err := database.ValidateBackup(filepath.Join(m.dir, name), key); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(m.dir, restoreRequestFile), []byte(name+"\n"), 0644)
+}
+
+// Start takes a backup every interval until Stop is called; it does nothing
+// when the interval is zero
+func (m *Manager) Start() {
+	if m.interval <= 0 {
+		return
+	}
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		defer errorsink.Recover("backup")
+
+		ticker := time.NewTicker(m.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-m.stopChan:
+				return
+			case <-ticker.C:
+				backup, err := m.Create()
+				if err != nil {
+					m.logger.WithError(err).Error("Scheduled backup failed")
+					continue
+				}
+				m.logger.WithFields(logrus.Fields{
+					"backup": backup.Name,
+					"size":   backup.Size,
+				}).Info("Scheduled backup completed")
+			}
+		}
+	}()
+}
+
+// Stop stops the backup schedule
+func (m *Manager) Stop() {
+	close(m.stopChan)
+	m.wg.Wait()
+}
+
+// RestorePending restores the backup named in the restore request file of the
+// backup directory, if there is one. It runs at startup before the database is
+// opened. The backup is validated first and the current database is backed up
+// as pre-restore-<timestamp>.db, so a restore can be undone. A request that
+// fails validation is renamed to restore.failed and the current database is
+// kept. It returns the name of the restored backup, or "" when none was
+// requested.
+func RestorePending(cfg *config.Config, dbPath string, logger *logrus.Logger) (string, error) {
+	requestPath := filepath.Join(cfg.BackupDir, restoreRequestFile)
+	request, err := os.ReadFile(requestPath)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read restore request: %v", err)
+	}
+
+	name := strings.TrimSpace(string(request))
+	fail := func(err error) (string, error) {
+		os.Rename(requestPath, requestPath+".failed")
+		return "", fmt.Errorf("restore of %s failed, keeping the current database: %v", name, err)
+	}
+	if name != filepath.Base(name) || name == "" {
+		return fail(fmt.Errorf("invalid backup name"))
+	}
+	backupPath := filepath.Join(cfg.BackupDir, name)
+	version, err := database.ValidateBackup(backupPath, cfg.DatabaseKey)
+	if err != nil {
+		return fail(err)
+	}
+
+	// Keep the current database, including changes still in its WAL file
+	if _, err := os.Stat(dbPath); err == nil {
+		current, err := database.OpenFromConfig(cfg, dbPath)
+		if err != nil {
+			return fail(err)
+		}
+		prePath := filepath.Join(cfg.BackupDir, "pre-restore-"+time.Now().UTC().Format(timeLayout)+fileSuffix)
+		err = current.Backup(prePath)
+		current.Close()
+		if err != nil {
+			return fail(fmt.Errorf("failed to back up the current database: %v", err))
+		}
+		logger.WithField("backup", filepath.Base(prePath)).Info("Backed up the current database before restoring")
+	}
+
+	if err := copyFile(backupPath, dbPath+".restore"); err != nil {
+		return fail(err)
+	}
+	for _, suffix := range []string{"-wal", "-shm"} {
+		if err := os.Remove(dbPath + suffix); err != nil && !os.IsNotExist(err) {
+			return fail(fmt.Errorf("failed to remove %s file: %v", suffix, err))
+		}
+	}
+	if err := os.Rename(dbPath+".restore", dbPath); err != nil {
+		return fail(fmt.Errorf("failed to move restored database into place: %v", err))
+	}
+	if err := os.Remove(requestPath); err != nil {
+		return "", fmt.Errorf("restored %s but failed to remove the restore request: %v", name, err)
+	}
+
+	logger.WithFields(logrus.Fields{
+		"backup":         name,
+		"schema_version": version,
+	}).Info("Restored database from backup")
+	return name, nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("failed to open backup: %v", err)
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return fmt.Errorf("failed to create database file: %v", err)
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return fmt.Errorf("failed to copy backup: %v", err)
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return fmt.Errorf("failed to copy backup: %v", err)
+	}
+	return out.Close()
+}
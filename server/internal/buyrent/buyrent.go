@@ -0,0 +1,253 @@
+package buyrent
+
+import (
+	"fmt"
+	"math"
+)
+
+// Scenario is a property profile and the assumptions the cost of buying and
+// renting it are compared under. Percentages are yearly unless noted. Unset
+// fields take the defaults of Defaults.
+type Scenario struct {
+	// Property profile; the purchase price is estimated from the district's
+	// sales when it is not given
+	District      string  `json:"district,omitempty"`
+	LivingArea    float64 `json:"living_area,omitempty"`
+	PurchasePrice float64 `json:"purchase_price,omitempty"`
+	MonthlyRent   float64 `json:"monthly_rent"`
+
+	HorizonYears  int      `json:"horizon_years,omitempty"`
+	MortgageRate  *float64 `json:"mortgage_rate_pct,omitempty"`
+	MortgageYears int      `json:"mortgage_years,omitempty"`
+	DownPayment   *float64 `json:"down_payment_pct,omitempty"` // of the purchase price
+	// Share of the mortgage interest paid back through tax deduction
+	InterestDeduction *float64 `json:"interest_deduction_pct,omitempty"`
+	Appreciation      *float64 `json:"appreciation_pct,omitempty"`
+	RentGrowth        *float64 `json:"rent_growth_pct,omitempty"`
+	Maintenance       *float64 `json:"maintenance_pct,omitempty"` // of the home value
+	OwnerCosts        *float64 `json:"owner_costs_pct,omitempty"` // taxes and insurance, of the home value
+	PurchaseCosts     *float64 `json:"purchase_costs_pct,omitempty"`
+	SellingCosts      *float64 `json:"selling_costs_pct,omitempty"`
+	InvestmentReturn  *float64 `json:"investment_return_pct,omitempty"`
+}
+
+// Defaults are the assumptions used for the fields a scenario leaves unset:
+// a 30-year annuity mortgage at 4% with 10% down, 2% transfer tax plus 2% other
+// purchase costs, and money not spent on housing invested at 4%
+var Defaults = Scenario{
+	HorizonYears:      10,
+	MortgageRate:      float(4),
+	MortgageYears:     30,
+	DownPayment:       float(10),
+	InterestDeduction: float(0),
+	Appreciation:      float(3),
+	RentGrowth:        float(3),
+	Maintenance:       float(1),
+	OwnerCosts:        float(0.5),
+	PurchaseCosts:     float(4),
+	SellingCosts:      float(1.5),
+	InvestmentReturn:  float(4),
+}
+
+func float(v float64) *float64 {
+	return &v
+}
+
+// WithDefaults fills the unset fields of s from Defaults
+func (s Scenario) WithDefaults() Scenario {
+	if s.HorizonYears == 0 {
+		s.HorizonYears = Defaults.HorizonYears
+	}
+	if s.MortgageYears == 0 {
+		s.MortgageYears = Defaults.MortgageYears
+	}
+	for _, field := range []struct{ value, fallback **float64 }{
+		{&s.MortgageRate, &Defaults.MortgageRate},
+		{&s.DownPayment, &Defaults.DownPayment},
+		{&s.InterestDeduction, &Defaults.InterestDeduction},
+		{&s.Appreciation, &Defaults.Appreciation},
+		{&s.RentGrowth, &Defaults.RentGrowth},
+		{&s.Maintenance, &Defaults.Maintenance},
+		{&s.OwnerCosts, &Defaults.OwnerCosts},
+		{&s.PurchaseCosts, &Defaults.PurchaseCosts},
+		{&s.SellingCosts, &Defaults.SellingCosts},
+		{&s.InvestmentReturn, &Defaults.InvestmentReturn},
+	} {
+		if *field.value == nil {
+			*field.value = *field.fallback
+		}
+	}
+	return s
+}
+
+// Validate checks a scenario with its defaults filled in. The purchase price
+// must be known by then.
+func (s Scenario) Validate() error {
+	switch {
+	case s.PurchasePrice <= 0:
+		return fmt.Errorf("purchase_price must be positive, or give a district and living_area to estimate it")
+	case s.MonthlyRent <= 0:
+		return fmt.Errorf("monthly_rent must be positive")
+	case s.HorizonYears < 1 || s.HorizonYears > 50:
+		return fmt.Errorf("horizon_years must be between 1 and 50")
+	case s.MortgageYears < 1 || s.MortgageYears > 50:
+		return fmt.Errorf("mortgage_years must be between 1 and 50")
+	case *s.DownPayment < 0 || *s.DownPayment > 100:
+		return fmt.Errorf("down_payment_pct must be between 0 and 100")
+	case *s.InterestDeduction < 0 || *s.InterestDeduction > 100:
+		return fmt.Errorf("interest_deduction_pct must be between 0 and 100")
+	}
+	rates := []struct {
+		name string
+		pct  float64
+		min  float64
+	}{
+		{"mortgage_rate_pct", *s.MortgageRate, 0},
+		{"appreciation_pct", *s.Appreciation, -20},
+		{"rent_growth_pct", *s.RentGrowth, -20},
+		{"maintenance_pct", *s.Maintenance, 0},
+		{"owner_costs_pct", *s.OwnerCosts, 0},
+		{"purchase_costs_pct", *s.PurchaseCosts, 0},
+		{"selling_costs_pct", *s.SellingCosts, 0},
+		{"investment_return_pct", *s.InvestmentReturn, -20},
+	}
+	for _, rate := range rates {
+		if rate.pct < rate.min || rate.pct > 50 {
+			return fmt.Errorf("%s must be between %g and 50", rate.name, rate.min)
+		}
+	}
+	return nil
+}
+
+// Year is one year of the comparison. Costs are paid during the year, values
+// and net worth are at its end. Net worth counts what a buyer would keep after
+// selling the home and paying off the mortgage, and the savings either side
+// invested.
+type Year struct {
+	Year              int     `json:"year"`
+	MortgageInterest  float64 `json:"mortgage_interest"`
+	MortgagePrincipal float64 `json:"mortgage_principal"`
+	InterestDeduction float64 `json:"interest_deduction"`
+	Maintenance       float64 `json:"maintenance"`
+	OwnerCosts        float64 `json:"owner_costs"`
+	BuyingCost        float64 `json:"buying_cost"` // all of the above, less the deduction
+	HomeValue         float64 `json:"home_value"`
+	MortgageBalance   float64 `json:"mortgage_balance"`
+	BuyerNetWorth     float64 `json:"buyer_net_worth"`
+	Rent              float64 `json:"rent"`
+	RenterNetWorth    float64 `json:"renter_net_worth"`
+	Difference        float64 `json:"difference"` // buyer minus renter net worth
+}
+
+// Comparison is the year-by-year comparison of buying and renting
+type Comparison struct {
+	Scenario       Scenario `json:"scenario"`
+	Loan           float64  `json:"loan"`
+	UpfrontCosts   float64  `json:"upfront_costs"` // down payment and purchase costs
+	MonthlyPayment float64  `json:"monthly_mortgage_payment"`
+	Years          []Year   `json:"years"`
+	// First year from which buying leaves more net worth, nil if it never does
+	// within the horizon
+	BreakEvenYear *int   `json:"break_even_year"`
+	Verdict       string `json:"verdict"` // "buy" or "rent" at the end of the horizon
+}
+
+// Compare works out the costs and net worth of buying and of renting, month by
+// month with an annuity mortgage. Both start with the money for the down
+// payment and purchase costs; the renter invests it. Each month the side with
+// the lower housing costs invests the difference.
+func Compare(s Scenario) (*Comparison, error) {
+	s = s.WithDefaults()
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+
+	price := s.PurchasePrice
+	loan := price * (1 - *s.DownPayment/100)
+	upfront := price - loan + price**s.PurchaseCosts/100
+	monthlyRate := *s.MortgageRate / 100 / 12
+	months := s.MortgageYears * 12
+	payment := loan / float64(months)
+	if monthlyRate != 0 {
+		payment = loan * monthlyRate / (1 - math.Pow(1+monthlyRate, -float64(months)))
+	}
+	investRate := math.Pow(1+*s.InvestmentReturn/100, 1.0/12) - 1
+
+	result := &Comparison{
+		Scenario:       s,
+		Loan:           round(loan),
+		UpfrontCosts:   round(upfront),
+		MonthlyPayment: round(payment),
+	}
+
+	balance := loan
+	homeValue := price
+	rent := s.MonthlyRent
+	buyerSavings, renterSavings := 0.0, upfront
+	for year := 1; year <= s.HorizonYears; year++ {
+		y := Year{Year: year}
+		for month := 0; month < 12; month++ {
+			var interest, principal float64
+			if balance > 0 {
+				interest = balance * monthlyRate
+				principal = math.Min(payment-interest, balance)
+				balance -= principal
+			}
+			deduction := interest * *s.InterestDeduction / 100
+			maintenance := homeValue * *s.Maintenance / 100 / 12
+			ownerCosts := homeValue * *s.OwnerCosts / 100 / 12
+			buying := interest + principal - deduction + maintenance + ownerCosts
+
+			y.MortgageInterest += interest
+			y.MortgagePrincipal += principal
+			y.InterestDeduction += deduction
+			y.Maintenance += maintenance
+			y.OwnerCosts += ownerCosts
+			y.BuyingCost += buying
+			y.Rent += rent
+
+			buyerSavings *= 1 + investRate
+			renterSavings *= 1 + investRate
+			if buying > rent {
+				renterSavings += buying - rent
+			} else {
+				buyerSavings += rent - buying
+			}
+		}
+		homeValue *= 1 + *s.Appreciation/100
+		rent *= 1 + *s.RentGrowth/100
+
+		buyerNetWorth := homeValue*(1-*s.SellingCosts/100) - balance + buyerSavings
+		y.MortgageInterest = round(y.MortgageInterest)
+		y.MortgagePrincipal = round(y.MortgagePrincipal)
+		y.InterestDeduction = round(y.InterestDeduction)
+		y.Maintenance = round(y.Maintenance)
+		y.OwnerCosts = round(y.OwnerCosts)
+		y.BuyingCost = round(y.BuyingCost)
+		y.Rent = round(y.Rent)
+		y.HomeValue = round(homeValue)
+		y.MortgageBalance = round(balance)
+		y.BuyerNetWorth = round(buyerNetWorth)
+		y.RenterNetWorth = round(renterSavings)
+		y.Difference = y.BuyerNetWorth - y.RenterNetWorth
+		result.Years = append(result.Years, y)
+
+		if y.Difference >= 0 && result.BreakEvenYear == nil {
+			breakEven := year
+			result.BreakEvenYear = &breakEven
+		} else if y.Difference < 0 {
+			result.BreakEvenYear = nil
+		}
+	}
+
+	result.Verdict = "rent"
+	if last := result.Years[len(result.Years)-1]; last.Difference >= 0 {
+		result.Verdict = "buy"
+	}
+	return result, nil
+}
+
+// round rounds an amount to whole currency units
+func round(amount float64) float64 {
+	return math.Round(amount)
+}
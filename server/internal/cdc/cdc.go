@@ -0,0 +1,210 @@
+// Package cdc mirrors property changes into an external database or stream
+// for analytical workloads. It tails the property_sync change log that also
+// serves offline clients, so it sees every insert, update and removal of a
+// property, coalesced per property between transfers.
+package cdc
+
+import (
+	"context"
+	"fundamental/server/internal/database"
+	"fundamental/server/internal/errorsink"
+	"fundamental/server/internal/models"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// pollInterval is the time between checks for new changes
+	pollInterval = 5 * time.Second
+
+	// batchSize is the number of changes sent to the sink at once
+	batchSize = 500
+)
+
+// Streamer sends new property changes to the configured sink in the
+// background
+type Streamer struct {
+	db     *database.Database
+	logger *logrus.Logger
+
+	// mu serializes transfers and configuration changes, so progress is never
+	// recorded for a sink that was just replaced
+	mu        sync.Mutex
+	sink      Sink
+	open      *models.CDCSink // configuration sink was opened with
+	lastError string          // logged once until a transfer succeeds
+
+	ctx      context.Context
+	cancel   context.CancelFunc
+	stopChan chan struct{}
+	wg       sync.WaitGroup
+}
+
+// NewStreamer creates a streamer; call Start to begin mirroring
+func NewStreamer(db *database.Database, logger *logrus.Logger) *Streamer {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Streamer{
+		db:       db,
+		logger:   errorsink.WithModule(logger, "cdc"),
+		ctx:      ctx,
+		cancel:   cancel,
+		stopChan: make(chan struct{}),
+	}
+}
+
+// Start checks for new changes every few seconds while the sink is enabled
+func (s *Streamer) Start() {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer errorsink.Recover("cdc")
+
+		ticker := time.NewTicker(pollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.stopChan:
+				return
+			case <-ticker.C:
+				s.transfer()
+			}
+		}
+	}()
+}
+
+// Stop waits for the running transfer and closes the sink
+func (s *Streamer) Stop() {
+	close(s.stopChan)
+	s.cancel()
+	s.wg.Wait()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closeSink()
+}
+
+// Configure stores a new sink configuration, which the next transfer picks up
+func (s *Streamer) Configure(req models.CDCSinkRequest) (*models.CDCSink, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	config, err := s.db.SaveCDCSink(req)
+	if err != nil {
+		return nil, err
+	}
+	s.closeSink()
+	s.lastError = ""
+	s.logger.WithFields(logrus.Fields{
+		"enabled": config.Enabled,
+		"kind":    config.Kind,
+		"target":  config.Target,
+	}).Info("Configured CDC sink")
+	return config, nil
+}
+
+// Status returns the sink configuration and how far it lags behind, or nil
+// when no sink is configured
+func (s *Streamer) Status() (*models.CDCStatus, error) {
+	config, err := s.db.GetCDCSink()
+	if err != nil || config == nil {
+		return nil, err
+	}
+	latest, pending, oldest, err := s.db.GetCDCLag(config.SentSeq)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &models.CDCStatus{Sink: config, LatestSeq: latest, PendingChanges: pending}
+	if oldest != nil {
+		status.LagSeconds = max(time.Since(*oldest).Round(time.Second).Seconds(), 0)
+	}
+	return status, nil
+}
+
+// closeSink closes the open sink, if any; the caller holds mu
+func (s *Streamer) closeSink() {
+	if s.sink == nil {
+		return
+	}
+	if err := s.sink.Close(); err != nil {
+		s.logger.WithError(err).Warn("Failed to close CDC sink")
+	}
+	s.sink, s.open = nil, nil
+}
+
+// transfer sends all changes after the stored cursor to the sink, in
+// batches, recording progress after each batch. A failed batch is sent again
+// by the next transfer.
+func (s *Streamer) transfer() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	config, err := s.db.GetCDCSink()
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to read CDC sink")
+		return
+	}
+	if config == nil || !config.Enabled {
+		s.closeSink()
+		return
+	}
+	if s.open == nil || s.open.Kind != config.Kind || s.open.URL != config.URL || s.open.Target != config.Target {
+		s.closeSink()
+		sink, err := NewSink(config)
+		if err != nil {
+			s.fail(err)
+			return
+		}
+		s.sink, s.open = sink, config
+	}
+
+	cursor := config.SentSeq
+	for {
+		batch, err := s.db.GetPropertyChanges(cursor, nil, batchSize)
+		if err != nil {
+			s.logger.WithError(err).Error("Failed to read property changes for CDC")
+			return
+		}
+		if batch.Cursor == cursor {
+			return
+		}
+		if err := s.sink.Write(s.ctx, batch); err != nil {
+			if s.ctx.Err() == nil {
+				s.fail(err)
+			}
+			return
+		}
+		if err := s.db.RecordCDCProgress(batch.Cursor); err != nil {
+			s.logger.WithError(err).Error("Failed to record CDC progress")
+			return
+		}
+		s.lastError = ""
+		s.logger.WithFields(logrus.Fields{
+			"cursor":  batch.Cursor,
+			"changed": len(batch.Properties),
+			"deleted": len(batch.Deleted),
+		}).Debug("Sent property changes to CDC sink")
+		cursor = batch.Cursor
+		if !batch.HasMore {
+			return
+		}
+	}
+}
+
+// fail logs and records an error of the sink. The sink is reopened on the
+// next transfer, in case its connection broke. A sink that keeps failing the
+// same way is logged at debug level after the first time.
+func (s *Streamer) fail(err error) {
+	if err.Error() == s.lastError {
+		s.logger.WithError(err).Debug("CDC transfer failed again")
+	} else {
+		s.logger.WithError(err).Error("CDC transfer failed")
+		s.lastError = err.Error()
+	}
+	if err := s.db.RecordCDCError(err.Error()); err != nil {
+		s.logger.WithError(err).Error("Failed to record CDC error")
+	}
+	s.closeSink()
+}
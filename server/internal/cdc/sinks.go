@@ -0,0 +1,363 @@
+package cdc
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"fundamental/server/internal/models"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "github.com/lib/pq"
+)
+
+// Sink mirrors batches of property changes into an external system. Writes
+// are idempotent, so a batch can be sent again after a failure.
+type Sink interface {
+	Write(ctx context.Context, batch *models.SyncBatch) error
+	Close() error
+}
+
+// tableRegex matches a table name, optionally schema-qualified, and
+// topicRegex a Kafka topic name
+var (
+	tableRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+	topicRegex = regexp.MustCompile(`^[A-Za-z0-9._-]{1,249}$`)
+)
+
+// Validate checks a sink configuration without connecting to the sink. An
+// empty URL is accepted when a URL is stored already.
+func Validate(req models.CDCSinkRequest, hasURL bool) error {
+	switch req.Kind {
+	case models.CDCSinkPostgres, models.CDCSinkClickHouse:
+		if !tableRegex.MatchString(req.Target) {
+			return fmt.Errorf("target must be a table name such as properties or analytics.properties")
+		}
+	case models.CDCSinkKafka:
+		if !topicRegex.MatchString(req.Target) {
+			return fmt.Errorf("target must be a Kafka topic name")
+		}
+	default:
+		return fmt.Errorf("kind must be %s, %s or %s", models.CDCSinkPostgres, models.CDCSinkClickHouse, models.CDCSinkKafka)
+	}
+
+	if req.URL == "" {
+		if !hasURL {
+			return fmt.Errorf("url is required")
+		}
+		return nil
+	}
+	u, err := url.Parse(req.URL)
+	if err != nil || u.Host == "" {
+		return fmt.Errorf("url must be an absolute URL")
+	}
+	if req.Kind == models.CDCSinkPostgres && u.Scheme != "postgres" && u.Scheme != "postgresql" {
+		return fmt.Errorf("url must be a postgres:// connection string")
+	}
+	if req.Kind != models.CDCSinkPostgres && u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("url must be an http:// or https:// endpoint")
+	}
+	return nil
+}
+
+// MaskURL hides the password of a sink URL
+func MaskURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "••••"
+	}
+	return u.Redacted()
+}
+
+// NewSink opens the sink of a configuration
+func NewSink(config *models.CDCSink) (Sink, error) {
+	client := &http.Client{Timeout: 30 * time.Second}
+	switch config.Kind {
+	case models.CDCSinkPostgres:
+		db, err := sql.Open("postgres", config.URL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open PostgreSQL sink: %v", err)
+		}
+		return &postgresSink{db: db, table: config.Target}, nil
+	case models.CDCSinkClickHouse:
+		return &clickHouseSink{url: config.URL, table: config.Target, client: client}, nil
+	case models.CDCSinkKafka:
+		endpoint := strings.TrimRight(config.URL, "/") + "/topics/" + url.PathEscape(config.Target)
+		return &kafkaSink{endpoint: endpoint, client: client}, nil
+	}
+	return nil, fmt.Errorf("unknown CDC sink %q", config.Kind)
+}
+
+// column is a property column of the mirrored table
+type column struct {
+	name       string
+	postgres   string
+	clickHouse string
+	value      func(p *models.Property) interface{}
+}
+
+// optional returns nil for a zero time, so it is stored as null
+func optional(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+	return t
+}
+
+// columns are the property columns of the mirrored table after id. Both
+// sinks add deleted, version (the change cursor) and synced_at.
+var columns = []column{
+	{"url", "TEXT", "String", func(p *models.Property) interface{} { return p.URL }},
+	{"street", "TEXT", "String", func(p *models.Property) interface{} { return p.Street }},
+	{"neighborhood", "TEXT", "String", func(p *models.Property) interface{} { return p.Neighborhood }},
+	{"property_type", "TEXT", "String", func(p *models.Property) interface{} { return p.PropertyType }},
+	{"city", "TEXT", "String", func(p *models.Property) interface{} { return p.City }},
+	{"postal_code", "TEXT", "String", func(p *models.Property) interface{} { return p.PostalCode }},
+	{"district", "TEXT", "String", func(p *models.Property) interface{} { return p.District }},
+	{"price", "BIGINT", "Int64", func(p *models.Property) interface{} { return int64(p.Price) }},
+	{"year_built", "INTEGER", "Nullable(Int32)", func(p *models.Property) interface{} { return p.YearBuilt }},
+	{"living_area", "INTEGER", "Nullable(Int32)", func(p *models.Property) interface{} { return p.LivingArea }},
+	{"num_rooms", "INTEGER", "Nullable(Int32)", func(p *models.Property) interface{} { return p.NumRooms }},
+	{"status", "TEXT", "String", func(p *models.Property) interface{} { return p.Status }},
+	{"listing_date", "DATE", "Nullable(Date)", func(p *models.Property) interface{} { return optional(p.ListingDate) }},
+	{"selling_date", "DATE", "Nullable(Date)", func(p *models.Property) interface{} { return optional(p.SellingDate) }},
+	{"latitude", "DOUBLE PRECISION", "Nullable(Float64)", func(p *models.Property) interface{} { return p.Latitude }},
+	{"longitude", "DOUBLE PRECISION", "Nullable(Float64)", func(p *models.Property) interface{} { return p.Longitude }},
+	{"energy_label", "TEXT", "String", func(p *models.Property) interface{} { return p.EnergyLabel }},
+	{"delisting_reason", "TEXT", "String", func(p *models.Property) interface{} { return p.DelistingReason }},
+	{"scraped_at", "TIMESTAMP", "Nullable(DateTime)", func(p *models.Property) interface{} { return optional(p.ScrapedAt) }},
+}
+
+// postgresSink upserts changed properties into a table, creating it on first
+// use, and deletes removed ones
+type postgresSink struct {
+	db      *sql.DB
+	table   string
+	created bool
+}
+
+func (s *postgresSink) Write(ctx context.Context, batch *models.SyncBatch) error {
+	if !s.created {
+		definitions := []string{"id BIGINT PRIMARY KEY"}
+		for _, c := range columns {
+			definitions = append(definitions, c.name+" "+c.postgres)
+		}
+		definitions = append(definitions, "version BIGINT NOT NULL", "synced_at TIMESTAMP NOT NULL")
+		stmt := "CREATE TABLE IF NOT EXISTS " + s.table + " (" + strings.Join(definitions, ", ") + ")"
+		if _, err := s.db.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("failed to create sink table: %v", err)
+		}
+		s.created = true
+	}
+
+	names := []string{"id"}
+	placeholders := []string{"$1"}
+	updates := []string{}
+	for i, c := range columns {
+		names = append(names, c.name)
+		placeholders = append(placeholders, "$"+strconv.Itoa(i+2))
+		updates = append(updates, c.name+" = EXCLUDED."+c.name)
+	}
+	names = append(names, "version", "synced_at")
+	placeholders = append(placeholders, "$"+strconv.Itoa(len(columns)+2), "$"+strconv.Itoa(len(columns)+3))
+	updates = append(updates, "version = EXCLUDED.version", "synced_at = EXCLUDED.synced_at")
+	upsert := "INSERT INTO " + s.table + " (" + strings.Join(names, ", ") + ") VALUES (" +
+		strings.Join(placeholders, ", ") + ") ON CONFLICT (id) DO UPDATE SET " + strings.Join(updates, ", ")
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin sink transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+	for i := range batch.Properties {
+		p := &batch.Properties[i]
+		args := []interface{}{p.ID}
+		for _, c := range columns {
+			args = append(args, c.value(p))
+		}
+		args = append(args, batch.Cursor, now)
+		if _, err := tx.ExecContext(ctx, upsert, args...); err != nil {
+			return fmt.Errorf("failed to upsert property %d: %v", p.ID, err)
+		}
+	}
+	for _, id := range batch.Deleted {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM "+s.table+" WHERE id = $1", id); err != nil {
+			return fmt.Errorf("failed to delete property %d: %v", id, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit sink transaction: %v", err)
+	}
+	return nil
+}
+
+func (s *postgresSink) Close() error {
+	return s.db.Close()
+}
+
+// clickHouseSink inserts changes over the ClickHouse HTTP interface into a
+// ReplacingMergeTree table, creating it on first use. Every change is a new
+// row; the row with the highest version wins on merge, and removed
+// properties are rows with deleted = 1. Query with FINAL to see the current
+// state.
+type clickHouseSink struct {
+	url     string
+	table   string
+	client  *http.Client
+	created bool
+}
+
+// query runs a statement with an optional body of input rows
+func (s *clickHouseSink) query(ctx context.Context, statement string, body io.Reader) error {
+	u, err := url.Parse(s.url)
+	if err != nil {
+		return fmt.Errorf("invalid ClickHouse URL: %v", err)
+	}
+	params := u.Query()
+	params.Set("query", statement)
+	u.RawQuery = params.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), body)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %v", err)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach ClickHouse: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("ClickHouse answered %s: %s", resp.Status, strings.TrimSpace(string(message)))
+	}
+	return nil
+}
+
+func (s *clickHouseSink) Write(ctx context.Context, batch *models.SyncBatch) error {
+	if !s.created {
+		definitions := []string{"id Int64"}
+		for _, c := range columns {
+			definitions = append(definitions, c.name+" "+c.clickHouse)
+		}
+		definitions = append(definitions, "deleted UInt8", "version Int64", "synced_at DateTime")
+		stmt := "CREATE TABLE IF NOT EXISTS " + s.table + " (" + strings.Join(definitions, ", ") +
+			") ENGINE = ReplacingMergeTree(version) ORDER BY id"
+		if err := s.query(ctx, stmt, nil); err != nil {
+			return fmt.Errorf("failed to create sink table: %v", err)
+		}
+		s.created = true
+	}
+	if len(batch.Properties) == 0 && len(batch.Deleted) == 0 {
+		return nil
+	}
+
+	now := time.Now().UTC().Format(time.DateTime)
+	var body bytes.Buffer
+	encoder := json.NewEncoder(&body)
+	for i := range batch.Properties {
+		p := &batch.Properties[i]
+		row := map[string]interface{}{"id": p.ID, "deleted": 0, "version": batch.Cursor, "synced_at": now}
+		for _, c := range columns {
+			value := c.value(p)
+			if t, ok := value.(time.Time); ok {
+				if strings.Contains(c.clickHouse, "DateTime") {
+					value = t.UTC().Format(time.DateTime)
+				} else {
+					value = t.Format(time.DateOnly)
+				}
+			}
+			row[c.name] = value
+		}
+		if err := encoder.Encode(row); err != nil {
+			return fmt.Errorf("failed to encode property %d: %v", p.ID, err)
+		}
+	}
+	for _, id := range batch.Deleted {
+		row := map[string]interface{}{"id": id, "deleted": 1, "version": batch.Cursor, "synced_at": now}
+		if err := encoder.Encode(row); err != nil {
+			return fmt.Errorf("failed to encode deletion %d: %v", id, err)
+		}
+	}
+	return s.query(ctx, "INSERT INTO "+s.table+" FORMAT JSONEachRow", &body)
+}
+
+func (s *clickHouseSink) Close() error {
+	return nil
+}
+
+// kafkaSink produces changes to a topic through the v2 API of a Kafka REST
+// proxy. Records are keyed by property id; the value is the property as
+// returned by the API, or null for a removed property, so a compacted topic
+// keeps the current state.
+type kafkaSink struct {
+	endpoint string
+	client   *http.Client
+}
+
+type kafkaRecord struct {
+	Key   string           `json:"key"`
+	Value *models.Property `json:"value"`
+}
+
+func (s *kafkaSink) Write(ctx context.Context, batch *models.SyncBatch) error {
+	records := make([]kafkaRecord, 0, len(batch.Properties)+len(batch.Deleted))
+	for i := range batch.Properties {
+		records = append(records, kafkaRecord{Key: strconv.FormatInt(batch.Properties[i].ID, 10), Value: &batch.Properties[i]})
+	}
+	for _, id := range batch.Deleted {
+		records = append(records, kafkaRecord{Key: strconv.FormatInt(id, 10)})
+	}
+	if len(records) == 0 {
+		return nil
+	}
+
+	payload, err := json.Marshal(map[string]interface{}{"records": records})
+	if err != nil {
+		return fmt.Errorf("failed to encode records: %v", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/vnd.kafka.json.v2+json")
+	req.Header.Set("Accept", "application/vnd.kafka.v2+json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach Kafka REST proxy: %v", err)
+	}
+	defer resp.Body.Close()
+	message, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("Kafka REST proxy answered %s: %s", resp.Status, strings.TrimSpace(string(message)))
+	}
+
+	// The proxy answers 200 with a per-record error when a record is rejected
+	var result struct {
+		Offsets []struct {
+			ErrorCode *int   `json:"error_code"`
+			Error     string `json:"error"`
+		} `json:"offsets"`
+	}
+	if err := json.Unmarshal(message, &result); err == nil {
+		for _, offset := range result.Offsets {
+			if offset.ErrorCode != nil {
+				return fmt.Errorf("Kafka REST proxy rejected a record: %s", offset.Error)
+			}
+		}
+	}
+	return nil
+}
+
+func (s *kafkaSink) Close() error {
+	return nil
+}
@@ -0,0 +1,146 @@
+// Package country describes the housing markets the server can ingest: the
+// currency listings are priced in and how postal codes are written and grouped
+// into districts. Funda listings are Dutch; spiders for other portals set the
+// country of their items.
+package country
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Default is the country of items that do not name one
+const Default = "NL"
+
+// Country is a market with its postal code format
+type Country struct {
+	Code     string // ISO 3166-1 alpha-2
+	Name     string
+	Currency string // ISO 4217 code listings are priced in
+
+	postalCode *regexp.Regexp
+	canonical  func(match []string) string
+	district   func(postalCode string) string
+}
+
+var countries = map[string]*Country{
+	"NL": {
+		Code:     "NL",
+		Name:     "Netherlands",
+		Currency: "EUR",
+		// 1234 AB; the four digits are the district
+		postalCode: regexp.MustCompile(`^(\d{4})\s*([A-Za-z]{2})$`),
+		canonical:  func(m []string) string { return m[1] + " " + strings.ToUpper(m[2]) },
+		district:   dutchDistrict,
+	},
+	"BE": {
+		Code:     "BE",
+		Name:     "Belgium",
+		Currency: "EUR",
+		// 1000, optionally written B-1000; a code covers a municipality
+		postalCode: regexp.MustCompile(`^(?:[Bb]-?)?(\d{4})$`),
+		canonical:  func(m []string) string { return m[1] },
+		district:   prefixedDistrict("BE", 4),
+	},
+	"DE": {
+		Code:     "DE",
+		Name:     "Germany",
+		Currency: "EUR",
+		// 10115, optionally written D-10115; a code covers a few streets to a
+		// small town
+		postalCode: regexp.MustCompile(`^(?:[Dd]-?)?(\d{5})$`),
+		canonical:  func(m []string) string { return m[1] },
+		district:   prefixedDistrict("DE", 5),
+	},
+}
+
+// dutchDistrict returns the four digits of a Dutch postal code, or "" when it
+// does not start with four digits. Dutch districts carry no country prefix, as
+// they did before other countries were supported.
+func dutchDistrict(postalCode string) string {
+	if len(postalCode) < 4 || !allDigits(postalCode[:4]) {
+		return ""
+	}
+	return postalCode[:4]
+}
+
+// prefixedDistrict returns a district function taking the first digits of a
+// postal code, prefixed with the country code so districts of different
+// countries never share a name
+func prefixedDistrict(code string, digits int) func(string) string {
+	return func(postalCode string) string {
+		if len(postalCode) < digits || !allDigits(postalCode[:digits]) {
+			return ""
+		}
+		return code + "-" + postalCode[:digits]
+	}
+}
+
+func allDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Lookup returns the country with an ISO code, in any case
+func Lookup(code string) (*Country, bool) {
+	c, ok := countries[strings.ToUpper(strings.TrimSpace(code))]
+	return c, ok
+}
+
+// Get returns the country with an ISO code, or the default country for an
+// empty or unknown code
+func Get(code string) *Country {
+	if c, ok := Lookup(code); ok {
+		return c
+	}
+	return countries[Default]
+}
+
+// ValidDistrict reports whether a district name is one the district function
+// of a supported country returns, such as "1012" or "BE-1000"
+func ValidDistrict(district string) bool {
+	for code, c := range countries {
+		if c.district(strings.TrimPrefix(district, code+"-")) == district {
+			return true
+		}
+	}
+	return false
+}
+
+// Codes returns the supported country codes in alphabetical order
+func Codes() []string {
+	codes := make([]string, 0, len(countries))
+	for code := range countries {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+// ValidPostalCode reports whether a postal code is written in the format of
+// the country
+func (c *Country) ValidPostalCode(postalCode string) bool {
+	return c.postalCode.MatchString(strings.TrimSpace(postalCode))
+}
+
+// CanonicalPostalCode returns a postal code in the canonical format of the
+// country, such as "1234 AB" in the Netherlands; other values are returned
+// unchanged
+func (c *Country) CanonicalPostalCode(postalCode string) string {
+	match := c.postalCode.FindStringSubmatch(strings.TrimSpace(postalCode))
+	if match == nil {
+		return postalCode
+	}
+	return c.canonical(match)
+}
+
+// District returns the district a canonical postal code is grouped in, or ""
+// when it has none
+func (c *Country) District(postalCode string) string {
+	return c.district(postalCode)
+}
@@ -0,0 +1,205 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"fundamental/server/internal/models"
+	"math"
+	"sort"
+	"strings"
+)
+
+// agentUpsert stores the agent of a scraped item, keeping the known page URL
+// when the item has none
+const agentUpsert = `
+	INSERT INTO agents (name, url)
+	VALUES (?, ?)
+	ON CONFLICT (name) DO UPDATE SET
+		url = COALESCE(excluded.url, agents.url),
+		updated_at = CURRENT_TIMESTAMP
+	RETURNING id
+`
+
+// agentID stores the agent named by a scraped item and returns its id, or nil
+// when the item names no agent
+func (u *propertyUpserter) agentID(prop map[string]interface{}) (interface{}, error) {
+	name, _ := prop["agent_name"].(string)
+	name = strings.Join(strings.Fields(name), " ")
+	if name == "" {
+		return nil, nil
+	}
+	if id, ok := u.agentIDs[name]; ok {
+		return id, nil
+	}
+	var url sql.NullString
+	if s, _ := prop["agent_url"].(string); strings.HasPrefix(s, "https://") || strings.HasPrefix(s, "http://") {
+		url = sql.NullString{String: strings.TrimSpace(s), Valid: true}
+	}
+	var id int64
+	if err := u.agents.QueryRow(name, url).Scan(&id); err != nil {
+		return nil, fmt.Errorf("failed to upsert agent: %w", err)
+	}
+	u.agentIDs[name] = id
+	return id, nil
+}
+
+// agentListing is a listing of an agent with what its stats need
+type agentListing struct {
+	agentID    int64
+	status     string
+	district   string
+	livingArea sql.NullFloat64
+	firstPrice sql.NullFloat64
+	daysToSell sql.NullFloat64
+}
+
+// ListAgentStats returns all agents with the stats of their listings, the
+// agents with the most listings first
+func (d *Database) ListAgentStats() ([]models.AgentStats, error) {
+	return d.agentStats(0)
+}
+
+// GetAgentStats returns an agent with the stats of its listings, or nil if it
+// does not exist
+func (d *Database) GetAgentStats(id int64) (*models.AgentStats, error) {
+	stats, err := d.agentStats(id)
+	if err != nil || len(stats) == 0 {
+		return nil, err
+	}
+	return &stats[0], nil
+}
+
+// agentStats computes the stats of one agent, or of all agents when id is 0
+func (d *Database) agentStats(id int64) ([]models.AgentStats, error) {
+	filter, args := "", []interface{}{}
+	if id != 0 {
+		filter, args = " WHERE id = ?", []interface{}{id}
+	}
+	rows, err := d.db.Query("SELECT id, name, COALESCE(url, '') FROM agents"+filter, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query agents: %v", err)
+	}
+	stats := []models.AgentStats{}
+	for rows.Next() {
+		var s models.AgentStats
+		if err := rows.Scan(&s.ID, &s.Name, &s.URL); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan agent: %v", err)
+		}
+		stats = append(stats, s)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating agents: %v", err)
+	}
+	if len(stats) == 0 {
+		return stats, nil
+	}
+
+	filter = "p.agent_id IS NOT NULL"
+	if id != 0 {
+		filter = "p.agent_id = ?"
+	}
+	rows, err = d.db.Query(`
+		SELECT p.agent_id, COALESCE(p.status, ''), COALESCE(p.district, ''), p.living_area,
+		       COALESCE((SELECT h.price FROM property_history h
+		                 WHERE h.property_id = p.id AND h.price IS NOT NULL
+		                 ORDER BY h.id LIMIT 1), p.price),
+		       CASE WHEN p.status = 'sold' AND p.listing_date IS NOT NULL AND p.selling_date IS NOT NULL
+		                 AND `+exactSellingDate("p.")+`
+		            THEN `+d.dialect.DaysBetween("p.listing_date", "p.selling_date")+` END
+		FROM properties p
+		WHERE `+filter, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query agent listings: %v", err)
+	}
+	listings := make(map[int64][]agentListing)
+	for rows.Next() {
+		var l agentListing
+		if err := rows.Scan(&l.agentID, &l.status, &l.district, &l.livingArea, &l.firstPrice, &l.daysToSell); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan agent listing: %v", err)
+		}
+		listings[l.agentID] = append(listings[l.agentID], l)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating agent listings: %v", err)
+	}
+
+	// Median sold price per m² by district, nil when there are too few sales
+	districtMedians := make(map[string]*float64)
+	districtMedian := func(district string) (*float64, error) {
+		if m, ok := districtMedians[district]; ok {
+			return m, nil
+		}
+		sales, err := d.districtSales(district, 0)
+		if err != nil {
+			return nil, err
+		}
+		var m *float64
+		if len(sales) >= bidMinComparables {
+			perSqm := make([]float64, len(sales))
+			for i, s := range sales {
+				perSqm[i] = s.price / s.livingArea
+			}
+			value := median(perSqm)
+			m = &value
+		}
+		districtMedians[district] = m
+		return m, nil
+	}
+
+	for i := range stats {
+		s := &stats[i]
+		var days, pricing []float64
+		for _, l := range listings[s.ID] {
+			s.Listings++
+			switch l.status {
+			case "active", "republished":
+				s.Active++
+			case "sold":
+				s.Sold++
+			}
+			if l.daysToSell.Valid && l.daysToSell.Float64 >= 0 {
+				days = append(days, l.daysToSell.Float64)
+			}
+			if l.district == "" || !l.livingArea.Valid || l.livingArea.Float64 <= 0 ||
+				!l.firstPrice.Valid || l.firstPrice.Float64 <= 0 {
+				continue
+			}
+			districtPerSqm, err := districtMedian(l.district)
+			if err != nil {
+				return nil, err
+			}
+			if districtPerSqm != nil {
+				perSqm := l.firstPrice.Float64 / l.livingArea.Float64
+				pricing = append(pricing, (perSqm/(*districtPerSqm)-1)*100)
+			}
+		}
+		s.AvgDaysToSell = roundedMean(days)
+		s.AvgPricingVsDistrict = roundedMean(pricing)
+		s.PricedListings = len(pricing)
+	}
+
+	sort.SliceStable(stats, func(i, j int) bool {
+		if stats[i].Listings != stats[j].Listings {
+			return stats[i].Listings > stats[j].Listings
+		}
+		return stats[i].Name < stats[j].Name
+	})
+	return stats, nil
+}
+
+// roundedMean returns the mean of values to one decimal, or nil without values
+func roundedMean(values []float64) *float64 {
+	if len(values) == 0 {
+		return nil
+	}
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	mean := math.Round(sum/float64(len(values))*10) / 10
+	return &mean
+}
@@ -0,0 +1,202 @@
+package database
+
+import (
+	"fmt"
+	"fundamental/server/internal/models"
+	"strings"
+)
+
+// archiveBatchSize bounds the number of properties moved per statement
+const archiveBatchSize = 500
+
+// orderedColumns returns the column names of a table in table order
+func orderedColumns(q queryer, table string) ([]string, error) {
+	rows, err := q.Query(fmt.Sprintf("SELECT * FROM %s WHERE 1 = 0", table))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read columns of %s: %v", table, err)
+	}
+	defer rows.Close()
+	return rows.Columns()
+}
+
+// moveRows copies the rows of from matching condition into to and deletes them
+// from from. Only the columns both tables have are copied; extra is appended
+// to the column list with its value expression, e.g. archived_at.
+func moveRows(tx *sqlTx, from, to, condition string, args []interface{}, extra map[string]string) (int64, error) {
+	fromColumns, err := orderedColumns(tx, from)
+	if err != nil {
+		return 0, err
+	}
+	toColumns, err := tableColumns(tx, to)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read columns of %s: %v", to, err)
+	}
+	var columns, values []string
+	for _, column := range fromColumns {
+		if toColumns[column] {
+			columns = append(columns, column)
+			values = append(values, column)
+		}
+	}
+	for column, value := range extra {
+		columns = append(columns, column)
+		values = append(values, value)
+	}
+
+	_, err = tx.Exec(fmt.Sprintf("INSERT INTO %s (%s) SELECT %s FROM %s WHERE %s",
+		to, strings.Join(columns, ", "), strings.Join(values, ", "), from, condition), args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to copy %s rows to %s: %v", from, to, err)
+	}
+	result, err := tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE %s", from, condition), args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete moved %s rows: %v", from, err)
+	}
+	return result.RowsAffected()
+}
+
+// archiveProperties moves properties and their history to the archive tables.
+// Snapshots, photos and watch alerts of the properties are deleted.
+func archiveProperties(tx *sqlTx, ids []interface{}) (int64, error) {
+	in := "(?" + strings.Repeat(", ?", len(ids)-1) + ")"
+	if _, err := moveRows(tx, "property_history", "property_history_archive", "property_id IN "+in, ids, nil); err != nil {
+		return 0, err
+	}
+	if _, err := tx.Exec("DELETE FROM property_snapshots WHERE property_id IN "+in, ids...); err != nil {
+		return 0, fmt.Errorf("failed to delete snapshots of archived properties: %v", err)
+	}
+	if _, err := tx.Exec("DELETE FROM property_images WHERE property_id IN "+in, ids...); err != nil {
+		return 0, fmt.Errorf("failed to delete images of archived properties: %v", err)
+	}
+	if _, err := tx.Exec("DELETE FROM property_scores WHERE property_id IN "+in, ids...); err != nil {
+		return 0, fmt.Errorf("failed to delete scores of archived properties: %v", err)
+	}
+	args := append(append([]interface{}{}, ids...), ids...)
+	if _, err := tx.Exec("DELETE FROM watch_alerts WHERE property_id IN "+in+" OR comparable_id IN "+in, args...); err != nil {
+		return 0, fmt.Errorf("failed to delete watch alerts of archived properties: %v", err)
+	}
+	return moveRows(tx, "properties", "properties_archive", "id IN "+in, ids,
+		map[string]string{"archived_at": "CURRENT_TIMESTAMP"})
+}
+
+// restoreArchived moves the archived properties matching condition, and their
+// history, back into properties
+func restoreArchived(tx *sqlTx, condition string, args ...interface{}) error {
+	ids, err := queryIDs(tx, "SELECT id FROM properties_archive WHERE "+condition+" ORDER BY id", args...)
+	if err != nil {
+		return fmt.Errorf("failed to find archived properties: %v", err)
+	}
+	for start := 0; start < len(ids); start += archiveBatchSize {
+		batch := ids[start:min(start+archiveBatchSize, len(ids))]
+		in := "(?" + strings.Repeat(", ?", len(batch)-1) + ")"
+		// The properties go first, the history references them
+		if _, err := moveRows(tx, "properties_archive", "properties", "id IN "+in, batch, nil); err != nil {
+			return err
+		}
+		if _, err := moveRows(tx, "property_history_archive", "property_history", "property_id IN "+in, batch, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// queryIDs returns the ids selected by query
+func queryIDs(tx *sqlTx, query string, args ...interface{}) ([]interface{}, error) {
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []interface{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// ArchiveStaleProperties moves properties that are inactive and have not been
+// updated for olderThanDays days, with their history, to properties_archive and
+// property_history_archive. Watched and tagged properties stay. It returns
+// the number of archived properties.
+func (d *Database) ArchiveStaleProperties(olderThanDays int) (int64, error) {
+	if olderThanDays <= 0 {
+		return 0, fmt.Errorf("archive threshold must be a positive number of days")
+	}
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	ids, err := queryIDs(tx, `
+		SELECT id FROM properties
+		WHERE status = 'inactive'
+		AND updated_at < `+d.dialect.TimestampOffset()+`
+		AND id NOT IN (SELECT property_id FROM watchlist_properties)
+		AND id NOT IN (SELECT property_id FROM property_tags)
+		ORDER BY id
+	`, fmt.Sprintf("-%d days", olderThanDays))
+	if err != nil {
+		return 0, fmt.Errorf("failed to find stale properties: %v", err)
+	}
+
+	var archived int64
+	for start := 0; start < len(ids); start += archiveBatchSize {
+		n, err := archiveProperties(tx, ids[start:min(start+archiveBatchSize, len(ids))])
+		if err != nil {
+			return 0, err
+		}
+		archived += n
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return archived, nil
+}
+
+// unarchiveURLs moves archived properties back into properties when their
+// listings are scraped again, so they keep their id and history and are
+// detected as republished
+func unarchiveURLs(tx *sqlTx, urls []interface{}) error {
+	for start := 0; start < len(urls); start += archiveBatchSize {
+		batch := urls[start:min(start+archiveBatchSize, len(urls))]
+		if err := restoreArchived(tx, "url IN (?"+strings.Repeat(", ?", len(batch)-1)+")", batch...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SearchArchivedProperties matches archived properties by street,
+// neighborhood, postal code or city, newest first
+func (d *Database) SearchArchivedProperties(query string, limit int) ([]models.Property, error) {
+	properties := []models.Property{}
+	terms := searchTerms(query)
+	if len(terms) == 0 || limit <= 0 {
+		return properties, nil
+	}
+
+	condition, args := searchLikeCondition(terms)
+	args = append(args, limit)
+	err := d.forEachProperty(`
+		SELECT `+propertyColumns+`
+		FROM properties_archive
+		WHERE `+condition+`
+		ORDER BY id DESC
+		LIMIT ?
+	`, args, func(p models.Property) error {
+		p.Archived = true
+		properties = append(properties, p)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to search archived properties: %v", err)
+	}
+	return properties, nil
+}
@@ -0,0 +1,51 @@
+package database
+
+import "time"
+
+// propertiesAsOfColumns are the columns of the properties table a reconstructed
+// state provides, see propertiesAsOf
+const propertiesAsOfColumns = "id, city, postal_code, district, living_area, listing_date, selling_date, selling_date_precision, delisted_at"
+
+// propertiesAsOf returns the table the stats queries read properties from,
+// with the arguments to bind in its place. Without asOf that is the properties
+// table itself. With an asOf date (YYYY-MM-DD) it reconstructs the properties
+// as they stood at the end of that day from the property history, archive
+// included: only properties with a history entry by then, at the status and
+// price of their last entry. Delisting is not written to the history, so an
+// active property counts as inactive from its delisted_at.
+func propertiesAsOf(asOf string) (string, []interface{}, error) {
+	if asOf == "" {
+		return "properties", nil, nil
+	}
+	day, err := time.Parse("2006-01-02", asOf)
+	if err != nil {
+		return "", nil, errorf(ErrValidation, "invalid asOf date %q, expected YYYY-MM-DD", asOf)
+	}
+	until := day.AddDate(0, 0, 1).Format("2006-01-02")
+
+	return `(
+            SELECT p.id, p.city, p.postal_code, p.district, p.living_area, p.listing_date,
+                   CASE WHEN h.status IN ('active', 'republished') AND p.delisted_at < ?
+                        THEN 'inactive' ELSE h.status END AS status,
+                   h.price,
+                   CASE WHEN h.status = 'sold' THEN p.selling_date END AS selling_date,
+                   p.selling_date_precision,
+                   h.created_at AS scraped_at
+            FROM (
+                SELECT ` + propertiesAsOfColumns + ` FROM properties
+                UNION ALL
+                SELECT ` + propertiesAsOfColumns + ` FROM properties_archive
+            ) p
+            JOIN (
+                SELECT property_id, status, price, created_at,
+                       ROW_NUMBER() OVER (PARTITION BY property_id ORDER BY id DESC) AS row_num
+                FROM (
+                    SELECT id, property_id, status, price, created_at
+                    FROM property_history WHERE created_at < ?
+                    UNION ALL
+                    SELECT id, property_id, status, price, created_at
+                    FROM property_history_archive WHERE created_at < ?
+                ) history
+            ) h ON h.property_id = p.id AND h.row_num = 1
+        ) AS properties`, []interface{}{until, until, until}, nil
+}
@@ -0,0 +1,130 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"fundamental/server/internal/models"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// auditInsert stores a changed field in the audit log, see propertyUpserter
+const auditInsert = `
+	INSERT INTO audit_log (property_id, field, old_value, new_value, source, run_id, changed_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?)
+`
+
+// auditValue returns the text form of a stored or scraped field value, so that
+// 450000, 450000.0 and "450000" compare equal for numeric columns. A living
+// area of 0 is stored as NULL and audited as such.
+func auditValue(field string, value interface{}) sql.NullString {
+	switch field {
+	case "price", "year_built", "living_area", "num_rooms":
+		n := historyPrice(value)
+		if !n.Valid || (field == "living_area" && n.Int64 <= 0) {
+			return sql.NullString{}
+		}
+		return sql.NullString{String: strconv.FormatInt(n.Int64, 10), Valid: true}
+	}
+
+	switch v := value.(type) {
+	case nil:
+		return sql.NullString{}
+	case string:
+		return sql.NullString{String: v, Valid: true}
+	case []byte:
+		return sql.NullString{String: string(v), Valid: true}
+	case time.Time:
+		return sql.NullString{String: v.UTC().Format(time.RFC3339), Valid: true}
+	case float64:
+		return sql.NullString{String: strconv.FormatFloat(v, 'f', -1, 64), Valid: true}
+	default:
+		return sql.NullString{String: fmt.Sprint(v), Valid: true}
+	}
+}
+
+// audit records the scraped fields of a stored property that an item changed
+func (u *propertyUpserter) audit(propertyID int64, prop map[string]interface{}, stored *storedListing, values map[string]interface{}) error {
+	source, runID, _ := itemProvenance(prop)
+	now := time.Now().UTC()
+	for _, field := range scrapedFields {
+		before := auditValue(field, stored.fields[field])
+		after := auditValue(field, values[field])
+		if before == after {
+			continue
+		}
+		_, err := u.audits.Exec(propertyID, field, before, after, source,
+			sql.NullString{String: runID, Valid: runID != ""}, now)
+		if err != nil {
+			return fmt.Errorf("failed to insert audit log entry: %w", err)
+		}
+	}
+	return nil
+}
+
+// ListAuditLog returns the audit log entries matching the filter, newest first
+func (d *Database) ListAuditLog(filter models.AuditFilter) ([]models.AuditEntry, error) {
+	var conditions []string
+	var args []interface{}
+	if filter.PropertyID > 0 {
+		conditions = append(conditions, "property_id = ?")
+		args = append(args, filter.PropertyID)
+	}
+	if filter.Field != "" {
+		conditions = append(conditions, "field = ?")
+		args = append(args, filter.Field)
+	}
+	if filter.Source != "" {
+		conditions = append(conditions, "source = ?")
+		args = append(args, filter.Source)
+	}
+	if filter.RunID != "" {
+		conditions = append(conditions, "run_id = ?")
+		args = append(args, filter.RunID)
+	}
+	if filter.Since != nil {
+		conditions = append(conditions, "changed_at >= ?")
+		args = append(args, filter.Since.UTC())
+	}
+	if filter.BeforeID > 0 {
+		conditions = append(conditions, "id < ?")
+		args = append(args, filter.BeforeID)
+	}
+
+	query := `
+		SELECT id, property_id, field, old_value, new_value, COALESCE(source, ''), COALESCE(run_id, ''), changed_at
+		FROM audit_log`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY id DESC LIMIT ?"
+	args = append(args, filter.Limit)
+
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query audit log: %v", err)
+	}
+	defer rows.Close()
+
+	entries := []models.AuditEntry{}
+	for rows.Next() {
+		var e models.AuditEntry
+		var oldValue, newValue sql.NullString
+		if err := rows.Scan(&e.ID, &e.PropertyID, &e.Field, &oldValue, &newValue,
+			&e.Source, &e.RunID, &e.ChangedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan audit log entry: %v", err)
+		}
+		if oldValue.Valid {
+			e.OldValue = &oldValue.String
+		}
+		if newValue.Valid {
+			e.NewValue = &newValue.String
+		}
+		entries = append(entries, e)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating audit log: %v", err)
+	}
+	return entries, nil
+}
@@ -0,0 +1,115 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+var errBackupUnsupported = errors.New("backups are only supported for SQLite databases; use pg_dump for PostgreSQL")
+
+// Backup writes a consistent copy of the database to path using the SQLite
+// online backup API; the database stays usable while it runs. An encrypted
+// database is copied with the same key. The copy is written next to path and
+// renamed when complete, so path never holds a partial backup.
+func (d *Database) Backup(path string) error {
+	if d.dialect.Name() != DriverSQLite {
+		return errBackupUnsupported
+	}
+
+	tmpPath := path + ".tmp"
+	os.Remove(tmpPath)
+	if err := d.backupTo(tmpPath); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to move backup into place: %v", err)
+	}
+	return nil
+}
+
+func (d *Database) backupTo(path string) error {
+	ctx := context.Background()
+	dest := newSQLiteDB(path, Options{Key: d.key})
+	defer dest.Close()
+
+	destConn, err := dest.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create backup file: %v", err)
+	}
+	defer destConn.Close()
+	srcConn, err := d.db.DB.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to open database for backup: %v", err)
+	}
+	defer srcConn.Close()
+
+	err = destConn.Raw(func(destRaw interface{}) error {
+		return srcConn.Raw(func(srcRaw interface{}) error {
+			backup, err := destRaw.(*sqlite3.SQLiteConn).Backup("main", srcRaw.(*sqlite3.SQLiteConn), "main")
+			if err != nil {
+				return fmt.Errorf("failed to start backup: %v", err)
+			}
+			// Copy all pages in one step: the source is read in a single
+			// transaction, so concurrent writes cannot restart the backup
+			if _, err := backup.Step(-1); err != nil {
+				backup.Finish()
+				return fmt.Errorf("failed to copy database: %v", err)
+			}
+			if err := backup.Finish(); err != nil {
+				return fmt.Errorf("failed to finish backup: %v", err)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return err
+	}
+	// The copy inherits WAL mode; a backup should be a single self-contained file
+	if _, err := destConn.ExecContext(ctx, "PRAGMA journal_mode = DELETE"); err != nil {
+		return fmt.Errorf("failed to finish backup: %v", err)
+	}
+	return nil
+}
+
+// ValidateBackup checks that the SQLite file at path is an intact FundaMental
+// database this version can run, and returns its schema version. key is the
+// SQLCipher key of an encrypted backup.
+func ValidateBackup(path, key string) (int, error) {
+	if _, err := os.Stat(path); err != nil {
+		return 0, fmt.Errorf("backup not found: %v", err)
+	}
+
+	db := newSQLiteDB("file:"+path+"?mode=ro", Options{Key: key})
+	defer db.Close()
+
+	var result string
+	if err := db.QueryRow("PRAGMA quick_check").Scan(&result); err != nil {
+		return 0, fmt.Errorf("failed to check backup: %v", err)
+	}
+	if result != "ok" {
+		return 0, fmt.Errorf("backup is corrupt: %s", result)
+	}
+
+	var version sql.NullInt64
+	if err := db.QueryRow("SELECT MAX(version) FROM schema_migrations").Scan(&version); err != nil {
+		return 0, fmt.Errorf("backup is not a FundaMental database: %v", err)
+	}
+	if !version.Valid {
+		return 0, fmt.Errorf("backup has no applied migrations")
+	}
+	if int(version.Int64) > SchemaVersion {
+		return 0, fmt.Errorf("backup has schema version %d, newer than this server's version %d", version.Int64, SchemaVersion)
+	}
+	var properties int
+	if err := db.QueryRow("SELECT COUNT(*) FROM properties").Scan(&properties); err != nil {
+		return 0, fmt.Errorf("backup has no properties table: %v", err)
+	}
+	return int(version.Int64), nil
+}
@@ -0,0 +1,269 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"fundamental/server/internal/models"
+	"math"
+	"sort"
+	"time"
+)
+
+const (
+	// bidMinComparables is the number of district sales an input of the bid
+	// advice needs before it is used
+	bidMinComparables = 5
+	// bidSimilarArea is the factor by which a comparable's living area may
+	// differ from the property's to count as similar
+	bidSimilarArea = 1.33
+)
+
+// districtSale is a home sold in the district over the past year
+type districtSale struct {
+	id         int64
+	price      float64
+	livingArea float64
+	firstPrice sql.NullFloat64
+	daysToSell sql.NullFloat64
+}
+
+// districtSales returns the homes sold in a district over the past year, except
+// the given property, with their first asking price and days to sell
+func (d *Database) districtSales(district string, exceptID int64) ([]districtSale, error) {
+	rows, err := d.db.Query(`
+		SELECT p.id, p.price, p.living_area,
+		       (SELECT h.price FROM property_history h
+		        WHERE h.property_id = p.id AND h.price IS NOT NULL
+		        ORDER BY h.id LIMIT 1),
+		       CASE WHEN p.listing_date IS NOT NULL AND `+exactSellingDate("p.")+`
+		            THEN `+d.dialect.DaysBetween("p.listing_date", "p.selling_date")+` END
+		FROM properties p
+		WHERE p.district = ?
+		AND p.status = 'sold'
+		AND p.id <> ?
+		AND p.price BETWEEN 50000 AND 10000000
+		AND p.living_area BETWEEN 15 AND 1000
+		AND p.selling_date >= `+d.dialect.DateOffset("-12 months")+`
+	`, district, exceptID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query district sales: %v", err)
+	}
+	defer rows.Close()
+
+	var sales []districtSale
+	for rows.Next() {
+		var s districtSale
+		if err := rows.Scan(&s.id, &s.price, &s.livingArea, &s.firstPrice, &s.daysToSell); err != nil {
+			return nil, fmt.Errorf("failed to scan district sale: %v", err)
+		}
+		sales = append(sales, s)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating district sales: %v", err)
+	}
+	return sales, nil
+}
+
+// median returns the median of values, sorting them
+func median(values []float64) float64 {
+	sort.Float64s(values)
+	return percentile(values, 0.5)
+}
+
+// estimateValue estimates the value of a home of the given living area from
+// the median price per m² of district sales, preferring homes of a similar
+// size. Returns 0 comparables when there are too few sales.
+func estimateValue(sales []districtSale, livingArea float64) (estimate int, comparables int) {
+	if livingArea <= 0 {
+		return 0, 0
+	}
+	var similar, all []float64
+	for _, s := range sales {
+		perSqm := s.price / s.livingArea
+		all = append(all, perSqm)
+		if s.livingArea >= livingArea/bidSimilarArea && s.livingArea <= livingArea*bidSimilarArea {
+			similar = append(similar, perSqm)
+		}
+	}
+	used := similar
+	if len(used) < bidMinComparables {
+		used = all
+	}
+	if len(used) < bidMinComparables {
+		return 0, 0
+	}
+	return int(math.Round(median(used) * livingArea)), len(used)
+}
+
+// EstimateDistrictPrice estimates the price of a home of the given living area
+// in a district from the sales of the past year. Returns 0 comparables when
+// there are too few sales.
+func (d *Database) EstimateDistrictPrice(district string, livingArea float64) (estimate int, comparables int, err error) {
+	sales, err := d.districtSales(district, 0)
+	if err != nil {
+		return 0, 0, err
+	}
+	estimate, comparables = estimateValue(sales, livingArea)
+	return estimate, comparables, nil
+}
+
+// GetBidAdvice suggests a bid range for a property, or returns nil if it does
+// not exist. A property without an asking price gets no range.
+func (d *Database) GetBidAdvice(propertyID int64) (*models.BidAdvice, error) {
+	var property *models.Property
+	err := d.forEachProperty(`
+		SELECT `+propertyColumns+`
+		FROM properties
+		WHERE id = ?
+	`, []interface{}{propertyID}, func(p models.Property) error {
+		property = &p
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get property: %v", err)
+	}
+	if property == nil {
+		return nil, nil
+	}
+
+	advice := &models.BidAdvice{
+		PropertyID:  property.ID,
+		AskingPrice: property.Price,
+		Factors:     []models.BidAdviceFactor{},
+	}
+	if property.Price <= 0 {
+		return advice, nil
+	}
+
+	// Republished under the same URL, or relisted under a new one
+	var republished, earlierListings int
+	err = d.db.QueryRow(`
+		SELECT COALESCE(republish_count, 0),
+		       (SELECT COUNT(*) FROM properties earlier
+		        WHERE (earlier.id = properties.canonical_id OR earlier.canonical_id = properties.canonical_id)
+		        AND earlier.id < properties.id)
+		FROM properties
+		WHERE id = ?
+	`, propertyID).Scan(&republished, &earlierListings)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get listing history: %v", err)
+	}
+	advice.TimesRelisted = republished + earlierListings
+
+	var sales []districtSale
+	if property.District != "" {
+		if sales, err = d.districtSales(property.District, property.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	if property.LivingArea != nil {
+		if estimate, comparables := estimateValue(sales, float64(*property.LivingArea)); comparables > 0 {
+			advice.Estimate = &estimate
+			advice.Comparables = comparables
+		}
+	}
+
+	// How far sellers in the district moved from their first asking price
+	var changes, daysToSell []float64
+	for _, s := range sales {
+		if s.firstPrice.Valid && s.firstPrice.Float64 > 0 {
+			changes = append(changes, (s.price/s.firstPrice.Float64-1)*100)
+		}
+		if s.daysToSell.Valid && s.daysToSell.Float64 >= 0 {
+			daysToSell = append(daysToSell, s.daysToSell.Float64)
+		}
+	}
+	if len(changes) >= bidMinComparables {
+		change := math.Round(median(changes)*10) / 10
+		advice.DistrictPriceChange = &change
+	}
+	if len(daysToSell) >= bidMinComparables {
+		days := math.Round(median(daysToSell))
+		advice.DistrictMedianDaysToSell = &days
+	}
+
+	if !property.ListingDate.IsZero() {
+		end := time.Now().UTC()
+		if property.Status == "sold" && !property.SellingDate.IsZero() {
+			end = property.SellingDate
+		}
+		if days := int(end.Sub(property.ListingDate).Hours() / 24); days >= 0 {
+			advice.DaysOnMarket = &days
+		}
+	}
+
+	adviseBid(advice)
+	return advice, nil
+}
+
+// clamp limits value to the range [low, high]
+func clamp(value, low, high float64) float64 {
+	return math.Max(low, math.Min(high, value))
+}
+
+// adviseBid turns the inputs of the advice into factors and a bid range
+// around the asking price moved by their adjustments
+func adviseBid(advice *models.BidAdvice) {
+	asking := float64(advice.AskingPrice)
+
+	if advice.Estimate != nil {
+		// Meet the estimate halfway, the asking price carries information too
+		gap := (float64(*advice.Estimate)/asking - 1) * 100
+		advice.Factors = append(advice.Factors, models.BidAdviceFactor{
+			Factor:     models.BidFactorValuation,
+			Adjustment: clamp(gap/2, -10, 10),
+			Detail:     fmt.Sprintf("Estimated value from %d comparable sales in the district", advice.Comparables),
+		})
+	}
+	if advice.DistrictPriceChange != nil {
+		advice.Factors = append(advice.Factors, models.BidAdviceFactor{
+			Factor:     models.BidFactorDistrictPrice,
+			Adjustment: clamp(*advice.DistrictPriceChange, -10, 5),
+			Detail:     "Median change of the asking price before homes in the district sold",
+		})
+	}
+	if advice.DaysOnMarket != nil && advice.DistrictMedianDaysToSell != nil && *advice.DistrictMedianDaysToSell > 0 {
+		ratio := float64(*advice.DaysOnMarket) / *advice.DistrictMedianDaysToSell
+		if ratio >= 1 {
+			adjustment, detail := -2.0, "On the market longer than homes in the district take to sell"
+			if ratio >= 2 {
+				adjustment, detail = -4.0, "On the market over twice as long as homes in the district take to sell"
+			}
+			advice.Factors = append(advice.Factors, models.BidAdviceFactor{
+				Factor:     models.BidFactorDaysOnMarket,
+				Adjustment: adjustment,
+				Detail:     detail,
+			})
+		}
+	}
+	if advice.TimesRelisted > 0 {
+		advice.Factors = append(advice.Factors, models.BidAdviceFactor{
+			Factor:     models.BidFactorRelisted,
+			Adjustment: -math.Min(float64(advice.TimesRelisted), 3),
+			Detail:     fmt.Sprintf("Republished or relisted %d times", advice.TimesRelisted),
+		})
+	}
+
+	var total float64
+	for i := range advice.Factors {
+		advice.Factors[i].Adjustment = math.Round(advice.Factors[i].Adjustment*10) / 10
+		total += advice.Factors[i].Adjustment
+	}
+
+	// A range around the estimate is narrower the more comparables back it
+	spread := 5.0
+	advice.Confidence = "low"
+	if advice.Estimate != nil {
+		spread = 3
+		advice.Confidence = "medium"
+		if advice.Comparables >= 2*bidMinComparables && advice.DistrictPriceChange != nil {
+			spread = 2
+			advice.Confidence = "high"
+		}
+	}
+
+	target := asking * (1 + clamp(total, -20, 15)/100)
+	advice.BidLow = int(math.Round(target*(1-spread/100)/1000) * 1000)
+	advice.BidHigh = int(math.Round(target*(1+spread/100)/1000) * 1000)
+}
@@ -0,0 +1,103 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"fundamental/server/internal/models"
+	"time"
+)
+
+// GetCDCSink returns the change data capture sink, or nil if none is configured
+func (d *Database) GetCDCSink() (*models.CDCSink, error) {
+	var sink models.CDCSink
+	var lastSentAt sql.NullTime
+	var lastError sql.NullString
+	err := d.db.QueryRow(`
+		SELECT enabled, kind, url, target, sent_seq, last_sent_at, last_error, updated_at
+		FROM cdc_sink
+		WHERE id = 1
+	`).Scan(&sink.Enabled, &sink.Kind, &sink.URL, &sink.Target, &sink.SentSeq, &lastSentAt, &lastError, &sink.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get CDC sink: %v", err)
+	}
+	if lastSentAt.Valid {
+		sink.LastSentAt = &lastSentAt.Time
+	}
+	sink.LastError = lastError.String
+	return &sink, nil
+}
+
+// SaveCDCSink stores the change data capture sink. Pointing it at another
+// system, table or topic starts over with a full copy; an empty URL keeps the
+// stored one.
+func (d *Database) SaveCDCSink(req models.CDCSinkRequest) (*models.CDCSink, error) {
+	current, err := d.GetCDCSink()
+	if err != nil {
+		return nil, err
+	}
+	if req.URL == "" && current != nil {
+		req.URL = current.URL
+	}
+
+	if current != nil && current.Kind == req.Kind && current.URL == req.URL && current.Target == req.Target {
+		_, err = d.db.Exec("UPDATE cdc_sink SET enabled = ?, updated_at = CURRENT_TIMESTAMP WHERE id = 1", req.Enabled)
+	} else {
+		_, err = d.db.Exec(`
+			INSERT INTO cdc_sink (id, enabled, kind, url, target, sent_seq, updated_at)
+			VALUES (1, ?, ?, ?, ?, 0, CURRENT_TIMESTAMP)
+			ON CONFLICT (id) DO UPDATE SET
+				enabled = excluded.enabled, kind = excluded.kind, url = excluded.url,
+				target = excluded.target, sent_seq = 0, last_sent_at = NULL,
+				last_error = NULL, updated_at = excluded.updated_at
+		`, req.Enabled, req.Kind, req.URL, req.Target)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to save CDC sink: %v", err)
+	}
+	return d.GetCDCSink()
+}
+
+// RecordCDCProgress stores the property change cursor mirrored to the sink
+func (d *Database) RecordCDCProgress(seq int64) error {
+	_, err := d.db.Exec(`
+		UPDATE cdc_sink
+		SET sent_seq = ?, last_sent_at = CURRENT_TIMESTAMP, last_error = NULL
+		WHERE id = 1
+	`, seq)
+	if err != nil {
+		return fmt.Errorf("failed to record CDC progress: %v", err)
+	}
+	return nil
+}
+
+// RecordCDCError stores the error that stopped the last transfer to the sink
+func (d *Database) RecordCDCError(message string) error {
+	if _, err := d.db.Exec("UPDATE cdc_sink SET last_error = ? WHERE id = 1", message); err != nil {
+		return fmt.Errorf("failed to record CDC error: %v", err)
+	}
+	return nil
+}
+
+// GetCDCLag returns the newest property change cursor, the number of changes
+// after sentSeq and when the oldest of them was made (nil when there are none)
+func (d *Database) GetCDCLag(sentSeq int64) (latest int64, pending int, oldest *time.Time, err error) {
+	var latestSeq sql.NullInt64
+	err = d.db.QueryRow("SELECT MAX(seq), COUNT(CASE WHEN seq > ? THEN 1 END) FROM property_sync", sentSeq).
+		Scan(&latestSeq, &pending)
+	if err != nil {
+		return 0, 0, nil, fmt.Errorf("failed to count pending changes: %v", err)
+	}
+	if pending == 0 {
+		return latestSeq.Int64, 0, nil, nil
+	}
+
+	var changedAt time.Time
+	err = d.db.QueryRow("SELECT changed_at FROM property_sync WHERE seq > ? ORDER BY seq LIMIT 1", sentSeq).Scan(&changedAt)
+	if err != nil {
+		return 0, 0, nil, fmt.Errorf("failed to get oldest pending change: %v", err)
+	}
+	return latestSeq.Int64, pending, &changedAt, nil
+}
@@ -0,0 +1,22 @@
+//go:build !sqlcipher
+
+package database
+
+import (
+	"database/sql"
+	"errors"
+)
+
+// CipherSupported reports whether this binary was built with SQLCipher support
+const CipherSupported = false
+
+var errCipherUnsupported = errors.New("database encryption requires a build with -tags sqlcipher linked against SQLCipher")
+
+func openEncrypted(path string, opts Options) (*sql.DB, error) {
+	return nil, errCipherUnsupported
+}
+
+// EncryptDatabase is unavailable without SQLCipher support
+func EncryptDatabase(plainPath, encryptedPath, key string) error {
+	return errCipherUnsupported
+}
@@ -0,0 +1,68 @@
+//go:build sqlcipher
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// CipherSupported reports whether this binary was built with SQLCipher support
+const CipherSupported = true
+
+// verifyCipher checks the linked SQLite library is SQLCipher and the key unlocks the database
+func verifyCipher(db *sql.DB) error {
+	var version string
+	if err := db.QueryRow("PRAGMA cipher_version").Scan(&version); err != nil || version == "" {
+		return fmt.Errorf("the linked SQLite library is not SQLCipher; see the README for build instructions")
+	}
+	// Reading the schema fails when the key is wrong
+	if _, err := db.Exec("SELECT COUNT(*) FROM sqlite_master"); err != nil {
+		return fmt.Errorf("failed to unlock encrypted database, check the encryption key: %v", err)
+	}
+	return nil
+}
+
+func openEncrypted(path string, opts Options) (*sql.DB, error) {
+	db := newSQLiteDB(path, opts)
+	if err := verifyCipher(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// EncryptDatabase writes an encrypted copy of the plain SQLite database at
+// plainPath to encryptedPath using sqlcipher_export
+func EncryptDatabase(plainPath, encryptedPath, key string) error {
+	if key == "" {
+		return fmt.Errorf("an encryption key is required")
+	}
+
+	db := newSQLiteDB(plainPath, Options{})
+	defer db.Close()
+	if err := verifyCipher(db); err != nil {
+		return err
+	}
+
+	// ATTACH and the export must run on the same connection
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to open plain database: %v", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "ATTACH DATABASE ? AS encrypted KEY ?", encryptedPath, key); err != nil {
+		return fmt.Errorf("failed to create encrypted database: %v", err)
+	}
+	if _, err := conn.ExecContext(ctx, "SELECT sqlcipher_export('encrypted')"); err != nil {
+		conn.ExecContext(ctx, "DETACH DATABASE encrypted")
+		return fmt.Errorf("failed to export to encrypted database: %v", err)
+	}
+	if _, err := conn.ExecContext(ctx, "DETACH DATABASE encrypted"); err != nil {
+		return fmt.Errorf("failed to detach encrypted database: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// sqlDB wraps *sql.DB so every query is rebound for the active dialect and
+// runs on ctx, limited to timeout when it is not zero
+type sqlDB struct {
+	*sql.DB
+	dialect Dialect
+	ctx     context.Context
+	timeout time.Duration
+}
+
+// withTimeout returns the context of a query that completes within the call
+func (db *sqlDB) withTimeout() (context.Context, context.CancelFunc) {
+	if db.timeout <= 0 {
+		return db.ctx, func() {}
+	}
+	return context.WithTimeout(db.ctx, db.timeout)
+}
+
+// rowsContext returns the context of a query whose rows are read after the
+// call returns. Cancelling it would close the rows, so it is left to expire:
+// its timer is released at the deadline or when the bound context, such as
+// that of the request, ends.
+func (db *sqlDB) rowsContext() context.Context {
+	if db.timeout <= 0 {
+		return db.ctx
+	}
+	ctx, cancel := context.WithTimeout(db.ctx, db.timeout)
+	_ = cancel
+	return ctx
+}
+
+func (db *sqlDB) Exec(query string, args ...interface{}) (sql.Result, error) {
+	ctx, cancel := db.withTimeout()
+	defer cancel()
+	return db.DB.ExecContext(ctx, db.dialect.Rebind(query), args...)
+}
+
+func (db *sqlDB) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return db.DB.QueryContext(db.rowsContext(), db.dialect.Rebind(query), args...)
+}
+
+// QueryContext runs a query on ctx without the query timeout, for results that
+// are streamed for as long as the caller reads them
+func (db *sqlDB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return db.DB.QueryContext(ctx, db.dialect.Rebind(query), args...)
+}
+
+func (db *sqlDB) QueryRow(query string, args ...interface{}) *sql.Row {
+	return db.DB.QueryRowContext(db.rowsContext(), db.dialect.Rebind(query), args...)
+}
+
+func (db *sqlDB) Prepare(query string) (*sql.Stmt, error) {
+	ctx, cancel := db.withTimeout()
+	defer cancel()
+	return db.DB.PrepareContext(ctx, db.dialect.Rebind(query))
+}
+
+// Begin starts a transaction on the bound context. The query timeout limits
+// the whole transaction, up to its Commit or Rollback.
+func (db *sqlDB) Begin() (*sqlTx, error) {
+	ctx, cancel := db.withTimeout()
+	tx, err := db.DB.BeginTx(ctx, nil)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	return &sqlTx{Tx: tx, dialect: db.dialect, cancel: cancel}, nil
+}
+
+// sqlTx wraps *sql.Tx so every query is rebound for the active dialect
+type sqlTx struct {
+	*sql.Tx
+	dialect Dialect
+	cancel  context.CancelFunc
+}
+
+func (tx *sqlTx) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return tx.Tx.Exec(tx.dialect.Rebind(query), args...)
+}
+
+func (tx *sqlTx) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return tx.Tx.Query(tx.dialect.Rebind(query), args...)
+}
+
+func (tx *sqlTx) QueryRow(query string, args ...interface{}) *sql.Row {
+	return tx.Tx.QueryRow(tx.dialect.Rebind(query), args...)
+}
+
+func (tx *sqlTx) Prepare(query string) (*sql.Stmt, error) {
+	return tx.Tx.Prepare(tx.dialect.Rebind(query))
+}
+
+func (tx *sqlTx) Commit() error {
+	defer tx.cancel()
+	return tx.Tx.Commit()
+}
+
+func (tx *sqlTx) Rollback() error {
+	defer tx.cancel()
+	return tx.Tx.Rollback()
+}
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
@@ -1,38 +1,177 @@
 package database
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
+	"fundamental/server/config"
+	"fundamental/server/internal/country"
 	"fundamental/server/internal/geocoding"
 	"fundamental/server/internal/models"
+	"sort"
 	"strings"
 	"time"
 
-	_ "github.com/mattn/go-sqlite3"
+	_ "github.com/lib/pq"
 )
 
 type Database struct {
-	db *sql.DB
+	db      *sqlDB
+	dialect Dialect
+	key     string // SQLCipher key, used for backups
+	replica *Database
+	stats   *statsCache
+	// districts caches the district medians of notifications across runs
+	districts *districtCache
+	// geocoding runs the geocoding passes one at a time
+	geocoding *geocodeRuns
+	// queryTimeout limits the queries of copies returned by WithContext
+	queryTimeout time.Duration
 }
 
+// NewDatabase opens the SQLite database at dbPath
 func NewDatabase(dbPath string) (*Database, error) {
-	db, err := sql.Open("sqlite3", dbPath)
+	return Open(DriverSQLite, dbPath)
+}
+
+// Open connects to a database using the given driver ("sqlite" or "postgres")
+// and data source name: a file path for SQLite, a connection string for PostgreSQL
+func Open(driver, dsn string) (*Database, error) {
+	return OpenWithOptions(driver, dsn, DefaultOptions())
+}
+
+// OpenFromConfig opens the database selected by the configuration. sqlitePath
+// is used when the driver is SQLite. A read replica is opened along with it
+// when configured: a read-only connection pool on the same SQLite file, or the
+// PostgreSQL replica at DatabaseReadURL.
+func OpenFromConfig(cfg *config.Config, sqlitePath string) (*Database, error) {
+	dialect, err := NewDialect(cfg.DatabaseDriver)
+	if err != nil {
+		return nil, err
+	}
+	dsn := sqlitePath
+	if dialect.Name() == DriverPostgres {
+		dsn = cfg.DatabaseURL
+	}
+	opts := Options{
+		Key:           cfg.DatabaseKey,
+		JournalMode:   cfg.DatabaseJournalMode,
+		BusyTimeout:   time.Duration(cfg.DatabaseBusyTimeoutMs) * time.Millisecond,
+		MaxOpenConns:  cfg.DatabaseMaxOpenConns,
+		MaxIdleConns:  cfg.DatabaseMaxIdleConns,
+		StatsCacheTTL: time.Duration(cfg.StatsCacheTTLSeconds) * time.Second,
+		QueryTimeout:  time.Duration(cfg.DatabaseQueryTimeoutSeconds) * time.Second,
+	}
+	db, err := OpenWithOptions(cfg.DatabaseDriver, dsn, opts)
 	if err != nil {
 		return nil, err
 	}
 
-	// Enable foreign keys
-	_, err = db.Exec("PRAGMA foreign_keys = ON")
+	replicaDSN := ""
+	if db.dialect.Name() == DriverPostgres {
+		replicaDSN = cfg.DatabaseReadURL
+	} else if cfg.DatabaseReadReplica {
+		replicaDSN = dsn
+	}
+	if replicaDSN != "" {
+		opts.ReadOnly = true
+		if db.replica, err = OpenWithOptions(cfg.DatabaseDriver, replicaDSN, opts); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to open read replica: %v", err)
+		}
+	}
+	return db, nil
+}
+
+// Reader returns the database to run read-only queries on: the read replica
+// when one is open, the database itself otherwise
+func (d *Database) Reader() *Database {
+	if d.replica != nil {
+		return d.replica
+	}
+	return d
+}
+
+// sqliteJournalModes lists the journal modes accepted by SQLite
+var sqliteJournalModes = map[string]bool{
+	"DELETE": true, "TRUNCATE": true, "PERSIST": true, "MEMORY": true, "WAL": true, "OFF": true,
+}
+
+// OpenWithOptions is like Open with explicit pool and connection settings
+func OpenWithOptions(driver, dsn string, opts Options) (*Database, error) {
+	dialect, err := NewDialect(driver)
 	if err != nil {
 		return nil, err
 	}
 
-	return &Database{db: db}, nil
+	var db *sql.DB
+	if dialect.Name() == DriverSQLite {
+		opts.JournalMode = strings.ToUpper(opts.JournalMode)
+		if opts.JournalMode != "" && !sqliteJournalModes[opts.JournalMode] {
+			return nil, fmt.Errorf("unsupported SQLite journal mode: %s", opts.JournalMode)
+		}
+		if opts.Key != "" {
+			db, err = openEncrypted(dsn, opts)
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			db = newSQLiteDB(dsn, opts)
+		}
+	} else {
+		if opts.Key != "" {
+			return nil, fmt.Errorf("encryption keys are only supported for SQLite databases")
+		}
+		db, err = sql.Open(dialect.DriverName(), dsn)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	db.SetMaxOpenConns(opts.MaxOpenConns)
+	db.SetMaxIdleConns(opts.MaxIdleConns)
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to connect to %s: %v", dialect.Name(), err)
+	}
+
+	return &Database{
+		db:           &sqlDB{DB: db, dialect: dialect, ctx: context.Background()},
+		dialect:      dialect,
+		key:          opts.Key,
+		stats:        newStatsCache(opts.StatsCacheTTL),
+		districts:    newDistrictCache(opts.StatsCacheTTL > 0),
+		geocoding:    &geocodeRuns{},
+		queryTimeout: opts.QueryTimeout,
+	}, nil
 }
 
-func (d *Database) GetAllProperties(startDate, endDate string, city string) ([]models.Property, error) {
-	query := `
-        SELECT 
+// WithContext returns a copy of the database whose queries run on ctx, each
+// limited to the configured query timeout, so they are cancelled when an API
+// request ends or takes too long. The copy shares the connections and caches
+// of d, and its read replica is bound to ctx as well. Queries on d itself, such
+// as those of migrations, backups and scheduled jobs, have no timeout.
+func (d *Database) WithContext(ctx context.Context) *Database {
+	bound := *d
+	conn := *d.db
+	conn.ctx = ctx
+	conn.timeout = d.queryTimeout
+	bound.db = &conn
+	if d.replica != nil {
+		bound.replica = d.replica.WithContext(ctx)
+	}
+	return &bound
+}
+
+// Dialect returns the SQL dialect of the underlying database
+func (d *Database) Dialect() Dialect {
+	return d.dialect
+}
+
+// propertyColumns are the columns read by scanProperty
+const propertyColumns = `
             id, 
             url, 
             street, 
@@ -40,6 +179,7 @@ func (d *Database) GetAllProperties(startDate, endDate string, city string) ([]m
             property_type, 
             city, 
             postal_code,
+            district,
             price, 
             year_built, 
             living_area, 
@@ -51,176 +191,379 @@ func (d *Database) GetAllProperties(startDate, endDate string, city string) ([]m
             COALESCE(created_at, CURRENT_TIMESTAMP) as created_at,
             latitude,
             longitude,
-            energy_label
-        FROM properties
-        WHERE (
-            -- For active properties, check effective_date (listing_date or scraped_at)
-            (status = 'active' AND (
-                ? = '' OR COALESCE(listing_date, scraped_at) >= ?
-            ) AND (
-                ? = '' OR COALESCE(listing_date, scraped_at) <= ?
-            ))
-            OR
-            -- For sold properties, check selling_date only if it exists
-            (status = 'sold' AND selling_date IS NOT NULL AND (
-                ? = '' OR selling_date >= ?
-            ) AND (
-                ? = '' OR selling_date <= ?
-            ))
-        )
-        AND (? = '' OR LOWER(city) = LOWER(?))
-    `
-	var args []interface{}
-	args = append(args,
-		startDate, startDate, // For active properties listing_date >= ?
-		endDate, endDate, // For active properties listing_date <= ?
-		startDate, startDate, // For sold properties selling_date >= ?
-		endDate, endDate, // For sold properties selling_date <= ?
-		city, city, // For city filter
+            energy_label,
+            street_image_url,
+            street_image_link,
+            canonical_id,
+            agent_id,
+            delisting_reason,
+            delisted_at,
+            country,
+            currency,
+            selling_date_precision`
+
+// scanProperty reads a row selected with propertyColumns
+func scanProperty(row rowScanner) (models.Property, error) {
+	var p models.Property
+	var street, neighborhood, propertyType, city, postalCode, district, status sql.NullString
+	var listingDate, sellingDate, scrapedAt, createdAt sql.NullString
+	var yearBuilt, livingArea, numRooms sql.NullInt64
+	var price sql.NullInt64
+	var latitude, longitude sql.NullFloat64
+	var energyLabel, streetImageURL, streetImageLink sql.NullString
+	var canonicalID, agentID sql.NullInt64
+	var delistingReason sql.NullString
+	var delistedAt sql.NullTime
+	var countryCode, currency, sellingDatePrecision sql.NullString
+
+	err := row.Scan(
+		&p.ID,
+		&p.URL,
+		&street,
+		&neighborhood,
+		&propertyType,
+		&city,
+		&postalCode,
+		&district,
+		&price,
+		&yearBuilt,
+		&livingArea,
+		&numRooms,
+		&status,
+		&listingDate,
+		&sellingDate,
+		&scrapedAt,
+		&createdAt,
+		&latitude,
+		&longitude,
+		&energyLabel,
+		&streetImageURL,
+		&streetImageLink,
+		&canonicalID,
+		&agentID,
+		&delistingReason,
+		&delistedAt,
+		&countryCode,
+		&currency,
+		&sellingDatePrecision,
 	)
-
-	rows, err := d.db.Query(query, args...)
 	if err != nil {
-		return nil, err
+		return p, err
 	}
-	defer rows.Close()
 
-	var properties []models.Property
-	for rows.Next() {
-		var p models.Property
-		var street, neighborhood, propertyType, city, postalCode, status sql.NullString
-		var listingDate, sellingDate, scrapedAt, createdAt sql.NullString
-		var yearBuilt, livingArea, numRooms sql.NullInt64
-		var price sql.NullInt64
-		var latitude, longitude sql.NullFloat64
-		var energyLabel sql.NullString
+	// Handle nullable string fields
+	if street.Valid {
+		p.Street = street.String
+	}
+	if neighborhood.Valid {
+		p.Neighborhood = neighborhood.String
+	}
+	if propertyType.Valid {
+		p.PropertyType = propertyType.String
+	}
+	if city.Valid {
+		p.City = city.String
+	}
+	if postalCode.Valid {
+		p.PostalCode = postalCode.String
+	}
+	if district.Valid {
+		p.District = district.String
+	}
+	if status.Valid {
+		p.Status = status.String
+	}
 
-		err := rows.Scan(
-			&p.ID,
-			&p.URL,
-			&street,
-			&neighborhood,
-			&propertyType,
-			&city,
-			&postalCode,
-			&price,
-			&yearBuilt,
-			&livingArea,
-			&numRooms,
-			&status,
-			&listingDate,
-			&sellingDate,
-			&scrapedAt,
-			&createdAt,
-			&latitude,
-			&longitude,
-			&energyLabel,
-		)
-		if err != nil {
-			return nil, err
-		}
+	// Handle nullable numeric fields
+	if price.Valid {
+		p.Price = int(price.Int64)
+	}
+	if yearBuilt.Valid {
+		yb := int(yearBuilt.Int64)
+		p.YearBuilt = &yb
+	}
+	if livingArea.Valid {
+		la := int(livingArea.Int64)
+		p.LivingArea = &la
+	}
+	if numRooms.Valid {
+		nr := int(numRooms.Int64)
+		p.NumRooms = &nr
+	}
 
-		// Handle nullable string fields
-		if street.Valid {
-			p.Street = street.String
-		}
-		if neighborhood.Valid {
-			p.Neighborhood = neighborhood.String
-		}
-		if propertyType.Valid {
-			p.PropertyType = propertyType.String
-		}
-		if city.Valid {
-			p.City = city.String
-		}
-		if postalCode.Valid {
-			p.PostalCode = postalCode.String
-		}
-		if status.Valid {
-			p.Status = status.String
-		}
+	// Handle nullable coordinates
+	if latitude.Valid {
+		lat := latitude.Float64
+		p.Latitude = &lat
+	}
+	if longitude.Valid {
+		lon := longitude.Float64
+		p.Longitude = &lon
+	}
 
-		// Handle nullable numeric fields
-		if price.Valid {
-			p.Price = int(price.Int64)
+	// Handle energy_label
+	if energyLabel.Valid {
+		p.EnergyLabel = energyLabel.String
+	}
+	p.StreetImageURL = streetImageURL.String
+	p.StreetImageLink = streetImageLink.String
+	if canonicalID.Valid {
+		p.CanonicalID = &canonicalID.Int64
+	}
+	if agentID.Valid {
+		p.AgentID = &agentID.Int64
+	}
+	p.DelistingReason = delistingReason.String
+	p.Country = countryCode.String
+	p.Currency = currency.String
+	if delistedAt.Valid {
+		p.DelistedAt = &delistedAt.Time
+	}
+
+	// Parse dates if they're valid
+	if listingDate.Valid && listingDate.String != "" {
+		if t, err := time.Parse("2006-01-02", listingDate.String); err == nil {
+			p.ListingDate = t
 		}
-		if yearBuilt.Valid {
-			yb := int(yearBuilt.Int64)
-			p.YearBuilt = &yb
+	}
+	if sellingDate.Valid && sellingDate.String != "" {
+		if t, err := time.Parse("2006-01-02", sellingDate.String); err == nil {
+			p.SellingDate = t
+			// Selling dates stored before their precision was recorded are exact
+			p.SellingDatePrecision = models.DatePrecisionDay
+			if sellingDatePrecision.Valid && sellingDatePrecision.String != "" {
+				p.SellingDatePrecision = sellingDatePrecision.String
+			}
 		}
-		if livingArea.Valid {
-			la := int(livingArea.Int64)
-			p.LivingArea = &la
+	}
+	if scrapedAt.Valid && scrapedAt.String != "" {
+		if t, err := time.Parse(time.RFC3339, scrapedAt.String); err == nil {
+			p.ScrapedAt = t
 		}
-		if numRooms.Valid {
-			nr := int(numRooms.Int64)
-			p.NumRooms = &nr
+	}
+	if createdAt.Valid && createdAt.String != "" {
+		if t, err := time.Parse(time.RFC3339, createdAt.String); err == nil {
+			p.CreatedAt = t
 		}
+	}
 
-		// Handle nullable coordinates
-		if latitude.Valid {
-			lat := latitude.Float64
-			p.Latitude = &lat
-		}
-		if longitude.Valid {
-			lon := longitude.Float64
-			p.Longitude = &lon
-		}
+	return p, nil
+}
 
-		// Handle energy_label
-		if energyLabel.Valid {
-			p.EnergyLabel = energyLabel.String
-		}
+// propertySortColumns maps the sort_by values of the property list to SQL expressions
+var propertySortColumns = map[string]string{
+	"id":            "id",
+	"price":         "price",
+	"living_area":   "living_area",
+	"price_per_sqm": "CAST(price AS FLOAT) / NULLIF(living_area, 0)",
+	"listing_date":  "listing_date",
+	"selling_date":  "selling_date",
+	"scraped_at":    "scraped_at",
+	"city":          "city",
+	"postal_code":   "postal_code",
+}
 
-		// Parse dates if they're valid
-		if listingDate.Valid && listingDate.String != "" {
-			if t, err := time.Parse("2006-01-02", listingDate.String); err == nil {
-				p.ListingDate = t
-			}
+// PropertySortFields returns the fields the property list can be sorted by
+func PropertySortFields() []string {
+	fields := make([]string, 0, len(propertySortColumns))
+	for field := range propertySortColumns {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// propertyListFilter selects the properties in a date range and city; bind
+// it with propertyListArgs
+func propertyListFilter(city string) string {
+	return propertyDateFilter + `
+        AND ` + cityFilter(city)
+}
+
+func propertyListArgs(startDate, endDate, city string) []interface{} {
+	args := propertyDateFilterArgs(startDate, endDate)
+	return append(args, city, city) // For city filter
+}
+
+// attributeFilter returns the condition and arguments of the price, area,
+// room, energy label, property type and status filters of the options
+func attributeFilter(opts models.PropertyListOptions) (string, []interface{}) {
+	conditions := []string{"1 = 1"}
+	var args []interface{}
+	ranges := []struct {
+		column   string
+		min, max int
+	}{
+		{"price", opts.MinPrice, opts.MaxPrice},
+		{"living_area", opts.MinLivingArea, opts.MaxLivingArea},
+		{"num_rooms", opts.MinRooms, opts.MaxRooms},
+	}
+	for _, r := range ranges {
+		if r.min > 0 {
+			conditions = append(conditions, r.column+" >= ?")
+			args = append(args, r.min)
 		}
-		if sellingDate.Valid && sellingDate.String != "" {
-			if t, err := time.Parse("2006-01-02", sellingDate.String); err == nil {
-				p.SellingDate = t
-			}
+		if r.max > 0 {
+			conditions = append(conditions, r.column+" <= ?")
+			args = append(args, r.max)
 		}
-		if scrapedAt.Valid && scrapedAt.String != "" {
-			if t, err := time.Parse(time.RFC3339, scrapedAt.String); err == nil {
-				p.ScrapedAt = t
-			}
+	}
+	lists := []struct {
+		column string
+		values []string
+	}{
+		{"UPPER(energy_label)", opts.EnergyLabels},
+		{"UPPER(property_type)", opts.PropertyTypes},
+		{"status", opts.Statuses},
+	}
+	for _, l := range lists {
+		if len(l.values) == 0 {
+			continue
 		}
-		if createdAt.Valid && createdAt.String != "" {
-			if t, err := time.Parse(time.RFC3339, createdAt.String); err == nil {
-				p.CreatedAt = t
+		conditions = append(conditions, l.column+" IN (?"+strings.Repeat(", ?", len(l.values)-1)+")")
+		for _, v := range l.values {
+			if l.column != "status" {
+				v = strings.ToUpper(v)
 			}
+			args = append(args, v)
 		}
+	}
+	return "(" + strings.Join(conditions, " AND ") + ")", args
+}
 
-		properties = append(properties, p)
+// GetAllProperties returns a page of the properties in the date range and city
+// that match the filters of opts, sorted by opts.SortBy, together with the
+// total number of matches. A zero limit returns all properties.
+func (d *Database) GetAllProperties(startDate, endDate string, city string, opts models.PropertyListOptions) (*models.PropertyList, error) {
+	if opts.SortBy == "" {
+		opts.SortBy = "id"
 	}
-	return properties, nil
+	column, ok := propertySortColumns[opts.SortBy]
+	if !ok {
+		column, ok = scoreSortColumn(opts.SortBy)
+	}
+	if !ok {
+		return nil, errorf(ErrValidation, "unknown sort field %q", opts.SortBy)
+	}
+	direction := "ASC"
+	if strings.EqualFold(opts.Order, "desc") {
+		direction = "DESC"
+		opts.Order = "desc"
+	} else {
+		opts.Order = "asc"
+	}
+	if opts.Limit <= 0 {
+		opts.Limit, opts.Offset = 0, 0
+	}
+
+	list := &models.PropertyList{
+		Properties: []models.Property{},
+		Limit:      opts.Limit,
+		Offset:     opts.Offset,
+		SortBy:     opts.SortBy,
+		Order:      opts.Order,
+	}
+	tags, tagArgs := tagFilter(opts.Tags)
+	attributes, attributeArgs := attributeFilter(opts)
+	scores, scoreArgs := scoreFilter(opts.MinScores, opts.MaxScores)
+	args := append(propertyListArgs(startDate, endDate, city), tagArgs...)
+	args = append(args, attributeArgs...)
+	args = append(args, scoreArgs...)
+	filter := propertyListFilter(city) + " AND " + tags + " AND " + attributes + " AND " + scores
+	err := d.db.QueryRow("SELECT COUNT(*) FROM properties WHERE "+filter, args...).Scan(&list.Total)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count properties: %v", err)
+	}
+
+	// NULLs sort last in either direction, and id keeps pages stable
+	query := `
+        SELECT ` + propertyColumns + `
+        FROM properties
+        WHERE ` + filter + `
+        ORDER BY (` + column + `) IS NULL, ` + column + ` ` + direction + `, id ` + direction
+	if opts.Limit > 0 {
+		query += `
+        LIMIT ? OFFSET ?`
+		args = append(args, opts.Limit, opts.Offset)
+	}
+
+	err = d.forEachProperty(query, args, func(p models.Property) error {
+		list.Properties = append(list.Properties, p)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := d.setPropertyTags(list.Properties); err != nil {
+		return nil, err
+	}
+	if err := d.setPropertyScores(list.Properties); err != nil {
+		return nil, err
+	}
+	return list, nil
 }
 
-func (d *Database) GetPropertyStats(startDate, endDate string, city string) (models.PropertyStats, error) {
+// ForEachProperty calls fn for every property in the date range and city, one
+// row at a time, so memory use does not grow with the number of properties.
+// Iteration stops at the first error returned by fn, which is passed on.
+func (d *Database) ForEachProperty(startDate, endDate string, city string, fn func(models.Property) error) error {
+	query := `
+        SELECT ` + propertyColumns + `
+        FROM properties
+        WHERE ` + propertyListFilter(city)
+	return d.forEachProperty(query, propertyListArgs(startDate, endDate, city), fn)
+}
+
+// forEachProperty runs a query selecting propertyColumns and calls fn per row
+func (d *Database) forEachProperty(query string, args []interface{}, fn func(models.Property) error) error {
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		p, err := scanProperty(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(p); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// propertyStats computes GetPropertyStats without the cache
+func (d *Database) propertyStats(startDate, endDate string, city string, asOf string) (models.PropertyStats, error) {
+	var stats models.PropertyStats
+	source, args, err := propertiesAsOf(asOf)
+	if err != nil {
+		return stats, err
+	}
+
 	query := `
         WITH price_data AS (
             SELECT 
                 price,
                 living_area,
                 status,
-                COALESCE(listing_date, scraped_at) as effective_date,
+                COALESCE(listing_date, CAST(scraped_at AS TEXT)) as effective_date,
                 selling_date,
                 CASE 
-                    WHEN listing_date IS NOT NULL AND selling_date IS NOT NULL 
-                    THEN julianday(selling_date) - julianday(listing_date) 
+                    WHEN listing_date IS NOT NULL AND selling_date IS NOT NULL
+                    AND ` + exactSellingDate("") + `
+                    THEN ` + d.dialect.DaysBetween("listing_date", "selling_date") + `
                 END as days_to_sell
-            FROM properties
+            FROM ` + source + `
             WHERE price IS NOT NULL
-            AND (? = '' OR LOWER(city) = LOWER(?))
+            AND ` + cityFilter(city) + `
             AND (
                 -- For active properties, check effective_date (listing_date or scraped_at)
                 (status = 'active' AND (
-                    ? = '' OR COALESCE(listing_date, scraped_at) >= ?
+                    ? = '' OR COALESCE(listing_date, CAST(scraped_at AS TEXT)) >= ?
                 ) AND (
-                    ? = '' OR COALESCE(listing_date, scraped_at) <= ?
+                    ? = '' OR COALESCE(listing_date, CAST(scraped_at AS TEXT)) <= ?
                 ))
                 OR
                 -- For sold properties, check selling_date only if it exists
@@ -265,7 +608,6 @@ func (d *Database) GetPropertyStats(startDate, endDate string, city string) (mod
             COALESCE(active_count, 0) as total_active
         FROM active_stats, sold_stats
     `
-	var args []interface{}
 	args = append(args,
 		city, city, // For city filter
 		startDate, startDate, // For active properties listing_date >= ?
@@ -274,8 +616,7 @@ func (d *Database) GetPropertyStats(startDate, endDate string, city string) (mod
 		endDate, endDate, // For sold properties selling_date <= ?
 	)
 
-	var stats models.PropertyStats
-	err := d.db.QueryRow(query, args...).Scan(
+	err = d.db.QueryRow(query, args...).Scan(
 		&stats.TotalProperties,
 		&stats.AveragePrice,
 		&stats.PricePerSqm,
@@ -283,25 +624,38 @@ func (d *Database) GetPropertyStats(startDate, endDate string, city string) (mod
 		&stats.TotalSold,
 		&stats.TotalActive,
 	)
+	if err != nil {
+		return stats, err
+	}
+
+	err = d.fillPropertyMedians(&stats, startDate, endDate, city, asOf)
 	return stats, err
 }
 
-func (d *Database) GetAreaStats(postalPrefix string, startDate, endDate string, city string) (models.AreaStats, error) {
+// GetAreaStats summarizes the properties whose postal code starts with
+// postalPrefix; with an asOf date, as they stood at the end of that day
+func (d *Database) GetAreaStats(postalPrefix string, startDate, endDate string, city string, asOf string) (models.AreaStats, error) {
+	var stats models.AreaStats
+	source, args, err := propertiesAsOf(asOf)
+	if err != nil {
+		return stats, err
+	}
+
 	query := `
         SELECT 
-            postal_code,
+            MIN(postal_code) as postal_code,
             COUNT(*) as property_count,
             AVG(price) as average_price,
             AVG(CAST(price AS FLOAT) / NULLIF(living_area, 0)) as avg_price_per_sqm
-        FROM properties
+        FROM ` + source + `
         WHERE postal_code LIKE ? || '%'
-        AND (? = '' OR LOWER(city) = LOWER(?))
+        AND ` + cityFilter(city) + `
         AND (
             -- For active properties, check effective_date (listing_date or scraped_at)
             (status = 'active' AND (
-                ? = '' OR COALESCE(listing_date, scraped_at) >= ?
+                ? = '' OR COALESCE(listing_date, CAST(scraped_at AS TEXT)) >= ?
             ) AND (
-                ? = '' OR COALESCE(listing_date, scraped_at) <= ?
+                ? = '' OR COALESCE(listing_date, CAST(scraped_at AS TEXT)) <= ?
             ))
             OR
             -- For sold properties, check selling_date only if it exists
@@ -311,9 +665,8 @@ func (d *Database) GetAreaStats(postalPrefix string, startDate, endDate string,
                 ? = '' OR selling_date <= ?
             ))
         )
-        GROUP BY substr(postal_code, 1, 4)
+        GROUP BY district
     `
-	var args []interface{}
 	args = append(args,
 		postalPrefix,
 		city, city, // For city filter
@@ -323,8 +676,7 @@ func (d *Database) GetAreaStats(postalPrefix string, startDate, endDate string,
 		endDate, endDate, // For sold properties selling_date <= ?
 	)
 
-	var stats models.AreaStats
-	err := d.db.QueryRow(query, args...).Scan(
+	err = d.db.QueryRow(query, args...).Scan(
 		&stats.PostalCode,
 		&stats.PropertyCount,
 		&stats.AveragePrice,
@@ -340,7 +692,7 @@ func (d *Database) GetRecentSales(limit int, startDate, endDate string, city str
                listing_date, selling_date, scraped_at, created_at
         FROM properties
         WHERE status = 'sold'
-        AND (? = '' OR LOWER(city) = LOWER(?))
+        AND ` + cityFilter(city) + `
     `
 	var args []interface{}
 	args = append(args, city, city)
@@ -381,254 +733,39 @@ func (d *Database) GetRecentSales(limit int, startDate, endDate string, city str
 }
 
 func (d *Database) Close() error {
+	if d.replica != nil {
+		d.replica.Close()
+	}
 	return d.db.Close()
 }
 
-func (d *Database) RunMigrations() error {
-	// Create properties table first
-	_, err := d.db.Exec(`
-		CREATE TABLE IF NOT EXISTS properties (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			url TEXT UNIQUE NOT NULL,
-			street TEXT,
-			neighborhood TEXT,
-			property_type TEXT,
-			city TEXT,
-			postal_code TEXT,
-			price INTEGER,
-			year_built INTEGER,
-			living_area INTEGER,
-			num_rooms INTEGER,
-			status TEXT,
-			listing_date TEXT,
-			selling_date TEXT,
-			scraped_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-			energy_label TEXT,
-			republish_count INTEGER DEFAULT 0,
-			latitude REAL,
-			longitude REAL,
-			geocoding_attempted BOOLEAN DEFAULT 0
-		);
-	`)
-	if err != nil {
-		return fmt.Errorf("failed to create properties table: %v", err)
-	}
-
-	// Create property_history table
-	_, err = d.db.Exec(`
-		CREATE TABLE IF NOT EXISTS property_history (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			property_id INTEGER NOT NULL,
-			status TEXT,
-			price INTEGER,
-			listing_date TEXT,
-			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-			FOREIGN KEY (property_id) REFERENCES properties(id)
-		);
-	`)
-	if err != nil {
-		return fmt.Errorf("failed to create property_history table: %v", err)
-	}
-
-	// Create metropolitan areas table
-	_, err = d.db.Exec(`
-		CREATE TABLE IF NOT EXISTS metropolitan_areas (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			name TEXT UNIQUE NOT NULL,
-			center_lat REAL,
-			center_lng REAL,
-			zoom_level INTEGER DEFAULT 13,
-			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-		);
-	`)
-	if err != nil {
-		return fmt.Errorf("failed to create metropolitan_areas table: %v", err)
-	}
-
-	// Create telegram configuration table
-	_, err = d.db.Exec(`
-		CREATE TABLE IF NOT EXISTS telegram_config (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			bot_token TEXT NOT NULL,
-			chat_id TEXT NOT NULL,
-			is_enabled BOOLEAN DEFAULT 1,
-			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-		);
-	`)
-	if err != nil {
-		return fmt.Errorf("failed to create telegram_config table: %v", err)
-	}
-
-	// Create metropolitan cities table without the foreign key constraint
-	_, err = d.db.Exec(`
-		CREATE TABLE IF NOT EXISTS metropolitan_cities (
-			metropolitan_area_id INTEGER,
-			city TEXT NOT NULL,
-			lat REAL,
-			lng REAL,
-			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-			PRIMARY KEY (metropolitan_area_id, city)
-		);
-	`)
-	if err != nil {
-		return fmt.Errorf("failed to create metropolitan_cities table: %v", err)
-	}
-
-	// Add coordinate columns to metropolitan_areas if they don't exist
-	_, err = d.db.Exec(`
-		ALTER TABLE metropolitan_areas 
-		ADD COLUMN center_lat REAL;
-	`)
-	if err != nil && err.Error() != "duplicate column name: center_lat" {
-		return err
-	}
-
-	_, err = d.db.Exec(`
-		ALTER TABLE metropolitan_areas 
-		ADD COLUMN center_lng REAL;
-	`)
-	if err != nil && err.Error() != "duplicate column name: center_lng" {
-		return err
-	}
-
-	_, err = d.db.Exec(`
-		ALTER TABLE metropolitan_areas 
-		ADD COLUMN zoom_level INTEGER DEFAULT 13;
-	`)
-	if err != nil && err.Error() != "duplicate column name: zoom_level" {
-		return err
-	}
-
-	// Add coordinate columns to metropolitan_cities if they don't exist
-	_, err = d.db.Exec(`
-		ALTER TABLE metropolitan_cities 
-		ADD COLUMN lat REAL;
-	`)
-	if err != nil && err.Error() != "duplicate column name: lat" {
-		return err
-	}
-
-	_, err = d.db.Exec(`
-		ALTER TABLE metropolitan_cities 
-		ADD COLUMN lng REAL;
-	`)
-	if err != nil && err.Error() != "duplicate column name: lng" {
-		return err
-	}
-
-	// Add republish_count column if it doesn't exist
-	_, err = d.db.Exec(`
-		ALTER TABLE properties 
-		ADD COLUMN republish_count INTEGER DEFAULT 0;
-	`)
-	if err != nil && err.Error() != "duplicate column name: republish_count" {
-		return fmt.Errorf("failed to add republish_count column: %v", err)
-	}
-
-	// Add latitude and longitude columns if they don't exist
-	_, err = d.db.Exec(`
-		ALTER TABLE properties 
-		ADD COLUMN latitude REAL;
-	`)
-	if err != nil && err.Error() != "duplicate column name: latitude" {
-		return err
-	}
-
-	_, err = d.db.Exec(`
-		ALTER TABLE properties 
-		ADD COLUMN longitude REAL;
-	`)
-	if err != nil && err.Error() != "duplicate column name: longitude" {
-		return err
-	}
-
-	// Add geocoding_attempted column
-	_, err = d.db.Exec(`
-		ALTER TABLE properties 
-		ADD COLUMN geocoding_attempted BOOLEAN DEFAULT 0;
-	`)
-	if err != nil && err.Error() != "duplicate column name: geocoding_attempted" {
-		return err
-	}
-
-	// Mark properties that already have coordinates as attempted
-	_, err = d.db.Exec(`
-		UPDATE properties 
-		SET geocoding_attempted = 1 
-		WHERE latitude IS NOT NULL 
-		AND longitude IS NOT NULL;
-	`)
-	if err != nil {
-		return fmt.Errorf("failed to mark existing coordinates as attempted: %v", err)
-	}
-
-	// Create spatial index on coordinates
-	_, err = d.db.Exec(`
-		CREATE INDEX IF NOT EXISTS idx_properties_coordinates 
-		ON properties(latitude, longitude);
-	`)
-	if err != nil {
-		return err
-	}
-
-	// Add energy_label column if it doesn't exist
-	_, err = d.db.Exec(`
-		ALTER TABLE properties 
-		ADD COLUMN energy_label TEXT;
-	`)
-	if err != nil && err.Error() != "duplicate column name: energy_label" {
-		return fmt.Errorf("failed to add energy_label column: %v", err)
-	}
-
-	// Create telegram_filters table
-	_, err = d.db.Exec(`
-		CREATE TABLE IF NOT EXISTS telegram_filters (
-			min_price INTEGER,
-			max_price INTEGER,
-			min_living_area INTEGER,
-			max_living_area INTEGER,
-			min_rooms INTEGER,
-			max_rooms INTEGER,
-			districts TEXT,
-			energy_labels TEXT
-		)
-	`)
-	if err != nil {
-		return fmt.Errorf("failed to create telegram_filters table: %v", err)
-	}
-
-	// Ensure we have exactly one row in telegram_filters
-	var count int
-	err = d.db.QueryRow("SELECT COUNT(*) FROM telegram_filters").Scan(&count)
-	if err != nil {
-		return fmt.Errorf("failed to count telegram_filters: %v", err)
-	}
-
-	if count == 0 {
-		_, err = d.db.Exec("INSERT INTO telegram_filters DEFAULT VALUES")
-		if err != nil {
-			return fmt.Errorf("failed to insert default telegram_filters: %v", err)
-		}
-	}
+func (d *Database) UpdateMissingCoordinates(geocoder *geocoding.Geocoder) error {
+	return d.GeocodeMissingCoordinates(context.Background(), geocoder, nil)
+}
 
-	return nil
+// GeocodeMissingCoordinates geocodes the properties without coordinates,
+// reporting the number of handled addresses to progress after each one. Only
+// one pass runs at a time: a caller arriving while one runs waits for it and
+// follows its progress instead of starting another. A caller whose ctx is
+// cancelled stops waiting; the pass stops between batches once no caller waits
+// for it, keeping the finished batches. Servers sharing the database take turns
+// through the geocoding lease; while another server holds it, the call returns
+// ErrGeocodingLeased.
+func (d *Database) GeocodeMissingCoordinates(ctx context.Context, geocoder *geocoding.Geocoder, progress func(done, total int)) error {
+	return d.geocoding.run(ctx, func(ctx context.Context, progress func(done, total int)) error {
+		return d.withGeocodingLease(ctx, func(ctx context.Context, progress func(done, total int)) error {
+			return d.geocodeMissingCoordinates(ctx, geocoder, progress)
+		}, progress)
+	}, progress)
 }
 
-func (d *Database) UpdateMissingCoordinates(geocoder *geocoding.Geocoder) error {
+func (d *Database) geocodeMissingCoordinates(ctx context.Context, geocoder *geocoding.Geocoder, progress func(done, total int)) error {
 	// Get total count of properties needing geocoding
 	var totalCount int
 	err := d.db.QueryRow(`
-		SELECT COUNT(*) 
-		FROM properties 
-		WHERE (latitude IS NULL OR longitude IS NULL)
-		AND geocoding_attempted = 0
-		AND street IS NOT NULL 
-		AND postal_code IS NOT NULL 
-		AND city IS NOT NULL
-	`).Scan(&totalCount)
+		SELECT COUNT(*)
+		FROM properties
+		WHERE ` + pendingGeocodingCondition).Scan(&totalCount)
 	if err != nil {
 		return fmt.Errorf("failed to count properties: %v", err)
 	}
@@ -645,6 +782,10 @@ func (d *Database) UpdateMissingCoordinates(geocoder *geocoding.Geocoder) error
 
 	// Process properties in batches
 	for processed+failed < totalCount {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
 		// Start a new transaction for each batch
 		tx, err := d.db.Begin()
 		if err != nil {
@@ -652,13 +793,9 @@ func (d *Database) UpdateMissingCoordinates(geocoder *geocoding.Geocoder) error
 		}
 
 		rows, err := tx.Query(`
-			SELECT id, street, postal_code, city 
-			FROM properties 
-			WHERE (latitude IS NULL OR longitude IS NULL)
-			AND geocoding_attempted = 0
-			AND street IS NOT NULL 
-			AND postal_code IS NOT NULL 
-			AND city IS NOT NULL
+			SELECT id, street, postal_code, city, field_provenance 
+			FROM properties
+			WHERE `+pendingGeocodingCondition+`
 			LIMIT ?
 		`, batchSize)
 		if err != nil {
@@ -666,13 +803,31 @@ func (d *Database) UpdateMissingCoordinates(geocoder *geocoding.Geocoder) error
 			return fmt.Errorf("failed to query properties: %v", err)
 		}
 
+		// Read the whole batch first; not every driver allows statements
+		// on a connection while a result set is still open
+		type pendingAddress struct {
+			id                       int64
+			street, postalCode, city string
+			provenance               sql.NullString
+		}
+		var batch []pendingAddress
+		for rows.Next() {
+			var a pendingAddress
+			if err := rows.Scan(&a.id, &a.street, &a.postalCode, &a.city, &a.provenance); err != nil {
+				rows.Close()
+				tx.Rollback()
+				return fmt.Errorf("failed to scan row: %v", err)
+			}
+			batch = append(batch, a)
+		}
+		rows.Close()
+
 		stmt, err := tx.Prepare(`
 			UPDATE properties 
-			SET latitude = ?, longitude = ?, geocoding_attempted = 1
+			SET latitude = ?, longitude = ?, geocoding_attempted = 1, field_provenance = ?
 			WHERE id = ?
 		`)
 		if err != nil {
-			rows.Close()
 			tx.Rollback()
 			return fmt.Errorf("failed to prepare statement: %v", err)
 		}
@@ -683,31 +838,19 @@ func (d *Database) UpdateMissingCoordinates(geocoder *geocoding.Geocoder) error
 			WHERE id = ?
 		`)
 		if err != nil {
-			rows.Close()
 			stmt.Close()
 			tx.Rollback()
 			return fmt.Errorf("failed to prepare failed statement: %v", err)
 		}
 
 		var batchProcessed int
-		for rows.Next() {
-			var id int64
-			var street, postalCode, city string
-			if err := rows.Scan(&id, &street, &postalCode, &city); err != nil {
-				rows.Close()
-				stmt.Close()
-				failedStmt.Close()
-				tx.Rollback()
-				return fmt.Errorf("failed to scan row: %v", err)
-			}
-
-			lat, lon, err := geocoder.GeocodeAddress(street, postalCode, city)
+		for _, a := range batch {
+			lat, lon, err := geocoder.GeocodeAddress(a.street, a.postalCode, a.city)
 			if err != nil {
-				fmt.Printf("Failed to geocode %s, %s, %s: %v\n", street, postalCode, city, err)
+				fmt.Printf("Failed to geocode %s, %s, %s: %v\n", a.street, a.postalCode, a.city, err)
 				// Mark as attempted even if geocoding failed
-				_, err = failedStmt.Exec(id)
+				_, err = failedStmt.Exec(a.id)
 				if err != nil {
-					rows.Close()
 					stmt.Close()
 					failedStmt.Close()
 					tx.Rollback()
@@ -715,12 +858,23 @@ func (d *Database) UpdateMissingCoordinates(geocoder *geocoding.Geocoder) error
 				}
 				failed++
 				batchProcessed++
+				if progress != nil {
+					progress(processed+failed, totalCount)
+				}
 				continue
 			}
 
-			_, err = stmt.Exec(lat, lon, id)
+			provenance := parseProvenance(a.provenance.String)
+			entry := models.FieldProvenance{
+				Source:    models.SourceEnrichment,
+				RunID:     "geocoding",
+				UpdatedAt: time.Now().UTC(),
+			}
+			provenance["latitude"] = entry
+			provenance["longitude"] = entry
+			provenanceJSON, _ := json.Marshal(provenance)
+			_, err = stmt.Exec(lat, lon, string(provenanceJSON), a.id)
 			if err != nil {
-				rows.Close()
 				stmt.Close()
 				failedStmt.Close()
 				tx.Rollback()
@@ -730,12 +884,15 @@ func (d *Database) UpdateMissingCoordinates(geocoder *geocoding.Geocoder) error
 			processed++
 			batchProcessed++
 
+			if progress != nil {
+				progress(processed+failed, totalCount)
+			}
+
 			// Print progress
 			fmt.Printf("Progress: %d/%d properties processed (%.1f%%), %d failed\n",
 				processed+failed, totalCount, float64(processed+failed)/float64(totalCount)*100, failed)
 		}
 
-		rows.Close()
 		stmt.Close()
 		failedStmt.Close()
 
@@ -759,152 +916,153 @@ func (d *Database) UpdateMissingCoordinates(geocoder *geocoding.Geocoder) error
 }
 
 func (d *Database) GetDB() *sql.DB {
-	return d.db
+	return d.db.DB
 }
 
-// InsertProperties inserts a batch of properties into the database and returns the newly inserted ones
+// propertyDistrict returns the district column value of a scraped postal code
+// in a country, NULL when it has no district
+func propertyDistrict(countryCode, postalCode interface{}) sql.NullString {
+	code, _ := countryCode.(string)
+	s, _ := postalCode.(string)
+	district := country.Get(code).District(s)
+	return sql.NullString{String: district, Valid: district != ""}
+}
+
+// itemCountry sets the country of a scraped item, the default country when it
+// names none, and its currency, that of the country when it names none. An
+// unknown country is left for validateItem to reject.
+func itemCountry(prop map[string]interface{}) {
+	code, _ := prop["country"].(string)
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if code == "" {
+		code = country.Default
+	}
+	prop["country"] = code
+	currency, _ := prop["currency"].(string)
+	currency = strings.ToUpper(strings.TrimSpace(currency))
+	if currency == "" {
+		currency = country.Get(code).Currency
+	}
+	prop["currency"] = currency
+}
+
+// prepareItem stores the URL, country and postal code of a scraped item the
+// way NormalizeProperties would, and the precision of its selling date
+func prepareItem(prop map[string]interface{}) {
+	if url, ok := prop["url"].(string); ok {
+		prop["url"] = canonicalURL(url)
+	}
+	itemCountry(prop)
+	if postalCode, ok := prop["postal_code"].(string); ok {
+		prop["postal_code"] = canonicalPostalCode(prop["country"].(string), postalCode)
+	}
+	itemSellingDate(prop)
+}
+
+// StoredURLs returns which of the given property URLs are already stored
+func (d *Database) StoredURLs(urls []string) (map[string]bool, error) {
+	stored := make(map[string]bool)
+	for start := 0; start < len(urls); start += upsertBatchSize {
+		chunk := urls[start:min(start+upsertBatchSize, len(urls))]
+		args := make([]interface{}, len(chunk))
+		for i, url := range chunk {
+			args[i] = url
+		}
+		rows, err := d.db.Query(`
+			SELECT url FROM properties
+			WHERE url IN (?`+strings.Repeat(", ?", len(chunk)-1)+`)
+		`, args...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query stored URLs: %v", err)
+		}
+		for rows.Next() {
+			var url string
+			if err := rows.Scan(&url); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("failed to scan stored URL: %v", err)
+			}
+			stored[url] = true
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, fmt.Errorf("error iterating stored URLs: %v", err)
+		}
+	}
+	return stored, nil
+}
+
+// InsertProperties inserts or updates a batch of scraped properties and returns
+// the newly inserted ones. Items are written with multi-row upserts, see
+// propertyUpserter. Items that fail the built-in checks or the enabled
+// validation rules are quarantined in rejected_items instead, with the reasons
+// set under the "_rejected" key of the item.
 func (d *Database) InsertProperties(properties []map[string]interface{}) ([]map[string]interface{}, error) {
-	tx, err := d.db.Begin()
+	rules, err := d.enabledValidationRules()
 	if err != nil {
-		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+		return nil, err
 	}
-	defer tx.Rollback()
-
-	var newProperties []map[string]interface{}
+	return d.insertProperties(properties, rules)
+}
 
+// insertProperties is InsertProperties checking items against the given
+// validation rules
+func (d *Database) insertProperties(properties []map[string]interface{}, rules []validationRule) ([]map[string]interface{}, error) {
+	var valid, rejected []map[string]interface{}
+	var urls []interface{}
 	for _, prop := range properties {
-		// Check if property exists and get its current state
-		var existingID int64
-		var currentStatus string
-		var republishCount int
-		err = tx.QueryRow(`
-			SELECT id, status, republish_count 
-			FROM properties 
-			WHERE url = ?
-		`, prop["url"]).Scan(&existingID, &currentStatus, &republishCount)
-
-		if err == nil {
-			// Property exists, handle update
-			if currentStatus == "inactive" && prop["status"] == "active" {
-				// Property is being republished
-				republishCount++
-				prop["status"] = "republished"
-				prop["republish_count"] = republishCount
-			}
+		prepareItem(prop)
+		reasons := validateItem(prop)
+		var failed []string
+		if len(reasons) > 0 {
+			failed = append(failed, builtinRule)
+		}
+		ruleReasons, ruleIDs := checkValidationRules(prop, rules)
+		reasons = append(reasons, ruleReasons...)
+		failed = append(failed, ruleIDs...)
+		if len(reasons) > 0 {
+			prop[itemRejectedKey] = strings.Join(reasons, "; ")
+			prop[itemRejectedRulesKey] = strings.Join(failed, ",")
+			rejected = append(rejected, prop)
+			continue
+		}
+		valid = append(valid, prop)
+		urls = append(urls, prop["url"])
+	}
 
-			// Update the property
-			_, err = tx.Exec(`
-				UPDATE properties 
-				SET street = ?, 
-					neighborhood = ?,
-					property_type = ?,
-					city = ?,
-					postal_code = ?,
-					price = ?,
-					year_built = ?,
-					living_area = CASE WHEN CAST(? AS INTEGER) > 0 THEN CAST(? AS INTEGER) ELSE NULL END,
-					num_rooms = ?,
-					status = ?,
-					listing_date = ?,
-					selling_date = ?,
-					scraped_at = ?,
-					republish_count = ?,
-					energy_label = ?
-				WHERE url = ?
-			`,
-				prop["street"],
-				prop["neighborhood"],
-				prop["property_type"],
-				prop["city"],
-				prop["postal_code"],
-				prop["price"],
-				prop["year_built"],
-				prop["living_area"], prop["living_area"], // Pass living_area twice for the CASE statement
-				prop["num_rooms"],
-				prop["status"],
-				prop["listing_date"],
-				prop["selling_date"],
-				prop["scraped_at"],
-				republishCount,
-				prop["energy_label"],
-				prop["url"],
-			)
-			if err != nil {
-				return nil, fmt.Errorf("failed to update property: %w", err)
-			}
+	tx, err := d.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
 
-			// Record history
-			_, err = tx.Exec(`
-				INSERT INTO property_history 
-				(property_id, status, price, listing_date)
-				VALUES (?, ?, ?, ?)
-			`,
-				existingID,
-				prop["status"],
-				prop["price"],
-				prop["listing_date"],
-			)
-			if err != nil {
-				return nil, fmt.Errorf("failed to insert property history: %w", err)
-			}
+	if err := rejectItems(tx, rejected); err != nil {
+		return nil, err
+	}
 
-		} else if err == sql.ErrNoRows {
-			// Insert new property
-			result, err := tx.Exec(`
-				INSERT INTO properties 
-				(url, street, neighborhood, property_type, city, postal_code, 
-				 price, year_built, living_area, num_rooms, status, 
-				 listing_date, selling_date, scraped_at, republish_count, energy_label)
-				VALUES (?, ?, ?, ?, ?, ?, ?, ?, 
-				 CASE WHEN CAST(? AS INTEGER) > 0 THEN CAST(? AS INTEGER) ELSE NULL END,
-				 ?, ?, ?, ?, ?, ?, ?)
-			`,
-				prop["url"],
-				prop["street"],
-				prop["neighborhood"],
-				prop["property_type"],
-				prop["city"],
-				prop["postal_code"],
-				prop["price"],
-				prop["year_built"],
-				prop["living_area"], prop["living_area"], // Pass living_area twice for the CASE statement
-				prop["num_rooms"],
-				prop["status"],
-				prop["listing_date"],
-				prop["selling_date"],
-				prop["scraped_at"],
-				0, // Initial republish_count
-				prop["energy_label"],
-			)
-			if err != nil {
-				return nil, fmt.Errorf("failed to insert property: %w", err)
-			}
+	// Listings scraped again after they were archived are moved back first,
+	// keeping their ids and history
+	if err := unarchiveURLs(tx, urls); err != nil {
+		return nil, err
+	}
 
-			// Get the new property ID
-			propertyID, err := result.LastInsertId()
-			if err != nil {
-				return nil, fmt.Errorf("failed to get last insert ID: %w", err)
-			}
+	upserter, err := newPropertyUpserter(tx)
+	if err != nil {
+		return nil, err
+	}
+	defer upserter.close()
 
-			// Record initial history
-			_, err = tx.Exec(`
-				INSERT INTO property_history 
-				(property_id, status, price, listing_date)
-				VALUES (?, ?, ?, ?)
-			`,
-				propertyID,
-				prop["status"],
-				prop["price"],
-				prop["listing_date"],
-			)
+	var newProperties []map[string]interface{}
+	for pending := valid; len(pending) > 0; {
+		round, deferred := nextRound(pending)
+		for start := 0; start < len(round); start += upsertBatchSize {
+			inserted, err := upserter.upsert(round[start:min(start+upsertBatchSize, len(round))])
 			if err != nil {
-				return nil, fmt.Errorf("failed to insert initial property history: %w", err)
+				return nil, err
 			}
-
-			newProperties = append(newProperties, prop)
-		} else {
-			return nil, fmt.Errorf("failed to check existing property: %w", err)
+			newProperties = append(newProperties, inserted...)
 		}
+		pending = deferred
 	}
 
 	if err := tx.Commit(); err != nil {
@@ -918,9 +1076,9 @@ func (d *Database) InsertProperties(properties []map[string]interface{}) ([]map[
 func (d *Database) GetMetropolitanAreas() ([]models.MetropolitanArea, error) {
 	rows, err := d.db.Query(`
 		SELECT m.id, m.name, m.center_lat, m.center_lng, m.zoom_level,
-		       GROUP_CONCAT(mc.city) as cities,
-		       GROUP_CONCAT(mc.lat) as city_lats,
-		       GROUP_CONCAT(mc.lng) as city_lngs
+		       ` + d.dialect.GroupConcat("mc.city") + ` as cities,
+		       ` + d.dialect.GroupConcat("mc.lat") + ` as city_lats,
+		       ` + d.dialect.GroupConcat("mc.lng") + ` as city_lngs
 		FROM metropolitan_areas m
 		LEFT JOIN metropolitan_cities mc ON m.id = mc.metropolitan_area_id
 		GROUP BY m.id, m.name
@@ -1028,7 +1186,7 @@ func (d *Database) GetMetropolitanAreaByName(name string) (*models.MetropolitanA
 	var citiesStr sql.NullString
 
 	err := d.db.QueryRow(`
-		SELECT m.id, m.name, GROUP_CONCAT(mc.city) as cities
+		SELECT m.id, m.name, `+d.dialect.GroupConcat("mc.city")+` as cities
 		FROM metropolitan_areas m
 		LEFT JOIN metropolitan_cities mc ON m.id = mc.metropolitan_area_id
 		WHERE m.name = ?
@@ -1071,17 +1229,14 @@ func (d *Database) UpdateMetropolitanArea(area models.MetropolitanArea) error {
 	var id int64
 	if err == sql.ErrNoRows {
 		// Insert new area
-		result, err := tx.Exec(`
+		err = tx.QueryRow(`
 			INSERT INTO metropolitan_areas (name, center_lat, center_lng, zoom_level) 
 			VALUES (?, ?, ?, ?)
-		`, area.Name, area.CenterLat, area.CenterLng, area.ZoomLevel)
+			RETURNING id
+		`, area.Name, area.CenterLat, area.CenterLng, area.ZoomLevel).Scan(&id)
 		if err != nil {
 			return fmt.Errorf("failed to insert metropolitan area: %v", err)
 		}
-		id, err = result.LastInsertId()
-		if err != nil {
-			return fmt.Errorf("failed to get metropolitan area ID: %v", err)
-		}
 	} else {
 		// Update existing area
 		id = existingID
@@ -1133,7 +1288,7 @@ func (d *Database) DeleteMetropolitanArea(name string) error {
 	}
 
 	if rowsAffected == 0 {
-		return fmt.Errorf("metropolitan area not found: %s", name)
+		return errorf(ErrNotFound, "metropolitan area not found: %s", name)
 	}
 
 	return nil
@@ -1164,6 +1319,31 @@ func (d *Database) GetCitiesInMetropolitanArea(name string) ([]string, error) {
 	return cities, nil
 }
 
+// GetCities returns the distinct cities that have properties
+func (d *Database) GetCities() ([]string, error) {
+	rows, err := d.db.Query(`
+		SELECT DISTINCT city
+		FROM properties
+		WHERE city IS NOT NULL AND city <> ''
+		ORDER BY city
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query cities: %v", err)
+	}
+	defer rows.Close()
+
+	var cities []string
+	for rows.Next() {
+		var city string
+		if err := rows.Scan(&city); err != nil {
+			return nil, fmt.Errorf("failed to scan city: %v", err)
+		}
+		cities = append(cities, city)
+	}
+
+	return cities, nil
+}
+
 func (d *Database) cityExists(city string) (bool, error) {
 	var exists bool
 	err := d.db.QueryRow("SELECT EXISTS(SELECT 1 FROM properties WHERE LOWER(city) = LOWER(?) LIMIT 1)", city).Scan(&exists)
@@ -1174,7 +1354,8 @@ func (d *Database) cityExists(city string) (bool, error) {
 func (d *Database) GetTelegramConfig() (*models.TelegramConfig, error) {
 	var config models.TelegramConfig
 	err := d.db.QueryRow(`
-		SELECT id, bot_token, chat_id, is_enabled, created_at, updated_at
+		SELECT id, bot_token, chat_id, is_enabled, COALESCE(locale, ''), COALESCE(currency, ''),
+		       created_at, updated_at
 		FROM telegram_config
 		ORDER BY id DESC
 		LIMIT 1
@@ -1183,6 +1364,8 @@ func (d *Database) GetTelegramConfig() (*models.TelegramConfig, error) {
 		&config.BotToken,
 		&config.ChatID,
 		&config.IsEnabled,
+		&config.Locale,
+		&config.Currency,
 		&config.CreatedAt,
 		&config.UpdatedAt,
 	)
@@ -1198,13 +1381,15 @@ func (d *Database) GetTelegramConfig() (*models.TelegramConfig, error) {
 // UpdateTelegramConfig updates or creates the Telegram configuration
 func (d *Database) UpdateTelegramConfig(config *models.TelegramConfigRequest) error {
 	_, err := d.db.Exec(`
-		INSERT OR REPLACE INTO telegram_config
-		(bot_token, chat_id, is_enabled, updated_at)
-		VALUES (?, ?, ?, CURRENT_TIMESTAMP)
+		INSERT INTO telegram_config
+		(bot_token, chat_id, is_enabled, locale, currency, updated_at)
+		VALUES (?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
 	`,
 		config.BotToken,
 		config.ChatID,
 		config.IsEnabled,
+		config.Locale,
+		config.Currency,
 	)
 	if err != nil {
 		return fmt.Errorf("failed to update telegram config: %v", err)
@@ -1219,11 +1404,11 @@ func (d *Database) GetDistrictMedianPricePerSqm(district string) (float64, error
 			SELECT 
 				CAST(price AS FLOAT) / CAST(living_area AS FLOAT) as price_per_sqm
 			FROM properties 
-			WHERE substr(postal_code, 1, 4) = ?
+			WHERE district = ?
 				AND price > 0 
 				AND living_area > 0
 				AND selling_date IS NOT NULL
-				AND selling_date >= date('now', '-1 year')
+				AND selling_date >= ` + d.dialect.DateOffset("-1 year") + `
 		)
 		SELECT 
 			AVG(price_per_sqm) as median_price
@@ -1233,7 +1418,7 @@ func (d *Database) GetDistrictMedianPricePerSqm(district string) (float64, error
 			ORDER BY price_per_sqm
 			LIMIT 2 - (SELECT COUNT(*) FROM prices_per_sqm) % 2
 			OFFSET (SELECT (COUNT(*) - 1) / 2 FROM prices_per_sqm)
-		);
+		) AS middle;
 	`
 
 	var medianPrice *float64
@@ -1248,7 +1433,9 @@ func (d *Database) GetDistrictMedianPricePerSqm(district string) (float64, error
 	return *medianPrice, nil
 }
 
-// MarkInactiveProperties marks properties as inactive if their URLs are not in the activeURLs list
+// MarkInactiveProperties marks the listed properties of a city whose URLs are
+// not in the activeURLs list as inactive, withdrawn from the market. A sold
+// spider that finds one of them later reclassifies it as sold.
 func (d *Database) MarkInactiveProperties(city string, activeURLs []string) error {
 	// Convert activeURLs slice to a map for O(1) lookup
 	activeURLMap := make(map[string]bool)
@@ -1266,7 +1453,7 @@ func (d *Database) MarkInactiveProperties(city string, activeURLs []string) erro
 	// Get all active properties for the city
 	rows, err := tx.Query(`
 		SELECT id, url FROM properties 
-		WHERE city = ? AND status = 'active'
+		WHERE city = ? AND status IN ('active', 'republished')
 	`, city)
 	if err != nil {
 		return fmt.Errorf("failed to query active properties: %v", err)
@@ -1304,9 +1491,11 @@ func (d *Database) MarkInactiveProperties(city string, activeURLs []string) erro
 		query := fmt.Sprintf(`
 			UPDATE properties 
 			SET status = 'inactive', 
+				delisting_reason = '%s',
+				delisted_at = CURRENT_TIMESTAMP,
 				updated_at = CURRENT_TIMESTAMP 
 			WHERE id IN (%s)
-		`, strings.Join(idStr, ","))
+		`, models.DelistingWithdrawn, strings.Join(idStr, ","))
 
 		_, err = tx.Exec(query, idArgs...)
 		if err != nil {
@@ -1322,8 +1511,8 @@ func (d *Database) MarkInactiveProperties(city string, activeURLs []string) erro
 	return nil
 }
 
-// GetDistrictPriceAnalysis returns median prices and counts for both active and sold properties
-func (d *Database) GetDistrictPriceAnalysis(district string) (activeMedian float64, activeCount int, soldMedian float64, soldCount int, err error) {
+// districtPriceAnalysis computes GetDistrictPriceAnalysis without the cache
+func (d *Database) districtPriceAnalysis(district string) (activeMedian float64, activeCount int, soldMedian float64, soldCount int, err error) {
 	// Get active listings median and count
 	err = d.db.QueryRow(`
 		WITH price_per_sqm AS (
@@ -1331,7 +1520,7 @@ func (d *Database) GetDistrictPriceAnalysis(district string) (activeMedian float
 				price / living_area as price_sqm,
 				COUNT(*) OVER () as total_count
 			FROM properties
-			WHERE substr(postal_code, 1, 4) = ?
+			WHERE district = ?
 			AND status = 'active'
 			AND price > 0 AND living_area > 0
 			-- Additional data quality checks
@@ -1346,22 +1535,13 @@ func (d *Database) GetDistrictPriceAnalysis(district string) (activeMedian float
 			FROM price_per_sqm
 		)
 		SELECT 
-			COALESCE(
-				CASE 
-					WHEN total_count = 0 THEN 0
-					WHEN total_count % 2 = 0 THEN
-						-- Even number of rows: average of two middle values
-						(SELECT AVG(price_sqm) 
-						 FROM ranked 
-						 WHERE row_num IN ((total_count/2), (total_count/2) + 1))
-					ELSE
-						-- Odd number of rows: middle value
-						(SELECT price_sqm 
-						 FROM ranked 
-						 WHERE row_num = (total_count + 1)/2)
-				END, 0
-			) as median,
-			MAX(total_count) as count
+			-- Average of the two middle values, which coincide for an odd number of rows
+			COALESCE((
+				SELECT AVG(price_sqm)
+				FROM ranked
+				WHERE row_num IN ((total_count + 1)/2, (total_count + 2)/2)
+			), 0) as median,
+			COALESCE(MAX(total_count), 0) as count
 		FROM ranked
 	`, district).Scan(&activeMedian, &activeCount)
 	if err != nil {
@@ -1375,13 +1555,13 @@ func (d *Database) GetDistrictPriceAnalysis(district string) (activeMedian float
 				price / living_area as price_sqm,
 				COUNT(*) OVER () as total_count
 			FROM properties
-			WHERE substr(postal_code, 1, 4) = ?
+			WHERE district = ?
 			AND status = 'sold'
 			AND price > 0 AND living_area > 0
 			-- Additional data quality checks
 			AND living_area BETWEEN 15 AND 1000  -- Reasonable size range
 			AND price BETWEEN 50000 AND 10000000  -- Reasonable price range
-			AND selling_date >= date('now', '-12 months')
+			AND selling_date >= `+d.dialect.DateOffset("-12 months")+`
 		),
 		ranked AS (
 			SELECT 
@@ -1391,22 +1571,13 @@ func (d *Database) GetDistrictPriceAnalysis(district string) (activeMedian float
 			FROM price_per_sqm
 		)
 		SELECT 
-			COALESCE(
-				CASE 
-					WHEN total_count = 0 THEN 0
-					WHEN total_count % 2 = 0 THEN
-						-- Even number of rows: average of two middle values
-						(SELECT AVG(price_sqm) 
-						 FROM ranked 
-						 WHERE row_num IN ((total_count/2), (total_count/2) + 1))
-					ELSE
-						-- Odd number of rows: middle value
-						(SELECT price_sqm 
-						 FROM ranked 
-						 WHERE row_num = (total_count + 1)/2)
-				END, 0
-			) as median,
-			MAX(total_count) as count
+			-- Average of the two middle values, which coincide for an odd number of rows
+			COALESCE((
+				SELECT AVG(price_sqm)
+				FROM ranked
+				WHERE row_num IN ((total_count + 1)/2, (total_count + 2)/2)
+			), 0) as median,
+			COALESCE(MAX(total_count), 0) as count
 		FROM ranked
 	`, district).Scan(&soldMedian, &soldCount)
 	if err != nil {
@@ -1422,8 +1593,8 @@ func (d *Database) GetPreviousPrice(propertyID int64) (int, error) {
 	err := d.db.QueryRow(`
 		SELECT price
 		FROM property_history
-		WHERE property_id = ?
-		ORDER BY listing_date DESC
+		WHERE property_id = ? AND change_type IS NOT NULL AND price IS NOT NULL
+		ORDER BY id DESC
 		LIMIT 1 OFFSET 1
 	`, propertyID).Scan(&previousPrice)
 
@@ -1440,20 +1611,20 @@ func (d *Database) GetPreviousPrice(propertyID int64) (int, error) {
 // GetTelegramFilters retrieves the current telegram notification filters
 func (d *Database) GetTelegramFilters() (*models.TelegramFilters, error) {
 	filters := &models.TelegramFilters{}
-	var districts, energyLabels sql.NullString
+	var districts, energyLabels, rules sql.NullString
 
 	err := d.db.QueryRow(`
 		SELECT 
 			min_price, max_price,
 			min_living_area, max_living_area,
 			min_rooms, max_rooms,
-			districts, energy_labels
+			districts, energy_labels, rules
 		FROM telegram_filters LIMIT 1
 	`).Scan(
 		&filters.MinPrice, &filters.MaxPrice,
 		&filters.MinLivingArea, &filters.MaxLivingArea,
 		&filters.MinRooms, &filters.MaxRooms,
-		&districts, &energyLabels,
+		&districts, &energyLabels, &rules,
 	)
 
 	if err != nil {
@@ -1467,13 +1638,19 @@ func (d *Database) GetTelegramFilters() (*models.TelegramFilters, error) {
 	if energyLabels.Valid && energyLabels.String != "" {
 		filters.EnergyLabels = strings.Split(energyLabels.String, ",")
 	}
+	if rules.Valid && rules.String != "" {
+		filters.Rules = &models.Rule{}
+		if err := json.Unmarshal([]byte(rules.String), filters.Rules); err != nil {
+			return nil, fmt.Errorf("failed to decode telegram filter rules: %v", err)
+		}
+	}
 
 	return filters, nil
 }
 
 // UpdateTelegramFilters updates the telegram notification filters
 func (d *Database) UpdateTelegramFilters(filters *models.TelegramFilters) error {
-	var districts, energyLabels sql.NullString
+	var districts, energyLabels, rules sql.NullString
 
 	// Convert string arrays to database format
 	if len(filters.Districts) > 0 {
@@ -1482,6 +1659,13 @@ func (d *Database) UpdateTelegramFilters(filters *models.TelegramFilters) error
 	if len(filters.EnergyLabels) > 0 {
 		energyLabels = sql.NullString{String: strings.Join(filters.EnergyLabels, ","), Valid: true}
 	}
+	if filters.Rules != nil {
+		encoded, err := json.Marshal(filters.Rules)
+		if err != nil {
+			return fmt.Errorf("failed to encode telegram filter rules: %v", err)
+		}
+		rules = sql.NullString{String: string(encoded), Valid: true}
+	}
 
 	_, err := d.db.Exec(`
 		UPDATE telegram_filters SET
@@ -1492,11 +1676,12 @@ func (d *Database) UpdateTelegramFilters(filters *models.TelegramFilters) error
 			min_rooms = $5,
 			max_rooms = $6,
 			districts = $7,
-			energy_labels = $8
+			energy_labels = $8,
+			rules = $9
 	`, filters.MinPrice, filters.MaxPrice,
 		filters.MinLivingArea, filters.MaxLivingArea,
 		filters.MinRooms, filters.MaxRooms,
-		districts, energyLabels)
+		districts, energyLabels, rules)
 
 	if err != nil {
 		return fmt.Errorf("failed to update telegram filters: %v", err)
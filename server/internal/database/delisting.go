@@ -0,0 +1,84 @@
+package database
+
+import (
+	"fmt"
+	"fundamental/server/internal/models"
+	"math"
+	"strings"
+	"time"
+)
+
+// ExpireListings marks listed properties that no spider has seen for the
+// given number of days as inactive, expired, and returns how many it marked
+func (d *Database) ExpireListings(days int) (int64, error) {
+	if days <= 0 {
+		return 0, fmt.Errorf("expiry threshold must be a positive number of days")
+	}
+
+	result, err := d.db.Exec(`
+		UPDATE properties
+		SET status = 'inactive',
+			delisting_reason = ?,
+			delisted_at = CURRENT_TIMESTAMP,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE status IN ('active', 'republished')
+		AND scraped_at < `+d.dialect.TimestampOffset(),
+		models.DelistingExpired, fmt.Sprintf("-%d days", days))
+	if err != nil {
+		return 0, fmt.Errorf("failed to expire listings: %v", err)
+	}
+	return result.RowsAffected()
+}
+
+// GetDelistingStats counts the delisted properties of each district by
+// reason. Dates filter on the delisting time, which listings delisted before
+// reasons were recorded do not have; they only count without a date range.
+func (d *Database) GetDelistingStats(startDate, endDate, city string) ([]models.DistrictDelistings, error) {
+	conditions := []string{"delisting_reason IS NOT NULL", "district IS NOT NULL", "district <> ''", cityFilter(city)}
+	args := []interface{}{city, city}
+	if startDate != "" {
+		start, err := time.Parse("2006-01-02", startDate)
+		if err != nil {
+			return nil, errorf(ErrValidation, "invalid start date %q", startDate)
+		}
+		conditions = append(conditions, "delisted_at >= ?")
+		args = append(args, start)
+	}
+	if endDate != "" {
+		end, err := time.Parse("2006-01-02", endDate)
+		if err != nil {
+			return nil, errorf(ErrValidation, "invalid end date %q", endDate)
+		}
+		conditions = append(conditions, "delisted_at < ?")
+		args = append(args, end.AddDate(0, 0, 1))
+	}
+
+	rows, err := d.db.Query(`
+		SELECT district,
+			COUNT(*),
+			SUM(CASE WHEN delisting_reason = 'sold' THEN 1 ELSE 0 END),
+			SUM(CASE WHEN delisting_reason = 'withdrawn' THEN 1 ELSE 0 END),
+			SUM(CASE WHEN delisting_reason = 'expired' THEN 1 ELSE 0 END)
+		FROM properties
+		WHERE `+strings.Join(conditions, " AND ")+`
+		GROUP BY district
+		ORDER BY district`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query delisting stats: %v", err)
+	}
+	defer rows.Close()
+
+	stats := []models.DistrictDelistings{}
+	for rows.Next() {
+		var s models.DistrictDelistings
+		if err := rows.Scan(&s.District, &s.Delisted, &s.Sold, &s.Withdrawn, &s.Expired); err != nil {
+			return nil, fmt.Errorf("failed to scan delisting stats: %v", err)
+		}
+		s.WithdrawalRate = math.Round(float64(s.Withdrawn)/float64(s.Delisted)*1000) / 1000
+		stats = append(stats, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating delisting stats: %v", err)
+	}
+	return stats, nil
+}
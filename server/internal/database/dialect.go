@@ -0,0 +1,167 @@
+package database
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Supported database drivers
+const (
+	DriverSQLite   = "sqlite"
+	DriverPostgres = "postgres"
+)
+
+// Dialect hides the SQL differences between the supported database engines.
+// Queries are written with ? placeholders and SQLite-compatible SQL; anything
+// that differs between engines goes through one of these helpers.
+type Dialect interface {
+	// Name returns the driver name used in configuration
+	Name() string
+	// DriverName returns the database/sql driver to open
+	DriverName() string
+	// Rebind rewrites ? placeholders into the engine's placeholder syntax
+	Rebind(query string) string
+	// DaysBetween returns an expression for the number of days between two date columns
+	DaysBetween(from, to string) string
+	// DateOffset returns today shifted by a modifier such as "-12 months" as YYYY-MM-DD text
+	DateOffset(modifier string) string
+	// TimestampOffset returns the current timestamp shifted by a bound modifier such as "-90 days"
+	TimestampOffset() string
+	// GroupConcat returns a comma separated aggregate of expr
+	GroupConcat(expr string) string
+	// Truncate returns the integer part of a non-negative numeric expression
+	Truncate(expr string) string
+	// IndexCountQuery returns a query counting the indexes with a bound name
+	IndexCountQuery() string
+	// UniqueIndexCountQuery returns a query counting the unique indexes covering
+	// exactly one column, with the table and column bound in that order
+	UniqueIndexCountQuery() string
+	// DDL translates a schema statement written for SQLite
+	DDL(stmt string) string
+}
+
+// NewDialect returns the dialect for a configured driver name
+func NewDialect(driver string) (Dialect, error) {
+	switch strings.ToLower(driver) {
+	case "", DriverSQLite, "sqlite3":
+		return sqliteDialect{}, nil
+	case DriverPostgres, "postgresql", "pgx":
+		return postgresDialect{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported database driver: %s", driver)
+	}
+}
+
+type sqliteDialect struct{}
+
+func (sqliteDialect) Name() string               { return DriverSQLite }
+func (sqliteDialect) DriverName() string         { return "sqlite3" }
+func (sqliteDialect) Rebind(query string) string { return query }
+
+func (sqliteDialect) DaysBetween(from, to string) string {
+	return fmt.Sprintf("julianday(%s) - julianday(%s)", to, from)
+}
+
+func (sqliteDialect) DateOffset(modifier string) string {
+	return fmt.Sprintf("date('now', '%s')", modifier)
+}
+
+func (sqliteDialect) TimestampOffset() string {
+	return "datetime('now', ?)"
+}
+
+func (sqliteDialect) GroupConcat(expr string) string {
+	return fmt.Sprintf("GROUP_CONCAT(%s)", expr)
+}
+
+func (sqliteDialect) Truncate(expr string) string {
+	return fmt.Sprintf("CAST(%s AS INTEGER)", expr)
+}
+
+func (sqliteDialect) IndexCountQuery() string {
+	return "SELECT COUNT(*) FROM sqlite_master WHERE type = 'index' AND name = ?"
+}
+
+func (sqliteDialect) UniqueIndexCountQuery() string {
+	return `SELECT COUNT(*) FROM pragma_index_list(?) AS il
+		WHERE il."unique" = 1
+		AND (SELECT COUNT(*) FROM pragma_index_info(il.name)) = 1
+		AND (SELECT name FROM pragma_index_info(il.name)) = ?`
+}
+
+func (sqliteDialect) DDL(stmt string) string { return stmt }
+
+type postgresDialect struct{}
+
+func (postgresDialect) Name() string       { return DriverPostgres }
+func (postgresDialect) DriverName() string { return "postgres" }
+
+// Rebind numbers the ? placeholders as $1, $2, ... leaving string literals untouched
+func (postgresDialect) Rebind(query string) string {
+	var b strings.Builder
+	b.Grow(len(query) + 16)
+	n := 0
+	inString := false
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case c == '\'':
+			inString = !inString
+			b.WriteByte(c)
+		case c == '?' && !inString:
+			n++
+			b.WriteByte('$')
+			b.WriteString(strconv.Itoa(n))
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+func (postgresDialect) DaysBetween(from, to string) string {
+	return fmt.Sprintf("(CAST(%s AS DATE) - CAST(%s AS DATE))", to, from)
+}
+
+func (postgresDialect) DateOffset(modifier string) string {
+	return fmt.Sprintf("to_char(CURRENT_DATE + INTERVAL '%s', 'YYYY-MM-DD')", modifier)
+}
+
+func (postgresDialect) TimestampOffset() string {
+	return "CURRENT_TIMESTAMP + CAST(? AS INTERVAL)"
+}
+
+func (postgresDialect) GroupConcat(expr string) string {
+	return fmt.Sprintf("string_agg(CAST(%s AS TEXT), ',')", expr)
+}
+
+// Truncate truncates before the cast, which rounds in PostgreSQL
+func (postgresDialect) Truncate(expr string) string {
+	return fmt.Sprintf("CAST(TRUNC(%s) AS INTEGER)", expr)
+}
+
+func (postgresDialect) IndexCountQuery() string {
+	return "SELECT COUNT(*) FROM pg_indexes WHERE indexname = ?"
+}
+
+func (postgresDialect) UniqueIndexCountQuery() string {
+	return `SELECT COUNT(*) FROM pg_index i
+		JOIN pg_attribute a ON a.attrelid = i.indrelid AND a.attnum = i.indkey[0]
+		WHERE i.indrelid = to_regclass(?) AND i.indisunique AND i.indnatts = 1
+		AND a.attname = ?`
+}
+
+// postgresTypes maps SQLite column types to their PostgreSQL equivalents.
+// Flag columns compared against 0 and 1 in queries stay integers.
+var postgresTypes = strings.NewReplacer(
+	"INTEGER PRIMARY KEY AUTOINCREMENT", "BIGSERIAL PRIMARY KEY",
+	"BOOLEAN DEFAULT 0", "INTEGER DEFAULT 0",
+	"BOOLEAN DEFAULT 1", "BOOLEAN DEFAULT TRUE",
+	" REAL", " DOUBLE PRECISION",
+	" BLOB", " BYTEA",
+)
+
+func (postgresDialect) DDL(stmt string) string {
+	return postgresTypes.Replace(stmt)
+}
@@ -0,0 +1,138 @@
+package database
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"fundamental/server/internal/models"
+	"time"
+)
+
+const districtBoundaryColumns = `id, source, valid_from, valid_to, districts, checksum, parameters`
+
+func scanDistrictBoundaryVersion(row rowScanner, extra ...interface{}) (*models.DistrictBoundaryVersion, error) {
+	var version models.DistrictBoundaryVersion
+	var validTo sql.NullTime
+	var parameters sql.NullString
+	dest := append([]interface{}{&version.ID, &version.Source, &version.ValidFrom, &validTo,
+		&version.Districts, &version.Checksum, &parameters}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+	if validTo.Valid {
+		version.ValidTo = &validTo.Time
+	}
+	if parameters.Valid && parameters.String != "" {
+		version.Parameters = json.RawMessage(parameters.String)
+	}
+	return &version, nil
+}
+
+// SaveDistrictBoundaries records a set of district boundaries, given as the
+// JSON array of their GeoJSON features with the parameters they were
+// generated with, if any, as in force from validFrom. The
+// current version ends there and the new one becomes current, unless its
+// features are the same as the current version's, which is then returned with
+// false instead.
+func (d *Database) SaveDistrictBoundaries(source string, validFrom time.Time, districts int, features []byte, parameters json.RawMessage) (*models.DistrictBoundaryVersion, bool, error) {
+	sum := sha256.Sum256(features)
+	checksum := hex.EncodeToString(sum[:])
+	validFrom = validFrom.UTC()
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	current, err := scanDistrictBoundaryVersion(tx.QueryRow(
+		"SELECT " + districtBoundaryColumns + " FROM district_boundary_versions WHERE valid_to IS NULL"))
+	if err != nil && err != sql.ErrNoRows {
+		return nil, false, fmt.Errorf("failed to get current district boundaries: %v", err)
+	}
+	if current != nil {
+		if current.Checksum == checksum {
+			return current, false, nil
+		}
+		if !validFrom.After(current.ValidFrom) {
+			return nil, false, errorf(ErrConflict, "district boundaries must be newer than the current version, valid from %s",
+				current.ValidFrom.Format(time.RFC3339))
+		}
+		if _, err := tx.Exec("UPDATE district_boundary_versions SET valid_to = ? WHERE id = ?", validFrom, current.ID); err != nil {
+			return nil, false, fmt.Errorf("failed to end current district boundaries: %v", err)
+		}
+	}
+
+	version, err := scanDistrictBoundaryVersion(tx.QueryRow(`
+		INSERT INTO district_boundary_versions (source, valid_from, districts, checksum, features, parameters)
+		VALUES (?, ?, ?, ?, ?, ?)
+		RETURNING `+districtBoundaryColumns,
+		source, validFrom, districts, checksum, string(features), nullJSON(parameters)))
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to insert district boundaries: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, false, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return version, true, nil
+}
+
+// GetDistrictBoundaries returns a version of the district boundaries with the
+// JSON array of its features, or nil if it does not exist
+func (d *Database) GetDistrictBoundaries(id int64) (*models.DistrictBoundaryVersion, []byte, error) {
+	return d.getDistrictBoundaries("WHERE id = ?", id)
+}
+
+// DistrictBoundariesAt returns the district boundaries in force at a time,
+// with the JSON array of their features: the current version for a zero time,
+// and the oldest version for a time before it, the best known for the years
+// before versions were kept. It returns nil when no version was recorded.
+func (d *Database) DistrictBoundariesAt(at time.Time) (*models.DistrictBoundaryVersion, []byte, error) {
+	if at.IsZero() {
+		return d.getDistrictBoundaries("WHERE valid_to IS NULL")
+	}
+	version, features, err := d.getDistrictBoundaries(
+		"WHERE valid_from <= ? ORDER BY valid_from DESC LIMIT 1", at.UTC())
+	if version != nil || err != nil {
+		return version, features, err
+	}
+	return d.getDistrictBoundaries("ORDER BY valid_from LIMIT 1")
+}
+
+func (d *Database) getDistrictBoundaries(where string, args ...interface{}) (*models.DistrictBoundaryVersion, []byte, error) {
+	var features string
+	version, err := scanDistrictBoundaryVersion(d.db.QueryRow(
+		"SELECT "+districtBoundaryColumns+", features FROM district_boundary_versions "+where, args...), &features)
+	if err == sql.ErrNoRows {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get district boundaries: %v", err)
+	}
+	return version, []byte(features), nil
+}
+
+// ListDistrictBoundaryVersions returns the versions of the district
+// boundaries, newest first, without their features
+func (d *Database) ListDistrictBoundaryVersions() ([]models.DistrictBoundaryVersion, error) {
+	rows, err := d.db.Query("SELECT " + districtBoundaryColumns + " FROM district_boundary_versions ORDER BY valid_from DESC")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query district boundary versions: %v", err)
+	}
+	defer rows.Close()
+
+	versions := []models.DistrictBoundaryVersion{}
+	for rows.Next() {
+		version, err := scanDistrictBoundaryVersion(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan district boundary version: %v", err)
+		}
+		versions = append(versions, *version)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating district boundary versions: %v", err)
+	}
+	return versions, nil
+}
@@ -0,0 +1,108 @@
+package database
+
+import (
+	"sync"
+	"time"
+)
+
+// districtCacheMaxAge bounds how long the medians of a district are served
+// without a scrape touching it, so changes made outside the spiders, and the
+// sales dropping out of the past year, are picked up
+const districtCacheMaxAge = 24 * time.Hour
+
+// districtCache keeps the district medians behind the price analysis of new
+// listing notifications. Unlike the stats cache it is not dropped whenever a
+// property changes: after each spider run WarmDistrictAnalysis recomputes the
+// districts the run touched, and the other districts keep their medians.
+type districtCache struct {
+	mu      sync.Mutex
+	entries map[string]districtEntry
+}
+
+// districtPrices are the medians and counts of GetDistrictPriceAnalysis
+type districtPrices struct {
+	activeMedian float64
+	activeCount  int
+	soldMedian   float64
+	soldCount    int
+}
+
+type districtEntry struct {
+	prices   districtPrices
+	computed time.Time
+}
+
+// newDistrictCache returns a district cache, or nil, which caches nothing,
+// when enabled is false
+func newDistrictCache(enabled bool) *districtCache {
+	if !enabled {
+		return nil
+	}
+	return &districtCache{entries: make(map[string]districtEntry)}
+}
+
+// get returns the cached medians of a district
+func (c *districtCache) get(district string) (districtPrices, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[district]
+	if !ok || time.Since(entry.computed) > districtCacheMaxAge {
+		return districtPrices{}, false
+	}
+	return entry.prices, true
+}
+
+// put stores the medians of a district
+func (c *districtCache) put(district string, prices districtPrices) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[district] = districtEntry{prices: prices, computed: time.Now()}
+}
+
+// GetDistrictPriceAnalysis returns median prices and counts for both active and sold properties
+func (d *Database) GetDistrictPriceAnalysis(district string) (activeMedian float64, activeCount int, soldMedian float64, soldCount int, err error) {
+	if d.districts != nil {
+		if p, ok := d.districts.get(district); ok {
+			return p.activeMedian, p.activeCount, p.soldMedian, p.soldCount, nil
+		}
+	}
+	var p districtPrices
+	p.activeMedian, p.activeCount, p.soldMedian, p.soldCount, err = d.districtPriceAnalysis(district)
+	if err != nil {
+		return 0, 0, 0, 0, err
+	}
+	if d.districts != nil {
+		d.districts.put(district, p)
+	}
+	return p.activeMedian, p.activeCount, p.soldMedian, p.soldCount, nil
+}
+
+// WarmDistrictAnalysis recomputes the medians of districts whose properties
+// changed, such as those touched by a spider run, so the next notifications
+// find them cached. It returns the first error but warms the other districts.
+func (d *Database) WarmDistrictAnalysis(districts []string) error {
+	if d.districts == nil {
+		return nil
+	}
+	var firstErr error
+	for _, district := range districts {
+		if district == "" {
+			continue
+		}
+		var p districtPrices
+		var err error
+		p.activeMedian, p.activeCount, p.soldMedian, p.soldCount, err = d.districtPriceAnalysis(district)
+		if err != nil {
+			// Drop the stale medians rather than serve them
+			d.districts.mu.Lock()
+			delete(d.districts.entries, district)
+			d.districts.mu.Unlock()
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		d.districts.put(district, p)
+	}
+	return firstErr
+}
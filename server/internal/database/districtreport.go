@@ -0,0 +1,206 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"fundamental/server/internal/models"
+	"math"
+	"time"
+)
+
+// GetDistrictReport assembles the scorecard of a 4-digit postal district,
+// without its market phase, which the caller derives from
+// GetDistrictMarketIndicators. Returns nil when the district has no properties.
+func (d *Database) GetDistrictReport(district string) (*models.DistrictReport, error) {
+	report := &models.DistrictReport{
+		District:     district,
+		GeneratedAt:  time.Now().UTC(),
+		EnergyLabels: []models.EnergyLabelShare{},
+	}
+
+	err := d.db.QueryRow(`
+		SELECT COALESCE(city, '') FROM properties
+		WHERE district = ?
+		GROUP BY city
+		ORDER BY COUNT(*) DESC, city
+		LIMIT 1
+	`, district).Scan(&report.City)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up district: %v", err)
+	}
+
+	if err := d.fillDistrictPrices(report); err != nil {
+		return nil, err
+	}
+	if err := d.fillDistrictEnergyLabels(report); err != nil {
+		return nil, err
+	}
+
+	republishing := &report.Republishing
+	err = d.db.QueryRow(`
+		SELECT
+			COALESCE(SUM(CASE WHEN listing_date >= `+d.dialect.DateOffset("-90 days")+` THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN listing_date >= `+d.dialect.DateOffset("-12 months")+` THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN listing_date >= `+d.dialect.DateOffset("-12 months")+`
+				AND (COALESCE(republish_count, 0) > 0 OR canonical_id IS NOT NULL) THEN 1 ELSE 0 END), 0)
+		FROM properties
+		WHERE district = ?
+	`, district).Scan(&report.Inventory.NewListings, &republishing.Listings, &republishing.Republished)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count district listings: %v", err)
+	}
+	if republishing.Listings > 0 {
+		rate := math.Round(float64(republishing.Republished)/float64(republishing.Listings)*1000) / 1000
+		republishing.Rate = &rate
+	}
+
+	withdrawals := &report.Withdrawals
+	withdrawals.District = district
+	err = d.db.QueryRow(`
+		SELECT COUNT(*),
+			COALESCE(SUM(CASE WHEN delisting_reason = 'sold' THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN delisting_reason = 'withdrawn' THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN delisting_reason = 'expired' THEN 1 ELSE 0 END), 0)
+		FROM properties
+		WHERE district = ?
+		AND delisting_reason IS NOT NULL
+		AND delisted_at > `+d.dialect.TimestampOffset()+`
+	`, district, "-12 months").Scan(&withdrawals.Delisted, &withdrawals.Sold, &withdrawals.Withdrawn, &withdrawals.Expired)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count district delistings: %v", err)
+	}
+	if withdrawals.Delisted > 0 {
+		withdrawals.WithdrawalRate = math.Round(float64(withdrawals.Withdrawn)/float64(withdrawals.Delisted)*1000) / 1000
+	}
+
+	return report, nil
+}
+
+// fillDistrictPrices sets the prices, velocity and active inventory of a
+// district report from the listings on the market and the sales of the past
+// two years
+func (d *Database) fillDistrictPrices(report *models.DistrictReport) error {
+	rows, err := d.db.Query(`
+		SELECT status, price, living_area,
+		       CASE WHEN status = 'sold' AND listing_date IS NOT NULL AND `+exactSellingDate("")+`
+		            THEN `+d.dialect.DaysBetween("listing_date", "selling_date")+` END,
+		       CASE WHEN status = 'sold' AND selling_date < `+d.dialect.DateOffset("-12 months")+` THEN 1 ELSE 0 END
+		FROM properties
+		WHERE district = ?
+		AND (
+			status IN ('active', 'republished')
+			OR (status = 'sold' AND selling_date >= `+d.dialect.DateOffset("-24 months")+`)
+		)
+	`, report.District)
+	if err != nil {
+		return fmt.Errorf("failed to query district prices: %v", err)
+	}
+	defer rows.Close()
+
+	var soldPrices, soldPerSqm, priorPerSqm, askingPrices, askingPerSqm, daysToSell []float64
+	for rows.Next() {
+		var status string
+		var price, livingArea, days sql.NullFloat64
+		var prior bool
+		if err := rows.Scan(&status, &price, &livingArea, &days, &prior); err != nil {
+			return fmt.Errorf("failed to scan district price: %v", err)
+		}
+
+		validPrice := price.Valid && price.Float64 >= 50000 && price.Float64 <= 10000000
+		validArea := livingArea.Valid && livingArea.Float64 >= 15 && livingArea.Float64 <= 1000
+		switch {
+		case status != "sold":
+			report.Inventory.Active++
+			if validPrice {
+				askingPrices = append(askingPrices, price.Float64)
+				if validArea {
+					askingPerSqm = append(askingPerSqm, price.Float64/livingArea.Float64)
+				}
+			}
+		case prior:
+			if validPrice && validArea {
+				priorPerSqm = append(priorPerSqm, price.Float64/livingArea.Float64)
+			}
+		default:
+			report.Velocity.Sales++
+			if validPrice {
+				soldPrices = append(soldPrices, price.Float64)
+				if validArea {
+					soldPerSqm = append(soldPerSqm, price.Float64/livingArea.Float64)
+				}
+			}
+			if days.Valid && days.Float64 >= 0 {
+				daysToSell = append(daysToSell, days.Float64)
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating district prices: %v", err)
+	}
+
+	prices := &report.Prices
+	for _, m := range []struct {
+		values []float64
+		target **float64
+	}{
+		{soldPrices, &prices.MedianSoldPrice},
+		{soldPerSqm, &prices.MedianSoldPricePerSqm},
+		{priorPerSqm, &prices.PriorSoldPricePerSqm},
+		{askingPrices, &prices.MedianAskingPrice},
+		{askingPerSqm, &prices.MedianAskingPricePerSqm},
+		{daysToSell, &report.Velocity.MedianDaysToSell},
+	} {
+		if len(m.values) > 0 {
+			v := math.Round(median(m.values))
+			*m.target = &v
+		}
+	}
+	if prices.MedianSoldPricePerSqm != nil && prices.PriorSoldPricePerSqm != nil {
+		change := math.Round((*prices.MedianSoldPricePerSqm / *prices.PriorSoldPricePerSqm - 1)*1000) / 1000
+		prices.YearlyChange = &change
+	}
+	report.Velocity.SalesPerMonth = math.Round(float64(report.Velocity.Sales)/12*10) / 10
+	return nil
+}
+
+// fillDistrictEnergyLabels sets the energy label mix of the homes of a
+// district on the market or sold over the past year
+func (d *Database) fillDistrictEnergyLabels(report *models.DistrictReport) error {
+	rows, err := d.db.Query(`
+		SELECT COALESCE(NULLIF(energy_label, ''), 'unknown') AS label, COUNT(*)
+		FROM properties
+		WHERE district = ?
+		AND (
+			status IN ('active', 'republished')
+			OR (status = 'sold' AND selling_date >= `+d.dialect.DateOffset("-12 months")+`)
+		)
+		GROUP BY label
+		ORDER BY label
+	`, report.District)
+	if err != nil {
+		return fmt.Errorf("failed to query district energy labels: %v", err)
+	}
+	defer rows.Close()
+
+	var total int
+	for rows.Next() {
+		var share models.EnergyLabelShare
+		if err := rows.Scan(&share.Label, &share.Count); err != nil {
+			return fmt.Errorf("failed to scan district energy label: %v", err)
+		}
+		total += share.Count
+		report.EnergyLabels = append(report.EnergyLabels, share)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating district energy labels: %v", err)
+	}
+
+	for i := range report.EnergyLabels {
+		share := float64(report.EnergyLabels[i].Count) / float64(total)
+		report.EnergyLabels[i].Share = math.Round(share*1000) / 1000
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"fundamental/server/internal/models"
+	"math"
+	"sort"
+	"strings"
+)
+
+// energyLabelOrder ranks the energy labels from best to worst; other labels
+// sort after them and "unknown" last
+var energyLabelOrder = []string{"A+++++", "A++++", "A+++", "A++", "A+", "A", "B", "C", "D", "E", "F", "G"}
+
+// energyLabelRank returns the sort position of a label
+func energyLabelRank(label string) int {
+	for i, l := range energyLabelOrder {
+		if l == label {
+			return i
+		}
+	}
+	if label == "unknown" {
+		return len(energyLabelOrder) + 1
+	}
+	return len(energyLabelOrder)
+}
+
+// energyLabelGroup collects the prices of a city or district per label
+type energyLabelGroup struct {
+	stats     models.EnergyLabelStats
+	counts    map[string]int
+	perSqm    map[string][]float64
+	allPerSqm []float64
+}
+
+// GetEnergyLabelStats returns the energy label mix of the homes sold over the
+// past months per city, or per district when byDistrict is set, optionally in
+// one city, with the median price per m² of each label and its premium over
+// the baseline label. Implausible prices and living areas are counted but left
+// out of the medians.
+func (d *Database) GetEnergyLabelStats(city string, byDistrict bool, baseline string, months int) ([]models.EnergyLabelStats, error) {
+	group, grouped := "COALESCE(city, '')", "1 = 1"
+	if byDistrict {
+		group, grouped = "district", "district IS NOT NULL"
+	}
+	rows, err := d.db.Query(`
+		SELECT `+group+`, COALESCE(NULLIF(UPPER(TRIM(energy_label)), ''), 'unknown'), price, living_area
+		FROM properties
+		WHERE status = 'sold'
+		AND selling_date >= `+d.dialect.DateOffset(fmt.Sprintf("-%d months", months))+`
+		AND `+grouped+`
+		AND `+cityFilter(city)+`
+	`, city, city)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query energy labels: %v", err)
+	}
+	defer rows.Close()
+
+	groups := make(map[string]*energyLabelGroup)
+	for rows.Next() {
+		var name, label string
+		var price, livingArea sql.NullFloat64
+		if err := rows.Scan(&name, &label, &price, &livingArea); err != nil {
+			return nil, fmt.Errorf("failed to scan energy label: %v", err)
+		}
+
+		g, ok := groups[name]
+		if !ok {
+			g = &energyLabelGroup{counts: map[string]int{}, perSqm: map[string][]float64{}}
+			if byDistrict {
+				g.stats.District = name
+			} else {
+				g.stats.City = name
+			}
+			groups[name] = g
+		}
+		g.stats.Homes++
+		g.counts[label]++
+		validPrice := price.Valid && price.Float64 >= 50000 && price.Float64 <= 10000000
+		validArea := livingArea.Valid && livingArea.Float64 >= 15 && livingArea.Float64 <= 1000
+		if validPrice && validArea {
+			perSqm := price.Float64 / livingArea.Float64
+			g.perSqm[label] = append(g.perSqm[label], perSqm)
+			g.allPerSqm = append(g.allPerSqm, perSqm)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating energy labels: %v", err)
+	}
+
+	baseline = strings.ToUpper(baseline)
+	stats := make([]models.EnergyLabelStats, 0, len(groups))
+	for _, g := range groups {
+		s := g.stats
+		s.MedianPricePerSqm = roundedMedian(g.allPerSqm)
+		s.BaselineLabel = baseline
+		s.BaselinePricePerSqm = roundedMedian(g.perSqm[baseline])
+
+		s.Labels = make([]models.EnergyLabelPremium, 0, len(g.counts))
+		for label, count := range g.counts {
+			premium := models.EnergyLabelPremium{
+				Label:             label,
+				Count:             count,
+				Share:             math.Round(float64(count)/float64(s.Homes)*1000) / 1000,
+				MedianPricePerSqm: roundedMedian(g.perSqm[label]),
+			}
+			if premium.MedianPricePerSqm != nil && s.BaselinePricePerSqm != nil {
+				p := math.Round((*premium.MedianPricePerSqm / *s.BaselinePricePerSqm - 1)*1000) / 1000
+				premium.Premium = &p
+			}
+			s.Labels = append(s.Labels, premium)
+		}
+		sort.Slice(s.Labels, func(i, j int) bool {
+			ri, rj := energyLabelRank(s.Labels[i].Label), energyLabelRank(s.Labels[j].Label)
+			if ri != rj {
+				return ri < rj
+			}
+			return s.Labels[i].Label < s.Labels[j].Label
+		})
+		stats = append(stats, s)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].City+stats[i].District < stats[j].City+stats[j].District
+	})
+	return stats, nil
+}
@@ -0,0 +1,41 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Kinds of errors returned by the database layer. An error of a kind wraps it,
+// so callers tell them apart with errors.Is, such as
+// errors.Is(err, database.ErrNotFound); other errors are failures of the
+// database itself.
+var (
+	// ErrNotFound is returned when the record an operation acts on does not
+	// exist. Getters return nil instead.
+	ErrNotFound = errors.New("not found")
+	// ErrConflict is returned when an operation clashes with the stored data,
+	// such as a name that is already taken
+	ErrConflict = errors.New("conflict")
+	// ErrValidation is returned when the input of an operation is invalid
+	ErrValidation = errors.New("invalid input")
+)
+
+// kindError is an error of one of the kinds above with its own message
+type kindError struct {
+	kind    error
+	message string
+}
+
+func (e *kindError) Error() string { return e.message }
+
+func (e *kindError) Unwrap() error { return e.kind }
+
+// newError returns an error of a kind
+func newError(kind error, message string) error {
+	return &kindError{kind: kind, message: message}
+}
+
+// errorf formats an error of a kind; %w verbs in format are not unwrapped
+func errorf(kind error, format string, args ...interface{}) error {
+	return newError(kind, fmt.Sprintf(format, args...))
+}
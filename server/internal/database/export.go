@@ -0,0 +1,218 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"fundamental/server/internal/models"
+)
+
+// PropertyIterator reads the properties of a query one row at a time, like
+// sql.Rows. Call Next before each Property and Close when done.
+type PropertyIterator struct {
+	rows     *sql.Rows
+	tags     map[int64][]string
+	property models.Property
+	err      error
+}
+
+// IterateProperties returns an iterator over the properties matching the
+// filter in id order. Memory use does not grow with the number of properties,
+// and cancelling ctx stops the query.
+func (d *Database) IterateProperties(ctx context.Context, filter models.PropertyFilter) (*PropertyIterator, error) {
+	// Tags are far fewer than properties, so they are read up front
+	names, err := d.propertyTagNames(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	where, args := exportFilter(filter)
+	rows, err := d.db.QueryContext(ctx, "SELECT "+propertyColumns+" FROM properties WHERE "+where+" ORDER BY id", args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query properties: %v", err)
+	}
+	return &PropertyIterator{rows: rows, tags: names}, nil
+}
+
+// CountExportProperties returns the number of properties IterateProperties
+// returns for the filter
+func (d *Database) CountExportProperties(filter models.PropertyFilter) (int, error) {
+	where, args := exportFilter(filter)
+	var count int
+	if err := d.db.QueryRow("SELECT COUNT(*) FROM properties WHERE "+where, args...).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count properties: %v", err)
+	}
+	return count, nil
+}
+
+// exportFilter returns the condition and arguments selecting the properties
+// of an export
+func exportFilter(filter models.PropertyFilter) (string, []interface{}) {
+	tags, tagArgs := tagFilter(filter.Tags)
+	where := propertyListFilter(filter.City) + `
+        AND (? = '' OR status = ?)
+        AND ` + tags
+	args := propertyListArgs(filter.StartDate, filter.EndDate, filter.City)
+	args = append(args, filter.Status, filter.Status)
+	args = append(args, tagArgs...)
+	return where, args
+}
+
+// IterateAllProperties returns an iterator over every property in id order,
+// whatever its status and dates, for copies of the whole table
+func (d *Database) IterateAllProperties(ctx context.Context) (*PropertyIterator, error) {
+	names, err := d.propertyTagNames(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := d.db.QueryContext(ctx, "SELECT "+propertyColumns+" FROM properties ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query properties: %v", err)
+	}
+	return &PropertyIterator{rows: rows, tags: names}, nil
+}
+
+// IteratePropertiesByCity returns an iterator over every property, or those
+// of one city, grouped by city and then by the year of the listing date or,
+// without one, the scrape, as the partitions of a dataset are written
+func (d *Database) IteratePropertiesByCity(ctx context.Context, city string) (*PropertyIterator, error) {
+	names, err := d.propertyTagNames(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := d.db.QueryContext(ctx, `
+        SELECT `+propertyColumns+`
+        FROM properties
+        WHERE `+cityFilter(city)+`
+        ORDER BY COALESCE(city, ''), SUBSTR(COALESCE(listing_date, CAST(scraped_at AS TEXT)), 1, 4), id`,
+		city, city)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query properties: %v", err)
+	}
+	return &PropertyIterator{rows: rows, tags: names}, nil
+}
+
+// CountPropertiesByCity returns the number of properties
+// IteratePropertiesByCity returns for a city, or for all cities
+func (d *Database) CountPropertiesByCity(city string) (int, error) {
+	var count int
+	if err := d.db.QueryRow("SELECT COUNT(*) FROM properties WHERE "+cityFilter(city), city, city).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count properties: %v", err)
+	}
+	return count, nil
+}
+
+// Next reads the next property, returning false at the end or on an error
+func (it *PropertyIterator) Next() bool {
+	if it.err != nil || !it.rows.Next() {
+		return false
+	}
+	it.property, it.err = scanProperty(it.rows)
+	it.property.Tags = it.tags[it.property.ID]
+	return it.err == nil
+}
+
+// Property returns the property read by the last call to Next
+func (it *PropertyIterator) Property() models.Property {
+	return it.property
+}
+
+// Err returns the error that stopped the iteration, if any
+func (it *PropertyIterator) Err() error {
+	if it.err != nil {
+		return fmt.Errorf("failed to scan property: %v", it.err)
+	}
+	return it.rows.Err()
+}
+
+// Close releases the rows of the iterator
+func (it *PropertyIterator) Close() error {
+	return it.rows.Close()
+}
+
+// HistoryIterator reads property history entries one row at a time, like
+// PropertyIterator
+type HistoryIterator struct {
+	rows  *sql.Rows
+	event models.PropertyChangeEvent
+	err   error
+}
+
+// historyExportColumns are the columns read by HistoryIterator from a history
+// table joined with its properties table
+const historyExportColumns = `h.property_id, p.url, COALESCE(p.street, ''), COALESCE(p.city, '') AS city,
+            SUBSTR(CAST(h.created_at AS TEXT), 1, 4) AS year, h.id AS id,
+            h.change_type, COALESCE(h.status, ''), h.price, h.previous_status, h.previous_price, h.created_at`
+
+// IterateHistoryByCity returns an iterator over the price and status changes
+// of every property, or those of one city, archived properties included,
+// grouped by city and then by the year of the change
+func (d *Database) IterateHistoryByCity(ctx context.Context, city string) (*HistoryIterator, error) {
+	rows, err := d.db.QueryContext(ctx, `
+        SELECT `+historyExportColumns+`
+        FROM property_history h
+        JOIN properties p ON p.id = h.property_id
+        WHERE h.change_type IS NOT NULL AND `+cityFilter(city)+`
+        UNION ALL
+        SELECT `+historyExportColumns+`
+        FROM property_history_archive h
+        JOIN properties_archive p ON p.id = h.property_id
+        WHERE h.change_type IS NOT NULL AND `+cityFilter(city)+`
+        ORDER BY city, year, id`,
+		city, city, city, city)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query property history: %v", err)
+	}
+	return &HistoryIterator{rows: rows}, nil
+}
+
+// Next reads the next history entry, returning false at the end or on an error
+func (it *HistoryIterator) Next() bool {
+	if it.err != nil || !it.rows.Next() {
+		return false
+	}
+
+	var event models.PropertyChangeEvent
+	var year sql.NullString
+	var id int64
+	var price, previousPrice sql.NullInt64
+	var previousStatus sql.NullString
+	it.err = it.rows.Scan(&event.PropertyID, &event.URL, &event.Street, &event.City, &year, &id,
+		&event.ChangeType, &event.Status, &price, &previousStatus, &previousPrice, &event.ChangedAt)
+	if it.err != nil {
+		return false
+	}
+	if price.Valid {
+		p := int(price.Int64)
+		event.Price = &p
+	}
+	if previousStatus.Valid {
+		event.PreviousStatus = &previousStatus.String
+	}
+	if previousPrice.Valid {
+		p := int(previousPrice.Int64)
+		event.PreviousPrice = &p
+	}
+	it.event = event
+	return true
+}
+
+// Event returns the history entry read by the last call to Next
+func (it *HistoryIterator) Event() models.PropertyChangeEvent {
+	return it.event
+}
+
+// Err returns the error that stopped the iteration, if any
+func (it *HistoryIterator) Err() error {
+	if it.err != nil {
+		return fmt.Errorf("failed to scan property history: %v", it.err)
+	}
+	return it.rows.Err()
+}
+
+// Close releases the rows of the iterator
+func (it *HistoryIterator) Close() error {
+	return it.rows.Close()
+}
@@ -0,0 +1,138 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// geocodePass geocodes the properties without coordinates, reporting progress
+type geocodePass func(ctx context.Context, progress func(done, total int)) error
+
+// geocodeRuns runs one geocoding pass at a time. The spider managers, the
+// geocode task and the startup run all geocode after their own triggers; a
+// caller arriving while a pass runs attaches to it, getting its progress and
+// its result, instead of starting a second pass that would query Nominatim for
+// the same addresses and race on geocoding_attempted.
+type geocodeRuns struct {
+	mu       sync.Mutex
+	current  *geocodeRun
+	nextID   int
+	observer GeocodingObserver
+}
+
+// GeocodingObserver follows every geocoding pass of a database, whoever
+// started it
+type GeocodingObserver interface {
+	GeocodingProgress(done, total int)
+	GeocodingFinished(done, total int, err error)
+}
+
+// ObserveGeocoding reports the progress and the outcome of the geocoding
+// passes to o
+func (d *Database) ObserveGeocoding(o GeocodingObserver) {
+	d.geocoding.mu.Lock()
+	defer d.geocoding.mu.Unlock()
+	d.geocoding.observer = o
+}
+
+// geocodeRun is the pass in flight and the callers attached to it
+type geocodeRun struct {
+	cancel    context.CancelFunc
+	finished  chan struct{}
+	err       error
+	listeners map[int]func(done, total int)
+	// notifying delivers progress to one listener at a time, in order
+	notifying sync.Mutex
+	done      int
+	total     int
+}
+
+// run runs pass, or attaches to the pass in flight, and returns its error.
+// progress, which may be nil, receives the progress of the pass from the
+// moment the caller attaches. A caller whose ctx is cancelled detaches and
+// gets its ctx error; the pass stops once every caller has detached.
+func (r *geocodeRuns) run(ctx context.Context, pass geocodePass, progress func(done, total int)) error {
+	r.mu.Lock()
+	current := r.current
+	if current == nil {
+		passCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		current = &geocodeRun{
+			cancel:    cancel,
+			finished:  make(chan struct{}),
+			listeners: make(map[int]func(done, total int)),
+		}
+		r.current = current
+		go r.execute(passCtx, current, pass)
+	}
+	id := r.nextID
+	r.nextID++
+	if progress != nil {
+		current.listeners[id] = progress
+	} else {
+		current.listeners[id] = func(int, int) {}
+	}
+	r.mu.Unlock()
+
+	// Catch up on the progress so far before the pass reports more
+	current.notifying.Lock()
+	r.mu.Lock()
+	done, total := current.done, current.total
+	r.mu.Unlock()
+	if progress != nil && total > 0 {
+		progress(done, total)
+	}
+	current.notifying.Unlock()
+
+	select {
+	case <-current.finished:
+		return current.err
+	case <-ctx.Done():
+		r.mu.Lock()
+		delete(current.listeners, id)
+		if len(current.listeners) == 0 {
+			current.cancel()
+		}
+		r.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// execute runs a pass and hands its result to the attached callers. A panic
+// of the pass fails it rather than leaving its callers waiting.
+func (r *geocodeRuns) execute(ctx context.Context, run *geocodeRun, pass geocodePass) {
+	var err error
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = fmt.Errorf("geocoding pass panicked: %v", recovered)
+		}
+		r.mu.Lock()
+		r.current = nil
+		run.err = err
+		observer, done, total := r.observer, run.done, run.total
+		r.mu.Unlock()
+		run.cancel()
+		close(run.finished)
+		if observer != nil {
+			observer.GeocodingFinished(done, total, err)
+		}
+	}()
+
+	err = pass(ctx, func(done, total int) {
+		run.notifying.Lock()
+		defer run.notifying.Unlock()
+		r.mu.Lock()
+		run.done, run.total = done, total
+		listeners := make([]func(done, total int), 0, len(run.listeners)+1)
+		for _, listener := range run.listeners {
+			listeners = append(listeners, listener)
+		}
+		if r.observer != nil {
+			listeners = append(listeners, r.observer.GeocodingProgress)
+		}
+		r.mu.Unlock()
+		for _, listener := range listeners {
+			listener(done, total)
+		}
+	})
+}
@@ -0,0 +1,206 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"fundamental/server/internal/models"
+	"os"
+	"sync"
+	"time"
+)
+
+// geocodingLeaseTTL is how long a geocoding lease lasts without being renewed.
+// The holder renews it several times per period, so only a server that
+// stopped, or lost its database, lets it expire during a pass.
+const geocodingLeaseTTL = 2 * time.Minute
+
+// ErrGeocodingLeased is returned when another server holds the geocoding lease
+var ErrGeocodingLeased = newError(ErrConflict, "geocoding is running on another server")
+
+// instanceName names this server as the owner of leases
+var instanceName = func() string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s:%d", host, os.Getpid())
+}()
+
+// pendingGeocodingCondition selects the properties waiting to be geocoded
+const pendingGeocodingCondition = `(latitude IS NULL OR longitude IS NULL)
+		AND geocoding_attempted = 0
+		AND street IS NOT NULL
+		AND postal_code IS NOT NULL
+		AND city IS NOT NULL`
+
+// withGeocodingLease runs a geocoding pass while holding the geocoding lease,
+// renewing it with the progress of the pass. It returns ErrGeocodingLeased
+// without running the pass when another server holds the lease, and stops the
+// pass when the lease is taken over.
+func (d *Database) withGeocodingLease(ctx context.Context, pass geocodePass, progress func(done, total int)) error {
+	if err := d.acquireGeocodingLease(); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var mu sync.Mutex
+	var done, total int
+	counts := func() (int, int) {
+		mu.Lock()
+		defer mu.Unlock()
+		return done, total
+	}
+
+	var lost error
+	renewing := make(chan struct{})
+	go func() {
+		defer close(renewing)
+		ticker := time.NewTicker(geocodingLeaseTTL / 4)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				err := d.renewGeocodingLease(counts())
+				if errors.Is(err, ErrGeocodingLeased) {
+					lost = err
+					cancel()
+					return
+				}
+				if err != nil {
+					fmt.Printf("Failed to renew the geocoding lease: %v\n", err)
+				}
+			}
+		}
+	}()
+
+	err := pass(ctx, func(n, t int) {
+		mu.Lock()
+		done, total = n, t
+		mu.Unlock()
+		progress(n, t)
+	})
+	cancel()
+	<-renewing
+	if lost != nil {
+		return lost
+	}
+
+	if releaseErr := d.releaseGeocodingLease(counts()); releaseErr != nil && err == nil {
+		err = releaseErr
+	}
+	return err
+}
+
+// acquireGeocodingLease takes the geocoding lease when it is free, expired or
+// already held by this server
+func (d *Database) acquireGeocodingLease() error {
+	now := time.Now().UTC()
+	result, err := d.db.Exec(`
+		INSERT INTO geocoding_lease (id, owner, acquired_at, renewed_at, expires_at, done, total)
+		VALUES (1, ?, ?, ?, ?, 0, 0)
+		ON CONFLICT (id) DO UPDATE SET
+			owner = excluded.owner,
+			acquired_at = excluded.acquired_at,
+			renewed_at = excluded.renewed_at,
+			expires_at = excluded.expires_at,
+			done = 0,
+			total = 0
+		WHERE geocoding_lease.owner = excluded.owner
+		OR geocoding_lease.expires_at <= excluded.acquired_at
+	`, instanceName, now, now, now.Add(geocodingLeaseTTL))
+	if err != nil {
+		return fmt.Errorf("failed to acquire geocoding lease: %v", err)
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return fmt.Errorf("failed to acquire geocoding lease: %v", err)
+	} else if n == 0 {
+		return d.geocodingLeaseHeld()
+	}
+	return nil
+}
+
+// renewGeocodingLease extends the lease of this server and records the
+// progress of its pass
+func (d *Database) renewGeocodingLease(done, total int) error {
+	now := time.Now().UTC()
+	return d.updateGeocodingLease(now, now.Add(geocodingLeaseTTL), done, total)
+}
+
+// releaseGeocodingLease ends the lease of this server, keeping it as the
+// record of the last pass
+func (d *Database) releaseGeocodingLease(done, total int) error {
+	now := time.Now().UTC()
+	return d.updateGeocodingLease(now, now, done, total)
+}
+
+func (d *Database) updateGeocodingLease(renewedAt, expiresAt time.Time, done, total int) error {
+	result, err := d.db.Exec(`
+		UPDATE geocoding_lease
+		SET renewed_at = ?, expires_at = ?, done = ?, total = ?
+		WHERE id = 1 AND owner = ?
+	`, renewedAt, expiresAt, done, total, instanceName)
+	if err != nil {
+		return fmt.Errorf("failed to update geocoding lease: %v", err)
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return fmt.Errorf("failed to update geocoding lease: %v", err)
+	} else if n == 0 {
+		return d.geocodingLeaseHeld()
+	}
+	return nil
+}
+
+// geocodingLeaseHeld returns ErrGeocodingLeased naming the lease holder
+func (d *Database) geocodingLeaseHeld() error {
+	lease, err := d.getGeocodingLease()
+	if err != nil {
+		return err
+	}
+	if lease == nil {
+		return ErrGeocodingLeased
+	}
+	return fmt.Errorf("%w: held by %s until %s", ErrGeocodingLeased, lease.Owner, lease.ExpiresAt.Format(time.RFC3339))
+}
+
+// getGeocodingLease returns the geocoding lease, or nil before the first pass
+func (d *Database) getGeocodingLease() (*models.GeocodingLease, error) {
+	var lease models.GeocodingLease
+	err := d.db.QueryRow(`
+		SELECT owner, acquired_at, renewed_at, expires_at, done, total
+		FROM geocoding_lease
+		WHERE id = 1
+	`).Scan(&lease.Owner, &lease.AcquiredAt, &lease.RenewedAt, &lease.ExpiresAt, &lease.Done, &lease.Total)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get geocoding lease: %v", err)
+	}
+	lease.Active = lease.ExpiresAt.After(time.Now())
+	return &lease, nil
+}
+
+// GetGeocodingStatus returns the number of properties waiting to be geocoded
+// and the lease of the current or last geocoding pass
+func (d *Database) GetGeocodingStatus() (*models.GeocodingStatus, error) {
+	status := models.GeocodingStatus{Instance: instanceName}
+	if err := d.db.QueryRow(`
+		SELECT COUNT(*)
+		FROM properties
+		WHERE ` + pendingGeocodingCondition).Scan(&status.Pending); err != nil {
+		return nil, fmt.Errorf("failed to count properties: %v", err)
+	}
+
+	lease, err := d.getGeocodingLease()
+	if err != nil {
+		return nil, err
+	}
+	status.Lease = lease
+	return &status, nil
+}
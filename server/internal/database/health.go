@@ -0,0 +1,26 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// Ping verifies a connection to the database can be made
+func (d *Database) Ping() error {
+	ctx, cancel := d.db.withTimeout()
+	defer cancel()
+	if err := d.db.PingContext(ctx); err != nil {
+		return fmt.Errorf("failed to reach %s: %v", d.dialect.Name(), err)
+	}
+	return nil
+}
+
+// AppliedSchemaVersion returns the version of the newest applied migration,
+// 0 when none was applied
+func (d *Database) AppliedSchemaVersion() (int, error) {
+	var version sql.NullInt64
+	if err := d.db.QueryRow("SELECT MAX(version) FROM schema_migrations").Scan(&version); err != nil {
+		return 0, fmt.Errorf("failed to get schema version: %v", err)
+	}
+	return int(version.Int64), nil
+}
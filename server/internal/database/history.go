@@ -0,0 +1,179 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"fundamental/server/internal/models"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// historyPrice converts a scraped or stored price to an integer. Spider items
+// carry JSON numbers, imports may carry strings.
+func historyPrice(value interface{}) sql.NullInt64 {
+	switch v := value.(type) {
+	case int:
+		return sql.NullInt64{Int64: int64(v), Valid: true}
+	case int64:
+		return sql.NullInt64{Int64: v, Valid: true}
+	case float64:
+		return sql.NullInt64{Int64: int64(math.Round(v)), Valid: true}
+	case string:
+		if n, err := strconv.ParseFloat(v, 64); err == nil {
+			return sql.NullInt64{Int64: int64(math.Round(n)), Valid: true}
+		}
+	}
+	return sql.NullInt64{}
+}
+
+// historyChange classifies the transition from the stored to the scraped state.
+// Returns an empty string when neither price nor status changed.
+func historyChange(previousStatus string, previousPrice, price sql.NullInt64, status string) string {
+	priceChanged := previousPrice != price
+	statusChanged := previousStatus != status
+	switch {
+	case priceChanged && statusChanged:
+		return models.HistoryPriceAndStatusChange
+	case priceChanged:
+		return models.HistoryPriceChange
+	case statusChanged:
+		return models.HistoryStatusChange
+	}
+	return ""
+}
+
+// historyInsert stores a history entry for a property, see propertyUpserter
+const historyInsert = `
+	INSERT INTO property_history
+	(property_id, change_type, status, price, listing_date, previous_status, previous_price)
+	VALUES (?, ?, ?, ?, ?, ?, ?)
+`
+
+// historyColumns are the property_history columns read by GetPropertyHistory
+const historyColumns = `id, change_type, COALESCE(status, ''), price, listing_date,
+			previous_status, previous_price, created_at`
+
+// GetPropertyHistory returns the price and status transitions of a property,
+// oldest first, including the history of an archived property
+func (d *Database) GetPropertyHistory(propertyID int64) ([]models.PropertyHistoryEntry, error) {
+	// A compound select at the top level keeps the column types of
+	// property_history, which the SQLite driver needs to scan created_at
+	rows, err := d.db.Query(`
+		SELECT `+historyColumns+`
+		FROM property_history
+		WHERE property_id = ? AND change_type IS NOT NULL
+		UNION ALL
+		SELECT `+historyColumns+`
+		FROM property_history_archive
+		WHERE property_id = ? AND change_type IS NOT NULL
+		ORDER BY id
+	`, propertyID, propertyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query property history: %v", err)
+	}
+	defer rows.Close()
+
+	history := []models.PropertyHistoryEntry{}
+	for rows.Next() {
+		var entry models.PropertyHistoryEntry
+		var price, previousPrice sql.NullInt64
+		var listingDate, previousStatus sql.NullString
+		if err := rows.Scan(&entry.ID, &entry.ChangeType, &entry.Status, &price, &listingDate,
+			&previousStatus, &previousPrice, &entry.ChangedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan property history: %v", err)
+		}
+
+		if price.Valid {
+			p := int(price.Int64)
+			entry.Price = &p
+		}
+		if listingDate.Valid {
+			entry.ListingDate = &listingDate.String
+		}
+		if entry.ChangeType != models.HistoryListed {
+			if previousStatus.Valid {
+				entry.PreviousStatus = &previousStatus.String
+			}
+			if previousPrice.Valid {
+				p := int(previousPrice.Int64)
+				entry.PreviousPrice = &p
+			}
+		}
+		if entry.Price != nil && entry.PreviousPrice != nil && *entry.Price != *entry.PreviousPrice {
+			change := *entry.Price - *entry.PreviousPrice
+			entry.PriceChange = &change
+			if *entry.PreviousPrice != 0 {
+				pct := math.Round(float64(change)/float64(*entry.PreviousPrice)*1000) / 10
+				entry.PriceChangePct = &pct
+			}
+		}
+		history = append(history, entry)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating property history: %v", err)
+	}
+	return history, nil
+}
+
+// GetPropertyChangeEvents returns history entries of the given change types
+// recorded between two times, newest first
+func (d *Database) GetPropertyChangeEvents(changeTypes []string, from, to time.Time, city string, limit int) ([]models.PropertyChangeEvent, error) {
+	if len(changeTypes) == 0 {
+		return []models.PropertyChangeEvent{}, nil
+	}
+
+	args := []interface{}{
+		from.UTC().Format("2006-01-02 15:04:05"),
+		to.UTC().Format("2006-01-02 15:04:05"),
+		city, city,
+	}
+	for _, changeType := range changeTypes {
+		args = append(args, changeType)
+	}
+	args = append(args, limit)
+
+	rows, err := d.db.Query(`
+		SELECT p.id, p.url, COALESCE(p.street, ''), COALESCE(p.city, ''),
+			h.change_type, COALESCE(h.status, ''), h.price, h.previous_status, h.previous_price, h.created_at
+		FROM property_history h
+		JOIN properties p ON p.id = h.property_id
+		WHERE h.created_at >= ? AND h.created_at <= ?
+		AND (? = '' OR LOWER(p.city) = LOWER(?))
+		AND h.change_type IN (?`+strings.Repeat(", ?", len(changeTypes)-1)+`)
+		ORDER BY h.id DESC
+		LIMIT ?
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query property change events: %v", err)
+	}
+	defer rows.Close()
+
+	events := []models.PropertyChangeEvent{}
+	for rows.Next() {
+		var event models.PropertyChangeEvent
+		var price, previousPrice sql.NullInt64
+		var previousStatus sql.NullString
+		if err := rows.Scan(&event.PropertyID, &event.URL, &event.Street, &event.City,
+			&event.ChangeType, &event.Status, &price, &previousStatus, &previousPrice, &event.ChangedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan property change event: %v", err)
+		}
+		if price.Valid {
+			p := int(price.Int64)
+			event.Price = &p
+		}
+		if previousStatus.Valid {
+			event.PreviousStatus = &previousStatus.String
+		}
+		if previousPrice.Valid {
+			p := int(previousPrice.Int64)
+			event.PreviousPrice = &p
+		}
+		events = append(events, event)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating property change events: %v", err)
+	}
+	return events, nil
+}
@@ -0,0 +1,157 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"fundamental/server/internal/models"
+	"fundamental/server/internal/thumbnail"
+	"os"
+	"strings"
+)
+
+// maxPropertyImages bounds the photos stored per listing
+const maxPropertyImages = 50
+
+// itemImages returns the photo URLs of a scraped item in order, without
+// duplicates and anything but http(s) URLs. ok is false when the item has no
+// image list, which leaves the stored photos alone.
+func itemImages(prop map[string]interface{}) (urls []string, ok bool) {
+	list, ok := prop["images"].([]interface{})
+	if !ok {
+		if strs, isStrings := prop["images"].([]string); isStrings {
+			for _, s := range strs {
+				list = append(list, s)
+			}
+			ok = true
+		}
+	}
+	if !ok {
+		return nil, false
+	}
+	seen := make(map[string]bool)
+	for _, v := range list {
+		url, _ := v.(string)
+		url = strings.TrimSpace(url)
+		if (!strings.HasPrefix(url, "https://") && !strings.HasPrefix(url, "http://")) || seen[url] {
+			continue
+		}
+		seen[url] = true
+		urls = append(urls, url)
+		if len(urls) == maxPropertyImages {
+			break
+		}
+	}
+	return urls, true
+}
+
+// imageUpsert stores a photo of a listing at its position
+const imageUpsert = `
+	INSERT INTO property_images (property_id, position, url)
+	VALUES (?, ?, ?)
+	ON CONFLICT (property_id, url) DO UPDATE SET position = excluded.position
+`
+
+// storeImages replaces the photos of a property with urls, keeping the
+// thumbnails of photos that are still there
+func (u *propertyUpserter) storeImages(propertyID int64, urls []string) error {
+	args := []interface{}{propertyID}
+	for i, url := range urls {
+		if _, err := u.images.Exec(propertyID, i, url); err != nil {
+			return fmt.Errorf("failed to store property image: %w", err)
+		}
+		args = append(args, url)
+	}
+	query := "DELETE FROM property_images WHERE property_id = ?"
+	if len(urls) > 0 {
+		query += " AND url NOT IN (?" + strings.Repeat(", ?", len(urls)-1) + ")"
+	}
+	if _, err := u.tx.Exec(query, args...); err != nil {
+		return fmt.Errorf("failed to delete stale property images: %w", err)
+	}
+	return nil
+}
+
+// GetPropertyImages returns the photos of a property in order, or nil if the
+// property does not exist
+func (d *Database) GetPropertyImages(propertyID int64) ([]models.PropertyImage, error) {
+	var exists int
+	err := d.db.QueryRow("SELECT COUNT(*) FROM properties WHERE id = ?", propertyID).Scan(&exists)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up property: %v", err)
+	}
+	if exists == 0 {
+		return nil, nil
+	}
+
+	rows, err := d.db.Query(`
+		SELECT id, position, url, thumbnail_path
+		FROM property_images
+		WHERE property_id = ?
+		ORDER BY position
+	`, propertyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query property images: %v", err)
+	}
+	defer rows.Close()
+
+	images := []models.PropertyImage{}
+	for rows.Next() {
+		var image models.PropertyImage
+		var thumbnailPath sql.NullString
+		if err := rows.Scan(&image.ID, &image.Position, &image.URL, &thumbnailPath); err != nil {
+			return nil, fmt.Errorf("failed to scan property image: %v", err)
+		}
+		// The thumbnail stays with a photo that is no longer the primary one
+		// until the new primary photo is cached
+		if thumbnailPath.Valid && image.Position == 0 {
+			image.ThumbnailURL = fmt.Sprintf("/api/properties/%d/thumbnail", propertyID)
+		}
+		images = append(images, image)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating property images: %v", err)
+	}
+	return images, nil
+}
+
+// CacheThumbnail returns the path of the thumbnail of a property's primary
+// photo, downloading it first when it is not cached yet. It returns an empty
+// path when the property has no photos.
+func (d *Database) CacheThumbnail(cache *thumbnail.Cache, propertyID int64) (string, error) {
+	var imageID int64
+	var url string
+	var thumbnailPath sql.NullString
+	err := d.db.QueryRow(`
+		SELECT id, url, thumbnail_path
+		FROM property_images
+		WHERE property_id = ?
+		ORDER BY position
+		LIMIT 1
+	`, propertyID).Scan(&imageID, &url, &thumbnailPath)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get primary image: %v", err)
+	}
+	if thumbnailPath.Valid {
+		if _, err := os.Stat(thumbnailPath.String); err == nil {
+			return thumbnailPath.String, nil
+		}
+	}
+
+	path, err := cache.Fetch(propertyID, url)
+	if err != nil {
+		return "", fmt.Errorf("failed to cache thumbnail of property %d: %v", propertyID, err)
+	}
+	// The thumbnail file belongs to the primary photo only
+	_, err = d.db.Exec(`
+		UPDATE property_images
+		SET thumbnail_path = CASE WHEN id = ? THEN ? ELSE NULL END
+		WHERE property_id = ?
+	`, imageID, path, propertyID)
+	if err != nil {
+		return "", fmt.Errorf("failed to store thumbnail path: %v", err)
+	}
+	return path, nil
+}
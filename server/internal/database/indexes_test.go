@@ -0,0 +1,322 @@
+package database
+
+import (
+	"flag"
+	"fmt"
+	"fundamental/server/internal/models"
+	"math/rand"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// benchProperties is the size of the benchmark database
+var benchProperties = flag.Int("bench.properties", 300000, "properties in the benchmark database")
+
+// compositeIndexes are the indexes of migrations 9 and 10 with their columns
+var compositeIndexes = map[string]string{
+	"idx_properties_city_status_selling": "LOWER(city), status, selling_date",
+	"idx_properties_city_status_listing": "LOWER(city), status, listing_date",
+	"idx_properties_district_status":     "district, status",
+}
+
+// benchCities are the cities of the benchmark database. The i-th has the 15
+// districts from 1000 + 20i.
+var benchCities = []string{
+	"amsterdam", "rotterdam", "den haag", "utrecht", "eindhoven", "groningen", "tilburg",
+	"almere", "breda", "nijmegen", "apeldoorn", "haarlem", "arnhem", "enschede",
+	"amersfoort", "zaanstad", "den bosch", "haarlemmermeer", "zwolle", "leiden",
+}
+
+// newTestDatabase opens a migrated database in a temporary directory
+func newTestDatabase(tb testing.TB) *Database {
+	tb.Helper()
+	d, err := NewDatabase(filepath.Join(tb.TempDir(), "test.db"))
+	if err != nil {
+		tb.Fatalf("failed to open database: %v", err)
+	}
+	tb.Cleanup(func() { d.Close() })
+	if err := d.RunMigrations(); err != nil {
+		tb.Fatalf("failed to run migrations: %v", err)
+	}
+	return d
+}
+
+// dropCompositeIndexes drops the indexes of migrations 9 and 10
+func dropCompositeIndexes(tb testing.TB, d *Database) {
+	tb.Helper()
+	for name := range compositeIndexes {
+		if _, err := d.db.Exec("DROP INDEX " + name); err != nil {
+			tb.Fatalf("failed to drop %s: %v", name, err)
+		}
+	}
+}
+
+// queryPlan returns the steps of the EXPLAIN QUERY PLAN of a query, joined by " / "
+func queryPlan(tb testing.TB, d *Database, query string, args ...interface{}) string {
+	tb.Helper()
+	rows, err := d.db.Query("EXPLAIN QUERY PLAN "+query, args...)
+	if err != nil {
+		tb.Fatalf("failed to explain query: %v", err)
+	}
+	defer rows.Close()
+	var steps []string
+	for rows.Next() {
+		var id, parent, unused int
+		var detail string
+		if err := rows.Scan(&id, &parent, &unused, &detail); err != nil {
+			tb.Fatalf("failed to scan query plan: %v", err)
+		}
+		steps = append(steps, detail)
+	}
+	if err := rows.Err(); err != nil {
+		tb.Fatalf("failed to read query plan: %v", err)
+	}
+	return strings.Join(steps, " / ")
+}
+
+// TestCompositeIndexQueryPlans checks the plans documented in
+// documentation/query-indexes.md, with and without the composite indexes
+func TestCompositeIndexQueryPlans(t *testing.T) {
+	tests := []struct {
+		name   string
+		query  string
+		args   []interface{}
+		before string
+		after  string
+	}{
+		{
+			name: "recent sales in a city",
+			query: `SELECT id, url, street, neighborhood, property_type, city, postal_code,
+				price, year_built, living_area, num_rooms, status,
+				listing_date, selling_date, scraped_at, created_at
+				FROM properties WHERE status = 'sold' AND ` + cityFilter("amsterdam") + `
+				ORDER BY selling_date DESC LIMIT 10`,
+			args:   []interface{}{"amsterdam", "amsterdam"},
+			before: "SCAN properties / USE TEMP B-TREE FOR ORDER BY",
+			after:  "SEARCH properties USING INDEX idx_properties_city_status_selling (<expr>=? AND status=?)",
+		},
+		{
+			name: "sold properties in a district over the last 12 months",
+			query: `SELECT price, living_area FROM properties
+				WHERE district = ? AND status = 'sold' AND selling_date >= date('now', '-12 months')`,
+			args:   []interface{}{"1040"},
+			before: "SCAN properties",
+			after:  "SEARCH properties USING INDEX idx_properties_district_status (district=? AND status=?)",
+		},
+		{
+			name:   "properties in a city and date range",
+			query:  `SELECT id FROM properties WHERE ` + propertyDateFilter + ` AND ` + cityFilter("amsterdam"),
+			args:   append(propertyDateFilterArgs("", ""), "amsterdam", "amsterdam"),
+			before: "SCAN properties",
+			after:  "SEARCH properties USING INDEX idx_properties_city_status_listing (<expr>=?)",
+		},
+	}
+
+	d := newTestDatabase(t)
+	for _, tt := range tests {
+		if got := queryPlan(t, d, tt.query, tt.args...); got != tt.after {
+			t.Errorf("%s: got plan %q, want %q", tt.name, got, tt.after)
+		}
+	}
+	dropCompositeIndexes(t, d)
+	for _, tt := range tests {
+		if got := queryPlan(t, d, tt.query, tt.args...); got != tt.before {
+			t.Errorf("%s without the indexes: got plan %q, want %q", tt.name, got, tt.before)
+		}
+	}
+}
+
+// seedBenchProperties fills the database with n properties spread evenly over
+// benchCities and their districts, a third each active, sold and inactive,
+// listed in the past three years. Nine in ten are geocoded, spread evenly over
+// the Netherlands.
+func seedBenchProperties(tb testing.TB, d *Database, n int) {
+	tb.Helper()
+	tx, err := d.db.DB.Begin()
+	if err != nil {
+		tb.Fatalf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+	stmt, err := tx.Prepare(`
+		INSERT INTO properties (url, street, neighborhood, property_type, city, postal_code, district,
+			price, year_built, living_area, num_rooms, energy_label,
+			status, listing_date, selling_date, scraped_at, latitude, longitude)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`)
+	if err != nil {
+		tb.Fatalf("failed to prepare insert: %v", err)
+	}
+	defer stmt.Close()
+
+	rng := rand.New(rand.NewSource(1))
+	now := time.Now()
+	statuses := []string{"active", "sold", "inactive"}
+	for i := 0; i < n; i++ {
+		city := i % len(benchCities)
+		district := fmt.Sprintf("%d", 1000+20*city+(i/len(benchCities))%15)
+		status := statuses[(i/(15*len(benchCities)))%len(statuses)]
+		livingArea := 40 + rng.Intn(160)
+		listed := now.AddDate(0, 0, -rng.Intn(3*365))
+		var sold interface{}
+		if status == "sold" {
+			if date := listed.AddDate(0, 0, 7+rng.Intn(120)); date.Before(now) {
+				sold = date.Format("2006-01-02")
+			} else {
+				sold = now.Format("2006-01-02")
+			}
+		}
+		var lat, lng interface{}
+		if i%10 != 0 {
+			lat, lng = 50.75+rng.Float64()*2.8, 3.35+rng.Float64()*3.9
+		}
+		_, err := stmt.Exec(
+			fmt.Sprintf("https://www.funda.nl/koop/%d/", i), fmt.Sprintf("Straat %d", i), "Centrum", "appartement",
+			benchCities[city], district+" AB", district,
+			150000+rng.Intn(1350000), 1900+rng.Intn(125), livingArea, 1+livingArea/30, "ABCDEFG"[rng.Intn(7):][:1],
+			status, listed.Format("2006-01-02"), sold, now.Format(time.RFC3339), lat, lng,
+		)
+		if err != nil {
+			tb.Fatalf("failed to insert property: %v", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		tb.Fatalf("failed to commit properties: %v", err)
+	}
+	if err := d.Optimize(false); err != nil {
+		tb.Fatalf("failed to analyze database: %v", err)
+	}
+}
+
+// BenchmarkCompositeIndexes times the queries served by the composite indexes
+// on a seeded database, then again without the indexes, and the creation of
+// each index. The measurements in documentation/query-indexes.md come from
+//
+//	go test ./internal/database -run '^$' -bench CompositeIndexes -benchtime 5x
+func BenchmarkCompositeIndexes(b *testing.B) {
+	d := newTestDatabase(b)
+	seedBenchProperties(b, d, *benchProperties)
+
+	yearAgo := time.Now().AddDate(-1, 0, 0)
+	from, to := yearAgo.Format("2006-01-02"), time.Now().Format("2006-01-02")
+	queries := []struct {
+		name string
+		run  func() error
+	}{
+		{"GetPropertyStats/Amsterdam", func() error {
+			_, err := d.propertyStats("", "", "Amsterdam", "")
+			return err
+		}},
+		{"GetPropertyStats/Amsterdam_past_year", func() error {
+			_, err := d.propertyStats(from, to, "Amsterdam", "")
+			return err
+		}},
+		{"GetAllProperties/Amsterdam_limit_50", func() error {
+			_, err := d.GetAllProperties("", "", "Amsterdam", models.PropertyListOptions{Limit: 50})
+			return err
+		}},
+		{"GetAreaStats/1000_Amsterdam", func() error {
+			_, err := d.GetAreaStats("1000", "", "", "Amsterdam", "")
+			return err
+		}},
+		{"GetDistrictPriceAnalysis/1000", func() error {
+			_, _, _, _, err := d.districtPriceAnalysis("1000")
+			return err
+		}},
+		{"GetDistrictMedianPricePerSqm/1000", func() error {
+			_, err := d.GetDistrictMedianPricePerSqm("1000")
+			return err
+		}},
+		{"GetPriceHistogram/Amsterdam", func() error {
+			_, err := d.GetPriceHistogram(50000, "", "", "Amsterdam", "")
+			return err
+		}},
+		{"GetMarketTimeSeries/avg_sold_price_Amsterdam_monthly", func() error {
+			_, err := d.GetMarketTimeSeries("avg_sold_price", IntervalMonth, yearAgo, time.Now(), "Amsterdam")
+			return err
+		}},
+	}
+	run := func(variant string) {
+		for _, q := range queries {
+			b.Run(variant+"/"+q.name, func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					if err := q.run(); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+
+	run("indexed")
+	dropCompositeIndexes(b, d)
+	run("unindexed")
+
+	for name, columns := range compositeIndexes {
+		b.Run("create/"+name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := d.db.Exec(fmt.Sprintf("CREATE INDEX %s ON properties (%s)", name, columns)); err != nil {
+					b.Fatal(err)
+				}
+				b.StopTimer()
+				if _, err := d.db.Exec("DROP INDEX " + name); err != nil {
+					b.Fatal(err)
+				}
+				b.StartTimer()
+			}
+		})
+	}
+}
+
+// BenchmarkSpatialIndex times bounding-box queries through the R*Tree, then
+// through idx_properties_coordinates, on the database of
+// BenchmarkCompositeIndexes. The lookup counts the matching coordinates; the
+// full query also reads and filters the property rows.
+//
+//	go test ./internal/database -run '^$' -bench SpatialIndex -benchtime 5x
+func BenchmarkSpatialIndex(b *testing.B) {
+	d := newTestDatabase(b)
+	seedBenchProperties(b, d, *benchProperties)
+
+	boxes := []struct {
+		name                           string
+		minLat, minLng, maxLat, maxLng float64
+	}{
+		{"viewport_0.1x0.1", 52.32, 4.85, 52.42, 4.95},
+		{"strip_2.6x0.02", 50.9, 5.0, 53.5, 5.02},
+		{"zoomed_out_1x1", 51.5, 4.5, 52.5, 5.5},
+	}
+	run := func(variant string) {
+		for _, box := range boxes {
+			b.Run(variant+"/lookup/"+box.name, func(b *testing.B) {
+				condition, args, err := d.boundsFilter(box.minLat, box.minLng, box.maxLat, box.maxLng)
+				if err != nil {
+					b.Fatal(err)
+				}
+				var matches int
+				for i := 0; i < b.N; i++ {
+					if err := d.db.QueryRow("SELECT COUNT(*) FROM properties WHERE "+condition, args...).Scan(&matches); err != nil {
+						b.Fatal(err)
+					}
+				}
+				b.ReportMetric(float64(matches), "matches")
+			})
+			b.Run(variant+"/full/"+box.name, func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					if _, err := d.GetPropertiesInBounds(box.minLat, box.minLng, box.maxLat, box.maxLng, models.PropertyFilter{}); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+
+	run("rtree")
+	for _, trigger := range spatialTriggers {
+		if _, err := d.db.Exec("DROP TRIGGER " + trigger); err != nil {
+			b.Fatal(err)
+		}
+	}
+	run("btree")
+}
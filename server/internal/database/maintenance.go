@@ -0,0 +1,83 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+)
+
+// maxIntegrityProblems bounds the problems reported by an integrity check
+const maxIntegrityProblems = 100
+
+// ErrIntegrityCheckUnsupported is returned by CheckIntegrity for PostgreSQL,
+// which has no equivalent of SQLite's integrity_check
+var ErrIntegrityCheckUnsupported = errors.New("integrity checks are only supported for SQLite")
+
+// Optimize refreshes the query planner statistics: PRAGMA optimize and
+// ANALYZE on SQLite, ANALYZE on PostgreSQL. With vacuum the database is also
+// vacuumed to reclaim the space of deleted rows, which locks it for a while.
+func (d *Database) Optimize(vacuum bool) error {
+	var statements []string
+	if d.dialect.Name() == DriverSQLite {
+		statements = append(statements, "PRAGMA optimize", "ANALYZE")
+		if vacuum {
+			statements = append(statements, "VACUUM")
+		}
+	} else if vacuum {
+		statements = append(statements, "VACUUM ANALYZE")
+	} else {
+		statements = append(statements, "ANALYZE")
+	}
+
+	for _, stmt := range statements {
+		if _, err := d.db.Exec(stmt); err != nil {
+			return fmt.Errorf("failed to run %s: %v", stmt, err)
+		}
+	}
+	return nil
+}
+
+// CheckIntegrity runs SQLite's integrity and foreign key checks and returns
+// the problems found, at most 100; none means the database is intact
+func (d *Database) CheckIntegrity() ([]string, error) {
+	if d.dialect.Name() != DriverSQLite {
+		return nil, ErrIntegrityCheckUnsupported
+	}
+
+	var problems []string
+	rows, err := d.db.Query(fmt.Sprintf("PRAGMA integrity_check(%d)", maxIntegrityProblems))
+	if err != nil {
+		return nil, fmt.Errorf("failed to run integrity check: %v", err)
+	}
+	for rows.Next() {
+		var result string
+		if err := rows.Scan(&result); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan integrity check result: %v", err)
+		}
+		if result != "ok" {
+			problems = append(problems, result)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to run integrity check: %v", err)
+	}
+
+	rows, err = d.db.Query("PRAGMA foreign_key_check")
+	if err != nil {
+		return nil, fmt.Errorf("failed to run foreign key check: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() && len(problems) < maxIntegrityProblems {
+		var table, parent string
+		var rowid, fkid interface{}
+		if err := rows.Scan(&table, &rowid, &parent, &fkid); err != nil {
+			return nil, fmt.Errorf("failed to scan foreign key check result: %v", err)
+		}
+		problems = append(problems, fmt.Sprintf("row %v of %s references a missing row of %s", rowid, table, parent))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to run foreign key check: %v", err)
+	}
+	return problems, nil
+}
@@ -0,0 +1,108 @@
+package database
+
+import (
+	"fmt"
+	"fundamental/server/internal/models"
+	"sort"
+)
+
+// districtMarket evaluates relative search criteria against the current
+// statistics of each district, loading a district the first time it is needed.
+// It implements models.MarketReference; the first query error is kept in err.
+type districtMarket struct {
+	d            *Database
+	pricesPerSqm map[string][]float64 // sorted asking prices per m² of current listings
+	sales        map[string][]districtSale
+	err          error
+}
+
+// MarketReference returns the current district statistics relative criteria
+// and rules are evaluated against. It caches what it loads, so use a new one
+// for every batch of properties.
+func (d *Database) MarketReference() models.MarketReference {
+	return d.newDistrictMarket()
+}
+
+func (d *Database) newDistrictMarket() *districtMarket {
+	return &districtMarket{
+		d:            d,
+		pricesPerSqm: make(map[string][]float64),
+		sales:        make(map[string][]districtSale),
+	}
+}
+
+// PricePerSqmPercentile returns the percentage of the district's active and
+// republished listings asking at most pricePerSqm per m²
+func (m *districtMarket) PricePerSqmPercentile(district string, pricePerSqm float64) (float64, bool) {
+	prices, ok := m.pricesPerSqm[district]
+	if !ok {
+		var err error
+		if prices, err = m.d.districtListingPricesPerSqm(district); err != nil {
+			if m.err == nil {
+				m.err = err
+			}
+			return 0, false
+		}
+		m.pricesPerSqm[district] = prices
+	}
+	if len(prices) < bidMinComparables {
+		return 0, false
+	}
+	atMost := sort.Search(len(prices), func(i int) bool { return prices[i] > pricePerSqm })
+	return float64(atMost) / float64(len(prices)) * 100, true
+}
+
+// EstimateValue estimates the value of a home like the bid advice does, from
+// the district's sales of the past year except the property itself
+func (m *districtMarket) EstimateValue(district string, livingArea float64, exceptID int64) (int, bool) {
+	sales, ok := m.sales[district]
+	if !ok {
+		var err error
+		if sales, err = m.d.districtSales(district, 0); err != nil {
+			if m.err == nil {
+				m.err = err
+			}
+			return 0, false
+		}
+		m.sales[district] = sales
+	}
+	comparables := make([]districtSale, 0, len(sales))
+	for _, s := range sales {
+		if s.id != exceptID {
+			comparables = append(comparables, s)
+		}
+	}
+	estimate, n := estimateValue(comparables, livingArea)
+	return estimate, n > 0
+}
+
+// districtListingPricesPerSqm returns the sorted asking prices per m² of the
+// district's current listings
+func (d *Database) districtListingPricesPerSqm(district string) ([]float64, error) {
+	rows, err := d.db.Query(`
+		SELECT CAST(price AS FLOAT) / living_area
+		FROM properties
+		WHERE district = ?
+		AND status IN ('active', 'republished')
+		AND price BETWEEN 50000 AND 10000000
+		AND living_area BETWEEN 15 AND 1000
+	`, district)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query district listings: %v", err)
+	}
+	defer rows.Close()
+
+	var prices []float64
+	for rows.Next() {
+		var price float64
+		if err := rows.Scan(&price); err != nil {
+			return nil, fmt.Errorf("failed to scan district listing: %v", err)
+		}
+		prices = append(prices, price)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating district listings: %v", err)
+	}
+	sort.Float64s(prices)
+	return prices, nil
+}
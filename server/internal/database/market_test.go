@@ -0,0 +1,120 @@
+package database
+
+import (
+	"fundamental/server/internal/models"
+	"testing"
+)
+
+// newLoadedMarket returns a districtMarket whose districts are already loaded,
+// so it never queries the database: district 1012 has five listings and six
+// sales, 1013 two listings and two sales, and 1099 none.
+func newLoadedMarket() *districtMarket {
+	m := (*Database)(nil).newDistrictMarket()
+	m.pricesPerSqm["1012"] = []float64{5000, 6000, 7000, 8000, 9000}
+	m.pricesPerSqm["1013"] = []float64{5000, 6000}
+	m.pricesPerSqm["1099"] = nil
+	m.sales["1012"] = []districtSale{
+		{id: 1, price: 500000, livingArea: 100},
+		{id: 2, price: 600000, livingArea: 100},
+		{id: 3, price: 700000, livingArea: 100},
+		{id: 4, price: 800000, livingArea: 100},
+		{id: 5, price: 900000, livingArea: 100},
+		{id: 6, price: 1300000, livingArea: 100},
+	}
+	m.sales["1013"] = []districtSale{
+		{id: 7, price: 500000, livingArea: 100},
+		{id: 8, price: 600000, livingArea: 100},
+	}
+	m.sales["1099"] = nil
+	return m
+}
+
+func TestDistrictMarketPricePerSqmPercentile(t *testing.T) {
+	m := newLoadedMarket()
+
+	tests := []struct {
+		name        string
+		district    string
+		pricePerSqm float64
+		want        float64
+		ok          bool
+	}{
+		{"cheapest listing", "1012", 5000, 20, true},
+		{"between listings", "1012", 6500, 40, true},
+		{"below all listings", "1012", 4000, 0, true},
+		{"most expensive listing", "1012", 9000, 100, true},
+		{"too few comparables", "1013", 5000, 0, false},
+		{"district without listings", "1099", 5000, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := m.PricePerSqmPercentile(tt.district, tt.pricePerSqm)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("PricePerSqmPercentile(%q, %v) = %v, %v, want %v, %v",
+					tt.district, tt.pricePerSqm, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestDistrictMarketEstimateValue(t *testing.T) {
+	m := newLoadedMarket()
+
+	tests := []struct {
+		name       string
+		district   string
+		livingArea float64
+		exceptID   int64
+		want       int
+		ok         bool
+	}{
+		// The median of 5000 to 13000 per m² over six sales is 7500
+		{"all sales", "1012", 100, 0, 750000, true},
+		// Without sale 6 the median of the other five is 7000
+		{"except the property itself", "1012", 100, 6, 700000, true},
+		{"scaled to the living area", "1012", 80, 6, 560000, true},
+		{"no living area", "1012", 0, 0, 0, false},
+		{"too few comparables", "1013", 100, 0, 0, false},
+		{"district without sales", "1099", 100, 0, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := m.EstimateValue(tt.district, tt.livingArea, tt.exceptID)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("EstimateValue(%q, %v, %d) = %v, %v, want %v, %v",
+					tt.district, tt.livingArea, tt.exceptID, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestRelativeCriteriaOnDistrictMarket(t *testing.T) {
+	pct := func(v float64) *float64 { return &v }
+	area := 100
+	property := func(id int64, postalCode string, price int) *models.Property {
+		return &models.Property{ID: id, PostalCode: postalCode, Price: price, LivingArea: &area}
+	}
+
+	tests := []struct {
+		name     string
+		criteria models.RelativeCriteria
+		property *models.Property
+		want     bool
+	}{
+		{"in the bottom 40%", models.RelativeCriteria{MaxPricePerSqmPercentile: pct(40)}, property(10, "1012 AB", 600000), true},
+		{"above the bottom 40%", models.RelativeCriteria{MaxPricePerSqmPercentile: pct(40)}, property(10, "1012 AB", 700000), false},
+		{"10% below the estimate", models.RelativeCriteria{MinBelowEstimatePct: pct(10)}, property(10, "1012 AB", 675000), true},
+		{"less than 10% below the estimate", models.RelativeCriteria{MinBelowEstimatePct: pct(10)}, property(10, "1012 AB", 680000), false},
+		// Its own sale is not a comparable, which lowers the estimate to 700000
+		{"estimate without its own sale", models.RelativeCriteria{MinBelowEstimatePct: pct(10)}, property(6, "1012 AB", 675000), false},
+		{"too few comparables", models.RelativeCriteria{MaxPricePerSqmPercentile: pct(100)}, property(10, "1013 AB", 500000), false},
+		{"district without listings", models.RelativeCriteria{MinBelowEstimatePct: pct(0)}, property(10, "1099 AB", 500000), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.criteria.Matches(tt.property, newLoadedMarket()); got != tt.want {
+				t.Errorf("Matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"fundamental/server/internal/models"
+)
+
+// marketMinSales is the number of sales a window needs before its medians and
+// shares are used
+const marketMinSales = 5
+
+// GetMarketIndicators measures the inputs of the market phase of a city over
+// the last windowDays and the window before it. Inventory counts listings with
+// a listing date that were not yet sold or delisted at the time.
+func (d *Database) GetMarketIndicators(city string, windowDays int) (*models.MarketIndicators, error) {
+	indicators := &models.MarketIndicators{City: city, WindowDays: windowDays}
+	if err := d.measureMarket(indicators, cityFilter(city), city, city); err != nil {
+		return nil, err
+	}
+	return indicators, nil
+}
+
+// GetDistrictMarketIndicators measures the inputs of the market phase of a
+// 4-digit postal district, see GetMarketIndicators
+func (d *Database) GetDistrictMarketIndicators(district string, windowDays int) (*models.MarketIndicators, error) {
+	indicators := &models.MarketIndicators{District: district, WindowDays: windowDays}
+	if err := d.measureMarket(indicators, "district = ?", district); err != nil {
+		return nil, err
+	}
+	return indicators, nil
+}
+
+// measureMarket fills the indicators of the properties matching area, a
+// condition bound with areaArgs
+func (d *Database) measureMarket(indicators *models.MarketIndicators, area string, areaArgs ...interface{}) error {
+	windowDays := indicators.WindowDays
+	if windowDays <= 0 {
+		return fmt.Errorf("market window must be a positive number of days")
+	}
+	window := fmt.Sprintf("-%d days", windowDays)
+
+	// Listings delisted before delisted_at was recorded count as gone
+	err := d.db.QueryRow(`
+		SELECT
+			COALESCE(SUM(CASE WHEN status IN ('active', 'republished') THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN listing_date <= `+d.dialect.DateOffset(window)+` AND (
+				status IN ('active', 'republished')
+				OR (status = 'sold' AND selling_date > `+d.dialect.DateOffset(window)+`)
+				OR (status = 'inactive' AND delisted_at > `+d.dialect.TimestampOffset()+`)
+			) THEN 1 ELSE 0 END), 0)
+		FROM properties
+		WHERE listing_date IS NOT NULL
+		AND `+area, append([]interface{}{window}, areaArgs...)...).Scan(&indicators.Inventory, &indicators.PriorInventory)
+	if err != nil {
+		return fmt.Errorf("failed to count inventory: %v", err)
+	}
+
+	rows, err := d.db.Query(`
+		SELECT p.price, p.living_area,
+		       (SELECT h.price FROM property_history h
+		        WHERE h.property_id = p.id AND h.price IS NOT NULL
+		        ORDER BY h.id LIMIT 1),
+		       CASE WHEN p.listing_date IS NOT NULL AND `+exactSellingDate("p.")+`
+		            THEN `+d.dialect.DaysBetween("p.listing_date", "p.selling_date")+` END,
+		       CASE WHEN p.selling_date > `+d.dialect.DateOffset(window)+` THEN 1 ELSE 0 END
+		FROM properties p
+		WHERE p.status = 'sold'
+		AND p.price BETWEEN 50000 AND 10000000
+		AND p.selling_date > `+d.dialect.DateOffset(fmt.Sprintf("-%d days", 2*windowDays))+`
+		AND `+area, areaArgs...)
+	if err != nil {
+		return fmt.Errorf("failed to query market sales: %v", err)
+	}
+	defer rows.Close()
+
+	var days, perSqm, priorPerSqm []float64
+	var overAsking, withAsking int
+	for rows.Next() {
+		var price float64
+		var livingArea, firstPrice, daysToSell sql.NullFloat64
+		var recent bool
+		if err := rows.Scan(&price, &livingArea, &firstPrice, &daysToSell, &recent); err != nil {
+			return fmt.Errorf("failed to scan market sale: %v", err)
+		}
+
+		validArea := livingArea.Valid && livingArea.Float64 >= 15 && livingArea.Float64 <= 1000
+		if !recent {
+			if validArea {
+				priorPerSqm = append(priorPerSqm, price/livingArea.Float64)
+			}
+			continue
+		}
+		indicators.Sales++
+		if validArea {
+			perSqm = append(perSqm, price/livingArea.Float64)
+		}
+		if daysToSell.Valid && daysToSell.Float64 >= 0 {
+			days = append(days, daysToSell.Float64)
+		}
+		if firstPrice.Valid && firstPrice.Float64 > 0 {
+			withAsking++
+			if price > firstPrice.Float64 {
+				overAsking++
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating market sales: %v", err)
+	}
+
+	if len(days) >= marketMinSales {
+		v := median(days)
+		indicators.MedianDaysToSell = &v
+	}
+	if withAsking >= marketMinSales {
+		v := float64(overAsking) / float64(withAsking)
+		indicators.OverAskingShare = &v
+	}
+	if len(perSqm) >= marketMinSales {
+		v := median(perSqm)
+		indicators.PricePerSqm = &v
+	}
+	if len(priorPerSqm) >= marketMinSales {
+		v := median(priorPerSqm)
+		indicators.PriorPricePerSqm = &v
+	}
+	return nil
+}
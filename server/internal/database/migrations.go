@@ -0,0 +1,1346 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"fundamental/server/internal/models"
+	"time"
+)
+
+// migration is a versioned schema change. Up and Down run inside a transaction
+// together with the bookkeeping in schema_migrations. DDL is written for SQLite
+// and translated by the dialect.
+type migration struct {
+	Version int
+	Name    string
+	Up      func(tx *sqlTx) error
+	Down    func(tx *sqlTx) error
+}
+
+// migrations lists every schema change in order. Append new migrations to the
+// end; never edit or renumber one that has been released.
+var migrations = []migration{
+	{
+		Version: 1,
+		Name:    "baseline schema",
+		Up: func(tx *sqlTx) error {
+			err := execAll(tx,
+				`CREATE TABLE IF NOT EXISTS properties (
+					id INTEGER PRIMARY KEY AUTOINCREMENT,
+					url TEXT UNIQUE NOT NULL,
+					street TEXT,
+					neighborhood TEXT,
+					property_type TEXT,
+					city TEXT,
+					postal_code TEXT,
+					price INTEGER,
+					year_built INTEGER,
+					living_area INTEGER,
+					num_rooms INTEGER,
+					status TEXT,
+					listing_date TEXT,
+					selling_date TEXT,
+					scraped_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+					created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+					updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+				)`,
+				`CREATE TABLE IF NOT EXISTS property_history (
+					id INTEGER PRIMARY KEY AUTOINCREMENT,
+					property_id INTEGER NOT NULL,
+					status TEXT,
+					price INTEGER,
+					listing_date TEXT,
+					created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+					FOREIGN KEY (property_id) REFERENCES properties(id)
+				)`,
+				`CREATE TABLE IF NOT EXISTS metropolitan_areas (
+					id INTEGER PRIMARY KEY AUTOINCREMENT,
+					name TEXT UNIQUE NOT NULL,
+					created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+				)`,
+				`CREATE TABLE IF NOT EXISTS telegram_config (
+					id INTEGER PRIMARY KEY AUTOINCREMENT,
+					bot_token TEXT NOT NULL,
+					chat_id TEXT NOT NULL,
+					is_enabled BOOLEAN DEFAULT 1,
+					created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+					updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+				)`,
+				// Created without the foreign key constraint
+				`CREATE TABLE IF NOT EXISTS metropolitan_cities (
+					metropolitan_area_id INTEGER,
+					city TEXT NOT NULL,
+					created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+					PRIMARY KEY (metropolitan_area_id, city)
+				)`,
+				`CREATE TABLE IF NOT EXISTS telegram_filters (
+					min_price INTEGER,
+					max_price INTEGER,
+					min_living_area INTEGER,
+					max_living_area INTEGER,
+					min_rooms INTEGER,
+					max_rooms INTEGER,
+					districts TEXT,
+					energy_labels TEXT
+				)`,
+			)
+			if err != nil {
+				return err
+			}
+
+			// Columns added after the first release; databases created by older
+			// versions may lack some of them
+			columns := []struct{ table, column, definition string }{
+				{"metropolitan_areas", "center_lat", "REAL"},
+				{"metropolitan_areas", "center_lng", "REAL"},
+				{"metropolitan_areas", "zoom_level", "INTEGER DEFAULT 13"},
+				{"metropolitan_cities", "lat", "REAL"},
+				{"metropolitan_cities", "lng", "REAL"},
+				{"properties", "republish_count", "INTEGER DEFAULT 0"},
+				{"properties", "latitude", "REAL"},
+				{"properties", "longitude", "REAL"},
+				{"properties", "geocoding_attempted", "BOOLEAN DEFAULT 0"},
+				{"properties", "energy_label", "TEXT"},
+			}
+			for _, c := range columns {
+				if err := addColumn(tx, c.table, c.column, c.definition); err != nil {
+					return err
+				}
+			}
+
+			return execAll(tx,
+				// Mark properties that already have coordinates as attempted
+				`UPDATE properties
+				SET geocoding_attempted = 1
+				WHERE latitude IS NOT NULL
+				AND longitude IS NOT NULL`,
+				`CREATE INDEX IF NOT EXISTS idx_properties_coordinates
+				ON properties(latitude, longitude)`,
+			)
+		},
+		Down: func(tx *sqlTx) error {
+			return execAll(tx,
+				"DROP TABLE IF EXISTS telegram_filters",
+				"DROP TABLE IF EXISTS metropolitan_cities",
+				"DROP TABLE IF EXISTS telegram_config",
+				"DROP TABLE IF EXISTS metropolitan_areas",
+				"DROP TABLE IF EXISTS property_history",
+				"DROP TABLE IF EXISTS properties",
+			)
+		},
+	},
+	{
+		Version: 2,
+		Name:    "property snapshots",
+		Up: func(tx *sqlTx) error {
+			return execAll(tx,
+				`CREATE TABLE IF NOT EXISTS property_snapshots (
+					id INTEGER PRIMARY KEY AUTOINCREMENT,
+					property_id INTEGER NOT NULL,
+					payload BLOB NOT NULL,
+					created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+					FOREIGN KEY (property_id) REFERENCES properties(id)
+				)`,
+				`CREATE INDEX IF NOT EXISTS idx_property_snapshots_property
+				ON property_snapshots(property_id, created_at)`,
+			)
+		},
+		Down: func(tx *sqlTx) error {
+			return execAll(tx, "DROP TABLE IF EXISTS property_snapshots")
+		},
+	},
+	{
+		Version: 3,
+		Name:    "field provenance",
+		Up: func(tx *sqlTx) error {
+			return addColumn(tx, "properties", "field_provenance", "TEXT")
+		},
+		Down: func(tx *sqlTx) error {
+			return dropColumn(tx, "properties", "field_provenance")
+		},
+	},
+	{
+		Version: 4,
+		Name:    "user preferences",
+		Up: func(tx *sqlTx) error {
+			// Rows with an empty owner hold the defaults
+			return execAll(tx,
+				`CREATE TABLE IF NOT EXISTS user_preferences (
+					owner TEXT NOT NULL,
+					namespace TEXT NOT NULL,
+					key TEXT NOT NULL,
+					value TEXT NOT NULL,
+					updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+					PRIMARY KEY (owner, namespace, key)
+				)`,
+			)
+		},
+		Down: func(tx *sqlTx) error {
+			return execAll(tx, "DROP TABLE IF EXISTS user_preferences")
+		},
+	},
+	{
+		Version: 5,
+		Name:    "shared views",
+		Up: func(tx *sqlTx) error {
+			return execAll(tx,
+				`CREATE TABLE IF NOT EXISTS shared_views (
+					token TEXT PRIMARY KEY,
+					definition TEXT NOT NULL,
+					definition_hash TEXT NOT NULL UNIQUE,
+					access_count INTEGER DEFAULT 0,
+					created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+					last_accessed_at TIMESTAMP
+				)`,
+			)
+		},
+		Down: func(tx *sqlTx) error {
+			return execAll(tx, "DROP TABLE IF EXISTS shared_views")
+		},
+	},
+	{
+		Version: 6,
+		Name:    "drop legacy schema_version table",
+		Up: func(tx *sqlTx) error {
+			return execAll(tx, "DROP TABLE IF EXISTS schema_version")
+		},
+		Down: func(tx *sqlTx) error {
+			return nil
+		},
+	},
+	{
+		Version: 7,
+		Name:    "api tokens",
+		Up: func(tx *sqlTx) error {
+			return execAll(tx,
+				`CREATE TABLE IF NOT EXISTS api_tokens (
+					id INTEGER PRIMARY KEY AUTOINCREMENT,
+					name TEXT NOT NULL,
+					token_hash TEXT NOT NULL UNIQUE,
+					token_prefix TEXT NOT NULL,
+					scopes TEXT,
+					expires_at TIMESTAMP,
+					last_used_at TIMESTAMP,
+					revoked_at TIMESTAMP,
+					created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+				)`,
+			)
+		},
+		Down: func(tx *sqlTx) error {
+			return execAll(tx, "DROP TABLE IF EXISTS api_tokens")
+		},
+	},
+	{
+		Version: 8,
+		Name:    "property history changes",
+		Up: func(tx *sqlTx) error {
+			for _, column := range []struct{ name, definition string }{
+				{"change_type", "TEXT"},
+				{"previous_status", "TEXT"},
+				{"previous_price", "INTEGER"},
+			} {
+				if err := addColumn(tx, "property_history", column.name, column.definition); err != nil {
+					return err
+				}
+			}
+
+			// Classify the existing rows, which were written on every scrape. Rows
+			// that repeat the previous state keep a NULL change_type.
+			return execAll(tx,
+				`UPDATE property_history
+				SET previous_status = h.prev_status,
+					previous_price = h.prev_price,
+					change_type = CASE
+						WHEN h.row_num = 1 THEN 'listed'
+						WHEN price IS DISTINCT FROM h.prev_price AND status IS DISTINCT FROM h.prev_status THEN 'price_and_status_change'
+						WHEN price IS DISTINCT FROM h.prev_price THEN 'price_change'
+						WHEN status IS DISTINCT FROM h.prev_status THEN 'status_change'
+					END
+				FROM (
+					SELECT id,
+						LAG(status) OVER (PARTITION BY property_id ORDER BY id) AS prev_status,
+						LAG(price) OVER (PARTITION BY property_id ORDER BY id) AS prev_price,
+						ROW_NUMBER() OVER (PARTITION BY property_id ORDER BY id) AS row_num
+					FROM property_history
+				) AS h
+				WHERE property_history.id = h.id`,
+				`CREATE INDEX IF NOT EXISTS idx_property_history_property
+				ON property_history(property_id, id)`,
+			)
+		},
+		Down: func(tx *sqlTx) error {
+			if err := execAll(tx, "DROP INDEX IF EXISTS idx_property_history_property"); err != nil {
+				return err
+			}
+			for _, column := range []string{"previous_price", "previous_status", "change_type"} {
+				if err := dropColumn(tx, "property_history", column); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		Version: 9,
+		Name:    "composite indexes",
+		Up: func(tx *sqlTx) error {
+			// Match the LOWER(city) and substr(postal_code, 1, 4) expressions
+			// the stats, list and district queries filter on
+			return execAll(tx,
+				`CREATE INDEX IF NOT EXISTS idx_properties_city_status_selling
+				ON properties(LOWER(city), status, selling_date)`,
+				`CREATE INDEX IF NOT EXISTS idx_properties_city_status_listing
+				ON properties(LOWER(city), status, listing_date)`,
+				`CREATE INDEX IF NOT EXISTS idx_properties_district_status
+				ON properties(substr(postal_code, 1, 4), status)`,
+			)
+		},
+		Down: func(tx *sqlTx) error {
+			return execAll(tx,
+				"DROP INDEX IF EXISTS idx_properties_district_status",
+				"DROP INDEX IF EXISTS idx_properties_city_status_listing",
+				"DROP INDEX IF EXISTS idx_properties_city_status_selling",
+			)
+		},
+	},
+	{
+		Version: 10,
+		Name:    "property district",
+		Up: func(tx *sqlTx) error {
+			if err := addColumn(tx, "properties", "district", "TEXT"); err != nil {
+				return err
+			}
+			if err := backfillDistricts(tx); err != nil {
+				return err
+			}
+			return execAll(tx,
+				"DROP INDEX IF EXISTS idx_properties_district_status",
+				`CREATE INDEX IF NOT EXISTS idx_properties_district_status
+				ON properties(district, status)`,
+			)
+		},
+		Down: func(tx *sqlTx) error {
+			if err := execAll(tx,
+				"DROP INDEX IF EXISTS idx_properties_district_status",
+				`CREATE INDEX IF NOT EXISTS idx_properties_district_status
+				ON properties(substr(postal_code, 1, 4), status)`,
+			); err != nil {
+				return err
+			}
+			return dropColumn(tx, "properties", "district")
+		},
+	},
+	{
+		Version: 11,
+		Name:    "outlier flags",
+		Up: func(tx *sqlTx) error {
+			if err := addColumn(tx, "properties", "outlier_flags", "TEXT"); err != nil {
+				return err
+			}
+			return backfillOutlierFlags(tx)
+		},
+		Down: func(tx *sqlTx) error {
+			return dropColumn(tx, "properties", "outlier_flags")
+		},
+	},
+	{
+		Version: 12,
+		Name:    "property sync log",
+		Up:      createPropertySync,
+		Down:    dropPropertySync,
+	},
+	{
+		// The archive tables have the columns of properties and property_history;
+		// later migrations add property columns with addPropertyColumn
+		Version: 13,
+		Name:    "property archive",
+		Up: func(tx *sqlTx) error {
+			if err := execAll(tx,
+				"CREATE TABLE IF NOT EXISTS properties_archive AS SELECT * FROM properties WHERE 1 = 0",
+				"CREATE TABLE IF NOT EXISTS property_history_archive AS SELECT * FROM property_history WHERE 1 = 0",
+			); err != nil {
+				return err
+			}
+			if err := addColumn(tx, "properties_archive", "archived_at", "TIMESTAMP"); err != nil {
+				return err
+			}
+			return execAll(tx,
+				"CREATE UNIQUE INDEX IF NOT EXISTS idx_properties_archive_url ON properties_archive(url)",
+				"CREATE INDEX IF NOT EXISTS idx_property_history_archive_property ON property_history_archive(property_id, id)",
+			)
+		},
+		Down: func(tx *sqlTx) error {
+			// Move archived properties back before dropping the archive
+			if err := restoreArchived(tx, "1 = 1"); err != nil {
+				return err
+			}
+			return execAll(tx,
+				"DROP TABLE IF EXISTS property_history_archive",
+				"DROP TABLE IF EXISTS properties_archive",
+			)
+		},
+	},
+	{
+		Version: 14,
+		Name:    "street images",
+		Up: func(tx *sqlTx) error {
+			for _, column := range [][2]string{
+				{"street_image_url", "TEXT"},
+				{"street_image_link", "TEXT"},
+				{"street_image_checked_at", "TIMESTAMP"},
+			} {
+				if err := addPropertyColumn(tx, column[0], column[1]); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Down: func(tx *sqlTx) error {
+			for _, column := range []string{"street_image_checked_at", "street_image_link", "street_image_url"} {
+				if err := dropPropertyColumn(tx, column); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		Version: 15,
+		Name:    "telegram number format",
+		Up: func(tx *sqlTx) error {
+			if err := addColumn(tx, "telegram_config", "locale", "TEXT"); err != nil {
+				return err
+			}
+			return addColumn(tx, "telegram_config", "currency", "TEXT")
+		},
+		Down: func(tx *sqlTx) error {
+			if err := dropColumn(tx, "telegram_config", "currency"); err != nil {
+				return err
+			}
+			return dropColumn(tx, "telegram_config", "locale")
+		},
+	},
+	{
+		Version: 16,
+		Name:    "relisting chains",
+		Up: func(tx *sqlTx) error {
+			if err := addPropertyColumn(tx, "canonical_id", "INTEGER"); err != nil {
+				return err
+			}
+			if err := execAll(tx,
+				"CREATE INDEX IF NOT EXISTS idx_properties_address ON properties(postal_code, street)",
+				"CREATE INDEX IF NOT EXISTS idx_properties_canonical ON properties(canonical_id)",
+			); err != nil {
+				return err
+			}
+			return backfillCanonicalIDs(tx)
+		},
+		Down: func(tx *sqlTx) error {
+			if err := execAll(tx,
+				"DROP INDEX IF EXISTS idx_properties_canonical",
+				"DROP INDEX IF EXISTS idx_properties_address",
+			); err != nil {
+				return err
+			}
+			return dropPropertyColumn(tx, "canonical_id")
+		},
+	},
+	{
+		Version: 17,
+		Name:    "saved searches",
+		Up: func(tx *sqlTx) error {
+			return execAll(tx,
+				`CREATE TABLE IF NOT EXISTS saved_searches (
+					id INTEGER PRIMARY KEY AUTOINCREMENT,
+					name TEXT NOT NULL,
+					criteria TEXT NOT NULL,
+					created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+					updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+				)`,
+			)
+		},
+		Down: func(tx *sqlTx) error {
+			return execAll(tx, "DROP TABLE IF EXISTS saved_searches")
+		},
+	},
+	{
+		Version: 18,
+		Name:    "rejected items",
+		Up: func(tx *sqlTx) error {
+			return execAll(tx,
+				`CREATE TABLE IF NOT EXISTS rejected_items (
+					id INTEGER PRIMARY KEY AUTOINCREMENT,
+					url TEXT,
+					source TEXT,
+					run_id TEXT,
+					reasons TEXT NOT NULL,
+					payload TEXT NOT NULL,
+					created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+				)`,
+			)
+		},
+		Down: func(tx *sqlTx) error {
+			return execAll(tx, "DROP TABLE IF EXISTS rejected_items")
+		},
+	},
+	{
+		Version: 19,
+		Name:    "property images",
+		Up: func(tx *sqlTx) error {
+			return execAll(tx,
+				`CREATE TABLE IF NOT EXISTS property_images (
+					id INTEGER PRIMARY KEY AUTOINCREMENT,
+					property_id INTEGER NOT NULL,
+					position INTEGER NOT NULL,
+					url TEXT NOT NULL,
+					thumbnail_path TEXT,
+					created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+					FOREIGN KEY (property_id) REFERENCES properties(id)
+				)`,
+				`CREATE UNIQUE INDEX IF NOT EXISTS idx_property_images_property_url
+				ON property_images(property_id, url)`,
+			)
+		},
+		Down: func(tx *sqlTx) error {
+			return execAll(tx, "DROP TABLE IF EXISTS property_images")
+		},
+	},
+	{
+		Version: 20,
+		Name:    "watchlists",
+		Up: func(tx *sqlTx) error {
+			return execAll(tx,
+				`CREATE TABLE IF NOT EXISTS watchlists (
+					id INTEGER PRIMARY KEY AUTOINCREMENT,
+					name TEXT NOT NULL,
+					radius_meters INTEGER NOT NULL,
+					created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+					updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+				)`,
+				`CREATE TABLE IF NOT EXISTS watchlist_properties (
+					watchlist_id INTEGER NOT NULL,
+					property_id INTEGER NOT NULL,
+					created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+					PRIMARY KEY (watchlist_id, property_id),
+					FOREIGN KEY (watchlist_id) REFERENCES watchlists(id),
+					FOREIGN KEY (property_id) REFERENCES properties(id)
+				)`,
+				`CREATE TABLE IF NOT EXISTS watch_alerts (
+					id INTEGER PRIMARY KEY AUTOINCREMENT,
+					watchlist_id INTEGER NOT NULL,
+					property_id INTEGER NOT NULL,
+					comparable_id INTEGER NOT NULL,
+					distance_meters REAL NOT NULL,
+					created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+				)`,
+				`CREATE UNIQUE INDEX IF NOT EXISTS idx_watch_alerts_match
+				ON watch_alerts(watchlist_id, property_id, comparable_id)`,
+			)
+		},
+		Down: func(tx *sqlTx) error {
+			return execAll(tx,
+				"DROP TABLE IF EXISTS watch_alerts",
+				"DROP TABLE IF EXISTS watchlist_properties",
+				"DROP TABLE IF EXISTS watchlists",
+			)
+		},
+	},
+	{
+		Version: 21,
+		Name:    "agents",
+		Up: func(tx *sqlTx) error {
+			if err := execAll(tx,
+				`CREATE TABLE IF NOT EXISTS agents (
+					id INTEGER PRIMARY KEY AUTOINCREMENT,
+					name TEXT NOT NULL,
+					url TEXT,
+					created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+					updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+				)`,
+				"CREATE UNIQUE INDEX IF NOT EXISTS idx_agents_name ON agents(name)",
+			); err != nil {
+				return err
+			}
+			if err := addPropertyColumn(tx, "agent_id", "INTEGER"); err != nil {
+				return err
+			}
+			return execAll(tx, "CREATE INDEX IF NOT EXISTS idx_properties_agent ON properties(agent_id)")
+		},
+		Down: func(tx *sqlTx) error {
+			if err := execAll(tx, "DROP INDEX IF EXISTS idx_properties_agent"); err != nil {
+				return err
+			}
+			if err := dropPropertyColumn(tx, "agent_id"); err != nil {
+				return err
+			}
+			return execAll(tx, "DROP TABLE IF EXISTS agents")
+		},
+	},
+	{
+		Version: 22,
+		Name:    "district subscriptions",
+		Up: func(tx *sqlTx) error {
+			return execAll(tx,
+				`CREATE TABLE IF NOT EXISTS district_subscriptions (
+					district TEXT PRIMARY KEY,
+					created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+				)`,
+			)
+		},
+		Down: func(tx *sqlTx) error {
+			return execAll(tx, "DROP TABLE IF EXISTS district_subscriptions")
+		},
+	},
+	{
+		Version: 23,
+		Name:    "tasks",
+		Up: func(tx *sqlTx) error {
+			return execAll(tx,
+				`CREATE TABLE IF NOT EXISTS tasks (
+					id INTEGER PRIMARY KEY AUTOINCREMENT,
+					kind TEXT NOT NULL,
+					status TEXT NOT NULL,
+					params TEXT,
+					progress REAL NOT NULL DEFAULT 0,
+					message TEXT,
+					result TEXT,
+					error TEXT,
+					created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+					started_at TIMESTAMP,
+					finished_at TIMESTAMP
+				)`,
+				"CREATE INDEX IF NOT EXISTS idx_tasks_status ON tasks(status, id)",
+			)
+		},
+		Down: func(tx *sqlTx) error {
+			return execAll(tx, "DROP TABLE IF EXISTS tasks")
+		},
+	},
+	{
+		Version: 24,
+		Name:    "audit log",
+		Up: func(tx *sqlTx) error {
+			return execAll(tx,
+				`CREATE TABLE IF NOT EXISTS audit_log (
+					id INTEGER PRIMARY KEY AUTOINCREMENT,
+					property_id INTEGER NOT NULL,
+					field TEXT NOT NULL,
+					old_value TEXT,
+					new_value TEXT,
+					source TEXT,
+					run_id TEXT,
+					changed_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+				)`,
+				"CREATE INDEX IF NOT EXISTS idx_audit_log_property ON audit_log(property_id, id)",
+				"CREATE INDEX IF NOT EXISTS idx_audit_log_run ON audit_log(run_id)",
+			)
+		},
+		Down: func(tx *sqlTx) error {
+			return execAll(tx, "DROP TABLE IF EXISTS audit_log")
+		},
+	},
+	{
+		Version: 25,
+		Name:    "delisting reasons",
+		Up: func(tx *sqlTx) error {
+			if err := addPropertyColumn(tx, "delisting_reason", "TEXT"); err != nil {
+				return err
+			}
+			if err := addPropertyColumn(tx, "delisted_at", "TIMESTAMP"); err != nil {
+				return err
+			}
+			// Listings delisted before reasons were recorded keep no delisting time
+			return execAll(tx,
+				"UPDATE properties SET delisting_reason = 'sold' WHERE status = 'sold' AND delisting_reason IS NULL",
+				"UPDATE properties SET delisting_reason = 'withdrawn' WHERE status = 'inactive' AND delisting_reason IS NULL",
+				"CREATE INDEX IF NOT EXISTS idx_properties_delisting ON properties(delisting_reason, district)",
+			)
+		},
+		Down: func(tx *sqlTx) error {
+			if err := execAll(tx, "DROP INDEX IF EXISTS idx_properties_delisting"); err != nil {
+				return err
+			}
+			if err := dropPropertyColumn(tx, "delisted_at"); err != nil {
+				return err
+			}
+			return dropPropertyColumn(tx, "delisting_reason")
+		},
+	},
+	{
+		Version: 26,
+		Name:    "price changes",
+		Up: func(tx *sqlTx) error {
+			// Backfilled from the price transitions in the history, archived ones included
+			return execAll(tx,
+				`CREATE TABLE IF NOT EXISTS price_changes (
+					id INTEGER PRIMARY KEY AUTOINCREMENT,
+					property_id INTEGER NOT NULL,
+					old_price INTEGER NOT NULL,
+					new_price INTEGER NOT NULL,
+					change_pct REAL NOT NULL,
+					changed_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+				)`,
+				"CREATE INDEX IF NOT EXISTS idx_price_changes_property ON price_changes(property_id, id)",
+				"CREATE INDEX IF NOT EXISTS idx_price_changes_changed ON price_changes(changed_at)",
+				`INSERT INTO price_changes (property_id, old_price, new_price, change_pct, changed_at)
+				SELECT property_id, previous_price, price,
+					CAST(price - previous_price AS FLOAT) * 100 / previous_price, created_at
+				FROM (
+					SELECT id, property_id, change_type, price, previous_price, created_at FROM property_history
+					UNION ALL
+					SELECT id, property_id, change_type, price, previous_price, created_at FROM property_history_archive
+				) history
+				WHERE change_type IN ('price_change', 'price_and_status_change')
+				AND price IS NOT NULL AND previous_price > 0 AND price <> previous_price
+				ORDER BY id`,
+			)
+		},
+		Down: func(tx *sqlTx) error {
+			return execAll(tx, "DROP TABLE IF EXISTS price_changes")
+		},
+	},
+	{
+		Version: 27,
+		Name:    "property tags",
+		Up: func(tx *sqlTx) error {
+			return execAll(tx,
+				`CREATE TABLE IF NOT EXISTS tags (
+					id INTEGER PRIMARY KEY AUTOINCREMENT,
+					name TEXT NOT NULL,
+					color TEXT,
+					created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+				)`,
+				"CREATE UNIQUE INDEX IF NOT EXISTS idx_tags_name ON tags(LOWER(name))",
+				`CREATE TABLE IF NOT EXISTS property_tags (
+					tag_id INTEGER NOT NULL,
+					property_id INTEGER NOT NULL,
+					created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+					PRIMARY KEY (tag_id, property_id),
+					FOREIGN KEY (tag_id) REFERENCES tags(id),
+					FOREIGN KEY (property_id) REFERENCES properties(id)
+				)`,
+				"CREATE INDEX IF NOT EXISTS idx_property_tags_property ON property_tags(property_id)",
+			)
+		},
+		Down: func(tx *sqlTx) error {
+			return execAll(tx,
+				"DROP TABLE IF EXISTS property_tags",
+				"DROP TABLE IF EXISTS tags",
+			)
+		},
+	},
+	{
+		Version: 28,
+		Name:    "change data capture sink",
+		Up: func(tx *sqlTx) error {
+			// A single row; sent_seq is the property_sync cursor mirrored so far
+			return execAll(tx,
+				`CREATE TABLE IF NOT EXISTS cdc_sink (
+					id INTEGER PRIMARY KEY,
+					enabled BOOLEAN DEFAULT 1,
+					kind TEXT NOT NULL,
+					url TEXT NOT NULL,
+					target TEXT NOT NULL,
+					sent_seq INTEGER NOT NULL DEFAULT 0,
+					last_sent_at TIMESTAMP,
+					last_error TEXT,
+					updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+				)`,
+			)
+		},
+		Down: func(tx *sqlTx) error {
+			return execAll(tx, "DROP TABLE IF EXISTS cdc_sink")
+		},
+	},
+	{
+		Version: 29,
+		Name:    "property country and currency",
+		Up: func(tx *sqlTx) error {
+			// Every property stored so far is a Dutch listing priced in euros
+			for _, column := range [][2]string{
+				{"country", "TEXT NOT NULL DEFAULT 'NL'"},
+				{"currency", "TEXT NOT NULL DEFAULT 'EUR'"},
+			} {
+				if err := addPropertyColumn(tx, column[0], column[1]); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Down: func(tx *sqlTx) error {
+			for _, column := range []string{"currency", "country"} {
+				if err := dropPropertyColumn(tx, column); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		Version: 30,
+		Name:    "notification filter rules",
+		Up: func(tx *sqlTx) error {
+			return addColumn(tx, "telegram_filters", "rules", "TEXT")
+		},
+		Down: func(tx *sqlTx) error {
+			return dropColumn(tx, "telegram_filters", "rules")
+		},
+	},
+	{
+		Version: 31,
+		Name:    "geocoding lease",
+		Up: func(tx *sqlTx) error {
+			// A single row naming the server running the geocoding pass
+			return execAll(tx,
+				`CREATE TABLE IF NOT EXISTS geocoding_lease (
+					id INTEGER PRIMARY KEY,
+					owner TEXT NOT NULL,
+					acquired_at TIMESTAMP NOT NULL,
+					renewed_at TIMESTAMP NOT NULL,
+					expires_at TIMESTAMP NOT NULL,
+					done INTEGER NOT NULL DEFAULT 0,
+					total INTEGER NOT NULL DEFAULT 0
+				)`,
+			)
+		},
+		Down: func(tx *sqlTx) error {
+			return execAll(tx, "DROP TABLE IF EXISTS geocoding_lease")
+		},
+	},
+	{
+		Version: 32,
+		Name:    "property scores",
+		Up: func(tx *sqlTx) error {
+			// Scores of the external scoring model, one row per property and
+			// score name
+			return execAll(tx,
+				`CREATE TABLE IF NOT EXISTS property_scores (
+					property_id INTEGER NOT NULL,
+					name TEXT NOT NULL,
+					score REAL NOT NULL,
+					scored_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+					PRIMARY KEY (property_id, name),
+					FOREIGN KEY (property_id) REFERENCES properties(id)
+				)`,
+				"CREATE INDEX IF NOT EXISTS idx_property_scores_name ON property_scores(name, score)",
+			)
+		},
+		Down: func(tx *sqlTx) error {
+			return execAll(tx, "DROP TABLE IF EXISTS property_scores")
+		},
+	},
+	{
+		Version: 33,
+		Name:    "validation rules",
+		Up: func(tx *sqlTx) error {
+			err := execAll(tx,
+				`CREATE TABLE IF NOT EXISTS validation_rules (
+					id INTEGER PRIMARY KEY AUTOINCREMENT,
+					field TEXT NOT NULL,
+					kind TEXT NOT NULL,
+					value TEXT,
+					message TEXT,
+					enabled BOOLEAN DEFAULT 1,
+					created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+					updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+				)`,
+			)
+			if err != nil {
+				return err
+			}
+			// The checks a rejected item failed: rule ids and "builtin"
+			return addColumn(tx, "rejected_items", "rules", "TEXT")
+		},
+		Down: func(tx *sqlTx) error {
+			if err := dropColumn(tx, "rejected_items", "rules"); err != nil {
+				return err
+			}
+			return execAll(tx, "DROP TABLE IF EXISTS validation_rules")
+		},
+	},
+	{
+		Version: 34,
+		Name:    "selling date precision",
+		Up: func(tx *sqlTx) error {
+			// NULL for the selling dates stored so far, which are taken as exact
+			return addPropertyColumn(tx, "selling_date_precision", "TEXT")
+		},
+		Down: func(tx *sqlTx) error {
+			return dropPropertyColumn(tx, "selling_date_precision")
+		},
+	},
+	{
+		Version: 35,
+		Name:    "spider runs",
+		Up: func(tx *sqlTx) error {
+			// One row per finished spider run and city, with the share of
+			// Funda's results it covered
+			return execAll(tx,
+				`CREATE TABLE IF NOT EXISTS spider_runs (
+					run_id TEXT PRIMARY KEY,
+					spider_type TEXT NOT NULL,
+					place TEXT NOT NULL,
+					started_at TIMESTAMP NOT NULL,
+					finished_at TIMESTAMP,
+					pages INTEGER NOT NULL DEFAULT 0,
+					listings INTEGER NOT NULL DEFAULT 0,
+					items INTEGER NOT NULL DEFAULT 0,
+					stored INTEGER NOT NULL DEFAULT 0,
+					new_items INTEGER NOT NULL DEFAULT 0,
+					rejected INTEGER NOT NULL DEFAULT 0,
+					result_count INTEGER,
+					coverage REAL,
+					error TEXT
+				)`,
+				"CREATE INDEX IF NOT EXISTS idx_spider_runs_place ON spider_runs(place, spider_type, started_at)",
+			)
+		},
+		Down: func(tx *sqlTx) error {
+			return execAll(tx, "DROP TABLE IF EXISTS spider_runs")
+		},
+	},
+	{
+		Version: 36,
+		Name:    "table modifications",
+		Up:      createTableModifications,
+		Down:    dropTableModifications,
+	},
+	{
+		Version: 37,
+		Name:    "district boundary versions",
+		Up: func(tx *sqlTx) error {
+			// Every set of district boundaries in force since versions are
+			// kept, with the JSON array of its GeoJSON features
+			return execAll(tx,
+				`CREATE TABLE IF NOT EXISTS district_boundary_versions (
+					id INTEGER PRIMARY KEY AUTOINCREMENT,
+					source TEXT NOT NULL,
+					valid_from TIMESTAMP NOT NULL,
+					valid_to TIMESTAMP,
+					districts INTEGER NOT NULL,
+					checksum TEXT NOT NULL,
+					features TEXT NOT NULL
+				)`,
+				"CREATE INDEX IF NOT EXISTS idx_district_boundary_versions_valid ON district_boundary_versions(valid_from)",
+			)
+		},
+		Down: func(tx *sqlTx) error {
+			return execAll(tx, "DROP TABLE IF EXISTS district_boundary_versions")
+		},
+	},
+	{
+		Version: 38,
+		Name:    "settings",
+		Up: func(tx *sqlTx) error {
+			// Server settings changed through the API, as JSON by name
+			err := execAll(tx,
+				`CREATE TABLE IF NOT EXISTS settings (
+					name TEXT PRIMARY KEY,
+					value TEXT NOT NULL,
+					updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+				)`,
+			)
+			if err != nil {
+				return err
+			}
+			// The hull options generated boundaries were made with
+			return addColumn(tx, "district_boundary_versions", "parameters", "TEXT")
+		},
+		Down: func(tx *sqlTx) error {
+			if err := dropColumn(tx, "district_boundary_versions", "parameters"); err != nil {
+				return err
+			}
+			return execAll(tx, "DROP TABLE IF EXISTS settings")
+		},
+	},
+	{
+		Version: 39,
+		Name:    "pdok cache",
+		Up: func(tx *sqlTx) error {
+			// The postal code centroids PDOK returned for a district, as a
+			// JSON array of [lon, lat] coordinates
+			return execAll(tx,
+				`CREATE TABLE IF NOT EXISTS pdok_cache (
+					district TEXT NOT NULL,
+					city TEXT NOT NULL,
+					points TEXT NOT NULL,
+					fetched_at TIMESTAMP NOT NULL,
+					PRIMARY KEY (district, city)
+				)`,
+			)
+		},
+		Down: func(tx *sqlTx) error {
+			return execAll(tx, "DROP TABLE IF EXISTS pdok_cache")
+		},
+	},
+	{
+		Version: 40,
+		Name:    "workspaces",
+		Up: func(tx *sqlTx) error {
+			// The workspaces of a multi-tenant server; the data of each is in
+			// a database of its own
+			err := execAll(tx,
+				`CREATE TABLE IF NOT EXISTS workspaces (
+					slug TEXT PRIMARY KEY,
+					name TEXT NOT NULL,
+					created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+				)`,
+			)
+			if err != nil {
+				return err
+			}
+			// The workspace a token reads, NULL for the main dataset
+			return addColumn(tx, "api_tokens", "workspace", "TEXT")
+		},
+		Down: func(tx *sqlTx) error {
+			if err := dropColumn(tx, "api_tokens", "workspace"); err != nil {
+				return err
+			}
+			return execAll(tx, "DROP TABLE IF EXISTS workspaces")
+		},
+	},
+	{
+		Version: 41,
+		Name:    "quota usage",
+		Up: func(tx *sqlTx) error {
+			// Uses of the resources with a quota, counted per UTC day
+			return execAll(tx,
+				`CREATE TABLE IF NOT EXISTS quota_usage (
+					day TEXT NOT NULL,
+					resource TEXT NOT NULL,
+					used INTEGER NOT NULL,
+					PRIMARY KEY (day, resource)
+				)`,
+			)
+		},
+		Down: func(tx *sqlTx) error {
+			return execAll(tx, "DROP TABLE IF EXISTS quota_usage")
+		},
+	},
+}
+
+// SchemaVersion is the version of the newest migration
+var SchemaVersion = migrations[len(migrations)-1].Version
+
+// execAll runs DDL statements after translating them for the dialect
+func execAll(tx *sqlTx, statements ...string) error {
+	for _, stmt := range statements {
+		if _, err := tx.Exec(tx.dialect.DDL(stmt)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// backfillDistricts sets the district of every property from its postal code,
+// one update per distinct postal code prefix
+func backfillDistricts(tx *sqlTx) error {
+	rows, err := tx.Query("SELECT DISTINCT substr(postal_code, 1, 4) FROM properties WHERE postal_code IS NOT NULL")
+	if err != nil {
+		return fmt.Errorf("failed to read postal code prefixes: %v", err)
+	}
+	var districts []string
+	for rows.Next() {
+		var prefix string
+		if err := rows.Scan(&prefix); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan postal code prefix: %v", err)
+		}
+		if district := models.District(prefix); district != "" {
+			districts = append(districts, district)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read postal code prefixes: %v", err)
+	}
+
+	for _, district := range districts {
+		if _, err := tx.Exec("UPDATE properties SET district = ? WHERE substr(postal_code, 1, 4) = ?", district, district); err != nil {
+			return fmt.Errorf("failed to backfill district %s: %v", district, err)
+		}
+	}
+	return nil
+}
+
+// backfillOutlierFlags flags the stored properties with an implausible price
+// or living area
+func backfillOutlierFlags(tx *sqlTx) error {
+	rows, err := tx.Query("SELECT id, price, living_area FROM properties WHERE price IS NOT NULL OR living_area IS NOT NULL")
+	if err != nil {
+		return fmt.Errorf("failed to read prices: %v", err)
+	}
+	flagged := make(map[int64]sql.NullString)
+	for rows.Next() {
+		var id int64
+		var price, livingArea sql.NullInt64
+		if err := rows.Scan(&id, &price, &livingArea); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan prices: %v", err)
+		}
+		if flags := outlierFlags(price, livingArea); flags.Valid {
+			flagged[id] = flags
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read prices: %v", err)
+	}
+
+	for id, flags := range flagged {
+		if _, err := tx.Exec("UPDATE properties SET outlier_flags = ? WHERE id = ?", flags, id); err != nil {
+			return fmt.Errorf("failed to flag property %d: %v", id, err)
+		}
+	}
+	return nil
+}
+
+// queryer is implemented by both sqlDB and sqlTx
+type queryer interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// tableColumns returns the column names of a table, or an error if it does not exist
+func tableColumns(q queryer, table string) (map[string]bool, error) {
+	rows, err := q.Query(fmt.Sprintf("SELECT * FROM %s WHERE 1 = 0", table))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	present := make(map[string]bool, len(columns))
+	for _, column := range columns {
+		present[column] = true
+	}
+	return present, nil
+}
+
+// addColumn adds a column unless the table already has it
+func addColumn(tx *sqlTx, table, column, definition string) error {
+	columns, err := tableColumns(tx, table)
+	if err != nil {
+		return fmt.Errorf("failed to read columns of %s: %v", table, err)
+	}
+	if columns[column] {
+		return nil
+	}
+	return execAll(tx, fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+}
+
+// addPropertyColumn adds a column to properties and to properties_archive, which
+// must keep the same columns
+func addPropertyColumn(tx *sqlTx, column, definition string) error {
+	if err := addColumn(tx, "properties", column, definition); err != nil {
+		return err
+	}
+	return addColumn(tx, "properties_archive", column, definition)
+}
+
+// dropPropertyColumn removes a column from properties and properties_archive
+func dropPropertyColumn(tx *sqlTx, column string) error {
+	if err := dropColumn(tx, "properties_archive", column); err != nil {
+		return err
+	}
+	return dropColumn(tx, "properties", column)
+}
+
+// dropColumn removes a column if the table has it
+func dropColumn(tx *sqlTx, table, column string) error {
+	columns, err := tableColumns(tx, table)
+	if err != nil {
+		return fmt.Errorf("failed to read columns of %s: %v", table, err)
+	}
+	if !columns[column] {
+		return nil
+	}
+	return execAll(tx, fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s", table, column))
+}
+
+// ensureMigrationsTable creates the schema_migrations bookkeeping table
+func (d *Database) ensureMigrationsTable() error {
+	_, err := d.db.Exec(`
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			name TEXT NOT NULL,
+			applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create schema_migrations table: %v", err)
+	}
+	return nil
+}
+
+// appliedMigrations returns the applied migration versions and when they were applied
+func (d *Database) appliedMigrations() (map[int]time.Time, error) {
+	rows, err := d.db.Query("SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query schema migrations: %v", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan schema migration: %v", err)
+		}
+		applied[version] = appliedAt
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating schema migrations: %v", err)
+	}
+	return applied, nil
+}
+
+// RunMigrations applies all pending migrations, seeds the default rows and
+// sets up the full-text search and spatial indexes
+func (d *Database) RunMigrations() error {
+	if err := d.MigrateTo(SchemaVersion); err != nil {
+		return err
+	}
+	if err := d.seedDefaults(); err != nil {
+		return err
+	}
+	if err := d.ensureSearchIndex(); err != nil {
+		return err
+	}
+	return d.ensureSpatialIndex()
+}
+
+// MigrateTo applies or reverts migrations until the schema is at the given
+// version. Already applied migrations are skipped, so it is safe to run repeatedly.
+// The views exist only at the latest version.
+func (d *Database) MigrateTo(version int) error {
+	if version < 0 || version > SchemaVersion {
+		return fmt.Errorf("unknown schema version %d, latest is %d", version, SchemaVersion)
+	}
+	if err := d.ensureMigrationsTable(); err != nil {
+		return err
+	}
+	applied, err := d.appliedMigrations()
+	if err != nil {
+		return err
+	}
+
+	// The views are dropped while tables change and created again from their
+	// current definitions once the schema is current
+	pending := false
+	for _, m := range migrations {
+		_, done := applied[m.Version]
+		if done != (m.Version <= version) {
+			pending = true
+		}
+	}
+	if pending {
+		if err := d.dropViews(); err != nil {
+			return err
+		}
+	}
+
+	// Apply pending migrations in ascending order
+	for _, m := range migrations {
+		if _, done := applied[m.Version]; done || m.Version > version {
+			continue
+		}
+		if err := d.applyMigration(m, true); err != nil {
+			return err
+		}
+	}
+
+	// Revert newer migrations in descending order
+	for i := len(migrations) - 1; i >= 0; i-- {
+		m := migrations[i]
+		if _, done := applied[m.Version]; !done || m.Version <= version {
+			continue
+		}
+		if err := d.applyMigration(m, false); err != nil {
+			return err
+		}
+	}
+
+	if version == SchemaVersion {
+		return d.ensureViews()
+	}
+	return nil
+}
+
+// applyMigration runs one migration step and records it in a single transaction
+func (d *Database) applyMigration(m migration, up bool) error {
+	direction := "up"
+	step := m.Up
+	if !up {
+		direction = "down"
+		step = m.Down
+	}
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if err := step(tx); err != nil {
+		return fmt.Errorf("migration %d (%s) %s failed: %v", m.Version, m.Name, direction, err)
+	}
+
+	if up {
+		_, err = tx.Exec("INSERT INTO schema_migrations (version, name) VALUES (?, ?)", m.Version, m.Name)
+	} else {
+		_, err = tx.Exec("DELETE FROM schema_migrations WHERE version = ?", m.Version)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to record migration %d: %v", m.Version, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit migration %d: %v", m.Version, err)
+	}
+	return nil
+}
+
+// MigrationStatus lists every known migration and whether it has been applied
+func (d *Database) MigrationStatus() ([]models.MigrationStatus, error) {
+	if err := d.ensureMigrationsTable(); err != nil {
+		return nil, err
+	}
+	applied, err := d.appliedMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]models.MigrationStatus, 0, len(migrations))
+	for _, m := range migrations {
+		status := models.MigrationStatus{Version: m.Version, Name: m.Name}
+		if appliedAt, ok := applied[m.Version]; ok {
+			status.Applied = true
+			status.AppliedAt = &appliedAt
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// seedDefaults inserts the rows the application expects to exist
+func (d *Database) seedDefaults() error {
+	// Ensure we have exactly one row in telegram_filters
+	var count int
+	err := d.db.QueryRow("SELECT COUNT(*) FROM telegram_filters").Scan(&count)
+	if err != nil {
+		return fmt.Errorf("failed to count telegram_filters: %v", err)
+	}
+
+	if count == 0 {
+		_, err = d.db.Exec("INSERT INTO telegram_filters DEFAULT VALUES")
+		if err != nil {
+			return fmt.Errorf("failed to insert default telegram_filters: %v", err)
+		}
+	}
+
+	return d.seedDefaultPreferences()
+}
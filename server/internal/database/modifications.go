@@ -0,0 +1,135 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"fundamental/server/internal/models"
+	"time"
+)
+
+// modificationTrackedTables are the tables whose changes are counted in
+// table_modifications, for conditional requests on the data read from them
+var modificationTrackedTables = []string{
+	"properties", "property_history", "tags", "property_tags", "property_scores",
+}
+
+// modificationUpsert counts a change of the named table
+func modificationUpsert(table string) string {
+	return fmt.Sprintf(`INSERT INTO table_modifications (table_name, version, modified_at)
+		VALUES (%s, 1, CURRENT_TIMESTAMP)
+		ON CONFLICT (table_name) DO UPDATE SET
+			version = table_modifications.version + 1, modified_at = excluded.modified_at;`, table)
+}
+
+// sqliteModificationOps are the operations counted in SQLite, by the suffix
+// of their trigger's name
+var sqliteModificationOps = [][2]string{{"ai", "INSERT"}, {"au", "UPDATE"}, {"ad", "DELETE"}}
+
+// sqliteModificationTriggers count the inserts, updates and deletes of a table.
+// SQLite triggers run for each row, so a bulk ingest bumps the version once
+// per row; only its changing matters.
+func sqliteModificationTriggers(table string) []string {
+	var triggers []string
+	for _, op := range sqliteModificationOps {
+		triggers = append(triggers, fmt.Sprintf(`CREATE TRIGGER IF NOT EXISTS %[1]s_modified_%[2]s AFTER %[3]s ON %[1]s BEGIN
+			%[4]s
+		END`, table, op[0], op[1], modificationUpsert("'"+table+"'")))
+	}
+	return triggers
+}
+
+// postgresModificationFunction counts a change of the table that fired the
+// trigger; the triggers run once per statement
+var postgresModificationFunction = `CREATE OR REPLACE FUNCTION table_modifications_record() RETURNS trigger AS $$
+	BEGIN
+		` + modificationUpsert("TG_TABLE_NAME") + `
+		RETURN NULL;
+	END
+	$$ LANGUAGE plpgsql`
+
+// createTableModifications creates the change counters of the tracked tables
+// and the triggers that keep them up to date
+func createTableModifications(tx *sqlTx) error {
+	err := execAll(tx,
+		`CREATE TABLE IF NOT EXISTS table_modifications (
+			table_name TEXT PRIMARY KEY,
+			version INTEGER NOT NULL,
+			modified_at TIMESTAMP NOT NULL
+		)`,
+	)
+	if err != nil {
+		return err
+	}
+
+	statements := []string{}
+	if tx.dialect.Name() == DriverPostgres {
+		statements = append(statements, postgresModificationFunction)
+	}
+	for _, table := range modificationTrackedTables {
+		// The data present before tracking counts as the first version
+		statements = append(statements, modificationUpsert("'"+table+"'"))
+		if tx.dialect.Name() == DriverPostgres {
+			statements = append(statements,
+				fmt.Sprintf("DROP TRIGGER IF EXISTS %[1]s_modified ON %[1]s", table),
+				fmt.Sprintf(`CREATE TRIGGER %[1]s_modified AFTER INSERT OR UPDATE OR DELETE OR TRUNCATE ON %[1]s
+				FOR EACH STATEMENT EXECUTE FUNCTION table_modifications_record()`, table),
+			)
+		} else {
+			statements = append(statements, sqliteModificationTriggers(table)...)
+		}
+	}
+	for _, stmt := range statements {
+		if _, err := tx.Exec(stmt); err != nil {
+			return fmt.Errorf("failed to create modification trigger: %v", err)
+		}
+	}
+	return nil
+}
+
+// dropTableModifications removes the change counters and their triggers
+func dropTableModifications(tx *sqlTx) error {
+	var statements []string
+	for _, table := range modificationTrackedTables {
+		if tx.dialect.Name() == DriverPostgres {
+			statements = append(statements, fmt.Sprintf("DROP TRIGGER IF EXISTS %[1]s_modified ON %[1]s", table))
+			continue
+		}
+		for _, op := range sqliteModificationOps {
+			statements = append(statements, fmt.Sprintf("DROP TRIGGER IF EXISTS %s_modified_%s", table, op[0]))
+		}
+	}
+	if tx.dialect.Name() == DriverPostgres {
+		statements = append(statements, "DROP FUNCTION IF EXISTS table_modifications_record()")
+	}
+	statements = append(statements, "DROP TABLE IF EXISTS table_modifications")
+	for _, stmt := range statements {
+		if _, err := tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LastModified returns the combined change counter of tables: the sum of their
+// versions, which changes whenever one of them does, and the newest of their
+// modification times. Untracked tables are ignored.
+func (d *Database) LastModified(tables ...string) (models.TableModification, error) {
+	var last models.TableModification
+	for _, table := range tables {
+		var version int64
+		var modifiedAt time.Time
+		err := d.db.QueryRow("SELECT version, modified_at FROM table_modifications WHERE table_name = ?", table).
+			Scan(&version, &modifiedAt)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return models.TableModification{}, fmt.Errorf("failed to get modification of %s: %v", table, err)
+		}
+		last.Version += version
+		if modifiedAt.After(last.ModifiedAt) {
+			last.ModifiedAt = modifiedAt
+		}
+	}
+	return last, nil
+}
@@ -0,0 +1,88 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"fundamental/server/internal/models"
+	"sort"
+)
+
+// neighborhoodValues collects the values a neighborhood's medians are taken from
+type neighborhoodValues struct {
+	stats                          models.NeighborhoodStats
+	askingPerSqm, soldPerSqm, days []float64
+}
+
+// GetNeighborhoodStats returns the listings and sales of the past year per
+// neighborhood of a city, or of every city when city is empty, ordered by city
+// and neighborhood. Properties without a neighborhood are left out, and
+// implausible prices and living areas are left out of the medians.
+func (d *Database) GetNeighborhoodStats(city string) ([]models.NeighborhoodStats, error) {
+	rows, err := d.db.Query(`
+		SELECT COALESCE(city, ''), neighborhood, status, price, living_area,
+		       CASE WHEN status = 'sold' AND listing_date IS NOT NULL AND `+exactSellingDate("")+`
+		            THEN `+d.dialect.DaysBetween("listing_date", "selling_date")+` END
+		FROM properties
+		WHERE neighborhood IS NOT NULL AND neighborhood <> ''
+		AND `+cityFilter(city)+`
+		AND (
+			status IN ('active', 'republished')
+			OR (status = 'sold' AND selling_date >= `+d.dialect.DateOffset("-12 months")+`)
+		)
+	`, city, city)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query neighborhood prices: %v", err)
+	}
+	defer rows.Close()
+
+	neighborhoods := make(map[[2]string]*neighborhoodValues)
+	for rows.Next() {
+		var cityName, name, status string
+		var price, livingArea, days sql.NullFloat64
+		if err := rows.Scan(&cityName, &name, &status, &price, &livingArea, &days); err != nil {
+			return nil, fmt.Errorf("failed to scan neighborhood price: %v", err)
+		}
+
+		key := [2]string{cityName, name}
+		n, ok := neighborhoods[key]
+		if !ok {
+			n = &neighborhoodValues{stats: models.NeighborhoodStats{City: cityName, Neighborhood: name}}
+			neighborhoods[key] = n
+		}
+
+		validPrice := price.Valid && price.Float64 >= 50000 && price.Float64 <= 10000000
+		validArea := livingArea.Valid && livingArea.Float64 >= 15 && livingArea.Float64 <= 1000
+		if status != "sold" {
+			n.stats.ActiveListings++
+			if validPrice && validArea {
+				n.askingPerSqm = append(n.askingPerSqm, price.Float64/livingArea.Float64)
+			}
+			continue
+		}
+		n.stats.SoldLastYear++
+		if validPrice && validArea {
+			n.soldPerSqm = append(n.soldPerSqm, price.Float64/livingArea.Float64)
+		}
+		if days.Valid && days.Float64 >= 0 {
+			n.days = append(n.days, days.Float64)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating neighborhood prices: %v", err)
+	}
+
+	stats := make([]models.NeighborhoodStats, 0, len(neighborhoods))
+	for _, n := range neighborhoods {
+		n.stats.MedianAskingPerSqm = roundedMedian(n.askingPerSqm)
+		n.stats.MedianSoldPerSqm = roundedMedian(n.soldPerSqm)
+		n.stats.MedianDaysToSell = roundedMedian(n.days)
+		stats = append(stats, n.stats)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].City != stats[j].City {
+			return stats[i].City < stats[j].City
+		}
+		return stats[i].Neighborhood < stats[j].Neighborhood
+	})
+	return stats, nil
+}
@@ -0,0 +1,295 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"fundamental/server/config"
+	"fundamental/server/internal/country"
+	"fundamental/server/internal/models"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Bounds outside of which a property is flagged as an outlier
+const (
+	outlierMinPrice       = 50000
+	outlierMaxPrice       = 10000000
+	outlierMinLivingArea  = 15
+	outlierMaxLivingArea  = 1000
+	outlierMinPricePerSqm = 500
+	outlierMaxPricePerSqm = 30000
+)
+
+var normalizeSpacesRegex = regexp.MustCompile(`\s+`)
+
+// statusTaxonomy maps the stored status spellings to the statuses the queries use
+var statusTaxonomy = map[string]string{
+	"active":      "active",
+	"sold":        "sold",
+	"inactive":    "inactive",
+	"republished": "republished",
+	"te koop":     "active",
+	"beschikbaar": "active",
+	"for sale":    "active",
+	"verkocht":    "sold",
+	"ingetrokken": "inactive",
+	"withdrawn":   "inactive",
+}
+
+// normalizedFields are the columns normalization can change, in report order
+var normalizedFields = []string{"url", "city", "postal_code", "district", "status", "outlier_flags"}
+
+// outlierFlags returns the comma-separated reasons a price and living area are
+// implausible, or NULL when they are not
+func outlierFlags(price, livingArea sql.NullInt64) sql.NullString {
+	var flags []string
+	if price.Valid && (price.Int64 < outlierMinPrice || price.Int64 > outlierMaxPrice) {
+		flags = append(flags, "price")
+	}
+	if livingArea.Valid && (livingArea.Int64 < outlierMinLivingArea || livingArea.Int64 > outlierMaxLivingArea) {
+		flags = append(flags, "living_area")
+	}
+	if price.Valid && livingArea.Valid && livingArea.Int64 > 0 {
+		perSqm := price.Int64 / livingArea.Int64
+		if perSqm < outlierMinPricePerSqm || perSqm > outlierMaxPricePerSqm {
+			flags = append(flags, "price_per_sqm")
+		}
+	}
+	return sql.NullString{String: strings.Join(flags, ","), Valid: len(flags) > 0}
+}
+
+// itemOutlierFlags returns the outlier flags of a scraped item
+func itemOutlierFlags(price, livingArea interface{}) sql.NullString {
+	area := historyPrice(livingArea)
+	if area.Valid && area.Int64 <= 0 {
+		area = sql.NullInt64{} // Stored as NULL, see InsertProperties
+	}
+	return outlierFlags(historyPrice(price), area)
+}
+
+// canonicalURL lowercases the scheme and host of a listing URL and drops the
+// query and fragment, which carry tracking parameters rather than the listing
+func canonicalURL(raw string) string {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || u.Host == "" {
+		return raw
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = strings.ToLower(u.Host)
+	if u.Host == "funda.nl" {
+		u.Host = "www.funda.nl"
+	}
+	u.RawQuery, u.ForceQuery, u.Fragment, u.RawFragment = "", false, "", ""
+	return u.String()
+}
+
+// canonicalPostalCode formats a postal code the way its country writes them,
+// such as "1234 AB" in the Netherlands; other values are returned unchanged
+func canonicalPostalCode(countryCode, postalCode string) string {
+	return country.Get(countryCode).CanonicalPostalCode(postalCode)
+}
+
+// canonicalCity collapses whitespace and uses the spelling of the configured
+// metropolitan city with the same Funda name, e.g. "Den-bosch" becomes
+// "'s-Hertogenbosch"
+func canonicalCity(city string, configured map[string]string) string {
+	cleaned := normalizeSpacesRegex.ReplaceAllString(strings.TrimSpace(city), " ")
+	if name, ok := configured[config.NormalizeCity(cleaned)]; ok {
+		return name
+	}
+	return cleaned
+}
+
+// normalizeRow holds the normalized columns of a property
+type normalizeRow struct {
+	id           int64
+	country      string
+	values       map[string]sql.NullString
+	outlierFlags sql.NullString // recomputed from price and living area
+	provenance   map[string]models.FieldProvenance
+}
+
+// NormalizeProperties re-applies the current normalization rules to every
+// stored property: city spelling, postal code format, canonical URLs, the
+// status taxonomy, the district column and outlier flags. With apply false it
+// only reports the changes. At most maxChanges changes and skipped changes are
+// listed; the counts cover all of them.
+//
+// Fields set by hand are left alone, as are URLs whose canonical form belongs
+// to another property and statuses outside the taxonomy; these are reported
+// as skipped.
+func (d *Database) NormalizeProperties(apply bool, maxChanges int) (*models.NormalizationReport, error) {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	configured := make(map[string]string)
+	cityRows, err := tx.Query("SELECT DISTINCT city FROM metropolitan_cities")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get configured cities: %v", err)
+	}
+	for cityRows.Next() {
+		var city string
+		if err := cityRows.Scan(&city); err != nil {
+			cityRows.Close()
+			return nil, fmt.Errorf("failed to scan configured city: %v", err)
+		}
+		configured[config.NormalizeCity(city)] = city
+	}
+	cityRows.Close()
+
+	// Read every row before writing any, the rows are compared against each
+	// other for URL conflicts
+	rows, err := tx.Query(`
+		SELECT id, country, url, city, postal_code, district, status, outlier_flags,
+			price, living_area, field_provenance
+		FROM properties
+		ORDER BY id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read properties: %v", err)
+	}
+	var stored []normalizeRow
+	urls := make(map[string]int64)
+	for rows.Next() {
+		var row normalizeRow
+		var urlValue, city, postalCode, district, status, flags, provenance sql.NullString
+		var price, livingArea sql.NullInt64
+		var countryCode sql.NullString
+		if err := rows.Scan(&row.id, &countryCode, &urlValue, &city, &postalCode, &district, &status, &flags,
+			&price, &livingArea, &provenance); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan property: %v", err)
+		}
+		row.values = map[string]sql.NullString{
+			"url":           urlValue,
+			"city":          city,
+			"postal_code":   postalCode,
+			"district":      district,
+			"status":        status,
+			"outlier_flags": flags,
+		}
+		row.country = countryCode.String
+		row.outlierFlags = outlierFlags(price, livingArea)
+		row.provenance = parseProvenance(provenance.String)
+		urls[urlValue.String] = row.id
+		stored = append(stored, row)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read properties: %v", err)
+	}
+
+	report := &models.NormalizationReport{
+		DryRun:      !apply,
+		Scanned:     len(stored),
+		FieldCounts: make(map[string]int),
+		Changes:     []models.NormalizationChange{},
+		Skipped:     []models.NormalizationChange{},
+	}
+	for _, row := range stored {
+		current := row.values
+		normalized := make(map[string]sql.NullString, len(normalizedFields))
+		for _, field := range normalizedFields {
+			normalized[field] = current[field]
+		}
+		if current["url"].Valid {
+			normalized["url"] = sql.NullString{String: canonicalURL(current["url"].String), Valid: true}
+		}
+		if current["city"].Valid {
+			normalized["city"] = sql.NullString{String: canonicalCity(current["city"].String, configured), Valid: true}
+		}
+		if current["postal_code"].Valid {
+			normalized["postal_code"] = sql.NullString{String: canonicalPostalCode(row.country, current["postal_code"].String), Valid: true}
+		}
+		if current["status"].Valid {
+			status := strings.ToLower(strings.TrimSpace(current["status"].String))
+			if canonical, ok := statusTaxonomy[status]; ok {
+				status = canonical
+			}
+			normalized["status"] = sql.NullString{String: status, Valid: true}
+		}
+		normalized["outlier_flags"] = row.outlierFlags
+
+		// Decide which changes can be applied before deriving the district
+		skip := func(field, reason string) {
+			change := normalizationChange(row.id, current["url"].String, field, current[field], normalized[field])
+			change.Reason = reason
+			if len(report.Skipped) < maxChanges {
+				report.Skipped = append(report.Skipped, change)
+			}
+			normalized[field] = current[field]
+		}
+		for _, field := range []string{"city", "postal_code", "status"} {
+			if normalized[field] != current[field] && row.provenance[field].Source == models.SourceManual {
+				skip(field, "set by hand")
+			}
+		}
+		if status := normalized["status"]; status.Valid {
+			if _, ok := statusTaxonomy[status.String]; !ok {
+				skip("status", "unknown status")
+			}
+		}
+		if canonical := normalized["url"]; canonical != current["url"] {
+			if owner, taken := urls[canonical.String]; taken && owner != row.id {
+				skip("url", fmt.Sprintf("canonical URL belongs to property %d", owner))
+			} else {
+				delete(urls, current["url"].String)
+				urls[canonical.String] = row.id
+			}
+		}
+		normalized["district"] = propertyDistrict(row.country, normalized["postal_code"].String)
+
+		var assignments []string
+		var args []interface{}
+		for _, field := range normalizedFields {
+			if normalized[field] == current[field] {
+				continue
+			}
+			report.FieldCounts[field]++
+			if len(report.Changes) < maxChanges {
+				report.Changes = append(report.Changes,
+					normalizationChange(row.id, current["url"].String, field, current[field], normalized[field]))
+			} else {
+				report.Truncated = true
+			}
+			// Field names come from normalizedFields
+			assignments = append(assignments, field+" = ?")
+			args = append(args, normalized[field])
+		}
+		if len(assignments) == 0 {
+			continue
+		}
+		report.ChangedProperties++
+
+		if apply {
+			args = append(args, row.id)
+			_, err := tx.Exec("UPDATE properties SET "+strings.Join(assignments, ", ")+
+				", updated_at = CURRENT_TIMESTAMP WHERE id = ?", args...)
+			if err != nil {
+				return nil, fmt.Errorf("failed to normalize property %d: %v", row.id, err)
+			}
+		}
+	}
+
+	if apply {
+		if err := tx.Commit(); err != nil {
+			return nil, fmt.Errorf("failed to commit transaction: %v", err)
+		}
+	}
+	return report, nil
+}
+
+func normalizationChange(id int64, url, field string, old, new sql.NullString) models.NormalizationChange {
+	change := models.NormalizationChange{PropertyID: id, URL: url, Field: field}
+	if old.Valid {
+		change.Old = &old.String
+	}
+	if new.Valid {
+		change.New = &new.String
+	}
+	return change
+}
@@ -0,0 +1,66 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"fundamental/server/internal/models"
+	"time"
+)
+
+// GetPDOKPoints returns the cached PDOK points of a district, or nil when
+// they were never fetched
+func (d *Database) GetPDOKPoints(district, city string) (*models.PDOKCacheEntry, error) {
+	entry := models.PDOKCacheEntry{District: district, City: city}
+	var points string
+	err := d.db.QueryRow("SELECT points, fetched_at FROM pdok_cache WHERE district = ? AND city = ?",
+		district, city).Scan(&points, &entry.FetchedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get PDOK points: %v", err)
+	}
+	if err := json.Unmarshal([]byte(points), &entry.Points); err != nil {
+		return nil, fmt.Errorf("failed to decode PDOK points of district %s: %v", district, err)
+	}
+	return &entry, nil
+}
+
+// SavePDOKPoints caches the points PDOK returned for a district
+func (d *Database) SavePDOKPoints(entry models.PDOKCacheEntry) error {
+	points, err := json.Marshal(entry.Points)
+	if err != nil {
+		return fmt.Errorf("failed to encode PDOK points: %v", err)
+	}
+	_, err = d.db.Exec(`
+		INSERT INTO pdok_cache (district, city, points, fetched_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT (district, city) DO UPDATE SET points = excluded.points, fetched_at = excluded.fetched_at
+	`, entry.District, entry.City, string(points), entry.FetchedAt.UTC())
+	if err != nil {
+		return fmt.Errorf("failed to save PDOK points: %v", err)
+	}
+	return nil
+}
+
+// GetPDOKCacheStats returns the number of cached districts and when the
+// oldest and newest were fetched
+func (d *Database) GetPDOKCacheStats() (*models.PDOKCacheStats, error) {
+	var stats models.PDOKCacheStats
+	if err := d.db.QueryRow("SELECT COUNT(*) FROM pdok_cache").Scan(&stats.Entries); err != nil {
+		return nil, fmt.Errorf("failed to count PDOK cache entries: %v", err)
+	}
+	if stats.Entries == 0 {
+		return &stats, nil
+	}
+	// Ordered rather than MIN and MAX, which SQLite returns as text
+	var oldest, newest time.Time
+	if err := d.db.QueryRow("SELECT fetched_at FROM pdok_cache ORDER BY fetched_at LIMIT 1").Scan(&oldest); err != nil {
+		return nil, fmt.Errorf("failed to get oldest PDOK cache entry: %v", err)
+	}
+	if err := d.db.QueryRow("SELECT fetched_at FROM pdok_cache ORDER BY fetched_at DESC LIMIT 1").Scan(&newest); err != nil {
+		return nil, fmt.Errorf("failed to get newest PDOK cache entry: %v", err)
+	}
+	stats.OldestFetchedAt, stats.NewestFetchedAt = &oldest, &newest
+	return &stats, nil
+}
@@ -0,0 +1,143 @@
+package database
+
+import (
+	"encoding/json"
+	"fmt"
+	"fundamental/server/internal/format"
+	"fundamental/server/internal/models"
+)
+
+// defaultPreferencesOwner is the owner under which default preferences are stored
+const defaultPreferencesOwner = ""
+
+// defaultPreferences are seeded on migration and returned until a user overrides them
+var defaultPreferences = []struct {
+	Namespace string
+	Key       string
+	Value     string
+}{
+	{"dashboard", "default_city", `""`},
+	{"dashboard", "date_range", `{"preset":"12m","start_date":"","end_date":""}`},
+	{"map", "layers", `["properties","districts"]`},
+	{"map", "heatmap", `false`},
+	{"format", "currency", `{"locale":"nl-NL","currency":"EUR"}`},
+}
+
+// seedDefaultPreferences inserts missing default preferences without touching existing ones
+func (d *Database) seedDefaultPreferences() error {
+	for _, pref := range defaultPreferences {
+		_, err := d.db.Exec(`
+			INSERT INTO user_preferences (owner, namespace, key, value)
+			VALUES (?, ?, ?, ?)
+			ON CONFLICT DO NOTHING
+		`, defaultPreferencesOwner, pref.Namespace, pref.Key, pref.Value)
+		if err != nil {
+			return fmt.Errorf("failed to seed default preference %s.%s: %v", pref.Namespace, pref.Key, err)
+		}
+	}
+	return nil
+}
+
+// GetPreferences returns the preferences of an owner merged over the defaults.
+// An empty namespace returns every namespace.
+func (d *Database) GetPreferences(owner, namespace string) ([]models.Preference, error) {
+	rows, err := d.db.Query(`
+		SELECT namespace, key, value, owner = '' as is_default, updated_at
+		FROM user_preferences p
+		WHERE (owner = ? OR (owner = '' AND NOT EXISTS (
+			SELECT 1 FROM user_preferences o
+			WHERE o.owner = ? AND o.namespace = p.namespace AND o.key = p.key
+		)))
+		AND (? = '' OR namespace = ?)
+		ORDER BY namespace, key
+	`, owner, owner, namespace, namespace)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query preferences: %v", err)
+	}
+	defer rows.Close()
+
+	preferences := []models.Preference{}
+	for rows.Next() {
+		var pref models.Preference
+		var value string
+		if err := rows.Scan(&pref.Namespace, &pref.Key, &value, &pref.IsDefault, &pref.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan preference: %v", err)
+		}
+		pref.Value = json.RawMessage(value)
+		preferences = append(preferences, pref)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating preferences: %v", err)
+	}
+
+	return preferences, nil
+}
+
+// CountPreferences returns the number of preferences stored for an owner
+func (d *Database) CountPreferences(owner string) (int, error) {
+	var count int
+	err := d.db.QueryRow("SELECT COUNT(*) FROM user_preferences WHERE owner = ?", owner).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count preferences: %v", err)
+	}
+	return count, nil
+}
+
+// HasPreference reports whether an owner stored a value for a preference
+func (d *Database) HasPreference(owner, namespace, key string) (bool, error) {
+	var exists bool
+	err := d.db.QueryRow(`
+		SELECT EXISTS(SELECT 1 FROM user_preferences WHERE owner = ? AND namespace = ? AND key = ?)
+	`, owner, namespace, key).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("failed to check preference: %v", err)
+	}
+	return exists, nil
+}
+
+// SetPreference stores a JSON encoded preference value for an owner
+func (d *Database) SetPreference(owner, namespace, key string, value json.RawMessage) error {
+	_, err := d.db.Exec(`
+		INSERT INTO user_preferences (owner, namespace, key, value, updated_at)
+		VALUES (?, ?, ?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT(owner, namespace, key) DO UPDATE SET
+			value = excluded.value,
+			updated_at = CURRENT_TIMESTAMP
+	`, owner, namespace, key, string(value))
+	if err != nil {
+		return fmt.Errorf("failed to set preference: %v", err)
+	}
+	return nil
+}
+
+// DeletePreference removes an owner's preference so the default applies again
+func (d *Database) DeletePreference(owner, namespace, key string) error {
+	_, err := d.db.Exec(`
+		DELETE FROM user_preferences
+		WHERE owner = ? AND namespace = ? AND key = ?
+	`, owner, namespace, key)
+	if err != nil {
+		return fmt.Errorf("failed to delete preference: %v", err)
+	}
+	return nil
+}
+
+// GetFormatSettings returns the number and currency format of an owner, from
+// the format.currency preference; the empty owner returns the default
+func (d *Database) GetFormatSettings(owner string) (format.Settings, error) {
+	preferences, err := d.GetPreferences(owner, "format")
+	if err != nil {
+		return format.DefaultSettings, err
+	}
+	for _, pref := range preferences {
+		if pref.Key != "currency" {
+			continue
+		}
+		var settings format.Settings
+		if err := json.Unmarshal(pref.Value, &settings); err != nil {
+			return format.DefaultSettings, fmt.Errorf("invalid format.currency preference: %v", err)
+		}
+		return settings.Merge(format.DefaultSettings), nil
+	}
+	return format.DefaultSettings, nil
+}
@@ -0,0 +1,65 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"fundamental/server/internal/models"
+	"math"
+)
+
+// priceChangeInsert records a change of the asking price of a property, see
+// propertyUpserter
+const priceChangeInsert = `
+	INSERT INTO price_changes (property_id, old_price, new_price, change_pct)
+	VALUES (?, ?, ?, ?)
+`
+
+// GetPriceDrops returns the listed properties whose asking price dropped by at
+// least minPct percent in the past days, with their largest drop in that
+// period, largest drops first
+func (d *Database) GetPriceDrops(days int, minPct float64, city string, limit int) ([]models.PriceDrop, error) {
+	rows, err := d.db.Query(`
+		SELECT c.id, c.property_id, c.old_price, c.new_price, c.change_pct, c.changed_at,
+			p.url, COALESCE(p.street, ''), COALESCE(p.city, ''), COALESCE(p.district, ''),
+			p.status, p.price, p.living_area
+		FROM (
+			SELECT id, property_id, old_price, new_price, change_pct, changed_at,
+				ROW_NUMBER() OVER (PARTITION BY property_id ORDER BY change_pct, id DESC) AS drop_rank
+			FROM price_changes
+			WHERE change_pct < 0
+			AND changed_at >= `+d.dialect.TimestampOffset()+`
+		) c
+		JOIN properties p ON p.id = c.property_id
+		WHERE c.drop_rank = 1
+		AND c.change_pct <= ?
+		AND p.status IN ('active', 'republished')
+		AND `+cityFilter(city)+`
+		ORDER BY c.change_pct, c.id DESC
+		LIMIT ?
+	`, fmt.Sprintf("-%d days", days), -minPct, city, city, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query price drops: %v", err)
+	}
+	defer rows.Close()
+
+	drops := []models.PriceDrop{}
+	for rows.Next() {
+		var drop models.PriceDrop
+		var livingArea sql.NullInt64
+		if err := rows.Scan(&drop.ID, &drop.PropertyID, &drop.OldPrice, &drop.NewPrice, &drop.ChangePct,
+			&drop.ChangedAt, &drop.URL, &drop.Street, &drop.City, &drop.District,
+			&drop.Status, &drop.Price, &livingArea); err != nil {
+			return nil, fmt.Errorf("failed to scan price drop: %v", err)
+		}
+		drop.ChangePct = math.Round(drop.ChangePct*10) / 10
+		if livingArea.Valid {
+			la := int(livingArea.Int64)
+			drop.LivingArea = &la
+		}
+		drops = append(drops, drop)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating price drops: %v", err)
+	}
+	return drops, nil
+}
@@ -0,0 +1,72 @@
+package database
+
+import (
+	"fmt"
+	"fundamental/server/internal/models"
+	"strings"
+)
+
+// MaxPropertyBatch bounds the IDs and URLs asked for in one GetPropertiesBatch
+const MaxPropertyBatch = 500
+
+// GetPropertiesBatch returns the properties with the given IDs or listing
+// URLs, ordered by ID, with their tags and scores. URLs are compared in their
+// canonical form. The IDs and URLs that match no property are reported as
+// missing, the URLs as they were given.
+func (d *Database) GetPropertiesBatch(ids []int64, urls []string) (*models.PropertyBatch, error) {
+	if len(ids)+len(urls) > MaxPropertyBatch {
+		return nil, errorf(ErrValidation, "at most %d IDs and URLs can be fetched at once", MaxPropertyBatch)
+	}
+	batch := &models.PropertyBatch{Properties: []models.Property{}, MissingIDs: []int64{}, MissingURLs: []string{}}
+	if len(ids)+len(urls) == 0 {
+		return batch, nil
+	}
+
+	var conditions []string
+	var args []interface{}
+	if len(ids) > 0 {
+		conditions = append(conditions, "id IN (?"+strings.Repeat(", ?", len(ids)-1)+")")
+		for _, id := range ids {
+			args = append(args, id)
+		}
+	}
+	canonical := make([]string, len(urls))
+	if len(urls) > 0 {
+		conditions = append(conditions, "url IN (?"+strings.Repeat(", ?", len(urls)-1)+")")
+		for i, u := range urls {
+			canonical[i] = canonicalURL(u)
+			args = append(args, canonical[i])
+		}
+	}
+
+	query := "SELECT " + propertyColumns + " FROM properties WHERE " + strings.Join(conditions, " OR ") + " ORDER BY id"
+	foundIDs := make(map[int64]bool)
+	foundURLs := make(map[string]bool)
+	err := d.forEachProperty(query, args, func(p models.Property) error {
+		batch.Properties = append(batch.Properties, p)
+		foundIDs[p.ID] = true
+		foundURLs[p.URL] = true
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query properties: %v", err)
+	}
+	if err := d.setPropertyTags(batch.Properties); err != nil {
+		return nil, err
+	}
+	if err := d.setPropertyScores(batch.Properties); err != nil {
+		return nil, err
+	}
+
+	for _, id := range ids {
+		if !foundIDs[id] {
+			batch.MissingIDs = append(batch.MissingIDs, id)
+		}
+	}
+	for i, u := range urls {
+		if !foundURLs[canonical[i]] {
+			batch.MissingURLs = append(batch.MissingURLs, u)
+		}
+	}
+	return batch, nil
+}
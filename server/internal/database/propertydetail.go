@@ -0,0 +1,118 @@
+package database
+
+import (
+	"fmt"
+	"fundamental/server/internal/models"
+)
+
+const (
+	// detailComparables is the number of comparables of a property detail
+	detailComparables = 5
+	// detailComparableRadius is the distance in meters within which
+	// comparables of a property detail are searched
+	detailComparableRadius = 1500
+)
+
+// GetPropertyDetail returns a property with its tags, scores, history,
+// geocoding state and nearby comparables, or nil if it does not exist. An
+// archived property is read from the archive.
+func (d *Database) GetPropertyDetail(propertyID int64) (*models.PropertyDetail, error) {
+	var detail *models.PropertyDetail
+	var table string
+	for _, t := range []string{"properties", "properties_archive"} {
+		err := d.forEachProperty(`
+			SELECT `+propertyColumns+`
+			FROM `+t+`
+			WHERE id = ?
+		`, []interface{}{propertyID}, func(p models.Property) error {
+			p.Archived = t == "properties_archive"
+			detail = &models.PropertyDetail{Property: p}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get property: %v", err)
+		}
+		if detail != nil {
+			table = t
+			break
+		}
+	}
+	if detail == nil {
+		return nil, nil
+	}
+
+	properties := []models.Property{detail.Property}
+	if err := d.setPropertyTags(properties); err != nil {
+		return nil, err
+	}
+	if err := d.setPropertyScores(properties); err != nil {
+		return nil, err
+	}
+	detail.Property = properties[0]
+
+	err := d.db.QueryRow(`
+		SELECT CASE
+			WHEN latitude IS NOT NULL AND longitude IS NOT NULL THEN ?
+			WHEN `+pendingGeocodingCondition+` THEN ?
+			WHEN geocoding_attempted = 0 THEN ?
+			ELSE ?
+		END
+		FROM `+table+`
+		WHERE id = ?
+	`, models.GeocodeLocated, models.GeocodePending, models.GeocodeNoAddress, models.GeocodeFailed,
+		propertyID).Scan(&detail.GeocodeStatus)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get geocoding state: %v", err)
+	}
+
+	if detail.History, err = d.GetPropertyHistory(propertyID); err != nil {
+		return nil, err
+	}
+	if detail.Comparables, err = d.propertyComparables(detail.Property); err != nil {
+		return nil, err
+	}
+	return detail, nil
+}
+
+// propertyComparables returns the nearest other homes of the same property
+// type whose living area differs from the property's by less than
+// bidSimilarArea, leaving out the other listings of the same home
+func (d *Database) propertyComparables(p models.Property) ([]models.NearbyProperty, error) {
+	comparables := []models.NearbyProperty{}
+	if p.Latitude == nil || p.Longitude == nil {
+		return comparables, nil
+	}
+	nearby, err := d.GetPropertiesNear(*p.Latitude, *p.Longitude, detailComparableRadius, 0, models.PropertyFilter{})
+	if err != nil {
+		return nil, err
+	}
+
+	home := listingHome(p)
+	for _, n := range nearby {
+		if listingHome(n.Property) == home {
+			continue
+		}
+		if p.PropertyType != "" && n.PropertyType != p.PropertyType {
+			continue
+		}
+		if p.LivingArea != nil {
+			area := float64(*p.LivingArea)
+			if n.LivingArea == nil || float64(*n.LivingArea) < area/bidSimilarArea || float64(*n.LivingArea) > area*bidSimilarArea {
+				continue
+			}
+		}
+		comparables = append(comparables, n)
+		if len(comparables) == detailComparables {
+			break
+		}
+	}
+	return comparables, nil
+}
+
+// listingHome returns the ID of the first listing of the home a listing is of
+func listingHome(p models.Property) int64 {
+	if p.CanonicalID != nil {
+		return *p.CanonicalID
+	}
+	return p.ID
+}
@@ -0,0 +1,252 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"fundamental/server/internal/models"
+	"strings"
+	"time"
+)
+
+// scrapedFields lists the property columns written by spider and import items
+var scrapedFields = []string{
+	"street", "neighborhood", "property_type", "city", "postal_code",
+	"price", "year_built", "living_area", "num_rooms", "status",
+	"listing_date", "selling_date", "selling_date_precision", "energy_label",
+}
+
+// provenanceFields lists the property columns whose provenance is tracked
+var provenanceFields = append(append([]string{}, scrapedFields...), "latitude", "longitude")
+
+// Item keys carrying provenance metadata alongside spider and import payloads
+const (
+	itemSourceKey     = "_source"
+	itemRunIDKey      = "_run_id"
+	itemConfidenceKey = "_confidence"
+)
+
+func isProvenanceField(field string) bool {
+	for _, f := range provenanceFields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// parseProvenance decodes the field_provenance column
+func parseProvenance(raw string) map[string]models.FieldProvenance {
+	provenance := make(map[string]models.FieldProvenance)
+	if raw == "" {
+		return provenance
+	}
+	if err := json.Unmarshal([]byte(raw), &provenance); err != nil {
+		return make(map[string]models.FieldProvenance)
+	}
+	return provenance
+}
+
+// itemProvenance extracts the source, run ID and per-field confidence scores of an item
+func itemProvenance(prop map[string]interface{}) (source, runID string, confidence map[string]float64) {
+	source = models.SourceSpider
+	if s, ok := prop[itemSourceKey].(string); ok && s != "" {
+		source = s
+	}
+	runID, _ = prop[itemRunIDKey].(string)
+
+	confidence = make(map[string]float64)
+	switch c := prop[itemConfidenceKey].(type) {
+	case map[string]interface{}:
+		for field, value := range c {
+			if score, ok := value.(float64); ok {
+				confidence[field] = score
+			}
+		}
+	case map[string]float64:
+		confidence = c
+	}
+	return source, runID, confidence
+}
+
+// mergeProvenance determines which of the given fields an item may write given
+// the existing provenance, and returns the locked fields plus the updated provenance
+func mergeProvenance(existing map[string]models.FieldProvenance, prop map[string]interface{}, fields []string) ([]string, map[string]models.FieldProvenance) {
+	source, runID, confidence := itemProvenance(prop)
+	now := time.Now().UTC()
+
+	var locked []string
+	for _, field := range fields {
+		current, tracked := existing[field]
+		if tracked && !current.CanBeOverwrittenBy(source) {
+			locked = append(locked, field)
+			continue
+		}
+		if prop[field] == nil {
+			continue
+		}
+
+		entry := models.FieldProvenance{
+			Source:    source,
+			RunID:     runID,
+			UpdatedAt: now,
+		}
+		if score, ok := confidence[field]; ok {
+			entry.Confidence = &score
+		}
+		existing[field] = entry
+	}
+	return locked, existing
+}
+
+// lockedValues loads the current values of fields that must not be overwritten
+func lockedValues(tx *sqlTx, propertyID int64, fields []string) (map[string]interface{}, error) {
+	values := make([]interface{}, len(fields))
+	scanArgs := make([]interface{}, len(fields))
+	for i := range values {
+		scanArgs[i] = &values[i]
+	}
+
+	query := fmt.Sprintf("SELECT %s FROM properties WHERE id = ?", strings.Join(fields, ", "))
+	if err := tx.QueryRow(query, propertyID).Scan(scanArgs...); err != nil {
+		return nil, fmt.Errorf("failed to load locked fields: %w", err)
+	}
+
+	result := make(map[string]interface{}, len(fields))
+	for i, field := range fields {
+		if b, ok := values[i].([]byte); ok {
+			values[i] = string(b)
+		}
+		result[field] = values[i]
+	}
+	return result, nil
+}
+
+// GetPropertyProvenance returns the provenance of every tracked field of a property
+func (d *Database) GetPropertyProvenance(propertyID int64) (map[string]models.FieldProvenance, error) {
+	var raw sql.NullString
+	err := d.db.QueryRow("SELECT field_provenance FROM properties WHERE id = ?", propertyID).Scan(&raw)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get property provenance: %v", err)
+	}
+	return parseProvenance(raw.String), nil
+}
+
+// UpdatePropertyFields writes field values from a manual or enrichment source,
+// honouring the precedence of the sources that set each field previously
+func (d *Database) UpdatePropertyFields(propertyID int64, req models.FieldUpdateRequest) (*models.FieldUpdateResult, error) {
+	source := req.Source
+	if source == "" {
+		source = models.SourceManual
+	}
+	if models.SourcePriority(source) == 0 {
+		return nil, errorf(ErrValidation, "unknown source: %s", source)
+	}
+	for field := range req.Fields {
+		if !isProvenanceField(field) {
+			return nil, errorf(ErrValidation, "field cannot be updated: %s", field)
+		}
+	}
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	var raw, countryCode sql.NullString
+	err = tx.QueryRow("SELECT field_provenance, country FROM properties WHERE id = ?", propertyID).Scan(&raw, &countryCode)
+	if err == sql.ErrNoRows {
+		return nil, errorf(ErrNotFound, "property %d not found", propertyID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get property provenance: %v", err)
+	}
+	provenance := parseProvenance(raw.String)
+
+	result := &models.FieldUpdateResult{Applied: []string{}, Skipped: []string{}}
+	now := time.Now().UTC()
+	for _, field := range provenanceFields {
+		value, ok := req.Fields[field]
+		if !ok {
+			continue
+		}
+		if current, tracked := provenance[field]; tracked && !current.CanBeOverwrittenBy(source) {
+			result.Skipped = append(result.Skipped, field)
+			continue
+		}
+
+		// Field names are validated against provenanceFields above
+		_, err := tx.Exec(fmt.Sprintf("UPDATE properties SET %s = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?", field), value, propertyID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to update %s: %v", field, err)
+		}
+		if field == "postal_code" {
+			if _, err := tx.Exec("UPDATE properties SET district = ? WHERE id = ?", propertyDistrict(countryCode.String, value), propertyID); err != nil {
+				return nil, fmt.Errorf("failed to update district: %v", err)
+			}
+		}
+		provenance[field] = models.FieldProvenance{
+			Source:     source,
+			Confidence: req.Confidence,
+			UpdatedAt:  now,
+		}
+		result.Applied = append(result.Applied, field)
+	}
+
+	encoded, err := json.Marshal(provenance)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode provenance: %v", err)
+	}
+	if _, err := tx.Exec("UPDATE properties SET field_provenance = ? WHERE id = ?", string(encoded), propertyID); err != nil {
+		return nil, fmt.Errorf("failed to update provenance: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return result, nil
+}
+
+// ResetFieldProvenance clears the provenance of a field so any source may write it again
+func (d *Database) ResetFieldProvenance(propertyID int64, field string) error {
+	if !isProvenanceField(field) {
+		return errorf(ErrValidation, "unknown field: %s", field)
+	}
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	var raw sql.NullString
+	err = tx.QueryRow("SELECT field_provenance FROM properties WHERE id = ?", propertyID).Scan(&raw)
+	if err == sql.ErrNoRows {
+		return errorf(ErrNotFound, "property %d not found", propertyID)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get property provenance: %v", err)
+	}
+	if !raw.Valid {
+		return nil
+	}
+
+	provenance := parseProvenance(raw.String)
+	delete(provenance, field)
+	encoded, err := json.Marshal(provenance)
+	if err != nil {
+		return fmt.Errorf("failed to encode provenance: %v", err)
+	}
+	if _, err := tx.Exec("UPDATE properties SET field_provenance = ? WHERE id = ?", string(encoded), propertyID); err != nil {
+		return fmt.Errorf("failed to reset field provenance: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return nil
+}
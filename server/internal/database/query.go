@@ -0,0 +1,305 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"fundamental/server/internal/models"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// ErrInvalidQuery is returned for SQL that read-only queries refuse to run or
+// that fails to run
+var ErrInvalidQuery = newError(ErrValidation, "invalid query")
+
+// sqliteRecursive is the authorizer action of a recursive common table
+// expression, which the driver does not define
+const sqliteRecursive = 33
+
+// queryableTables are the tables and views read-only queries may read. Tokens,
+// settings, sessions and internal bookkeeping are left out.
+var queryableTables = map[string]bool{
+	"v_active_listings":        true,
+	"v_sold_12m":               true,
+	"v_district_stats":         true,
+	"properties":               true,
+	"properties_archive":       true,
+	"property_history":         true,
+	"property_history_archive": true,
+	"price_changes":            true,
+	"property_images":          true,
+	"property_tags":            true,
+	"property_scores":          true,
+	"tags":                     true,
+	"agents":                   true,
+	"metropolitan_areas":       true,
+	"metropolitan_cities":      true,
+}
+
+// QueryableTables returns the names of the tables and views read-only queries
+// may read
+func QueryableTables() []string {
+	names := make([]string, 0, len(queryableTables))
+	for name := range queryableTables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// singleSelect checks that query is one SELECT, WITH or VALUES statement and
+// returns it without a trailing semicolon. String literals, quoted identifiers
+// and comments are skipped when looking for a second statement; anything it
+// cannot tell apart, such as PostgreSQL dollar quoting, is refused.
+func singleSelect(query string) (string, error) {
+	query = strings.TrimSpace(query)
+	query = strings.TrimSpace(strings.TrimSuffix(query, ";"))
+	if query == "" {
+		return "", fmt.Errorf("%w: the query is empty", ErrInvalidQuery)
+	}
+
+	var code strings.Builder // the query with literals and comments removed
+	for i := 0; i < len(query); i++ {
+		switch {
+		case query[i] == '\'' || query[i] == '"':
+			quote := query[i]
+			j := i + 1
+			for ; j < len(query); j++ {
+				if query[j] != quote {
+					continue
+				}
+				// A doubled quote stands for itself
+				if j+1 < len(query) && query[j+1] == quote {
+					j++
+					continue
+				}
+				break
+			}
+			if j >= len(query) {
+				return "", fmt.Errorf("%w: unterminated quote", ErrInvalidQuery)
+			}
+			i = j
+			code.WriteByte(' ')
+		case strings.HasPrefix(query[i:], "--"):
+			end := strings.IndexByte(query[i:], '\n')
+			if end < 0 {
+				end = len(query) - i
+			}
+			i += end
+			code.WriteByte(' ')
+		case strings.HasPrefix(query[i:], "/*"):
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				return "", fmt.Errorf("%w: unterminated comment", ErrInvalidQuery)
+			}
+			i += end + 3
+			code.WriteByte(' ')
+		case query[i] == ';':
+			return "", fmt.Errorf("%w: only one statement can be run", ErrInvalidQuery)
+		default:
+			code.WriteByte(query[i])
+		}
+	}
+
+	fields := strings.Fields(code.String())
+	if len(fields) == 0 {
+		return "", fmt.Errorf("%w: the query is empty", ErrInvalidQuery)
+	}
+	switch strings.ToUpper(fields[0]) {
+	case "SELECT", "WITH", "VALUES":
+		return query, nil
+	}
+	return "", fmt.Errorf("%w: only SELECT statements can be run", ErrInvalidQuery)
+}
+
+// ReadOnlyQuery runs a SELECT statement written by a user and returns up to
+// maxRows rows. It may only read queryableTables: on SQLite an authorizer
+// refuses everything else while the statement is prepared, on PostgreSQL the
+// tables of its plan are checked and it runs in a read-only transaction. The
+// query is cancelled after timeout.
+func (d *Database) ReadOnlyQuery(ctx context.Context, query string, maxRows int, timeout time.Duration) (*models.QueryResult, error) {
+	query, err := singleSelect(query)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	var result *models.QueryResult
+	if d.dialect.Name() == DriverPostgres {
+		result, err = d.postgresReadOnlyQuery(ctx, query, maxRows, timeout)
+	} else {
+		result, err = d.sqliteReadOnlyQuery(ctx, query, maxRows)
+	}
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, fmt.Errorf("%w: the query did not finish within %s", ErrInvalidQuery, timeout)
+		}
+		return nil, err
+	}
+	result.ElapsedMs = time.Since(start).Milliseconds()
+	return result, nil
+}
+
+func (d *Database) sqliteReadOnlyQuery(ctx context.Context, query string, maxRows int) (*models.QueryResult, error) {
+	conn, err := d.db.DB.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get connection: %v", err)
+	}
+	defer conn.Close()
+
+	// Common table expressions are read like tables; a name that is not in the
+	// schema can only be one of those
+	schema := map[string]bool{}
+	names, err := conn.QueryContext(ctx, "SELECT LOWER(name) FROM sqlite_master")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema: %v", err)
+	}
+	for names.Next() {
+		var name string
+		if err := names.Scan(&name); err != nil {
+			names.Close()
+			return nil, fmt.Errorf("failed to read schema: %v", err)
+		}
+		schema[name] = true
+	}
+	names.Close()
+
+	var denied string
+	authorize := func(op int, arg1, arg2, _ string) int {
+		switch op {
+		case sqlite3.SQLITE_SELECT, sqlite3.SQLITE_FUNCTION, sqliteRecursive:
+			return sqlite3.SQLITE_OK
+		case sqlite3.SQLITE_READ:
+			table := strings.ToLower(arg1)
+			if queryableTables[table] || (!schema[table] && !strings.HasPrefix(table, "sqlite_")) {
+				return sqlite3.SQLITE_OK
+			}
+			if denied == "" {
+				denied = "table " + arg1 + " cannot be queried"
+			}
+		default:
+			if denied == "" {
+				denied = "only SELECT statements can be run"
+			}
+		}
+		return sqlite3.SQLITE_DENY
+	}
+	if err := conn.Raw(func(raw interface{}) error {
+		raw.(*sqlite3.SQLiteConn).RegisterAuthorizer(authorize)
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("failed to restrict connection: %v", err)
+	}
+	// The connection goes back to the pool without the authorizer
+	defer conn.Raw(func(raw interface{}) error {
+		raw.(*sqlite3.SQLiteConn).RegisterAuthorizer(nil)
+		return nil
+	})
+
+	rows, err := conn.QueryContext(ctx, query)
+	if err != nil {
+		if denied != "" {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidQuery, denied)
+		}
+		return nil, fmt.Errorf("%w: %v", ErrInvalidQuery, err)
+	}
+	defer rows.Close()
+	return readQueryResult(rows, maxRows)
+}
+
+func (d *Database) postgresReadOnlyQuery(ctx context.Context, query string, maxRows int, timeout time.Duration) (*models.QueryResult, error) {
+	tx, err := d.db.DB.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf("SET LOCAL statement_timeout = %d", timeout.Milliseconds())); err != nil {
+		return nil, fmt.Errorf("failed to set statement timeout: %v", err)
+	}
+
+	var plan []byte
+	if err := tx.QueryRowContext(ctx, "EXPLAIN (FORMAT JSON) "+query).Scan(&plan); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidQuery, err)
+	}
+	var nodes interface{}
+	if err := json.Unmarshal(plan, &nodes); err != nil {
+		return nil, fmt.Errorf("failed to decode query plan: %v", err)
+	}
+	if table := deniedRelation(nodes); table != "" {
+		return nil, fmt.Errorf("%w: table %s cannot be queried", ErrInvalidQuery, table)
+	}
+
+	rows, err := tx.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidQuery, err)
+	}
+	defer rows.Close()
+	return readQueryResult(rows, maxRows)
+}
+
+// deniedRelation returns the first table of a PostgreSQL JSON plan that is not
+// queryable, or an empty string
+func deniedRelation(node interface{}) string {
+	switch v := node.(type) {
+	case []interface{}:
+		for _, child := range v {
+			if table := deniedRelation(child); table != "" {
+				return table
+			}
+		}
+	case map[string]interface{}:
+		if name, ok := v["Relation Name"].(string); ok && !queryableTables[name] {
+			return name
+		}
+		for _, child := range v {
+			if table := deniedRelation(child); table != "" {
+				return table
+			}
+		}
+	}
+	return ""
+}
+
+// readQueryResult reads up to maxRows rows of a query
+func readQueryResult(rows *sql.Rows, maxRows int) (*models.QueryResult, error) {
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read columns: %v", err)
+	}
+	result := &models.QueryResult{Columns: columns, Rows: [][]interface{}{}}
+	for rows.Next() {
+		if len(result.Rows) == maxRows {
+			result.Truncated = true
+			break
+		}
+		values := make([]interface{}, len(columns))
+		pointers := make([]interface{}, len(columns))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %v", err)
+		}
+		for i, value := range values {
+			// Text arrives as bytes from PostgreSQL and for untyped SQLite expressions
+			if b, ok := value.([]byte); ok {
+				values[i] = string(b)
+			}
+		}
+		result.Rows = append(result.Rows, values)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidQuery, err)
+	}
+	result.RowCount = len(result.Rows)
+	return result, nil
+}
@@ -0,0 +1,149 @@
+package database
+
+import (
+	"fmt"
+	"fundamental/server/config"
+	"fundamental/server/internal/models"
+	"time"
+)
+
+// QuotasSetting is the setting holding the quotas of the dataset
+const QuotasSetting = "quotas"
+
+// The resources whose use is counted per day. Uses refused for a quota are
+// counted as well.
+const (
+	UsageNotifications        = "notifications"
+	UsageNotificationsRefused = "notifications_refused"
+	UsageExports              = "exports"
+	UsageExportRows           = "export_rows"
+	UsageExportsRefused       = "exports_refused"
+)
+
+// usageReportDays is the period of the longer usage totals
+const usageReportDays = 30
+
+// usageDay returns the UTC day uses at t are counted on
+func usageDay(t time.Time) string {
+	return t.UTC().Format("2006-01-02")
+}
+
+// GetQuotas returns the quotas of the dataset, unlimited when none are set
+func (d *Database) GetQuotas() (models.WorkspaceQuotas, error) {
+	var quotas models.WorkspaceQuotas
+	if _, err := d.GetSetting(QuotasSetting, &quotas); err != nil {
+		return models.WorkspaceQuotas{}, err
+	}
+	return quotas, nil
+}
+
+// SaveQuotas replaces the quotas of the dataset. Negative quotas are
+// rejected.
+func (d *Database) SaveQuotas(quotas models.WorkspaceQuotas) error {
+	for name, quota := range map[string]*int{
+		"max_cities":                quotas.MaxCities,
+		"max_notifications_per_day": quotas.MaxNotificationsPerDay,
+		"max_export_rows":           quotas.MaxExportRows,
+	} {
+		if quota != nil && *quota < 0 {
+			return errorf(ErrValidation, "%s must not be negative", name)
+		}
+	}
+	return d.SaveSetting(QuotasSetting, quotas)
+}
+
+// RecordUsage adds n uses of a resource to today's count
+func (d *Database) RecordUsage(resource string, n int) error {
+	_, err := d.db.Exec(`
+		INSERT INTO quota_usage (day, resource, used) VALUES (?, ?, ?)
+		ON CONFLICT (day, resource) DO UPDATE SET used = quota_usage.used + excluded.used
+	`, usageDay(time.Now()), resource, n)
+	if err != nil {
+		return fmt.Errorf("failed to record usage of %s: %v", resource, err)
+	}
+	return nil
+}
+
+// UseNotificationQuota counts a notification and reports whether it may be
+// sent, which it may not once today's MaxNotificationsPerDay are used. The
+// check and the count are one statement, so concurrent senders cannot both
+// take the last notification.
+func (d *Database) UseNotificationQuota() (bool, error) {
+	quotas, err := d.GetQuotas()
+	if err != nil {
+		return false, err
+	}
+	if quotas.MaxNotificationsPerDay == nil {
+		return true, d.RecordUsage(UsageNotifications, 1)
+	}
+
+	allowed := false
+	if *quotas.MaxNotificationsPerDay > 0 {
+		result, err := d.db.Exec(`
+			INSERT INTO quota_usage (day, resource, used) VALUES (?, ?, 1)
+			ON CONFLICT (day, resource) DO UPDATE SET used = quota_usage.used + 1
+			WHERE quota_usage.used < ?
+		`, usageDay(time.Now()), UsageNotifications, *quotas.MaxNotificationsPerDay)
+		if err != nil {
+			return false, fmt.Errorf("failed to use notification quota: %v", err)
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return false, fmt.Errorf("failed to get rows affected: %v", err)
+		}
+		allowed = n > 0
+	}
+	if !allowed {
+		return false, d.RecordUsage(UsageNotificationsRefused, 1)
+	}
+	return true, nil
+}
+
+// GetQuotaUsage reports the quotas of the dataset and what it used of them:
+// the cities scraped and skipped, the number of properties and the counted
+// resources of today and the last 30 days
+func (d *Database) GetQuotaUsage() (*models.QuotaUsage, error) {
+	quotas, err := d.GetQuotas()
+	if err != nil {
+		return nil, err
+	}
+	cities, err := config.GetCityNames(d)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cities: %v", err)
+	}
+
+	now := time.Now()
+	usage := &models.QuotaUsage{
+		Quotas:     quotas,
+		Day:        usageDay(now),
+		Today:      map[string]int{},
+		Last30Days: map[string]int{},
+	}
+	usage.Cities, usage.SkippedCities = quotas.ScrapedCities(cities)
+	if err := d.db.QueryRow("SELECT COUNT(*) FROM properties").Scan(&usage.Properties); err != nil {
+		return nil, fmt.Errorf("failed to count properties: %v", err)
+	}
+
+	rows, err := d.db.Query(`
+		SELECT day, resource, used FROM quota_usage WHERE day >= ?
+	`, usageDay(now.AddDate(0, 0, 1-usageReportDays)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query usage: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var day, resource string
+		var used int
+		if err := rows.Scan(&day, &resource, &used); err != nil {
+			return nil, fmt.Errorf("failed to scan usage: %v", err)
+		}
+		usage.Last30Days[resource] += used
+		if day == usage.Day {
+			usage.Today[resource] += used
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating usage: %v", err)
+	}
+	return usage, nil
+}
@@ -0,0 +1,148 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"fundamental/server/internal/models"
+	"strings"
+)
+
+// A home that Funda relists under a new URL is recognised by its address and
+// living area. The listings of one home form a chain: every later listing has
+// canonical_id set to the id of the first one.
+
+// relisting is the latest earlier listing of a home that is listed again
+type relisting struct {
+	previousID  int64
+	canonicalID int64
+	status      string
+	price       sql.NullInt64
+}
+
+// homeKey identifies a home by postal code, street and living area. It is
+// empty when one of them is missing.
+func homeKey(postalCode, street string, livingArea int64) string {
+	if postalCode == "" || street == "" || livingArea <= 0 {
+		return ""
+	}
+	return fmt.Sprintf("%s|%s|%d", postalCode, strings.ToLower(street), livingArea)
+}
+
+// itemHomeKey returns the home key of a scraped item
+func itemHomeKey(prop map[string]interface{}) string {
+	street, _ := prop["street"].(string)
+	postalCode, _ := prop["postal_code"].(string)
+	livingArea, _ := prop["living_area"].(float64)
+	return homeKey(postalCode, street, int64(livingArea))
+}
+
+// findRelistings returns, by URL, the latest listing of the same home under
+// another URL for the scraped items that are not the first listing of their
+// home. The candidates of all items are read with one query by postal code.
+func findRelistings(tx *sqlTx, items []map[string]interface{}) (map[string]*relisting, error) {
+	found := make(map[string]*relisting)
+	homes := make(map[string]bool)
+	var postalCodes []interface{}
+	seen := make(map[string]bool)
+	for _, prop := range items {
+		key := itemHomeKey(prop)
+		if key == "" {
+			continue
+		}
+		homes[key] = true
+		if postalCode := prop["postal_code"].(string); !seen[postalCode] {
+			seen[postalCode] = true
+			postalCodes = append(postalCodes, postalCode)
+		}
+	}
+	if len(postalCodes) == 0 {
+		return found, nil
+	}
+
+	rows, err := tx.Query(`
+		SELECT id, url, COALESCE(canonical_id, id), COALESCE(status, ''), price,
+		       postal_code, street, living_area
+		FROM properties
+		WHERE postal_code IN (?`+strings.Repeat(", ?", len(postalCodes)-1)+`)
+		AND street IS NOT NULL AND living_area IS NOT NULL
+		ORDER BY id
+	`, postalCodes...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up earlier listings: %v", err)
+	}
+	defer rows.Close()
+
+	type listing struct {
+		url string
+		relisting
+	}
+	listings := make(map[string][]listing)
+	for rows.Next() {
+		var l listing
+		var postalCode, street string
+		var livingArea int64
+		if err := rows.Scan(&l.previousID, &l.url, &l.canonicalID, &l.status, &l.price,
+			&postalCode, &street, &livingArea); err != nil {
+			return nil, fmt.Errorf("failed to scan earlier listing: %v", err)
+		}
+		if key := homeKey(postalCode, street, livingArea); homes[key] {
+			listings[key] = append(listings[key], l)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating earlier listings: %v", err)
+	}
+
+	for _, prop := range items {
+		url := prop["url"].(string)
+		candidates := listings[itemHomeKey(prop)]
+		for i := len(candidates) - 1; i >= 0; i-- {
+			if candidates[i].url != url {
+				r := candidates[i].relisting
+				found[url] = &r
+				break
+			}
+		}
+	}
+	return found, nil
+}
+
+// backfillCanonicalIDs links the existing listings of the same home to the
+// first one
+func backfillCanonicalIDs(tx *sqlTx) error {
+	_, err := tx.Exec(`
+		UPDATE properties
+		SET canonical_id = (
+			SELECT MIN(first.id) FROM properties first
+			WHERE first.postal_code = properties.postal_code
+			AND LOWER(first.street) = LOWER(properties.street)
+			AND first.living_area = properties.living_area
+			AND first.id < properties.id
+		)
+		WHERE street IS NOT NULL AND postal_code IS NOT NULL AND living_area IS NOT NULL
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to link relisted properties: %v", err)
+	}
+	return nil
+}
+
+// GetPropertyListings returns every listing of the home a property belongs to,
+// first listing first. A property that was never relisted returns itself only.
+func (d *Database) GetPropertyListings(propertyID int64) ([]models.Property, error) {
+	listings := []models.Property{}
+	err := d.forEachProperty(`
+		SELECT `+propertyColumns+`
+		FROM properties
+		WHERE id = (SELECT COALESCE(canonical_id, id) FROM properties WHERE id = ?)
+		OR canonical_id = (SELECT COALESCE(canonical_id, id) FROM properties WHERE id = ?)
+		ORDER BY id
+	`, []interface{}{propertyID, propertyID}, func(p models.Property) error {
+		listings = append(listings, p)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get property listings: %v", err)
+	}
+	return listings, nil
+}
@@ -0,0 +1,83 @@
+package database
+
+import (
+	"fmt"
+	"fundamental/server/internal/models"
+	"sort"
+	"time"
+)
+
+// retentionTarget is a table retention rules can prune: rows whose timestamp
+// is older than the maximum age and that match the condition are deleted
+type retentionTarget struct {
+	table     string
+	timestamp string
+	condition string
+}
+
+// retentionTargets are the datasets retention rules apply to, by name.
+// Property snapshots have their own limits, see PruneSnapshots.
+var retentionTargets = map[string]retentionTarget{
+	"property_history":         {"property_history", "created_at", ""},
+	"property_history_archive": {"property_history_archive", "created_at", ""},
+	"price_changes":            {"price_changes", "changed_at", ""},
+	"audit_log":                {"audit_log", "changed_at", ""},
+	"rejected_items":           {"rejected_items", "created_at", ""},
+	"watch_alerts":             {"watch_alerts", "created_at", ""},
+	"tasks":                    {"tasks", "finished_at", "finished_at IS NOT NULL"},
+	"shared_views":             {"shared_views", "COALESCE(last_accessed_at, created_at)", ""},
+	"spider_runs":              {"spider_runs", "started_at", ""},
+}
+
+// RetentionDatasets returns the names of the datasets retention rules apply
+// to, sorted
+func RetentionDatasets() []string {
+	names := make([]string, 0, len(retentionTargets))
+	for name := range retentionTargets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ApplyRetention deletes the rows of each dataset older than its maximum age
+// in days, or with dryRun only counts them. Rules of zero days or less keep
+// everything and are left out of the report.
+func (d *Database) ApplyRetention(rules map[string]int, dryRun bool) (*models.RetentionReport, error) {
+	report := &models.RetentionReport{DryRun: dryRun, RanAt: time.Now().UTC(), Rules: []models.RetentionResult{}}
+	for _, name := range RetentionDatasets() {
+		days := rules[name]
+		if days <= 0 {
+			continue
+		}
+		target := retentionTargets[name]
+		condition := target.timestamp + " < " + d.dialect.TimestampOffset()
+		if target.condition != "" {
+			condition += " AND " + target.condition
+		}
+		modifier := fmt.Sprintf("-%d days", days)
+
+		result := models.RetentionResult{
+			Dataset:    name,
+			MaxAgeDays: days,
+			Cutoff:     report.RanAt.AddDate(0, 0, -days),
+		}
+		if dryRun {
+			err := d.db.QueryRow("SELECT COUNT(*) FROM "+target.table+" WHERE "+condition, modifier).Scan(&result.Rows)
+			if err != nil {
+				return nil, fmt.Errorf("failed to count expired %s: %v", name, err)
+			}
+		} else {
+			deleted, err := d.db.Exec("DELETE FROM "+target.table+" WHERE "+condition, modifier)
+			if err != nil {
+				return nil, fmt.Errorf("failed to delete expired %s: %v", name, err)
+			}
+			if result.Rows, err = deleted.RowsAffected(); err != nil {
+				return nil, fmt.Errorf("failed to get rows affected: %v", err)
+			}
+		}
+		report.Deleted += result.Rows
+		report.Rules = append(report.Rules, result)
+	}
+	return report, nil
+}
@@ -0,0 +1,258 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"fundamental/server/internal/models"
+	"math"
+	"sort"
+	"time"
+)
+
+func scanSavedSearch(row rowScanner) (*models.SavedSearch, error) {
+	var search models.SavedSearch
+	var criteria string
+	if err := row.Scan(&search.ID, &search.Name, &criteria, &search.CreatedAt, &search.UpdatedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(criteria), &search.Criteria); err != nil {
+		return nil, fmt.Errorf("failed to decode criteria of saved search %d: %v", search.ID, err)
+	}
+	return &search, nil
+}
+
+// ListSavedSearches returns all saved searches, newest first
+func (d *Database) ListSavedSearches() ([]models.SavedSearch, error) {
+	rows, err := d.db.Query("SELECT id, name, criteria, created_at, updated_at FROM saved_searches ORDER BY id DESC")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query saved searches: %v", err)
+	}
+	defer rows.Close()
+
+	searches := []models.SavedSearch{}
+	for rows.Next() {
+		search, err := scanSavedSearch(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan saved search: %v", err)
+		}
+		searches = append(searches, *search)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating saved searches: %v", err)
+	}
+	return searches, nil
+}
+
+// GetSavedSearch returns a saved search, or nil if it does not exist
+func (d *Database) GetSavedSearch(id int64) (*models.SavedSearch, error) {
+	row := d.db.QueryRow("SELECT id, name, criteria, created_at, updated_at FROM saved_searches WHERE id = ?", id)
+	search, err := scanSavedSearch(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get saved search: %v", err)
+	}
+	return search, nil
+}
+
+// CreateSavedSearch stores a new saved search
+func (d *Database) CreateSavedSearch(req models.SavedSearchRequest) (*models.SavedSearch, error) {
+	criteria, err := json.Marshal(req.Criteria)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode search criteria: %v", err)
+	}
+	var id int64
+	err = d.db.QueryRow(`
+		INSERT INTO saved_searches (name, criteria)
+		VALUES (?, ?)
+		RETURNING id
+	`, req.Name, string(criteria)).Scan(&id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert saved search: %v", err)
+	}
+	return d.GetSavedSearch(id)
+}
+
+// UpdateSavedSearch replaces the name and criteria of a saved search. Returns
+// nil if it does not exist.
+func (d *Database) UpdateSavedSearch(id int64, req models.SavedSearchRequest) (*models.SavedSearch, error) {
+	criteria, err := json.Marshal(req.Criteria)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode search criteria: %v", err)
+	}
+	result, err := d.db.Exec(`
+		UPDATE saved_searches SET name = ?, criteria = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?
+	`, req.Name, string(criteria), id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update saved search: %v", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rows affected: %v", err)
+	}
+	if n == 0 {
+		return nil, nil
+	}
+	return d.GetSavedSearch(id)
+}
+
+// DeleteSavedSearch removes a saved search. Returns false if it does not exist.
+func (d *Database) DeleteSavedSearch(id int64) (bool, error) {
+	result, err := d.db.Exec("DELETE FROM saved_searches WHERE id = ?", id)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete saved search: %v", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %v", err)
+	}
+	return n > 0, nil
+}
+
+// BacktestSearch replays search criteria over the listings that appeared in the
+// given number of weeks up to today, the first week starting on a Monday. A
+// listing appeared on its listing date, or when it was first scraped if it has
+// none. Listings are matched with the same rules as notifications, so the
+// result shows how many alerts the criteria would have sent; relative criteria
+// compare them with the current statistics of their district.
+func (d *Database) BacktestSearch(criteria models.SearchCriteria, weeks int) (*models.SearchBacktest, error) {
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	currentWeek := today.AddDate(0, 0, -(int(today.Weekday())+6)%7)
+	from := currentWeek.AddDate(0, 0, -7*(weeks-1))
+
+	// Tags are far fewer than listings, so they are read up front for rules
+	tags, err := d.propertyTagNames(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := d.db.Query(`
+		SELECT id, appeared, price, living_area, num_rooms, postal_code, energy_label, city, status, days_to_sell,
+		       district, neighborhood, property_type, year_built, country
+		FROM (
+			SELECT id, SUBSTR(COALESCE(listing_date, CAST(scraped_at AS TEXT)), 1, 10) AS appeared,
+			       price, living_area, num_rooms, COALESCE(postal_code, '') AS postal_code,
+			       COALESCE(energy_label, '') AS energy_label, COALESCE(city, '') AS city,
+			       COALESCE(status, '') AS status, COALESCE(district, '') AS district,
+			       COALESCE(neighborhood, '') AS neighborhood, COALESCE(property_type, '') AS property_type,
+			       year_built, country,
+			       CASE WHEN status = 'sold' AND listing_date IS NOT NULL AND selling_date IS NOT NULL
+			                 AND `+exactSellingDate("")+`
+			            THEN `+d.dialect.DaysBetween("listing_date", "selling_date")+` END AS days_to_sell
+			FROM properties
+			WHERE price IS NOT NULL
+			AND `+cityFilter(criteria.City)+`
+		) listings
+		WHERE appeared >= ? AND appeared <= ?
+	`, criteria.City, criteria.City, from.Format("2006-01-02"), today.Format("2006-01-02"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query listings for backtest: %v", err)
+	}
+	defer rows.Close()
+
+	result := &models.SearchBacktest{
+		Criteria: criteria,
+		From:     from.Format("2006-01-02"),
+		To:       today.Format("2006-01-02"),
+		Weeks:    make([]models.BacktestWeek, weeks),
+	}
+	for i := range result.Weeks {
+		result.Weeks[i].WeekStart = from.AddDate(0, 0, 7*i).Format("2006-01-02")
+	}
+
+	var market models.MarketReference
+	var districts *districtMarket
+	if criteria.RelativeCriteria.IsSet() || criteria.Rules != nil {
+		districts = d.newDistrictMarket()
+		market = districts
+	}
+
+	var prices, pricesPerSqm, daysToSell []float64
+	for rows.Next() {
+		var appeared string
+		var price int
+		var livingArea, numRooms, yearBuilt sql.NullInt64
+		var days sql.NullFloat64
+		p := models.Property{}
+		if err := rows.Scan(&p.ID, &appeared, &price, &livingArea, &numRooms, &p.PostalCode, &p.EnergyLabel,
+			&p.City, &p.Status, &days, &p.District, &p.Neighborhood, &p.PropertyType, &yearBuilt, &p.Country); err != nil {
+			return nil, fmt.Errorf("failed to scan listing for backtest: %v", err)
+		}
+		p.Price = price
+		if livingArea.Valid {
+			la := int(livingArea.Int64)
+			p.LivingArea = &la
+		}
+		if numRooms.Valid {
+			nr := int(numRooms.Int64)
+			p.NumRooms = &nr
+		}
+		if yearBuilt.Valid {
+			yb := int(yearBuilt.Int64)
+			p.YearBuilt = &yb
+		}
+		p.Tags = tags[p.ID]
+		if !criteria.Matches(&p, market) {
+			continue
+		}
+		day, err := time.Parse("2006-01-02", appeared)
+		if err != nil {
+			continue
+		}
+
+		result.Weeks[int(day.Sub(from).Hours()/24)/7].Listings++
+		result.Total++
+		prices = append(prices, float64(price))
+		if p.LivingArea != nil && *p.LivingArea > 0 {
+			pricesPerSqm = append(pricesPerSqm, float64(price)/float64(*p.LivingArea))
+		}
+		if days.Valid && days.Float64 >= 0 {
+			result.Sold++
+			daysToSell = append(daysToSell, days.Float64)
+			if days.Float64 <= 30 {
+				result.SoldWithin30Days++
+			}
+			if days.Float64 <= 90 {
+				result.SoldWithin90Days++
+			}
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating listings for backtest: %v", err)
+	}
+	if districts != nil && districts.err != nil {
+		return nil, fmt.Errorf("failed to load district statistics for backtest: %v", districts.err)
+	}
+
+	result.AveragePerWeek = math.Round(float64(result.Total)/float64(weeks)*10) / 10
+	if len(prices) > 0 {
+		sort.Float64s(prices)
+		result.Price = models.PriceDistribution{
+			Min:    prices[0],
+			P25:    math.Round(percentile(prices, 0.25)),
+			Median: math.Round(percentile(prices, 0.5)),
+			P75:    math.Round(percentile(prices, 0.75)),
+			Max:    prices[len(prices)-1],
+		}
+	}
+	if len(pricesPerSqm) > 0 {
+		sort.Float64s(pricesPerSqm)
+		result.MedianPricePerSqm = math.Round(percentile(pricesPerSqm, 0.5))
+	}
+	if len(daysToSell) > 0 {
+		sort.Float64s(daysToSell)
+		result.MedianDaysToSell = math.Round(percentile(daysToSell, 0.5))
+	}
+	return result, nil
+}
+
+// percentile interpolates the p-th fraction of sorted values
+func percentile(sorted []float64, p float64) float64 {
+	position := p * float64(len(sorted)-1)
+	lower := int(math.Floor(position))
+	upper := int(math.Ceil(position))
+	return sorted[lower] + (sorted[upper]-sorted[lower])*(position-float64(lower))
+}
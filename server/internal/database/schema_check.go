@@ -0,0 +1,198 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"slices"
+	"sort"
+)
+
+// expectedTables lists the columns every table must have after migrations
+var expectedTables = map[string][]string{
+	"properties":               propertyTableColumns,
+	"properties_archive":       slices.Concat(propertyTableColumns, []string{"archived_at"}),
+	"property_history":         propertyHistoryColumns,
+	"property_history_archive": propertyHistoryColumns,
+	"metropolitan_areas":       {"id", "name", "center_lat", "center_lng", "zoom_level", "created_at"},
+	"metropolitan_cities":      {"metropolitan_area_id", "city", "lat", "lng", "created_at"},
+	"telegram_config":          {"id", "bot_token", "chat_id", "is_enabled", "locale", "currency", "created_at", "updated_at"},
+	"telegram_filters": {
+		"min_price", "max_price", "min_living_area", "max_living_area",
+		"min_rooms", "max_rooms", "districts", "energy_labels", "rules",
+	},
+	"property_snapshots": {"id", "property_id", "payload", "created_at"},
+	"user_preferences":   {"owner", "namespace", "key", "value", "updated_at"},
+	"shared_views": {
+		"token", "definition", "definition_hash", "access_count", "created_at", "last_accessed_at",
+	},
+	"api_tokens": {
+		"id", "name", "token_hash", "token_prefix", "scopes",
+		"expires_at", "last_used_at", "revoked_at", "created_at", "workspace",
+	},
+	"property_sync":          {"property_id", "seq", "city", "previous_city", "deleted", "changed_at"},
+	"saved_searches":         {"id", "name", "criteria", "created_at", "updated_at"},
+	"rejected_items":         {"id", "url", "source", "run_id", "reasons", "payload", "rules", "created_at"},
+	"validation_rules":       {"id", "field", "kind", "value", "message", "enabled", "created_at", "updated_at"},
+	"property_images":        {"id", "property_id", "position", "url", "thumbnail_path", "created_at"},
+	"watchlists":             {"id", "name", "radius_meters", "created_at", "updated_at"},
+	"watchlist_properties":   {"watchlist_id", "property_id", "created_at"},
+	"watch_alerts":           {"id", "watchlist_id", "property_id", "comparable_id", "distance_meters", "created_at"},
+	"agents":                 {"id", "name", "url", "created_at", "updated_at"},
+	"district_subscriptions": {"district", "created_at"},
+	"tasks": {
+		"id", "kind", "status", "params", "progress", "message", "result", "error",
+		"created_at", "started_at", "finished_at",
+	},
+	"spider_runs": {
+		"run_id", "spider_type", "place", "started_at", "finished_at", "pages", "listings",
+		"items", "stored", "new_items", "rejected", "result_count", "coverage", "error",
+	},
+	"table_modifications": {
+		"table_name", "version", "modified_at",
+	},
+	"district_boundary_versions": {
+		"id", "source", "valid_from", "valid_to", "districts", "checksum", "features", "parameters",
+	},
+	"settings": {
+		"name", "value", "updated_at",
+	},
+	"pdok_cache": {
+		"district", "city", "points", "fetched_at",
+	},
+	"workspaces": {
+		"slug", "name", "created_at",
+	},
+	"quota_usage": {
+		"day", "resource", "used",
+	},
+	"audit_log":         {"id", "property_id", "field", "old_value", "new_value", "source", "run_id", "changed_at"},
+	"price_changes":     {"id", "property_id", "old_price", "new_price", "change_pct", "changed_at"},
+	"tags":              {"id", "name", "color", "created_at"},
+	"property_tags":     {"tag_id", "property_id", "created_at"},
+	"cdc_sink":          {"id", "enabled", "kind", "url", "target", "sent_seq", "last_sent_at", "last_error", "updated_at"},
+	"geocoding_lease":   {"id", "owner", "acquired_at", "renewed_at", "expires_at", "done", "total"},
+	"property_scores":   {"property_id", "name", "score", "scored_at"},
+	"schema_migrations": {"version", "name", "applied_at"},
+}
+
+// propertyTableColumns are the columns of properties and properties_archive
+var propertyTableColumns = []string{
+	"id", "url", "street", "neighborhood", "property_type", "city", "postal_code",
+	"price", "year_built", "living_area", "num_rooms", "status", "listing_date",
+	"selling_date", "scraped_at", "created_at", "updated_at", "energy_label",
+	"republish_count", "latitude", "longitude", "geocoding_attempted", "field_provenance",
+	"district", "outlier_flags", "street_image_url", "street_image_link", "street_image_checked_at",
+	"canonical_id", "agent_id", "delisting_reason", "delisted_at", "country", "currency",
+	"selling_date_precision",
+}
+
+// propertyHistoryColumns are the columns of property_history and property_history_archive
+var propertyHistoryColumns = []string{
+	"id", "property_id", "status", "price", "listing_date", "created_at",
+	"change_type", "previous_status", "previous_price",
+}
+
+// expectedIndexes lists the indexes the queries rely on
+var expectedIndexes = []string{
+	"idx_properties_coordinates",
+	"idx_property_snapshots_property",
+	"idx_property_history_property",
+	"idx_properties_city_status_selling",
+	"idx_properties_city_status_listing",
+	"idx_properties_district_status",
+	"idx_property_sync_seq",
+	"idx_properties_archive_url",
+	"idx_property_history_archive_property",
+	"idx_properties_address",
+	"idx_properties_canonical",
+	"idx_properties_agent",
+	"idx_tasks_status",
+	"idx_audit_log_property",
+	"idx_audit_log_run",
+	"idx_price_changes_property",
+	"idx_price_changes_changed",
+	"idx_properties_delisting",
+	"idx_property_images_property_url",
+	"idx_watch_alerts_match",
+	"idx_tags_name",
+	"idx_property_tags_property",
+	"idx_property_scores_name",
+	"idx_spider_runs_place",
+	"idx_district_boundary_versions_valid",
+}
+
+// expectedUniqueColumns lists the columns upserts rely on being unique, as
+// table and column
+var expectedUniqueColumns = [][2]string{
+	{"properties", "url"},
+	{"agents", "name"},
+}
+
+// ValidateSchema compares the live schema with the tables, views, columns,
+// indexes and migration version the current code expects. It returns one
+// message per mismatch.
+func (d *Database) ValidateSchema() ([]string, error) {
+	var problems []string
+
+	tables := make([]string, 0, len(expectedTables))
+	for table := range expectedTables {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+
+	for _, table := range tables {
+		present, err := tableColumns(d.db, table)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("missing table %s", table))
+			continue
+		}
+		for _, column := range expectedTables[table] {
+			if !present[column] {
+				problems = append(problems, fmt.Sprintf("missing column %s.%s", table, column))
+			}
+		}
+	}
+
+	for _, v := range views {
+		present, err := tableColumns(d.db, v.Name)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("missing view %s", v.Name))
+			continue
+		}
+		for _, column := range v.Columns {
+			if !present[column.Name] {
+				problems = append(problems, fmt.Sprintf("missing column %s.%s", v.Name, column.Name))
+			}
+		}
+	}
+
+	for _, index := range expectedIndexes {
+		var count int
+		if err := d.db.QueryRow(d.dialect.IndexCountQuery(), index).Scan(&count); err != nil {
+			return nil, fmt.Errorf("failed to look up index %s: %v", index, err)
+		}
+		if count == 0 {
+			problems = append(problems, fmt.Sprintf("missing index %s", index))
+		}
+	}
+
+	for _, unique := range expectedUniqueColumns {
+		var count int
+		if err := d.db.QueryRow(d.dialect.UniqueIndexCountQuery(), unique[0], unique[1]).Scan(&count); err != nil {
+			return nil, fmt.Errorf("failed to look up unique index on %s.%s: %v", unique[0], unique[1], err)
+		}
+		if count == 0 {
+			problems = append(problems, fmt.Sprintf("missing unique index on %s.%s", unique[0], unique[1]))
+		}
+	}
+
+	var version sql.NullInt64
+	err := d.db.QueryRow("SELECT MAX(version) FROM schema_migrations").Scan(&version)
+	if err != nil || !version.Valid {
+		problems = append(problems, "no schema migrations have been applied")
+	} else if int(version.Int64) != SchemaVersion {
+		problems = append(problems, fmt.Sprintf("schema version is %d, expected %d", version.Int64, SchemaVersion))
+	}
+
+	return problems, nil
+}
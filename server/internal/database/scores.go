@@ -0,0 +1,149 @@
+package database
+
+import (
+	"fmt"
+	"fundamental/server/internal/models"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// scoreSortPrefix starts the sort_by values sorting the property list by a
+// score, such as "score.value"
+const scoreSortPrefix = "score."
+
+// scoreNamePattern restricts score names to identifiers, so they can be used
+// in sort fields and query parameters
+var scoreNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,39}$`)
+
+// ValidScoreName reports whether a score returned by the scoring model can be
+// stored under its name: lowercase letters, digits and underscores, starting
+// with a letter, at most 40 characters
+func ValidScoreName(name string) bool {
+	return scoreNamePattern.MatchString(name)
+}
+
+// IsScoreSort reports whether a sort_by value of the property list sorts by a
+// score, "score.<name>"
+func IsScoreSort(field string) bool {
+	name, ok := strings.CutPrefix(field, scoreSortPrefix)
+	return ok && ValidScoreName(name)
+}
+
+// scoreSortColumn returns the SQL expression of a score sort field; the name
+// is validated, so it can be inlined
+func scoreSortColumn(field string) (string, bool) {
+	if !IsScoreSort(field) {
+		return "", false
+	}
+	name := strings.TrimPrefix(field, scoreSortPrefix)
+	return `(SELECT ps.score FROM property_scores ps
+            WHERE ps.property_id = properties.id AND ps.name = '` + name + `')`, true
+}
+
+// scoreFilter returns a condition restricting a properties query to the
+// properties with scores in the given ranges, bounds included, with its
+// arguments. Properties without a score do not match a range on it.
+func scoreFilter(minScores, maxScores map[string]float64) (string, []interface{}) {
+	conditions := []string{"1 = 1"}
+	var args []interface{}
+	for _, bound := range []struct {
+		scores map[string]float64
+		op     string
+	}{{minScores, ">="}, {maxScores, "<="}} {
+		names := make([]string, 0, len(bound.scores))
+		for name := range bound.scores {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			conditions = append(conditions, `id IN (
+            SELECT ps.property_id FROM property_scores ps
+            WHERE ps.name = ? AND ps.score `+bound.op+` ?)`)
+			args = append(args, name, bound.scores[name])
+		}
+	}
+	return "(" + strings.Join(conditions, " AND ") + ")", args
+}
+
+// SavePropertyScores stores the scores of a property by name, replacing the
+// earlier scores of the same names. Returns an ErrValidation error, and
+// stores nothing, when a name is invalid or a score is not a finite number.
+func (d *Database) SavePropertyScores(propertyID int64, scores map[string]float64) error {
+	for name, score := range scores {
+		if !ValidScoreName(name) {
+			return errorf(ErrValidation, "invalid score name %q", name)
+		}
+		if math.IsNaN(score) || math.IsInf(score, 0) {
+			return errorf(ErrValidation, "score %s is not a finite number", name)
+		}
+	}
+	if len(scores) == 0 {
+		return nil
+	}
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	for name, score := range scores {
+		_, err := tx.Exec(`
+			INSERT INTO property_scores (property_id, name, score, scored_at)
+			VALUES (?, ?, ?, CURRENT_TIMESTAMP)
+			ON CONFLICT (property_id, name) DO UPDATE SET
+				score = excluded.score,
+				scored_at = excluded.scored_at
+		`, propertyID, name, score)
+		if err != nil {
+			return fmt.Errorf("failed to store property score: %v", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return nil
+}
+
+// setPropertyScores fills in the scores of a page of properties
+func (d *Database) setPropertyScores(properties []models.Property) error {
+	byID := make(map[int64]*models.Property, len(properties))
+	ids := make([]interface{}, 0, len(properties))
+	for i := range properties {
+		byID[properties[i].ID] = &properties[i]
+		ids = append(ids, properties[i].ID)
+	}
+
+	for start := 0; start < len(ids); start += archiveBatchSize {
+		batch := ids[start:min(start+archiveBatchSize, len(ids))]
+		rows, err := d.db.Query(`
+			SELECT property_id, name, score FROM property_scores
+			WHERE property_id IN (?`+strings.Repeat(", ?", len(batch)-1)+`)
+		`, batch...)
+		if err != nil {
+			return fmt.Errorf("failed to query property scores: %v", err)
+		}
+		for rows.Next() {
+			var propertyID int64
+			var name string
+			var score float64
+			if err := rows.Scan(&propertyID, &name, &score); err != nil {
+				rows.Close()
+				return fmt.Errorf("failed to scan property score: %v", err)
+			}
+			p := byID[propertyID]
+			if p.Scores == nil {
+				p.Scores = make(map[string]float64)
+			}
+			p.Scores[name] = score
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return fmt.Errorf("error iterating property scores: %v", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,212 @@
+package database
+
+import (
+	"fmt"
+	"fundamental/server/internal/models"
+	"strings"
+	"unicode"
+)
+
+// Search modes reported with search results
+const (
+	SearchModeFTS  = "fts"
+	SearchModeLike = "like"
+)
+
+// searchPostalCode indexes a postal code both as written and without the space,
+// so "1012 AB", "1012AB" and "1012" all match
+func searchPostalCode(row string) string {
+	return fmt.Sprintf("COALESCE(%[1]s.postal_code, '') || ' ' || REPLACE(COALESCE(%[1]s.postal_code, ''), ' ', '')", row)
+}
+
+func searchValues(row string) string {
+	return fmt.Sprintf("%[1]s.id, COALESCE(%[1]s.street, ''), COALESCE(%[1]s.neighborhood, ''), %[2]s, COALESCE(%[1]s.city, '')",
+		row, searchPostalCode(row))
+}
+
+// searchIndexStatements create the contentless FTS5 index over properties and
+// the triggers that keep it in sync
+var searchIndexStatements = []string{
+	`CREATE VIRTUAL TABLE IF NOT EXISTS properties_fts USING fts5(
+		street, neighborhood, postal_code, city,
+		content='', prefix='2 3'
+	)`,
+	`CREATE TRIGGER IF NOT EXISTS properties_fts_ai AFTER INSERT ON properties BEGIN
+		INSERT INTO properties_fts(rowid, street, neighborhood, postal_code, city)
+		VALUES (` + searchValues("new") + `);
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS properties_fts_ad AFTER DELETE ON properties BEGIN
+		INSERT INTO properties_fts(properties_fts, rowid, street, neighborhood, postal_code, city)
+		VALUES ('delete', ` + searchValues("old") + `);
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS properties_fts_au AFTER UPDATE OF street, neighborhood, postal_code, city ON properties BEGIN
+		INSERT INTO properties_fts(properties_fts, rowid, street, neighborhood, postal_code, city)
+		VALUES ('delete', ` + searchValues("old") + `);
+		INSERT INTO properties_fts(rowid, street, neighborhood, postal_code, city)
+		VALUES (` + searchValues("new") + `);
+	END`,
+}
+
+var searchTriggers = []string{"properties_fts_ai", "properties_fts_ad", "properties_fts_au"}
+
+// fts5Available reports whether the linked SQLite was built with FTS5, which
+// needs the sqlite_fts5 build tag with the bundled SQLite
+func (d *Database) fts5Available() bool {
+	if d.dialect.Name() != DriverSQLite {
+		return false
+	}
+	var enabled int
+	err := d.db.QueryRow("SELECT sqlite_compileoption_used('ENABLE_FTS5')").Scan(&enabled)
+	return err == nil && enabled == 1
+}
+
+// searchIndexReady reports whether the FTS5 index exists and is kept in sync
+func (d *Database) searchIndexReady() (bool, error) {
+	if !d.fts5Available() {
+		return false, nil
+	}
+	var count int
+	err := d.db.QueryRow(`
+		SELECT COUNT(*) FROM sqlite_master
+		WHERE type = 'trigger' AND name IN (?, ?, ?)
+	`, searchTriggers[0], searchTriggers[1], searchTriggers[2]).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("failed to look up search triggers: %v", err)
+	}
+	return count == len(searchTriggers), nil
+}
+
+// ensureSearchIndex sets up the full-text index when FTS5 is available. The
+// index is not part of the migrations because it depends on how the binary was
+// built: without FTS5 its triggers would make every write to properties fail,
+// so they are dropped and search falls back to LIKE. When the index is
+// (re)created it is filled from the properties table.
+func (d *Database) ensureSearchIndex() error {
+	if d.dialect.Name() != DriverSQLite {
+		return nil
+	}
+
+	if !d.fts5Available() {
+		for _, trigger := range searchTriggers {
+			if _, err := d.db.Exec("DROP TRIGGER IF EXISTS " + trigger); err != nil {
+				return fmt.Errorf("failed to drop search trigger: %v", err)
+			}
+		}
+		return nil
+	}
+
+	ready, err := d.searchIndexReady()
+	if err != nil || ready {
+		return err
+	}
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	for _, stmt := range searchIndexStatements {
+		if _, err := tx.Exec(stmt); err != nil {
+			return fmt.Errorf("failed to create search index: %v", err)
+		}
+	}
+	// Writes made while the triggers were missing are not indexed, so rebuild
+	if _, err := tx.Exec("INSERT INTO properties_fts(properties_fts) VALUES ('delete-all')"); err != nil {
+		return fmt.Errorf("failed to clear search index: %v", err)
+	}
+	_, err = tx.Exec(`
+		INSERT INTO properties_fts(rowid, street, neighborhood, postal_code, city)
+		SELECT ` + searchValues("properties") + ` FROM properties
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to fill search index: %v", err)
+	}
+
+	return tx.Commit()
+}
+
+// searchTerms splits a query into lower-case words of letters and digits
+func searchTerms(query string) []string {
+	return strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// SearchProperties finds properties whose street, neighborhood, postal code or
+// city contain all words of the query, as word prefixes when the full-text
+// index is available and as substrings otherwise. Returns the mode used.
+func (d *Database) SearchProperties(query string, limit int) ([]models.Property, string, error) {
+	properties := []models.Property{}
+	terms := searchTerms(query)
+	if len(terms) == 0 {
+		return properties, SearchModeLike, nil
+	}
+
+	ready, err := d.searchIndexReady()
+	if err != nil {
+		return nil, "", err
+	}
+
+	collect := func(p models.Property) error {
+		properties = append(properties, p)
+		return nil
+	}
+
+	if ready {
+		// Quote every term so FTS5 syntax characters cannot leak into the query
+		match := make([]string, len(terms))
+		for i, term := range terms {
+			match[i] = `"` + term + `"*`
+		}
+		err = d.forEachProperty(`
+			SELECT `+propertyColumns+`
+			FROM properties
+			JOIN (
+				SELECT rowid AS match_id, rank AS match_rank
+				FROM properties_fts
+				WHERE properties_fts MATCH ?
+				ORDER BY rank
+				LIMIT ?
+			) AS matches ON matches.match_id = properties.id
+			ORDER BY matches.match_rank
+		`, []interface{}{strings.Join(match, " "), limit}, collect)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to search properties: %v", err)
+		}
+		return properties, SearchModeFTS, nil
+	}
+
+	condition, args := searchLikeCondition(terms)
+	args = append(args, limit)
+
+	err = d.forEachProperty(`
+		SELECT `+propertyColumns+`
+		FROM properties
+		WHERE `+condition+`
+		ORDER BY id DESC
+		LIMIT ?
+	`, args, collect)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to search properties: %v", err)
+	}
+	return properties, SearchModeLike, nil
+}
+
+// searchLikeCondition returns the substring match of every term against the
+// searched columns, used when there is no FTS5 index
+func searchLikeCondition(terms []string) (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+	for _, term := range terms {
+		conditions = append(conditions, `(
+			LOWER(COALESCE(street, '')) LIKE ?
+			OR LOWER(COALESCE(neighborhood, '')) LIKE ?
+			OR LOWER(REPLACE(COALESCE(postal_code, ''), ' ', '')) LIKE ?
+			OR LOWER(COALESCE(city, '')) LIKE ?
+		)`)
+		pattern := "%" + term + "%"
+		args = append(args, pattern, pattern, pattern, pattern)
+	}
+	return strings.Join(conditions, " AND "), args
+}
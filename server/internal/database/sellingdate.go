@@ -0,0 +1,47 @@
+package database
+
+import (
+	"fundamental/server/internal/models"
+	"regexp"
+	"strings"
+)
+
+// monthDateRegex matches a selling date known only to the month, YYYY-MM
+var monthDateRegex = regexp.MustCompile(`^\d{4}-\d{2}$`)
+
+// itemSellingDate sets the precision of the selling date of a scraped or
+// imported item. A month-only date (YYYY-MM) is stored as the first of the
+// month with month precision, a full date is exact unless the item says it
+// is not, and an item without a selling date has no precision.
+func itemSellingDate(prop map[string]interface{}) {
+	date, _ := prop["selling_date"].(string)
+	date = strings.TrimSpace(date)
+	if date == "" {
+		delete(prop, "selling_date_precision")
+		return
+	}
+	if monthDateRegex.MatchString(date) {
+		prop["selling_date"] = date + "-01"
+		prop["selling_date_precision"] = models.DatePrecisionMonth
+		return
+	}
+	precision, _ := prop["selling_date_precision"].(string)
+	precision = strings.ToLower(strings.TrimSpace(precision))
+	if precision == "" {
+		precision = models.DatePrecisionDay
+	}
+	prop["selling_date_precision"] = precision
+}
+
+// validDatePrecision reports whether a selling date precision is known
+func validDatePrecision(precision string) bool {
+	return precision == models.DatePrecisionDay || precision == models.DatePrecisionMonth
+}
+
+// exactSellingDate is the condition of a property whose selling date is known
+// to the day, with the table alias prefix such as "p.". Days to sell are only
+// computed from exact dates; dates stored before their precision was recorded
+// count as exact.
+func exactSellingDate(alias string) string {
+	return "COALESCE(" + alias + "selling_date_precision, '" + models.DatePrecisionDay + "') = '" + models.DatePrecisionDay + "'"
+}
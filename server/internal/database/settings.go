@@ -0,0 +1,41 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// GetSetting decodes the JSON value of a server setting into value and
+// reports whether the setting is stored
+func (d *Database) GetSetting(name string, value interface{}) (bool, error) {
+	var raw string
+	err := d.db.QueryRow("SELECT value FROM settings WHERE name = ?", name).Scan(&raw)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to get setting %s: %v", name, err)
+	}
+	if err := json.Unmarshal([]byte(raw), value); err != nil {
+		return false, fmt.Errorf("failed to decode setting %s: %v", name, err)
+	}
+	return true, nil
+}
+
+// SaveSetting stores the JSON encoding of a server setting
+func (d *Database) SaveSetting(name string, value interface{}) error {
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to encode setting %s: %v", name, err)
+	}
+	_, err = d.db.Exec(`
+		INSERT INTO settings (name, value, updated_at) VALUES (?, ?, ?)
+		ON CONFLICT (name) DO UPDATE SET value = excluded.value, updated_at = excluded.updated_at
+	`, name, string(raw), time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("failed to save setting %s: %v", name, err)
+	}
+	return nil
+}
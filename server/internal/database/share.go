@@ -0,0 +1,125 @@
+package database
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"fundamental/server/internal/models"
+	"math/big"
+)
+
+const (
+	shareTokenLength   = 8
+	shareTokenAlphabet = "abcdefghijkmnopqrstuvwxyzABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+)
+
+// generateShareToken returns a random token without easily confused characters
+func generateShareToken() (string, error) {
+	token := make([]byte, shareTokenLength)
+	max := big.NewInt(int64(len(shareTokenAlphabet)))
+	for i := range token {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		token[i] = shareTokenAlphabet[n.Int64()]
+	}
+	return string(token), nil
+}
+
+// CreateSharedView stores a view definition and returns its token. Sharing an
+// identical definition again returns the existing token.
+func (d *Database) CreateSharedView(definition json.RawMessage) (*models.SharedView, error) {
+	sum := sha256.Sum256(definition)
+	hash := hex.EncodeToString(sum[:])
+
+	existing, err := d.sharedViewToken(hash)
+	if err != nil {
+		return nil, err
+	}
+	if existing != "" {
+		return d.getSharedView(existing)
+	}
+
+	// Retry on the unlikely event of a token collision
+	for attempt := 0; attempt < 5; attempt++ {
+		token, err := generateShareToken()
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate share token: %v", err)
+		}
+
+		result, err := d.db.Exec(`
+			INSERT INTO shared_views (token, definition, definition_hash)
+			VALUES (?, ?, ?)
+			ON CONFLICT DO NOTHING
+		`, token, string(definition), hash)
+		if err != nil {
+			return nil, fmt.Errorf("failed to insert shared view: %v", err)
+		}
+		if n, _ := result.RowsAffected(); n == 1 {
+			return d.getSharedView(token)
+		}
+
+		// Nothing was inserted: either a concurrent request shared the same
+		// definition first, or the token is taken
+		if existing, err = d.sharedViewToken(hash); err != nil {
+			return nil, err
+		}
+		if existing != "" {
+			return d.getSharedView(existing)
+		}
+	}
+
+	return nil, fmt.Errorf("failed to allocate a unique share token")
+}
+
+// sharedViewToken returns the token of the shared view with the definition
+// hash, or "" if the definition was not shared
+func (d *Database) sharedViewToken(hash string) (string, error) {
+	var token string
+	err := d.db.QueryRow("SELECT token FROM shared_views WHERE definition_hash = ?", hash).Scan(&token)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to check existing shared view: %v", err)
+	}
+	return token, nil
+}
+
+// ResolveSharedView returns the view stored under token and records the access
+func (d *Database) ResolveSharedView(token string) (*models.SharedView, error) {
+	result, err := d.db.Exec(`
+		UPDATE shared_views
+		SET access_count = access_count + 1, last_accessed_at = CURRENT_TIMESTAMP
+		WHERE token = ?
+	`, token)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update shared view: %v", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return nil, nil
+	}
+	return d.getSharedView(token)
+}
+
+func (d *Database) getSharedView(token string) (*models.SharedView, error) {
+	var view models.SharedView
+	var definition string
+	err := d.db.QueryRow(`
+		SELECT token, definition, access_count, created_at
+		FROM shared_views
+		WHERE token = ?
+	`, token).Scan(&view.Token, &definition, &view.AccessCount, &view.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get shared view: %v", err)
+	}
+	view.Definition = json.RawMessage(definition)
+	return &view, nil
+}
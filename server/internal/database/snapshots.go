@@ -0,0 +1,128 @@
+package database
+
+import (
+	"bytes"
+	"compress/gzip"
+	"database/sql"
+	"fmt"
+	"fundamental/server/internal/models"
+	"io"
+)
+
+// SavePropertySnapshot stores the gzip-compressed raw spider payload for the property with the given URL
+func (d *Database) SavePropertySnapshot(url string, payload []byte) error {
+	var propertyID int64
+	err := d.db.QueryRow("SELECT id FROM properties WHERE url = ?", url).Scan(&propertyID)
+	if err == sql.ErrNoRows {
+		return errorf(ErrNotFound, "property not found for snapshot: %s", url)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to look up property for snapshot: %v", err)
+	}
+
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	if _, err := writer.Write(payload); err != nil {
+		return fmt.Errorf("failed to compress snapshot: %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("failed to compress snapshot: %v", err)
+	}
+
+	_, err = d.db.Exec(`
+		INSERT INTO property_snapshots (property_id, payload)
+		VALUES (?, ?)
+	`, propertyID, buf.Bytes())
+	if err != nil {
+		return fmt.Errorf("failed to insert property snapshot: %v", err)
+	}
+	return nil
+}
+
+// GetPropertySnapshots returns the most recent snapshots of a property, newest first
+func (d *Database) GetPropertySnapshots(propertyID int64, limit int) ([]models.PropertySnapshot, error) {
+	rows, err := d.db.Query(`
+		SELECT s.id, s.property_id, p.url, s.payload, s.created_at
+		FROM property_snapshots s
+		JOIN properties p ON p.id = s.property_id
+		WHERE s.property_id = ?
+		ORDER BY s.created_at DESC, s.id DESC
+		LIMIT ?
+	`, propertyID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query property snapshots: %v", err)
+	}
+	defer rows.Close()
+
+	snapshots := []models.PropertySnapshot{}
+	for rows.Next() {
+		var snapshot models.PropertySnapshot
+		var compressed []byte
+		if err := rows.Scan(&snapshot.ID, &snapshot.PropertyID, &snapshot.URL, &compressed, &snapshot.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan property snapshot: %v", err)
+		}
+
+		reader, err := gzip.NewReader(bytes.NewReader(compressed))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress snapshot %d: %v", snapshot.ID, err)
+		}
+		payload, err := io.ReadAll(reader)
+		reader.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress snapshot %d: %v", snapshot.ID, err)
+		}
+		snapshot.Payload = payload
+
+		snapshots = append(snapshots, snapshot)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating property snapshots: %v", err)
+	}
+
+	return snapshots, nil
+}
+
+// PruneSnapshots enforces the snapshot retention limits: snapshots older than
+// retentionDays are removed and at most maxPerProperty are kept per property.
+// A limit of zero or less disables that rule. Returns the number of deleted rows.
+func (d *Database) PruneSnapshots(retentionDays, maxPerProperty int) (int64, error) {
+	var deleted int64
+
+	if retentionDays > 0 {
+		result, err := d.db.Exec(`
+			DELETE FROM property_snapshots
+			WHERE created_at < `+d.dialect.TimestampOffset()+`
+		`, fmt.Sprintf("-%d days", retentionDays))
+		if err != nil {
+			return deleted, fmt.Errorf("failed to prune expired snapshots: %v", err)
+		}
+		if n, err := result.RowsAffected(); err == nil {
+			deleted += n
+		}
+	}
+
+	if maxPerProperty > 0 {
+		result, err := d.db.Exec(`
+			DELETE FROM property_snapshots
+			WHERE id IN (
+				SELECT id FROM (
+					SELECT id,
+						ROW_NUMBER() OVER (
+							PARTITION BY property_id
+							ORDER BY created_at DESC, id DESC
+						) as row_num
+					FROM property_snapshots
+				) AS ranked
+				WHERE row_num > ?
+			)
+		`, maxPerProperty)
+		if err != nil {
+			return deleted, fmt.Errorf("failed to prune excess snapshots: %v", err)
+		}
+		if n, err := result.RowsAffected(); err == nil {
+			deleted += n
+		}
+	}
+
+	return deleted, nil
+}
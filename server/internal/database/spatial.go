@@ -0,0 +1,273 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"fundamental/server/internal/models"
+	"math"
+	"sort"
+	"strings"
+)
+
+// earthRadiusMeters is the mean radius of the earth used for distances
+const earthRadiusMeters = 6371008.8
+
+// spatialIndexStatements create the R*Tree index over property coordinates and
+// the triggers that keep it in sync. Properties without coordinates are not in
+// the index.
+var spatialIndexStatements = []string{
+	`CREATE VIRTUAL TABLE IF NOT EXISTS properties_rtree USING rtree(
+		id, min_lat, max_lat, min_lng, max_lng
+	)`,
+	`CREATE TRIGGER IF NOT EXISTS properties_rtree_ai AFTER INSERT ON properties
+	WHEN new.latitude IS NOT NULL AND new.longitude IS NOT NULL BEGIN
+		INSERT INTO properties_rtree VALUES (new.id, new.latitude, new.latitude, new.longitude, new.longitude);
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS properties_rtree_ad AFTER DELETE ON properties BEGIN
+		DELETE FROM properties_rtree WHERE id = old.id;
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS properties_rtree_au AFTER UPDATE OF latitude, longitude ON properties BEGIN
+		DELETE FROM properties_rtree WHERE id = old.id;
+		INSERT INTO properties_rtree
+		SELECT new.id, new.latitude, new.latitude, new.longitude, new.longitude
+		WHERE new.latitude IS NOT NULL AND new.longitude IS NOT NULL;
+	END`,
+}
+
+var spatialTriggers = []string{"properties_rtree_ai", "properties_rtree_ad", "properties_rtree_au"}
+
+// rtreeAvailable reports whether the linked SQLite was built with the R*Tree
+// module. The bundled SQLite always is; a system SQLite or SQLCipher may not be.
+func (d *Database) rtreeAvailable() bool {
+	if d.dialect.Name() != DriverSQLite {
+		return false
+	}
+	var enabled int
+	err := d.db.QueryRow("SELECT sqlite_compileoption_used('ENABLE_RTREE')").Scan(&enabled)
+	return err == nil && enabled == 1
+}
+
+// spatialIndexReady reports whether the R*Tree index exists and is kept in sync
+func (d *Database) spatialIndexReady() (bool, error) {
+	if !d.rtreeAvailable() {
+		return false, nil
+	}
+	var count int
+	err := d.db.QueryRow(`
+		SELECT COUNT(*) FROM sqlite_master
+		WHERE type = 'trigger' AND name IN (?, ?, ?)
+	`, spatialTriggers[0], spatialTriggers[1], spatialTriggers[2]).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("failed to look up spatial index triggers: %v", err)
+	}
+	return count == len(spatialTriggers), nil
+}
+
+// ensureSpatialIndex sets up the R*Tree index when the module is available.
+// Like the search index it is not a migration: without the module the triggers
+// would make every write to properties fail, so they are dropped and
+// coordinate queries use idx_properties_coordinates instead. When the index is
+// (re)created it is filled from the properties table.
+func (d *Database) ensureSpatialIndex() error {
+	if d.dialect.Name() != DriverSQLite {
+		return nil
+	}
+
+	if !d.rtreeAvailable() {
+		for _, trigger := range spatialTriggers {
+			if _, err := d.db.Exec("DROP TRIGGER IF EXISTS " + trigger); err != nil {
+				return fmt.Errorf("failed to drop spatial index trigger: %v", err)
+			}
+		}
+		return nil
+	}
+
+	ready, err := d.spatialIndexReady()
+	if err != nil || ready {
+		return err
+	}
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	for _, stmt := range spatialIndexStatements {
+		if _, err := tx.Exec(stmt); err != nil {
+			return fmt.Errorf("failed to create spatial index: %v", err)
+		}
+	}
+	// Coordinates written while the triggers were missing are not indexed, so rebuild
+	if _, err := tx.Exec("DELETE FROM properties_rtree"); err != nil {
+		return fmt.Errorf("failed to clear spatial index: %v", err)
+	}
+	_, err = tx.Exec(`
+		INSERT INTO properties_rtree
+		SELECT id, latitude, latitude, longitude, longitude FROM properties
+		WHERE latitude IS NOT NULL AND longitude IS NOT NULL
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to fill spatial index: %v", err)
+	}
+
+	return tx.Commit()
+}
+
+// boundsFilter returns the condition selecting the properties inside a
+// bounding box. The R*Tree stores 32-bit floats rounded outwards, so its
+// candidates are checked against the exact coordinates.
+func (d *Database) boundsFilter(minLat, minLng, maxLat, maxLng float64) (string, []interface{}, error) {
+	condition := "latitude BETWEEN ? AND ? AND longitude BETWEEN ? AND ?"
+	args := []interface{}{minLat, maxLat, minLng, maxLng}
+
+	ready, err := d.spatialIndexReady()
+	if err != nil {
+		return "", nil, err
+	}
+	if !ready {
+		return condition, args, nil
+	}
+	condition = `id IN (
+            SELECT id FROM properties_rtree
+            WHERE max_lat >= ? AND min_lat <= ? AND max_lng >= ? AND min_lng <= ?
+        ) AND ` + condition
+	return condition, append([]interface{}{minLat, maxLat, minLng, maxLng}, args...), nil
+}
+
+// GetPropertiesInBounds returns the geocoded properties inside a bounding box
+// that match the filter, so a map only loads the properties in its viewport.
+func (d *Database) GetPropertiesInBounds(minLat, minLng, maxLat, maxLng float64, filter models.PropertyFilter) ([]models.Property, error) {
+	bounds, args, err := d.boundsFilter(minLat, minLng, maxLat, maxLng)
+	if err != nil {
+		return nil, err
+	}
+	tags, tagArgs := tagFilter(filter.Tags)
+	query := `
+        SELECT ` + propertyColumns + `
+        FROM properties
+        WHERE ` + bounds + `
+        AND ` + propertyListFilter(filter.City) + `
+        AND (? = '' OR status = ?)
+        AND ` + tags + `
+        ORDER BY id`
+	args = append(args, propertyListArgs(filter.StartDate, filter.EndDate, filter.City)...)
+	args = append(args, filter.Status, filter.Status)
+	args = append(args, tagArgs...)
+
+	properties := []models.Property{}
+	err = d.forEachProperty(query, args, func(p models.Property) error {
+		properties = append(properties, p)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get properties in bounds: %v", err)
+	}
+	return properties, nil
+}
+
+// GetPointClusters groups the geocoded properties inside a bounding box that
+// match the filter into a grid of cells by cells, so a zoomed out map gets one
+// point per cell instead of every property. Empty cells are left out. Results
+// are cached like the dashboard statistics.
+func (d *Database) GetPointClusters(minLat, minLng, maxLat, maxLng float64, cells int, filter models.PropertyFilter) ([]models.PointCluster, error) {
+	key := fmt.Sprintf("point_clusters\x00%v\x00%v\x00%v\x00%v\x00%d\x00%s\x00%s\x00%s\x00%s\x00%s",
+		minLat, minLng, maxLat, maxLng, cells, filter.StartDate, filter.EndDate, filter.City, filter.Status,
+		strings.Join(filter.Tags, "\x00"))
+	value, err := d.cachedStats(key, func() (interface{}, error) {
+		return d.pointClusters(minLat, minLng, maxLat, maxLng, cells, filter)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return value.([]models.PointCluster), nil
+}
+
+func (d *Database) pointClusters(minLat, minLng, maxLat, maxLng float64, cells int, filter models.PropertyFilter) ([]models.PointCluster, error) {
+	bounds, boundsArgs, err := d.boundsFilter(minLat, minLng, maxLat, maxLng)
+	if err != nil {
+		return nil, err
+	}
+	latStep := (maxLat - minLat) / float64(cells)
+	lngStep := (maxLng - minLng) / float64(cells)
+	if latStep <= 0 || lngStep <= 0 {
+		return []models.PointCluster{}, nil
+	}
+
+	tags, tagArgs := tagFilter(filter.Tags)
+	query := `
+        SELECT AVG(latitude), AVG(longitude), COUNT(*),
+               SUM(CASE WHEN status = 'active' THEN 1 ELSE 0 END), AVG(price)
+        FROM properties
+        WHERE ` + bounds + `
+        AND ` + propertyListFilter(filter.City) + `
+        AND (? = '' OR status = ?)
+        AND ` + tags + `
+        GROUP BY ` + d.dialect.Truncate("(latitude - ?) / ?") + `, ` + d.dialect.Truncate("(longitude - ?) / ?")
+	args := append(boundsArgs, propertyListArgs(filter.StartDate, filter.EndDate, filter.City)...)
+	args = append(args, filter.Status, filter.Status)
+	args = append(args, tagArgs...)
+	args = append(args, minLat, latStep, minLng, lngStep)
+
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query point clusters: %v", err)
+	}
+	defer rows.Close()
+
+	clusters := []models.PointCluster{}
+	for rows.Next() {
+		var cluster models.PointCluster
+		var avgPrice sql.NullFloat64
+		if err := rows.Scan(&cluster.Latitude, &cluster.Longitude, &cluster.Count, &cluster.ActiveCount, &avgPrice); err != nil {
+			return nil, fmt.Errorf("failed to scan point cluster: %v", err)
+		}
+		cluster.AvgPrice = math.Round(avgPrice.Float64)
+		clusters = append(clusters, cluster)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating point clusters: %v", err)
+	}
+	return clusters, nil
+}
+
+// GetPropertiesNear returns the geocoded properties within radiusMeters of a
+// point that match the filter, nearest first. At most limit properties are
+// returned when limit is positive.
+func (d *Database) GetPropertiesNear(lat, lng, radiusMeters float64, limit int, filter models.PropertyFilter) ([]models.NearbyProperty, error) {
+	// Select the bounding box of the circle, then drop its corners
+	latDelta := radiusMeters / earthRadiusMeters * 180 / math.Pi
+	lngDelta := 180.0
+	if cos := math.Cos(lat * math.Pi / 180); cos > 1e-9 {
+		lngDelta = math.Min(latDelta/cos, 180)
+	}
+	inBounds, err := d.GetPropertiesInBounds(lat-latDelta, lng-lngDelta, lat+latDelta, lng+lngDelta, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	nearby := []models.NearbyProperty{}
+	for _, p := range inBounds {
+		distance := haversineMeters(lat, lng, *p.Latitude, *p.Longitude)
+		if distance <= radiusMeters {
+			nearby = append(nearby, models.NearbyProperty{Property: p, DistanceMeters: math.Round(distance)})
+		}
+	}
+	sort.SliceStable(nearby, func(i, j int) bool {
+		return nearby[i].DistanceMeters < nearby[j].DistanceMeters
+	})
+	if limit > 0 && len(nearby) > limit {
+		nearby = nearby[:limit]
+	}
+	return nearby, nil
+}
+
+// haversineMeters returns the great-circle distance between two points
+func haversineMeters(lat1, lng1, lat2, lng2 float64) float64 {
+	toRad := math.Pi / 180
+	dLat := (lat2 - lat1) * toRad
+	dLng := (lng2 - lng1) * toRad
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1*toRad)*math.Cos(lat2*toRad)*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * earthRadiusMeters * math.Asin(math.Sqrt(a))
+}
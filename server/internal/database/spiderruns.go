@@ -0,0 +1,88 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"fundamental/server/internal/models"
+)
+
+const spiderRunColumns = `run_id, spider_type, place, started_at, finished_at, pages, listings, items, stored, new_items, rejected, result_count, coverage, error`
+
+func scanSpiderRun(row rowScanner) (*models.SpiderRun, error) {
+	var run models.SpiderRun
+	var finishedAt sql.NullTime
+	var resultCount sql.NullInt64
+	var coverage sql.NullFloat64
+	var errMsg sql.NullString
+	if err := row.Scan(&run.RunID, &run.SpiderType, &run.Place, &run.StartedAt, &finishedAt,
+		&run.Pages, &run.Listings, &run.Items, &run.Stored, &run.New, &run.Rejected,
+		&resultCount, &coverage, &errMsg); err != nil {
+		return nil, err
+	}
+	if finishedAt.Valid {
+		run.FinishedAt = &finishedAt.Time
+	}
+	if resultCount.Valid {
+		count := int(resultCount.Int64)
+		run.ResultCount = &count
+	}
+	if coverage.Valid {
+		run.Coverage = &coverage.Float64
+	}
+	run.Error = errMsg.String
+	return &run, nil
+}
+
+// SaveSpiderRun records a finished spider run
+func (d *Database) SaveSpiderRun(run models.SpiderRun) error {
+	var finishedAt sql.NullTime
+	if run.FinishedAt != nil {
+		finishedAt = sql.NullTime{Time: run.FinishedAt.UTC(), Valid: true}
+	}
+	_, err := d.db.Exec(`
+		INSERT INTO spider_runs (`+spiderRunColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, run.RunID, run.SpiderType, run.Place, run.StartedAt.UTC(), finishedAt,
+		run.Pages, run.Listings, run.Items, run.Stored, run.New, run.Rejected,
+		run.ResultCount, run.Coverage, sql.NullString{String: run.Error, Valid: run.Error != ""})
+	if err != nil {
+		return fmt.Errorf("failed to insert spider run: %v", err)
+	}
+	return nil
+}
+
+// ListSpiderRuns returns the newest spider runs, optionally only those of one
+// city or spider type
+func (d *Database) ListSpiderRuns(place, spiderType string, limit int) ([]models.SpiderRun, error) {
+	query := "SELECT " + spiderRunColumns + " FROM spider_runs WHERE 1=1"
+	var args []interface{}
+	if place != "" {
+		query += " AND place = ?"
+		args = append(args, place)
+	}
+	if spiderType != "" {
+		query += " AND spider_type = ?"
+		args = append(args, spiderType)
+	}
+	query += " ORDER BY started_at DESC, run_id DESC LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query spider runs: %v", err)
+	}
+	defer rows.Close()
+
+	runs := []models.SpiderRun{}
+	for rows.Next() {
+		run, err := scanSpiderRun(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan spider run: %v", err)
+		}
+		runs = append(runs, *run)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating spider runs: %v", err)
+	}
+	return runs, nil
+}
@@ -0,0 +1,116 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// Options tunes the connection pool and the per-connection SQLite settings
+type Options struct {
+	// Key unlocks an SQLCipher encrypted SQLite database; empty for a plain database
+	Key string
+	// JournalMode is the SQLite journal mode; WAL lets readers run alongside a writer
+	JournalMode string
+	// BusyTimeout is how long SQLite waits for a lock before failing with "database is locked"
+	BusyTimeout time.Duration
+	// MaxOpenConns and MaxIdleConns size the connection pool; zero means unlimited open connections
+	MaxOpenConns int
+	MaxIdleConns int
+	// ReadOnly opens SQLite connections that cannot write, for a read replica
+	// of the database. Their transactions start deferred so they never wait
+	// for the write lock.
+	ReadOnly bool
+	// StatsCacheTTL is how long the dashboard stats are cached when the
+	// properties do not change; zero disables the cache
+	StatsCacheTTL time.Duration
+	// QueryTimeout limits every query run on a context bound with WithContext;
+	// zero means no limit
+	QueryTimeout time.Duration
+}
+
+// DefaultOptions returns the settings used when none are configured
+func DefaultOptions() Options {
+	return Options{
+		JournalMode:  "WAL",
+		BusyTimeout:  5 * time.Second,
+		MaxOpenConns: 10,
+		MaxIdleConns: 5,
+	}
+}
+
+// sqliteConnector opens SQLite connections and applies the key and pragmas to
+// every connection before it is handed to the pool. database/sql keeps several
+// connections, so pragmas executed once on the pool would only reach one of them.
+type sqliteConnector struct {
+	dsn    string
+	driver *sqlite3.SQLiteDriver
+}
+
+func (c *sqliteConnector) Connect(context.Context) (driver.Conn, error) {
+	return c.driver.Open(c.dsn)
+}
+
+func (c *sqliteConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+// quoteLiteral quotes a string as an SQL literal
+func quoteLiteral(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
+
+// sqlitePragmas returns the statements run on every new connection. The
+// SQLCipher key has to come first, before anything reads the database.
+func sqlitePragmas(opts Options) []string {
+	var pragmas []string
+	if opts.Key != "" {
+		pragmas = append(pragmas, "PRAGMA key = "+quoteLiteral(opts.Key))
+	}
+	pragmas = append(pragmas, "PRAGMA foreign_keys = ON")
+	if opts.BusyTimeout > 0 {
+		pragmas = append(pragmas, fmt.Sprintf("PRAGMA busy_timeout = %d", opts.BusyTimeout.Milliseconds()))
+	}
+	// The journal mode is kept in the database file, the writer sets it
+	if opts.JournalMode != "" && !opts.ReadOnly {
+		pragmas = append(pragmas, "PRAGMA journal_mode = "+opts.JournalMode)
+	}
+	if opts.ReadOnly {
+		pragmas = append(pragmas, "PRAGMA query_only = ON")
+	}
+	return pragmas
+}
+
+// newSQLiteDB returns a connection pool for the SQLite database at path.
+// Transactions take the write lock when they begin: a deferred transaction
+// that reads first and writes later cannot wait for a concurrent writer and
+// fails with "database is locked" regardless of the busy timeout.
+func newSQLiteDB(path string, opts Options) *sql.DB {
+	separator := "?"
+	if strings.Contains(path, "?") {
+		separator = "&"
+	}
+	txlock := "immediate"
+	if opts.ReadOnly {
+		txlock = "deferred"
+	}
+	pragmas := sqlitePragmas(opts)
+	return sql.OpenDB(&sqliteConnector{
+		dsn: path + separator + "_txlock=" + txlock,
+		driver: &sqlite3.SQLiteDriver{
+			ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+				for _, pragma := range pragmas {
+					if _, err := conn.Exec(pragma, nil); err != nil {
+						return fmt.Errorf("failed to apply %q: %v", strings.SplitN(pragma, "=", 2)[0], err)
+					}
+				}
+				return nil
+			},
+		},
+	})
+}
@@ -0,0 +1,340 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"fundamental/server/internal/country"
+	"fundamental/server/internal/models"
+	"math"
+	"math/rand"
+)
+
+// propertyDateFilter restricts a query to active properties listed and sold
+// properties sold within a date range. Bind it with propertyDateFilterArgs.
+const propertyDateFilter = `(
+            -- For active properties, check effective_date (listing_date or scraped_at)
+            (status = 'active' AND (
+                ? = '' OR COALESCE(listing_date, CAST(scraped_at AS TEXT)) >= ?
+            ) AND (
+                ? = '' OR COALESCE(listing_date, CAST(scraped_at AS TEXT)) <= ?
+            ))
+            OR
+            -- For sold properties, check selling_date only if it exists
+            (status = 'sold' AND selling_date IS NOT NULL AND (
+                ? = '' OR selling_date >= ?
+            ) AND (
+                ? = '' OR selling_date <= ?
+            ))
+        )`
+
+// cityFilter returns the condition restricting a query to one city, matched
+// case-insensitively; bind it with city twice. An OR with the empty-city case
+// keeps the city indexes from being used, so the condition only contains it
+// when no city is selected.
+func cityFilter(city string) string {
+	if city == "" {
+		return "(? = '' OR LOWER(city) = LOWER(?))"
+	}
+	return "(? <> '' AND LOWER(city) = LOWER(?))"
+}
+
+// propertyDateFilterArgs returns the arguments for propertyDateFilter
+func propertyDateFilterArgs(startDate, endDate string) []interface{} {
+	return []interface{}{
+		startDate, startDate, // For active properties listing_date >= ?
+		endDate, endDate, // For active properties listing_date <= ?
+		startDate, startDate, // For sold properties selling_date >= ?
+		endDate, endDate, // For sold properties selling_date <= ?
+	}
+}
+
+// GetPriceHistogram counts active and sold properties per price bucket of bucketSize euros;
+// with an asOf date, as they stood at the end of that day
+func (d *Database) GetPriceHistogram(bucketSize int, startDate, endDate string, city string, asOf string) ([]models.PriceBucket, error) {
+	source, sourceArgs, err := propertiesAsOf(asOf)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+        SELECT 
+            (price / ?) * ? as bucket_start,
+            SUM(CASE WHEN status = 'active' THEN 1 ELSE 0 END) as active_count,
+            SUM(CASE WHEN status = 'sold' THEN 1 ELSE 0 END) as sold_count
+        FROM ` + source + `
+        WHERE price > 0
+        AND ` + cityFilter(city) + `
+        AND ` + propertyDateFilter + `
+        GROUP BY bucket_start
+        ORDER BY bucket_start
+    `
+	var args []interface{}
+	args = append(args,
+		bucketSize, bucketSize, // For bucket calculation
+	)
+	args = append(args, sourceArgs...)
+	args = append(args, city, city) // For city filter
+	args = append(args, propertyDateFilterArgs(startDate, endDate)...)
+
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query price histogram: %v", err)
+	}
+	defer rows.Close()
+
+	buckets := []models.PriceBucket{}
+	for rows.Next() {
+		var bucket models.PriceBucket
+		if err := rows.Scan(&bucket.MinPrice, &bucket.ActiveCount, &bucket.SoldCount); err != nil {
+			return nil, fmt.Errorf("failed to scan price bucket: %v", err)
+		}
+		bucket.MaxPrice = bucket.MinPrice + bucketSize
+		buckets = append(buckets, bucket)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating price buckets: %v", err)
+	}
+
+	return buckets, nil
+}
+
+// fillPropertyMedians sets the median price and price per m² of a stats result,
+// overall and for active and sold properties separately. The median is the
+// middle value, or the mean of the two middle values for an even count.
+func (d *Database) fillPropertyMedians(stats *models.PropertyStats, startDate, endDate string, city string, asOf string) error {
+	source, args, err := propertiesAsOf(asOf)
+	if err != nil {
+		return err
+	}
+
+	query := `
+        WITH filtered AS (
+            SELECT status, price, CAST(price AS FLOAT) / NULLIF(living_area, 0) as price_per_sqm
+            FROM ` + source + `
+            WHERE price IS NOT NULL
+            AND ` + cityFilter(city) + `
+            AND ` + propertyDateFilter + `
+        ),
+        grouped AS (
+            SELECT status as grp, price, price_per_sqm FROM filtered
+            UNION ALL
+            SELECT 'all' as grp, price, price_per_sqm FROM filtered
+        ),
+        ranked AS (
+            SELECT 'price' as metric, grp, CAST(price AS FLOAT) as value,
+                   ROW_NUMBER() OVER (PARTITION BY grp ORDER BY price) as row_num,
+                   COUNT(*) OVER (PARTITION BY grp) as total_count
+            FROM grouped
+            UNION ALL
+            SELECT 'price_per_sqm' as metric, grp, price_per_sqm as value,
+                   ROW_NUMBER() OVER (PARTITION BY grp ORDER BY price_per_sqm) as row_num,
+                   COUNT(*) OVER (PARTITION BY grp) as total_count
+            FROM grouped
+            WHERE price_per_sqm IS NOT NULL
+        )
+        SELECT metric, grp, AVG(value) as median
+        FROM ranked
+        WHERE row_num IN ((total_count + 1) / 2, (total_count + 2) / 2)
+        GROUP BY metric, grp
+    `
+	args = append(args, city, city)
+	args = append(args, propertyDateFilterArgs(startDate, endDate)...)
+
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to query median prices: %v", err)
+	}
+	defer rows.Close()
+
+	targets := map[string]*float64{
+		"price/all":            &stats.MedianPrice,
+		"price/active":         &stats.ActiveMedianPrice,
+		"price/sold":           &stats.SoldMedianPrice,
+		"price_per_sqm/all":    &stats.MedianPricePerSqm,
+		"price_per_sqm/active": &stats.ActiveMedianPricePerSqm,
+		"price_per_sqm/sold":   &stats.SoldMedianPricePerSqm,
+	}
+	for rows.Next() {
+		var metric, group string
+		var median float64
+		if err := rows.Scan(&metric, &group, &median); err != nil {
+			return fmt.Errorf("failed to scan median price: %v", err)
+		}
+		if target, ok := targets[metric+"/"+group]; ok {
+			*target = math.Round(median)
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("error iterating median prices: %v", err)
+	}
+
+	return nil
+}
+
+// Scatter sampling methods
+const (
+	SampleReservoir = "reservoir"
+	SampleGrid      = "grid"
+)
+
+// GetScatterSample returns at most limit (living area, price) points. Reservoir
+// sampling picks a uniform random subset; grid sampling keeps one point per cell
+// of a price/area grid so sparse outliers stay visible.
+func (d *Database) GetScatterSample(limit int, method string, startDate, endDate string, city string) (*models.ScatterSample, error) {
+	filtered := `
+        SELECT id, living_area, price, district, status
+        FROM properties
+        WHERE price > 0 AND living_area > 0
+        AND ` + cityFilter(city) + `
+        AND ` + propertyDateFilter
+	var args []interface{}
+	args = append(args, city, city)
+	args = append(args, propertyDateFilterArgs(startDate, endDate)...)
+
+	sample := &models.ScatterSample{Method: method}
+	err := d.db.QueryRow("SELECT COUNT(*) FROM ("+filtered+") AS filtered", args...).Scan(&sample.Total)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count scatter points: %v", err)
+	}
+
+	var query string
+	switch method {
+	case SampleGrid:
+		// Size the grid so it has roughly limit cells. Cells are numbered
+		// with integer division, which truncates on SQLite and PostgreSQL
+		// alike; casting a fraction to an integer rounds on PostgreSQL.
+		side := int(math.Ceil(math.Sqrt(float64(limit))))
+		query = `
+            WITH filtered AS (` + filtered + `),
+            bounds AS (
+                SELECT MIN(living_area) as min_area, MAX(living_area) as max_area,
+                       MIN(price) as min_price, MAX(price) as max_price
+                FROM filtered
+            ),
+            cells AS (
+                SELECT f.*,
+                    ROW_NUMBER() OVER (
+                        PARTITION BY
+                            CAST(f.living_area - b.min_area AS BIGINT) * ? / (b.max_area - b.min_area + 1),
+                            CAST(f.price - b.min_price AS BIGINT) * ? / (b.max_price - b.min_price + 1)
+                        ORDER BY f.id
+                    ) as cell_row
+                FROM filtered f, bounds b
+            )
+            SELECT id, living_area, price, district, status
+            FROM cells
+            WHERE cell_row = 1
+        `
+		args = append(args, side, side)
+	case SampleReservoir:
+		query = filtered
+	default:
+		return nil, errorf(ErrValidation, "unknown sampling method: %s", method)
+	}
+
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query scatter points: %v", err)
+	}
+	defer rows.Close()
+
+	// Reservoir sampling keeps memory bounded by limit regardless of the row count
+	points := make([]models.ScatterPoint, 0, limit)
+	seen := 0
+	for rows.Next() {
+		var id int64
+		var point models.ScatterPoint
+		var district, status sql.NullString
+		if err := rows.Scan(&id, &point.LivingArea, &point.Price, &district, &status); err != nil {
+			return nil, fmt.Errorf("failed to scan scatter point: %v", err)
+		}
+		point.District = district.String
+		point.Status = status.String
+
+		if len(points) < limit {
+			points = append(points, point)
+		} else if j := rand.Intn(seen + 1); j < limit {
+			points[j] = point
+		}
+		seen++
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating scatter points: %v", err)
+	}
+
+	sample.Points = points
+	sample.Truncated = sample.Total > len(points)
+	return sample, nil
+}
+
+// GetPostalCodeStats summarizes the properties of a full postal code, such as
+// "1012 AB", in the date range and city; with an asOf date, as they stood at
+// the end of that day
+func (d *Database) GetPostalCodeStats(postalCode string, startDate, endDate string, city string, asOf string) (*models.PostalCodeStats, error) {
+	postalCode = canonicalPostalCode(country.Default, postalCode)
+	source, args, err := propertiesAsOf(asOf)
+	if err != nil {
+		return nil, err
+	}
+	args = append(args, postalCode)
+	args = append(args, propertyListArgs(startDate, endDate, city)...)
+
+	rows, err := d.db.Query(`
+		SELECT status, price, living_area,
+		       CASE WHEN status = 'sold' AND listing_date IS NOT NULL AND `+exactSellingDate("")+`
+		            THEN `+d.dialect.DaysBetween("listing_date", "selling_date")+` END
+		FROM `+source+`
+		WHERE postal_code = ?
+		AND `+propertyListFilter(city), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query postal code properties: %v", err)
+	}
+	defer rows.Close()
+
+	stats := &models.PostalCodeStats{PostalCode: postalCode, District: models.District(postalCode)}
+	var prices, perSqm, soldPerSqm, daysToSell []float64
+	for rows.Next() {
+		var status string
+		var price, livingArea, days sql.NullFloat64
+		if err := rows.Scan(&status, &price, &livingArea, &days); err != nil {
+			return nil, fmt.Errorf("failed to scan postal code property: %v", err)
+		}
+		stats.PropertyCount++
+		if status == "sold" {
+			stats.SoldCount++
+		} else {
+			stats.ActiveCount++
+		}
+		if price.Valid && price.Float64 > 0 {
+			prices = append(prices, price.Float64)
+			if livingArea.Valid && livingArea.Float64 > 0 {
+				perSqm = append(perSqm, price.Float64/livingArea.Float64)
+				if status == "sold" {
+					soldPerSqm = append(soldPerSqm, price.Float64/livingArea.Float64)
+				}
+			}
+		}
+		if days.Valid && days.Float64 >= 0 {
+			daysToSell = append(daysToSell, days.Float64)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating postal code properties: %v", err)
+	}
+
+	for _, m := range []struct {
+		values []float64
+		target **float64
+	}{
+		{prices, &stats.MedianPrice},
+		{perSqm, &stats.MedianPricePerSqm},
+		{soldPerSqm, &stats.SoldMedianPricePerSqm},
+		{daysToSell, &stats.MedianDaysToSell},
+	} {
+		if len(m.values) > 0 {
+			v := math.Round(median(m.values))
+			*m.target = &v
+		}
+	}
+	return stats, nil
+}
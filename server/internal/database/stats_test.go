@@ -0,0 +1,63 @@
+package database
+
+import "testing"
+
+func TestGetScatterSampleGrid(t *testing.T) {
+	d := newTestDatabase(t)
+	seedBenchProperties(t, d, 20000)
+
+	// The active and sold properties of Amsterdam, which the sample draws from
+	rows, err := d.db.Query(`
+		SELECT living_area, price FROM properties
+		WHERE LOWER(city) = 'amsterdam' AND status IN ('active', 'sold')
+	`)
+	if err != nil {
+		t.Fatalf("failed to query properties: %v", err)
+	}
+	var areas, prices []int64
+	for rows.Next() {
+		var area, price int64
+		if err := rows.Scan(&area, &price); err != nil {
+			t.Fatalf("failed to scan property: %v", err)
+		}
+		areas, prices = append(areas, area), append(prices, price)
+	}
+	rows.Close()
+	minArea, maxArea, minPrice, maxPrice := areas[0], areas[0], prices[0], prices[0]
+	for i := range areas {
+		minArea, maxArea = min(minArea, areas[i]), max(maxArea, areas[i])
+		minPrice, maxPrice = min(minPrice, prices[i]), max(maxPrice, prices[i])
+	}
+
+	// A grid of 10 by 10 cells for a limit of 100
+	const limit, side = 100, 10
+	cell := func(area, price int64) [2]int64 {
+		return [2]int64{(area - minArea) * side / (maxArea - minArea + 1), (price - minPrice) * side / (maxPrice - minPrice + 1)}
+	}
+	cells := map[[2]int64]bool{}
+	for i := range areas {
+		cells[cell(areas[i], prices[i])] = true
+	}
+
+	sample, err := d.GetScatterSample(limit, SampleGrid, "", "", "Amsterdam")
+	if err != nil {
+		t.Fatalf("failed to sample: %v", err)
+	}
+	if sample.Total != len(areas) || !sample.Truncated {
+		t.Errorf("got total %d, truncated %v, want %d, true", sample.Total, sample.Truncated, len(areas))
+	}
+	if len(sample.Points) != len(cells) {
+		t.Errorf("got %d points, want one for each of the %d cells with properties", len(sample.Points), len(cells))
+	}
+	sampled := map[[2]int64]bool{}
+	for _, point := range sample.Points {
+		c := cell(int64(point.LivingArea), int64(point.Price))
+		if c[0] >= side || c[1] >= side {
+			t.Errorf("point %+v is outside the %d by %d grid", point, side, side)
+		}
+		if sampled[c] {
+			t.Errorf("point %+v is the second of cell %v", point, c)
+		}
+		sampled[c] = true
+	}
+}
@@ -0,0 +1,113 @@
+package database
+
+import (
+	"fundamental/server/internal/models"
+	"sync"
+	"time"
+)
+
+// statsCacheMaxEntries bounds the number of cached results; expired entries
+// are dropped when it is reached, and all entries when none have expired
+const statsCacheMaxEntries = 1000
+
+// statsCache keeps the results of the aggregate queries behind the dashboard.
+// An entry is served until its TTL expires or the properties change: every
+// insert, update and delete of a property is recorded in property_sync, so a
+// new highest sequence number there means a spider run or an edit touched the
+// data and all entries are dropped.
+type statsCache struct {
+	ttl time.Duration
+
+	mu      sync.Mutex
+	seq     int64
+	entries map[string]statsEntry
+}
+
+type statsEntry struct {
+	value   interface{}
+	expires time.Time
+}
+
+// newStatsCache returns a cache keeping results for ttl, or nil, which caches
+// nothing, for a zero ttl
+func newStatsCache(ttl time.Duration) *statsCache {
+	if ttl <= 0 {
+		return nil
+	}
+	return &statsCache{ttl: ttl, entries: make(map[string]statsEntry)}
+}
+
+// get returns the cached value of a key for the given change sequence number
+func (c *statsCache) get(key string, seq int64) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if seq != c.seq {
+		c.seq = seq
+		c.entries = make(map[string]statsEntry)
+		return nil, false
+	}
+	entry, ok := c.entries[key]
+	if !ok || time.Now().After(entry.expires) {
+		return nil, false
+	}
+	return entry.value, true
+}
+
+// put stores the value of a key computed at the given change sequence number
+func (c *statsCache) put(key string, seq int64, value interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if seq != c.seq {
+		// The properties changed while the value was computed
+		return
+	}
+	now := time.Now()
+	if len(c.entries) >= statsCacheMaxEntries {
+		for k, entry := range c.entries {
+			if now.After(entry.expires) {
+				delete(c.entries, k)
+			}
+		}
+		if len(c.entries) >= statsCacheMaxEntries {
+			c.entries = make(map[string]statsEntry)
+		}
+	}
+	c.entries[key] = statsEntry{value: value, expires: now.Add(c.ttl)}
+}
+
+// cachedStats returns the cached value of a key, or computes and caches it.
+// When the cache is disabled or the change log cannot be read the value is
+// computed on every call.
+func (d *Database) cachedStats(key string, compute func() (interface{}, error)) (interface{}, error) {
+	if d.stats == nil {
+		return compute()
+	}
+	var seq int64
+	if err := d.db.QueryRow(`SELECT COALESCE(MAX(seq), 0) FROM property_sync`).Scan(&seq); err != nil {
+		return compute()
+	}
+	if value, ok := d.stats.get(key, seq); ok {
+		return value, nil
+	}
+	value, err := compute()
+	if err != nil {
+		return nil, err
+	}
+	d.stats.put(key, seq, value)
+	return value, nil
+}
+
+// GetPropertyStats summarizes the active and sold properties in the date range
+// and city; with an asOf date, as they stood at the end of that day
+func (d *Database) GetPropertyStats(startDate, endDate string, city string, asOf string) (models.PropertyStats, error) {
+	key := "property_stats\x00" + startDate + "\x00" + endDate + "\x00" + city + "\x00" + asOf
+	value, err := d.cachedStats(key, func() (interface{}, error) {
+		return d.propertyStats(startDate, endDate, city, asOf)
+	})
+	if err != nil {
+		return models.PropertyStats{}, err
+	}
+	return value.(models.PropertyStats), nil
+}
@@ -0,0 +1,258 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"fundamental/server/internal/format"
+	"fundamental/server/internal/geocoding"
+	"fundamental/server/internal/models"
+	"fundamental/server/internal/streetview"
+	"fundamental/server/internal/thumbnail"
+	"time"
+)
+
+// The interfaces below are the slices of Database each consumer needs, so
+// the spider manager, the Telegram service, the task manager and the API
+// handlers can be given a fake store instead of a database file.
+
+// PropertyStore stores scraped properties and what is derived from them
+// after a spider run
+type PropertyStore interface {
+	InsertProperties(properties []map[string]interface{}) ([]map[string]interface{}, error)
+	SavePropertySnapshot(url string, payload []byte) error
+	PruneSnapshots(retentionDays, maxPerProperty int) (int64, error)
+	UpdateMissingCoordinates(geocoder *geocoding.Geocoder) error
+	UpdateStreetImages(finder *streetview.Finder, limit int) (int, error)
+	SetStreetImage(url string, image *streetview.Image) error
+	CacheThumbnail(cache *thumbnail.Cache, propertyID int64) (string, error)
+	MatchWatchComparables(soldIDs []int64) ([]models.WatchAlert, error)
+	WarmDistrictAnalysis(districts []string) error
+}
+
+// TelegramStore reads the Telegram settings and the market context added to
+// notifications, and counts the notifications against their daily quota
+type TelegramStore interface {
+	GetTelegramConfig() (*models.TelegramConfig, error)
+	GetTelegramFilters() (*models.TelegramFilters, error)
+	IsDistrictSubscribed(district string) (bool, error)
+	GetFormatSettings(owner string) (format.Settings, error)
+	GetDistrictPriceAnalysis(district string) (activeMedian float64, activeCount int, soldMedian float64, soldCount int, err error)
+	GetBidAdvice(propertyID int64) (*models.BidAdvice, error)
+	GetPreviousPrice(propertyID int64) (int, error)
+	GetMarketIndicators(city string, windowDays int) (*models.MarketIndicators, error)
+	MarketReference() models.MarketReference
+	UseNotificationQuota() (bool, error)
+}
+
+// MetroStore manages the metropolitan areas and the coordinates of their
+// cities
+type MetroStore interface {
+	GetMetropolitanAreas() ([]models.MetropolitanArea, error)
+	GetMetropolitanAreaByName(name string) (*models.MetropolitanArea, error)
+	UpdateMetropolitanArea(area models.MetropolitanArea) error
+	DeleteMetropolitanArea(name string) error
+	UpdateCityCoordinates(areaID int64, city string, lat, lng float64) error
+}
+
+// ScoreStore stores the scores of the scoring model
+type ScoreStore interface {
+	SavePropertyScores(propertyID int64, scores map[string]float64) error
+}
+
+// SpiderRunStore records the spider runs
+type SpiderRunStore interface {
+	SaveSpiderRun(run models.SpiderRun) error
+}
+
+// DistrictBoundaryStore keeps the versions of the district boundaries and
+// reads the options they are generated with
+type DistrictBoundaryStore interface {
+	SaveDistrictBoundaries(source string, validFrom time.Time, districts int, features []byte, parameters json.RawMessage) (*models.DistrictBoundaryVersion, bool, error)
+	DistrictBoundariesAt(at time.Time) (*models.DistrictBoundaryVersion, []byte, error)
+	GetSetting(name string, value interface{}) (bool, error)
+}
+
+// PDOKCacheStore caches the postal code centroids fetched from PDOK
+type PDOKCacheStore interface {
+	GetPDOKPoints(district, city string) (*models.PDOKCacheEntry, error)
+	SavePDOKPoints(entry models.PDOKCacheEntry) error
+	GetPDOKCacheStats() (*models.PDOKCacheStats, error)
+}
+
+// TaskStore persists the background tasks and their progress
+type TaskStore interface {
+	CreateTask(kind string, params json.RawMessage) (*models.Task, error)
+	ClaimNextTask() (*models.Task, error)
+	UpdateTaskProgress(id int64, progress float64, message string) error
+	FinishTask(id int64, status string, result json.RawMessage, errMsg string) error
+	CancelQueuedTask(id int64) (bool, error)
+	FailInterruptedTasks() (int64, error)
+	GetTask(id int64) (*models.Task, error)
+}
+
+// PropertyReader reads the properties, their history and the market
+// statistics the API serves
+type PropertyReader interface {
+	GetAllProperties(startDate, endDate string, city string, opts models.PropertyListOptions) (*models.PropertyList, error)
+	ForEachProperty(startDate, endDate string, city string, fn func(models.Property) error) error
+	GetPropertiesInBounds(minLat, minLng, maxLat, maxLng float64, filter models.PropertyFilter) ([]models.Property, error)
+	GetPointClusters(minLat, minLng, maxLat, maxLng float64, cells int, filter models.PropertyFilter) ([]models.PointCluster, error)
+	GetPropertiesNear(lat, lng, radiusMeters float64, limit int, filter models.PropertyFilter) ([]models.NearbyProperty, error)
+	SearchProperties(query string, limit int) ([]models.Property, string, error)
+	SearchArchivedProperties(query string, limit int) ([]models.Property, error)
+	GetPropertiesBatch(ids []int64, urls []string) (*models.PropertyBatch, error)
+	GetPropertyDetail(propertyID int64) (*models.PropertyDetail, error)
+	GetPropertyHistory(propertyID int64) ([]models.PropertyHistoryEntry, error)
+	GetPropertyListings(propertyID int64) ([]models.Property, error)
+	GetPropertySnapshots(propertyID int64, limit int) ([]models.PropertySnapshot, error)
+	GetPropertyImages(propertyID int64) ([]models.PropertyImage, error)
+	GetPropertyProvenance(propertyID int64) (map[string]models.FieldProvenance, error)
+	GetPropertyChanges(cursor int64, cities []string, limit int) (*models.SyncBatch, error)
+	GetPropertyChangeEvents(changeTypes []string, from, to time.Time, city string, limit int) ([]models.PropertyChangeEvent, error)
+	GetSyncStatus(cities []string) (*models.SyncStatus, error)
+	GetRecentSales(limit int, startDate, endDate string, city string) ([]models.Property, error)
+	GetPriceDrops(days int, minPct float64, city string, limit int) ([]models.PriceDrop, error)
+	GetBidAdvice(propertyID int64) (*models.BidAdvice, error)
+	GetGeocodingStatus() (*models.GeocodingStatus, error)
+	GetCities() ([]string, error)
+	LastModified(tables ...string) (models.TableModification, error)
+
+	GetPropertyStats(startDate, endDate string, city string, asOf string) (models.PropertyStats, error)
+	GetAreaStats(postalPrefix string, startDate, endDate string, city string, asOf string) (models.AreaStats, error)
+	GetPostalCodeStats(postalCode string, startDate, endDate string, city string, asOf string) (*models.PostalCodeStats, error)
+	GetPriceHistogram(bucketSize int, startDate, endDate string, city string, asOf string) ([]models.PriceBucket, error)
+	GetScatterSample(limit int, method string, startDate, endDate string, city string) (*models.ScatterSample, error)
+	GetMonthlyTrends(startDate, endDate, city string, byDistrict bool) ([]models.MonthlyTrend, error)
+	GetNeighborhoodStats(city string) ([]models.NeighborhoodStats, error)
+	GetEnergyLabelStats(city string, byDistrict bool, baseline string, months int) ([]models.EnergyLabelStats, error)
+	GetDelistingStats(startDate, endDate, city string) ([]models.DistrictDelistings, error)
+	GetMarketTimeSeries(metric, interval string, from, to time.Time, city string) ([]models.TimeSeriesPoint, error)
+	GetMarketIndicators(city string, windowDays int) (*models.MarketIndicators, error)
+	GetDistrictMarketIndicators(district string, windowDays int) (*models.MarketIndicators, error)
+	GetDistrictReport(district string) (*models.DistrictReport, error)
+	EstimateDistrictPrice(district string, livingArea float64) (estimate int, comparables int, err error)
+	GetAgentStats(id int64) (*models.AgentStats, error)
+	ListAgentStats() ([]models.AgentStats, error)
+	GetDataQuality() (*models.DataQuality, error)
+
+	CountExportProperties(filter models.PropertyFilter) (int, error)
+	IterateProperties(ctx context.Context, filter models.PropertyFilter) (*PropertyIterator, error)
+	CountPropertiesByCity(city string) (int, error)
+	IteratePropertiesByCity(ctx context.Context, city string) (*PropertyIterator, error)
+	IterateHistoryByCity(ctx context.Context, city string) (*HistoryIterator, error)
+	CountView(ctx context.Context, name string) (int, error)
+	IterateView(ctx context.Context, name string) (*ViewIterator, error)
+	ReadOnlyQuery(ctx context.Context, query string, maxRows int, timeout time.Duration) (*models.QueryResult, error)
+}
+
+// APIStore is what the API handlers read and write: the properties, what
+// the spiders and the Telegram service they start store, and the records the
+// API manages
+type APIStore interface {
+	PropertyReader
+	PropertyStore
+	TelegramStore
+	ScoreStore
+	SpiderRunStore
+	DistrictBoundaryStore
+	Dialect() Dialect
+
+	// Property edits and maintenance
+	UpdatePropertyFields(propertyID int64, req models.FieldUpdateRequest) (*models.FieldUpdateResult, error)
+	ResetFieldProvenance(propertyID int64, field string) error
+	NormalizeProperties(apply bool, maxChanges int) (*models.NormalizationReport, error)
+	GeocodeMissingCoordinates(ctx context.Context, geocoder *geocoding.Geocoder, progress func(done, total int)) error
+	ArchiveStaleProperties(olderThanDays int) (int64, error)
+	ApplyRetention(rules map[string]int, dryRun bool) (*models.RetentionReport, error)
+	ListAuditLog(filter models.AuditFilter) ([]models.AuditEntry, error)
+	ListDistrictBoundaryVersions() ([]models.DistrictBoundaryVersion, error)
+	GetDistrictBoundaries(id int64) (*models.DistrictBoundaryVersion, []byte, error)
+
+	// Validation of scraped items
+	ListValidationRules() ([]models.ValidationRule, error)
+	CreateValidationRule(req models.ValidationRuleRequest) (*models.ValidationRule, error)
+	UpdateValidationRule(id int64, req models.ValidationRuleRequest) (*models.ValidationRule, error)
+	DeleteValidationRule(id int64) error
+	ListRejectedItems(source string, limit, offset int) (*models.RejectedItemList, error)
+	ApproveRejectedItem(id int64, fields map[string]interface{}) (*models.ApprovedItem, error)
+	DeleteRejectedItem(id int64) (bool, error)
+
+	// Tags, saved searches and watchlists
+	ListTags() ([]models.Tag, error)
+	CreateTag(req models.TagRequest) (*models.Tag, error)
+	UpdateTag(id int64, req models.TagRequest) (*models.Tag, error)
+	DeleteTag(id int64) (bool, error)
+	UpdatePropertyTags(req models.PropertyTagsRequest) (*models.PropertyTagsResult, error)
+	ListSavedSearches() ([]models.SavedSearch, error)
+	GetSavedSearch(id int64) (*models.SavedSearch, error)
+	CreateSavedSearch(req models.SavedSearchRequest) (*models.SavedSearch, error)
+	UpdateSavedSearch(id int64, req models.SavedSearchRequest) (*models.SavedSearch, error)
+	DeleteSavedSearch(id int64) (bool, error)
+	BacktestSearch(criteria models.SearchCriteria, weeks int) (*models.SearchBacktest, error)
+	ListWatchlists() ([]models.Watchlist, error)
+	GetWatchlist(id int64) (*models.Watchlist, error)
+	CreateWatchlist(req models.WatchlistRequest) (*models.Watchlist, error)
+	UpdateWatchlist(id int64, req models.WatchlistRequest) (*models.Watchlist, error)
+	DeleteWatchlist(id int64) (bool, error)
+	AddWatchlistProperty(watchlistID, propertyID int64) (bool, error)
+	RemoveWatchlistProperty(watchlistID, propertyID int64) (bool, error)
+	ListWatchAlerts(watchlistID int64, limit int) ([]models.WatchAlert, error)
+
+	// Telegram settings and district subscriptions
+	UpdateTelegramConfig(config *models.TelegramConfigRequest) error
+	UpdateTelegramFilters(filters *models.TelegramFilters) error
+	ListDistrictSubscriptions() ([]models.DistrictSubscription, error)
+	GetDistrictSubscription(district string) (*models.DistrictSubscription, error)
+	SubscribeDistrict(district string) (bool, error)
+	UnsubscribeDistrict(district string) (bool, error)
+
+	// Settings, preferences and shared views
+	SaveSetting(name string, value interface{}) error
+	GetPreferences(owner, namespace string) ([]models.Preference, error)
+	CountPreferences(owner string) (int, error)
+	HasPreference(owner, namespace, key string) (bool, error)
+	SetPreference(owner, namespace, key string, value json.RawMessage) error
+	DeletePreference(owner, namespace, key string) error
+	CreateSharedView(definition json.RawMessage) (*models.SharedView, error)
+	ResolveSharedView(token string) (*models.SharedView, error)
+	GetMetropolitanAreas() ([]models.MetropolitanArea, error)
+
+	// Tokens, spider runs, tasks, change data capture, workspaces and quotas
+	ListAPITokens() ([]models.APIToken, error)
+	CreateAPIToken(name string, scopes []string, workspace string, expiresAt *time.Time) (*models.CreatedAPIToken, error)
+	RevokeAPIToken(id int64) (bool, error)
+	ListSpiderRuns(place, spiderType string, limit int) ([]models.SpiderRun, error)
+	ListTasks(kind, status string, limit int) ([]models.Task, error)
+	GetTask(id int64) (*models.Task, error)
+	GetCDCSink() (*models.CDCSink, error)
+	GetWorkspace(slug string) (*models.Workspace, error)
+	GetQuotas() (models.WorkspaceQuotas, error)
+	GetQuotaUsage() (*models.QuotaUsage, error)
+	RecordUsage(resource string, n int) error
+}
+
+// HealthStore answers the readiness probe
+type HealthStore interface {
+	Ping() error
+	AppliedSchemaVersion() (int, error)
+	Dialect() Dialect
+}
+
+// TokenStore resolves the API tokens of requests
+type TokenStore interface {
+	AuthenticateAPIToken(secret string) (*models.APIToken, error)
+}
+
+var (
+	_ PropertyStore         = (*Database)(nil)
+	_ TelegramStore         = (*Database)(nil)
+	_ MetroStore            = (*Database)(nil)
+	_ ScoreStore            = (*Database)(nil)
+	_ SpiderRunStore        = (*Database)(nil)
+	_ DistrictBoundaryStore = (*Database)(nil)
+	_ PDOKCacheStore        = (*Database)(nil)
+	_ TaskStore             = (*Database)(nil)
+	_ APIStore              = (*Database)(nil)
+	_ HealthStore           = (*Database)(nil)
+	_ TokenStore            = (*Database)(nil)
+)
@@ -0,0 +1,86 @@
+package database
+
+import (
+	"fmt"
+	"fundamental/server/internal/streetview"
+)
+
+// streetImageRefreshDays is how long the photo of an active listing is kept
+// before it is looked up again; Mapillary photo URLs expire
+const streetImageRefreshDays = 14
+
+// UpdateStreetImages looks up the street-level photo nearest to up to limit
+// geocoded properties that have not been looked up yet, or that are active and
+// were looked up more than streetImageRefreshDays days ago. Properties without
+// a photo nearby are marked as looked up as well. It returns the number of
+// properties that got a photo.
+func (d *Database) UpdateStreetImages(finder *streetview.Finder, limit int) (int, error) {
+	rows, err := d.db.Query(`
+		SELECT id, latitude, longitude
+		FROM properties
+		WHERE latitude IS NOT NULL AND longitude IS NOT NULL
+		AND (street_image_checked_at IS NULL
+			OR (status IN ('active', 'republished') AND street_image_checked_at < `+d.dialect.TimestampOffset()+`))
+		ORDER BY street_image_checked_at IS NOT NULL, id DESC
+		LIMIT ?
+	`, fmt.Sprintf("-%d days", streetImageRefreshDays), limit)
+	if err != nil {
+		return 0, fmt.Errorf("failed to find properties without street image: %v", err)
+	}
+
+	// Read all first; the lookups are slow and must not hold the result set open
+	type pendingProperty struct {
+		id       int64
+		lat, lng float64
+	}
+	var pending []pendingProperty
+	for rows.Next() {
+		var p pendingProperty
+		if err := rows.Scan(&p.id, &p.lat, &p.lng); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan property: %v", err)
+		}
+		pending = append(pending, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to find properties without street image: %v", err)
+	}
+
+	var found int
+	for _, p := range pending {
+		image, err := finder.Nearest(p.lat, p.lng)
+		if err != nil {
+			// Leave the property for the next run
+			return found, fmt.Errorf("failed to look up street image of property %d: %v", p.id, err)
+		}
+		if err := d.setStreetImage("id = ?", p.id, image); err != nil {
+			return found, err
+		}
+		if image != nil {
+			found++
+		}
+	}
+	return found, nil
+}
+
+// SetStreetImage stores the street-level photo found for the property with a
+// listing URL; image is nil when there is none nearby
+func (d *Database) SetStreetImage(url string, image *streetview.Image) error {
+	return d.setStreetImage("url = ?", url, image)
+}
+
+func (d *Database) setStreetImage(condition string, key interface{}, image *streetview.Image) error {
+	var imageURL, link interface{}
+	if image != nil {
+		imageURL, link = image.URL, image.Link
+	}
+	_, err := d.db.Exec(`
+		UPDATE properties
+		SET street_image_url = ?, street_image_link = ?, street_image_checked_at = CURRENT_TIMESTAMP
+		WHERE `+condition, imageURL, link, key)
+	if err != nil {
+		return fmt.Errorf("failed to store street image: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"fundamental/server/internal/models"
+	"math"
+)
+
+// ListDistrictSubscriptions returns the subscribed districts in order with a
+// summary of their listings and sales
+func (d *Database) ListDistrictSubscriptions() ([]models.DistrictSubscription, error) {
+	return d.districtSubscriptions("")
+}
+
+// GetDistrictSubscription returns the subscription to a district, or nil if
+// the district is not subscribed
+func (d *Database) GetDistrictSubscription(district string) (*models.DistrictSubscription, error) {
+	subscriptions, err := d.districtSubscriptions(district)
+	if err != nil || len(subscriptions) == 0 {
+		return nil, err
+	}
+	return &subscriptions[0], nil
+}
+
+// SubscribeDistrict subscribes to a district. Returns false if it already was.
+func (d *Database) SubscribeDistrict(district string) (bool, error) {
+	result, err := d.db.Exec(`
+		INSERT INTO district_subscriptions (district)
+		VALUES (?)
+		ON CONFLICT (district) DO NOTHING
+	`, district)
+	if err != nil {
+		return false, fmt.Errorf("failed to subscribe to district: %v", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %v", err)
+	}
+	return n > 0, nil
+}
+
+// UnsubscribeDistrict removes the subscription to a district. Returns false if
+// it was not subscribed.
+func (d *Database) UnsubscribeDistrict(district string) (bool, error) {
+	result, err := d.db.Exec("DELETE FROM district_subscriptions WHERE district = ?", district)
+	if err != nil {
+		return false, fmt.Errorf("failed to unsubscribe from district: %v", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %v", err)
+	}
+	return n > 0, nil
+}
+
+// IsDistrictSubscribed reports whether new listings in a district are notified
+// regardless of the notification filters
+func (d *Database) IsDistrictSubscribed(district string) (bool, error) {
+	if district == "" {
+		return false, nil
+	}
+	var count int
+	if err := d.db.QueryRow("SELECT COUNT(*) FROM district_subscriptions WHERE district = ?", district).Scan(&count); err != nil {
+		return false, fmt.Errorf("failed to look up district subscription: %v", err)
+	}
+	return count > 0, nil
+}
+
+// districtSubscriptions returns one subscription, or all when district is ""
+func (d *Database) districtSubscriptions(district string) ([]models.DistrictSubscription, error) {
+	query, args := "SELECT district, created_at FROM district_subscriptions ORDER BY district", []interface{}{}
+	if district != "" {
+		query, args = "SELECT district, created_at FROM district_subscriptions WHERE district = ?", []interface{}{district}
+	}
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query district subscriptions: %v", err)
+	}
+	subscriptions := []models.DistrictSubscription{}
+	for rows.Next() {
+		var s models.DistrictSubscription
+		if err := rows.Scan(&s.District, &s.SubscribedAt); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan district subscription: %v", err)
+		}
+		subscriptions = append(subscriptions, s)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating district subscriptions: %v", err)
+	}
+
+	for i := range subscriptions {
+		if err := d.addDistrictSummary(&subscriptions[i]); err != nil {
+			return nil, err
+		}
+	}
+	return subscriptions, nil
+}
+
+// addDistrictSummary fills in the listing and sales summary of a subscription
+func (d *Database) addDistrictSummary(s *models.DistrictSubscription) error {
+	rows, err := d.db.Query(`
+		SELECT price, living_area, created_at >= `+d.dialect.TimestampOffset()+`
+		FROM properties
+		WHERE district = ?
+		AND status IN ('active', 'republished')
+	`, "-30 days", s.District)
+	if err != nil {
+		return fmt.Errorf("failed to query district listings: %v", err)
+	}
+	var asking []float64
+	for rows.Next() {
+		var price, livingArea sql.NullFloat64
+		var recent sql.NullBool
+		if err := rows.Scan(&price, &livingArea, &recent); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan district listing: %v", err)
+		}
+		s.ActiveListings++
+		if recent.Bool {
+			s.NewLast30Days++
+		}
+		if price.Float64 > 0 && livingArea.Float64 > 0 {
+			asking = append(asking, price.Float64/livingArea.Float64)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating district listings: %v", err)
+	}
+	s.MedianAskingPerSqm = roundedMedian(asking)
+
+	sales, err := d.districtSales(s.District, 0)
+	if err != nil {
+		return err
+	}
+	var sold, days []float64
+	for _, sale := range sales {
+		sold = append(sold, sale.price/sale.livingArea)
+		if sale.daysToSell.Valid && sale.daysToSell.Float64 >= 0 {
+			days = append(days, sale.daysToSell.Float64)
+		}
+	}
+	s.SoldLastYear = len(sales)
+	s.MedianSoldPerSqm = roundedMedian(sold)
+	s.MedianDaysToSell = roundedMedian(days)
+	return nil
+}
+
+// roundedMedian returns the median of values rounded to a whole number, or nil
+// without values
+func roundedMedian(values []float64) *float64 {
+	if len(values) == 0 {
+		return nil
+	}
+	m := math.Round(median(values))
+	return &m
+}
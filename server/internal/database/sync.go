@@ -0,0 +1,274 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"fundamental/server/internal/models"
+	"strings"
+)
+
+// syncedColumns are the property columns whose changes are sent to sync
+// clients. Scrape bookkeeping such as scraped_at is left out, so a scrape that
+// finds a listing unchanged does not resend it.
+var syncedColumns = []string{
+	"url", "street", "neighborhood", "property_type", "city", "postal_code", "district",
+	"price", "year_built", "living_area", "num_rooms", "status",
+	"listing_date", "selling_date", "latitude", "longitude", "energy_label",
+}
+
+// syncedColumnsChanged returns a condition that is true when an update changed
+// a synced column, using the dialect's null-safe comparison
+func syncedColumnsChanged(distinct string) string {
+	conditions := make([]string, len(syncedColumns))
+	for i, column := range syncedColumns {
+		conditions[i] = fmt.Sprintf("old.%[1]s %[2]s new.%[1]s", column, distinct)
+	}
+	return strings.Join(conditions, " OR ")
+}
+
+// syncUpsert records a change of a property in property_sync; row is new or old
+func syncUpsert(row, seq, deleted, previousCity string) string {
+	return fmt.Sprintf(`INSERT INTO property_sync (property_id, seq, city, previous_city, deleted, changed_at)
+		VALUES (%[1]s.id, %[2]s, %[1]s.city, %[4]s, %[3]s, CURRENT_TIMESTAMP)
+		ON CONFLICT (property_id) DO UPDATE SET
+			seq = excluded.seq, city = excluded.city, previous_city = excluded.previous_city,
+			deleted = excluded.deleted, changed_at = excluded.changed_at;`, row, seq, deleted, previousCity)
+}
+
+// sqliteSyncSeq numbers changes in SQLite, which has a single writer
+const sqliteSyncSeq = "(SELECT COALESCE(MAX(seq), 0) + 1 FROM property_sync)"
+
+// sqliteSyncTriggers record every property change in property_sync
+var sqliteSyncTriggers = []string{
+	`CREATE TRIGGER IF NOT EXISTS property_sync_ai AFTER INSERT ON properties BEGIN
+		` + syncUpsert("new", sqliteSyncSeq, "0", "NULL") + `
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS property_sync_au AFTER UPDATE ON properties
+	WHEN ` + syncedColumnsChanged("IS NOT") + ` BEGIN
+		` + syncUpsert("new", sqliteSyncSeq, "0", "old.city") + `
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS property_sync_ad AFTER DELETE ON properties BEGIN
+		` + syncUpsert("old", sqliteSyncSeq, "1", "NULL") + `
+	END`,
+}
+
+// postgresSyncTriggers record every property change in property_sync. A
+// sequence numbers the changes. Numbers are taken before commit, so with
+// concurrent writers a change can become visible after a higher number.
+var postgresSyncTriggers = []string{
+	"CREATE SEQUENCE IF NOT EXISTS property_sync_seq",
+	"SELECT setval('property_sync_seq', GREATEST((SELECT MAX(seq) FROM property_sync), 1))",
+	`CREATE OR REPLACE FUNCTION property_sync_record() RETURNS trigger AS $$
+	BEGIN
+		IF TG_OP = 'DELETE' THEN
+			` + syncUpsert("old", "nextval('property_sync_seq')", "1", "NULL") + `
+			RETURN old;
+		END IF;
+		IF TG_OP = 'UPDATE' THEN
+			IF NOT (` + syncedColumnsChanged("IS DISTINCT FROM") + `) THEN
+				RETURN new;
+			END IF;
+		END IF;
+		` + syncUpsert("new", "nextval('property_sync_seq')", "0",
+		"CASE WHEN TG_OP = 'UPDATE' THEN old.city END") + `
+		RETURN new;
+	END
+	$$ LANGUAGE plpgsql`,
+	`CREATE TRIGGER property_sync AFTER INSERT OR UPDATE OR DELETE ON properties
+	FOR EACH ROW EXECUTE FUNCTION property_sync_record()`,
+}
+
+// createPropertySync creates the change log used by sync clients, numbers the
+// existing properties as the first changes and installs the triggers that keep
+// it up to date
+func createPropertySync(tx *sqlTx) error {
+	err := execAll(tx,
+		`CREATE TABLE IF NOT EXISTS property_sync (
+			property_id INTEGER PRIMARY KEY,
+			seq INTEGER NOT NULL,
+			city TEXT,
+			previous_city TEXT,
+			deleted INTEGER NOT NULL DEFAULT 0,
+			changed_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`,
+		"CREATE INDEX IF NOT EXISTS idx_property_sync_seq ON property_sync(seq)",
+		`INSERT INTO property_sync (property_id, seq, city)
+		SELECT id, ROW_NUMBER() OVER (ORDER BY id), city FROM properties`,
+	)
+	if err != nil {
+		return err
+	}
+
+	triggers := sqliteSyncTriggers
+	if tx.dialect.Name() == DriverPostgres {
+		triggers = postgresSyncTriggers
+	}
+	for _, stmt := range triggers {
+		if _, err := tx.Exec(stmt); err != nil {
+			return fmt.Errorf("failed to create sync trigger: %v", err)
+		}
+	}
+	return nil
+}
+
+// dropPropertySync removes the change log and its triggers
+func dropPropertySync(tx *sqlTx) error {
+	statements := []string{
+		"DROP TRIGGER IF EXISTS property_sync_ai",
+		"DROP TRIGGER IF EXISTS property_sync_au",
+		"DROP TRIGGER IF EXISTS property_sync_ad",
+	}
+	if tx.dialect.Name() == DriverPostgres {
+		statements = []string{
+			"DROP TRIGGER IF EXISTS property_sync ON properties",
+			"DROP FUNCTION IF EXISTS property_sync_record()",
+			"DROP SEQUENCE IF EXISTS property_sync_seq",
+		}
+	}
+	statements = append(statements, "DROP TABLE IF EXISTS property_sync")
+	for _, stmt := range statements {
+		if _, err := tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// syncCityFilter restricts property_sync rows to properties that are or were
+// in one of the cities; bind it with syncCityArgs
+func syncCityFilter(cities []string) string {
+	if len(cities) == 0 {
+		return "1 = 1"
+	}
+	placeholders := "?" + strings.Repeat(", ?", len(cities)-1)
+	return "(LOWER(city) IN (" + placeholders + ") OR LOWER(previous_city) IN (" + placeholders + "))"
+}
+
+func syncCityArgs(cities []string) []interface{} {
+	var args []interface{}
+	for range 2 {
+		for _, city := range cities {
+			args = append(args, strings.ToLower(city))
+		}
+	}
+	return args
+}
+
+// GetSyncStatus returns the newest change cursor and the number of properties
+// in the cities (all cities when empty), to size an initial download
+func (d *Database) GetSyncStatus(cities []string) (*models.SyncStatus, error) {
+	status := &models.SyncStatus{}
+	var cursor sql.NullInt64
+	err := d.db.QueryRow("SELECT MAX(seq) FROM property_sync").Scan(&cursor)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sync cursor: %v", err)
+	}
+	status.Cursor = cursor.Int64
+
+	query := "SELECT COUNT(*) FROM property_sync WHERE deleted = 0"
+	var args []interface{}
+	if len(cities) > 0 {
+		query += " AND LOWER(city) IN (?" + strings.Repeat(", ?", len(cities)-1) + ")"
+		for _, city := range cities {
+			args = append(args, strings.ToLower(city))
+		}
+	}
+	if err := d.db.QueryRow(query, args...).Scan(&status.Properties); err != nil {
+		return nil, fmt.Errorf("failed to count synced properties: %v", err)
+	}
+	return status, nil
+}
+
+// GetPropertyChanges returns up to limit property changes after cursor, oldest
+// first, for the properties in the cities (all cities when empty). Changed
+// properties are returned in full; deleted properties and properties that
+// moved to a city outside the selection are returned as tombstones. Cursor 0
+// starts an initial download, which leaves out tombstones.
+//
+// Properties are read after the change log, so a property can be newer than
+// the returned cursor and be sent again with the next batch; clients apply
+// changes as upserts.
+func (d *Database) GetPropertyChanges(cursor int64, cities []string, limit int) (*models.SyncBatch, error) {
+	query := `
+		SELECT seq, property_id, deleted, COALESCE(LOWER(city), '')
+		FROM property_sync
+		WHERE seq > ?
+		AND ` + syncCityFilter(cities) + `
+		ORDER BY seq
+		LIMIT ?`
+	args := []interface{}{cursor}
+	args = append(args, syncCityArgs(cities)...)
+	args = append(args, limit+1)
+
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query property changes: %v", err)
+	}
+	defer rows.Close()
+
+	selected := make(map[string]bool, len(cities))
+	for _, city := range cities {
+		selected[strings.ToLower(city)] = true
+	}
+
+	batch := &models.SyncBatch{
+		Cursor:     cursor,
+		Properties: []models.Property{},
+		Deleted:    []int64{},
+	}
+	var changed []int64
+	read := 0
+	for rows.Next() {
+		if read == limit {
+			batch.HasMore = true
+			break
+		}
+		read++
+		var seq, id int64
+		var deleted int
+		var city string
+		if err := rows.Scan(&seq, &id, &deleted, &city); err != nil {
+			return nil, fmt.Errorf("failed to scan property change: %v", err)
+		}
+		batch.Cursor = seq
+		if deleted == 1 || (len(cities) > 0 && !selected[city]) {
+			if cursor > 0 {
+				batch.Deleted = append(batch.Deleted, id)
+			}
+			continue
+		}
+		changed = append(changed, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read property changes: %v", err)
+	}
+	rows.Close()
+
+	if len(changed) == 0 {
+		return batch, nil
+	}
+
+	found := make(map[int64]models.Property, len(changed))
+	placeholders := "?" + strings.Repeat(", ?", len(changed)-1)
+	ids := make([]interface{}, len(changed))
+	for i, id := range changed {
+		ids[i] = id
+	}
+	err = d.forEachProperty("SELECT "+propertyColumns+" FROM properties WHERE id IN ("+placeholders+")", ids,
+		func(p models.Property) error {
+			found[p.ID] = p
+			return nil
+		})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get changed properties: %v", err)
+	}
+	for _, id := range changed {
+		if p, ok := found[id]; ok {
+			batch.Properties = append(batch.Properties, p)
+		} else {
+			// Deleted after the change log was read
+			batch.Deleted = append(batch.Deleted, id)
+		}
+	}
+	return batch, nil
+}
@@ -0,0 +1,315 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"fundamental/server/internal/models"
+	"strings"
+)
+
+// ErrTagExists is returned when a tag is created or renamed to the name of
+// another tag
+var ErrTagExists = newError(ErrConflict, "a tag with this name already exists")
+
+// ErrUnknownTag is returned, wrapped with the name, when a bulk update names
+// a tag that does not exist
+var ErrUnknownTag = newError(ErrValidation, "unknown tag")
+
+// tagColumns are the columns read by scanTag
+const tagColumns = `t.id, t.name, COALESCE(t.color, ''), t.created_at,
+			(SELECT COUNT(*) FROM property_tags pt WHERE pt.tag_id = t.id)`
+
+func scanTag(row rowScanner) (*models.Tag, error) {
+	var tag models.Tag
+	if err := row.Scan(&tag.ID, &tag.Name, &tag.Color, &tag.CreatedAt, &tag.PropertyCount); err != nil {
+		return nil, err
+	}
+	return &tag, nil
+}
+
+// tagFilter returns a condition restricting a properties query to the
+// properties with all of the given tags, matched case-insensitively, with its
+// arguments. Without tags the condition is always true.
+func tagFilter(tags []string) (string, []interface{}) {
+	if len(tags) == 0 {
+		return "1 = 1", nil
+	}
+	conditions := make([]string, len(tags))
+	args := make([]interface{}, len(tags))
+	for i, tag := range tags {
+		conditions[i] = `id IN (
+            SELECT pt.property_id FROM property_tags pt
+            JOIN tags t ON t.id = pt.tag_id
+            WHERE LOWER(t.name) = LOWER(?))`
+		args[i] = tag
+	}
+	return "(" + strings.Join(conditions, " AND ") + ")", args
+}
+
+// propertyTagNames returns the tag names of the given properties, or of all
+// tagged properties when ids is nil, by property id
+func (d *Database) propertyTagNames(ids []int64) (map[int64][]string, error) {
+	names := make(map[int64][]string)
+	query := `
+		SELECT pt.property_id, t.name
+		FROM property_tags pt
+		JOIN tags t ON t.id = pt.tag_id`
+	batches := [][]int64{nil}
+	if ids != nil {
+		batches = batches[:0]
+		for start := 0; start < len(ids); start += archiveBatchSize {
+			batches = append(batches, ids[start:min(start+archiveBatchSize, len(ids))])
+		}
+	}
+
+	for _, batch := range batches {
+		var args []interface{}
+		condition := ""
+		if batch != nil {
+			args = make([]interface{}, len(batch))
+			for i, id := range batch {
+				args[i] = id
+			}
+			condition = " WHERE pt.property_id IN (?" + strings.Repeat(", ?", len(batch)-1) + ")"
+		}
+		rows, err := d.db.Query(query+condition+" ORDER BY LOWER(t.name)", args...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query property tags: %v", err)
+		}
+		for rows.Next() {
+			var propertyID int64
+			var name string
+			if err := rows.Scan(&propertyID, &name); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("failed to scan property tag: %v", err)
+			}
+			names[propertyID] = append(names[propertyID], name)
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, fmt.Errorf("error iterating property tags: %v", err)
+		}
+	}
+	return names, nil
+}
+
+// setPropertyTags fills in the tags of a page of properties
+func (d *Database) setPropertyTags(properties []models.Property) error {
+	if len(properties) == 0 {
+		return nil
+	}
+	ids := make([]int64, len(properties))
+	for i, p := range properties {
+		ids[i] = p.ID
+	}
+	names, err := d.propertyTagNames(ids)
+	if err != nil {
+		return err
+	}
+	for i := range properties {
+		properties[i].Tags = names[properties[i].ID]
+	}
+	return nil
+}
+
+// ListTags returns all tags with their number of properties, by name
+func (d *Database) ListTags() ([]models.Tag, error) {
+	rows, err := d.db.Query("SELECT " + tagColumns + " FROM tags t ORDER BY LOWER(t.name)")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tags: %v", err)
+	}
+	defer rows.Close()
+
+	tags := []models.Tag{}
+	for rows.Next() {
+		tag, err := scanTag(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan tag: %v", err)
+		}
+		tags = append(tags, *tag)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating tags: %v", err)
+	}
+	return tags, nil
+}
+
+// GetTag returns a tag, or nil if it does not exist
+func (d *Database) GetTag(id int64) (*models.Tag, error) {
+	tag, err := scanTag(d.db.QueryRow("SELECT "+tagColumns+" FROM tags t WHERE t.id = ?", id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tag: %v", err)
+	}
+	return tag, nil
+}
+
+// tagNameTaken reports whether a tag other than exceptID has the name,
+// regardless of case
+func (d *Database) tagNameTaken(name string, exceptID int64) (bool, error) {
+	var count int
+	err := d.db.QueryRow("SELECT COUNT(*) FROM tags WHERE LOWER(name) = LOWER(?) AND id <> ?", name, exceptID).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("failed to look up tag name: %v", err)
+	}
+	return count > 0, nil
+}
+
+// CreateTag stores a new tag. Returns ErrTagExists when the name is taken.
+func (d *Database) CreateTag(req models.TagRequest) (*models.Tag, error) {
+	if taken, err := d.tagNameTaken(req.Name, 0); err != nil {
+		return nil, err
+	} else if taken {
+		return nil, ErrTagExists
+	}
+
+	var id int64
+	err := d.db.QueryRow(`
+		INSERT INTO tags (name, color)
+		VALUES (?, NULLIF(?, ''))
+		RETURNING id
+	`, req.Name, req.Color).Scan(&id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert tag: %v", err)
+	}
+	return d.GetTag(id)
+}
+
+// UpdateTag replaces the name and color of a tag. Returns nil if it does not
+// exist and ErrTagExists when the name is taken by another tag.
+func (d *Database) UpdateTag(id int64, req models.TagRequest) (*models.Tag, error) {
+	if taken, err := d.tagNameTaken(req.Name, id); err != nil {
+		return nil, err
+	} else if taken {
+		return nil, ErrTagExists
+	}
+
+	result, err := d.db.Exec("UPDATE tags SET name = ?, color = NULLIF(?, '') WHERE id = ?", req.Name, req.Color, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update tag: %v", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rows affected: %v", err)
+	}
+	if n == 0 {
+		return nil, nil
+	}
+	return d.GetTag(id)
+}
+
+// DeleteTag removes a tag from all properties and deletes it. Returns false
+// if it does not exist.
+func (d *Database) DeleteTag(id int64) (bool, error) {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM property_tags WHERE tag_id = ?", id); err != nil {
+		return false, fmt.Errorf("failed to delete property tags: %v", err)
+	}
+	result, err := tx.Exec("DELETE FROM tags WHERE id = ?", id)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete tag: %v", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return n > 0, nil
+}
+
+// UpdatePropertyTags adds and removes tags, by name, on many properties at
+// once; adding a tag a property already has is a no-op. Requested properties
+// that do not exist are reported and skipped. Returns an error wrapping
+// ErrUnknownTag, and changes nothing, when a named tag does not exist.
+func (d *Database) UpdatePropertyTags(req models.PropertyTagsRequest) (*models.PropertyTagsResult, error) {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	add, err := tagIDs(tx, req.Add)
+	if err != nil {
+		return nil, err
+	}
+	remove, err := tagIDs(tx, req.Remove)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &models.PropertyTagsResult{MissingIDs: []int64{}}
+	existing := make(map[int64]bool, len(req.PropertyIDs))
+	for start := 0; start < len(req.PropertyIDs); start += archiveBatchSize {
+		batch := req.PropertyIDs[start:min(start+archiveBatchSize, len(req.PropertyIDs))]
+		args := make([]interface{}, len(batch))
+		for i, id := range batch {
+			args[i] = id
+		}
+		ids, err := queryIDs(tx, "SELECT id FROM properties WHERE id IN (?"+strings.Repeat(", ?", len(batch)-1)+")", args...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up properties: %v", err)
+		}
+		for _, id := range ids {
+			existing[id.(int64)] = true
+		}
+	}
+
+	seen := make(map[int64]bool, len(req.PropertyIDs))
+	for _, propertyID := range req.PropertyIDs {
+		if seen[propertyID] {
+			continue
+		}
+		seen[propertyID] = true
+		if !existing[propertyID] {
+			result.MissingIDs = append(result.MissingIDs, propertyID)
+			continue
+		}
+		for _, tagID := range add {
+			_, err := tx.Exec(`
+				INSERT INTO property_tags (tag_id, property_id) VALUES (?, ?)
+				ON CONFLICT (tag_id, property_id) DO NOTHING
+			`, tagID, propertyID)
+			if err != nil {
+				return nil, fmt.Errorf("failed to tag property: %v", err)
+			}
+		}
+		for _, tagID := range remove {
+			if _, err := tx.Exec("DELETE FROM property_tags WHERE tag_id = ? AND property_id = ?", tagID, propertyID); err != nil {
+				return nil, fmt.Errorf("failed to untag property: %v", err)
+			}
+		}
+		result.Updated++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return result, nil
+}
+
+// tagIDs resolves tag names to ids, case-insensitively
+func tagIDs(tx *sqlTx, names []string) ([]int64, error) {
+	ids := make([]int64, 0, len(names))
+	for _, name := range names {
+		var id int64
+		err := tx.QueryRow("SELECT id FROM tags WHERE LOWER(name) = LOWER(?)", name).Scan(&id)
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownTag, name)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up tag: %v", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
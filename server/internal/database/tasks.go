@@ -0,0 +1,185 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"fundamental/server/internal/models"
+	"strings"
+	"time"
+)
+
+const taskColumns = `id, kind, status, params, progress, message, result, error, created_at, started_at, finished_at`
+
+func scanTask(row rowScanner) (*models.Task, error) {
+	var task models.Task
+	var params, message, result, errMsg sql.NullString
+	var startedAt, finishedAt sql.NullTime
+	if err := row.Scan(&task.ID, &task.Kind, &task.Status, &params, &task.Progress,
+		&message, &result, &errMsg, &task.CreatedAt, &startedAt, &finishedAt); err != nil {
+		return nil, err
+	}
+
+	if params.Valid && params.String != "" {
+		task.Params = json.RawMessage(params.String)
+	}
+	if result.Valid && result.String != "" {
+		task.Result = json.RawMessage(result.String)
+	}
+	task.Message = message.String
+	task.Error = errMsg.String
+	if startedAt.Valid {
+		task.StartedAt = &startedAt.Time
+	}
+	if finishedAt.Valid {
+		task.FinishedAt = &finishedAt.Time
+	}
+	return &task, nil
+}
+
+// nullJSON stores an empty JSON value as NULL
+func nullJSON(value json.RawMessage) sql.NullString {
+	s := strings.TrimSpace(string(value))
+	return sql.NullString{String: s, Valid: s != "" && s != "null"}
+}
+
+// CreateTask queues a task
+func (d *Database) CreateTask(kind string, params json.RawMessage) (*models.Task, error) {
+	row := d.db.QueryRow(`
+		INSERT INTO tasks (kind, status, params, created_at)
+		VALUES (?, ?, ?, ?)
+		RETURNING `+taskColumns,
+		kind, models.TaskQueued, nullJSON(params), time.Now().UTC())
+	task, err := scanTask(row)
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert task: %v", err)
+	}
+	return task, nil
+}
+
+// GetTask returns a task, or nil if it does not exist
+func (d *Database) GetTask(id int64) (*models.Task, error) {
+	task, err := scanTask(d.db.QueryRow("SELECT "+taskColumns+" FROM tasks WHERE id = ?", id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get task: %v", err)
+	}
+	return task, nil
+}
+
+// ListTasks returns the newest tasks, optionally only those of one kind or
+// status
+func (d *Database) ListTasks(kind, status string, limit int) ([]models.Task, error) {
+	query := "SELECT " + taskColumns + " FROM tasks WHERE 1=1"
+	var args []interface{}
+	if kind != "" {
+		query += " AND kind = ?"
+		args = append(args, kind)
+	}
+	if status != "" {
+		query += " AND status = ?"
+		args = append(args, status)
+	}
+	query += " ORDER BY id DESC LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tasks: %v", err)
+	}
+	defer rows.Close()
+
+	tasks := []models.Task{}
+	for rows.Next() {
+		task, err := scanTask(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan task: %v", err)
+		}
+		tasks = append(tasks, *task)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating tasks: %v", err)
+	}
+	return tasks, nil
+}
+
+// ClaimNextTask marks the oldest queued task as running and returns it, or
+// nil when no task is queued
+func (d *Database) ClaimNextTask() (*models.Task, error) {
+	row := d.db.QueryRow(`
+		UPDATE tasks SET status = ?, started_at = ?
+		WHERE id = (SELECT id FROM tasks WHERE status = ? ORDER BY id LIMIT 1)
+		AND status = ?
+		RETURNING `+taskColumns,
+		models.TaskRunning, time.Now().UTC(), models.TaskQueued, models.TaskQueued)
+	task, err := scanTask(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim task: %v", err)
+	}
+	return task, nil
+}
+
+// UpdateTaskProgress records the progress of a running task
+func (d *Database) UpdateTaskProgress(id int64, progress float64, message string) error {
+	_, err := d.db.Exec(`
+		UPDATE tasks SET progress = ?, message = ?
+		WHERE id = ? AND status = ?
+	`, progress, message, id, models.TaskRunning)
+	if err != nil {
+		return fmt.Errorf("failed to update task progress: %v", err)
+	}
+	return nil
+}
+
+// FinishTask records the outcome of a running task. A succeeded task has its
+// progress set to 1.
+func (d *Database) FinishTask(id int64, status string, result json.RawMessage, errMsg string) error {
+	progress := "progress"
+	if status == models.TaskSucceeded {
+		progress = "1"
+	}
+	_, err := d.db.Exec(`
+		UPDATE tasks SET status = ?, result = ?, error = ?, finished_at = ?, progress = `+progress+`
+		WHERE id = ? AND status = ?
+	`, status, nullJSON(result), sql.NullString{String: errMsg, Valid: errMsg != ""}, time.Now().UTC(),
+		id, models.TaskRunning)
+	if err != nil {
+		return fmt.Errorf("failed to finish task: %v", err)
+	}
+	return nil
+}
+
+// CancelQueuedTask cancels a task that has not started yet. Returns false if
+// the task is not queued.
+func (d *Database) CancelQueuedTask(id int64) (bool, error) {
+	result, err := d.db.Exec(`
+		UPDATE tasks SET status = ?, finished_at = ?
+		WHERE id = ? AND status = ?
+	`, models.TaskCanceled, time.Now().UTC(), id, models.TaskQueued)
+	if err != nil {
+		return false, fmt.Errorf("failed to cancel task: %v", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %v", err)
+	}
+	return n > 0, nil
+}
+
+// FailInterruptedTasks marks the tasks that were still running when the
+// server stopped as failed and returns how many there were
+func (d *Database) FailInterruptedTasks() (int64, error) {
+	result, err := d.db.Exec(`
+		UPDATE tasks SET status = ?, error = ?, finished_at = ?
+		WHERE status = ?
+	`, models.TaskFailed, "interrupted by a server restart", time.Now().UTC(), models.TaskRunning)
+	if err != nil {
+		return 0, fmt.Errorf("failed to fail interrupted tasks: %v", err)
+	}
+	return result.RowsAffected()
+}
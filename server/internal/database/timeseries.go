@@ -0,0 +1,229 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"fundamental/server/internal/models"
+	"math"
+	"sort"
+	"time"
+)
+
+// Time series bucket sizes
+const (
+	IntervalDay   = "day"
+	IntervalMonth = "month"
+)
+
+// marketMetric describes how a market metric is aggregated per date bucket
+type marketMetric struct {
+	dateColumn string
+	value      func(d Dialect) string
+	condition  string
+}
+
+func countValue(Dialect) string { return "COUNT(*)" }
+
+// marketMetrics are the metrics available as time series
+var marketMetrics = map[string]marketMetric{
+	"new_listings": {
+		dateColumn: "listing_date",
+		value:      countValue,
+	},
+	"sold_count": {
+		dateColumn: "selling_date",
+		value:      countValue,
+		condition:  "status = 'sold'",
+	},
+	"avg_listing_price": {
+		dateColumn: "listing_date",
+		value:      func(Dialect) string { return "AVG(price)" },
+	},
+	"avg_sold_price": {
+		dateColumn: "selling_date",
+		value:      func(Dialect) string { return "AVG(price)" },
+		condition:  "status = 'sold'",
+	},
+	"avg_sold_price_per_sqm": {
+		dateColumn: "selling_date",
+		value:      func(Dialect) string { return "AVG(CAST(price AS FLOAT) / NULLIF(living_area, 0))" },
+		condition:  "status = 'sold'",
+	},
+	"avg_days_to_sell": {
+		dateColumn: "selling_date",
+		value: func(d Dialect) string {
+			return "AVG(" + d.DaysBetween("listing_date", "selling_date") + ")"
+		},
+		condition: "status = 'sold' AND listing_date IS NOT NULL AND " + exactSellingDate(""),
+	},
+}
+
+// MarketMetrics returns the names of the metrics available as time series
+func MarketMetrics() []string {
+	names := make([]string, 0, len(marketMetrics))
+	for name := range marketMetrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// IsMarketMetric reports whether a metric can be queried as a time series
+func IsMarketMetric(name string) bool {
+	_, ok := marketMetrics[name]
+	return ok
+}
+
+// GetMarketTimeSeries aggregates a market metric per day or month between two
+// dates. Buckets without properties are left out.
+func (d *Database) GetMarketTimeSeries(metric, interval string, from, to time.Time, city string) ([]models.TimeSeriesPoint, error) {
+	m, ok := marketMetrics[metric]
+	if !ok {
+		return nil, errorf(ErrValidation, "unknown metric %q", metric)
+	}
+
+	// Dates are stored as YYYY-MM-DD, so buckets are prefixes of the date
+	prefixLen, layout := 10, "2006-01-02"
+	if interval == IntervalMonth {
+		prefixLen, layout = 7, "2006-01"
+	}
+	bucket := fmt.Sprintf("SUBSTR(%s, 1, %d)", m.dateColumn, prefixLen)
+	condition := "1 = 1"
+	if m.condition != "" {
+		condition = m.condition
+	}
+
+	query := fmt.Sprintf(`
+		SELECT %s AS bucket, %s AS value
+		FROM properties
+		WHERE price IS NOT NULL
+		AND %s IS NOT NULL
+		AND %s >= ? AND %s <= ?
+		AND %s
+		AND %s
+		GROUP BY %s
+		ORDER BY bucket
+	`, bucket, m.value(d.dialect), m.dateColumn, m.dateColumn, m.dateColumn, condition, cityFilter(city), bucket)
+
+	rows, err := d.db.Query(query, from.Format("2006-01-02"), to.Format("2006-01-02"), city, city)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query %s time series: %v", metric, err)
+	}
+	defer rows.Close()
+
+	points := []models.TimeSeriesPoint{}
+	for rows.Next() {
+		var key string
+		var value sql.NullFloat64
+		if err := rows.Scan(&key, &value); err != nil {
+			return nil, fmt.Errorf("failed to scan time series point: %v", err)
+		}
+		t, err := time.Parse(layout, key)
+		if err != nil || !value.Valid {
+			// Skip malformed dates and buckets without values
+			continue
+		}
+		points = append(points, models.TimeSeriesPoint{Time: t, Value: value.Float64})
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating time series: %v", err)
+	}
+	return points, nil
+}
+
+// GetMonthlyTrends buckets the properties sold between two dates (YYYY-MM-DD,
+// empty for no bound) by month of sale, with their number and median price,
+// price per m² and days from listing to sale. With byDistrict every month is
+// split per district and properties without a district are left out. Months
+// are returned in order, districts in order within a month.
+func (d *Database) GetMonthlyTrends(startDate, endDate, city string, byDistrict bool) ([]models.MonthlyTrend, error) {
+	district := "''"
+	districtCondition := "1 = 1"
+	if byDistrict {
+		district = "district"
+		districtCondition = "district IS NOT NULL"
+	}
+
+	query := `
+        WITH sold AS (
+            SELECT SUBSTR(selling_date, 1, 7) as month, ` + district + ` as district,
+                   CAST(price AS FLOAT) as price,
+                   CAST(price AS FLOAT) / NULLIF(living_area, 0) as price_per_sqm,
+                   CASE WHEN listing_date IS NOT NULL AND ` + exactSellingDate("") + `
+                        THEN ` + d.dialect.DaysBetween("listing_date", "selling_date") + ` END as days_to_sell
+            FROM properties
+            WHERE status = 'sold'
+            AND selling_date IS NOT NULL
+            AND price IS NOT NULL
+            AND ` + cityFilter(city) + `
+            AND ` + districtCondition + `
+            AND (? = '' OR selling_date >= ?)
+            AND (? = '' OR selling_date <= ?)
+        ),
+        ranked AS (
+            SELECT 'price' as metric, month, district, price as value,
+                   ROW_NUMBER() OVER (PARTITION BY month, district ORDER BY price) as row_num,
+                   COUNT(*) OVER (PARTITION BY month, district) as total_count
+            FROM sold
+            UNION ALL
+            SELECT 'price_per_sqm' as metric, month, district, price_per_sqm as value,
+                   ROW_NUMBER() OVER (PARTITION BY month, district ORDER BY price_per_sqm) as row_num,
+                   COUNT(*) OVER (PARTITION BY month, district) as total_count
+            FROM sold
+            WHERE price_per_sqm IS NOT NULL
+            UNION ALL
+            SELECT 'days_to_sell' as metric, month, district, days_to_sell as value,
+                   ROW_NUMBER() OVER (PARTITION BY month, district ORDER BY days_to_sell) as row_num,
+                   COUNT(*) OVER (PARTITION BY month, district) as total_count
+            FROM sold
+            WHERE days_to_sell >= 0
+        )
+        SELECT 'volume' as metric, month, district, CAST(COUNT(*) AS FLOAT) as value
+        FROM sold
+        GROUP BY month, district
+        UNION ALL
+        SELECT metric, month, district, AVG(value) as value
+        FROM ranked
+        WHERE row_num IN ((total_count + 1) / 2, (total_count + 2) / 2)
+        GROUP BY metric, month, district
+        ORDER BY month, district
+    `
+	args := []interface{}{city, city, startDate, startDate, endDate, endDate}
+
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query monthly trends: %v", err)
+	}
+	defer rows.Close()
+
+	trends := []models.MonthlyTrend{}
+	index := make(map[string]int)
+	for rows.Next() {
+		var metric, month, district string
+		var value float64
+		if err := rows.Scan(&metric, &month, &district, &value); err != nil {
+			return nil, fmt.Errorf("failed to scan monthly trend: %v", err)
+		}
+		key := month + "/" + district
+		i, ok := index[key]
+		if !ok {
+			i = len(trends)
+			index[key] = i
+			trends = append(trends, models.MonthlyTrend{Month: month, District: district})
+		}
+		switch metric {
+		case "volume":
+			trends[i].Volume = int(value)
+		case "price":
+			trends[i].MedianPrice = math.Round(value)
+		case "price_per_sqm":
+			trends[i].MedianPricePerSqm = math.Round(value)
+		case "days_to_sell":
+			trends[i].MedianDaysToSell = math.Round(value)
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating monthly trends: %v", err)
+	}
+	return trends, nil
+}
@@ -0,0 +1,158 @@
+package database
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"fundamental/server/internal/models"
+	"time"
+)
+
+const (
+	apiTokenPrefix      = "fmt_"
+	apiTokenBytes       = 24
+	apiTokenDisplayLen  = 12
+	apiTokenTouchPeriod = time.Minute
+)
+
+// hashAPIToken returns the stored form of a token; tokens are never stored in plain text
+func hashAPIToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateAPIToken generates a new read-only token, of a workspace unless
+// workspace is empty. The returned value holds the secret token, which cannot
+// be retrieved again.
+func (d *Database) CreateAPIToken(name string, scopes []string, workspace string, expiresAt *time.Time) (*models.CreatedAPIToken, error) {
+	random := make([]byte, apiTokenBytes)
+	if _, err := rand.Read(random); err != nil {
+		return nil, fmt.Errorf("failed to generate api token: %v", err)
+	}
+	token := apiTokenPrefix + hex.EncodeToString(random)
+	if scopes == nil {
+		scopes = []string{}
+	}
+	scopesJSON, err := json.Marshal(scopes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode scopes: %v", err)
+	}
+
+	created := &models.CreatedAPIToken{
+		APIToken: models.APIToken{
+			Name:      name,
+			Prefix:    token[:apiTokenDisplayLen],
+			Scopes:    scopes,
+			Workspace: workspace,
+			ExpiresAt: expiresAt,
+			CreatedAt: time.Now().UTC(),
+		},
+		Token: token,
+	}
+	err = d.db.QueryRow(`
+		INSERT INTO api_tokens (name, token_hash, token_prefix, scopes, workspace, expires_at, created_at)
+		VALUES (?, ?, ?, ?, NULLIF(?, ''), ?, ?)
+		RETURNING id
+	`, name, hashAPIToken(token), created.Prefix, string(scopesJSON), workspace, expiresAt, created.CreatedAt).Scan(&created.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert api token: %v", err)
+	}
+	return created, nil
+}
+
+const apiTokenColumns = `id, name, token_prefix, scopes, COALESCE(workspace, ''), expires_at, last_used_at, revoked_at, created_at`
+
+func scanAPIToken(row rowScanner) (*models.APIToken, error) {
+	var token models.APIToken
+	var scopes sql.NullString
+	var expiresAt, lastUsedAt, revokedAt sql.NullTime
+	if err := row.Scan(&token.ID, &token.Name, &token.Prefix, &scopes, &token.Workspace,
+		&expiresAt, &lastUsedAt, &revokedAt, &token.CreatedAt); err != nil {
+		return nil, err
+	}
+
+	token.Scopes = []string{}
+	if scopes.Valid && scopes.String != "" {
+		if err := json.Unmarshal([]byte(scopes.String), &token.Scopes); err != nil {
+			return nil, fmt.Errorf("failed to decode scopes of api token %d: %v", token.ID, err)
+		}
+	}
+	if expiresAt.Valid {
+		token.ExpiresAt = &expiresAt.Time
+	}
+	if lastUsedAt.Valid {
+		token.LastUsedAt = &lastUsedAt.Time
+	}
+	if revokedAt.Valid {
+		token.RevokedAt = &revokedAt.Time
+	}
+	return &token, nil
+}
+
+// ListAPITokens returns all tokens, including revoked and expired ones, newest first
+func (d *Database) ListAPITokens() ([]models.APIToken, error) {
+	rows, err := d.db.Query("SELECT " + apiTokenColumns + " FROM api_tokens ORDER BY id DESC")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query api tokens: %v", err)
+	}
+	defer rows.Close()
+
+	tokens := []models.APIToken{}
+	for rows.Next() {
+		token, err := scanAPIToken(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan api token: %v", err)
+		}
+		tokens = append(tokens, *token)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating api tokens: %v", err)
+	}
+	return tokens, nil
+}
+
+// RevokeAPIToken revokes a token. Returns false if no active token has the given ID.
+func (d *Database) RevokeAPIToken(id int64) (bool, error) {
+	result, err := d.db.Exec(`
+		UPDATE api_tokens SET revoked_at = ?
+		WHERE id = ? AND revoked_at IS NULL
+	`, time.Now().UTC(), id)
+	if err != nil {
+		return false, fmt.Errorf("failed to revoke api token: %v", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %v", err)
+	}
+	return n > 0, nil
+}
+
+// AuthenticateAPIToken looks up an active token and records its use. Returns
+// nil if the token is unknown, revoked or expired.
+func (d *Database) AuthenticateAPIToken(secret string) (*models.APIToken, error) {
+	row := d.db.QueryRow("SELECT "+apiTokenColumns+" FROM api_tokens WHERE token_hash = ?", hashAPIToken(secret))
+	token, err := scanAPIToken(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up api token: %v", err)
+	}
+
+	now := time.Now().UTC()
+	if !token.Active(now) {
+		return nil, nil
+	}
+
+	// Only write last_used_at once per period to keep reads cheap
+	if token.LastUsedAt == nil || now.Sub(*token.LastUsedAt) > apiTokenTouchPeriod {
+		if _, err := d.db.Exec("UPDATE api_tokens SET last_used_at = ? WHERE id = ?", now, token.ID); err != nil {
+			return nil, fmt.Errorf("failed to record api token use: %v", err)
+		}
+		token.LastUsedAt = &now
+	}
+	return token, nil
+}
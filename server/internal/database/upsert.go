@@ -0,0 +1,434 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"fundamental/server/internal/models"
+	"strings"
+	"time"
+)
+
+// Scraped items are written with multi-row upserts. Before a batch is written
+// the stored state of its URLs and the earlier listings of its new homes are
+// read with one query each, so republishing, relisting and history are decided
+// the same way as for an item written on its own.
+
+// upsertBatchSize bounds the items per upsert. At 27 parameters per item a
+// batch stays well below the parameter limits of SQLite and PostgreSQL.
+const upsertBatchSize = 400
+
+// upsertColumns are the properties columns written for a scraped item. All but
+// url and canonical_id are overwritten when the URL is already stored, and
+// agent_id only by an item that names the agent. The delisting columns are
+// derived from the status, see delisting.
+var upsertColumns = []string{
+	"url", "street", "neighborhood", "property_type", "city", "postal_code", "district", "outlier_flags",
+	"price", "year_built", "living_area", "num_rooms", "status",
+	"listing_date", "selling_date", "selling_date_precision", "scraped_at", "republish_count", "energy_label",
+	"field_provenance", "canonical_id", "agent_id", "delisting_reason", "delisted_at",
+	"country", "currency",
+}
+
+// upsertStatement returns the upsert of rows items. It returns the id and URL
+// of every written row.
+func upsertStatement(rows int) string {
+	values := make([]string, len(upsertColumns))
+	var updates []string
+	for i, column := range upsertColumns {
+		values[i] = "?"
+		if column == "living_area" {
+			// living_area is bound twice, a living area of 0 is stored as NULL
+			values[i] = "CASE WHEN CAST(? AS INTEGER) > 0 THEN CAST(? AS INTEGER) ELSE NULL END"
+		}
+		switch column {
+		case "url", "canonical_id":
+		case "agent_id":
+			updates = append(updates, "agent_id = COALESCE(excluded.agent_id, properties.agent_id)")
+		default:
+			updates = append(updates, column+" = excluded."+column)
+		}
+	}
+	row := "(" + strings.Join(values, ", ") + ")"
+	return fmt.Sprintf(`
+		INSERT INTO properties (%s)
+		VALUES %s
+		ON CONFLICT (url) DO UPDATE SET %s
+		RETURNING id, url
+	`, strings.Join(upsertColumns, ", "), strings.Repeat(row+", ", rows-1)+row, strings.Join(updates, ", "))
+}
+
+// storedListing is the stored state of a scraped URL
+type storedListing struct {
+	id              int64
+	status          string
+	price           sql.NullInt64
+	republishCount  int
+	provenance      sql.NullString
+	delistingReason sql.NullString
+	delistedAt      sql.NullTime
+	fields          map[string]interface{} // scraped fields, for the audit log
+}
+
+// storedListings returns the stored state of the given URLs by URL
+func storedListings(tx *sqlTx, urls []interface{}) (map[string]*storedListing, error) {
+	rows, err := tx.Query(`
+		SELECT id, url, COALESCE(status, ''), price, COALESCE(republish_count, 0), field_provenance,
+			delisting_reason, delisted_at, `+
+		strings.Join(scrapedFields, ", ")+`
+		FROM properties
+		WHERE url IN (?`+strings.Repeat(", ?", len(urls)-1)+`)
+	`, urls...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check existing properties: %w", err)
+	}
+	defer rows.Close()
+
+	stored := make(map[string]*storedListing, len(urls))
+	for rows.Next() {
+		var url string
+		var s storedListing
+		fields := make([]interface{}, len(scrapedFields))
+		scanArgs := []interface{}{&s.id, &url, &s.status, &s.price, &s.republishCount, &s.provenance,
+			&s.delistingReason, &s.delistedAt}
+		for i := range fields {
+			scanArgs = append(scanArgs, &fields[i])
+		}
+		if err := rows.Scan(scanArgs...); err != nil {
+			return nil, fmt.Errorf("failed to scan existing property: %w", err)
+		}
+		s.fields = make(map[string]interface{}, len(scrapedFields))
+		for i, field := range scrapedFields {
+			s.fields[field] = fields[i]
+		}
+		stored[url] = &s
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating existing properties: %w", err)
+	}
+	return stored, nil
+}
+
+// delisting returns the delisting reason and time to store for an item with
+// the given status: none while it is listed, sold once it sold and otherwise
+// the stored reason or withdrawn. The stored time is kept while the reason
+// does not change.
+func delisting(status interface{}, stored *storedListing, now time.Time) (reason, at interface{}) {
+	raw, _ := status.(string)
+	switch statusTaxonomy[strings.ToLower(strings.TrimSpace(raw))] {
+	case "active", "republished":
+		return nil, nil
+	case "sold":
+		reason = models.DelistingSold
+	case "inactive":
+		reason = models.DelistingWithdrawn
+		if stored != nil && stored.delistingReason.Valid && stored.delistingReason.String != models.DelistingSold {
+			reason = stored.delistingReason.String
+		}
+	default:
+		// An unknown status leaves the stored classification alone
+		if stored == nil {
+			return nil, nil
+		}
+		return stored.delistingReason, stored.delistedAt
+	}
+	if stored != nil && stored.delistingReason.String == reason && stored.delistedAt.Valid {
+		return reason, stored.delistedAt.Time
+	}
+	return reason, now
+}
+
+// nextRound splits off the items that can be written in one upsert. An item
+// whose URL or home occurs earlier in the batch waits for a later round, so
+// it sees the earlier item as stored, and so do the items after it that share
+// its URL or home.
+func nextRound(items []map[string]interface{}) (round, deferred []map[string]interface{}) {
+	urls := make(map[string]bool)
+	homes := make(map[string]bool)
+	for _, prop := range items {
+		url := prop["url"].(string)
+		home := itemHomeKey(prop)
+		if urls[url] || (home != "" && homes[home]) {
+			deferred = append(deferred, prop)
+		} else {
+			round = append(round, prop)
+		}
+		urls[url] = true
+		if home != "" {
+			homes[home] = true
+		}
+	}
+	return round, deferred
+}
+
+// propertyUpserter writes batches of scraped items in a transaction. The
+// upsert is prepared once per batch size.
+type propertyUpserter struct {
+	tx      *sqlTx
+	upserts map[int]*sql.Stmt
+	history *sql.Stmt
+	prices  *sql.Stmt
+	images  *sql.Stmt
+	agents  *sql.Stmt
+	audits  *sql.Stmt
+	// agentIDs caches the ids of the agents written in the transaction by name
+	agentIDs map[string]int64
+}
+
+func newPropertyUpserter(tx *sqlTx) (*propertyUpserter, error) {
+	history, err := tx.Prepare(historyInsert)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare history statement: %w", err)
+	}
+	prices, err := tx.Prepare(priceChangeInsert)
+	if err != nil {
+		history.Close()
+		return nil, fmt.Errorf("failed to prepare price change statement: %w", err)
+	}
+	images, err := tx.Prepare(imageUpsert)
+	if err != nil {
+		history.Close()
+		prices.Close()
+		return nil, fmt.Errorf("failed to prepare image statement: %w", err)
+	}
+	agents, err := tx.Prepare(agentUpsert)
+	if err != nil {
+		history.Close()
+		prices.Close()
+		images.Close()
+		return nil, fmt.Errorf("failed to prepare agent statement: %w", err)
+	}
+	audits, err := tx.Prepare(auditInsert)
+	if err != nil {
+		history.Close()
+		prices.Close()
+		images.Close()
+		agents.Close()
+		return nil, fmt.Errorf("failed to prepare audit log statement: %w", err)
+	}
+	return &propertyUpserter{
+		tx:       tx,
+		upserts:  make(map[int]*sql.Stmt),
+		history:  history,
+		prices:   prices,
+		images:   images,
+		agents:   agents,
+		audits:   audits,
+		agentIDs: make(map[string]int64),
+	}, nil
+}
+
+// close closes the prepared statements
+func (u *propertyUpserter) close() {
+	for _, stmt := range u.upserts {
+		stmt.Close()
+	}
+	u.history.Close()
+	u.prices.Close()
+	u.images.Close()
+	u.agents.Close()
+	u.audits.Close()
+}
+
+// upsertFor returns the prepared upsert of rows items
+func (u *propertyUpserter) upsertFor(rows int) (*sql.Stmt, error) {
+	if stmt, ok := u.upserts[rows]; ok {
+		return stmt, nil
+	}
+	stmt, err := u.tx.Prepare(upsertStatement(rows))
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare upsert statement: %w", err)
+	}
+	u.upserts[rows] = stmt
+	return stmt, nil
+}
+
+// upsert writes a batch of items with distinct URLs and homes, records their
+// history, changed fields and photos and returns the newly inserted ones
+func (u *propertyUpserter) upsert(items []map[string]interface{}) ([]map[string]interface{}, error) {
+	urls := make([]interface{}, len(items))
+	for i, prop := range items {
+		urls[i] = prop["url"]
+	}
+	stored, err := storedListings(u.tx, urls)
+	if err != nil {
+		return nil, err
+	}
+	var fresh []map[string]interface{}
+	for _, prop := range items {
+		if stored[prop["url"].(string)] == nil {
+			fresh = append(fresh, prop)
+		}
+	}
+	previous, err := findRelistings(u.tx, fresh)
+	if err != nil {
+		return nil, err
+	}
+
+	// values holds the column values of each item: the scraped ones, except
+	// for fields set by higher-precedence sources
+	values := make([]map[string]interface{}, len(items))
+	now := time.Now().UTC()
+	args := make([]interface{}, 0, len(items)*(len(upsertColumns)+1))
+	for i, prop := range items {
+		url := prop["url"].(string)
+		values[i] = prop
+		republishCount := 0
+		var provenance map[string]models.FieldProvenance
+		var canonicalID interface{}
+
+		if s := stored[url]; s != nil {
+			republishCount = s.republishCount
+			if s.status == "inactive" && prop["status"] == "active" {
+				// Property is being republished
+				republishCount++
+				prop["status"] = "republished"
+				prop["republish_count"] = republishCount
+			}
+
+			var locked []string
+			locked, provenance = mergeProvenance(parseProvenance(s.provenance.String), prop, scrapedFields)
+			if len(locked) > 0 {
+				current, err := lockedValues(u.tx, s.id, locked)
+				if err != nil {
+					return nil, err
+				}
+				values[i] = make(map[string]interface{}, len(prop))
+				for k, v := range prop {
+					values[i][k] = v
+				}
+				for k, v := range current {
+					values[i][k] = v
+				}
+			}
+		} else {
+			_, provenance = mergeProvenance(make(map[string]models.FieldProvenance), prop, scrapedFields)
+			// A home relisted under a new URL joins the chain of its earlier listings
+			if r := previous[url]; r != nil {
+				canonicalID = r.canonicalID
+				prop["canonical_id"] = r.canonicalID
+			}
+		}
+		provenanceJSON, err := json.Marshal(provenance)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode provenance: %w", err)
+		}
+		agentID, err := u.agentID(prop)
+		if err != nil {
+			return nil, err
+		}
+
+		v := values[i]
+		delistingReason, delistedAt := delisting(v["status"], stored[url], now)
+		args = append(args,
+			url,
+			v["street"],
+			v["neighborhood"],
+			v["property_type"],
+			v["city"],
+			v["postal_code"],
+			propertyDistrict(v["country"], v["postal_code"]),
+			itemOutlierFlags(v["price"], v["living_area"]),
+			v["price"],
+			v["year_built"],
+			v["living_area"], v["living_area"],
+			v["num_rooms"],
+			v["status"],
+			v["listing_date"],
+			v["selling_date"],
+			v["selling_date_precision"],
+			prop["scraped_at"],
+			republishCount,
+			v["energy_label"],
+			string(provenanceJSON),
+			canonicalID,
+			agentID,
+			delistingReason,
+			delistedAt,
+			v["country"],
+			v["currency"],
+		)
+	}
+
+	stmt, err := u.upsertFor(len(items))
+	if err != nil {
+		return nil, err
+	}
+	rows, err := stmt.Query(args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to upsert properties: %w", err)
+	}
+	ids := make(map[string]int64, len(items))
+	for rows.Next() {
+		var id int64
+		var url string
+		if err := rows.Scan(&id, &url); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan upserted property: %w", err)
+		}
+		ids[url] = id
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to upsert properties: %w", err)
+	}
+
+	var newProperties []map[string]interface{}
+	for i, prop := range items {
+		url := prop["url"].(string)
+		id, ok := ids[url]
+		if !ok {
+			return nil, fmt.Errorf("upsert returned no row for %s", url)
+		}
+		if images, ok := itemImages(prop); ok {
+			if err := u.storeImages(id, images); err != nil {
+				return nil, err
+			}
+			prop["images"] = images
+		}
+		// A home that sold is matched against watched properties once stored
+		if status, _ := values[i]["status"].(string); status == "sold" && (stored[url] == nil || stored[url].status != "sold") {
+			prop[itemSoldKey] = id
+		}
+
+		if s := stored[url]; s != nil {
+			if err := u.audit(id, prop, s, values[i]); err != nil {
+				return nil, err
+			}
+
+			// Record history only when the price or status changed
+			price := historyPrice(values[i]["price"])
+			status, _ := values[i]["status"].(string)
+			if change := historyChange(s.status, s.price, price, status); change != "" {
+				_, err = u.history.Exec(id, change, status, price, values[i]["listing_date"],
+					sql.NullString{String: s.status, Valid: true}, s.price)
+				if err != nil {
+					return nil, fmt.Errorf("failed to insert property history: %w", err)
+				}
+			}
+			if price.Valid && s.price.Valid && s.price.Int64 > 0 && price != s.price {
+				pct := float64(price.Int64-s.price.Int64) * 100 / float64(s.price.Int64)
+				if _, err = u.prices.Exec(id, s.price, price, pct); err != nil {
+					return nil, fmt.Errorf("failed to insert price change: %w", err)
+				}
+			}
+			continue
+		}
+
+		// Record initial history; a relisting starts from the state of the
+		// previous listing
+		if r := previous[url]; r != nil {
+			_, err = u.history.Exec(id, models.HistoryRelisted, prop["status"], historyPrice(prop["price"]),
+				prop["listing_date"], sql.NullString{String: r.status, Valid: true}, r.price)
+		} else {
+			_, err = u.history.Exec(id, models.HistoryListed, prop["status"], historyPrice(prop["price"]),
+				prop["listing_date"], sql.NullString{}, sql.NullInt64{})
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to insert initial property history: %w", err)
+		}
+		prop["id"] = id
+		newProperties = append(newProperties, prop)
+	}
+	return newProperties, nil
+}
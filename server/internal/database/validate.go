@@ -0,0 +1,332 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"fundamental/server/internal/country"
+	"fundamental/server/internal/models"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Bounds outside of which a scraped item is rejected rather than stored. They
+// are wider than the outlier bounds: an outlier is implausible, a rejected item
+// is broken.
+const (
+	validMinPrice      = 10000
+	validMaxPrice      = 100000000
+	validMinLivingArea = 5
+	validMaxLivingArea = 10000
+)
+
+// itemRejectedKey is set on a rejected item to the reasons it was rejected for,
+// separated by "; "
+const itemRejectedKey = "_rejected"
+
+// currencyRegex matches an ISO 4217 currency code
+var currencyRegex = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// itemDateLayouts are the accepted formats of listing and selling dates
+var itemDateLayouts = []string{"2006-01-02", "2006-01-02T15:04:05", time.RFC3339, "2006-01-02 15:04:05"}
+
+// parseItemDate parses a listing or selling date of a scraped item
+func parseItemDate(value string) (time.Time, bool) {
+	for _, layout := range itemDateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// validateItem returns the reasons a scraped item cannot be stored, or nil.
+// Missing fields are allowed, fields that are present must make sense.
+func validateItem(prop map[string]interface{}) []string {
+	var reasons []string
+	if url, _ := prop["url"].(string); url == "" {
+		reasons = append(reasons, "missing url")
+	}
+
+	if prop["price"] != nil {
+		price := historyPrice(prop["price"])
+		switch {
+		case !price.Valid:
+			reasons = append(reasons, "price is not a number")
+		case price.Int64 < validMinPrice || price.Int64 > validMaxPrice:
+			reasons = append(reasons, fmt.Sprintf("price %d outside %d-%d", price.Int64, validMinPrice, validMaxPrice))
+		}
+	}
+
+	// A living area of 0 means unknown and is stored as NULL
+	if prop["living_area"] != nil {
+		area := historyPrice(prop["living_area"])
+		switch {
+		case !area.Valid:
+			reasons = append(reasons, "living area is not a number")
+		case area.Int64 != 0 && (area.Int64 < validMinLivingArea || area.Int64 > validMaxLivingArea):
+			reasons = append(reasons, fmt.Sprintf("living area %d m² outside %d-%d", area.Int64, validMinLivingArea, validMaxLivingArea))
+		}
+	}
+
+	// InsertProperties has set the country and currency, see itemCountry
+	countryCode, _ := prop["country"].(string)
+	c, known := country.Lookup(countryCode)
+	if !known {
+		reasons = append(reasons, fmt.Sprintf("unknown country %q", countryCode))
+	} else if postalCode, ok := prop["postal_code"].(string); ok && postalCode != "" &&
+		!c.ValidPostalCode(postalCode) {
+		reasons = append(reasons, fmt.Sprintf("invalid %s postal code %q", c.Code, postalCode))
+	}
+	if currency, _ := prop["currency"].(string); !currencyRegex.MatchString(currency) {
+		reasons = append(reasons, fmt.Sprintf("invalid currency %q", currency))
+	}
+
+	if status, ok := prop["status"].(string); ok && status != "" {
+		if _, known := statusTaxonomy[strings.ToLower(status)]; !known {
+			reasons = append(reasons, fmt.Sprintf("unknown status %q", status))
+		}
+	}
+
+	var listed, sold time.Time
+	dates := []struct {
+		field string
+		date  *time.Time
+	}{{"listing_date", &listed}, {"selling_date", &sold}}
+	for _, d := range dates {
+		value, ok := prop[d.field].(string)
+		if !ok || value == "" {
+			continue
+		}
+		t, ok := parseItemDate(value)
+		if !ok {
+			reasons = append(reasons, fmt.Sprintf("unparseable %s %q", strings.ReplaceAll(d.field, "_", " "), value))
+			continue
+		}
+		*d.date = t
+	}
+	// prepareItem has set the precision of a selling date, see itemSellingDate.
+	// A sale known to the month may have been in the month of the listing.
+	precision, _ := prop["selling_date_precision"].(string)
+	if precision != "" && !validDatePrecision(precision) {
+		reasons = append(reasons, fmt.Sprintf("unknown selling date precision %q", precision))
+	}
+	if precision == models.DatePrecisionMonth && !sold.IsZero() {
+		sold = sold.AddDate(0, 1, 0).Add(-time.Nanosecond)
+	}
+	if !listed.IsZero() && !sold.IsZero() && sold.Before(listed) {
+		reasons = append(reasons, "selling date before listing date")
+	}
+	return reasons
+}
+
+// rejectItems quarantines scraped items that failed validation for review
+func rejectItems(tx *sqlTx, items []map[string]interface{}) error {
+	if len(items) == 0 {
+		return nil
+	}
+	stmt, err := tx.Prepare(`
+		INSERT INTO rejected_items (url, source, run_id, reasons, payload, rules)
+		VALUES (?, ?, ?, ?, ?, NULLIF(?, ''))
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare rejected item statement: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, prop := range items {
+		payload := make(map[string]interface{}, len(prop))
+		for k, v := range prop {
+			if !strings.HasPrefix(k, "_") {
+				payload[k] = v
+			}
+		}
+		payloadJSON, err := json.Marshal(payload)
+		if err != nil {
+			return fmt.Errorf("failed to encode rejected item: %w", err)
+		}
+		source, runID, _ := itemProvenance(prop)
+		url, _ := prop["url"].(string)
+		rules, _ := prop[itemRejectedRulesKey].(string)
+		_, err = stmt.Exec(url, source, runID, prop[itemRejectedKey], string(payloadJSON), rules)
+		if err != nil {
+			return fmt.Errorf("failed to store rejected item: %w", err)
+		}
+	}
+	return nil
+}
+
+// ListRejectedItems returns rejected items, newest first. An empty source
+// returns items of every source.
+func (d *Database) ListRejectedItems(source string, limit, offset int) (*models.RejectedItemList, error) {
+	list := &models.RejectedItemList{Items: []models.RejectedItem{}}
+	err := d.db.QueryRow(`
+		SELECT COUNT(*) FROM rejected_items WHERE (? = '' OR source = ?)
+	`, source, source).Scan(&list.Total)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count rejected items: %v", err)
+	}
+
+	rows, err := d.db.Query(`
+		SELECT id, COALESCE(url, ''), COALESCE(source, ''), COALESCE(run_id, ''), reasons, payload,
+			COALESCE(rules, ''), created_at
+		FROM rejected_items
+		WHERE (? = '' OR source = ?)
+		ORDER BY id DESC
+		LIMIT ? OFFSET ?
+	`, source, source, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query rejected items: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var item models.RejectedItem
+		var reasons, payload, rules string
+		if err := rows.Scan(&item.ID, &item.URL, &item.Source, &item.RunID, &reasons, &payload, &rules, &item.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan rejected item: %v", err)
+		}
+		item.Reasons = strings.Split(reasons, "; ")
+		if rules != "" {
+			item.Rules = strings.Split(rules, ",")
+		}
+		item.Payload = json.RawMessage(payload)
+		list.Items = append(list.Items, item)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rejected items: %v", err)
+	}
+	return list, nil
+}
+
+// DeleteRejectedItem removes a reviewed rejected item. Returns false if it
+// does not exist.
+func (d *Database) DeleteRejectedItem(id int64) (bool, error) {
+	result, err := d.db.Exec("DELETE FROM rejected_items WHERE id = ?", id)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete rejected item: %v", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %v", err)
+	}
+	return n > 0, nil
+}
+
+// ApproveRejectedItem stores a quarantined item after review and removes it
+// from the rejected items. fields replace fields of the item first, to correct
+// it. The validation rules are not applied again, the built-in checks are:
+// returns an ErrValidation error, and keeps the item, when it still fails
+// them, and an ErrNotFound error when the item does not exist.
+func (d *Database) ApproveRejectedItem(id int64, fields map[string]interface{}) (*models.ApprovedItem, error) {
+	var source, runID, payload string
+	err := d.db.QueryRow(`
+		SELECT COALESCE(source, ''), COALESCE(run_id, ''), payload FROM rejected_items WHERE id = ?
+	`, id).Scan(&source, &runID, &payload)
+	if err == sql.ErrNoRows {
+		return nil, errorf(ErrNotFound, "rejected item %d not found", id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rejected item: %v", err)
+	}
+
+	var item map[string]interface{}
+	if err := json.Unmarshal([]byte(payload), &item); err != nil {
+		return nil, fmt.Errorf("failed to decode rejected item %d: %v", id, err)
+	}
+	for field, value := range fields {
+		if strings.HasPrefix(field, "_") {
+			return nil, errorf(ErrValidation, "invalid field %q", field)
+		}
+		item[field] = value
+	}
+	prepareItem(item)
+	if reasons := validateItem(item); len(reasons) > 0 {
+		return nil, errorf(ErrValidation, "item still fails validation: %s", strings.Join(reasons, "; "))
+	}
+	item[itemSourceKey] = source
+	item[itemRunIDKey] = runID
+
+	inserted, err := d.insertProperties([]map[string]interface{}{item}, nil)
+	if err != nil {
+		return nil, err
+	}
+	approved := &models.ApprovedItem{New: len(inserted) > 0}
+	if approved.New {
+		approved.PropertyID, _ = inserted[0]["id"].(int64)
+	} else if err := d.db.QueryRow("SELECT id FROM properties WHERE url = ?", item["url"]).Scan(&approved.PropertyID); err != nil {
+		return nil, fmt.Errorf("failed to look up approved property: %v", err)
+	}
+
+	if _, err := d.db.Exec("DELETE FROM rejected_items WHERE id = ?", id); err != nil {
+		return nil, fmt.Errorf("failed to delete approved item: %v", err)
+	}
+	return approved, nil
+}
+
+// quarantineSummary counts the rejected items by source and failed check
+func (d *Database) quarantineSummary() (models.QuarantineSummary, error) {
+	summary := models.QuarantineSummary{BySource: map[string]int{}, ByRule: map[string]int{}}
+	err := d.db.QueryRow(`
+		SELECT COUNT(*), COALESCE(SUM(CASE WHEN created_at >= `+d.dialect.TimestampOffset()+` THEN 1 ELSE 0 END), 0)
+		FROM rejected_items
+	`, "-1 days").Scan(&summary.Total, &summary.LastDay)
+	if err != nil {
+		return summary, fmt.Errorf("failed to count rejected items: %v", err)
+	}
+
+	rows, err := d.db.Query(`
+		SELECT COALESCE(source, ''), COALESCE(rules, ''), COUNT(*)
+		FROM rejected_items
+		GROUP BY COALESCE(source, ''), COALESCE(rules, '')
+	`)
+	if err != nil {
+		return summary, fmt.Errorf("failed to query rejected items: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var source, rules string
+		var count int
+		if err := rows.Scan(&source, &rules, &count); err != nil {
+			return summary, fmt.Errorf("failed to scan rejected item count: %v", err)
+		}
+		summary.BySource[source] += count
+		// Items rejected before the rules were recorded failed the
+		// built-in checks
+		if rules == "" {
+			rules = builtinRule
+		}
+		for _, rule := range strings.Split(rules, ",") {
+			summary.ByRule[rule] += count
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return summary, fmt.Errorf("error iterating rejected item counts: %v", err)
+	}
+	return summary, nil
+}
+
+// GetDataQuality counts the stored properties with implausible or missing
+// data and the quarantined items
+func (d *Database) GetDataQuality() (*models.DataQuality, error) {
+	quality := &models.DataQuality{}
+	err := d.db.QueryRow(`
+		SELECT
+			COUNT(*),
+			COALESCE(SUM(CASE WHEN outlier_flags IS NOT NULL THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN latitude IS NULL OR longitude IS NULL THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN living_area IS NULL OR living_area = 0 THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN energy_label IS NULL OR energy_label = '' THEN 1 ELSE 0 END), 0)
+		FROM properties
+	`).Scan(&quality.Properties, &quality.Outliers, &quality.MissingCoordinates,
+		&quality.MissingLivingArea, &quality.MissingEnergyLabel)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count property data quality: %v", err)
+	}
+
+	if quality.Quarantine, err = d.quarantineSummary(); err != nil {
+		return nil, err
+	}
+	return quality, nil
+}
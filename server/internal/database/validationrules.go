@@ -0,0 +1,243 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"fundamental/server/internal/models"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// builtinRule names the built-in checks of validateItem among the checks a
+// rejected item failed
+const builtinRule = "builtin"
+
+// itemRejectedRulesKey is set on a rejected item to the checks it failed,
+// rule ids and builtinRule separated by commas
+const itemRejectedRulesKey = "_rejected_rules"
+
+// validationRuleColumns are the columns read by scanValidationRule
+const validationRuleColumns = `id, field, kind, COALESCE(value, ''), COALESCE(message, ''), enabled, created_at, updated_at`
+
+func scanValidationRule(row rowScanner) (*models.ValidationRule, error) {
+	var rule models.ValidationRule
+	err := row.Scan(&rule.ID, &rule.Field, &rule.Kind, &rule.Value, &rule.Message, &rule.Enabled, &rule.CreatedAt, &rule.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+// ListValidationRules returns all validation rules in the order they were
+// created
+func (d *Database) ListValidationRules() ([]models.ValidationRule, error) {
+	rows, err := d.db.Query("SELECT " + validationRuleColumns + " FROM validation_rules ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query validation rules: %v", err)
+	}
+	defer rows.Close()
+
+	rules := []models.ValidationRule{}
+	for rows.Next() {
+		rule, err := scanValidationRule(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan validation rule: %v", err)
+		}
+		rules = append(rules, *rule)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating validation rules: %v", err)
+	}
+	return rules, nil
+}
+
+// GetValidationRule returns a validation rule, or nil if it does not exist
+func (d *Database) GetValidationRule(id int64) (*models.ValidationRule, error) {
+	rule, err := scanValidationRule(d.db.QueryRow("SELECT "+validationRuleColumns+" FROM validation_rules WHERE id = ?", id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get validation rule: %v", err)
+	}
+	return rule, nil
+}
+
+// CreateValidationRule stores a new validation rule. Returns an ErrValidation
+// error when the rule is invalid.
+func (d *Database) CreateValidationRule(req models.ValidationRuleRequest) (*models.ValidationRule, error) {
+	if err := req.Validate(); err != nil {
+		return nil, newError(ErrValidation, err.Error())
+	}
+	var id int64
+	err := d.db.QueryRow(`
+		INSERT INTO validation_rules (field, kind, value, message, enabled)
+		VALUES (?, ?, NULLIF(?, ''), NULLIF(?, ''), ?)
+		RETURNING id
+	`, req.Field, req.Kind, req.Value, req.Message, req.Enabled == nil || *req.Enabled).Scan(&id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert validation rule: %v", err)
+	}
+	return d.GetValidationRule(id)
+}
+
+// UpdateValidationRule replaces a validation rule. Returns an ErrNotFound
+// error if it does not exist and an ErrValidation error when it is invalid.
+func (d *Database) UpdateValidationRule(id int64, req models.ValidationRuleRequest) (*models.ValidationRule, error) {
+	if err := req.Validate(); err != nil {
+		return nil, newError(ErrValidation, err.Error())
+	}
+	result, err := d.db.Exec(`
+		UPDATE validation_rules
+		SET field = ?, kind = ?, value = NULLIF(?, ''), message = NULLIF(?, ''), enabled = ?,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?
+	`, req.Field, req.Kind, req.Value, req.Message, req.Enabled == nil || *req.Enabled, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update validation rule: %v", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rows affected: %v", err)
+	}
+	if n == 0 {
+		return nil, errorf(ErrNotFound, "validation rule %d not found", id)
+	}
+	return d.GetValidationRule(id)
+}
+
+// DeleteValidationRule removes a validation rule. Returns an ErrNotFound error
+// if it does not exist.
+func (d *Database) DeleteValidationRule(id int64) error {
+	result, err := d.db.Exec("DELETE FROM validation_rules WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete validation rule: %v", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %v", err)
+	}
+	if n == 0 {
+		return errorf(ErrNotFound, "validation rule %d not found", id)
+	}
+	return nil
+}
+
+// validationRule is an enabled rule ready to check items
+type validationRule struct {
+	models.ValidationRule
+	bound   float64
+	pattern *regexp.Regexp
+}
+
+// enabledValidationRules loads the enabled validation rules. Rules stored
+// before they could be parsed are skipped.
+func (d *Database) enabledValidationRules() ([]validationRule, error) {
+	stored, err := d.ListValidationRules()
+	if err != nil {
+		return nil, err
+	}
+	var rules []validationRule
+	for _, rule := range stored {
+		if !rule.Enabled {
+			continue
+		}
+		r := validationRule{ValidationRule: rule}
+		switch rule.Kind {
+		case models.ValidationMin, models.ValidationMax:
+			if r.bound, err = strconv.ParseFloat(rule.Value, 64); err != nil {
+				continue
+			}
+		case models.ValidationPattern:
+			if r.pattern, err = regexp.Compile(rule.Value); err != nil {
+				continue
+			}
+		}
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
+
+// itemNumber returns a numeric field of a scraped or imported item
+func itemNumber(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// itemFieldMissing reports whether an item lacks a field: absent, null, empty
+// text or 0, which the spiders send for unknown numbers
+func itemFieldMissing(value interface{}, present bool) bool {
+	if !present || value == nil {
+		return true
+	}
+	if s, ok := value.(string); ok {
+		return strings.TrimSpace(s) == ""
+	}
+	n, ok := itemNumber(value)
+	return ok && n == 0
+}
+
+// check returns why an item fails the rule, or "" when it passes. Only
+// required rules fail items without the field.
+func (r *validationRule) check(prop map[string]interface{}) string {
+	value, present := prop[r.Field]
+	missing := itemFieldMissing(value, present)
+	var reason string
+	switch r.Kind {
+	case models.ValidationRequired:
+		if missing {
+			reason = "missing " + strings.ReplaceAll(r.Field, "_", " ")
+		}
+	case models.ValidationMin, models.ValidationMax:
+		if missing {
+			break
+		}
+		n, ok := itemNumber(value)
+		switch {
+		case !ok:
+			reason = fmt.Sprintf("%s is not a number", strings.ReplaceAll(r.Field, "_", " "))
+		case r.Kind == models.ValidationMin && n < r.bound:
+			reason = fmt.Sprintf("%s %s below %s", r.Field, strconv.FormatFloat(n, 'f', -1, 64), r.Value)
+		case r.Kind == models.ValidationMax && n > r.bound:
+			reason = fmt.Sprintf("%s %s above %s", r.Field, strconv.FormatFloat(n, 'f', -1, 64), r.Value)
+		}
+	case models.ValidationPattern:
+		if missing {
+			break
+		}
+		if text := fmt.Sprint(value); !r.pattern.MatchString(text) {
+			reason = fmt.Sprintf("%s %q does not match %s", r.Field, text, r.Value)
+		}
+	}
+	if reason != "" && r.Message != "" {
+		reason = r.Message
+	}
+	return reason
+}
+
+// checkValidationRules returns the reasons an item fails the validation
+// rules, and the ids of the rules it fails
+func checkValidationRules(prop map[string]interface{}, rules []validationRule) (reasons, failed []string) {
+	for i := range rules {
+		if reason := rules[i].check(prop); reason != "" {
+			reasons = append(reasons, reason)
+			failed = append(failed, strconv.FormatInt(rules[i].ID, 10))
+		}
+	}
+	return reasons, failed
+}
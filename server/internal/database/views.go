@@ -0,0 +1,302 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"fundamental/server/internal/models"
+	"strings"
+	"time"
+)
+
+// Views give external consumers, such as BI tools, the read-only SQL endpoint
+// and exports, a stable shape to read instead of the tables. They are not
+// migrations: MigrateTo drops them before changing the schema and creates them
+// again from the definitions below once the schema is current, so a migration
+// that renames or drops a column only has to update a definition to keep a
+// view's columns the same. Never rename or remove a view column; add new ones
+// at the end.
+
+// view is a database view with its documentation
+type view struct {
+	models.View
+	// query returns the SELECT of the view, whose columns are named by Columns
+	query func(dialect Dialect) string
+}
+
+// listingColumns are the columns shared by the active and sold listing views
+var listingColumns = []models.ViewColumn{
+	{Name: "id", Type: models.ViewInteger, Description: "Property id"},
+	{Name: "url", Type: models.ViewText, Description: "Listing URL"},
+	{Name: "street", Type: models.ViewText, Description: "Street and house number"},
+	{Name: "neighborhood", Type: models.ViewText, Description: "Neighborhood"},
+	{Name: "property_type", Type: models.ViewText, Description: "Kind of home"},
+	{Name: "city", Type: models.ViewText, Description: "City"},
+	{Name: "postal_code", Type: models.ViewText, Description: "Postal code in the format of its country"},
+	{Name: "district", Type: models.ViewText, Description: "Postal district"},
+	{Name: "country", Type: models.ViewText, Description: "ISO 3166-1 country code"},
+	{Name: "currency", Type: models.ViewText, Description: "ISO 4217 currency of the prices"},
+	{Name: "price", Type: models.ViewInteger, Description: "Current asking price, or sale price once sold"},
+	{Name: "living_area", Type: models.ViewInteger, Description: "Living area in m²"},
+	{Name: "price_per_sqm", Type: models.ViewReal, Description: "Price per m² of living area, rounded"},
+	{Name: "num_rooms", Type: models.ViewInteger, Description: "Number of rooms"},
+	{Name: "year_built", Type: models.ViewInteger, Description: "Construction year"},
+	{Name: "energy_label", Type: models.ViewText, Description: "Energy label"},
+	{Name: "latitude", Type: models.ViewReal, Description: "Latitude of the geocoded address"},
+	{Name: "longitude", Type: models.ViewReal, Description: "Longitude of the geocoded address"},
+	{Name: "agent_id", Type: models.ViewInteger, Description: "Listing agent id"},
+	{Name: "outlier_flags", Type: models.ViewText, Description: "Comma-separated reasons the price or area is implausible"},
+	{Name: "listing_date", Type: models.ViewDate, Description: "Date the home was listed"},
+}
+
+// listingSelect selects listingColumns from properties
+const listingSelect = `id, url, street, neighborhood, property_type, city, postal_code, district,
+		country, currency, price, living_area,
+		CASE WHEN price > 0 AND living_area > 0 THEN ROUND(CAST(price AS REAL) / living_area) END,
+		num_rooms, year_built, energy_label, latitude, longitude, agent_id, outlier_flags, listing_date`
+
+// views are created in order and dropped in reverse, as later views read
+// earlier ones
+var views = []view{
+	{
+		View: models.View{
+			Name:        "v_active_listings",
+			Description: "Homes currently for sale, one row per listing",
+			Columns: append(append([]models.ViewColumn{}, listingColumns...),
+				models.ViewColumn{Name: "days_on_market", Type: models.ViewInteger, Description: "Days since the listing date"},
+			),
+		},
+		query: func(dialect Dialect) string {
+			return `SELECT ` + listingSelect + `,
+				CASE WHEN listing_date IS NOT NULL
+				     THEN CAST(` + dialect.DaysBetween("listing_date", dialect.DateOffset("+0 days")) + ` AS INTEGER) END
+				FROM properties
+				WHERE status IN ('active', 'republished')`
+		},
+	},
+	{
+		View: models.View{
+			Name:        "v_sold_12m",
+			Description: "Homes sold over the past twelve months, one row per sale",
+			Columns: append(append([]models.ViewColumn{}, listingColumns...),
+				models.ViewColumn{Name: "selling_date", Type: models.ViewDate, Description: "Date of the sale"},
+				models.ViewColumn{Name: "selling_date_precision", Type: models.ViewText, Description: "day, or month when only the month of the sale is known"},
+				models.ViewColumn{Name: "days_to_sell", Type: models.ViewInteger, Description: "Days from listing to sale, for exact selling dates"},
+				models.ViewColumn{Name: "first_asking_price", Type: models.ViewInteger, Description: "First asking price seen"},
+			),
+		},
+		query: func(dialect Dialect) string {
+			return `SELECT ` + listingSelect + `, selling_date,
+				COALESCE(selling_date_precision, '` + models.DatePrecisionDay + `'),
+				CASE WHEN listing_date IS NOT NULL AND ` + exactSellingDate("") + `
+				     THEN CAST(` + dialect.DaysBetween("listing_date", "selling_date") + ` AS INTEGER) END,
+				(SELECT h.price FROM property_history h
+				 WHERE h.property_id = properties.id AND h.price IS NOT NULL
+				 ORDER BY h.id LIMIT 1)
+				FROM properties
+				WHERE status = 'sold'
+				AND selling_date >= ` + dialect.DateOffset("-12 months")
+		},
+	},
+	{
+		View: models.View{
+			Name:        "v_district_stats",
+			Description: "Listings and sales of the past twelve months per postal district; averages leave out homes flagged as outliers",
+			Columns: []models.ViewColumn{
+				{Name: "country", Type: models.ViewText, Description: "ISO 3166-1 country code"},
+				{Name: "district", Type: models.ViewText, Description: "Postal district"},
+				{Name: "active_listings", Type: models.ViewInteger, Description: "Homes for sale"},
+				{Name: "avg_asking_price", Type: models.ViewReal, Description: "Average asking price"},
+				{Name: "avg_asking_price_per_sqm", Type: models.ViewReal, Description: "Average asking price per m²"},
+				{Name: "sold_12m", Type: models.ViewInteger, Description: "Homes sold over the past twelve months"},
+				{Name: "avg_sold_price", Type: models.ViewReal, Description: "Average sale price"},
+				{Name: "avg_sold_price_per_sqm", Type: models.ViewReal, Description: "Average sale price per m²"},
+				{Name: "avg_days_to_sell", Type: models.ViewReal, Description: "Average days from listing to sale"},
+			},
+		},
+		query: func(Dialect) string {
+			return `SELECT country, district,
+				SUM(active),
+				ROUND(AVG(CASE WHEN active = 1 AND outlier_flags IS NULL THEN price END)),
+				ROUND(AVG(CASE WHEN active = 1 AND outlier_flags IS NULL THEN price_per_sqm END)),
+				SUM(1 - active),
+				ROUND(AVG(CASE WHEN active = 0 AND outlier_flags IS NULL THEN price END)),
+				ROUND(AVG(CASE WHEN active = 0 AND outlier_flags IS NULL THEN price_per_sqm END)),
+				ROUND(AVG(CASE WHEN active = 0 THEN days_to_sell END))
+				FROM (
+					SELECT country, district, 1 AS active, price, price_per_sqm, outlier_flags,
+					       CAST(NULL AS INTEGER) AS days_to_sell
+					FROM v_active_listings
+					UNION ALL
+					SELECT country, district, 0, price, price_per_sqm, outlier_flags, days_to_sell
+					FROM v_sold_12m
+				) listings
+				WHERE district IS NOT NULL
+				GROUP BY country, district`
+		},
+	},
+}
+
+// Views returns the documentation of the database views
+func Views() []models.View {
+	documented := make([]models.View, len(views))
+	for i, v := range views {
+		documented[i] = v.View
+	}
+	return documented
+}
+
+// lookupView returns the view with a name
+func lookupView(name string) (*view, bool) {
+	for i := range views {
+		if views[i].Name == name {
+			return &views[i], true
+		}
+	}
+	return nil, false
+}
+
+// dropViews drops every view, so migrations can change the tables under them
+func (d *Database) dropViews() error {
+	for i := len(views) - 1; i >= 0; i-- {
+		if _, err := d.db.Exec("DROP VIEW IF EXISTS " + views[i].Name); err != nil {
+			return fmt.Errorf("failed to drop view %s: %v", views[i].Name, err)
+		}
+	}
+	return nil
+}
+
+// ensureViews replaces every view with its current definition
+func (d *Database) ensureViews() error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	for i := len(views) - 1; i >= 0; i-- {
+		if _, err := tx.Exec("DROP VIEW IF EXISTS " + views[i].Name); err != nil {
+			return fmt.Errorf("failed to drop view %s: %v", views[i].Name, err)
+		}
+	}
+	for _, v := range views {
+		names := make([]string, len(v.Columns))
+		for i, column := range v.Columns {
+			names[i] = column.Name
+		}
+		stmt := "CREATE VIEW " + v.Name + " (" + strings.Join(names, ", ") + ") AS " + v.query(d.dialect)
+		if err := execAll(tx, stmt); err != nil {
+			return fmt.Errorf("failed to create view %s: %v", v.Name, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// ViewIterator reads the rows of a view one at a time, like PropertyIterator.
+// Values are nil or, by column type, an *int64, *float64, *string or
+// *time.Time.
+type ViewIterator struct {
+	rows    *sql.Rows
+	columns []models.ViewColumn
+	values  []interface{}
+	err     error
+}
+
+// IterateView returns an iterator over the rows of a view, or nil when there
+// is no view with that name
+func (d *Database) IterateView(ctx context.Context, name string) (*ViewIterator, error) {
+	v, ok := lookupView(name)
+	if !ok {
+		return nil, nil
+	}
+	rows, err := d.db.QueryContext(ctx, "SELECT * FROM "+v.Name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query view %s: %v", v.Name, err)
+	}
+	return &ViewIterator{rows: rows, columns: v.Columns}, nil
+}
+
+// CountView returns the number of rows IterateView returns, 0 when there is
+// no view with that name
+func (d *Database) CountView(ctx context.Context, name string) (int, error) {
+	v, ok := lookupView(name)
+	if !ok {
+		return 0, nil
+	}
+	var count int
+	if err := d.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM "+v.Name).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count view %s: %v", v.Name, err)
+	}
+	return count, nil
+}
+
+// Columns returns the columns of the view
+func (it *ViewIterator) Columns() []models.ViewColumn {
+	return it.columns
+}
+
+// Next reads the next row, returning false at the end or on an error
+func (it *ViewIterator) Next() bool {
+	if it.err != nil || !it.rows.Next() {
+		return false
+	}
+
+	scanned := make([]interface{}, len(it.columns))
+	for i, column := range it.columns {
+		switch column.Type {
+		case models.ViewInteger:
+			scanned[i] = new(sql.NullInt64)
+		case models.ViewReal:
+			scanned[i] = new(sql.NullFloat64)
+		default:
+			scanned[i] = new(sql.NullString)
+		}
+	}
+	if it.err = it.rows.Scan(scanned...); it.err != nil {
+		return false
+	}
+
+	it.values = make([]interface{}, len(it.columns))
+	for i, column := range it.columns {
+		switch v := scanned[i].(type) {
+		case *sql.NullInt64:
+			if v.Valid {
+				it.values[i] = &v.Int64
+			}
+		case *sql.NullFloat64:
+			if v.Valid {
+				it.values[i] = &v.Float64
+			}
+		case *sql.NullString:
+			if !v.Valid {
+				continue
+			}
+			if column.Type != models.ViewDate {
+				it.values[i] = &v.String
+			} else if len(v.String) >= 10 {
+				if date, err := time.Parse("2006-01-02", v.String[:10]); err == nil {
+					it.values[i] = &date
+				}
+			}
+		}
+	}
+	return true
+}
+
+// Values returns the row read by the last call to Next, in column order
+func (it *ViewIterator) Values() []interface{} {
+	return it.values
+}
+
+// Err returns the error that stopped the iteration, if any
+func (it *ViewIterator) Err() error {
+	if it.err != nil {
+		return fmt.Errorf("failed to scan view row: %v", it.err)
+	}
+	return it.rows.Err()
+}
+
+// Close releases the rows of the iterator
+func (it *ViewIterator) Close() error {
+	return it.rows.Close()
+}
@@ -0,0 +1,411 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"fundamental/server/internal/models"
+	"math"
+	"strings"
+)
+
+// itemSoldKey is set on a scraped item that was stored as sold for the first
+// time, to the id of its property
+const itemSoldKey = "_sold"
+
+func scanWatchlist(row rowScanner) (*models.Watchlist, error) {
+	var watchlist models.Watchlist
+	if err := row.Scan(&watchlist.ID, &watchlist.Name, &watchlist.RadiusMeters, &watchlist.CreatedAt, &watchlist.UpdatedAt); err != nil {
+		return nil, err
+	}
+	watchlist.PropertyIDs = []int64{}
+	return &watchlist, nil
+}
+
+// addWatchlistProperties sets the property ids of watchlists
+func (d *Database) addWatchlistProperties(watchlists []*models.Watchlist) error {
+	byID := make(map[int64]*models.Watchlist, len(watchlists))
+	for _, w := range watchlists {
+		byID[w.ID] = w
+	}
+	rows, err := d.db.Query("SELECT watchlist_id, property_id FROM watchlist_properties ORDER BY created_at, property_id")
+	if err != nil {
+		return fmt.Errorf("failed to query watched properties: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var watchlistID, propertyID int64
+		if err := rows.Scan(&watchlistID, &propertyID); err != nil {
+			return fmt.Errorf("failed to scan watched property: %v", err)
+		}
+		if w := byID[watchlistID]; w != nil {
+			w.PropertyIDs = append(w.PropertyIDs, propertyID)
+		}
+	}
+	return rows.Err()
+}
+
+// ListWatchlists returns all watchlists with their properties, newest first
+func (d *Database) ListWatchlists() ([]models.Watchlist, error) {
+	rows, err := d.db.Query("SELECT id, name, radius_meters, created_at, updated_at FROM watchlists ORDER BY id DESC")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query watchlists: %v", err)
+	}
+	defer rows.Close()
+
+	var watchlists []*models.Watchlist
+	for rows.Next() {
+		watchlist, err := scanWatchlist(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan watchlist: %v", err)
+		}
+		watchlists = append(watchlists, watchlist)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating watchlists: %v", err)
+	}
+	rows.Close()
+
+	if err := d.addWatchlistProperties(watchlists); err != nil {
+		return nil, err
+	}
+	result := make([]models.Watchlist, len(watchlists))
+	for i, w := range watchlists {
+		result[i] = *w
+	}
+	return result, nil
+}
+
+// GetWatchlist returns a watchlist with its properties, or nil if it does not
+// exist
+func (d *Database) GetWatchlist(id int64) (*models.Watchlist, error) {
+	row := d.db.QueryRow("SELECT id, name, radius_meters, created_at, updated_at FROM watchlists WHERE id = ?", id)
+	watchlist, err := scanWatchlist(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get watchlist: %v", err)
+	}
+	if err := d.addWatchlistProperties([]*models.Watchlist{watchlist}); err != nil {
+		return nil, err
+	}
+	return watchlist, nil
+}
+
+// CreateWatchlist stores a new, empty watchlist
+func (d *Database) CreateWatchlist(req models.WatchlistRequest) (*models.Watchlist, error) {
+	var id int64
+	err := d.db.QueryRow(`
+		INSERT INTO watchlists (name, radius_meters)
+		VALUES (?, ?)
+		RETURNING id
+	`, req.Name, req.RadiusMeters).Scan(&id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert watchlist: %v", err)
+	}
+	return d.GetWatchlist(id)
+}
+
+// UpdateWatchlist replaces the name and radius of a watchlist. Returns nil if
+// it does not exist.
+func (d *Database) UpdateWatchlist(id int64, req models.WatchlistRequest) (*models.Watchlist, error) {
+	result, err := d.db.Exec(`
+		UPDATE watchlists SET name = ?, radius_meters = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?
+	`, req.Name, req.RadiusMeters, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update watchlist: %v", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rows affected: %v", err)
+	}
+	if n == 0 {
+		return nil, nil
+	}
+	return d.GetWatchlist(id)
+}
+
+// DeleteWatchlist removes a watchlist with its properties and alerts. Returns
+// false if it does not exist.
+func (d *Database) DeleteWatchlist(id int64) (bool, error) {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM watch_alerts WHERE watchlist_id = ?", id); err != nil {
+		return false, fmt.Errorf("failed to delete watch alerts: %v", err)
+	}
+	if _, err := tx.Exec("DELETE FROM watchlist_properties WHERE watchlist_id = ?", id); err != nil {
+		return false, fmt.Errorf("failed to delete watched properties: %v", err)
+	}
+	result, err := tx.Exec("DELETE FROM watchlists WHERE id = ?", id)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete watchlist: %v", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return n > 0, nil
+}
+
+// AddWatchlistProperty adds a property to a watchlist; adding it twice is a
+// no-op. Returns false if the property does not exist.
+func (d *Database) AddWatchlistProperty(watchlistID, propertyID int64) (bool, error) {
+	result, err := d.db.Exec(`
+		INSERT INTO watchlist_properties (watchlist_id, property_id)
+		SELECT ?, id FROM properties WHERE id = ?
+		ON CONFLICT (watchlist_id, property_id) DO NOTHING
+	`, watchlistID, propertyID)
+	if err != nil {
+		return false, fmt.Errorf("failed to add watched property: %v", err)
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %v", err)
+	} else if n > 0 {
+		return true, nil
+	}
+	var exists int
+	if err := d.db.QueryRow("SELECT COUNT(*) FROM properties WHERE id = ?", propertyID).Scan(&exists); err != nil {
+		return false, fmt.Errorf("failed to look up property: %v", err)
+	}
+	return exists > 0, nil
+}
+
+// RemoveWatchlistProperty removes a property from a watchlist with its
+// alerts. Returns false if it was not on the watchlist.
+func (d *Database) RemoveWatchlistProperty(watchlistID, propertyID int64) (bool, error) {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM watch_alerts WHERE watchlist_id = ? AND property_id = ?", watchlistID, propertyID); err != nil {
+		return false, fmt.Errorf("failed to delete watch alerts: %v", err)
+	}
+	result, err := tx.Exec("DELETE FROM watchlist_properties WHERE watchlist_id = ? AND property_id = ?", watchlistID, propertyID)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete watched property: %v", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return n > 0, nil
+}
+
+// propertiesByID returns the properties with the given ids by id
+func (d *Database) propertiesByID(ids []int64) (map[int64]models.Property, error) {
+	properties := make(map[int64]models.Property, len(ids))
+	for start := 0; start < len(ids); start += archiveBatchSize {
+		batch := ids[start:min(start+archiveBatchSize, len(ids))]
+		args := make([]interface{}, len(batch))
+		for i, id := range batch {
+			args[i] = id
+		}
+		err := d.forEachProperty(`
+			SELECT `+propertyColumns+`
+			FROM properties
+			WHERE id IN (?`+strings.Repeat(", ?", len(batch)-1)+`)
+		`, args, func(p models.Property) error {
+			properties[p.ID] = p
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get properties: %v", err)
+		}
+	}
+	return properties, nil
+}
+
+// setImpliedValue values the watched property of an alert at the sold price
+// per m² of the comparable
+func setImpliedValue(alert *models.WatchAlert) {
+	comparable, watched := alert.Comparable, alert.Property
+	if comparable.Price <= 0 || comparable.LivingArea == nil || *comparable.LivingArea <= 0 ||
+		watched.LivingArea == nil || *watched.LivingArea <= 0 {
+		return
+	}
+	value := int(math.Round(float64(comparable.Price) / float64(*comparable.LivingArea) * float64(*watched.LivingArea)))
+	alert.ImpliedValue = &value
+	if watched.Price > 0 {
+		change := math.Round((float64(value)/float64(watched.Price)-1)*1000) / 10
+		alert.ImpliedChange = &change
+	}
+}
+
+// listingChain returns the id of the first listing of a property's home
+func listingChain(p *models.Property) int64 {
+	if p.CanonicalID != nil {
+		return *p.CanonicalID
+	}
+	return p.ID
+}
+
+// isWatchComparable reports whether a sold home compares to a watched one:
+// another home of the same type, when both are known, and of a similar size
+func isWatchComparable(watched, sold *models.Property) bool {
+	// Listings of the same home share the id of its first listing
+	if listingChain(watched) == listingChain(sold) {
+		return false
+	}
+	if watched.PropertyType != "" && sold.PropertyType != "" && !strings.EqualFold(watched.PropertyType, sold.PropertyType) {
+		return false
+	}
+	if watched.LivingArea != nil && sold.LivingArea != nil {
+		area, soldArea := float64(*watched.LivingArea), float64(*sold.LivingArea)
+		if soldArea < area/bidSimilarArea || soldArea > area*bidSimilarArea {
+			return false
+		}
+	}
+	return true
+}
+
+// MatchWatchComparables raises an alert for every watched property that one
+// of the given sold properties is a comparable of, within the radius of the
+// property's watchlist. Properties without coordinates are skipped, and a sale
+// raises an alert only once per watched property and watchlist. It returns the
+// new alerts.
+func (d *Database) MatchWatchComparables(soldIDs []int64) ([]models.WatchAlert, error) {
+	if len(soldIDs) == 0 {
+		return nil, nil
+	}
+
+	type watch struct {
+		watchlistID int64
+		name        string
+		radius      float64
+		propertyID  int64
+	}
+	rows, err := d.db.Query(`
+		SELECT w.id, w.name, w.radius_meters, wp.property_id
+		FROM watchlist_properties wp
+		JOIN watchlists w ON w.id = wp.watchlist_id
+		ORDER BY w.id, wp.property_id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query watched properties: %v", err)
+	}
+	var watches []watch
+	ids := append([]int64{}, soldIDs...)
+	for rows.Next() {
+		var w watch
+		if err := rows.Scan(&w.watchlistID, &w.name, &w.radius, &w.propertyID); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan watched property: %v", err)
+		}
+		watches = append(watches, w)
+		ids = append(ids, w.propertyID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating watched properties: %v", err)
+	}
+	if len(watches) == 0 {
+		return nil, nil
+	}
+
+	properties, err := d.propertiesByID(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	var alerts []models.WatchAlert
+	for _, soldID := range soldIDs {
+		sold, ok := properties[soldID]
+		if !ok || sold.Status != "sold" || sold.Latitude == nil || sold.Longitude == nil {
+			continue
+		}
+		for _, w := range watches {
+			watched, ok := properties[w.propertyID]
+			if !ok || watched.Latitude == nil || watched.Longitude == nil || !isWatchComparable(&watched, &sold) {
+				continue
+			}
+			distance := haversineMeters(*watched.Latitude, *watched.Longitude, *sold.Latitude, *sold.Longitude)
+			if distance > w.radius {
+				continue
+			}
+
+			alert := models.WatchAlert{
+				WatchlistID:    w.watchlistID,
+				WatchlistName:  w.name,
+				Property:       watched,
+				Comparable:     sold,
+				DistanceMeters: math.Round(distance),
+			}
+			err := d.db.QueryRow(`
+				INSERT INTO watch_alerts (watchlist_id, property_id, comparable_id, distance_meters)
+				VALUES (?, ?, ?, ?)
+				ON CONFLICT (watchlist_id, property_id, comparable_id) DO NOTHING
+				RETURNING id, created_at
+			`, w.watchlistID, watched.ID, sold.ID, alert.DistanceMeters).Scan(&alert.ID, &alert.CreatedAt)
+			if err == sql.ErrNoRows {
+				continue // already raised
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to insert watch alert: %v", err)
+			}
+			setImpliedValue(&alert)
+			alerts = append(alerts, alert)
+		}
+	}
+	return alerts, nil
+}
+
+// ListWatchAlerts returns the alerts of a watchlist, newest first
+func (d *Database) ListWatchAlerts(watchlistID int64, limit int) ([]models.WatchAlert, error) {
+	rows, err := d.db.Query(`
+		SELECT a.id, a.watchlist_id, w.name, a.property_id, a.comparable_id, a.distance_meters, a.created_at
+		FROM watch_alerts a
+		JOIN watchlists w ON w.id = a.watchlist_id
+		WHERE a.watchlist_id = ?
+		ORDER BY a.id DESC
+		LIMIT ?
+	`, watchlistID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query watch alerts: %v", err)
+	}
+	var alerts []models.WatchAlert
+	var ids []int64
+	for rows.Next() {
+		var alert models.WatchAlert
+		if err := rows.Scan(&alert.ID, &alert.WatchlistID, &alert.WatchlistName, &alert.Property.ID,
+			&alert.Comparable.ID, &alert.DistanceMeters, &alert.CreatedAt); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan watch alert: %v", err)
+		}
+		alerts = append(alerts, alert)
+		ids = append(ids, alert.Property.ID, alert.Comparable.ID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating watch alerts: %v", err)
+	}
+
+	properties, err := d.propertiesByID(ids)
+	if err != nil {
+		return nil, err
+	}
+	result := []models.WatchAlert{}
+	for _, alert := range alerts {
+		watched, watchedOK := properties[alert.Property.ID]
+		comparable, comparableOK := properties[alert.Comparable.ID]
+		if !watchedOK || !comparableOK {
+			continue
+		}
+		alert.Property, alert.Comparable = watched, comparable
+		setImpliedValue(&alert)
+		result = append(result, alert)
+	}
+	return result, nil
+}
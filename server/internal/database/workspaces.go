@@ -0,0 +1,121 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"fundamental/server/internal/models"
+	"time"
+)
+
+// ErrWorkspaceExists is returned when a workspace is created with the slug of
+// another workspace
+var ErrWorkspaceExists = newError(ErrConflict, "a workspace with this slug already exists")
+
+const workspaceColumns = `slug, name, created_at`
+
+func scanWorkspace(row rowScanner) (*models.Workspace, error) {
+	var workspace models.Workspace
+	if err := row.Scan(&workspace.Slug, &workspace.Name, &workspace.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &workspace, nil
+}
+
+// CreateWorkspace records a new workspace. Returns ErrWorkspaceExists when
+// the slug is taken.
+func (d *Database) CreateWorkspace(slug, name string) (*models.Workspace, error) {
+	existing, err := d.GetWorkspace(slug)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, ErrWorkspaceExists
+	}
+
+	workspace, err := scanWorkspace(d.db.QueryRow(`
+		INSERT INTO workspaces (slug, name, created_at) VALUES (?, ?, ?)
+		RETURNING `+workspaceColumns, slug, name, time.Now().UTC()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert workspace: %v", err)
+	}
+	return workspace, nil
+}
+
+// ListWorkspaces returns all workspaces by slug
+func (d *Database) ListWorkspaces() ([]models.Workspace, error) {
+	rows, err := d.db.Query("SELECT " + workspaceColumns + " FROM workspaces ORDER BY slug")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query workspaces: %v", err)
+	}
+	defer rows.Close()
+
+	workspaces := []models.Workspace{}
+	for rows.Next() {
+		workspace, err := scanWorkspace(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan workspace: %v", err)
+		}
+		workspaces = append(workspaces, *workspace)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating workspaces: %v", err)
+	}
+	return workspaces, nil
+}
+
+// GetWorkspace returns a workspace, or nil if it does not exist
+func (d *Database) GetWorkspace(slug string) (*models.Workspace, error) {
+	workspace, err := scanWorkspace(d.db.QueryRow("SELECT "+workspaceColumns+" FROM workspaces WHERE slug = ?", slug))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get workspace: %v", err)
+	}
+	return workspace, nil
+}
+
+// RenameWorkspace changes the name of a workspace. Returns nil if it does not
+// exist.
+func (d *Database) RenameWorkspace(slug, name string) (*models.Workspace, error) {
+	workspace, err := scanWorkspace(d.db.QueryRow(`
+		UPDATE workspaces SET name = ? WHERE slug = ?
+		RETURNING `+workspaceColumns, name, slug))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to rename workspace: %v", err)
+	}
+	return workspace, nil
+}
+
+// DeleteWorkspace removes a workspace and revokes its tokens. Returns false if
+// it does not exist.
+func (d *Database) DeleteWorkspace(slug string) (bool, error) {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("DELETE FROM workspaces WHERE slug = ?", slug)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete workspace: %v", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %v", err)
+	}
+	if n == 0 {
+		return false, nil
+	}
+	_, err = tx.Exec("UPDATE api_tokens SET revoked_at = ? WHERE workspace = ? AND revoked_at IS NULL", time.Now().UTC(), slug)
+	if err != nil {
+		return false, fmt.Errorf("failed to revoke workspace tokens: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return true, nil
+}
@@ -0,0 +1,280 @@
+// Package errorsink forwards error-level logs and panics to an external error
+// tracker such as Sentry or a generic JSON webhook.
+package errorsink
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"os"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	queueSize    = 100
+	sendTimeout  = 10 * time.Second
+	moduleField  = "module"
+	defaultLevel = "error"
+)
+
+// Config selects where errors are reported. Both sinks may be set at once.
+type Config struct {
+	Enabled     bool
+	SentryDSN   string
+	WebhookURL  string
+	Environment string
+}
+
+// Event is a reported error or panic
+type Event struct {
+	ID          string                 `json:"event_id"`
+	Timestamp   time.Time              `json:"timestamp"`
+	Level       string                 `json:"level"`
+	Module      string                 `json:"module"`
+	Message     string                 `json:"message"`
+	Error       string                 `json:"error,omitempty"`
+	Stack       string                 `json:"stack,omitempty"`
+	Fields      map[string]interface{} `json:"fields,omitempty"`
+	Environment string                 `json:"environment,omitempty"`
+	ServerName  string                 `json:"server_name,omitempty"`
+}
+
+// transport delivers events to one sink
+type transport interface {
+	Send(event Event) error
+}
+
+// Reporter queues events and delivers them in the background. It implements
+// logrus.Hook so it can be attached to a logger.
+type Reporter struct {
+	enabled     atomic.Bool
+	transports  []transport
+	environment string
+	serverName  string
+	logger      *logrus.Logger
+	queue       chan Event
+	wg          sync.WaitGroup
+	closeOnce   sync.Once
+}
+
+var defaultReporter atomic.Pointer[Reporter]
+
+// New creates a reporter for the configured sinks. The logger is only used to
+// report delivery failures, at warning level so they are not reported again.
+func New(cfg Config, logger *logrus.Logger) (*Reporter, error) {
+	client := &http.Client{Timeout: sendTimeout}
+	r := &Reporter{
+		environment: cfg.Environment,
+		logger:      logger,
+		queue:       make(chan Event, queueSize),
+	}
+	r.serverName, _ = os.Hostname()
+
+	if cfg.SentryDSN != "" {
+		sentry, err := newSentryTransport(cfg.SentryDSN, client)
+		if err != nil {
+			return nil, err
+		}
+		r.transports = append(r.transports, sentry)
+	}
+	if cfg.WebhookURL != "" {
+		r.transports = append(r.transports, &webhookTransport{url: cfg.WebhookURL, client: client})
+	}
+
+	r.enabled.Store(cfg.Enabled && r.Configured())
+	if r.Configured() {
+		r.wg.Add(1)
+		go r.run()
+	}
+	return r, nil
+}
+
+// SetDefault makes r the reporter used by Recover, GinRecovery and Default
+func SetDefault(r *Reporter) {
+	defaultReporter.Store(r)
+}
+
+// Default returns the reporter set with SetDefault, or nil
+func Default() *Reporter {
+	return defaultReporter.Load()
+}
+
+// Configured reports whether at least one sink is set up
+func (r *Reporter) Configured() bool {
+	return r != nil && len(r.transports) > 0
+}
+
+// Enabled reports whether events are currently being reported
+func (r *Reporter) Enabled() bool {
+	return r != nil && r.enabled.Load()
+}
+
+// SetEnabled switches reporting on or off at runtime. Enabling has no effect
+// when no sink is configured.
+func (r *Reporter) SetEnabled(enabled bool) {
+	if r != nil {
+		r.enabled.Store(enabled && r.Configured())
+	}
+}
+
+// Capture queues an event for delivery. Events are dropped when the queue is full.
+func (r *Reporter) Capture(event Event) {
+	if !r.Enabled() {
+		return
+	}
+	select {
+	case r.queue <- r.complete(event):
+	default:
+		r.logger.WithField("message", event.Message).Warn("Error report queue is full, dropping event")
+	}
+}
+
+// CaptureSync delivers an event before returning, for use right before the process exits
+func (r *Reporter) CaptureSync(event Event) {
+	if r.Enabled() {
+		r.send(r.complete(event))
+	}
+}
+
+// Close stops the background delivery after sending queued events, waiting at most timeout
+func (r *Reporter) Close(timeout time.Duration) {
+	if !r.Configured() {
+		return
+	}
+	r.closeOnce.Do(func() { close(r.queue) })
+
+	done := make(chan struct{})
+	go func() {
+		r.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		r.logger.Warn("Timed out delivering queued error reports")
+	}
+}
+
+// complete fills the fields every event carries
+func (r *Reporter) complete(event Event) Event {
+	if event.ID == "" {
+		id := make([]byte, 16)
+		_, _ = rand.Read(id)
+		event.ID = hex.EncodeToString(id)
+	}
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now().UTC()
+	}
+	if event.Level == "" {
+		event.Level = defaultLevel
+	}
+	event.Environment = r.environment
+	event.ServerName = r.serverName
+	return event
+}
+
+func (r *Reporter) run() {
+	defer r.wg.Done()
+	for event := range r.queue {
+		r.send(event)
+	}
+}
+
+func (r *Reporter) send(event Event) {
+	for _, t := range r.transports {
+		if err := t.Send(event); err != nil {
+			r.logger.WithError(err).Warn("Failed to deliver error report")
+		}
+	}
+}
+
+// Levels implements logrus.Hook
+func (r *Reporter) Levels() []logrus.Level {
+	return []logrus.Level{logrus.ErrorLevel, logrus.FatalLevel, logrus.PanicLevel}
+}
+
+// Fire implements logrus.Hook. Fatal and panic entries are delivered
+// synchronously because the process stops right after them.
+func (r *Reporter) Fire(entry *logrus.Entry) error {
+	if !r.Enabled() {
+		return nil
+	}
+
+	event := Event{
+		Timestamp: entry.Time.UTC(),
+		Level:     entry.Level.String(),
+		Module:    "server",
+		Message:   entry.Message,
+		Fields:    make(map[string]interface{}, len(entry.Data)),
+	}
+	for key, value := range entry.Data {
+		switch {
+		case key == moduleField:
+			event.Module = fmt.Sprint(value)
+		case key == logrus.ErrorKey:
+			event.Error = fmt.Sprint(value)
+		default:
+			// Keep JSON-friendly values, stringify the rest
+			switch value.(type) {
+			case string, bool, int, int64, float64, nil:
+				event.Fields[key] = value
+			default:
+				event.Fields[key] = fmt.Sprint(value)
+			}
+		}
+	}
+
+	if entry.Level <= logrus.FatalLevel {
+		r.CaptureSync(event)
+	} else {
+		r.Capture(event)
+	}
+	return nil
+}
+
+// panicEvent describes a recovered panic
+func panicEvent(module, level string, recovered interface{}, fields map[string]interface{}) Event {
+	return Event{
+		Level:   level,
+		Module:  module,
+		Message: fmt.Sprintf("panic: %v", recovered),
+		Error:   fmt.Sprint(recovered),
+		Stack:   string(debug.Stack()),
+		Fields:  fields,
+	}
+}
+
+// Recover reports a panic of the calling goroutine and panics again. Use it as
+// the first deferred call of long-running goroutines:
+//
+//	defer errorsink.Recover("scheduler")
+func Recover(module string) {
+	if recovered := recover(); recovered != nil {
+		Default().CaptureSync(panicEvent(module, "fatal", recovered, nil))
+		panic(recovered)
+	}
+}
+
+// GinRecovery reports panics in request handlers and passes them on to gin's
+// own recovery, which logs them and answers with 500
+func GinRecovery() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				Default().Capture(panicEvent("api", "error", recovered, map[string]interface{}{
+					"method": c.Request.Method,
+					"path":   c.FullPath(),
+				}))
+				panic(recovered)
+			}
+		}()
+		c.Next()
+	}
+}
@@ -0,0 +1,45 @@
+package errorsink
+
+import "github.com/sirupsen/logrus"
+
+// moduleHook tags every entry of a logger with the subsystem it belongs to
+type moduleHook struct {
+	module string
+}
+
+func (h moduleHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (h moduleHook) Fire(entry *logrus.Entry) error {
+	if _, ok := entry.Data[moduleField]; !ok {
+		entry.Data[moduleField] = h.module
+	}
+	return nil
+}
+
+// WithModule returns a copy of logger that adds a "module" field to every
+// entry, so logs and error reports show which subsystem they come from. The
+// copy shares the output, formatter and hooks that logger has at the time of
+// the call.
+func WithModule(logger *logrus.Logger, module string) *logrus.Logger {
+	hooks := make(logrus.LevelHooks)
+	hooks.Add(moduleHook{module: module})
+	for level, levelHooks := range logger.Hooks {
+		for _, hook := range levelHooks {
+			if _, ok := hook.(moduleHook); !ok {
+				hooks[level] = append(hooks[level], hook)
+			}
+		}
+	}
+
+	return &logrus.Logger{
+		Out:          logger.Out,
+		Hooks:        hooks,
+		Formatter:    logger.Formatter,
+		ReportCaller: logger.ReportCaller,
+		Level:        logger.GetLevel(),
+		ExitFunc:     logger.ExitFunc,
+		BufferPool:   logger.BufferPool,
+	}
+}
@@ -0,0 +1,109 @@
+package errorsink
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// post sends a JSON body and treats any non-2xx answer as an error
+func post(client *http.Client, endpoint string, body interface{}, headers map[string]string) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %v", err)
+	}
+	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send event: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("sink answered %s: %s", resp.Status, strings.TrimSpace(string(message)))
+	}
+	return nil
+}
+
+// webhookTransport posts events as JSON to an arbitrary URL
+type webhookTransport struct {
+	url    string
+	client *http.Client
+}
+
+func (t *webhookTransport) Send(event Event) error {
+	return post(t.client, t.url, event, nil)
+}
+
+// sentryTransport posts events to the store endpoint of a Sentry project
+type sentryTransport struct {
+	endpoint  string
+	publicKey string
+	client    *http.Client
+}
+
+// newSentryTransport parses a DSN of the form https://<key>@<host>[/<path>]/<project>
+func newSentryTransport(dsn string, client *http.Client) (*sentryTransport, error) {
+	u, err := url.Parse(dsn)
+	if err != nil || u.User == nil || u.User.Username() == "" || u.Host == "" {
+		return nil, fmt.Errorf("invalid Sentry DSN")
+	}
+	path := strings.Trim(u.Path, "/")
+	slash := strings.LastIndex(path, "/")
+	project, prefix := path[slash+1:], ""
+	if slash >= 0 {
+		prefix = "/" + path[:slash]
+	}
+	if project == "" {
+		return nil, fmt.Errorf("invalid Sentry DSN: missing project ID")
+	}
+
+	return &sentryTransport{
+		endpoint:  fmt.Sprintf("%s://%s%s/api/%s/store/", u.Scheme, u.Host, prefix, project),
+		publicKey: u.User.Username(),
+		client:    client,
+	}, nil
+}
+
+func (t *sentryTransport) Send(event Event) error {
+	extra := make(map[string]interface{}, len(event.Fields)+1)
+	for key, value := range event.Fields {
+		extra[key] = value
+	}
+	if event.Stack != "" {
+		extra["stack"] = event.Stack
+	}
+
+	body := map[string]interface{}{
+		"event_id":    event.ID,
+		"timestamp":   event.Timestamp.Format("2006-01-02T15:04:05Z"),
+		"level":       event.Level,
+		"logger":      event.Module,
+		"platform":    "go",
+		"message":     event.Message,
+		"environment": event.Environment,
+		"server_name": event.ServerName,
+		"tags":        map[string]string{"module": event.Module},
+		"extra":       extra,
+	}
+	if event.Error != "" {
+		body["exception"] = map[string]interface{}{
+			"values": []map[string]string{{"type": event.Message, "value": event.Error, "module": event.Module}},
+		}
+	}
+
+	auth := fmt.Sprintf("Sentry sentry_version=7, sentry_client=fundamental/1.0, sentry_key=%s", t.publicKey)
+	return post(t.client, t.endpoint, body, map[string]string{"X-Sentry-Auth": auth})
+}
@@ -0,0 +1,239 @@
+package format
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Settings are the locale and currency amounts are formatted with, as stored
+// in the format.currency preference
+type Settings struct {
+	Locale   string `json:"locale"`
+	Currency string `json:"currency"`
+}
+
+// DefaultSettings format amounts the Dutch way in euros
+var DefaultSettings = Settings{Locale: "nl-NL", Currency: "EUR"}
+
+// locale describes how numbers and amounts are written
+type locale struct {
+	group         string // thousands separator
+	decimal       string
+	symbolAfter   bool // "450.000 €" instead of "€ 450.000"
+	symbolSpace   bool // space between the symbol and the amount
+	percentSpaced bool // "12,5 %" instead of "12,5%"
+}
+
+var locales = map[string]locale{
+	"nl-NL": {group: ".", decimal: ",", symbolSpace: true},
+	"nl-BE": {group: ".", decimal: ",", symbolAfter: true, symbolSpace: true},
+	"en-GB": {group: ",", decimal: "."},
+	"en-US": {group: ",", decimal: "."},
+	"de-DE": {group: ".", decimal: ",", symbolAfter: true, symbolSpace: true, percentSpaced: true},
+	"fr-FR": {group: "\u202f", decimal: ",", symbolAfter: true, symbolSpace: true, percentSpaced: true},
+}
+
+// languageLocales are the locales of the languages of locales, used for tags
+// without a supported region such as "nl" or "en-AU"
+var languageLocales = map[string]string{
+	"nl": "nl-NL",
+	"en": "en-GB",
+	"de": "de-DE",
+	"fr": "fr-FR",
+}
+
+var currencySymbols = map[string]string{
+	"EUR": "€",
+	"GBP": "£",
+	"USD": "$",
+	"CHF": "CHF",
+}
+
+// Locales returns the supported locales
+func Locales() []string {
+	names := make([]string, 0, len(locales))
+	for name := range locales {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// canonicalLocale writes a locale tag as language-REGION, e.g. nl_nl as nl-NL
+func canonicalLocale(tag string) string {
+	language, region, found := strings.Cut(strings.ReplaceAll(strings.TrimSpace(tag), "_", "-"), "-")
+	if !found {
+		return strings.ToLower(language)
+	}
+	return strings.ToLower(language) + "-" + strings.ToUpper(region)
+}
+
+// MatchLocale returns the supported locale of a language tag: the locale
+// itself, or the main locale of its language, e.g. nl-NL for "nl" or "nl_nl"
+func MatchLocale(tag string) (string, bool) {
+	canonical := canonicalLocale(tag)
+	if _, ok := locales[canonical]; ok {
+		return canonical, true
+	}
+	language, _, _ := strings.Cut(canonical, "-")
+	locale, ok := languageLocales[language]
+	return locale, ok
+}
+
+// Negotiate returns the supported locale best matching an Accept-Language
+// header such as "nl-BE,nl;q=0.9,en;q=0.8", or "" when none matches
+func Negotiate(acceptLanguage string) string {
+	type candidate struct {
+		tag     string
+		quality float64
+	}
+	var candidates []candidate
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		quality := 1.0
+		if q, found := strings.CutPrefix(strings.TrimSpace(params), "q="); found {
+			var err error
+			if quality, err = strconv.ParseFloat(q, 64); err != nil {
+				continue
+			}
+		}
+		if tag != "" && tag != "*" && quality > 0 {
+			candidates = append(candidates, candidate{tag, quality})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].quality > candidates[j].quality })
+	for _, c := range candidates {
+		if locale, ok := MatchLocale(c.tag); ok {
+			return locale
+		}
+	}
+	return ""
+}
+
+// Validate checks that the locale is supported and the currency is a
+// three-letter ISO 4217 code. Empty fields are allowed and use the defaults.
+func (s Settings) Validate() error {
+	if s.Locale != "" {
+		if _, ok := locales[canonicalLocale(s.Locale)]; !ok {
+			return fmt.Errorf("unsupported locale %q, use one of %s", s.Locale, strings.Join(Locales(), ", "))
+		}
+	}
+	if s.Currency != "" {
+		code := strings.ToUpper(s.Currency)
+		if len(code) != 3 || strings.Trim(code, "ABCDEFGHIJKLMNOPQRSTUVWXYZ") != "" {
+			return fmt.Errorf("currency %q is not a three-letter currency code", s.Currency)
+		}
+	}
+	return nil
+}
+
+// Merge fills the empty fields of s from fallback
+func (s Settings) Merge(fallback Settings) Settings {
+	if s.Locale == "" {
+		s.Locale = fallback.Locale
+	}
+	if s.Currency == "" {
+		s.Currency = fallback.Currency
+	}
+	return s
+}
+
+// Formatter formats numbers, amounts and percentages for a locale and currency
+type Formatter struct {
+	locale locale
+	symbol string
+}
+
+// New creates a formatter. Unsupported locales fall back to nl-NL; currencies
+// without a known symbol are written as their code.
+func New(settings Settings) *Formatter {
+	settings = settings.Merge(DefaultSettings)
+	loc, ok := locales[canonicalLocale(settings.Locale)]
+	if !ok {
+		loc = locales[DefaultSettings.Locale]
+	}
+	code := strings.ToUpper(settings.Currency)
+	symbol, ok := currencySymbols[code]
+	if !ok {
+		symbol = code
+	}
+	return &Formatter{locale: loc, symbol: symbol}
+}
+
+// Plain formats a number without thousands separators and with as many
+// decimals as it has, as spreadsheets of the locale read it, e.g. "52,37"
+func (f *Formatter) Plain(value float64) string {
+	return strings.Replace(strconv.FormatFloat(value, 'f', -1, 64), ".", f.locale.decimal, 1)
+}
+
+// ListSeparator returns the separator of values in a list, such as the fields
+// of a CSV file: a semicolon where the decimal separator is a comma
+func (f *Formatter) ListSeparator() rune {
+	if f.locale.decimal == "," {
+		return ';'
+	}
+	return ','
+}
+
+// Number formats a number with thousands separators and the given number of
+// decimals
+func (f *Formatter) Number(value float64, decimals int) string {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return "-"
+	}
+	digits := strconv.FormatFloat(math.Abs(value), 'f', decimals, 64)
+	whole, fraction, _ := strings.Cut(digits, ".")
+
+	var b strings.Builder
+	if value < 0 && strings.Trim(digits, "0.") != "" {
+		b.WriteString("-")
+	}
+	for i, digit := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			b.WriteString(f.locale.group)
+		}
+		b.WriteRune(digit)
+	}
+	if fraction != "" {
+		b.WriteString(f.locale.decimal)
+		b.WriteString(fraction)
+	}
+	return b.String()
+}
+
+// Money formats an amount in whole currency units, e.g. "€ 450.000" for nl-NL
+// or "€450,000" for en-GB
+func (f *Formatter) Money(value float64) string {
+	amount := f.Number(value, 0)
+	space := ""
+	if f.locale.symbolSpace {
+		space = " "
+	}
+	if f.locale.symbolAfter {
+		return amount + space + f.symbol
+	}
+	if strings.HasPrefix(amount, "-") {
+		return "-" + f.symbol + space + amount[1:]
+	}
+	return f.symbol + space + amount
+}
+
+// Percent formats a percentage, e.g. "12,5%" for 12.5 with one decimal
+func (f *Formatter) Percent(value float64, decimals int) string {
+	if f.locale.percentSpaced {
+		return f.Number(value, decimals) + " %"
+	}
+	return f.Number(value, decimals) + "%"
+}
+
+// SignedPercent formats a percentage change with a sign, e.g. "+3,5%"
+func (f *Formatter) SignedPercent(value float64, decimals int) string {
+	percent := f.Percent(value, decimals)
+	if strings.HasPrefix(percent, "-") {
+		return percent
+	}
+	return "+" + percent
+}
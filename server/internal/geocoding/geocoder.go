@@ -21,7 +21,28 @@ type Geocoder struct {
 	cacheLock sync.RWMutex
 	client    *http.Client
 	rateLimit time.Duration
-	lastCall  time.Time
+}
+
+// nominatimLimiter spaces the Nominatim requests of every geocoder in the
+// process, so the spider managers and the API handlers together stay within
+// the usage policy instead of each sending a request per second
+var nominatimLimiter limiter
+
+// limiter lets one caller through at a time, at least an interval after the
+// previous one
+type limiter struct {
+	mu   sync.Mutex
+	last time.Time
+}
+
+// wait blocks until interval has passed since the previous caller got through
+func (l *limiter) wait(interval time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if wait := interval - time.Since(l.last); wait > 0 {
+		time.Sleep(wait)
+	}
+	l.last = time.Now()
 }
 
 type GeocodingResult struct {
@@ -55,6 +76,13 @@ func NewGeocoder(logger *logrus.Logger, cacheDir string) *Geocoder {
 	return g
 }
 
+// SetTransport sends the geocoding requests through rt without the rate limit
+// of Nominatim, for stubs answering them locally
+func (g *Geocoder) SetTransport(rt http.RoundTripper) {
+	g.client.Transport = rt
+	g.rateLimit = 0
+}
+
 func (g *Geocoder) loadCache() {
 	cacheFile := filepath.Join(g.cacheDir, "geocode_cache.json")
 	data, err := os.ReadFile(cacheFile)
@@ -121,7 +149,7 @@ func (g *Geocoder) GeocodeAddress(street, postalCode, city string) (float64, flo
 	g.logger.WithField("address", fullAddress).Info("Geocoding address with Nominatim")
 
 	// Respect Nominatim's usage policy
-	time.Sleep(time.Second)
+	nominatimLimiter.wait(g.rateLimit)
 
 	// Build the query
 	params := url.Values{
@@ -197,10 +225,7 @@ func (g *Geocoder) GeocodeCity(city string) (*GeocodingResult, error) {
 	}
 
 	// Rate limiting
-	if time.Since(g.lastCall) < g.rateLimit {
-		time.Sleep(g.rateLimit - time.Since(g.lastCall))
-	}
-	g.lastCall = time.Now()
+	nominatimLimiter.wait(g.rateLimit)
 
 	// Construct the query with Netherlands context
 	query := fmt.Sprintf("%s, Netherlands", city)
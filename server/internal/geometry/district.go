@@ -3,10 +3,11 @@ package geometry
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"fundamental/server/internal/database"
+	"fundamental/server/internal/models"
 	"io"
-	"net/http"
-	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -18,6 +19,14 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
+// DistrictHullsFile is the name of the GeoJSON file of the district hulls in
+// the output directory
+const DistrictHullsFile = "district_hulls.geojson"
+
+// ErrInvalidBoundaries is returned for imported district boundaries that are
+// not usable
+var ErrInvalidBoundaries = errors.New("invalid district boundaries")
+
 type DistrictPoint struct {
 	Latitude  float64
 	Longitude float64
@@ -31,27 +40,28 @@ type District struct {
 }
 
 type DistrictManager struct {
-	db     *sql.DB
-	logger *logrus.Logger
+	db          *sql.DB
+	boundaries  database.DistrictBoundaryStore // versions of the boundaries
+	pdokCache   database.PDOKCacheStore        // points fetched from PDOK
+	pdokTTL     time.Duration                  // how long cached points are used, 0 disables the cache
+	pdokOffline bool                           // use cached points only
+	outputDir   string                         // directory the DistrictHullsFile is written to
+	logger      *logrus.Logger
 }
 
-type PDOKResponse struct {
-	Response struct {
-		Docs []struct {
-			CentroidLL string `json:"centroide_ll"`
-		} `json:"docs"`
-	} `json:"response"`
-}
-
-func NewDistrictManager(db *sql.DB, logger *logrus.Logger) *DistrictManager {
+func NewDistrictManager(db *database.Database, outputDir string, logger *logrus.Logger) *DistrictManager {
 	return &DistrictManager{
-		db:     db,
-		logger: logger,
+		db:         db.GetDB(),
+		boundaries: db,
+		pdokCache:  db,
+		pdokTTL:    DefaultPDOKCacheTTL,
+		outputDir:  outputDir,
+		logger:     logger,
 	}
 }
 
 func (dm *DistrictManager) CleanPreviousData() error {
-	outputPath := filepath.Join("..", "client", "public", "district_hulls.geojson")
+	outputPath := filepath.Join(dm.outputDir, DistrictHullsFile)
 	if err := os.Remove(outputPath); err != nil && !os.IsNotExist(err) {
 		return fmt.Errorf("failed to remove previous data: %v", err)
 	}
@@ -59,15 +69,11 @@ func (dm *DistrictManager) CleanPreviousData() error {
 }
 
 func (dm *DistrictManager) GetUniqueDistricts() (map[string]string, error) {
-	// Query to get unique postal districts (first 4 digits) and their cities
+	// Query to get unique postal districts and their cities
 	query := `
-		SELECT DISTINCT 
-			substr(postal_code, 1, 4) as district,
-			city
+		SELECT DISTINCT district, city
 		FROM properties 
-		WHERE postal_code IS NOT NULL
-		  AND length(postal_code) >= 4
-		  AND postal_code GLOB '[0-9][0-9][0-9][0-9]*'  -- Ensure valid postal code format (4 digits)
+		WHERE district IS NOT NULL  -- Only postal codes starting with 4 digits have a district
 	`
 
 	rows, err := dm.db.Query(query)
@@ -89,75 +95,6 @@ func (dm *DistrictManager) GetUniqueDistricts() (map[string]string, error) {
 	return districts, nil
 }
 
-func (dm *DistrictManager) FetchDistrictPoints(district string, city string) ([]DistrictPoint, error) {
-	baseURL := "https://api.pdok.nl/bzk/locatieserver/search/v3_1/free"
-
-	// Build query parameters
-	params := url.Values{}
-	params.Set("q", fmt.Sprintf("type:postcode AND postcode:%s* AND woonplaatsnaam:%s", district, city))
-	params.Set("rows", "100")
-	params.Set("fl", "*")
-	params.Set("fq", "type:postcode")
-
-	// Create request
-	req, err := http.NewRequest("GET", baseURL+"?"+params.Encode(), nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %v", err)
-	}
-
-	// Add headers
-	req.Header.Set("User-Agent", "FundaMental Property Analyzer/1.0")
-	req.Header.Set("Accept-Language", "nl-NL,nl;q=0.9,en-US;q=0.8,en;q=0.7")
-
-	// Make request
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to make request: %v", err)
-	}
-	defer resp.Body.Close()
-
-	// Read response
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %v", err)
-	}
-
-	// Parse response
-	var pdokResp PDOKResponse
-	if err := json.Unmarshal(body, &pdokResp); err != nil {
-		return nil, fmt.Errorf("failed to parse response: %v", err)
-	}
-
-	// Extract points
-	var points []DistrictPoint
-	seen := make(map[string]bool) // To deduplicate points
-
-	for _, doc := range pdokResp.Response.Docs {
-		var lat, lon float64
-		_, err := fmt.Sscanf(doc.CentroidLL, "POINT(%f %f)", &lon, &lat)
-		if err != nil {
-			dm.logger.Warnf("Failed to parse coordinates from %s: %v", doc.CentroidLL, err)
-			continue
-		}
-
-		// Deduplicate points
-		key := fmt.Sprintf("%.6f,%.6f", lat, lon)
-		if !seen[key] {
-			points = append(points, DistrictPoint{
-				Latitude:  lat,
-				Longitude: lon,
-			})
-			seen[key] = true
-		}
-	}
-
-	// Add delay to respect rate limits
-	time.Sleep(100 * time.Millisecond)
-
-	return points, nil
-}
-
 func angle(center, p orb.Point) float64 {
 	dx := p[0] - center[0]
 	dy := p[1] - center[1]
@@ -375,14 +312,24 @@ func (dm *DistrictManager) SaveDistrictHulls(districts map[string]*District) err
 		"metadata": metadata,
 	}
 
-	// Ensure the public directory exists
-	publicDir := filepath.Join("..", "client", "public")
-	if err := os.MkdirAll(publicDir, 0755); err != nil {
-		return fmt.Errorf("failed to create public directory: %v", err)
+	if err := dm.writeHullsFile(output); err != nil {
+		return err
+	}
+
+	dm.logger.Infof("Saved %d district hulls to %s", len(features), filepath.Join(dm.outputDir, DistrictHullsFile))
+	return nil
+}
+
+// writeHullsFile writes the GeoJSON of the district hulls to the output
+// directory
+func (dm *DistrictManager) writeHullsFile(output interface{}) error {
+	// Ensure the output directory exists
+	if err := os.MkdirAll(dm.outputDir, 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %v", err)
 	}
 
 	// Save to file
-	outputPath := filepath.Join(publicDir, "district_hulls.geojson")
+	outputPath := filepath.Join(dm.outputDir, DistrictHullsFile)
 	file, err := os.Create(outputPath)
 	if err != nil {
 		return fmt.Errorf("failed to create output file: %v", err)
@@ -394,8 +341,6 @@ func (dm *DistrictManager) SaveDistrictHulls(districts map[string]*District) err
 	if err := encoder.Encode(output); err != nil {
 		return fmt.Errorf("failed to encode GeoJSON: %v", err)
 	}
-
-	dm.logger.Infof("Saved %d district hulls to %s", len(features), outputPath)
 	return nil
 }
 
@@ -444,6 +389,11 @@ func (dm *DistrictManager) UpdateDistrictHulls() error {
 		features = append(features, feature)
 	}
 
+	options, err := dm.HullOptions()
+	if err != nil {
+		return fmt.Errorf("failed to get hull options: %v", err)
+	}
+
 	// Create complete GeoJSON object
 	geojson := map[string]interface{}{
 		"type":     "FeatureCollection",
@@ -452,6 +402,7 @@ func (dm *DistrictManager) UpdateDistrictHulls() error {
 			"generated": time.Now().Format(time.RFC3339),
 			"source":    "PDOK Locatieserver",
 		},
+		"options": options,
 	}
 
 	// Convert to JSON
@@ -467,6 +418,16 @@ func (dm *DistrictManager) UpdateDistrictHulls() error {
 	cmd := exec.Command("python3", scriptPath)
 	cmd.Dir = filepath.Dir(filepath.Dir(scriptPath)) // Set working directory to server root
 
+	// Tell the script where to write the hulls
+	outputDir, err := filepath.Abs(dm.outputDir)
+	if err != nil {
+		return fmt.Errorf("failed to resolve output directory: %v", err)
+	}
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %v", err)
+	}
+	cmd.Env = append(os.Environ(), "HULLS_OUTPUT_DIR="+outputDir)
+
 	// Set up pipes
 	stdin, err := cmd.StdinPipe()
 	if err != nil {
@@ -510,5 +471,99 @@ func (dm *DistrictManager) UpdateDistrictHulls() error {
 	}
 
 	dm.logger.Infof("Successfully generated %d district hulls", result.HullCount)
+
+	if _, _, err := dm.RecordBoundaries(models.BoundarySourceGenerated, time.Now(), &options); err != nil {
+		return fmt.Errorf("failed to record district boundary version: %v", err)
+	}
 	return nil
 }
+
+// RecordBoundaries keeps the district boundaries of the hulls file as a
+// version in force from validFrom with the hull options they were generated
+// with, unless they are the same as the current version. It returns the
+// current version, or nil when there is no hulls file, and whether it is new.
+func (dm *DistrictManager) RecordBoundaries(source string, validFrom time.Time, options *models.HullOptions) (*models.DistrictBoundaryVersion, bool, error) {
+	data, err := os.ReadFile(filepath.Join(dm.outputDir, DistrictHullsFile))
+	if os.IsNotExist(err) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read district hulls: %v", err)
+	}
+	fc, err := geojson.UnmarshalFeatureCollection(data)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to parse district hulls: %v", err)
+	}
+	features, err := json.Marshal(fc.Features)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to encode district hulls: %v", err)
+	}
+
+	var parameters json.RawMessage
+	if options != nil {
+		if parameters, err = json.Marshal(options); err != nil {
+			return nil, false, fmt.Errorf("failed to encode hull options: %v", err)
+		}
+	}
+	version, created, err := dm.boundaries.SaveDistrictBoundaries(source, validFrom, len(fc.Features), features, parameters)
+	if err != nil {
+		return nil, false, err
+	}
+	if created {
+		dm.logger.Infof("Recorded version %d of the district boundaries with %d districts", version.ID, version.Districts)
+	}
+	return version, created, nil
+}
+
+// AdoptBoundaries records the hulls file as the first version of the district
+// boundaries, in force since the file was written, when no version has been
+// recorded yet
+func (dm *DistrictManager) AdoptBoundaries() error {
+	current, _, err := dm.boundaries.DistrictBoundariesAt(time.Time{})
+	if err != nil || current != nil {
+		return err
+	}
+	info, err := os.Stat(filepath.Join(dm.outputDir, DistrictHullsFile))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read district hulls: %v", err)
+	}
+	_, _, err = dm.RecordBoundaries(models.BoundarySourceGenerated, info.ModTime(), nil)
+	return err
+}
+
+// ImportBoundaries replaces the district boundaries by an imported set, such
+// as the official postal code areas, and keeps it as a version in force from
+// now. Every feature needs a polygon geometry and a "district" property. It
+// returns the current version and whether the import made a new one.
+func (dm *DistrictManager) ImportBoundaries(fc *geojson.FeatureCollection) (*models.DistrictBoundaryVersion, bool, error) {
+	if len(fc.Features) == 0 {
+		return nil, false, fmt.Errorf("%w: the import has no features", ErrInvalidBoundaries)
+	}
+	for i, feature := range fc.Features {
+		if feature.Properties.MustString("district", "") == "" {
+			return nil, false, fmt.Errorf("%w: feature %d has no district property", ErrInvalidBoundaries, i)
+		}
+		switch feature.Geometry.(type) {
+		case orb.Polygon, orb.MultiPolygon:
+		default:
+			return nil, false, fmt.Errorf("%w: feature %d is not a polygon", ErrInvalidBoundaries, i)
+		}
+	}
+
+	output := geojson.NewFeatureCollection()
+	output.Features = fc.Features
+	output.ExtraMembers = geojson.Properties{
+		"metadata": map[string]interface{}{
+			"generated":   time.Now().Format(time.RFC3339),
+			"description": "Imported district boundaries",
+			"districts":   len(fc.Features),
+		},
+	}
+	if err := dm.writeHullsFile(output); err != nil {
+		return nil, false, err
+	}
+	return dm.RecordBoundaries(models.BoundarySourceImported, time.Now(), nil)
+}
@@ -0,0 +1,54 @@
+package geometry
+
+import (
+	"errors"
+	"fmt"
+	"fundamental/server/internal/models"
+)
+
+// HullOptionsSetting is the name of the setting holding the hull options
+const HullOptionsSetting = "hulls"
+
+// Bounds of the hull options
+const (
+	maxHullBufferDistance = 0.01 // degrees, about a kilometre
+	maxHullSmoothing      = 5
+)
+
+// ErrInvalidHullOptions is returned for hull options out of their bounds
+var ErrInvalidHullOptions = errors.New("invalid hull options")
+
+// DefaultHullOptions are the hull options used until others are saved: the
+// buffered convex hulls the boundaries have always been drawn with
+func DefaultHullOptions() models.HullOptions {
+	return models.HullOptions{
+		Algorithm:      models.HullConvex,
+		Concavity:      0.5,
+		BufferDistance: 0.001,
+		Smoothing:      0,
+	}
+}
+
+// ValidateHullOptions checks hull options against their bounds
+func ValidateHullOptions(options models.HullOptions) error {
+	switch {
+	case options.Algorithm != models.HullConvex && options.Algorithm != models.HullConcave:
+		return fmt.Errorf("%w: algorithm must be %s or %s", ErrInvalidHullOptions, models.HullConvex, models.HullConcave)
+	case options.Concavity <= 0 || options.Concavity > 1:
+		return fmt.Errorf("%w: concavity must be above 0 and at most 1", ErrInvalidHullOptions)
+	case options.BufferDistance < 0 || options.BufferDistance > maxHullBufferDistance:
+		return fmt.Errorf("%w: buffer_distance must be between 0 and %g degrees", ErrInvalidHullOptions, maxHullBufferDistance)
+	case options.Smoothing < 0 || options.Smoothing > maxHullSmoothing:
+		return fmt.Errorf("%w: smoothing must be between 0 and %d passes", ErrInvalidHullOptions, maxHullSmoothing)
+	}
+	return nil
+}
+
+// HullOptions returns the saved hull options over the defaults
+func (dm *DistrictManager) HullOptions() (models.HullOptions, error) {
+	options := DefaultHullOptions()
+	if _, err := dm.boundaries.GetSetting(HullOptionsSetting, &options); err != nil {
+		return DefaultHullOptions(), err
+	}
+	return options, nil
+}
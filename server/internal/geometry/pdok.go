@@ -0,0 +1,196 @@
+package geometry
+
+import (
+	"encoding/json"
+	"fmt"
+	"fundamental/server/internal/models"
+	"io"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultPDOKCacheTTL is how long the PDOK points of a district are used
+// before they are fetched again. Postal codes rarely change, so the cache
+// saves nearly every request of the nightly district hulls update.
+const DefaultPDOKCacheTTL = 90 * 24 * time.Hour
+
+// pdokRequestDelay is the pause after each request to PDOK
+const pdokRequestDelay = 100 * time.Millisecond
+
+// pdokLookups counts the outcomes of the PDOK lookups of every district
+// manager, those of the API and of the scheduler alike
+var pdokLookups struct {
+	hits, staleHits, misses, errors atomic.Int64
+}
+
+// EnablePDOKCache sets how long cached PDOK points are used, 0 to fetch
+// every district from PDOK, and whether the manager works offline, using
+// cached points of any age and skipping the districts without them
+func (dm *DistrictManager) EnablePDOKCache(ttl time.Duration, offline bool) {
+	dm.pdokTTL = ttl
+	dm.pdokOffline = offline
+}
+
+// FetchDistrictPoints returns the postal code centroids of a district: the
+// cached points while they are fresh, else those PDOK returns, which are
+// cached in turn. Expired points are still used when PDOK fails.
+func (dm *DistrictManager) FetchDistrictPoints(district string, city string) ([]DistrictPoint, error) {
+	var cached *models.PDOKCacheEntry
+	if dm.pdokTTL > 0 || dm.pdokOffline {
+		var err error
+		if cached, err = dm.pdokCache.GetPDOKPoints(district, city); err != nil {
+			dm.logger.Warnf("Failed to read cached PDOK points for district %s: %v", district, err)
+		}
+	}
+
+	if cached != nil && time.Since(cached.FetchedAt) < dm.pdokTTL {
+		pdokLookups.hits.Add(1)
+		return cachedDistrictPoints(cached), nil
+	}
+	if dm.pdokOffline {
+		if cached == nil {
+			pdokLookups.errors.Add(1)
+			return nil, fmt.Errorf("no cached PDOK points for district %s in offline mode", district)
+		}
+		pdokLookups.staleHits.Add(1)
+		return cachedDistrictPoints(cached), nil
+	}
+
+	points, err := dm.fetchPDOKPoints(district, city)
+	if err != nil {
+		if cached != nil {
+			dm.logger.Warnf("Using PDOK points of district %s cached on %s: %v", district, cached.FetchedAt.Format("2006-01-02"), err)
+			pdokLookups.staleHits.Add(1)
+			return cachedDistrictPoints(cached), nil
+		}
+		pdokLookups.errors.Add(1)
+		return nil, err
+	}
+	pdokLookups.misses.Add(1)
+
+	if dm.pdokTTL > 0 {
+		entry := models.PDOKCacheEntry{District: district, City: city, Points: make([][2]float64, len(points)), FetchedAt: time.Now()}
+		for i, p := range points {
+			entry.Points[i] = [2]float64{p.Longitude, p.Latitude}
+		}
+		if err := dm.pdokCache.SavePDOKPoints(entry); err != nil {
+			dm.logger.Warnf("Failed to cache PDOK points for district %s: %v", district, err)
+		}
+	}
+	return points, nil
+}
+
+// cachedDistrictPoints converts the coordinates of a cache entry to points
+func cachedDistrictPoints(entry *models.PDOKCacheEntry) []DistrictPoint {
+	points := make([]DistrictPoint, len(entry.Points))
+	for i, p := range entry.Points {
+		points[i] = DistrictPoint{Latitude: p[1], Longitude: p[0]}
+	}
+	return points
+}
+
+// PDOKCacheStats describes the PDOK cache and how the lookups since the
+// server started were answered
+func (dm *DistrictManager) PDOKCacheStats() (*models.PDOKCacheStats, error) {
+	stats, err := dm.pdokCache.GetPDOKCacheStats()
+	if err != nil {
+		return nil, err
+	}
+	stats.TTLDays = int(dm.pdokTTL / (24 * time.Hour))
+	stats.Offline = dm.pdokOffline
+	stats.Hits = pdokLookups.hits.Load()
+	stats.StaleHits = pdokLookups.staleHits.Load()
+	stats.Misses = pdokLookups.misses.Load()
+	stats.Errors = pdokLookups.errors.Load()
+	if total := stats.Hits + stats.StaleHits + stats.Misses + stats.Errors; total > 0 {
+		stats.HitRate = float64(stats.Hits+stats.StaleHits) / float64(total)
+	}
+	return stats, nil
+}
+
+// PDOKResponse is the part of a Locatieserver search response that is read
+type PDOKResponse struct {
+	Response struct {
+		Docs []struct {
+			CentroidLL string `json:"centroide_ll"`
+		} `json:"docs"`
+	} `json:"response"`
+}
+
+// fetchPDOKPoints requests the postal code centroids of a district from the
+// PDOK Locatieserver
+func (dm *DistrictManager) fetchPDOKPoints(district string, city string) ([]DistrictPoint, error) {
+	baseURL := "https://api.pdok.nl/bzk/locatieserver/search/v3_1/free"
+
+	// Build query parameters
+	params := url.Values{}
+	params.Set("q", fmt.Sprintf("type:postcode AND postcode:%s* AND woonplaatsnaam:%s", district, city))
+	params.Set("rows", "100")
+	params.Set("fl", "*")
+	params.Set("fq", "type:postcode")
+
+	// Create request
+	req, err := http.NewRequest("GET", baseURL+"?"+params.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+
+	// Add headers
+	req.Header.Set("User-Agent", "FundaMental Property Analyzer/1.0")
+	req.Header.Set("Accept-Language", "nl-NL,nl;q=0.9,en-US;q=0.8,en;q=0.7")
+
+	// Make request
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	// An error page must not be cached as a district without points
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("PDOK returned status %d", resp.StatusCode)
+	}
+
+	// Read response
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %v", err)
+	}
+
+	// Parse response
+	var pdokResp PDOKResponse
+	if err := json.Unmarshal(body, &pdokResp); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %v", err)
+	}
+
+	// Extract points
+	var points []DistrictPoint
+	seen := make(map[string]bool) // To deduplicate points
+
+	for _, doc := range pdokResp.Response.Docs {
+		var lat, lon float64
+		_, err := fmt.Sscanf(doc.CentroidLL, "POINT(%f %f)", &lon, &lat)
+		if err != nil {
+			dm.logger.Warnf("Failed to parse coordinates from %s: %v", doc.CentroidLL, err)
+			continue
+		}
+
+		// Deduplicate points
+		key := fmt.Sprintf("%.6f,%.6f", lat, lon)
+		if !seen[key] {
+			points = append(points, DistrictPoint{
+				Latitude:  lat,
+				Longitude: lon,
+			})
+			seen[key] = true
+		}
+	}
+
+	// Add delay to respect rate limits
+	time.Sleep(pdokRequestDelay)
+
+	return points, nil
+}
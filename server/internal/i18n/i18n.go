@@ -0,0 +1,64 @@
+// Package i18n translates the labels of the documents the server generates,
+// such as Telegram notifications and localized exports. Messages are written
+// in English in the code and looked up in the catalog of the language of a
+// locale; messages without a translation, and languages without a catalog,
+// stay English.
+package i18n
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Languages with a catalog
+const (
+	English = "en"
+	Dutch   = "nl"
+)
+
+// catalogs map English messages to their translations, by language
+var catalogs = map[string]map[string]string{
+	Dutch: dutch,
+}
+
+// Translator translates messages into the language of a locale
+type Translator struct {
+	language string
+	messages map[string]string
+}
+
+// Language returns the language of a locale tag such as "nl-NL" or "nl_be"
+// when it has a catalog, and English otherwise
+func Language(locale string) string {
+	language, _, _ := strings.Cut(strings.ReplaceAll(strings.TrimSpace(locale), "_", "-"), "-")
+	language = strings.ToLower(language)
+	if _, ok := catalogs[language]; ok {
+		return language
+	}
+	return English
+}
+
+// New returns the translator of a locale; an empty or unsupported locale
+// translates into English
+func New(locale string) *Translator {
+	language := Language(locale)
+	return &Translator{language: language, messages: catalogs[language]}
+}
+
+// Language returns the language messages are translated into
+func (t *Translator) Language() string {
+	return t.language
+}
+
+// T returns the translation of a message
+func (t *Translator) T(message string) string {
+	if translated, ok := t.messages[message]; ok {
+		return translated
+	}
+	return message
+}
+
+// Sprintf formats the translation of a format string
+func (t *Translator) Sprintf(format string, args ...interface{}) string {
+	return fmt.Sprintf(t.T(format), args...)
+}
@@ -0,0 +1,112 @@
+package i18n
+
+// dutch translates the messages into Dutch
+var dutch = map[string]string{
+	// Telegram: district analysis
+	"District comparison unavailable":                                     "Vergelijking met de wijk niet beschikbaar",
+	"📊 <u>District Analysis</u>":                                          "📊 <u>Wijkanalyse</u>",
+	"Current listings (%d properties):\n%s (%s vs. median)":               "Huidig aanbod (%d woningen):\n%s (%s t.o.v. de mediaan)",
+	"Current listings (0 properties):\nNo active listings for comparison": "Huidig aanbod (0 woningen):\nGeen aanbod om mee te vergelijken",
+	"Past year sales (%d properties):\n%s (%s vs. median)":                "Verkopen afgelopen jaar (%d woningen):\n%s (%s t.o.v. de mediaan)",
+	"Past year sales (0 properties):\nNo recent sales for comparison":     "Verkopen afgelopen jaar (0 woningen):\nGeen recente verkopen om mee te vergelijken",
+	"GREAT":    "UITSTEKEND",
+	"GOOD":     "GOED",
+	"NORMAL":   "NORMAAL",
+	"BAD":      "SLECHT",
+	"HORRIBLE": "BELABBERD",
+
+	// Telegram: bid advice
+	"🎯 <u>Suggested Bid</u>":         "🎯 <u>Biedadvies</u>",
+	"%s – %s (%s confidence)":        "%s – %s (betrouwbaarheid %s)",
+	"high":                           "hoog",
+	"medium":                         "gemiddeld",
+	"low":                            "laag",
+	"Estimated value: %s (%d sales)": "Geschatte waarde: %s (%d verkopen)",
+	"Estimated value":                "Geschatte waarde",
+	"District asking price changes":  "Ontwikkeling vraagprijzen in de wijk",
+	"Days on market":                 "Dagen te koop",
+	"Republished or relisted":        "Opnieuw aangeboden",
+
+	// Telegram: market phase
+	"🌡️ <u>Market</u>":                      "🌡️ <u>Markt</u>",
+	"Seller's market":                       "Verkopersmarkt",
+	"Balanced market":                       "Evenwichtige markt",
+	"Buyer's market":                        "Kopersmarkt",
+	"Listings on the market: %s in %d days": "Aanbod: %s in %d dagen",
+	"Median days to sell: %s":               "Mediane verkooptijd in dagen: %s",
+	"Sold over asking: %s":                  "Verkocht boven de vraagprijs: %s",
+	"Sold price per m²: %s in %d days":      "Verkoopprijs per m²: %s in %d dagen",
+
+	// Telegram: new listings
+	"N/A":                              "n.v.t.",
+	"N/A (price analysis unavailable)": "n.v.t. (prijsanalyse niet beschikbaar)",
+	"New Property Listed!":             "Nieuwe woning te koop!",
+	"Property Republished! (%d times)": "Woning opnieuw aangeboden! (%d keer)",
+	"Property Republished!":            "Woning opnieuw aangeboden!",
+	"%s (%s %s from %s)":               "%s (%s %s t.o.v. %s)",
+	"Subscribed district %s":           "Gevolgde wijk %s",
+	"Built":                            "Bouwjaar",
+	"Rooms":                            "Kamers",
+	"Energy label":                     "Energielabel",
+	"View on Funda":                    "Bekijk op Funda",
+	"Street view":                      "Straatbeeld",
+	"Map data":                         "Kaartgegevens",
+
+	// Telegram: watchlists
+	"Sold near a watched home":    "Verkocht bij een gevolgde woning",
+	"Sold for %s":                 "Verkocht voor %s",
+	"%s m from the watched home":  "%s m van de gevolgde woning",
+	"Asking %s":                   "Vraagprijs %s",
+	"Implied value %s":            "Afgeleide waarde %s",
+	"%s against the asking price": "%s t.o.v. de vraagprijs",
+
+	// Telegram: maintenance
+	"Database integrity check failed":                    "Integriteitscontrole van de database mislukt",
+	"The check found %d problem(s):":                     "De controle vond %d probleem/problemen:",
+	"… and %d more":                                      "… en nog %d",
+	"Restore a recent backup before the damage spreads.": "Zet een recente back-up terug voordat de schade zich verspreidt.",
+
+	// Telegram: scrape coverage
+	"Low scrape coverage": "Lage dekking van het scrapen",
+	"%s spider for %s: %d of %d listings found (%.0f%%).": "%s-spider voor %s: %d van %d woningen gevonden (%.0f%%).",
+	"Alerts are sent below %.0f%% coverage.":              "Meldingen worden verstuurd onder %.0f%% dekking.",
+	"Check the spider for changes to Funda's pages.":      "Controleer de spider op wijzigingen in de pagina's van Funda.",
+
+	// Column headers of localized exports
+	"street":                   "straat",
+	"neighborhood":             "buurt",
+	"property_type":            "woningtype",
+	"city":                     "plaats",
+	"postal_code":              "postcode",
+	"district":                 "wijk",
+	"country":                  "land",
+	"currency":                 "valuta",
+	"price":                    "prijs",
+	"year_built":               "bouwjaar",
+	"living_area":              "woonoppervlakte",
+	"num_rooms":                "kamers",
+	"listing_date":             "aanboddatum",
+	"selling_date":             "verkoopdatum",
+	"scraped_at":               "opgehaald_op",
+	"created_at":               "aangemaakt_op",
+	"latitude":                 "breedtegraad",
+	"longitude":                "lengtegraad",
+	"energy_label":             "energielabel",
+	"canonical_id":             "canoniek_id",
+	"agent_id":                 "makelaar_id",
+	"delisting_reason":         "reden_offline",
+	"delisted_at":              "offline_op",
+	"tags":                     "labels",
+	"price_per_sqm":            "prijs_per_m2",
+	"outlier_flags":            "afwijkingen",
+	"days_on_market":           "dagen_te_koop",
+	"days_to_sell":             "verkooptijd_dagen",
+	"first_asking_price":       "eerste_vraagprijs",
+	"active_listings":          "aanbod",
+	"avg_asking_price":         "gem_vraagprijs",
+	"avg_asking_price_per_sqm": "gem_vraagprijs_per_m2",
+	"sold_12m":                 "verkocht_12m",
+	"avg_sold_price":           "gem_verkoopprijs",
+	"avg_sold_price_per_sqm":   "gem_verkoopprijs_per_m2",
+	"avg_days_to_sell":         "gem_verkooptijd_dagen",
+}
@@ -0,0 +1,557 @@
+package importer
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"fundamental/server/internal/country"
+	"fundamental/server/internal/database"
+	"fundamental/server/internal/models"
+	"io"
+	"math"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ColumnMapping maps property fields (e.g. "price") to CSV column headers
+type ColumnMapping map[string]string
+
+// Options configures how a CSV file is interpreted
+type Options struct {
+	Mapping    ColumnMapping `json:"mapping"`
+	Delimiter  string        `json:"delimiter"`   // defaults to ","
+	DateFormat string        `json:"date_format"` // Go layout for date columns, optional
+}
+
+// propertyFields lists the fields that can be imported, in schema order
+var propertyFields = []string{
+	"url", "street", "neighborhood", "property_type", "city", "postal_code",
+	"price", "year_built", "living_area", "num_rooms", "status",
+	"listing_date", "selling_date", "selling_date_precision", "energy_label", "scraped_at",
+	"agent_name", "agent_url", "country", "currency",
+}
+
+var integerFields = map[string]bool{
+	"price":       true,
+	"year_built":  true,
+	"living_area": true,
+	"num_rooms":   true,
+}
+
+var dateFields = map[string]bool{
+	"listing_date": true,
+	"selling_date": true,
+}
+
+// Date layouts accepted for date columns when no explicit format is configured
+var dateLayouts = []string{
+	"2006-01-02",
+	"2006-01-02T15:04:05",
+	time.RFC3339,
+	"02-01-2006",
+	"02/01/2006",
+	"2006/01/02",
+}
+
+// Layouts of selling dates known only to the month, tried when no explicit
+// format is configured
+var monthLayouts = []string{
+	"2006-01",
+	"01-2006",
+	"01/2006",
+}
+
+var nonNumberRegex = regexp.MustCompile(`[^\d.,-]`)
+
+var validStatuses = map[string]bool{
+	"active":      true,
+	"sold":        true,
+	"inactive":    true,
+	"republished": true,
+}
+
+// batchSize is the number of valid rows written per InsertProperties call
+const batchSize = 500
+
+// Importer loads historical property data into the database
+type Importer struct {
+	db     *database.Database
+	logger *logrus.Logger
+}
+
+// NewImporter creates a new importer
+func NewImporter(db *database.Database, logger *logrus.Logger) *Importer {
+	if logger == nil {
+		logger = logrus.New()
+		logger.SetFormatter(&logrus.JSONFormatter{})
+		logger.SetOutput(os.Stdout)
+	}
+	return &Importer{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// DefaultMapping maps every property field to a CSV column of the same name
+func DefaultMapping() ColumnMapping {
+	mapping := make(ColumnMapping, len(propertyFields))
+	for _, field := range propertyFields {
+		mapping[field] = field
+	}
+	return mapping
+}
+
+// ImportCSV reads a CSV file, maps its columns onto the property schema and
+// stores every valid row. Rows are validated and deduplicated by URL, both
+// within the file and against existing properties: a row of a stored URL is
+// reported as a duplicate and leaves the stored property as it is.
+func (i *Importer) ImportCSV(r io.Reader, opts Options) (*models.ImportReport, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+	if opts.Delimiter != "" {
+		reader.Comma = []rune(opts.Delimiter)[0]
+	}
+
+	mapping, err := resolveMapping(opts.Mapping)
+	if err != nil {
+		return nil, err
+	}
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV header: %v", err)
+	}
+
+	// Resolve the column index of every mapped field
+	columnIndex := make(map[string]int)
+	for idx, name := range header {
+		columnIndex[strings.TrimSpace(strings.ToLower(name))] = idx
+	}
+	// With the default mapping only the url column is required
+	fieldIndex := make(map[string]int)
+	for field, column := range mapping {
+		idx, ok := columnIndex[strings.TrimSpace(strings.ToLower(column))]
+		if !ok {
+			if len(opts.Mapping) == 0 && field != "url" {
+				continue
+			}
+			return nil, fmt.Errorf("column %q mapped to %s not found in CSV header", column, field)
+		}
+		fieldIndex[field] = idx
+	}
+
+	run := i.newRun()
+	rowNum := 1 // header is row 1
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		rowNum++
+		if err != nil {
+			run.reject(rowNum, "", err)
+			continue
+		}
+		item, err := buildItem(record, fieldIndex, opts.DateFormat)
+		run.add(rowNum, item, err)
+	}
+	return run.finish("CSV"), nil
+}
+
+// ImportJSON reads a JSON array of property objects and stores every valid
+// element like ImportCSV does. Objects use the property field names as keys
+// unless a mapping is given; rows in the report are numbered from 1 in array
+// order. Reading stops at the first malformed element.
+func (i *Importer) ImportJSON(r io.Reader, opts Options) (*models.ImportReport, error) {
+	mapping, err := resolveMapping(opts.Mapping)
+	if err != nil {
+		return nil, err
+	}
+
+	// Mapped fields become the columns of a CSV-like record
+	fieldIndex := make(map[string]int, len(mapping))
+	keys := make([]string, 0, len(mapping))
+	for field, key := range mapping {
+		fieldIndex[field] = len(keys)
+		keys = append(keys, key)
+	}
+
+	decoder := json.NewDecoder(r)
+	if token, err := decoder.Token(); err != nil || token != json.Delim('[') {
+		return nil, fmt.Errorf("JSON import must be an array of properties")
+	}
+
+	run := i.newRun()
+	rowNum := 0
+	for decoder.More() {
+		rowNum++
+		var object map[string]interface{}
+		if err := decoder.Decode(&object); err != nil {
+			if _, ok := err.(*json.UnmarshalTypeError); ok {
+				run.reject(rowNum, "", fmt.Errorf("element is not an object"))
+				continue
+			}
+			run.reject(rowNum, "", fmt.Errorf("malformed JSON, import stopped: %v", err))
+			break
+		}
+
+		record := make([]string, len(keys))
+		var err error
+		for idx, key := range keys {
+			if record[idx], err = jsonValue(object[key]); err != nil {
+				err = fmt.Errorf("invalid %s: %v", key, err)
+				break
+			}
+		}
+		if err != nil {
+			url, _ := object[mapping["url"]].(string)
+			run.reject(rowNum, url, err)
+			continue
+		}
+		item, err := buildItem(record, fieldIndex, opts.DateFormat)
+		run.add(rowNum, item, err)
+	}
+	return run.finish("JSON"), nil
+}
+
+// resolveMapping validates a column mapping, defaulting to DefaultMapping
+func resolveMapping(mapping ColumnMapping) (ColumnMapping, error) {
+	if len(mapping) == 0 {
+		mapping = DefaultMapping()
+	}
+	for field := range mapping {
+		if !isPropertyField(field) {
+			return nil, fmt.Errorf("unknown property field in mapping: %s", field)
+		}
+	}
+	if _, ok := mapping["url"]; !ok {
+		return nil, fmt.Errorf("mapping must include the url field")
+	}
+	return mapping, nil
+}
+
+// jsonValue formats a decoded JSON value as the text a CSV field would hold;
+// null and missing values are empty
+func jsonValue(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case float64:
+		// Every numeric field is an integer, and parseInteger rejects a
+		// fraction
+		return strconv.FormatFloat(math.Round(v), 'f', 0, 64), nil
+	default:
+		return "", fmt.Errorf("expected a string or number")
+	}
+}
+
+// importRun collects the rows of one import into batches and reports on them
+type importRun struct {
+	importer  *Importer
+	report    *models.ImportReport
+	runID     string
+	seen      map[string]int // url -> first row number
+	batch     []map[string]interface{}
+	batchRows []int
+}
+
+func (i *Importer) newRun() *importRun {
+	return &importRun{
+		importer: i,
+		report:   &models.ImportReport{},
+		runID:    fmt.Sprintf("import-%s", time.Now().UTC().Format("20060102T150405")),
+		seen:     make(map[string]int),
+	}
+}
+
+// reject reports a row that could not be read or validated
+func (r *importRun) reject(rowNum int, url string, err error) {
+	r.report.TotalRows++
+	r.report.Add(models.ImportRowResult{Row: rowNum, URL: url, Status: "invalid", Error: err.Error()})
+}
+
+// add queues a built item for storage, reporting it when buildItem failed or
+// its URL was already seen in this import
+func (r *importRun) add(rowNum int, item map[string]interface{}, err error) {
+	if err != nil {
+		url, _ := item["url"].(string)
+		r.reject(rowNum, url, err)
+		return
+	}
+	r.report.TotalRows++
+
+	url := item["url"].(string)
+	if firstRow, ok := r.seen[url]; ok {
+		r.report.Add(models.ImportRowResult{
+			Row:    rowNum,
+			URL:    url,
+			Status: "duplicate",
+			Error:  fmt.Sprintf("duplicate of row %d", firstRow),
+		})
+		return
+	}
+	r.seen[url] = rowNum
+
+	item["_source"] = models.SourceImport
+	item["_run_id"] = r.runID
+	r.batch = append(r.batch, item)
+	r.batchRows = append(r.batchRows, rowNum)
+	if len(r.batch) >= batchSize {
+		r.importer.storeBatch(r.batch, r.batchRows, r.report)
+		r.batch, r.batchRows = nil, nil
+	}
+}
+
+// finish stores the last batch and returns the report in row order
+func (r *importRun) finish(format string) *models.ImportReport {
+	if len(r.batch) > 0 {
+		r.importer.storeBatch(r.batch, r.batchRows, r.report)
+		r.batch, r.batchRows = nil, nil
+	}
+
+	// Batched rows are reported after later invalid rows, so restore file order
+	sort.Slice(r.report.Rows, func(a, b int) bool {
+		return r.report.Rows[a].Row < r.report.Rows[b].Row
+	})
+
+	r.importer.logger.WithFields(logrus.Fields{
+		"total_rows": r.report.TotalRows,
+		"inserted":   r.report.Inserted,
+		"updated":    r.report.Updated,
+		"duplicates": r.report.Duplicates,
+		"invalid":    r.report.Invalid,
+	}).Infof("%s import completed", format)
+
+	return r.report
+}
+
+// storeBatch inserts the items of a batch whose URL is not stored yet,
+// falling back to row-by-row inserts when the batch fails so errors can be
+// attributed to individual rows. Imported rows are history, older than what
+// the spiders stored, so they never update a stored property.
+func (i *Importer) storeBatch(batch []map[string]interface{}, rows []int, report *models.ImportReport) {
+	urls := make([]string, len(batch))
+	for idx, item := range batch {
+		urls[idx] = item["url"].(string)
+	}
+	stored, err := i.db.StoredURLs(urls)
+	if err != nil {
+		i.logger.WithError(err).Error("Failed to look up stored properties")
+		for idx := range batch {
+			report.Add(models.ImportRowResult{Row: rows[idx], URL: urls[idx], Status: "invalid", Error: err.Error()})
+		}
+		return
+	}
+	var newItems []map[string]interface{}
+	var newRows []int
+	for idx, item := range batch {
+		if stored[urls[idx]] {
+			report.Add(models.ImportRowResult{Row: rows[idx], URL: urls[idx], Status: "duplicate", Error: "already stored"})
+			continue
+		}
+		newItems = append(newItems, item)
+		newRows = append(newRows, rows[idx])
+	}
+	if len(newItems) == 0 {
+		return
+	}
+	batch, rows = newItems, newRows
+
+	inserted, err := i.db.InsertProperties(batch)
+	if err == nil {
+		i.recordResults(batch, rows, inserted, report)
+		return
+	}
+
+	i.logger.WithError(err).Warn("Batch import failed, retrying rows individually")
+	for idx, item := range batch {
+		single := []map[string]interface{}{item}
+		inserted, err := i.db.InsertProperties(single)
+		if err != nil {
+			report.Add(models.ImportRowResult{
+				Row:    rows[idx],
+				URL:    item["url"].(string),
+				Status: "invalid",
+				Error:  err.Error(),
+			})
+			continue
+		}
+		i.recordResults(single, rows[idx:idx+1], inserted, report)
+	}
+}
+
+func (i *Importer) recordResults(batch []map[string]interface{}, rows []int, inserted []map[string]interface{}, report *models.ImportReport) {
+	newURLs := make(map[string]bool, len(inserted))
+	for _, prop := range inserted {
+		if url, ok := prop["url"].(string); ok {
+			newURLs[url] = true
+		}
+	}
+	for idx, item := range batch {
+		url := item["url"].(string)
+		// Rows that fail validation are kept in rejected_items for review
+		if reasons, ok := item["_rejected"].(string); ok {
+			report.Add(models.ImportRowResult{Row: rows[idx], URL: url, Status: "invalid", Error: reasons})
+			continue
+		}
+		status := "updated"
+		if newURLs[url] {
+			status = "inserted"
+		}
+		report.Add(models.ImportRowResult{Row: rows[idx], URL: url, Status: status})
+	}
+}
+
+// buildItem converts a CSV record into a property item shaped like the
+// spider output, validating and normalizing every mapped field
+func buildItem(record []string, fieldIndex map[string]int, dateFormat string) (map[string]interface{}, error) {
+	item := make(map[string]interface{})
+
+	for field, idx := range fieldIndex {
+		if idx >= len(record) {
+			continue
+		}
+		raw := strings.TrimSpace(record[idx])
+		if raw == "" {
+			continue
+		}
+
+		switch {
+		case integerFields[field]:
+			value, err := parseInteger(raw)
+			if err != nil {
+				return item, fmt.Errorf("invalid %s %q", field, raw)
+			}
+			// Spider items are decoded from JSON, so numbers are float64
+			item[field] = float64(value)
+		case dateFields[field]:
+			value, err := parseDate(raw, dateFormat)
+			if err != nil && field == "selling_date" && dateFormat == "" {
+				value, err = parseMonth(raw)
+			}
+			if err != nil {
+				return item, fmt.Errorf("invalid %s %q", field, raw)
+			}
+			item[field] = value
+		default:
+			item[field] = raw
+		}
+	}
+
+	url, _ := item["url"].(string)
+	if url == "" {
+		return item, fmt.Errorf("missing url")
+	}
+
+	c := country.Get(country.Default)
+	if code, ok := item["country"].(string); ok {
+		if c, ok = country.Lookup(code); !ok {
+			return item, fmt.Errorf("unknown country %q", code)
+		}
+		item["country"] = c.Code
+	}
+	if postalCode, ok := item["postal_code"].(string); ok {
+		if !c.ValidPostalCode(postalCode) {
+			return item, fmt.Errorf("invalid postal_code %q", postalCode)
+		}
+		item["postal_code"] = c.CanonicalPostalCode(postalCode)
+	}
+
+	if price, ok := item["price"].(float64); ok && price <= 0 {
+		return item, fmt.Errorf("price must be positive")
+	}
+	if livingArea, ok := item["living_area"].(float64); ok && livingArea < 0 {
+		return item, fmt.Errorf("living_area cannot be negative")
+	}
+
+	// Derive the status when the source has none
+	status, _ := item["status"].(string)
+	status = strings.ToLower(status)
+	if status == "" {
+		if _, sold := item["selling_date"]; sold {
+			status = "sold"
+		} else {
+			status = "inactive"
+		}
+	}
+	if !validStatuses[status] {
+		return item, fmt.Errorf("invalid status %q", status)
+	}
+	item["status"] = status
+
+	if _, ok := item["scraped_at"]; !ok {
+		item["scraped_at"] = time.Now().Format(time.RFC3339)
+	}
+
+	return item, nil
+}
+
+// parseInteger parses whole numbers such as "450000", "€ 450.000",
+// "1,250,000" or "85 m²". A dot or comma followed by three digits separates
+// thousands, unless the other separator follows it; any other separates
+// decimals. Every imported number is whole, so a fraction such as "1,5" is
+// rejected, while a zero one as in "€ 450.000,00" or "€ 450.000,-" is dropped.
+func parseInteger(raw string) (int, error) {
+	cleaned := nonNumberRegex.ReplaceAllString(raw, "")
+	if idx := strings.LastIndexAny(cleaned, ".,"); idx >= 0 {
+		whole, fraction := cleaned[:idx], cleaned[idx+1:]
+		other := "."
+		if cleaned[idx] == '.' {
+			other = ","
+		}
+		if len(fraction) != 3 || strings.Contains(whole, other) {
+			if strings.Trim(fraction, "0-") != "" {
+				return 0, fmt.Errorf("%q is not a whole number", raw)
+			}
+			cleaned = whole
+		}
+		cleaned = strings.NewReplacer(".", "", ",", "").Replace(cleaned)
+	}
+	if strings.Trim(cleaned, "-") == "" {
+		return 0, fmt.Errorf("no digits in %q", raw)
+	}
+	return strconv.Atoi(cleaned)
+}
+
+// parseDate normalizes a date to the YYYY-MM-DD format used in the database
+func parseDate(raw, layout string) (string, error) {
+	layouts := dateLayouts
+	if layout != "" {
+		layouts = []string{layout}
+	}
+	for _, l := range layouts {
+		if t, err := time.Parse(l, raw); err == nil {
+			return t.Format("2006-01-02"), nil
+		}
+	}
+	return "", fmt.Errorf("unrecognized date %q", raw)
+}
+
+// parseMonth parses a month-only selling date into YYYY-MM, which the
+// database stores as the first of the month with month precision
+func parseMonth(raw string) (string, error) {
+	for _, l := range monthLayouts {
+		if t, err := time.Parse(l, raw); err == nil {
+			return t.Format("2006-01"), nil
+		}
+	}
+	return "", fmt.Errorf("unrecognized date %q", raw)
+}
+
+func isPropertyField(field string) bool {
+	for _, f := range propertyFields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,101 @@
+package importer
+
+import (
+	"fundamental/server/internal/database"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestParseInteger(t *testing.T) {
+	tests := []struct {
+		raw   string
+		want  int
+		valid bool
+	}{
+		{"450000", 450000, true},
+		{"€ 450.000", 450000, true},
+		{"€ 450.000,00", 450000, true},
+		{"€ 450.000,-", 450000, true},
+		{"$1,250,000", 1250000, true},
+		{"1,250,000.00", 1250000, true},
+		{"1.250.000", 1250000, true},
+		{"85 m²", 85, true},
+		{"85,0 m²", 85, true},
+		{"-3", -3, true},
+		{"1,5", 0, false},
+		{"85,5 m²", 0, false},
+		{"1.5", 0, false},
+		{"450.000,50", 0, false},
+		{"1,250,000.5", 0, false},
+		{"m²", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			got, err := parseInteger(tt.raw)
+			if (err == nil) != tt.valid || got != tt.want {
+				t.Errorf("parseInteger(%q) = %d, %v, want %d, valid %v", tt.raw, got, err, tt.want, tt.valid)
+			}
+		})
+	}
+}
+
+func TestImportCSVKeepsStoredProperties(t *testing.T) {
+	db, err := database.NewDatabase(filepath.Join(t.TempDir(), "import.db"))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+	if err := db.RunMigrations(); err != nil {
+		t.Fatalf("failed to run migrations: %v", err)
+	}
+
+	const listed = "https://www.funda.nl/koop/amsterdam/huis-1/"
+	_, err = db.InsertProperties([]map[string]interface{}{{
+		"url":          listed,
+		"street":       "Keizersgracht 1",
+		"city":         "amsterdam",
+		"postal_code":  "1015 CJ",
+		"price":        float64(650000),
+		"living_area":  float64(100),
+		"energy_label": "A",
+		"status":       "active",
+		"listing_date": "2026-09-01",
+		"scraped_at":   "2026-10-15T08:00:00Z",
+	}})
+	if err != nil {
+		t.Fatalf("failed to store listing: %v", err)
+	}
+
+	// An archive of past sales with an old price of the stored listing, and
+	// no status or energy label
+	const sold = "https://www.funda.nl/koop/amsterdam/huis-2/"
+	csv := "url,street,city,postal_code,price,living_area\n" +
+		listed + ",Keizersgracht 1,amsterdam,1015 CJ,500000,100\n" +
+		sold + ",Keizersgracht 2,amsterdam,1015 CJ,450000,80\n"
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	report, err := NewImporter(db, logger).ImportCSV(strings.NewReader(csv), Options{})
+	if err != nil {
+		t.Fatalf("import failed: %v", err)
+	}
+	if report.Inserted != 1 || report.Duplicates != 1 || report.Updated != 0 || report.Invalid != 0 {
+		t.Fatalf("got %+v, want 1 inserted and 1 duplicate", report)
+	}
+	if row := report.Rows[0]; row.URL != listed || row.Status != "duplicate" {
+		t.Errorf("got first row %+v, want the stored listing as a duplicate", row)
+	}
+
+	batch, err := db.GetPropertiesBatch(nil, []string{listed})
+	if err != nil || len(batch.Properties) != 1 {
+		t.Fatalf("failed to get the stored listing: %v", err)
+	}
+	stored := batch.Properties[0]
+	if stored.Status != "active" || stored.Price != 650000 || stored.EnergyLabel != "A" {
+		t.Errorf("import changed the stored listing to status %q, price %d and energy label %q",
+			stored.Status, stored.Price, stored.EnergyLabel)
+	}
+}
@@ -0,0 +1,248 @@
+// Package live pushes events to connected dashboard clients as they happen:
+// newly inserted properties, so the dashboard does not have to poll the
+// property list, and the progress of spider runs and geocoding passes. The
+// spider managers and the database publish to the default hub; the API serves
+// its subscribers over a WebSocket and a Server-Sent Events stream.
+package live
+
+import (
+	"encoding/json"
+	"math"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/sirupsen/logrus"
+)
+
+// clientBuffer is the number of events a subscriber may fall behind before it
+// is dropped
+const clientBuffer = 64
+
+// Types of events
+const (
+	// EventNewProperty is sent for a newly inserted property
+	EventNewProperty = "new_property"
+	// EventSpiderStarted, EventSpiderProgress and EventSpiderFinished follow a
+	// spider run, with a SpiderProgress
+	EventSpiderStarted  = "spider_started"
+	EventSpiderProgress = "spider_progress"
+	EventSpiderFinished = "spider_finished"
+	// EventGeocodingProgress and EventGeocodingFinished follow a geocoding
+	// pass, with a GeocodingProgress
+	EventGeocodingProgress = "geocoding_progress"
+	EventGeocodingFinished = "geocoding_finished"
+)
+
+// ProgressEvents are the types of the events about the progress of jobs
+var ProgressEvents = []string{
+	EventSpiderStarted, EventSpiderProgress, EventSpiderFinished,
+	EventGeocodingProgress, EventGeocodingFinished,
+}
+
+// Event is a message sent to subscribers
+type Event struct {
+	Type string      `json:"type"`
+	Data interface{} `json:"data"`
+}
+
+// SpiderProgress is the progress of a spider run
+type SpiderProgress struct {
+	RunID      string `json:"run_id"`
+	SpiderType string `json:"spider_type"`
+	Place      string `json:"place"`
+	MaxPages   *int   `json:"max_pages,omitempty"`
+	// Pages is the number of result pages scraped so far and Listings the
+	// listings found on them
+	Pages    int `json:"pages"`
+	Listings int `json:"listings"`
+	// Items is the number of items received from the spider; Stored of them
+	// were written, New of them inserted, and Rejected failed validation
+	Items    int `json:"items"`
+	Stored   int `json:"stored"`
+	New      int `json:"new"`
+	Rejected int `json:"rejected"`
+	// ResultCount is the number of listings Funda reported for the search and
+	// Coverage the share of them the run went through, set once known
+	ResultCount *int     `json:"result_count,omitempty"`
+	Coverage    *float64 `json:"coverage,omitempty"`
+	Error       string   `json:"error,omitempty"`
+}
+
+// GeocodingProgress is the progress of a geocoding pass
+type GeocodingProgress struct {
+	Done    int     `json:"done"`
+	Total   int     `json:"total"`
+	Percent float64 `json:"percent"`
+	Error   string  `json:"error,omitempty"`
+}
+
+// Subscription receives the events of a hub. Events is closed when the
+// subscription is cancelled or dropped for falling behind.
+type Subscription struct {
+	Events <-chan []byte
+	city   string
+	types  map[string]bool
+	send   chan []byte
+}
+
+// Hub fans events out to its subscribers. Publishing never blocks: a
+// subscriber whose buffer is full is dropped and has to reconnect.
+type Hub struct {
+	logger  *logrus.Logger
+	mu      sync.Mutex
+	clients map[*Subscription]struct{}
+}
+
+var defaultHub atomic.Pointer[Hub]
+
+// NewHub creates a hub without subscribers
+func NewHub(logger *logrus.Logger) *Hub {
+	return &Hub{
+		logger:  logger,
+		clients: make(map[*Subscription]struct{}),
+	}
+}
+
+// SetDefault makes h the hub the spider managers and the database publish to
+func SetDefault(h *Hub) {
+	defaultHub.Store(h)
+}
+
+// Default returns the hub set with SetDefault, or nil
+func Default() *Hub {
+	return defaultHub.Load()
+}
+
+// Subscribe registers a subscriber for events of the given types. With a
+// city, the new properties of other cities are left out; events that are not
+// about a city are always sent.
+func (h *Hub) Subscribe(city string, types ...string) *Subscription {
+	send := make(chan []byte, clientBuffer)
+	s := &Subscription{
+		Events: send,
+		city:   strings.ToLower(strings.TrimSpace(city)),
+		types:  make(map[string]bool, len(types)),
+		send:   send,
+	}
+	for _, t := range types {
+		s.types[t] = true
+	}
+
+	h.mu.Lock()
+	h.clients[s] = struct{}{}
+	h.mu.Unlock()
+	return s
+}
+
+// Unsubscribe removes a subscriber and closes its events; it may be called
+// after the subscriber was dropped
+func (h *Hub) Unsubscribe(s *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.remove(s)
+}
+
+// Subscribers returns the number of connected subscribers
+func (h *Hub) Subscribers() int {
+	if h == nil {
+		return 0
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.clients)
+}
+
+// PublishProperties sends a new_property event for each property to the
+// subscribers of its city. The internal keys of scraped items, starting with
+// an underscore, are left out. A nil hub ignores the properties.
+func (h *Hub) PublishProperties(properties []map[string]interface{}) {
+	if h == nil || len(properties) == 0 {
+		return
+	}
+
+	for _, property := range properties {
+		data := make(map[string]interface{}, len(property))
+		for key, value := range property {
+			if !strings.HasPrefix(key, "_") {
+				data[key] = value
+			}
+		}
+		city, _ := property["city"].(string)
+		h.publish(EventNewProperty, strings.ToLower(city), data)
+	}
+}
+
+// PublishSpider sends an event about a spider run. A nil hub ignores it.
+func (h *Hub) PublishSpider(eventType string, progress SpiderProgress) {
+	if h == nil {
+		return
+	}
+	h.publish(eventType, "", progress)
+}
+
+// GeocodingProgress sends the progress of a geocoding pass. A nil hub ignores
+// it.
+func (h *Hub) GeocodingProgress(done, total int) {
+	if h == nil {
+		return
+	}
+	h.publish(EventGeocodingProgress, "", newGeocodingProgress(done, total, nil))
+}
+
+// GeocodingFinished sends the outcome of a geocoding pass. A nil hub ignores
+// it.
+func (h *Hub) GeocodingFinished(done, total int, err error) {
+	if h == nil {
+		return
+	}
+	h.publish(EventGeocodingFinished, "", newGeocodingProgress(done, total, err))
+}
+
+func newGeocodingProgress(done, total int, err error) GeocodingProgress {
+	progress := GeocodingProgress{Done: done, Total: total}
+	if total > 0 {
+		progress.Percent = math.Round(float64(done)*1000/float64(total)) / 10
+	}
+	if err != nil {
+		progress.Error = err.Error()
+	}
+	return progress
+}
+
+// publish encodes an event and queues it for its subscribers
+func (h *Hub) publish(eventType, city string, data interface{}) {
+	payload, err := json.Marshal(Event{Type: eventType, Data: data})
+	if err != nil {
+		h.logger.WithError(err).WithField("type", eventType).Warn("Failed to encode live event")
+		return
+	}
+	h.broadcast(eventType, city, payload)
+}
+
+// broadcast queues an event for its subscribers, dropping those that fell
+// behind
+func (h *Hub) broadcast(eventType, city string, payload []byte) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for s := range h.clients {
+		if !s.types[eventType] || (city != "" && s.city != "" && s.city != city) {
+			continue
+		}
+		select {
+		case s.send <- payload:
+		default:
+			h.logger.Warn("Dropped a live subscriber that fell behind")
+			h.remove(s)
+		}
+	}
+}
+
+// remove closes a subscriber's events once; the caller holds mu
+func (h *Hub) remove(s *Subscription) {
+	if _, ok := h.clients[s]; !ok {
+		return
+	}
+	delete(h.clients, s)
+	close(s.send)
+}
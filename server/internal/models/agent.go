@@ -0,0 +1,24 @@
+package models
+
+// Agent is a real estate agency listing properties
+type Agent struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+	URL  string `json:"url,omitempty"` // agency page on Funda
+}
+
+// AgentStats summarizes the listings of an agent
+type AgentStats struct {
+	Agent
+	Listings int `json:"listings"`
+	Active   int `json:"active"` // active or republished
+	Sold     int `json:"sold"`
+	// Mean days from listing to sale of the sold listings with both dates
+	AvgDaysToSell *float64 `json:"avg_days_to_sell"`
+	// Mean of how far the first asking price per m² of a listing lay above
+	// (positive) or below the median sold price per m² of its district over
+	// the past year, in percent, over the PricedListings listings with enough
+	// district sales to compare with
+	AvgPricingVsDistrict *float64 `json:"avg_pricing_vs_district_pct"`
+	PricedListings       int      `json:"priced_listings"`
+}
@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// AuditEntry is a field of a property changed by a scraped or imported item.
+// Values are stored as text; a nil value is NULL.
+type AuditEntry struct {
+	ID         int64     `json:"id"`
+	PropertyID int64     `json:"property_id"`
+	Field      string    `json:"field"`
+	OldValue   *string   `json:"old_value"`
+	NewValue   *string   `json:"new_value"`
+	Source     string    `json:"source"`
+	RunID      string    `json:"run_id,omitempty"`
+	ChangedAt  time.Time `json:"changed_at"`
+}
+
+// AuditFilter selects audit log entries; zero values match everything
+type AuditFilter struct {
+	PropertyID int64
+	Field      string
+	Source     string
+	RunID      string
+	Since      *time.Time
+	BeforeID   int64 // only entries older than this id, to page through the log
+	Limit      int
+}
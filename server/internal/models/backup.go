@@ -0,0 +1,10 @@
+package models
+
+import "time"
+
+// Backup is a database backup file in the backup directory
+type Backup struct {
+	Name      string    `json:"name"`
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,44 @@
+package models
+
+// Bid advice factors
+const (
+	BidFactorValuation     = "valuation"
+	BidFactorDistrictPrice = "district_price_change"
+	BidFactorDaysOnMarket  = "days_on_market"
+	BidFactorRelisted      = "relisted"
+)
+
+// BidAdviceFactor is one input of the bid advice and the percentage of the
+// asking price it moves the suggested bid by
+type BidAdviceFactor struct {
+	Factor     string  `json:"factor"`
+	Adjustment float64 `json:"adjustment_pct"`
+	Detail     string  `json:"detail"`
+}
+
+// BidAdvice suggests a bid range for a property from its estimated value, how
+// asking prices of sold homes in the district changed before the sale, how
+// long it has been on the market and how often it was republished or relisted.
+// Bids are rounded to thousands.
+type BidAdvice struct {
+	PropertyID  int64  `json:"property_id"`
+	AskingPrice int    `json:"asking_price"`
+	BidLow      int    `json:"bid_low"`
+	BidHigh     int    `json:"bid_high"`
+	Confidence  string `json:"confidence"` // "high", "medium" or "low"
+
+	// Value estimated from the median price per m² of comparable sales in the
+	// district over the past year, nil without enough comparables
+	Estimate    *int `json:"estimate"`
+	Comparables int  `json:"comparables"`
+
+	// Median change between the first and last asking price of the homes sold
+	// in the district over the past year
+	DistrictPriceChange      *float64 `json:"district_price_change_pct"`
+	DaysOnMarket             *int     `json:"days_on_market"`
+	DistrictMedianDaysToSell *float64 `json:"district_median_days_to_sell"`
+	// Times the home was republished or relisted under a new URL
+	TimesRelisted int `json:"times_relisted"`
+
+	Factors []BidAdviceFactor `json:"factors"`
+}
@@ -0,0 +1,42 @@
+package models
+
+import "time"
+
+// Kinds of change data capture sink
+const (
+	CDCSinkPostgres   = "postgres"   // upserts into a table of a PostgreSQL database
+	CDCSinkClickHouse = "clickhouse" // inserts into a ReplacingMergeTree table over HTTP
+	CDCSinkKafka      = "kafka"      // produces to a topic through a Kafka REST proxy
+)
+
+// CDCSink is the external system property changes are mirrored to. URL holds
+// credentials and is masked in API responses. SentSeq is the property change
+// cursor mirrored so far; 0 starts with a full copy.
+type CDCSink struct {
+	Enabled    bool       `json:"enabled"`
+	Kind       string     `json:"kind"`
+	URL        string     `json:"url"`
+	Target     string     `json:"target"` // table or topic
+	SentSeq    int64      `json:"sent_seq"`
+	LastSentAt *time.Time `json:"last_sent_at"`
+	LastError  string     `json:"last_error,omitempty"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+// CDCSinkRequest configures the change data capture sink. An empty URL keeps
+// the stored one, so a masked URL does not have to be sent back.
+type CDCSinkRequest struct {
+	Enabled bool   `json:"enabled"`
+	Kind    string `json:"kind"`
+	URL     string `json:"url"`
+	Target  string `json:"target"`
+}
+
+// CDCStatus is the sink configuration with its lag: the property changes not
+// mirrored yet and the age of the oldest of them
+type CDCStatus struct {
+	Sink           *CDCSink `json:"sink"`
+	LatestSeq      int64    `json:"latest_seq"`
+	PendingChanges int      `json:"pending_changes"`
+	LagSeconds     float64  `json:"lag_seconds"`
+}
@@ -0,0 +1,31 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Sources of district boundary versions
+const (
+	// BoundarySourceGenerated boundaries are the hulls computed from the PDOK
+	// postal code coordinates
+	BoundarySourceGenerated = "generated"
+	// BoundarySourceImported boundaries were uploaded, such as official ones
+	BoundarySourceImported = "imported"
+)
+
+// DistrictBoundaryVersion is a set of district boundaries as it was in force
+// from ValidFrom until ValidTo, which is nil for the current version. A new
+// version is kept whenever the boundaries are regenerated or imported with a
+// different geometry.
+type DistrictBoundaryVersion struct {
+	ID        int64      `json:"id"`
+	Source    string     `json:"source"`
+	ValidFrom time.Time  `json:"valid_from"`
+	ValidTo   *time.Time `json:"valid_to,omitempty"`
+	Districts int        `json:"districts"`
+	// Checksum is the SHA-256 of the features, which tells versions apart
+	Checksum string `json:"checksum"`
+	// Parameters are the HullOptions generated boundaries were made with
+	Parameters json.RawMessage `json:"parameters,omitempty"`
+}
@@ -0,0 +1,64 @@
+package models
+
+import "time"
+
+// DistrictReport is the scorecard of a 4-digit postal district, assembled from
+// the price, market phase, delisting and relisting analytics. Medians and
+// rates are nil when there is nothing to take them from.
+type DistrictReport struct {
+	District    string    `json:"district"`
+	City        string    `json:"city"`
+	GeneratedAt time.Time `json:"generated_at"`
+
+	Prices       DistrictPrices       `json:"prices"`
+	Inventory    DistrictInventory    `json:"inventory"`
+	Velocity     DistrictVelocity     `json:"velocity"`
+	EnergyLabels []EnergyLabelShare   `json:"energy_labels"`
+	Republishing DistrictRepublishing `json:"republishing"`
+	Withdrawals  DistrictDelistings   `json:"withdrawals"`
+	MarketPhase  MarketPhase          `json:"market_phase"`
+}
+
+// DistrictPrices is the price level of a district: sold prices over the past
+// year, the change of the sold price per m² against the year before, and the
+// asking prices of the listings on the market
+type DistrictPrices struct {
+	MedianSoldPrice         *float64 `json:"median_sold_price"`
+	MedianSoldPricePerSqm   *float64 `json:"median_sold_price_per_sqm"`
+	PriorSoldPricePerSqm    *float64 `json:"prior_sold_price_per_sqm"`
+	YearlyChange            *float64 `json:"yearly_change"` // fraction
+	MedianAskingPrice       *float64 `json:"median_asking_price"`
+	MedianAskingPricePerSqm *float64 `json:"median_asking_price_per_sqm"`
+}
+
+// DistrictInventory counts the listings of a district on the market now and
+// listed over the past 90 days
+type DistrictInventory struct {
+	Active      int `json:"active"`
+	NewListings int `json:"new_listings"`
+}
+
+// DistrictVelocity is how fast homes in a district sold over the past year
+type DistrictVelocity struct {
+	Sales            int      `json:"sales"`
+	SalesPerMonth    float64  `json:"sales_per_month"`
+	MedianDaysToSell *float64 `json:"median_days_to_sell"`
+}
+
+// EnergyLabelShare is the number and share of the homes of a district on the
+// market or sold over the past year with an energy label; homes without one
+// count as "unknown"
+type EnergyLabelShare struct {
+	Label string  `json:"label"`
+	Count int     `json:"count"`
+	Share float64 `json:"share"`
+}
+
+// DistrictRepublishing counts the listings of a district listed over the past
+// year that were taken off and put back on the market, under the same URL or
+// a new one
+type DistrictRepublishing struct {
+	Listings    int      `json:"listings"`
+	Republished int      `json:"republished"`
+	Rate        *float64 `json:"rate"`
+}
@@ -0,0 +1,29 @@
+package models
+
+// EnergyLabelStats is the energy label mix of the homes of a city or district
+// sold over a window, and the price per m² of each label against a baseline
+// label
+type EnergyLabelStats struct {
+	City     string `json:"city,omitempty"`
+	District string `json:"district,omitempty"`
+	Homes    int    `json:"homes"`
+	// Median price per m² of all homes, and of the baseline label the premiums
+	// are measured against
+	MedianPricePerSqm   *float64             `json:"median_price_per_sqm"`
+	BaselineLabel       string               `json:"baseline_label"`
+	BaselinePricePerSqm *float64             `json:"baseline_price_per_sqm"`
+	Labels              []EnergyLabelPremium `json:"labels"`
+}
+
+// EnergyLabelPremium is the number and share of homes with an energy label,
+// their median price per m² and how much more (or, when negative, less) that
+// is than the median of the baseline label, as a fraction. Homes without a
+// label count as "unknown". Medians and premiums are nil when there are no
+// homes to take them from.
+type EnergyLabelPremium struct {
+	Label             string   `json:"label"`
+	Count             int      `json:"count"`
+	Share             float64  `json:"share"`
+	MedianPricePerSqm *float64 `json:"median_price_per_sqm"`
+	Premium           *float64 `json:"premium"`
+}
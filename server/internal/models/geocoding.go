@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// GeocodingLease names the server running the geocoding pass, so replicas
+// sharing a database do not geocode the same addresses. The holder renews it
+// while the pass runs and lets it expire when the pass ends; a lease that is
+// no longer renewed, such as one of a server that crashed, expires and can
+// be taken over.
+type GeocodingLease struct {
+	Owner      string    `json:"owner"`
+	Active     bool      `json:"active"`
+	AcquiredAt time.Time `json:"acquired_at"`
+	RenewedAt  time.Time `json:"renewed_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	Done       int       `json:"done"`
+	Total      int       `json:"total"`
+}
+
+// GeocodingStatus is the number of properties waiting to be geocoded and the
+// lease of the current or last geocoding pass, nil before the first one.
+// Instance is the lease owner name of the server answering.
+type GeocodingStatus struct {
+	Instance string          `json:"instance"`
+	Pending  int             `json:"pending"`
+	Lease    *GeocodingLease `json:"lease"`
+}
@@ -0,0 +1,90 @@
+package models
+
+import "time"
+
+// GrafanaRange is the dashboard time range sent with Grafana JSON datasource requests
+type GrafanaRange struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+}
+
+// GrafanaTarget is a single query of a Grafana panel
+type GrafanaTarget struct {
+	Target string `json:"target"`
+	RefID  string `json:"refId"`
+	Type   string `json:"type"` // "timeserie" (default) or "table"
+}
+
+// GrafanaAdhocFilter is an ad hoc dashboard filter such as city = Amsterdam
+type GrafanaAdhocFilter struct {
+	Key      string `json:"key"`
+	Operator string `json:"operator"`
+	Value    string `json:"value"`
+}
+
+// GrafanaQueryRequest is the body of the datasource /query call
+type GrafanaQueryRequest struct {
+	Range         GrafanaRange         `json:"range"`
+	IntervalMs    int64                `json:"intervalMs"`
+	MaxDataPoints int                  `json:"maxDataPoints"`
+	Targets       []GrafanaTarget      `json:"targets"`
+	AdhocFilters  []GrafanaAdhocFilter `json:"adhocFilters"`
+}
+
+// GrafanaSearchRequest is the body of the datasource /search call
+type GrafanaSearchRequest struct {
+	Target string `json:"target"`
+}
+
+// GrafanaAnnotation is the annotation definition configured in the dashboard
+type GrafanaAnnotation struct {
+	Name       string      `json:"name"`
+	Datasource interface{} `json:"datasource"`
+	Enable     bool        `json:"enable"`
+	IconColor  string      `json:"iconColor,omitempty"`
+	Query      string      `json:"query"`
+}
+
+// GrafanaAnnotationRequest is the body of the datasource /annotations call
+type GrafanaAnnotationRequest struct {
+	Range      GrafanaRange      `json:"range"`
+	Annotation GrafanaAnnotation `json:"annotation"`
+}
+
+// GrafanaTimeSeries is a query result as [value, unix milliseconds] pairs
+type GrafanaTimeSeries struct {
+	Target     string       `json:"target"`
+	Datapoints [][2]float64 `json:"datapoints"`
+}
+
+// GrafanaColumn describes a column of a table result
+type GrafanaColumn struct {
+	Text string `json:"text"`
+	Type string `json:"type"`
+}
+
+// GrafanaTable is a query result in table form
+type GrafanaTable struct {
+	Type    string          `json:"type"`
+	Columns []GrafanaColumn `json:"columns"`
+	Rows    [][]interface{} `json:"rows"`
+}
+
+// GrafanaAnnotationEvent is an event drawn on Grafana graphs
+type GrafanaAnnotationEvent struct {
+	Annotation GrafanaAnnotation `json:"annotation"`
+	Time       int64             `json:"time"`
+	Title      string            `json:"title"`
+	Text       string            `json:"text"`
+	Tags       []string          `json:"tags"`
+}
+
+// GrafanaTagKey and GrafanaTagValue list the keys and values for ad hoc filters
+type GrafanaTagKey struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+type GrafanaTagValue struct {
+	Text string `json:"text"`
+}
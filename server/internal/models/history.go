@@ -0,0 +1,64 @@
+package models
+
+import "time"
+
+// Kinds of property history entries
+const (
+	HistoryListed               = "listed"
+	HistoryPriceChange          = "price_change"
+	HistoryStatusChange         = "status_change"
+	HistoryPriceAndStatusChange = "price_and_status_change"
+	HistoryRelisted             = "relisted" // first entry of a home listed again under a new URL
+)
+
+// PropertyHistoryEntry is a recorded price or status transition of a property
+type PropertyHistoryEntry struct {
+	ID             int64     `json:"id"`
+	ChangeType     string    `json:"change_type"`
+	Status         string    `json:"status"`
+	Price          *int      `json:"price"`
+	PreviousStatus *string   `json:"previous_status,omitempty"`
+	PreviousPrice  *int      `json:"previous_price,omitempty"`
+	PriceChange    *int      `json:"price_change,omitempty"`
+	PriceChangePct *float64  `json:"price_change_pct,omitempty"`
+	ListingDate    *string   `json:"listing_date,omitempty"`
+	ChangedAt      time.Time `json:"changed_at"`
+}
+
+// PropertyChangeEvent is a history entry together with the property it belongs to
+type PropertyChangeEvent struct {
+	PropertyID     int64     `json:"property_id"`
+	URL            string    `json:"url"`
+	Street         string    `json:"street"`
+	City           string    `json:"city"`
+	ChangeType     string    `json:"change_type"`
+	Status         string    `json:"status"`
+	Price          *int      `json:"price"`
+	PreviousStatus *string   `json:"previous_status,omitempty"`
+	PreviousPrice  *int      `json:"previous_price,omitempty"`
+	ChangedAt      time.Time `json:"changed_at"`
+}
+
+// PriceChange is a recorded change of the asking price of a property, by
+// ChangePct percent of the old price
+type PriceChange struct {
+	ID         int64     `json:"id"`
+	PropertyID int64     `json:"property_id"`
+	OldPrice   int       `json:"old_price"`
+	NewPrice   int       `json:"new_price"`
+	ChangePct  float64   `json:"change_pct"`
+	ChangedAt  time.Time `json:"changed_at"`
+}
+
+// PriceDrop is the largest recent price drop of a listed property together
+// with the property
+type PriceDrop struct {
+	PriceChange
+	URL        string `json:"url"`
+	Street     string `json:"street"`
+	City       string `json:"city"`
+	District   string `json:"district"`
+	Status     string `json:"status"`
+	Price      int    `json:"price"`
+	LivingArea *int   `json:"living_area"`
+}
@@ -0,0 +1,19 @@
+package models
+
+// Hull algorithms of the district boundary generation
+const (
+	HullConvex  = "convex"
+	HullConcave = "concave"
+)
+
+// HullOptions are the parameters the district boundaries are generated with.
+// Concavity applies to concave hulls: 1 gives the convex hull and lower values
+// follow the postal code points more closely. The hull is widened by
+// BufferDistance degrees and its corners are rounded by Smoothing passes of
+// corner cutting.
+type HullOptions struct {
+	Algorithm      string  `json:"algorithm"`
+	Concavity      float64 `json:"concavity"`
+	BufferDistance float64 `json:"buffer_distance"`
+	Smoothing      int     `json:"smoothing"`
+}
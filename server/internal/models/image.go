@@ -0,0 +1,10 @@
+package models
+
+// PropertyImage is a photo of a listing, in the order of the listing page
+type PropertyImage struct {
+	ID       int64  `json:"id"`
+	Position int    `json:"position"`
+	URL      string `json:"url"`
+	// Local copy of the primary photo, when thumbnails are cached
+	ThumbnailURL string `json:"thumbnail_url,omitempty"`
+}
@@ -0,0 +1,34 @@
+package models
+
+// ImportRowResult describes the outcome of importing a single row
+type ImportRowResult struct {
+	Row    int    `json:"row"`
+	URL    string `json:"url,omitempty"`
+	Status string `json:"status"` // "inserted", "updated", "duplicate" or "invalid"
+	Error  string `json:"error,omitempty"`
+}
+
+// ImportReport summarizes an import run with per-row results
+type ImportReport struct {
+	TotalRows  int               `json:"total_rows"`
+	Inserted   int               `json:"inserted"`
+	Updated    int               `json:"updated"`
+	Duplicates int               `json:"duplicates"`
+	Invalid    int               `json:"invalid"`
+	Rows       []ImportRowResult `json:"rows"`
+}
+
+// Add records a row result and updates the summary counters
+func (r *ImportReport) Add(result ImportRowResult) {
+	switch result.Status {
+	case "inserted":
+		r.Inserted++
+	case "updated":
+		r.Updated++
+	case "duplicate":
+		r.Duplicates++
+	case "invalid":
+		r.Invalid++
+	}
+	r.Rows = append(r.Rows, result)
+}
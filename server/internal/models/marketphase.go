@@ -0,0 +1,61 @@
+package models
+
+// Market phases
+const (
+	MarketPhaseSellers  = "sellers"
+	MarketPhaseBalanced = "balanced"
+	MarketPhaseBuyers   = "buyers"
+	MarketPhaseUnknown  = "unknown"
+)
+
+// Market phase components
+const (
+	MarketComponentInventory     = "inventory_trend"
+	MarketComponentDaysToSell    = "days_to_sell"
+	MarketComponentOverAsking    = "over_asking_share"
+	MarketComponentPriceMomentum = "price_momentum"
+)
+
+// MarketIndicators are the raw inputs of the market phase of a city or postal
+// district, measured over the last window of WindowDays and, for trends, the
+// window before it. Medians and shares are nil when the window has too few
+// sales.
+type MarketIndicators struct {
+	City       string
+	District   string
+	WindowDays int
+
+	// Listings on the market now and one window ago
+	Inventory      int
+	PriorInventory int
+
+	Sales            int
+	MedianDaysToSell *float64
+	// Share of the sales with a known first asking price that sold above it
+	OverAskingShare *float64
+	// Median sold price per m² in the window and the window before
+	PricePerSqm      *float64
+	PriorPricePerSqm *float64
+}
+
+// MarketPhaseComponent is one component of the market phase: its value and
+// its score from -1 (buyer's market) to 1 (seller's market), both nil when
+// there is too little data
+type MarketPhaseComponent struct {
+	Component string   `json:"component"`
+	Value     *float64 `json:"value"`
+	Score     *float64 `json:"score"`
+}
+
+// MarketPhase says whether a city or postal district is a buyer's or seller's
+// market, from the mean score of its components
+type MarketPhase struct {
+	City       string                 `json:"city,omitempty"`
+	District   string                 `json:"district,omitempty"`
+	Phase      string                 `json:"phase"`
+	Score      *float64               `json:"score"`
+	WindowDays int                    `json:"window_days"`
+	Inventory  int                    `json:"inventory"`
+	Sales      int                    `json:"sales"`
+	Components []MarketPhaseComponent `json:"components"`
+}
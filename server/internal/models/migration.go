@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// MigrationStatus describes a schema migration and whether it has been applied
+type MigrationStatus struct {
+	Version   int        `json:"version"`
+	Name      string     `json:"name"`
+	Applied   bool       `json:"applied"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+}
@@ -0,0 +1,10 @@
+package models
+
+import "time"
+
+// TableModification is the change counter of one or more tables: Version
+// grows with every change and ModifiedAt is the time of the last one
+type TableModification struct {
+	Version    int64
+	ModifiedAt time.Time
+}
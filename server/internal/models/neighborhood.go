@@ -0,0 +1,18 @@
+package models
+
+// NeighborhoodStats summarizes the homes of a neighborhood, as named by the
+// listings, on the market now and sold over the past year. Medians are nil
+// when there are no homes to take them from.
+type NeighborhoodStats struct {
+	City         string `json:"city"`
+	Neighborhood string `json:"neighborhood"`
+	// Listings for sale and the median asking price per m² of those with a
+	// living area
+	ActiveListings     int      `json:"active_listings"`
+	MedianAskingPerSqm *float64 `json:"median_asking_price_per_sqm"`
+	// Sales over the past year with their median price per m² and days from
+	// listing to sale
+	SoldLastYear     int      `json:"sold_last_year"`
+	MedianSoldPerSqm *float64 `json:"median_sold_price_per_sqm"`
+	MedianDaysToSell *float64 `json:"median_days_to_sell"`
+}
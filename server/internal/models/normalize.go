@@ -0,0 +1,24 @@
+package models
+
+// NormalizationChange is a field of a property whose stored value differs from
+// the value the current normalization rules produce
+type NormalizationChange struct {
+	PropertyID int64   `json:"property_id"`
+	URL        string  `json:"url"`
+	Field      string  `json:"field"`
+	Old        *string `json:"old"`
+	New        *string `json:"new"`
+	Reason     string  `json:"reason,omitempty"` // why a change was not applied
+}
+
+// NormalizationReport summarizes a normalization run. A dry run reports the
+// changes without writing them.
+type NormalizationReport struct {
+	DryRun            bool                  `json:"dry_run"`
+	Scanned           int                   `json:"scanned"`
+	ChangedProperties int                   `json:"changed_properties"`
+	FieldCounts       map[string]int        `json:"field_counts"`
+	Changes           []NormalizationChange `json:"changes"`
+	Truncated         bool                  `json:"truncated"` // more changes than listed
+	Skipped           []NormalizationChange `json:"skipped"`
+}
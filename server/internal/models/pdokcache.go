@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// PDOKCacheEntry holds the postal code centroids PDOK returned for a
+// district, as [lon, lat] coordinates
+type PDOKCacheEntry struct {
+	District  string       `json:"district"`
+	City      string       `json:"city"`
+	Points    [][2]float64 `json:"points"`
+	FetchedAt time.Time    `json:"fetched_at"`
+}
+
+// PDOKCacheStats describes the PDOK cache and the lookups of the district
+// hulls updates since the server started
+type PDOKCacheStats struct {
+	Entries         int        `json:"entries"`
+	OldestFetchedAt *time.Time `json:"oldest_fetched_at"`
+	NewestFetchedAt *time.Time `json:"newest_fetched_at"`
+	TTLDays         int        `json:"ttl_days"`
+	Offline         bool       `json:"offline"`
+
+	Hits      int64 `json:"hits"`       // fresh entries used
+	StaleHits int64 `json:"stale_hits"` // expired entries used offline or when PDOK failed
+	Misses    int64 `json:"misses"`     // lookups fetched from PDOK
+	Errors    int64 `json:"errors"`     // lookups without points, the district is skipped
+	// HitRate is the share of the lookups answered from the cache
+	HitRate float64 `json:"hit_rate"`
+}
@@ -0,0 +1,15 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Preference is a single UI setting stored per user or session
+type Preference struct {
+	Namespace string          `json:"namespace"`
+	Key       string          `json:"key"`
+	Value     json.RawMessage `json:"value"`
+	IsDefault bool            `json:"is_default"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
@@ -2,6 +2,20 @@ package models
 
 import "time"
 
+// District returns the postal district of a Dutch postal code, its 4 digits,
+// or "" when the postal code does not start with 4 digits
+func District(postalCode string) string {
+	if len(postalCode) < 4 {
+		return ""
+	}
+	for _, r := range postalCode[:4] {
+		if r < '0' || r > '9' {
+			return ""
+		}
+	}
+	return postalCode[:4]
+}
+
 type Property struct {
 	ID           int64     `json:"id"`
 	URL          string    `json:"url"`
@@ -10,6 +24,7 @@ type Property struct {
 	PropertyType string    `json:"property_type"`
 	City         string    `json:"city"`
 	PostalCode   string    `json:"postal_code"`
+	District     string    `json:"district"`
 	Price        int       `json:"price"`
 	YearBuilt    *int      `json:"year_built"`
 	LivingArea   *int      `json:"living_area"`
@@ -22,16 +37,159 @@ type Property struct {
 	Latitude     *float64  `json:"latitude"`
 	Longitude    *float64  `json:"longitude"`
 	EnergyLabel  string    `json:"energy_label"`
+	// Street-level photo near the property and its page in the provider's viewer
+	StreetImageURL  string `json:"street_image_url,omitempty"`
+	StreetImageLink string `json:"street_image_link,omitempty"`
+	// First listing of the same home when it was relisted under a new URL
+	CanonicalID *int64 `json:"canonical_id,omitempty"`
+	AgentID     *int64 `json:"agent_id,omitempty"` // listing agent, see /api/agents
+	Archived    bool   `json:"archived,omitempty"` // read from properties_archive
+	// Why and when the listing left the market, see the Delisting constants
+	DelistingReason string     `json:"delisting_reason,omitempty"`
+	DelistedAt      *time.Time `json:"delisted_at,omitempty"`
+	// How precisely the selling date is known, see the DatePrecision
+	// constants; empty without a selling date
+	SellingDatePrecision string `json:"selling_date_precision,omitempty"`
+	// Market of the listing and the currency its prices are in, see package country
+	Country  string `json:"country"`
+	Currency string `json:"currency"`
+	// Names of the custom tags of the property, on the list and export only
+	Tags []string `json:"tags,omitempty"`
+	// Scores of the scoring model by name, on the list only
+	Scores map[string]float64 `json:"scores,omitempty"`
+}
+
+// Precisions of a selling date. Some sold listings only show the month of the
+// sale; their selling date is the first of that month and is left out of the
+// days to sell.
+const (
+	DatePrecisionDay   = "day"
+	DatePrecisionMonth = "month"
+)
+
+// Reasons a listing left the market
+const (
+	DelistingSold      = "sold"      // found by the sold spider
+	DelistingWithdrawn = "withdrawn" // taken off the market without a sale
+	DelistingExpired   = "expired"   // not seen by a spider for LISTING_EXPIRY_DAYS days
+)
+
+// PropertyListOptions pages and sorts the property list
+type PropertyListOptions struct {
+	Limit  int // 0 returns all properties
+	Offset int
+	SortBy string
+	Order  string   // "asc" or "desc"
+	Tags   []string // only properties with all of these tags
+
+	// Ranges of the price, living area in m² and number of rooms, bounds
+	// included; 0 leaves a bound open. Properties without a living area or
+	// number of rooms do not match a range on it.
+	MinPrice, MaxPrice           int
+	MinLivingArea, MaxLivingArea int
+	MinRooms, MaxRooms           int
+	// Only properties with any of these energy labels and property types,
+	// compared case-insensitively, and statuses ("active" or "sold")
+	EnergyLabels  []string
+	PropertyTypes []string
+	Statuses      []string
+	// Ranges of scores of the scoring model by name, bounds included.
+	// Properties without a score do not match a range on it.
+	MinScores, MaxScores map[string]float64
+}
+
+// PropertyFilter restricts a property query to a date range, city and status.
+// Empty fields do not filter.
+type PropertyFilter struct {
+	StartDate string
+	EndDate   string
+	City      string
+	Status    string   // "active" or "sold"
+	Tags      []string // only properties with all of these tags
+}
+
+// PointCluster summarizes the geocoded properties in a cell of a map grid,
+// placed at their mean coordinates
+type PointCluster struct {
+	Latitude    float64 `json:"latitude"`
+	Longitude   float64 `json:"longitude"`
+	Count       int     `json:"count"`
+	ActiveCount int     `json:"active_count"`
+	AvgPrice    float64 `json:"avg_price"`
+}
+
+// NearbyProperty is a property with its distance to a searched point
+type NearbyProperty struct {
+	Property
+	DistanceMeters float64 `json:"distance_m"`
+}
+
+// Geocoding states of a property
+const (
+	GeocodeLocated   = "located"    // has coordinates
+	GeocodePending   = "pending"    // waits for the next geocoding pass
+	GeocodeFailed    = "failed"     // the geocoder found no coordinates
+	GeocodeNoAddress = "no_address" // the address is too incomplete to geocode
+)
+
+// PropertyDetail is a property with its history, geocoding state and the
+// nearby listings it compares with
+type PropertyDetail struct {
+	Property
+	GeocodeStatus string                 `json:"geocode_status"`
+	History       []PropertyHistoryEntry `json:"history"`
+	// Nearest homes of the same type and a similar living area, empty when
+	// the property has no coordinates
+	Comparables []NearbyProperty `json:"comparables"`
+}
+
+// PropertyList is a page of properties with the total number of matches
+type PropertyList struct {
+	Properties []Property `json:"properties"`
+	Total      int        `json:"total"`
+	Limit      int        `json:"limit"`
+	Offset     int        `json:"offset"`
+	SortBy     string     `json:"sort_by"`
+	Order      string     `json:"order"`
+}
+
+// PropertyBatchRequest asks for properties by ID, by listing URL or both
+type PropertyBatchRequest struct {
+	IDs  []int64  `json:"ids"`
+	URLs []string `json:"urls"`
+}
+
+// PropertyBatch holds the properties asked for by ID or listing URL, and the
+// IDs and URLs that matched none, such as those of archived properties
+type PropertyBatch struct {
+	Properties  []Property `json:"properties"`
+	MissingIDs  []int64    `json:"missing_ids"`
+	MissingURLs []string   `json:"missing_urls"`
+}
+
+// PropertySearchResult holds the properties matching a search query. Mode is
+// "fts" when the full-text index was used and "like" for the substring fallback.
+type PropertySearchResult struct {
+	Query      string     `json:"query"`
+	Mode       string     `json:"mode"`
+	Properties []Property `json:"properties"`
 }
 
 type PropertyStats struct {
-	TotalProperties int     `json:"total_properties"`
-	AveragePrice    float64 `json:"average_price"`
-	MedianPrice     float64 `json:"median_price"`
-	AvgDaysToSell   float64 `json:"avg_days_to_sell"`
-	TotalSold       int     `json:"total_sold"`
-	TotalActive     int     `json:"total_active"`
-	PricePerSqm     float64 `json:"price_per_sqm"`
+	TotalProperties   int     `json:"total_properties"`
+	AveragePrice      float64 `json:"average_price"`
+	MedianPrice       float64 `json:"median_price"`
+	AvgDaysToSell     float64 `json:"avg_days_to_sell"`
+	TotalSold         int     `json:"total_sold"`
+	TotalActive       int     `json:"total_active"`
+	PricePerSqm       float64 `json:"price_per_sqm"`
+	MedianPricePerSqm float64 `json:"median_price_per_sqm"`
+
+	// Medians per status; zero when there are no properties with that status
+	ActiveMedianPrice       float64 `json:"active_median_price"`
+	ActiveMedianPricePerSqm float64 `json:"active_median_price_per_sqm"`
+	SoldMedianPrice         float64 `json:"sold_median_price"`
+	SoldMedianPricePerSqm   float64 `json:"sold_median_price_per_sqm"`
 }
 
 type AreaStats struct {
@@ -42,6 +200,20 @@ type AreaStats struct {
 	AvgPricePerSqm float64 `json:"avg_price_per_sqm"`
 }
 
+// PostalCodeStats summarizes the properties of one full (PC6) postal code.
+// Medians are nil when there are no properties to take them from.
+type PostalCodeStats struct {
+	PostalCode            string   `json:"postal_code"`
+	District              string   `json:"district"`
+	PropertyCount         int      `json:"property_count"`
+	ActiveCount           int      `json:"active_count"`
+	SoldCount             int      `json:"sold_count"`
+	MedianPrice           *float64 `json:"median_price"`
+	MedianPricePerSqm     *float64 `json:"median_price_per_sqm"`
+	SoldMedianPricePerSqm *float64 `json:"sold_median_price_per_sqm"`
+	MedianDaysToSell      *float64 `json:"median_days_to_sell"`
+}
+
 type MetropolitanArea struct {
 	ID        int64    `json:"id"`
 	Name      string   `json:"name"`
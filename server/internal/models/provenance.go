@@ -0,0 +1,53 @@
+package models
+
+import "time"
+
+// Provenance sources that can write property fields
+const (
+	SourceSpider     = "spider"
+	SourceImport     = "import"
+	SourceEnrichment = "enrichment"
+	SourceManual     = "manual"
+)
+
+// FieldProvenance records which source and run last set a property field
+type FieldProvenance struct {
+	Source     string    `json:"source"`
+	RunID      string    `json:"run_id,omitempty"`
+	Confidence *float64  `json:"confidence,omitempty"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// SourcePriority returns the precedence of a source. Higher priorities win,
+// equal priorities fall back to last-write-wins.
+func SourcePriority(source string) int {
+	switch source {
+	case SourceManual:
+		return 3
+	case SourceEnrichment:
+		return 2
+	case SourceSpider, SourceImport:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// CanBeOverwrittenBy reports whether a write from source may replace the field
+func (p FieldProvenance) CanBeOverwrittenBy(source string) bool {
+	return SourcePriority(source) >= SourcePriority(p.Source)
+}
+
+// FieldUpdateRequest is used to correct property fields by hand or from enrichment jobs
+type FieldUpdateRequest struct {
+	Fields     map[string]interface{} `json:"fields"`
+	Source     string                 `json:"source"` // defaults to "manual"
+	Confidence *float64               `json:"confidence"`
+}
+
+// FieldUpdateResult lists which fields were written and which were kept
+// because a higher-precedence source set them
+type FieldUpdateResult struct {
+	Applied []string `json:"applied"`
+	Skipped []string `json:"skipped"`
+}
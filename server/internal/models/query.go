@@ -0,0 +1,11 @@
+package models
+
+// QueryResult is the outcome of a read-only SQL query: the column names and,
+// up to the row limit, the rows in the order of the columns
+type QueryResult struct {
+	Columns   []string        `json:"columns"`
+	Rows      [][]interface{} `json:"rows"`
+	RowCount  int             `json:"row_count"`
+	Truncated bool            `json:"truncated"` // more rows matched than the limit
+	ElapsedMs int64           `json:"elapsed_ms"`
+}
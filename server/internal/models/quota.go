@@ -0,0 +1,44 @@
+package models
+
+import "sort"
+
+// WorkspaceQuotas limit what a workspace uses. A nil quota is unlimited.
+type WorkspaceQuotas struct {
+	// Cities the scheduled spiders scrape, taken in alphabetical order
+	MaxCities *int `json:"max_cities"`
+	// Notifications about new listings and watched homes sent per UTC day
+	MaxNotificationsPerDay *int `json:"max_notifications_per_day"`
+	// Properties a single export may contain
+	MaxExportRows *int `json:"max_export_rows"`
+}
+
+// ScrapedCities splits cities into those the scheduled spiders scrape within
+// the MaxCities quota and those they skip, both in alphabetical order
+func (q WorkspaceQuotas) ScrapedCities(cities []string) (scraped, skipped []string) {
+	sorted := make([]string, len(cities))
+	copy(sorted, cities)
+	sort.Strings(sorted)
+	if q.MaxCities == nil || len(sorted) <= *q.MaxCities {
+		return sorted, []string{}
+	}
+	limit := max(*q.MaxCities, 0)
+	return sorted[:limit], sorted[limit:]
+}
+
+// QuotaUsage reports what a workspace, or the main dataset, used against its
+// quotas
+type QuotaUsage struct {
+	Quotas WorkspaceQuotas `json:"quotas"`
+	Day    string          `json:"day"` // today, in UTC
+
+	// Cities of the metropolitan areas the scheduled spiders scrape, and those
+	// skipped for the MaxCities quota
+	Cities        []string `json:"cities"`
+	SkippedCities []string `json:"skipped_cities"`
+	Properties    int      `json:"properties"`
+
+	// Counts by resource, such as "notifications" or "export_rows", of today
+	// and of the last 30 days including today
+	Today      map[string]int `json:"today"`
+	Last30Days map[string]int `json:"last_30_days"`
+}
@@ -0,0 +1,26 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// RejectedItem is a scraped or imported item that failed validation and was
+// kept for review instead of being stored
+type RejectedItem struct {
+	ID      int64    `json:"id"`
+	URL     string   `json:"url"`
+	Source  string   `json:"source"`
+	RunID   string   `json:"run_id"`
+	Reasons []string `json:"reasons"`
+	// Checks the item failed: validation rule ids and "builtin"
+	Rules     []string        `json:"rules,omitempty"`
+	Payload   json.RawMessage `json:"payload"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// RejectedItemList is a page of rejected items and the total number of them
+type RejectedItemList struct {
+	Total int            `json:"total"`
+	Items []RejectedItem `json:"items"`
+}
@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// RetentionReport is the outcome of applying the retention rules, or with
+// DryRun set, what applying them would delete
+type RetentionReport struct {
+	DryRun  bool              `json:"dry_run"`
+	RanAt   time.Time         `json:"ran_at"`
+	Deleted int64             `json:"deleted"`
+	Rules   []RetentionResult `json:"rules"`
+}
+
+// RetentionResult is the number of rows of a dataset older than its maximum
+// age, deleted or to be deleted
+type RetentionResult struct {
+	Dataset    string    `json:"dataset"`
+	MaxAgeDays int       `json:"max_age_days"`
+	Cutoff     time.Time `json:"cutoff"`
+	Rows       int64     `json:"rows"`
+}
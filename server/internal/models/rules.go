@@ -0,0 +1,333 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Rule is a condition on a property or a group of rules. A condition compares
+// a field with a value, such as {"field": "price", "op": "lte", "value": 450000};
+// a group holds rules of which all or any must match, such as
+// {"any": [{"field": "district", "op": "in", "value": ["1012", "1013"]}, ...]}.
+// A nil rule matches every property.
+type Rule struct {
+	All []Rule `json:"all,omitempty"`
+	Any []Rule `json:"any,omitempty"`
+
+	Field string      `json:"field,omitempty"`
+	Op    string      `json:"op,omitempty"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// Kinds of rule fields
+const (
+	RuleNumber = "number"
+	RuleText   = "text"
+	RuleList   = "list" // a list of text values, such as tags
+)
+
+// maxRuleDepth limits how deeply groups can be nested
+const maxRuleDepth = 8
+
+// ruleOperators are the operators each kind of field supports. "exists"
+// matches a property that has the field, or with the value false one that does
+// not.
+var ruleOperators = map[string][]string{
+	RuleNumber: {"eq", "ne", "lt", "lte", "gt", "gte", "in", "not_in", "exists"},
+	RuleText:   {"eq", "ne", "in", "not_in", "contains", "exists"},
+	RuleList:   {"contains", "not_contains", "exists"},
+}
+
+// RuleField is a property field rules can test. Value returns the field of a
+// property as a float64, string or []string, and false when the property does
+// not have it; market is nil where no district statistics are available.
+type RuleField struct {
+	Kind  string
+	Value func(p *Property, market MarketReference) (interface{}, bool)
+}
+
+// negatedOperators maps the negative operators to the ones they negate
+var negatedOperators = map[string]string{"ne": "eq", "not_in": "in", "not_contains": "contains"}
+
+// ruleFields are the fields rules can test, by name
+var ruleFields = map[string]RuleField{}
+
+// RegisterRuleField makes a field filterable in rules. Packages computing new
+// property attributes, such as scores, register them at init.
+func RegisterRuleField(name string, field RuleField) {
+	ruleFields[name] = field
+}
+
+// RuleFields returns the filterable fields and their kinds
+func RuleFields() map[string]string {
+	kinds := make(map[string]string, len(ruleFields))
+	for name, field := range ruleFields {
+		kinds[name] = field.Kind
+	}
+	return kinds
+}
+
+func numberField(value func(p *Property) (float64, bool)) RuleField {
+	return RuleField{Kind: RuleNumber, Value: func(p *Property, _ MarketReference) (interface{}, bool) {
+		return value(p)
+	}}
+}
+
+func textField(value func(p *Property) string) RuleField {
+	return RuleField{Kind: RuleText, Value: func(p *Property, _ MarketReference) (interface{}, bool) {
+		v := value(p)
+		return v, v != ""
+	}}
+}
+
+func optionalInt(v *int) (float64, bool) {
+	if v == nil {
+		return 0, false
+	}
+	return float64(*v), true
+}
+
+// propertyDistrict returns the district of a property, from its postal code
+// when it was not set
+func propertyDistrict(p *Property) string {
+	if p.District != "" {
+		return p.District
+	}
+	return District(p.PostalCode)
+}
+
+// pricePerSqm returns the asking price per m² of a property
+func pricePerSqm(p *Property) (float64, bool) {
+	if p.Price <= 0 || p.LivingArea == nil || *p.LivingArea <= 0 {
+		return 0, false
+	}
+	return float64(p.Price) / float64(*p.LivingArea), true
+}
+
+func init() {
+	RegisterRuleField("price", numberField(func(p *Property) (float64, bool) { return float64(p.Price), true }))
+	RegisterRuleField("living_area", numberField(func(p *Property) (float64, bool) { return optionalInt(p.LivingArea) }))
+	RegisterRuleField("num_rooms", numberField(func(p *Property) (float64, bool) { return optionalInt(p.NumRooms) }))
+	RegisterRuleField("year_built", numberField(func(p *Property) (float64, bool) { return optionalInt(p.YearBuilt) }))
+	RegisterRuleField("price_per_sqm", numberField(pricePerSqm))
+	RegisterRuleField("city", textField(func(p *Property) string { return p.City }))
+	RegisterRuleField("district", textField(propertyDistrict))
+	RegisterRuleField("postal_code", textField(func(p *Property) string { return p.PostalCode }))
+	RegisterRuleField("neighborhood", textField(func(p *Property) string { return p.Neighborhood }))
+	RegisterRuleField("property_type", textField(func(p *Property) string { return p.PropertyType }))
+	RegisterRuleField("energy_label", textField(func(p *Property) string { return p.EnergyLabel }))
+	RegisterRuleField("status", textField(func(p *Property) string { return p.Status }))
+	RegisterRuleField("country", textField(func(p *Property) string { return p.Country }))
+	RegisterRuleField("tags", RuleField{Kind: RuleList, Value: func(p *Property, _ MarketReference) (interface{}, bool) {
+		return p.Tags, len(p.Tags) > 0
+	}})
+	// The percentage of the district's current listings asking at most the
+	// property's price per m²
+	RegisterRuleField("district_percentile", RuleField{Kind: RuleNumber, Value: func(p *Property, market MarketReference) (interface{}, bool) {
+		perSqm, ok := pricePerSqm(p)
+		district := propertyDistrict(p)
+		if !ok || market == nil || district == "" {
+			return nil, false
+		}
+		return market.PricePerSqmPercentile(district, perSqm)
+	}})
+}
+
+// IsGroup reports whether the rule is a group rather than a condition
+func (r *Rule) IsGroup() bool {
+	return r.All != nil || r.Any != nil
+}
+
+// Validate checks that every condition tests a known field with an operator
+// and value of its kind
+func (r *Rule) Validate() error {
+	if r == nil {
+		return nil
+	}
+	return r.validate(1)
+}
+
+func (r *Rule) validate(depth int) error {
+	if depth > maxRuleDepth {
+		return fmt.Errorf("rules can be nested at most %d levels deep", maxRuleDepth)
+	}
+	if r.IsGroup() {
+		if r.Field != "" || r.Op != "" || r.Value != nil {
+			return errors.New("a rule is either a group (all, any) or a condition (field, op, value)")
+		}
+		if r.All != nil && r.Any != nil {
+			return errors.New("a group has either all or any rules")
+		}
+		for i := range r.All {
+			if err := r.All[i].validate(depth + 1); err != nil {
+				return err
+			}
+		}
+		for i := range r.Any {
+			if err := r.Any[i].validate(depth + 1); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	field, ok := ruleFields[r.Field]
+	if !ok {
+		return fmt.Errorf("unknown rule field %q, must be one of %s", r.Field, strings.Join(ruleFieldNames(), ", "))
+	}
+	supported := false
+	for _, op := range ruleOperators[field.Kind] {
+		if op == r.Op {
+			supported = true
+		}
+	}
+	if !supported {
+		return fmt.Errorf("rule field %s supports the operators %s", r.Field, strings.Join(ruleOperators[field.Kind], ", "))
+	}
+
+	switch {
+	case r.Op == "exists":
+		if _, ok := r.Value.(bool); r.Value != nil && !ok {
+			return fmt.Errorf("the value of %s exists must be true or false", r.Field)
+		}
+	case r.Op == "in" || r.Op == "not_in":
+		values, ok := r.Value.([]interface{})
+		if !ok || len(values) == 0 {
+			return fmt.Errorf("the value of %s %s must be a non-empty list", r.Field, r.Op)
+		}
+		for _, v := range values {
+			if !ruleValueOfKind(v, field.Kind) {
+				return fmt.Errorf("the values of %s %s must be %ss", r.Field, r.Op, field.Kind)
+			}
+		}
+	case field.Kind == RuleList:
+		if _, ok := r.Value.(string); !ok {
+			return fmt.Errorf("the value of %s %s must be text", r.Field, r.Op)
+		}
+	default:
+		if !ruleValueOfKind(r.Value, field.Kind) {
+			return fmt.Errorf("the value of %s %s must be a %s", r.Field, r.Op, field.Kind)
+		}
+	}
+	return nil
+}
+
+// ruleValueOfKind reports whether a decoded JSON value suits a field kind
+func ruleValueOfKind(v interface{}, kind string) bool {
+	switch v.(type) {
+	case float64, int:
+		return kind == RuleNumber
+	case string:
+		return kind == RuleText
+	}
+	return false
+}
+
+func ruleFieldNames() []string {
+	names := make([]string, 0, len(ruleFields))
+	for name := range ruleFields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Matches reports whether a property meets the rule. market provides the
+// district statistics of relative fields and may be nil. A condition on a field
+// the property does not have fails, except "exists" with false and the
+// negative operators ne, not_in and not_contains.
+func (r *Rule) Matches(p *Property, market MarketReference) bool {
+	if r == nil {
+		return true
+	}
+	if r.All != nil {
+		for i := range r.All {
+			if !r.All[i].Matches(p, market) {
+				return false
+			}
+		}
+		return true
+	}
+	if r.Any != nil {
+		for i := range r.Any {
+			if r.Any[i].Matches(p, market) {
+				return true
+			}
+		}
+		return false
+	}
+
+	field, ok := ruleFields[r.Field]
+	if !ok {
+		return false
+	}
+	value, present := field.Value(p, market)
+	switch r.Op {
+	case "exists":
+		want, ok := r.Value.(bool)
+		return present == (want || !ok)
+	}
+	if positive, ok := negatedOperators[r.Op]; ok {
+		return !present || !ruleCompare(field.Kind, positive, value, r.Value)
+	}
+	return present && ruleCompare(field.Kind, r.Op, value, r.Value)
+}
+
+// ruleCompare applies a positive operator to the value of a field
+func ruleCompare(kind, op string, value, operand interface{}) bool {
+	if op == "in" {
+		list, _ := operand.([]interface{})
+		for _, item := range list {
+			if ruleCompare(kind, "eq", value, item) {
+				return true
+			}
+		}
+		return false
+	}
+
+	switch kind {
+	case RuleNumber:
+		v, _ := value.(float64)
+		var x float64
+		switch o := operand.(type) {
+		case float64:
+			x = o
+		case int:
+			x = float64(o)
+		default:
+			return false
+		}
+		switch op {
+		case "eq":
+			return v == x
+		case "lt":
+			return v < x
+		case "lte":
+			return v <= x
+		case "gt":
+			return v > x
+		case "gte":
+			return v >= x
+		}
+	case RuleText:
+		v, _ := value.(string)
+		x, _ := operand.(string)
+		switch op {
+		case "eq":
+			return strings.EqualFold(v, x)
+		case "contains":
+			return strings.Contains(strings.ToLower(v), strings.ToLower(x))
+		}
+	case RuleList:
+		values, _ := value.([]string)
+		x, _ := operand.(string)
+		for _, v := range values {
+			if strings.EqualFold(v, x) {
+				return true
+			}
+		}
+	}
+	return false
+}
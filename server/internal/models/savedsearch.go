@@ -0,0 +1,144 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+// SearchCriteria are the filters of a saved search: the notification filters,
+// an optional city and criteria relative to the market
+type SearchCriteria struct {
+	City string `json:"city,omitempty"`
+	TelegramFilters
+	RelativeCriteria
+}
+
+// Matches reports whether a property meets the criteria. Relative criteria are
+// evaluated against market, which may be nil when none are set.
+func (c *SearchCriteria) Matches(property *Property, market MarketReference) bool {
+	if c.City != "" && !strings.EqualFold(c.City, property.City) {
+		return false
+	}
+	if !c.TelegramFilters.IsPropertyAllowed(property, market) {
+		return false
+	}
+	return c.RelativeCriteria.Matches(property, market)
+}
+
+// RelativeCriteria compare a listing with the market of its district at the
+// time it is matched, rather than with fixed numbers
+type RelativeCriteria struct {
+	// Asking price per m² among the given percentage of cheapest current
+	// listings of the district, e.g. 20 for the bottom 20%
+	MaxPricePerSqmPercentile *float64 `json:"max_price_per_sqm_percentile,omitempty"`
+	// Asking price at least the given percentage below the value estimated
+	// from the district's sales of the past year
+	MinBelowEstimatePct *float64 `json:"min_below_estimate_pct,omitempty"`
+}
+
+// MarketReference provides the district statistics relative criteria are
+// evaluated against. Both methods return false when the district has too
+// little data.
+type MarketReference interface {
+	// PricePerSqmPercentile returns the percentage of the district's current
+	// listings whose asking price per m² is at most pricePerSqm
+	PricePerSqmPercentile(district string, pricePerSqm float64) (float64, bool)
+	// EstimateValue estimates the value of a home of the given living area in
+	// the district, leaving the property itself out of the comparables
+	EstimateValue(district string, livingArea float64, exceptID int64) (int, bool)
+}
+
+// IsSet reports whether any relative criterion is set
+func (r *RelativeCriteria) IsSet() bool {
+	return r.MaxPricePerSqmPercentile != nil || r.MinBelowEstimatePct != nil
+}
+
+// Validate checks that the relative criteria are percentages in range
+func (r *RelativeCriteria) Validate() error {
+	if p := r.MaxPricePerSqmPercentile; p != nil && (*p <= 0 || *p > 100) {
+		return errors.New("max_price_per_sqm_percentile must be above 0 and at most 100")
+	}
+	if p := r.MinBelowEstimatePct; p != nil && (*p < 0 || *p >= 100) {
+		return errors.New("min_below_estimate_pct must be at least 0 and below 100")
+	}
+	return nil
+}
+
+// Matches reports whether a property meets the relative criteria. A property
+// without the price, living area or district a criterion needs, or in a
+// district with too little data, does not match it.
+func (r *RelativeCriteria) Matches(property *Property, market MarketReference) bool {
+	if !r.IsSet() {
+		return true
+	}
+	district := District(property.PostalCode)
+	if market == nil || district == "" || property.Price <= 0 ||
+		property.LivingArea == nil || *property.LivingArea <= 0 {
+		return false
+	}
+	livingArea := float64(*property.LivingArea)
+
+	if r.MaxPricePerSqmPercentile != nil {
+		percentile, ok := market.PricePerSqmPercentile(district, float64(property.Price)/livingArea)
+		if !ok || percentile > *r.MaxPricePerSqmPercentile {
+			return false
+		}
+	}
+	if r.MinBelowEstimatePct != nil {
+		estimate, ok := market.EstimateValue(district, livingArea, property.ID)
+		if !ok || float64(property.Price) > float64(estimate)*(1-*r.MinBelowEstimatePct/100) {
+			return false
+		}
+	}
+	return true
+}
+
+// SavedSearch is a named set of search criteria
+type SavedSearch struct {
+	ID        int64          `json:"id"`
+	Name      string         `json:"name"`
+	Criteria  SearchCriteria `json:"criteria"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+}
+
+// SavedSearchRequest creates or replaces a saved search
+type SavedSearchRequest struct {
+	Name     string         `json:"name"`
+	Criteria SearchCriteria `json:"criteria"`
+}
+
+// BacktestWeek is the number of matching listings that appeared in a week
+// starting on Monday
+type BacktestWeek struct {
+	WeekStart string `json:"week_start"`
+	Listings  int    `json:"listings"`
+}
+
+// PriceDistribution summarizes the asking prices of a set of listings
+type PriceDistribution struct {
+	Min    float64 `json:"min"`
+	P25    float64 `json:"p25"`
+	Median float64 `json:"median"`
+	P75    float64 `json:"p75"`
+	Max    float64 `json:"max"`
+}
+
+// SearchBacktest replays the criteria of a search over the listings that
+// appeared between From and To (YYYY-MM-DD). Sale speed covers the matching
+// listings that have sold since.
+type SearchBacktest struct {
+	Criteria          SearchCriteria    `json:"criteria"`
+	From              string            `json:"from"`
+	To                string            `json:"to"`
+	Total             int               `json:"total"`
+	AveragePerWeek    float64           `json:"average_per_week"`
+	Weeks             []BacktestWeek    `json:"weeks"`
+	Price             PriceDistribution `json:"price"`
+	MedianPricePerSqm float64           `json:"median_price_per_sqm"`
+	Sold              int               `json:"sold"`
+	MedianDaysToSell  float64           `json:"median_days_to_sell"`
+	SoldWithin30Days  int               `json:"sold_within_30_days"`
+	SoldWithin90Days  int               `json:"sold_within_90_days"`
+}
@@ -0,0 +1,120 @@
+package models
+
+import "testing"
+
+// stubMarket is a MarketReference on fixed district statistics
+type stubMarket struct {
+	// Asking prices per m² of the current listings by district; a district
+	// with fewer than stubMinComparables has too little data
+	pricesPerSqm map[string][]float64
+	// Estimated value per m² by district
+	valuePerSqm map[string]float64
+}
+
+// stubMinComparables mirrors the comparables the database needs
+const stubMinComparables = 5
+
+func (m stubMarket) PricePerSqmPercentile(district string, pricePerSqm float64) (float64, bool) {
+	prices := m.pricesPerSqm[district]
+	if len(prices) < stubMinComparables {
+		return 0, false
+	}
+	atMost := 0
+	for _, p := range prices {
+		if p <= pricePerSqm {
+			atMost++
+		}
+	}
+	return float64(atMost) / float64(len(prices)) * 100, true
+}
+
+func (m stubMarket) EstimateValue(district string, livingArea float64, exceptID int64) (int, bool) {
+	value, ok := m.valuePerSqm[district]
+	if !ok {
+		return 0, false
+	}
+	return int(value * livingArea), true
+}
+
+func TestRelativeCriteriaMatches(t *testing.T) {
+	market := stubMarket{
+		pricesPerSqm: map[string][]float64{
+			// Listings asking 5000 to 9000 per m²
+			"1012": {5000, 6000, 7000, 8000, 9000},
+			"1013": {5000, 6000},
+		},
+		valuePerSqm: map[string]float64{"1012": 7000},
+	}
+	pct := func(v float64) *float64 { return &v }
+	area := func(v int) *int { return &v }
+	// home of 100 m² in district 1012
+	home := func(price int) *Property {
+		return &Property{ID: 1, PostalCode: "1012 AB", Price: price, LivingArea: area(100)}
+	}
+
+	tests := []struct {
+		name     string
+		criteria RelativeCriteria
+		property *Property
+		market   MarketReference
+		want     bool
+	}{
+		{"no criteria", RelativeCriteria{}, home(900000), nil, true},
+		{"in the bottom 40%", RelativeCriteria{MaxPricePerSqmPercentile: pct(40)}, home(600000), market, true},
+		{"above the bottom 40%", RelativeCriteria{MaxPricePerSqmPercentile: pct(40)}, home(700000), market, false},
+		{"cheapest listing in the bottom 20%", RelativeCriteria{MaxPricePerSqmPercentile: pct(20)}, home(500000), market, true},
+		{"10% below the estimate", RelativeCriteria{MinBelowEstimatePct: pct(10)}, home(630000), market, true},
+		{"less than 10% below the estimate", RelativeCriteria{MinBelowEstimatePct: pct(10)}, home(640000), market, false},
+		{"above the estimate", RelativeCriteria{MinBelowEstimatePct: pct(0)}, home(710000), market, false},
+		{"both criteria met", RelativeCriteria{MaxPricePerSqmPercentile: pct(40), MinBelowEstimatePct: pct(10)}, home(600000), market, true},
+		{"percentile met, estimate not", RelativeCriteria{MaxPricePerSqmPercentile: pct(80), MinBelowEstimatePct: pct(10)}, home(650000), market, false},
+		{"nil market", RelativeCriteria{MaxPricePerSqmPercentile: pct(100)}, home(500000), nil, false},
+		{"unknown district", RelativeCriteria{MaxPricePerSqmPercentile: pct(100)},
+			&Property{PostalCode: "9999 ZZ", Price: 500000, LivingArea: area(100)}, market, false},
+		{"no estimate for the district", RelativeCriteria{MinBelowEstimatePct: pct(0)},
+			&Property{PostalCode: "1013 AA", Price: 100000, LivingArea: area(100)}, market, false},
+		{"too few comparables", RelativeCriteria{MaxPricePerSqmPercentile: pct(100)},
+			&Property{PostalCode: "1013 AA", Price: 500000, LivingArea: area(100)}, market, false},
+		{"no postal district", RelativeCriteria{MaxPricePerSqmPercentile: pct(100)},
+			&Property{PostalCode: "AB", Price: 500000, LivingArea: area(100)}, market, false},
+		{"no living area", RelativeCriteria{MaxPricePerSqmPercentile: pct(100)},
+			&Property{PostalCode: "1012 AB", Price: 500000}, market, false},
+		{"no price", RelativeCriteria{MaxPricePerSqmPercentile: pct(100)}, home(0), market, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.criteria.Matches(tt.property, tt.market); got != tt.want {
+				t.Errorf("Matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRelativeCriteriaValidate(t *testing.T) {
+	pct := func(v float64) *float64 { return &v }
+
+	tests := []struct {
+		name     string
+		criteria RelativeCriteria
+		valid    bool
+	}{
+		{"none", RelativeCriteria{}, true},
+		{"bottom 20%", RelativeCriteria{MaxPricePerSqmPercentile: pct(20)}, true},
+		{"all listings", RelativeCriteria{MaxPricePerSqmPercentile: pct(100)}, true},
+		{"zero percentile", RelativeCriteria{MaxPricePerSqmPercentile: pct(0)}, false},
+		{"negative percentile", RelativeCriteria{MaxPricePerSqmPercentile: pct(-5)}, false},
+		{"percentile above 100", RelativeCriteria{MaxPricePerSqmPercentile: pct(101)}, false},
+		{"at the estimate", RelativeCriteria{MinBelowEstimatePct: pct(0)}, true},
+		{"10% below the estimate", RelativeCriteria{MinBelowEstimatePct: pct(10)}, true},
+		{"negative below estimate", RelativeCriteria{MinBelowEstimatePct: pct(-1)}, false},
+		{"100% below the estimate", RelativeCriteria{MinBelowEstimatePct: pct(100)}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.criteria.Validate()
+			if (err == nil) != tt.valid {
+				t.Errorf("Validate() = %v, want valid %v", err, tt.valid)
+			}
+		})
+	}
+}
@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// Outcomes of a scheduled job run
+const (
+	JobRunRunning   = "running"
+	JobRunSucceeded = "succeeded"
+	JobRunFailed    = "failed"
+)
+
+// JobRun is the latest run of a scheduled job
+type JobRun struct {
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	Status     string     `json:"status"`
+	Error      string     `json:"error,omitempty"`
+}
+
+// ScheduledJob is a job of the scheduler with its cron expression and the
+// times it runs next
+type ScheduledJob struct {
+	Name        string      `json:"name"`
+	Description string      `json:"description"`
+	Cron        string      `json:"cron"`
+	Enabled     bool        `json:"enabled"`
+	NextRuns    []time.Time `json:"next_runs"`
+	LastRun     *JobRun     `json:"last_run"`
+}
@@ -0,0 +1,43 @@
+package models
+
+// Self-check result statuses
+const (
+	CheckOK     = "ok"
+	CheckFailed = "failed"
+)
+
+// SelfCheckResult is the outcome of a single startup check
+type SelfCheckResult struct {
+	Category string `json:"category"` // "database", "schema", "directory" or "prerequisite"
+	Name     string `json:"name"`
+	Status   string `json:"status"`
+	Message  string `json:"message,omitempty"`
+}
+
+// SelfCheckReport collects the results of all startup checks
+type SelfCheckReport struct {
+	OK      bool              `json:"ok"`
+	Results []SelfCheckResult `json:"results"`
+}
+
+// Add records a check result and updates the overall status
+func (r *SelfCheckReport) Add(category, name string, err error) {
+	result := SelfCheckResult{Category: category, Name: name, Status: CheckOK}
+	if err != nil {
+		result.Status = CheckFailed
+		result.Message = err.Error()
+		r.OK = false
+	}
+	r.Results = append(r.Results, result)
+}
+
+// Failures returns the failed checks
+func (r *SelfCheckReport) Failures() []SelfCheckResult {
+	var failures []SelfCheckResult
+	for _, result := range r.Results {
+		if result.Status == CheckFailed {
+			failures = append(failures, result)
+		}
+	}
+	return failures
+}
@@ -0,0 +1,14 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// SharedView is a stored filter/view definition addressable by a short token
+type SharedView struct {
+	Token       string          `json:"token"`
+	Definition  json.RawMessage `json:"definition"`
+	AccessCount int             `json:"access_count"`
+	CreatedAt   time.Time       `json:"created_at"`
+}
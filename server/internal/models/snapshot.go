@@ -0,0 +1,15 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// PropertySnapshot is the raw spider payload stored for a property at scrape time
+type PropertySnapshot struct {
+	ID         int64           `json:"id"`
+	PropertyID int64           `json:"property_id"`
+	URL        string          `json:"url"`
+	Payload    json.RawMessage `json:"payload"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// SpiderRun is the record of a spider run for one city. ResultCount is the
+// number of listings Funda reported for the search and Coverage the share of
+// them the run went through, both nil when unknown.
+type SpiderRun struct {
+	RunID      string     `json:"run_id"`
+	SpiderType string     `json:"spider_type"`
+	Place      string     `json:"place"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	// Pages is the number of result pages scraped and Listings the listings
+	// found on them
+	Pages    int `json:"pages"`
+	Listings int `json:"listings"`
+	// Items is the number of items received from the spider; Stored of them
+	// were written, New of them inserted, and Rejected failed validation
+	Items       int      `json:"items"`
+	Stored      int      `json:"stored"`
+	New         int      `json:"new"`
+	Rejected    int      `json:"rejected"`
+	ResultCount *int     `json:"result_count,omitempty"`
+	Coverage    *float64 `json:"coverage,omitempty"`
+	Error       string   `json:"error,omitempty"`
+}
@@ -0,0 +1,61 @@
+package models
+
+import "time"
+
+// PriceBucket holds the number of active and sold properties in a price range
+type PriceBucket struct {
+	MinPrice    int `json:"min_price"`
+	MaxPrice    int `json:"max_price"`
+	ActiveCount int `json:"active_count"`
+	SoldCount   int `json:"sold_count"`
+}
+
+// PriceHistogram is the response of the price histogram endpoint
+type PriceHistogram struct {
+	BucketSize int           `json:"bucket_size"`
+	Buckets    []PriceBucket `json:"buckets"`
+}
+
+// ScatterPoint is a single (living area, price) sample for scatter plots
+type ScatterPoint struct {
+	LivingArea int    `json:"living_area"`
+	Price      int    `json:"price"`
+	District   string `json:"district"`
+	Status     string `json:"status"`
+}
+
+// ScatterSample is a downsampled set of scatter points
+type ScatterSample struct {
+	Method    string         `json:"method"`
+	Total     int            `json:"total"`
+	Points    []ScatterPoint `json:"points"`
+	Truncated bool           `json:"truncated"`
+}
+
+// TimeSeriesPoint is the value of a market metric for the bucket starting at Time
+type TimeSeriesPoint struct {
+	Time  time.Time `json:"time"`
+	Value float64   `json:"value"`
+}
+
+// MonthlyTrend summarizes the properties sold in a month, in one district when
+// the trends are grouped by district
+type MonthlyTrend struct {
+	Month             string  `json:"month"` // YYYY-MM
+	District          string  `json:"district,omitempty"`
+	Volume            int     `json:"volume"`
+	MedianPrice       float64 `json:"median_price"`
+	MedianPricePerSqm float64 `json:"median_price_per_sqm"`
+	MedianDaysToSell  float64 `json:"median_days_to_sell"`
+}
+
+// DistrictDelistings counts the listings of a district that left the market,
+// by reason
+type DistrictDelistings struct {
+	District       string  `json:"district"`
+	Delisted       int     `json:"delisted"`
+	Sold           int     `json:"sold"`
+	Withdrawn      int     `json:"withdrawn"`
+	Expired        int     `json:"expired"`
+	WithdrawalRate float64 `json:"withdrawal_rate"` // share of the delisted listings that were withdrawn
+}
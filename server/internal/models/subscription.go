@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// DistrictSubscription is a postal district whose new listings are all
+// notified, whatever the notification filters, with a summary of its market
+type DistrictSubscription struct {
+	District     string    `json:"district"`
+	SubscribedAt time.Time `json:"subscribed_at"`
+	// Listings for sale, the median asking price per m² of those with a living
+	// area, and how many of them appeared over the past 30 days
+	ActiveListings     int      `json:"active_listings"`
+	MedianAskingPerSqm *float64 `json:"median_asking_price_per_sqm"`
+	NewLast30Days      int      `json:"new_last_30_days"`
+	// Sales over the past year with their median price per m² and days from
+	// listing to sale
+	SoldLastYear     int      `json:"sold_last_year"`
+	MedianSoldPerSqm *float64 `json:"median_sold_price_per_sqm"`
+	MedianDaysToSell *float64 `json:"median_days_to_sell"`
+}
@@ -0,0 +1,18 @@
+package models
+
+// SyncBatch is a page of property changes for an offline client. Properties
+// are upserts, Deleted lists tombstones; Cursor is passed to fetch the next
+// page and HasMore tells whether there is one.
+type SyncBatch struct {
+	Cursor     int64      `json:"cursor"`
+	HasMore    bool       `json:"has_more"`
+	Properties []Property `json:"properties"`
+	Deleted    []int64    `json:"deleted"`
+}
+
+// SyncStatus is the newest change cursor and the number of properties a full
+// download of the selected cities returns
+type SyncStatus struct {
+	Cursor     int64 `json:"cursor"`
+	Properties int   `json:"properties"`
+}
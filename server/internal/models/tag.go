@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// Tag is a custom label, such as "visited" or "needs renovation", applied to
+// properties. Names are unique regardless of case.
+type Tag struct {
+	ID            int64     `json:"id"`
+	Name          string    `json:"name"`
+	Color         string    `json:"color,omitempty"` // #rrggbb
+	PropertyCount int       `json:"property_count"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// TagRequest creates or replaces a tag
+type TagRequest struct {
+	Name  string `json:"name"`
+	Color string `json:"color"`
+}
+
+// PropertyTagsRequest adds and removes tags, by name, on properties in bulk
+type PropertyTagsRequest struct {
+	PropertyIDs []int64  `json:"property_ids"`
+	Add         []string `json:"add"`
+	Remove      []string `json:"remove"`
+}
+
+// PropertyTagsResult reports a bulk tag update: the number of properties
+// updated and the requested ids that do not exist
+type PropertyTagsResult struct {
+	Updated    int     `json:"updated"`
+	MissingIDs []int64 `json:"missing_ids"`
+}
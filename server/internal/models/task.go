@@ -0,0 +1,50 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Task statuses
+const (
+	TaskQueued    = "queued"
+	TaskRunning   = "running"
+	TaskSucceeded = "succeeded"
+	TaskFailed    = "failed"
+	TaskCanceled  = "canceled"
+)
+
+// Task is a persisted background task such as a geocoding run or a backup.
+// Progress goes from 0 to 1.
+type Task struct {
+	ID         int64           `json:"id"`
+	Kind       string          `json:"kind"`
+	Status     string          `json:"status"`
+	Params     json.RawMessage `json:"params,omitempty"`
+	Progress   float64         `json:"progress"`
+	Message    string          `json:"message,omitempty"`
+	Result     json.RawMessage `json:"result,omitempty"`
+	Error      string          `json:"error,omitempty"`
+	CreatedAt  time.Time       `json:"created_at"`
+	StartedAt  *time.Time      `json:"started_at,omitempty"`
+	FinishedAt *time.Time      `json:"finished_at,omitempty"`
+}
+
+// Finished reports whether the task has stopped for good
+func (t *Task) Finished() bool {
+	return t.Status == TaskSucceeded || t.Status == TaskFailed || t.Status == TaskCanceled
+}
+
+// TaskKind describes a kind of task that can be submitted. Admin kinds can
+// only be submitted with the admin token.
+type TaskKind struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Admin       bool   `json:"admin"`
+}
+
+// TaskRequest submits a task
+type TaskRequest struct {
+	Kind   string          `json:"kind"`
+	Params json.RawMessage `json:"params"`
+}
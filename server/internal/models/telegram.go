@@ -1,17 +1,17 @@
 package models
 
-import (
-	"time"
-
-	"github.com/sirupsen/logrus"
-)
+import "time"
 
 // TelegramConfig stores the bot credentials and basic settings
 type TelegramConfig struct {
-	ID        int64     `json:"id"`
-	IsEnabled bool      `json:"is_enabled"`
-	BotToken  string    `json:"bot_token"`
-	ChatID    string    `json:"chat_id"`
+	ID        int64  `json:"id"`
+	IsEnabled bool   `json:"is_enabled"`
+	BotToken  string `json:"bot_token"`
+	ChatID    string `json:"chat_id"`
+	// Number and currency format of the notifications; empty uses the
+	// default format.currency preference
+	Locale    string    `json:"locale"`
+	Currency  string    `json:"currency"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
@@ -21,9 +21,13 @@ type TelegramConfigRequest struct {
 	IsEnabled bool   `json:"is_enabled"`
 	BotToken  string `json:"bot_token"`
 	ChatID    string `json:"chat_id"`
+	Locale    string `json:"locale"`
+	Currency  string `json:"currency"`
 }
 
-// TelegramFilters stores the notification filter settings
+// TelegramFilters stores the notification filter settings: the fixed ranges
+// and lists of the settings form and rules on any field, which a property must
+// all meet
 type TelegramFilters struct {
 	MinPrice      *int     `json:"min_price"`
 	MaxPrice      *int     `json:"max_price"`
@@ -33,92 +37,59 @@ type TelegramFilters struct {
 	MaxRooms      *int     `json:"max_rooms"`
 	Districts     []string `json:"districts"`
 	EnergyLabels  []string `json:"energy_labels"`
+	Rules         *Rule    `json:"rules,omitempty"`
 }
 
-// IsPropertyAllowed checks if a property matches the filter criteria
-func (f *TelegramFilters) IsPropertyAllowed(property *Property) bool {
+// Rule returns the filters as one rule: a group of the conditions of the fixed
+// fields and the rules, or nil when nothing is filtered
+func (f *TelegramFilters) Rule() *Rule {
 	if f == nil {
-		return true // No filters means allow all
+		return nil
 	}
-
-	// Check price range
-	if f.MinPrice != nil && (property.Price < *f.MinPrice) {
-		return false
-	}
-	if f.MaxPrice != nil && (property.Price > *f.MaxPrice) {
-		return false
-	}
-
-	// Check living area range
-	if property.LivingArea != nil {
-		if f.MinLivingArea != nil && (*property.LivingArea < *f.MinLivingArea) {
-			logrus.WithFields(logrus.Fields{
-				"property_living_area": *property.LivingArea,
-				"min_living_area":      *f.MinLivingArea,
-				"postal_code":          property.PostalCode,
-			}).Debug("Property rejected due to living area below minimum")
-			return false
-		}
-		if f.MaxLivingArea != nil && (*property.LivingArea > *f.MaxLivingArea) {
-			logrus.WithFields(logrus.Fields{
-				"property_living_area": *property.LivingArea,
-				"max_living_area":      *f.MaxLivingArea,
-				"postal_code":          property.PostalCode,
-			}).Debug("Property rejected due to living area above maximum")
-			return false
+	var all []Rule
+	for _, bound := range []struct {
+		field, op string
+		value     *int
+	}{
+		{"price", "gte", f.MinPrice},
+		{"price", "lte", f.MaxPrice},
+		{"living_area", "gte", f.MinLivingArea},
+		{"living_area", "lte", f.MaxLivingArea},
+		{"num_rooms", "gte", f.MinRooms},
+		{"num_rooms", "lte", f.MaxRooms},
+	} {
+		if bound.value != nil {
+			all = append(all, Rule{Field: bound.field, Op: bound.op, Value: float64(*bound.value)})
 		}
-	} else if f.MinLivingArea != nil || f.MaxLivingArea != nil {
-		logrus.WithFields(logrus.Fields{
-			"postal_code":     property.PostalCode,
-			"min_living_area": f.MinLivingArea,
-			"max_living_area": f.MaxLivingArea,
-		}).Debug("Property rejected due to missing living area")
-		return false // Filter requires living area but property has none
 	}
-
-	// Check number of rooms
-	if property.NumRooms != nil {
-		if f.MinRooms != nil && (*property.NumRooms < *f.MinRooms) {
-			return false
+	for _, list := range []struct {
+		field  string
+		values []string
+	}{
+		{"district", f.Districts},
+		{"energy_label", f.EnergyLabels},
+	} {
+		if len(list.values) == 0 {
+			continue
 		}
-		if f.MaxRooms != nil && (*property.NumRooms > *f.MaxRooms) {
-			return false
+		values := make([]interface{}, len(list.values))
+		for i, v := range list.values {
+			values[i] = v
 		}
-	} else if f.MinRooms != nil || f.MaxRooms != nil {
-		return false // Filter requires rooms but property has none
+		all = append(all, Rule{Field: list.field, Op: "in", Value: values})
 	}
-
-	// Check district (postal code prefix)
-	if len(f.Districts) > 0 {
-		postalPrefix := property.PostalCode[:4] // First 4 digits of postal code
-		allowed := false
-		for _, district := range f.Districts {
-			if district == postalPrefix {
-				allowed = true
-				break
-			}
-		}
-		if !allowed {
-			return false
-		}
+	if f.Rules != nil {
+		all = append(all, *f.Rules)
 	}
-
-	// Check energy label
-	if len(f.EnergyLabels) > 0 {
-		if property.EnergyLabel == "" {
-			return false
-		}
-		allowed := false
-		for _, label := range f.EnergyLabels {
-			if label == property.EnergyLabel {
-				allowed = true
-				break
-			}
-		}
-		if !allowed {
-			return false
-		}
+	if len(all) == 0 {
+		return nil
 	}
+	return &Rule{All: all}
+}
 
-	return true
+// IsPropertyAllowed checks if a property matches the filter criteria. market
+// provides the district statistics of relative rule fields and may be nil, in
+// which case rules on them do not match.
+func (f *TelegramFilters) IsPropertyAllowed(property *Property, market MarketReference) bool {
+	return f.Rule().Matches(property, market)
 }
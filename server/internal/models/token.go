@@ -0,0 +1,56 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// APIToken is a read-only token for integrations such as dashboards. The
+// token itself is only returned once, when it is created.
+type APIToken struct {
+	ID         int64      `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`              // API path prefixes, e.g. "stats"; empty allows every read endpoint
+	Workspace  string     `json:"workspace,omitempty"` // slug of the workspace the token reads; empty for the main dataset
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// Active reports whether the token is neither revoked nor expired
+func (t *APIToken) Active(now time.Time) bool {
+	if t.RevokedAt != nil {
+		return false
+	}
+	return t.ExpiresAt == nil || now.Before(*t.ExpiresAt)
+}
+
+// Allows reports whether the token's scopes cover an API path such as /api/stats/scatter
+func (t *APIToken) Allows(path string) bool {
+	if len(t.Scopes) == 0 {
+		return true
+	}
+	for _, scope := range t.Scopes {
+		prefix := "/api/" + scope
+		if path == prefix || strings.HasPrefix(path, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// CreateAPITokenRequest is the payload for creating a read-only token
+type CreateAPITokenRequest struct {
+	Name          string   `json:"name" binding:"required"`
+	Scopes        []string `json:"scopes"`
+	Workspace     string   `json:"workspace"`       // omit for a token of the main dataset
+	ExpiresInDays *int     `json:"expires_in_days"` // omit for a token that does not expire
+}
+
+// CreatedAPIToken is returned once on creation and includes the secret token
+type CreatedAPIToken struct {
+	APIToken
+	Token string `json:"token"`
+}
@@ -0,0 +1,114 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Kinds of validation rules
+const (
+	ValidationRequired = "required" // the field is present, not empty and not 0
+	ValidationMin      = "min"      // the field is a number of at least Value
+	ValidationMax      = "max"      // the field is a number of at most Value
+	ValidationPattern  = "pattern"  // the field is text matching the regular expression Value
+)
+
+// ValidationFields are the fields of scraped and imported items validation
+// rules can check; NumericValidationFields are the ones min and max apply to
+var (
+	ValidationFields = []string{
+		"url", "street", "neighborhood", "property_type", "city", "postal_code",
+		"price", "year_built", "living_area", "num_rooms", "status",
+		"listing_date", "selling_date", "energy_label", "country", "currency",
+	}
+	NumericValidationFields = []string{"price", "year_built", "living_area", "num_rooms"}
+)
+
+// ValidationRule is a check, on top of the built-in ones, that scraped and
+// imported items must pass to be stored. Items failing a rule are quarantined
+// in the rejected items for review.
+type ValidationRule struct {
+	ID    int64  `json:"id"`
+	Field string `json:"field"`
+	Kind  string `json:"kind"`
+	// Bound of min and max, regular expression of pattern
+	Value string `json:"value,omitempty"`
+	// Reason recorded for failing items instead of the default one
+	Message   string    `json:"message,omitempty"`
+	Enabled   bool      `json:"enabled"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ValidationRuleRequest creates or replaces a validation rule. A rule is
+// enabled unless Enabled is false.
+type ValidationRuleRequest struct {
+	Field   string `json:"field"`
+	Kind    string `json:"kind"`
+	Value   string `json:"value"`
+	Message string `json:"message"`
+	Enabled *bool  `json:"enabled"`
+}
+
+// Validate checks that the rule tests a known field with a value of its kind
+func (r *ValidationRuleRequest) Validate() error {
+	if !slices.Contains(ValidationFields, r.Field) {
+		return fmt.Errorf("unknown field %q, must be one of %s", r.Field, strings.Join(ValidationFields, ", "))
+	}
+	switch r.Kind {
+	case ValidationRequired:
+		if r.Value != "" {
+			return fmt.Errorf("a required rule has no value")
+		}
+	case ValidationMin, ValidationMax:
+		if !slices.Contains(NumericValidationFields, r.Field) {
+			return fmt.Errorf("%s applies to the fields %s", r.Kind, strings.Join(NumericValidationFields, ", "))
+		}
+		if _, err := strconv.ParseFloat(r.Value, 64); err != nil {
+			return fmt.Errorf("the value of a %s rule must be a number", r.Kind)
+		}
+	case ValidationPattern:
+		if _, err := regexp.Compile(r.Value); err != nil || r.Value == "" {
+			return fmt.Errorf("the value of a pattern rule must be a regular expression")
+		}
+	default:
+		return fmt.Errorf("kind must be %s, %s, %s or %s", ValidationRequired, ValidationMin, ValidationMax, ValidationPattern)
+	}
+	return nil
+}
+
+// ApproveRequest corrects fields of a rejected item before it is stored
+type ApproveRequest struct {
+	Fields map[string]interface{} `json:"fields"`
+}
+
+// ApprovedItem is the property a rejected item was stored as
+type ApprovedItem struct {
+	PropertyID int64 `json:"property_id"`
+	New        bool  `json:"new"` // inserted rather than updating a stored listing
+}
+
+// DataQuality counts the properties with missing or implausible data and the
+// items held in quarantine
+type DataQuality struct {
+	Properties         int               `json:"properties"`
+	Outliers           int               `json:"outliers"` // implausible price or living area
+	MissingCoordinates int               `json:"missing_coordinates"`
+	MissingLivingArea  int               `json:"missing_living_area"`
+	MissingEnergyLabel int               `json:"missing_energy_label"`
+	Quarantine         QuarantineSummary `json:"quarantine"`
+}
+
+// QuarantineSummary counts the rejected items awaiting review
+type QuarantineSummary struct {
+	Total    int            `json:"total"`
+	LastDay  int            `json:"last_day"` // rejected in the past 24 hours
+	BySource map[string]int `json:"by_source"`
+	// Items failing each validation rule by id, and the built-in checks
+	// under "builtin"
+	ByRule map[string]int `json:"by_rule"`
+}
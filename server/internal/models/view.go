@@ -0,0 +1,24 @@
+package models
+
+// Column types of the database views
+const (
+	ViewInteger = "integer"
+	ViewReal    = "real"
+	ViewText    = "text"
+	ViewDate    = "date" // YYYY-MM-DD
+)
+
+// View describes one of the SQL views kept for external consumers. Its name,
+// columns and their meaning do not change with the tables underneath.
+type View struct {
+	Name        string       `json:"name"`
+	Description string       `json:"description"`
+	Columns     []ViewColumn `json:"columns"`
+}
+
+// ViewColumn is a column of a view
+type ViewColumn struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	Description string `json:"description"`
+}
@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// Analytical backends the properties can be exported to
+const (
+	AnalyticsDuckDB     = "duckdb"     // a DuckDB file written with the duckdb command-line tool
+	AnalyticsClickHouse = "clickhouse" // a MergeTree table loaded over HTTP
+)
+
+// AnalyticsExport is a run of the export of the properties to the analytical
+// backend
+type AnalyticsExport struct {
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at"`
+	Rows       int        `json:"rows"`
+	Error      string     `json:"error,omitempty"`
+}
+
+// AnalyticsStatus is the configured analytical backend, whether queries are
+// routed to it, and the last export since the server started
+type AnalyticsStatus struct {
+	Backend      string           `json:"backend"` // empty when disabled
+	Table        string           `json:"table,omitempty"`
+	RouteQueries bool             `json:"route_queries"`
+	LastExport   *AnalyticsExport `json:"last_export"`
+}
@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+// Watchlist is a named set of properties; a home sold within RadiusMeters of
+// one of them raises a watch alert
+type Watchlist struct {
+	ID           int64     `json:"id"`
+	Name         string    `json:"name"`
+	RadiusMeters int       `json:"radius_meters"`
+	PropertyIDs  []int64   `json:"property_ids"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// WatchlistRequest creates or replaces a watchlist
+type WatchlistRequest struct {
+	Name         string `json:"name"`
+	RadiusMeters int    `json:"radius_meters"`
+}
+
+// WatchAlert is a comparable home sold near a watched property. The implied
+// value is the sold price per m² of the comparable times the living area of
+// the watched property.
+type WatchAlert struct {
+	ID             int64     `json:"id"`
+	WatchlistID    int64     `json:"watchlist_id"`
+	WatchlistName  string    `json:"watchlist_name"`
+	Property       Property  `json:"property"`
+	Comparable     Property  `json:"comparable"`
+	DistanceMeters float64   `json:"distance_meters"`
+	ImpliedValue   *int      `json:"implied_value"`
+	ImpliedChange  *float64  `json:"implied_change_pct"` // implied value against the asking price
+	CreatedAt      time.Time `json:"created_at"`
+}
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// Workspace is an isolated dataset of a multi-tenant server, with its own
+// properties, saved searches, notification channels and schedules
+type Workspace struct {
+	Slug      string    `json:"slug"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// WorkspaceRequest is the payload for creating or renaming a workspace; the
+// slug cannot be changed
+type WorkspaceRequest struct {
+	Slug string `json:"slug"`
+	Name string `json:"name"`
+}
@@ -0,0 +1,485 @@
+// Package parquet writes flat tables in the Apache Parquet format. Every column
+// is optional and stored with plain encoding and no compression, which any
+// Parquet reader understands. Rows are buffered per row group, so memory use
+// is bounded by the row group size rather than the size of the table.
+package parquet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// magic starts and ends every Parquet file
+const magic = "PAR1"
+
+// DefaultRowGroupSize is the number of rows per row group when none is given
+const DefaultRowGroupSize = 10000
+
+// Type is the type of a column
+type Type int
+
+const (
+	Boolean   Type = iota // bool
+	Int64                 // int, int64 or *int
+	Double                // float64 or *float64
+	String                // string or *string, stored as UTF-8
+	Date                  // time.Time or *time.Time, stored as days since the Unix epoch
+	Timestamp             // time.Time or *time.Time, stored as milliseconds since the Unix epoch in UTC
+)
+
+// Physical types, converted types, encodings and the page type of the format
+const (
+	physicalBoolean   = 0
+	physicalInt32     = 1
+	physicalInt64     = 2
+	physicalDouble    = 5
+	physicalByteArray = 6
+
+	convertedUTF8            = 0
+	convertedDate            = 6
+	convertedTimestampMillis = 9
+
+	repetitionOptional = 1
+	encodingPlain      = 0
+	encodingRLE        = 3
+	codecUncompressed  = 0
+	pageTypeData       = 0
+)
+
+// Column is a named, typed column of a table
+type Column struct {
+	Name string
+	Type Type
+}
+
+// physical returns the physical and converted type of the column; a converted
+// type of -1 means none
+func (c Column) physical() (int32, int32) {
+	switch c.Type {
+	case Boolean:
+		return physicalBoolean, -1
+	case Int64:
+		return physicalInt64, -1
+	case Double:
+		return physicalDouble, -1
+	case String:
+		return physicalByteArray, convertedUTF8
+	case Date:
+		return physicalInt32, convertedDate
+	default:
+		return physicalInt64, convertedTimestampMillis
+	}
+}
+
+// columnBuffer holds the values of a column in the current row group
+type columnBuffer struct {
+	present []bool       // one entry per row, false for nulls
+	values  bytes.Buffer // plain-encoded values of the present rows
+	bools   []bool       // values of a Boolean column, bit-packed on flush
+}
+
+// columnChunk describes a column chunk written to the file
+type columnChunk struct {
+	offset    int64
+	size      int64
+	numValues int64
+}
+
+// rowGroup describes a row group written to the file
+type rowGroup struct {
+	chunks  []columnChunk
+	size    int64
+	numRows int64
+}
+
+// Writer writes a Parquet file to an io.Writer. Call Close to write the
+// buffered rows and the footer; the file is unreadable without it.
+type Writer struct {
+	w            io.Writer
+	offset       int64
+	columns      []Column
+	buffers      []columnBuffer
+	rowGroupSize int
+	rows         int
+	numRows      int64
+	rowGroups    []rowGroup
+	err          error
+}
+
+// NewWriter returns a writer of a table with the given columns that flushes a
+// row group every rowGroupSize rows, DefaultRowGroupSize when not positive
+func NewWriter(w io.Writer, columns []Column, rowGroupSize int) *Writer {
+	if rowGroupSize <= 0 {
+		rowGroupSize = DefaultRowGroupSize
+	}
+	return &Writer{
+		w:            w,
+		columns:      columns,
+		buffers:      make([]columnBuffer, len(columns)),
+		rowGroupSize: rowGroupSize,
+	}
+}
+
+// Write adds a row with one value per column, nil for null. See Type for the
+// Go types each column accepts; nil pointers and zero times are written as null.
+// A value of the wrong type fails the writer.
+func (w *Writer) Write(row []interface{}) error {
+	if w.err != nil {
+		return w.err
+	}
+	if w.offset == 0 {
+		w.write([]byte(magic))
+	}
+	if len(row) != len(w.columns) {
+		return fmt.Errorf("row has %d values for %d columns", len(row), len(w.columns))
+	}
+
+	for i, value := range row {
+		if err := w.buffers[i].add(w.columns[i], value); err != nil {
+			w.err = err
+			return err
+		}
+	}
+	w.rows++
+	if w.rows >= w.rowGroupSize {
+		w.flushRowGroup()
+	}
+	return w.err
+}
+
+// Close writes the buffered rows and the footer. It does not close the
+// underlying writer.
+func (w *Writer) Close() error {
+	if w.err != nil {
+		return w.err
+	}
+	if w.offset == 0 {
+		w.write([]byte(magic))
+	}
+	if w.rows > 0 {
+		w.flushRowGroup()
+	}
+
+	footer := w.fileMetaData()
+	w.write(footer)
+	var length [4]byte
+	binary.LittleEndian.PutUint32(length[:], uint32(len(footer)))
+	w.write(length[:])
+	w.write([]byte(magic))
+	return w.err
+}
+
+// write writes to the underlying writer, keeping the first error
+func (w *Writer) write(p []byte) {
+	if w.err != nil {
+		return
+	}
+	n, err := w.w.Write(p)
+	w.offset += int64(n)
+	w.err = err
+}
+
+// add appends a value to the buffer of a column
+func (b *columnBuffer) add(column Column, value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		b.present = append(b.present, false)
+		return nil
+	case *int:
+		if v == nil {
+			b.present = append(b.present, false)
+			return nil
+		}
+		value = *v
+	case *int64:
+		if v == nil {
+			b.present = append(b.present, false)
+			return nil
+		}
+		value = *v
+	case *float64:
+		if v == nil {
+			b.present = append(b.present, false)
+			return nil
+		}
+		value = *v
+	case *string:
+		if v == nil {
+			b.present = append(b.present, false)
+			return nil
+		}
+		value = *v
+	case *time.Time:
+		if v == nil {
+			b.present = append(b.present, false)
+			return nil
+		}
+		value = *v
+	}
+	if t, ok := value.(time.Time); ok && t.IsZero() {
+		b.present = append(b.present, false)
+		return nil
+	}
+
+	var ok bool
+	switch column.Type {
+	case Boolean:
+		var v bool
+		if v, ok = value.(bool); ok {
+			b.bools = append(b.bools, v)
+		}
+	case Int64:
+		var v int64
+		switch n := value.(type) {
+		case int:
+			v, ok = int64(n), true
+		case int64:
+			v, ok = n, true
+		}
+		if ok {
+			binary.Write(&b.values, binary.LittleEndian, v)
+		}
+	case Double:
+		var v float64
+		if v, ok = value.(float64); ok {
+			binary.Write(&b.values, binary.LittleEndian, math.Float64bits(v))
+		}
+	case String:
+		var v string
+		if v, ok = value.(string); ok {
+			binary.Write(&b.values, binary.LittleEndian, uint32(len(v)))
+			b.values.WriteString(v)
+		}
+	case Date:
+		var v time.Time
+		if v, ok = value.(time.Time); ok {
+			days := time.Date(v.Year(), v.Month(), v.Day(), 0, 0, 0, 0, time.UTC).Unix() / 86400
+			binary.Write(&b.values, binary.LittleEndian, int32(days))
+		}
+	case Timestamp:
+		var v time.Time
+		if v, ok = value.(time.Time); ok {
+			binary.Write(&b.values, binary.LittleEndian, v.UnixMilli())
+		}
+	}
+	if !ok {
+		return fmt.Errorf("invalid value %v (%T) for column %s", value, value, column.Name)
+	}
+	b.present = append(b.present, true)
+	return nil
+}
+
+// flushRowGroup writes the buffered rows as a row group with one data page
+// per column
+func (w *Writer) flushRowGroup() {
+	group := rowGroup{numRows: int64(w.rows)}
+	for i := range w.buffers {
+		b := &w.buffers[i]
+		if w.columns[i].Type == Boolean {
+			b.values.Write(packBits(b.bools))
+		}
+
+		levels := definitionLevels(b.present)
+		var body bytes.Buffer
+		binary.Write(&body, binary.LittleEndian, uint32(len(levels)))
+		body.Write(levels)
+		body.Write(b.values.Bytes())
+		header := pageHeader(w.rows, body.Len())
+
+		chunk := columnChunk{offset: w.offset, numValues: int64(w.rows)}
+		w.write(header)
+		w.write(body.Bytes())
+		chunk.size = w.offset - chunk.offset
+		group.chunks = append(group.chunks, chunk)
+		group.size += chunk.size
+
+		b.present = b.present[:0]
+		b.values.Reset()
+		b.bools = b.bools[:0]
+	}
+	w.rowGroups = append(w.rowGroups, group)
+	w.numRows += int64(w.rows)
+	w.rows = 0
+}
+
+// definitionLevels encodes whether each value is present as a single
+// bit-packed run of the RLE/bit-packing hybrid encoding with a bit width of 1
+func definitionLevels(present []bool) []byte {
+	packed := packBits(present)
+	header := binary.AppendUvarint(nil, uint64(len(packed))<<1|1)
+	return append(header, packed...)
+}
+
+// packBits packs bools into bytes, least significant bit first
+func packBits(bits []bool) []byte {
+	packed := make([]byte, (len(bits)+7)/8)
+	for i, bit := range bits {
+		if bit {
+			packed[i/8] |= 1 << uint(i%8)
+		}
+	}
+	return packed
+}
+
+// pageHeader returns the header of an uncompressed data page
+func pageHeader(numValues, size int) []byte {
+	var t thriftWriter
+	t.i32(1, pageTypeData)
+	t.i32(2, int32(size))
+	t.i32(3, int32(size))
+	t.structBegin(5)
+	t.i32(1, int32(numValues))
+	t.i32(2, encodingPlain)
+	t.i32(3, encodingRLE)
+	t.i32(4, encodingRLE)
+	t.structEnd()
+	t.stop()
+	return t.buf.Bytes()
+}
+
+// fileMetaData returns the footer describing the schema and row groups
+func (w *Writer) fileMetaData() []byte {
+	var t thriftWriter
+	t.i32(1, 1)
+
+	t.listBegin(2, thriftStruct, len(w.columns)+1)
+	t.elemBegin()
+	t.binary(4, "schema")
+	t.i32(5, int32(len(w.columns)))
+	t.structEnd()
+	for _, column := range w.columns {
+		physical, converted := column.physical()
+		t.elemBegin()
+		t.i32(1, physical)
+		t.i32(3, repetitionOptional)
+		t.binary(4, column.Name)
+		if converted >= 0 {
+			t.i32(6, converted)
+		}
+		t.structEnd()
+	}
+
+	t.i64(3, w.numRows)
+
+	t.listBegin(4, thriftStruct, len(w.rowGroups))
+	for _, group := range w.rowGroups {
+		t.elemBegin()
+		t.listBegin(1, thriftStruct, len(group.chunks))
+		for i, chunk := range group.chunks {
+			physical, _ := w.columns[i].physical()
+			t.elemBegin()
+			t.i64(2, chunk.offset)
+			t.structBegin(3)
+			t.i32(1, physical)
+			t.listBegin(2, thriftI32, 2)
+			t.varint(encodingPlain)
+			t.varint(encodingRLE)
+			t.listBegin(3, thriftBinary, 1)
+			t.bytes(w.columns[i].Name)
+			t.i32(4, codecUncompressed)
+			t.i64(5, chunk.numValues)
+			t.i64(6, chunk.size)
+			t.i64(7, chunk.size)
+			t.i64(9, chunk.offset)
+			t.structEnd()
+			t.structEnd()
+		}
+		t.i64(2, group.size)
+		t.i64(3, group.numRows)
+		t.structEnd()
+	}
+
+	t.binary(6, "fundamental")
+	t.stop()
+	return t.buf.Bytes()
+}
+
+// Type ids of the Thrift compact protocol
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// thriftWriter encodes the Thrift structures of the footer and page headers
+// with the compact protocol
+type thriftWriter struct {
+	buf     bytes.Buffer
+	lastID  int16
+	parents []int16
+}
+
+func (t *thriftWriter) fieldHeader(id int16, typ byte) {
+	if delta := id - t.lastID; delta > 0 && delta <= 15 {
+		t.buf.WriteByte(byte(delta)<<4 | typ)
+	} else {
+		t.buf.WriteByte(typ)
+		t.varint(int64(id))
+	}
+	t.lastID = id
+}
+
+// varint writes a zigzag-encoded variable-length integer
+func (t *thriftWriter) varint(v int64) {
+	t.buf.Write(binary.AppendUvarint(nil, uint64(v<<1^v>>63)))
+}
+
+func (t *thriftWriter) bytes(s string) {
+	t.buf.Write(binary.AppendUvarint(nil, uint64(len(s))))
+	t.buf.WriteString(s)
+}
+
+func (t *thriftWriter) i32(id int16, v int32) {
+	t.fieldHeader(id, thriftI32)
+	t.varint(int64(v))
+}
+
+func (t *thriftWriter) i64(id int16, v int64) {
+	t.fieldHeader(id, thriftI64)
+	t.varint(v)
+}
+
+func (t *thriftWriter) binary(id int16, s string) {
+	t.fieldHeader(id, thriftBinary)
+	t.bytes(s)
+}
+
+// listBegin starts a list field; write its elements with varint, bytes or
+// elemBegin
+func (t *thriftWriter) listBegin(id int16, elemType byte, size int) {
+	t.fieldHeader(id, thriftList)
+	if size < 15 {
+		t.buf.WriteByte(byte(size)<<4 | elemType)
+	} else {
+		t.buf.WriteByte(0xf0 | elemType)
+		t.buf.Write(binary.AppendUvarint(nil, uint64(size)))
+	}
+}
+
+// structBegin starts a struct field, elemBegin a struct list element; end both
+// with structEnd
+func (t *thriftWriter) structBegin(id int16) {
+	t.fieldHeader(id, thriftStruct)
+	t.elemBegin()
+}
+
+func (t *thriftWriter) elemBegin() {
+	t.parents = append(t.parents, t.lastID)
+	t.lastID = 0
+}
+
+func (t *thriftWriter) structEnd() {
+	t.stop()
+	t.lastID = t.parents[len(t.parents)-1]
+	t.parents = t.parents[:len(t.parents)-1]
+}
+
+func (t *thriftWriter) stop() {
+	t.buf.WriteByte(0)
+}
@@ -1,11 +1,19 @@
 package scheduler
 
 import (
+	"context"
+	"fmt"
 	"fundamental/server/config"
 	"fundamental/server/internal/database"
+	"fundamental/server/internal/errorsink"
 	"fundamental/server/internal/geometry"
+	"fundamental/server/internal/models"
 	"fundamental/server/internal/scraping"
+	"fundamental/server/internal/telegram"
+	"fundamental/server/internal/warehouse"
 	"os"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -35,6 +43,62 @@ func (j JobType) String() string {
 	}
 }
 
+// DefaultSchedules are the cron expressions of the scheduled jobs, in local
+// time, unless overridden with SetSchedule
+var DefaultSchedules = map[string]string{
+	"sold":             "0 0 * * *",
+	"district_hulls":   "30 0 * * *",
+	"expire_listings":  "45 0 * * *",
+	"archive":          "0 1 * * *",
+	"retention":        "15 1 * * *",
+	"analytics_export": "30 2 * * *",
+	"active":           "0 * * * *",
+	"refresh":          "0 */4 * * *",
+	"maintenance":      "0 3 * * *",
+	"integrity_check":  "30 3 * * 0",
+}
+
+// job is a job the scheduler runs on a cron schedule
+type job struct {
+	name        string
+	description string
+	schedule    *CronSchedule
+	enabled     bool
+	run         func(t time.Time) error
+	lastRun     *models.JobRun
+
+	// due optionally skips runs of the schedule at which the job has nothing
+	// to do
+	due func(t time.Time) bool
+}
+
+// maxScheduleScan bounds the runs of a schedule scanned for the next runs at
+// which a job is due
+const maxScheduleScan = 10000
+
+// runsAt reports whether the job runs at t
+func (j *job) runsAt(t time.Time) bool {
+	return j.enabled && j.schedule.Matches(t) && (j.due == nil || j.due(t))
+}
+
+// nextRuns returns the next n times after t at which the job runs
+func (j *job) nextRuns(t time.Time, n int) []time.Time {
+	runs := []time.Time{}
+	if !j.enabled {
+		return runs
+	}
+	for i := 0; len(runs) < n && i < maxScheduleScan; i++ {
+		t = j.schedule.Next(t)
+		if t.IsZero() {
+			break
+		}
+		if j.due == nil || j.due(t) {
+			runs = append(runs, t)
+		}
+	}
+	return runs
+}
+
 // Scheduler manages periodic execution of spiders
 type Scheduler struct {
 	spiderManager   *scraping.SpiderManager
@@ -46,10 +110,20 @@ type Scheduler struct {
 	jobMutex        sync.Mutex                // Ensures sequential job execution
 	isStartupRun    bool                      // Tracks whether we're in startup run
 	districtManager *geometry.DistrictManager // For updating district hulls
+	db              *database.Database
+	archiveDays     int                  // Archive inactive properties after this many days; 0 disables
+	expiryDays      int                  // Expire listings not seen for this many days; 0 disables
+	retention       map[string]int       // Maximum age in days by dataset
+	retentionDryRun bool                 // Only log what retention would delete
+	warehouse       *warehouse.Warehouse // Analytics backend the properties are exported to
+	vacuum          bool                 // Vacuum the database during maintenance
+	jobs            []*job               // in the order they run when due in the same minute
+	statusMu        sync.Mutex
+	telegramService *telegram.Service // For integrity alerts
 }
 
 // NewScheduler creates a new scheduler
-func NewScheduler(spiderManager *scraping.SpiderManager, db *database.Database, logger *logrus.Logger, cities []string) *Scheduler {
+func NewScheduler(spiderManager *scraping.SpiderManager, db *database.Database, outputDir string, logger *logrus.Logger, cities []string) *Scheduler {
 	if logger == nil {
 		logger = logrus.New()
 		logger.SetFormatter(&logrus.JSONFormatter{})
@@ -63,15 +137,183 @@ func NewScheduler(spiderManager *scraping.SpiderManager, db *database.Database,
 		normalizedMap[city] = config.NormalizeCity(city)
 	}
 
-	return &Scheduler{
+	s := &Scheduler{
 		spiderManager:   spiderManager,
 		logger:          logger,
 		stopChan:        make(chan struct{}),
 		cities:          cities,
 		normalizedMap:   normalizedMap,
 		isStartupRun:    true,
-		districtManager: geometry.NewDistrictManager(db.GetDB(), logger),
+		districtManager: geometry.NewDistrictManager(db, outputDir, logger),
+		db:              db,
+		telegramService: telegram.NewService(logger),
 	}
+	s.addJob("sold", "Run the sold spider for every city", true, func(time.Time) error {
+		return s.runSoldSpiders()
+	})
+	s.addJob("district_hulls", "Recompute the district boundaries", true, func(time.Time) error {
+		return s.districtManager.UpdateDistrictHulls()
+	})
+	s.addJob("expire_listings", "Mark listings no spider has seen for LISTING_EXPIRY_DAYS days as expired", false, func(time.Time) error {
+		expired, err := s.db.ExpireListings(s.expiryDays)
+		if err == nil {
+			s.logger.WithField("expired", expired).Info("Expired stale listings")
+		}
+		return err
+	})
+	s.addJob("archive", "Archive inactive properties that have not been updated for ARCHIVE_AFTER_DAYS days", false, func(time.Time) error {
+		archived, err := s.db.ArchiveStaleProperties(s.archiveDays)
+		if err == nil {
+			s.logger.WithField("archived", archived).Info("Archived stale properties")
+		}
+		return err
+	})
+	s.addJob("retention", "Delete rows older than the RETENTION_<DATASET>_DAYS rules", false, s.applyRetention)
+	s.addJob("analytics_export", "Export the properties to the ANALYTICS_BACKEND", false, func(time.Time) error {
+		_, err := s.warehouse.Export(context.Background())
+		return err
+	})
+	s.addJob("active", "Run the active spider for every city", true, func(time.Time) error {
+		return s.runActiveSpiders()
+	})
+	s.addJob("refresh", "Refresh the listings of the cities whose weekly slot is due", true, s.runRefreshSpiders)
+	s.job("refresh").due = func(t time.Time) bool {
+		return len(s.refreshCities(t)) > 0
+	}
+	s.addJob("maintenance", "Refresh the query planner statistics (PRAGMA optimize, ANALYZE)", true, func(time.Time) error {
+		return s.db.Optimize(s.vacuum)
+	})
+	s.addJob("integrity_check", "Check the SQLite database for corruption and alert via Telegram", db.Dialect().Name() == database.DriverSQLite, s.checkIntegrity)
+	return s
+}
+
+// EnableVacuum also vacuums the database during maintenance, which reclaims
+// the space of deleted rows but locks the database while it runs
+func (s *Scheduler) EnableVacuum(enabled bool) {
+	s.vacuum = enabled
+}
+
+// addJob registers a job with its default schedule
+func (s *Scheduler) addJob(name, description string, enabled bool, run func(t time.Time) error) {
+	schedule, err := ParseCron(DefaultSchedules[name])
+	if err != nil {
+		panic(err)
+	}
+	s.jobs = append(s.jobs, &job{
+		name:        name,
+		description: description,
+		schedule:    schedule,
+		enabled:     enabled,
+		run:         run,
+	})
+}
+
+// job returns the job with the given name, or nil
+func (s *Scheduler) job(name string) *job {
+	for _, j := range s.jobs {
+		if j.name == name {
+			return j
+		}
+	}
+	return nil
+}
+
+// SetSchedule replaces the cron expression of a job. It has to be called
+// before Start.
+func (s *Scheduler) SetSchedule(name, expr string) error {
+	j := s.job(name)
+	if j == nil {
+		return fmt.Errorf("unknown scheduled job %q", name)
+	}
+	schedule, err := ParseCron(expr)
+	if err != nil {
+		return err
+	}
+	j.schedule = schedule
+	return nil
+}
+
+// Jobs returns the scheduled jobs with their next n runs and last outcome
+func (s *Scheduler) Jobs(n int) []models.ScheduledJob {
+	now := time.Now()
+	s.statusMu.Lock()
+	defer s.statusMu.Unlock()
+
+	jobs := make([]models.ScheduledJob, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		job := models.ScheduledJob{
+			Name:        j.name,
+			Description: j.description,
+			Cron:        j.schedule.String(),
+			Enabled:     j.enabled,
+			NextRuns:    j.nextRuns(now, n),
+		}
+		if j.lastRun != nil {
+			run := *j.lastRun
+			job.LastRun = &run
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs
+}
+
+// EnableArchiving archives inactive properties that have not been updated for
+// the given number of days every night
+func (s *Scheduler) EnableArchiving(days int) {
+	s.archiveDays = days
+	s.job("archive").enabled = days > 0
+}
+
+// EnablePDOKCache sets how long the district hulls job uses cached PDOK
+// points and whether it uses them only, see DistrictManager.EnablePDOKCache
+func (s *Scheduler) EnablePDOKCache(ttl time.Duration, offline bool) {
+	s.districtManager.EnablePDOKCache(ttl, offline)
+}
+
+// EnableListingExpiry marks listings that no spider has seen for the given
+// number of days as expired every night
+func (s *Scheduler) EnableListingExpiry(days int) {
+	s.expiryDays = days
+	s.job("expire_listings").enabled = days > 0
+}
+
+// EnableRetention deletes the rows of each dataset older than its maximum
+// age in days every night, or with dryRun only logs how many it would delete
+func (s *Scheduler) EnableRetention(rules map[string]int, dryRun bool) {
+	s.retention = rules
+	s.retentionDryRun = dryRun
+	enabled := false
+	for _, days := range rules {
+		enabled = enabled || days > 0
+	}
+	s.job("retention").enabled = enabled
+}
+
+// EnableAnalyticsExport exports the properties to the analytics backend
+// every night; a nil warehouse leaves the job disabled
+func (s *Scheduler) EnableAnalyticsExport(w *warehouse.Warehouse) {
+	s.warehouse = w
+	s.job("analytics_export").enabled = w != nil
+}
+
+// applyRetention runs the retention rules and logs the outcome per dataset
+func (s *Scheduler) applyRetention(time.Time) error {
+	report, err := s.db.ApplyRetention(s.retention, s.retentionDryRun)
+	if err != nil {
+		return err
+	}
+	message := "Deleted expired rows"
+	if report.DryRun {
+		message = "Found expired rows (dry run)"
+	}
+	for _, rule := range report.Rules {
+		s.logger.WithFields(logrus.Fields{
+			"dataset":      rule.Dataset,
+			"max_age_days": rule.MaxAgeDays,
+			"rows":         rule.Rows,
+		}).Info(message)
+	}
+	return nil
 }
 
 // Start begins the scheduled tasks
@@ -83,13 +325,15 @@ func (s *Scheduler) Start() {
 // runScheduler handles all scheduled tasks
 func (s *Scheduler) runScheduler() {
 	defer s.wg.Done()
+	defer errorsink.Recover("scheduler")
 
 	// Run startup jobs in a separate goroutine
 	go func() {
+		defer errorsink.Recover("scheduler")
 		s.jobMutex.Lock()
 		defer s.jobMutex.Unlock()
 		s.logger.Info("Running startup spider jobs")
-		s.runActiveSpiders()
+		s.runJob(s.job("active"), time.Now())
 		s.isStartupRun = false // Mark startup as complete
 		s.logger.Info("Startup spider jobs completed")
 	}()
@@ -123,162 +367,201 @@ func (s *Scheduler) executeScheduledJobs(t time.Time) {
 		"minute": t.Minute(),
 	}).Debug("Checking scheduled jobs")
 
-	// Check if it's time for the sold spider (midnight)
-	if t.Hour() == 0 && t.Minute() == 0 {
-		s.logger.Info("Starting scheduled sold spider jobs")
-		s.runSoldSpiders()
-		s.logger.Info("Completed scheduled sold spider jobs")
-	}
-
-	// Check if it's time to update district hulls (00:30)
-	if t.Hour() == 0 && t.Minute() == 30 {
-		s.logger.Info("Starting scheduled district hull update")
-		if err := s.districtManager.UpdateDistrictHulls(); err != nil {
-			s.logger.WithError(err).Error("Failed to update district hulls")
-		} else {
-			s.logger.Info("Completed district hull update")
+	for _, j := range s.jobs {
+		if j.runsAt(t) {
+			s.runJob(j, t)
 		}
 	}
+}
 
-	// Check if it's time for the active spider (every hour)
-	if t.Minute() == 0 {
-		s.logger.Info("Starting scheduled active spider jobs")
-		s.runActiveSpiders()
-		s.logger.Info("Completed scheduled active spider jobs")
+// runJob runs a job and records its outcome
+func (s *Scheduler) runJob(j *job, t time.Time) {
+	run := &models.JobRun{StartedAt: time.Now(), Status: models.JobRunRunning}
+	s.statusMu.Lock()
+	j.lastRun = run
+	s.statusMu.Unlock()
+
+	logger := s.logger.WithField("job", j.name)
+	logger.Info("Starting scheduled job")
+	err := j.run(t)
+
+	finished := time.Now()
+	s.statusMu.Lock()
+	run.FinishedAt = &finished
+	if err != nil {
+		run.Status = models.JobRunFailed
+		run.Error = err.Error()
+	} else {
+		run.Status = models.JobRunSucceeded
 	}
+	s.statusMu.Unlock()
 
-	// Check refresh schedule
-	s.checkAndRunRefreshSpiders(t)
+	if err != nil {
+		logger.WithError(err).Error("Scheduled job failed")
+	} else {
+		logger.Info("Completed scheduled job")
+	}
 }
 
-// runActiveSpiders runs the active spider for all configured cities sequentially
-func (s *Scheduler) runActiveSpiders() {
-	s.logger.Info("Starting active spider run")
-	for _, city := range s.cities {
+// runSpiders runs a spider for all configured cities sequentially and
+// returns an error if it failed for any of them. Cities beyond the MaxCities
+// quota are skipped.
+func (s *Scheduler) runSpiders(jobType JobType, cities []string, run func(place string) error) error {
+	cities = s.withinCityQuota(jobType, cities)
+	var failed []string
+	for _, city := range cities {
 		normalized := s.normalizedMap[city]
 		s.logger.WithFields(logrus.Fields{
 			"city":            city,
 			"normalized_city": normalized,
-			"job_type":        JobTypeActive.String(),
+			"job_type":        jobType.String(),
 		}).Info("Starting spider job")
 
-		if err := s.spiderManager.RunActiveSpider(normalized, nil); err != nil {
+		if err := run(normalized); err != nil {
 			s.logger.WithError(err).WithFields(logrus.Fields{
 				"city":            city,
 				"normalized_city": normalized,
-				"job_type":        JobTypeActive.String(),
+				"job_type":        jobType.String(),
 			}).Error("Spider job failed")
+			failed = append(failed, city)
 		} else {
 			s.logger.WithFields(logrus.Fields{
 				"city":            city,
 				"normalized_city": normalized,
-				"job_type":        JobTypeActive.String(),
+				"job_type":        jobType.String(),
 			}).Info("Spider job completed successfully")
 		}
 	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%s spider failed for %d of %d cities: %s",
+			jobType, len(failed), len(cities), strings.Join(failed, ", "))
+	}
+	return nil
 }
 
-// runSoldSpiders runs the sold spider for all configured cities sequentially
-func (s *Scheduler) runSoldSpiders() {
-	s.logger.Info("Starting sold spider run")
-	for _, city := range s.cities {
-		normalized := s.normalizedMap[city]
-		s.logger.WithFields(logrus.Fields{
-			"city":            city,
-			"normalized_city": normalized,
-			"job_type":        JobTypeSold.String(),
-		}).Info("Starting spider job")
+// withinCityQuota leaves out of cities those beyond the MaxCities quota. The
+// quota is read on every run, so a change applies to the next run. When it
+// cannot be read all cities are kept.
+func (s *Scheduler) withinCityQuota(jobType JobType, cities []string) []string {
+	quotas, err := s.db.GetQuotas()
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to get city quota")
+		return cities
+	}
+	scraped, skipped := quotas.ScrapedCities(s.cities)
+	if len(skipped) == 0 {
+		return cities
+	}
 
-		if err := s.spiderManager.RunSoldSpider(normalized, nil); err != nil {
-			s.logger.WithError(err).WithFields(logrus.Fields{
-				"city":            city,
-				"normalized_city": normalized,
-				"job_type":        JobTypeSold.String(),
-			}).Error("Spider job failed")
-		} else {
-			s.logger.WithFields(logrus.Fields{
-				"city":            city,
-				"normalized_city": normalized,
-				"job_type":        JobTypeSold.String(),
-			}).Info("Spider job completed successfully")
+	allowed := make(map[string]bool, len(scraped))
+	for _, city := range scraped {
+		allowed[city] = true
+	}
+	var kept []string
+	for _, city := range cities {
+		if allowed[city] {
+			kept = append(kept, city)
 		}
 	}
+	s.logger.WithFields(logrus.Fields{
+		"job_type":   jobType.String(),
+		"max_cities": *quotas.MaxCities,
+		"skipped":    skipped,
+	}).Warn("City quota reached, skipping cities")
+	return kept
 }
 
-// checkAndRunRefreshSpiders checks and runs refresh spiders for the current time
-func (s *Scheduler) checkAndRunRefreshSpiders(t time.Time) {
-	if t.Minute() != 0 { // Only check on the hour
-		return
-	}
+// runActiveSpiders runs the active spider for all configured cities sequentially
+func (s *Scheduler) runActiveSpiders() error {
+	s.logger.Info("Starting active spider run")
+	return s.runSpiders(JobTypeActive, s.cities, func(place string) error {
+		return s.spiderManager.RunActiveSpider(place, nil)
+	})
+}
 
-	timeSlots := []int{0, 4, 8, 12, 16, 20}
-	daysOfWeek := []time.Weekday{
-		time.Sunday,
-		time.Monday,
-		time.Tuesday,
-		time.Wednesday,
-		time.Thursday,
-		time.Friday,
-		time.Saturday,
-	}
+// runSoldSpiders runs the sold spider for all configured cities sequentially
+func (s *Scheduler) runSoldSpiders() error {
+	s.logger.Info("Starting sold spider run")
+	return s.runSpiders(JobTypeSold, s.cities, func(place string) error {
+		return s.spiderManager.RunSoldSpider(place, nil)
+	})
+}
 
-	// Create schedule slots
-	type scheduleSlot struct {
-		day  time.Weekday
-		hour int
-	}
+// refreshSlot is a weekly run of the refresh job
+type refreshSlot struct {
+	day          time.Weekday
+	hour, minute int
+}
 
-	var schedule []scheduleSlot
-	// First fill all midnight slots
-	for _, day := range daysOfWeek {
-		schedule = append(schedule, scheduleSlot{day, timeSlots[0]})
-	}
-	// Then fill all 4am slots
-	for _, day := range daysOfWeek {
-		schedule = append(schedule, scheduleSlot{day, timeSlots[1]})
+// refreshSlots returns the weekly runs of the refresh schedule. Every city
+// gets its own slot: first the earliest time of day on each day of the week,
+// Sunday first, then the next time of day, and so on.
+func refreshSlots(schedule *CronSchedule) []refreshSlot {
+	// 1 January 2023 is a Sunday
+	start := time.Date(2023, time.January, 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 0, 7)
+
+	var slots []refreshSlot
+	for t := schedule.Next(start.Add(-time.Minute)); !t.IsZero() && t.Before(end); t = schedule.Next(t) {
+		slots = append(slots, refreshSlot{day: t.Weekday(), hour: t.Hour(), minute: t.Minute()})
 	}
-	// Continue for each time slot
-	for _, hour := range timeSlots[2:] {
-		for _, day := range daysOfWeek {
-			schedule = append(schedule, scheduleSlot{day, hour})
+	sort.SliceStable(slots, func(i, j int) bool {
+		a, b := slots[i], slots[j]
+		if a.hour != b.hour {
+			return a.hour < b.hour
 		}
-	}
+		if a.minute != b.minute {
+			return a.minute < b.minute
+		}
+		return a.day < b.day
+	})
+	return slots
+}
+
+// refreshCities returns the cities whose refresh slot is t. With the default
+// schedule every city is refreshed once a week, at midnight or at 4, 8, 12, 16
+// or 20 o'clock.
+func (s *Scheduler) refreshCities(t time.Time) []string {
+	slots := refreshSlots(s.job("refresh").schedule)
+	due := refreshSlot{day: t.Weekday(), hour: t.Hour(), minute: t.Minute()}
 
-	// Assign cities to schedule slots
-	citySchedule := make(map[string]scheduleSlot)
+	var cities []string
 	for i, city := range s.cities {
-		if i < len(schedule) {
-			citySchedule[city] = schedule[i]
+		if i < len(slots) && slots[i] == due {
+			cities = append(cities, city)
 		}
 	}
+	return cities
+}
 
-	// Check each city's schedule
-	for city, slot := range citySchedule {
-		if t.Weekday() == slot.day && t.Hour() == slot.hour {
-			normalized := s.normalizedMap[city]
-			s.logger.WithFields(logrus.Fields{
-				"city":            city,
-				"normalized_city": normalized,
-				"job_type":        JobTypeRefresh.String(),
-				"day":             slot.day,
-				"hour":            slot.hour,
-			}).Info("Starting spider job")
-
-			if err := s.spiderManager.RunRefreshSpider(normalized); err != nil {
-				s.logger.WithError(err).WithFields(logrus.Fields{
-					"city":            city,
-					"normalized_city": normalized,
-					"job_type":        JobTypeRefresh.String(),
-				}).Error("Spider job failed")
-			} else {
-				s.logger.WithFields(logrus.Fields{
-					"city":            city,
-					"normalized_city": normalized,
-					"job_type":        JobTypeRefresh.String(),
-				}).Info("Spider job completed successfully")
-			}
+// runRefreshSpiders runs the refresh spider for the cities whose slot is t
+func (s *Scheduler) runRefreshSpiders(t time.Time) error {
+	return s.runSpiders(JobTypeRefresh, s.refreshCities(t), s.spiderManager.RunRefreshSpider)
+}
+
+// checkIntegrity checks the database for corruption and sends a Telegram
+// alert when it finds any
+func (s *Scheduler) checkIntegrity(time.Time) error {
+	problems, err := s.db.CheckIntegrity()
+	if err != nil {
+		return err
+	}
+	if len(problems) == 0 {
+		s.logger.Info("Database integrity check passed")
+		return nil
+	}
+
+	s.logger.WithField("problems", problems).Error("Database integrity check found corruption")
+	config, err := s.db.GetTelegramConfig()
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to get Telegram config")
+	} else if config != nil {
+		s.telegramService.UpdateConfig(config)
+		if err := s.telegramService.NotifyDatabaseCorruption(problems); err != nil {
+			s.logger.WithError(err).Error("Failed to send database corruption alert")
 		}
 	}
+	return fmt.Errorf("database integrity check found %d problem(s)", len(problems))
 }
 
 // Stop gracefully stops the scheduler
@@ -0,0 +1,258 @@
+// Package scoring sends the features of newly scraped properties to an
+// external model and stores the scores it returns. Properties are scored in
+// the background, one at a time: a slow or failing model never holds up the
+// spiders, and properties it cannot score are left without scores.
+//
+// Each property is posted as JSON:
+//
+//	{"id": 123, "features": {"price": 450000, "living_area": 85, ...}}
+//
+// and the model answers with the scores by name:
+//
+//	{"scores": {"value": 0.82, "rent_yield": 4.1}}
+//
+// Score names are lowercase identifiers; the property list filters on them
+// with min_score[<name>] and max_score[<name>] and sorts on score.<name>.
+package scoring
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"fundamental/server/internal/database"
+	"fundamental/server/internal/errorsink"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// queueSize is the number of batches that may wait to be scored; batches
+	// arriving when the queue is full are not scored
+	queueSize = 16
+	// maxConsecutiveFailures is the number of properties in a row the model
+	// may fail to score before the rest of the batch is skipped
+	maxConsecutiveFailures = 3
+	// retryBackoff is the wait before the first retry, doubled for each next
+	retryBackoff = 500 * time.Millisecond
+)
+
+// featureKeys are the fields of a scraped property sent to the model
+var featureKeys = []string{
+	"url", "street", "neighborhood", "postal_code", "city", "country", "currency",
+	"property_type", "status", "price", "living_area", "num_rooms", "year_built",
+	"energy_label", "listing_date", "latitude", "longitude",
+}
+
+// request is the body posted for a property
+type request struct {
+	ID       int64                  `json:"id"`
+	Features map[string]interface{} `json:"features"`
+}
+
+// response is the answer of the model
+type response struct {
+	Scores map[string]float64 `json:"scores"`
+}
+
+// statusError is a response of the model other than 200 OK
+type statusError struct {
+	status int
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("scoring model answered %d %s", e.status, http.StatusText(e.status))
+}
+
+// Scorer posts properties to the scoring model and stores their scores
+type Scorer struct {
+	endpoint string
+	token    string
+	timeout  time.Duration
+	retries  int
+	client   *http.Client
+	store    database.ScoreStore
+	logger   *logrus.Logger
+
+	queueOnce sync.Once
+	queue     chan []request
+}
+
+// NewScorer creates a scorer for the model at endpoint, sending token as
+// bearer token when it is not empty. Each request may take timeout and is
+// retried up to retries times when the model cannot be reached, times out or
+// answers 429 or 5xx.
+func NewScorer(store database.ScoreStore, endpoint, token string, timeout time.Duration, retries int, logger *logrus.Logger) (*Scorer, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid scoring URL %q", endpoint)
+	}
+	if timeout <= 0 {
+		return nil, fmt.Errorf("scoring timeout must be positive")
+	}
+	return &Scorer{
+		endpoint: endpoint,
+		token:    token,
+		timeout:  timeout,
+		retries:  max(retries, 0),
+		client:   &http.Client{},
+		store:    store,
+		logger:   logger,
+	}, nil
+}
+
+// Enqueue queues newly inserted properties, as returned by InsertProperties,
+// to be scored in the background. Their features are copied, so the
+// properties may be changed afterwards. When the queue is full the batch is
+// dropped with a warning rather than blocking the caller. A nil scorer
+// ignores the properties.
+func (s *Scorer) Enqueue(properties []map[string]interface{}) {
+	if s == nil || len(properties) == 0 {
+		return
+	}
+	batch := make([]request, 0, len(properties))
+	for _, property := range properties {
+		id, ok := property["id"].(int64)
+		if !ok {
+			continue
+		}
+		features := make(map[string]interface{}, len(featureKeys))
+		for _, key := range featureKeys {
+			if value, ok := property[key]; ok {
+				features[key] = value
+			}
+		}
+		batch = append(batch, request{ID: id, Features: features})
+	}
+	if len(batch) == 0 {
+		return
+	}
+
+	s.queueOnce.Do(func() {
+		s.queue = make(chan []request, queueSize)
+		go s.run()
+	})
+	select {
+	case s.queue <- batch:
+	default:
+		s.logger.WithField("properties", len(batch)).Warn("Scoring queue is full, skipped scoring new properties")
+	}
+}
+
+// run scores the queued batches one at a time
+func (s *Scorer) run() {
+	defer errorsink.Recover("scoring")
+	for batch := range s.queue {
+		s.scoreBatch(batch)
+	}
+}
+
+// scoreBatch scores the properties of a batch, skipping the rest of the
+// batch when the model keeps failing
+func (s *Scorer) scoreBatch(batch []request) {
+	scored, failures := 0, 0
+	for i, req := range batch {
+		if failures >= maxConsecutiveFailures {
+			s.logger.WithFields(logrus.Fields{
+				"failures": failures,
+				"skipped":  len(batch) - i,
+			}).Warn("Scoring model keeps failing, skipped the rest of the batch")
+			break
+		}
+		if err := s.score(req); err != nil {
+			failures++
+			s.logger.WithError(err).WithField("property_id", req.ID).Warn("Failed to score property")
+			continue
+		}
+		failures = 0
+		scored++
+	}
+	s.logger.WithFields(logrus.Fields{
+		"properties": len(batch),
+		"scored":     scored,
+	}).Info("Scored new properties")
+}
+
+// score fetches the scores of a property and stores those with a valid name
+func (s *Scorer) score(req request) error {
+	scores, err := s.fetch(req)
+	if err != nil {
+		return err
+	}
+	for name := range scores {
+		if !database.ValidScoreName(name) {
+			s.logger.WithField("name", name).Warn("Ignored score with an invalid name")
+			delete(scores, name)
+		}
+	}
+	return s.store.SavePropertyScores(req.ID, scores)
+}
+
+// fetch posts a property to the model, retrying transient failures
+func (s *Scorer) fetch(req request) (map[string]float64, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode scoring request: %v", err)
+	}
+
+	backoff := retryBackoff
+	for attempt := 0; ; attempt++ {
+		scores, err := s.post(body)
+		if err == nil || attempt >= s.retries || !retryable(err) {
+			return scores, err
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// post sends one scoring request, bounded by the timeout
+func (s *Scorer) post(body []byte) (map[string]float64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.token)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+		return nil, &statusError{status: resp.StatusCode}
+	}
+
+	var result response
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode scoring response: %v", err)
+	}
+	if result.Scores == nil {
+		result.Scores = map[string]float64{}
+	}
+	return result.Scores, nil
+}
+
+// retryable reports whether a failed request may succeed when sent again:
+// the model could not be reached, timed out, or answered 429 or 5xx
+func retryable(err error) bool {
+	var status *statusError
+	if errors.As(err, &status) {
+		return status.status == http.StatusTooManyRequests || status.status >= 500
+	}
+	var urlErr *url.Error
+	return errors.As(err, &urlErr)
+}
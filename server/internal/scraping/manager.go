@@ -3,25 +3,64 @@ package scraping
 import (
 	"bufio"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"fundamental/server/config"
+	"fundamental/server/internal/country"
 	"fundamental/server/internal/database"
+	"fundamental/server/internal/errorsink"
+	"io"
+	"maps"
+	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
+	"sync"
+	"time"
 
 	"fundamental/server/internal/geocoding"
+	"fundamental/server/internal/live"
+	"fundamental/server/internal/models"
+	"fundamental/server/internal/scoring"
+	"fundamental/server/internal/staticmap"
+	"fundamental/server/internal/streetview"
 	"fundamental/server/internal/telegram"
+	"fundamental/server/internal/thumbnail"
 
 	"github.com/sirupsen/logrus"
 )
 
+// streetImagesPerRun bounds the street image lookups after each spider run
+const streetImagesPerRun = 200
+
+// Store is what the spider manager reads and writes: the scraped properties,
+// their scores, the Telegram settings of the notifications about them and the
+// records of the runs
+type Store interface {
+	database.PropertyStore
+	database.TelegramStore
+	database.ScoreStore
+	database.SpiderRunStore
+}
+
 // SpiderManager handles the execution of Scrapy spiders
 type SpiderManager struct {
 	logger          *logrus.Logger
 	scriptPath      string
-	db              *database.Database
+	db              Store
+	cfg             *config.Config
 	geocoder        *geocoding.Geocoder
+	streetImages    *streetview.Finder // nil when street images are disabled
+	thumbnails      *thumbnail.Cache   // nil when thumbnails are not cached
+	scorer          *scoring.Scorer    // nil when scoring is disabled
 	telegramService *telegram.Service
+	hub             *live.Hub // nil publishes to live.Default()
+
+	// recorder receives a copy of the spider output, see RecordTo
+	recorder io.Writer
+	// background counts the geocoding passes started after batches of items
+	background sync.WaitGroup
 }
 
 // SpiderParams contains parameters for running a spider
@@ -33,12 +72,12 @@ type SpiderParams struct {
 
 // SpiderMessage represents a message from the Python script
 type SpiderMessage struct {
-	Type string          `json:"type"` // "items", "complete", or "error"
+	Type string          `json:"type"` // "items", "progress", "result_count", "complete", or "error"
 	Data json.RawMessage `json:"data"`
 }
 
 // NewSpiderManager creates a new spider manager
-func NewSpiderManager(db *database.Database, logger *logrus.Logger) *SpiderManager {
+func NewSpiderManager(db Store, cfg *config.Config, logger *logrus.Logger) *SpiderManager {
 	if logger == nil {
 		logger = logrus.New()
 		logger.SetFormatter(&logrus.JSONFormatter{})
@@ -59,12 +98,45 @@ func NewSpiderManager(db *database.Database, logger *logrus.Logger) *SpiderManag
 	// Initialize telegram service
 	telegramService := telegram.NewService(logger)
 	telegramService.SetDatabase(db)
+	if cfg.TelegramStaticMaps {
+		telegramService.SetStaticMaps(staticmap.NewRenderer(cfg.StaticMapTileURL, cfg.StaticMapCacheDir, cfg.StaticMapZoom))
+	}
+	telegramService.SetBidAdvice(cfg.TelegramBidAdvice)
+	telegramService.SetListingPhotos(cfg.TelegramListingPhotos)
+
+	var thumbnails *thumbnail.Cache
+	if cfg.ThumbnailCacheDir != "" {
+		thumbnails = thumbnail.NewCache(cfg.ThumbnailCacheDir)
+	}
+
+	// Initialize street image lookups
+	var streetImages *streetview.Finder
+	if cfg.StreetImageProvider != "" {
+		streetImages, err = streetview.NewFinder(cfg.StreetImageProvider, cfg.StreetImageToken)
+		if err != nil {
+			logger.WithError(err).Error("Street images disabled")
+		}
+	}
+
+	// Initialize the scoring model
+	var scorer *scoring.Scorer
+	if cfg.ScoringURL != "" {
+		timeout := time.Duration(cfg.ScoringTimeoutSeconds) * time.Second
+		scorer, err = scoring.NewScorer(db, cfg.ScoringURL, cfg.ScoringToken, timeout, cfg.ScoringRetries, logger)
+		if err != nil {
+			logger.WithError(err).Error("Scoring disabled")
+		}
+	}
 
 	return &SpiderManager{
 		logger:          logger,
 		scriptPath:      absPath,
 		db:              db,
+		cfg:             cfg,
 		geocoder:        geocoder,
+		streetImages:    streetImages,
+		thumbnails:      thumbnails,
+		scorer:          scorer,
 		telegramService: telegramService,
 	}
 }
@@ -77,6 +149,115 @@ func (m *SpiderManager) RunSpider(params SpiderParams) error {
 		"place":       params.Place, // Already normalized by scheduler
 		"max_pages":   params.MaxPages,
 	}).Info("Starting spider")
+	return m.run(params, func(progress *live.SpiderProgress) error {
+		return m.scrape(params, progress)
+	})
+}
+
+// ReplaySpider runs a recorded spider output, as written by RecordTo,
+// through the same pipeline as the output of a live spider: validation,
+// storage, notifications and geocoding. It starts no spider and makes no
+// requests to Funda, so refactors of the ingestion can be checked offline.
+func (m *SpiderManager) ReplaySpider(params SpiderParams, fixture io.Reader) error {
+	m.logger.WithFields(logrus.Fields{
+		"spider_type": params.SpiderType,
+		"place":       params.Place,
+	}).Info("Replaying recorded spider output")
+	return m.run(params, func(progress *live.SpiderProgress) error {
+		touched := make(map[string]bool)
+		defer m.warmDistricts(touched)
+		pageSize, exhausted, err := m.ingest(fixture, progress, touched)
+		if err != nil {
+			return err
+		}
+		m.finish(progress, pageSize, exhausted)
+		return nil
+	})
+}
+
+// RecordTo copies the output of the spiders run from now on to w, one JSON
+// message per line, for ReplaySpider
+func (m *SpiderManager) RecordTo(w io.Writer) {
+	m.recorder = w
+}
+
+// SetGeocoder replaces the geocoder of new properties
+func (m *SpiderManager) SetGeocoder(geocoder *geocoding.Geocoder) {
+	m.geocoder = geocoder
+}
+
+// SetLiveHub publishes the progress and new properties of the spider runs to
+// hub instead of the default hub
+func (m *SpiderManager) SetLiveHub(hub *live.Hub) {
+	m.hub = hub
+}
+
+// liveHub returns the hub spider events are published to
+func (m *SpiderManager) liveHub() *live.Hub {
+	if m.hub != nil {
+		return m.hub
+	}
+	return live.Default()
+}
+
+// SetTelegramTransport sends the Telegram requests through rt
+func (m *SpiderManager) SetTelegramTransport(rt http.RoundTripper) {
+	m.telegramService.SetTransport(rt)
+}
+
+// Wait blocks until the geocoding of the stored items and their queued
+// Telegram notifications are done
+func (m *SpiderManager) Wait() {
+	m.background.Wait()
+	m.telegramService.Flush()
+}
+
+// Drain waits at most timeout for Wait, so a shutdown sends the queued
+// Telegram notifications without hanging on them. It reports whether
+// everything was done in time.
+func (m *SpiderManager) Drain(timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		m.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// run runs a spider with scrape, publishing its progress and recording the run
+func (m *SpiderManager) run(params SpiderParams, scrape func(progress *live.SpiderProgress) error) error {
+
+	// Identify this run so field provenance can be traced back to it
+	progress := live.SpiderProgress{
+		RunID:      fmt.Sprintf("%s-%s-%s", params.SpiderType, params.Place, time.Now().UTC().Format("20060102T150405")),
+		SpiderType: params.SpiderType,
+		Place:      params.Place,
+		MaxPages:   params.MaxPages,
+	}
+	startedAt := time.Now()
+	m.liveHub().PublishSpider(live.EventSpiderStarted, progress)
+	err := scrape(&progress)
+	if err != nil {
+		progress.Error = err.Error()
+	}
+	m.liveHub().PublishSpider(live.EventSpiderFinished, progress)
+	m.recordRun(progress, startedAt)
+	return err
+}
+
+// scrape runs the spider script and stores the items it sends, counting them
+// in progress. Once the spider completes, progress gets the coverage of the
+// results Funda reported.
+func (m *SpiderManager) scrape(params SpiderParams, progress *live.SpiderProgress) error {
+	// Districts whose properties the run stored; their medians are
+	// recomputed once it ends
+	touched := make(map[string]bool)
+	defer m.warmDistricts(touched)
 
 	// Prepare the command
 	cmd := exec.Command("python3", m.scriptPath)
@@ -118,8 +299,33 @@ func (m *SpiderManager) RunSpider(params SpiderParams) error {
 	}
 	stdin.Close()
 
+	var output io.Reader = combinedOutput
+	if m.recorder != nil {
+		output = io.TeeReader(combinedOutput, m.recorder)
+	}
+	pageSize, exhausted, err := m.ingest(output, progress, touched)
+	if err != nil {
+		return err
+	}
+
+	// Wait for the command to complete
+	if err := cmd.Wait(); err != nil {
+		return fmt.Errorf("spider failed: %v", err)
+	}
+
+	m.finish(progress, pageSize, exhausted)
+	return nil
+}
+
+// ingest stores the items of a spider's output and follows its progress,
+// counting them in progress and the districts they are in in touched. It
+// returns the page size of the results and whether the spider went through
+// all of them, for the coverage of the run.
+func (m *SpiderManager) ingest(output io.Reader, progress *live.SpiderProgress, touched map[string]bool) (pageSize int, exhausted bool, err error) {
+	runID := progress.RunID
+
 	// Read output
-	scanner := bufio.NewScanner(combinedOutput)
+	scanner := bufio.NewScanner(output)
 	buf := make([]byte, 0, 64*1024)
 	scanner.Buffer(buf, 1024*1024) // Increase buffer size to 1MB
 
@@ -134,51 +340,166 @@ func (m *SpiderManager) RunSpider(params SpiderParams) error {
 		if err := json.Unmarshal(line, &message); err == nil && message.Type != "" {
 			switch message.Type {
 			case "items":
-				// Process scraped items one by one
 				var items []map[string]interface{}
 				if err := json.Unmarshal(message.Data, &items); err != nil {
 					m.logger.WithError(err).Error("Failed to parse items data")
 					continue
 				}
 				m.logger.WithField("items_count", len(items)).Info("Received items from spider")
-
-				// Process each item individually
-				var newProperties []map[string]interface{}
+				progress.Items += len(items)
+
+				// Capture the raw payloads before InsertProperties adjusts the items
+				var snapshots [][]byte
+				if m.cfg.SnapshotsEnabled {
+					snapshots = make([][]byte, len(items))
+					for i, item := range items {
+						snapshots[i], _ = json.Marshal(item)
+					}
+				}
 				for _, item := range items {
-					processedItems, err := m.db.InsertProperties([]map[string]interface{}{item})
-					if err != nil {
-						m.logger.WithError(err).Error("Failed to store property")
+					item["_source"] = models.SourceSpider
+					item["_run_id"] = runID
+				}
+
+				newProperties, stored := m.storeItems(items)
+				rejected := 0
+				for i, item := range items {
+					if _, ok := item["_rejected"]; ok {
+						stored[i] = false
+						rejected++
+					}
+				}
+				if rejected > 0 {
+					m.logger.WithField("rejected", rejected).Warn("Rejected scraped items that failed validation, see /api/admin/rejected-items")
+				}
+				for i, ok := range stored {
+					if ok {
+						progress.Stored++
+						touched[itemDistrict(items[i])] = true
+					}
+				}
+				progress.New += len(newProperties)
+				progress.Rejected += rejected
+				m.liveHub().PublishSpider(live.EventSpiderProgress, *progress)
+
+				// Homes stored as sold for the first time
+				var soldIDs []int64
+				for i, item := range items {
+					if id, ok := item["_sold"].(int64); ok && stored[i] {
+						soldIDs = append(soldIDs, id)
+					}
+				}
+
+				for i, item := range items {
+					if snapshots == nil || !stored[i] {
 						continue
 					}
-					if len(processedItems) > 0 {
-						newProperties = append(newProperties, processedItems[0])
+					if url, ok := item["url"].(string); ok {
+						if err := m.db.SavePropertySnapshot(url, snapshots[i]); err != nil {
+							m.logger.WithError(err).Warn("Failed to store property snapshot")
+						}
 					}
 				}
 
+				// Push the new listings to the connected dashboards and
+				// queue them for the scoring model
+				m.liveHub().PublishProperties(newProperties)
+				m.scorer.Enqueue(newProperties)
+
 				// After processing all items, handle geocoding and notifications
 				if len(newProperties) > 0 {
-					// Trigger geocoding in a background goroutine
-					go func() {
-						m.logger.Info("Starting geocoding for newly inserted properties...")
-						if err := m.db.UpdateMissingCoordinates(m.geocoder); err != nil {
-							m.logger.WithError(err).Error("Failed to update coordinates for new properties")
-						}
-					}()
-
 					// Send notifications for new properties
 					config, err := m.db.GetTelegramConfig()
 					if err != nil {
 						m.logger.WithError(err).Error("Failed to get Telegram config")
 					} else if config != nil {
 						m.telegramService.UpdateConfig(config)
+						streetImages := m.cfg.TelegramStreetImages && m.streetImages != nil
 						for _, prop := range newProperties {
-							if err := m.telegramService.NotifyNewProperty(prop); err != nil {
-								m.logger.WithError(err).Error("Failed to send Telegram notification")
+							if config.IsEnabled && (m.cfg.TelegramStaticMaps || streetImages) {
+								m.addCoordinates(prop)
+							}
+							if config.IsEnabled && streetImages {
+								m.addStreetImage(prop)
 							}
+							if config.IsEnabled && m.cfg.TelegramListingPhotos {
+								m.addThumbnail(prop)
+							}
+						}
+						// Evaluated once the batch is complete, so the district
+						// analysis is loaded once per district
+						if err := m.telegramService.NotifyNewProperties(newProperties); err != nil {
+							m.logger.WithError(err).Error("Failed to queue Telegram notifications")
 						}
 					}
 				}
 
+				if len(newProperties) > 0 || len(soldIDs) > 0 {
+					// Trigger geocoding in a background goroutine; addresses
+					// geocoded for the notifications come from the geocoder's
+					// cache. Sold homes are matched against watched properties
+					// once they have coordinates.
+					m.background.Add(1)
+					go func() {
+						defer m.background.Done()
+						defer errorsink.Recover("geocoding")
+						m.logger.Info("Starting geocoding for newly inserted properties...")
+						if err := m.db.UpdateMissingCoordinates(m.geocoder); errors.Is(err, database.ErrGeocodingLeased) {
+							m.logger.WithError(err).Info("Skipped geocoding new properties")
+						} else if err != nil {
+							m.logger.WithError(err).Error("Failed to update coordinates for new properties")
+						}
+						for _, prop := range newProperties {
+							m.addThumbnail(prop)
+						}
+						m.notifyWatchComparables(soldIDs)
+						if m.streetImages != nil {
+							found, err := m.db.UpdateStreetImages(m.streetImages, streetImagesPerRun)
+							if err != nil {
+								m.logger.WithError(err).Warn("Failed to update street images")
+							} else {
+								m.logger.WithField("found", found).Info("Updated street images")
+							}
+						}
+					}()
+				}
+
+			case "progress":
+				var page struct {
+					Page     int `json:"page"`
+					Listings int `json:"listings"`
+				}
+				if err := json.Unmarshal(message.Data, &page); err != nil {
+					m.logger.WithError(err).Error("Failed to parse progress data")
+					continue
+				}
+				progress.Pages = max(progress.Pages, page.Page)
+				progress.Listings += page.Listings
+				m.liveHub().PublishSpider(live.EventSpiderProgress, *progress)
+
+			case "result_count":
+				var count struct {
+					Total    int `json:"total"`
+					PageSize int `json:"page_size"`
+				}
+				if err := json.Unmarshal(message.Data, &count); err != nil {
+					m.logger.WithError(err).Error("Failed to parse result count")
+					continue
+				}
+				progress.ResultCount = &count.Total
+				pageSize = count.PageSize
+				m.liveHub().PublishSpider(live.EventSpiderProgress, *progress)
+
+			case "complete":
+				var complete struct {
+					ResultsExhausted bool `json:"results_exhausted"`
+				}
+				if err := json.Unmarshal(message.Data, &complete); err != nil {
+					m.logger.WithError(err).Error("Failed to parse completion data")
+					continue
+				}
+				exhausted = complete.ResultsExhausted
+
 			case "error":
 				var errorData map[string]interface{}
 				if err := json.Unmarshal(message.Data, &errorData); err != nil {
@@ -216,15 +537,128 @@ func (m *SpiderManager) RunSpider(params SpiderParams) error {
 	}
 
 	if err := scanner.Err(); err != nil {
-		return fmt.Errorf("error reading spider output: %v", err)
+		return 0, false, fmt.Errorf("error reading spider output: %v", err)
 	}
+	return pageSize, exhausted, nil
+}
 
-	// Wait for the command to complete
-	if err := cmd.Wait(); err != nil {
-		return fmt.Errorf("spider failed: %v", err)
+// finish completes a run whose output was stored: progress gets the coverage
+// of the results Funda reported, and the snapshots are pruned
+func (m *SpiderManager) finish(progress *live.SpiderProgress, pageSize int, exhausted bool) {
+	if progress.ResultCount != nil {
+		progress.Coverage = scrapeCoverage(progress.Listings, progress.Pages, *progress.ResultCount, pageSize, exhausted)
 	}
 
-	return nil
+	// Enforce snapshot retention after each run
+	if m.cfg.SnapshotsEnabled {
+		deleted, err := m.db.PruneSnapshots(m.cfg.SnapshotRetentionDays, m.cfg.SnapshotMaxPerProperty)
+		if err != nil {
+			m.logger.WithError(err).Warn("Failed to prune property snapshots")
+		} else if deleted > 0 {
+			m.logger.WithField("deleted", deleted).Info("Pruned property snapshots")
+		}
+	}
+}
+
+// scrapeCoverage returns the share of Funda's results a run went through:
+// the listings found on the result pages against the count Funda reported.
+// A run that stopped early, on known listings or at max_pages, is held
+// against the results that fit on the pages it scraped. Returns nil when
+// Funda reported no results.
+func scrapeCoverage(listings, pages, resultCount, pageSize int, exhausted bool) *float64 {
+	expected := resultCount
+	if !exhausted && pageSize > 0 {
+		expected = min(expected, pages*pageSize)
+	}
+	if expected <= 0 {
+		return nil
+	}
+	// Listings moving between pages during the run can be counted twice
+	coverage := min(float64(listings)/float64(expected), 1)
+	return &coverage
+}
+
+// recordRun stores the record of a finished run and alerts when it covered
+// less of Funda's results than configured
+func (m *SpiderManager) recordRun(progress live.SpiderProgress, startedAt time.Time) {
+	finishedAt := time.Now()
+	run := models.SpiderRun{
+		RunID:       progress.RunID,
+		SpiderType:  progress.SpiderType,
+		Place:       progress.Place,
+		StartedAt:   startedAt,
+		FinishedAt:  &finishedAt,
+		Pages:       progress.Pages,
+		Listings:    progress.Listings,
+		Items:       progress.Items,
+		Stored:      progress.Stored,
+		New:         progress.New,
+		Rejected:    progress.Rejected,
+		ResultCount: progress.ResultCount,
+		Coverage:    progress.Coverage,
+		Error:       progress.Error,
+	}
+	if err := m.db.SaveSpiderRun(run); err != nil {
+		m.logger.WithError(err).Warn("Failed to record spider run")
+	}
+
+	if run.Coverage == nil || *run.Coverage >= m.cfg.ScrapeCoverageAlert {
+		return
+	}
+	m.logger.WithFields(logrus.Fields{
+		"spider_type":  run.SpiderType,
+		"place":        run.Place,
+		"listings":     run.Listings,
+		"result_count": *run.ResultCount,
+		"coverage":     *run.Coverage,
+	}).Warn("Spider run covered few of Funda's results, the parser or the pagination may be broken")
+
+	config, err := m.db.GetTelegramConfig()
+	if err != nil {
+		m.logger.WithError(err).Error("Failed to get Telegram config")
+		return
+	}
+	if config == nil {
+		return
+	}
+	m.telegramService.UpdateConfig(config)
+	if err := m.telegramService.NotifyLowScrapeCoverage(run, m.cfg.ScrapeCoverageAlert); err != nil {
+		m.logger.WithError(err).Error("Failed to send scrape coverage alert")
+	}
+}
+
+// storeItems writes a batch of scraped items and returns the newly inserted
+// ones. When the batch fails the items are written one by one, so a single bad
+// item does not lose the others; stored reports which items were written.
+func (m *SpiderManager) storeItems(items []map[string]interface{}) (newProperties []map[string]interface{}, stored []bool) {
+	stored = make([]bool, len(items))
+	// InsertProperties adjusts the items it writes, so the batch works on
+	// copies that replace the items only once it succeeds
+	batch := make([]map[string]interface{}, len(items))
+	for i, item := range items {
+		batch[i] = maps.Clone(item)
+	}
+	newProperties, err := m.db.InsertProperties(batch)
+	if err == nil {
+		copy(items, batch)
+		for i := range stored {
+			stored[i] = true
+		}
+		return newProperties, stored
+	}
+
+	m.logger.WithError(err).Warn("Failed to store items, retrying one by one")
+	newProperties = nil
+	for i, item := range items {
+		inserted, err := m.db.InsertProperties([]map[string]interface{}{item})
+		if err != nil {
+			m.logger.WithError(err).Error("Failed to store property")
+			continue
+		}
+		stored[i] = true
+		newProperties = append(newProperties, inserted...)
+	}
+	return newProperties, stored
 }
 
 // RunActiveSpider runs the active listings spider
@@ -277,3 +711,117 @@ func (m *SpiderManager) runSpider(params SpiderParams) error {
 	// ... rest of the function
 	return nil
 }
+
+// addCoordinates geocodes a new property that has no coordinates yet, so its
+// notification can include a map image
+func (m *SpiderManager) addCoordinates(property map[string]interface{}) {
+	if _, ok := property["latitude"].(float64); ok {
+		return
+	}
+	street, _ := property["street"].(string)
+	postalCode, _ := property["postal_code"].(string)
+	city, _ := property["city"].(string)
+	if street == "" || city == "" {
+		return
+	}
+	lat, lng, err := m.geocoder.GeocodeAddress(street, postalCode, city)
+	if err != nil {
+		m.logger.WithError(err).Warn("Failed to geocode property for notification")
+		return
+	}
+	property["latitude"] = lat
+	property["longitude"] = lng
+}
+
+// addStreetImage looks up the street-level photo nearest to a new property for
+// its notification and stores it, so the enrichment run does not look it up
+// again
+func (m *SpiderManager) addStreetImage(property map[string]interface{}) {
+	lat, latOK := property["latitude"].(float64)
+	lng, lngOK := property["longitude"].(float64)
+	if !latOK || !lngOK {
+		return
+	}
+	image, err := m.streetImages.Nearest(lat, lng)
+	if err != nil {
+		m.logger.WithError(err).Warn("Failed to look up street image for notification")
+		return
+	}
+	if image != nil {
+		property["street_image_url"] = image.URL
+		property["street_image_link"] = image.Link
+	}
+	if url, ok := property["url"].(string); ok {
+		if err := m.db.SetStreetImage(url, image); err != nil {
+			m.logger.WithError(err).Warn("Failed to store street image")
+		}
+	}
+}
+
+// addThumbnail caches the thumbnail of a new property's primary photo, once
+func (m *SpiderManager) addThumbnail(property map[string]interface{}) {
+	id, ok := property["id"].(int64)
+	if m.thumbnails == nil || !ok {
+		return
+	}
+	if _, done := property["thumbnail_path"]; done {
+		return
+	}
+	path, err := m.db.CacheThumbnail(m.thumbnails, id)
+	if err != nil {
+		m.logger.WithError(err).Warn("Failed to cache listing thumbnail")
+	}
+	property["thumbnail_path"] = path
+}
+
+// itemDistrict returns the district of a stored item, from its country and
+// postal code
+func itemDistrict(item map[string]interface{}) string {
+	code, _ := item["country"].(string)
+	postalCode, _ := item["postal_code"].(string)
+	return country.Get(code).District(postalCode)
+}
+
+// warmDistricts recomputes the cached medians of the districts a run touched,
+// so the notifications of the next run, and of other runs, find them ready
+func (m *SpiderManager) warmDistricts(touched map[string]bool) {
+	delete(touched, "")
+	if len(touched) == 0 {
+		return
+	}
+	districts := slices.Sorted(maps.Keys(touched))
+	if err := m.db.WarmDistrictAnalysis(districts); err != nil {
+		m.logger.WithError(err).Warn("Failed to warm the district analysis")
+		return
+	}
+	m.logger.WithField("districts", len(districts)).Info("Warmed the district analysis")
+}
+
+// notifyWatchComparables raises watch alerts for homes that sold near watched
+// properties and sends them to Telegram
+func (m *SpiderManager) notifyWatchComparables(soldIDs []int64) {
+	alerts, err := m.db.MatchWatchComparables(soldIDs)
+	if err != nil {
+		m.logger.WithError(err).Error("Failed to match sold homes against watchlists")
+		return
+	}
+	if len(alerts) == 0 {
+		return
+	}
+	m.logger.WithField("alerts", len(alerts)).Info("Homes sold near watched properties")
+
+	config, err := m.db.GetTelegramConfig()
+	if err != nil {
+		m.logger.WithError(err).Error("Failed to get Telegram config")
+		return
+	}
+	if config == nil {
+		return
+	}
+	m.telegramService.UpdateConfig(config)
+	for _, alert := range alerts {
+		if err := m.telegramService.NotifyWatchComparable(alert); err != nil {
+			m.logger.WithError(err).Error("Failed to send watch alert")
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package selfcheck
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"fundamental/server/internal/database"
+	"fundamental/server/internal/models"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// pythonTimeout bounds the time spent probing the Python environment
+const pythonTimeout = 15 * time.Second
+
+// databaseDirectory names the directory check of the SQLite database
+const databaseDirectory = "database"
+
+// Options describes the environment the server depends on
+type Options struct {
+	// Directories maps a description to a directory that must be writable
+	Directories map[string]string
+	// Scripts lists the helper scripts that must exist
+	Scripts []string
+	// PythonModules lists the modules the helper scripts import
+	PythonModules []string
+}
+
+// DefaultOptions returns the directories and prerequisites used by the server.
+// dbDir is the directory holding the SQLite database, empty for other backends,
+// geocodeCacheDir the geocoding cache and outputDir the directory generated
+// client files are written to.
+func DefaultOptions(dbDir, geocodeCacheDir, outputDir string) Options {
+	opts := Options{
+		Directories: map[string]string{
+			"geocode cache": geocodeCacheDir,
+			"output":        outputDir,
+		},
+		Scripts: []string{
+			filepath.Join("scripts", "run_spider.py"),
+			filepath.Join("scripts", "generate_hulls.py"),
+		},
+		PythonModules: []string{"scrapy", "dateutil", "numpy", "shapely"},
+	}
+	if dbDir != "" {
+		opts.Directories[databaseDirectory] = dbDir
+	}
+	return opts
+}
+
+// Run validates the database schema, directory permissions and external tools
+// and returns a report of every check
+func Run(db *database.Database, opts Options) *models.SelfCheckReport {
+	report := &models.SelfCheckReport{OK: true}
+
+	problems, err := db.ValidateSchema()
+	if err != nil {
+		report.Add("schema", "database schema", err)
+	} else if len(problems) > 0 {
+		report.Add("schema", "database schema", errors.New(strings.Join(problems, "; ")))
+	} else {
+		report.Add("schema", fmt.Sprintf("database schema (version %d)", database.SchemaVersion), nil)
+	}
+
+	names := make([]string, 0, len(opts.Directories))
+	for name := range opts.Directories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		dir := opts.Directories[name]
+		report.Add("directory", fmt.Sprintf("%s (%s)", name, dir), checkWritable(dir))
+	}
+
+	for _, script := range opts.Scripts {
+		_, err := os.Stat(script)
+		report.Add("prerequisite", script, err)
+	}
+
+	python, err := exec.LookPath("python3")
+	report.Add("prerequisite", "python3", err)
+	if err == nil && len(opts.PythonModules) > 0 {
+		report.Add("prerequisite", "python modules", checkPythonModules(python, opts.PythonModules))
+	}
+
+	return report
+}
+
+// Fatal reports whether a failed check keeps the server from starting. Only
+// the schema and the database directory do; missing spider and hull
+// prerequisites or another unwritable directory disable the features that
+// need them, which the server can run without.
+func Fatal(result models.SelfCheckResult) bool {
+	if result.Status != models.CheckFailed {
+		return false
+	}
+	switch result.Category {
+	case "schema":
+		return true
+	case "directory":
+		return strings.HasPrefix(result.Name, databaseDirectory+" (")
+	}
+	return false
+}
+
+// checkWritable creates the directory if needed and verifies a file can be written to it
+func checkWritable(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("cannot create directory: %v", err)
+	}
+	file, err := os.CreateTemp(dir, ".selfcheck-*")
+	if err != nil {
+		return fmt.Errorf("directory is not writable: %v", err)
+	}
+	name := file.Name()
+	file.Close()
+	return os.Remove(name)
+}
+
+// checkPythonModules reports the modules that cannot be imported by python
+func checkPythonModules(python string, modules []string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), pythonTimeout)
+	defer cancel()
+
+	script := "import importlib.util, sys; print(' '.join(m for m in sys.argv[1:] if importlib.util.find_spec(m) is None))"
+	args := append([]string{"-c", script}, modules...)
+	output, err := exec.CommandContext(ctx, python, args...).Output()
+	if err != nil {
+		return fmt.Errorf("failed to inspect python modules: %v", err)
+	}
+	if missing := strings.TrimSpace(string(output)); missing != "" {
+		return fmt.Errorf("missing python modules: %s", missing)
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+// Package smoketest replays recorded spider output through the ingestion
+// pipeline, from validation to the Telegram notifications, with stubs in place
+// of Nominatim and the Telegram API, and reports what came out, so changes to
+// the ingestion can be checked against a known result without network access.
+package smoketest
+
+import (
+	"fmt"
+	"fundamental/server/config"
+	"fundamental/server/internal/database"
+	"fundamental/server/internal/geocoding"
+	"fundamental/server/internal/models"
+	"fundamental/server/internal/scraping"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/sirupsen/logrus"
+)
+
+// stubBotToken and stubChatID are the Telegram settings of a replay
+const (
+	stubBotToken = "smoke-test"
+	stubChatID   = "smoke-test"
+)
+
+// Report is the outcome of a replay, compared with the golden report of the
+// fixture
+type Report struct {
+	// Counts of the spider run
+	Items    int `json:"items"`
+	Stored   int `json:"stored"`
+	New      int `json:"new"`
+	Rejected int `json:"rejected"`
+	// Share of the result count in the fixture the listings covered
+	Coverage *float64 `json:"coverage"`
+
+	// Properties in the database after the replay and how many of them the
+	// geocoding stub located
+	Properties int `json:"properties"`
+	Geocoded   int `json:"geocoded"`
+
+	// Telegram messages sent, by API method
+	Telegram map[string]int `json:"telegram"`
+
+	// Requests to hosts without a stub, which a replay must not make
+	Blocked []string `json:"blocked"`
+}
+
+// Options describe a replay
+type Options struct {
+	Params scraping.SpiderParams
+	// Database file the items are stored in; empty uses a new database that
+	// is removed afterwards
+	DatabasePath string
+}
+
+// Replay runs the spider output recorded in fixture through the ingestion
+// pipeline and reports the outcome
+func Replay(cfg *config.Config, fixture io.Reader, opts Options, logger *logrus.Logger) (*Report, error) {
+	workDir, err := os.MkdirTemp("", "fundamental-smoke-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create working directory: %v", err)
+	}
+	defer os.RemoveAll(workDir)
+
+	dbPath := opts.DatabasePath
+	if dbPath == "" {
+		dbPath = filepath.Join(workDir, "smoke.db")
+	}
+	cfg.DatabaseDriver = database.DriverSQLite
+	db, err := database.OpenFromConfig(cfg, dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %v", err)
+	}
+	defer db.Close()
+	if err := db.RunMigrations(); err != nil {
+		return nil, fmt.Errorf("failed to run migrations: %v", err)
+	}
+
+	// Notify every new listing that passes the filters
+	err = db.UpdateTelegramConfig(&models.TelegramConfigRequest{IsEnabled: true, BotToken: stubBotToken, ChatID: stubChatID})
+	if err != nil {
+		return nil, err
+	}
+
+	// Leave out the enrichments that need services without a stub
+	cfg.TelegramStaticMaps = false
+	cfg.StreetImageProvider = ""
+	cfg.ThumbnailCacheDir = ""
+	cfg.ScoringURL = ""
+
+	stub := newTransport()
+	geocoder := geocoding.NewGeocoder(logger, filepath.Join(workDir, "geocode_cache"))
+	geocoder.SetTransport(stub)
+
+	manager := scraping.NewSpiderManager(db, cfg, logger)
+	manager.SetGeocoder(geocoder)
+	manager.SetTelegramTransport(stub)
+	if err := manager.ReplaySpider(opts.Params, fixture); err != nil {
+		return nil, fmt.Errorf("replay failed: %v", err)
+	}
+	manager.Wait()
+
+	runs, err := db.ListSpiderRuns(opts.Params.Place, opts.Params.SpiderType, 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(runs) == 0 {
+		return nil, fmt.Errorf("the replay was not recorded as a spider run")
+	}
+	run := runs[0]
+
+	report := &Report{
+		Items:    run.Items,
+		Stored:   run.Stored,
+		New:      run.New,
+		Rejected: run.Rejected,
+		Coverage: run.Coverage,
+		Telegram: stub.telegramCalls(),
+		Blocked:  stub.blockedHosts(),
+	}
+	err = db.GetDB().QueryRow("SELECT COUNT(*), COUNT(latitude) FROM properties").Scan(&report.Properties, &report.Geocoded)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count properties: %v", err)
+	}
+	sort.Strings(report.Blocked)
+	return report, nil
+}
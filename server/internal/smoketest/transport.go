@@ -0,0 +1,85 @@
+package smoketest
+
+import (
+	"fmt"
+	"hash/fnv"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+)
+
+// transport answers the requests of a replay: Nominatim searches with
+// coordinates derived from the address, and Telegram API calls with success.
+// Requests to other hosts fail and are recorded.
+type transport struct {
+	mu       sync.Mutex
+	telegram map[string]int
+	blocked  map[string]bool
+}
+
+func newTransport() *transport {
+	return &transport{telegram: make(map[string]int), blocked: make(map[string]bool)}
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+	switch req.URL.Host {
+	case "nominatim.openstreetmap.org":
+		lat, lng := stubCoordinates(req.URL.Query().Get("q"))
+		return response(req, fmt.Sprintf(`[{"lat": "%.6f", "lon": "%.6f"}]`, lat, lng)), nil
+	case "api.telegram.org":
+		t.mu.Lock()
+		t.telegram[path.Base(req.URL.Path)]++
+		t.mu.Unlock()
+		return response(req, `{"ok": true, "result": {}}`), nil
+	}
+	t.mu.Lock()
+	t.blocked[req.URL.Host] = true
+	t.mu.Unlock()
+	return nil, fmt.Errorf("smoke test: no stub for %s", req.URL.Host)
+}
+
+// stubCoordinates places an address in Amsterdam, at the same place every
+// replay
+func stubCoordinates(address string) (float64, float64) {
+	h := fnv.New32a()
+	h.Write([]byte(address))
+	sum := h.Sum32()
+	return 52.30 + float64(sum%1000)/10000, 4.80 + float64(sum/1000%1000)/5000
+}
+
+// response is a successful JSON response to req
+func response(req *http.Request, body string) *http.Response {
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(body)),
+		Request:    req,
+	}
+}
+
+// telegramCalls returns the number of Telegram API calls by method
+func (t *transport) telegramCalls() map[string]int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	calls := make(map[string]int, len(t.telegram))
+	for method, count := range t.telegram {
+		calls[method] = count
+	}
+	return calls
+}
+
+// blockedHosts returns the hosts requests were refused to
+func (t *transport) blockedHosts() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	hosts := []string{}
+	for host := range t.blocked {
+		hosts = append(hosts, host)
+	}
+	return hosts
+}
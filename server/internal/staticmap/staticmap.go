@@ -0,0 +1,178 @@
+package staticmap
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/jpeg" // Tile servers may serve JPEG tiles
+	"image/png"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Attribution must accompany every image rendered from OpenStreetMap tiles
+const Attribution = "© OpenStreetMap contributors"
+
+// AttributionURL is the page the attribution links to
+const AttributionURL = "https://www.openstreetmap.org/copyright"
+
+const (
+	tileSize   = 256
+	width      = 600
+	height     = 400
+	tileMaxAge = 7 * 24 * time.Hour // OSM tile usage policy asks for a week at least
+	markerSize = 9
+)
+
+// Renderer renders map images around a point from a tile server. Tiles and
+// rendered images are cached on disk, so a listing or neighbourhood that is
+// notified twice does not hit the tile server again.
+type Renderer struct {
+	tileURL  string
+	cacheDir string
+	zoom     int
+	client   *http.Client
+	mu       sync.Mutex
+}
+
+// NewRenderer creates a renderer. tileURL contains {z}, {x} and {y}
+// placeholders, e.g. https://tile.openstreetmap.org/{z}/{x}/{y}.png.
+func NewRenderer(tileURL, cacheDir string, zoom int) *Renderer {
+	if zoom < 1 || zoom > 19 {
+		zoom = 16
+	}
+	return &Renderer{
+		tileURL:  tileURL,
+		cacheDir: cacheDir,
+		zoom:     zoom,
+		client:   &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Render returns a PNG map centered on a point with a marker on it
+func (r *Renderer) Render(lat, lng float64) ([]byte, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cachePath := filepath.Join(r.cacheDir, "maps", fmt.Sprintf("%d_%.5f_%.5f.png", r.zoom, lat, lng))
+	if data, err := os.ReadFile(cachePath); err == nil {
+		return data, nil
+	}
+
+	// Pixel position of the point on the world map at this zoom level
+	scale := float64(tileSize) * math.Exp2(float64(r.zoom))
+	latRad := lat * math.Pi / 180
+	centerX := (lng + 180) / 360 * scale
+	centerY := (1 - math.Log(math.Tan(latRad)+1/math.Cos(latRad))/math.Pi) / 2 * scale
+	left := int(math.Floor(centerX)) - width/2
+	top := int(math.Floor(centerY)) - height/2
+
+	tiles := 1 << r.zoom
+	canvas := image.NewRGBA(image.Rect(0, 0, width, height))
+	for ty := floorDiv(top, tileSize); ty <= floorDiv(top+height-1, tileSize); ty++ {
+		if ty < 0 || ty >= tiles {
+			continue
+		}
+		for tx := floorDiv(left, tileSize); tx <= floorDiv(left+width-1, tileSize); tx++ {
+			tile, err := r.tile(r.zoom, ((tx%tiles)+tiles)%tiles, ty)
+			if err != nil {
+				return nil, err
+			}
+			at := image.Pt(tx*tileSize-left, ty*tileSize-top)
+			draw.Draw(canvas, image.Rectangle{Min: at, Max: at.Add(image.Pt(tileSize, tileSize))}, tile, image.Point{}, draw.Src)
+		}
+	}
+	drawMarker(canvas, int(math.Floor(centerX))-left, int(math.Floor(centerY))-top)
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, canvas); err != nil {
+		return nil, fmt.Errorf("failed to encode map image: %v", err)
+	}
+	if err := writeCache(cachePath, buf.Bytes()); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// tile returns a map tile, from the disk cache when it is recent enough
+func (r *Renderer) tile(z, x, y int) (image.Image, error) {
+	cachePath := filepath.Join(r.cacheDir, "tiles", strconv.Itoa(z), strconv.Itoa(x), strconv.Itoa(y))
+	if info, err := os.Stat(cachePath); err == nil && time.Since(info.ModTime()) < tileMaxAge {
+		if data, err := os.ReadFile(cachePath); err == nil {
+			if img, _, err := image.Decode(bytes.NewReader(data)); err == nil {
+				return img, nil
+			}
+		}
+	}
+
+	url := strings.NewReplacer("{z}", strconv.Itoa(z), "{x}", strconv.Itoa(x), "{y}", strconv.Itoa(y)).Replace(r.tileURL)
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create tile request: %v", err)
+	}
+	req.Header.Set("User-Agent", "FundaMental Property Analyzer/1.0")
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download tile %d/%d/%d: %v", z, x, y, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("tile server returned status %d for tile %d/%d/%d", resp.StatusCode, z, x, y)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tile %d/%d/%d: %v", z, x, y, err)
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode tile %d/%d/%d: %v", z, x, y, err)
+	}
+	if err := writeCache(cachePath, data); err != nil {
+		return nil, err
+	}
+	return img, nil
+}
+
+// drawMarker draws a red dot with a white outline centered on (x, y)
+func drawMarker(img *image.RGBA, x, y int) {
+	outline := color.RGBA{255, 255, 255, 255}
+	fill := color.RGBA{220, 38, 38, 255}
+	for dy := -markerSize - 2; dy <= markerSize+2; dy++ {
+		for dx := -markerSize - 2; dx <= markerSize+2; dx++ {
+			switch d := dx*dx + dy*dy; {
+			case d <= markerSize*markerSize:
+				img.Set(x+dx, y+dy, fill)
+			case d <= (markerSize+2)*(markerSize+2):
+				img.Set(x+dx, y+dy, outline)
+			}
+		}
+	}
+}
+
+func writeCache(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create map cache directory: %v", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write map cache: %v", err)
+	}
+	return nil
+}
+
+// floorDiv divides rounding towards negative infinity
+func floorDiv(a, b int) int {
+	if a < 0 {
+		return -((-a + b - 1) / b)
+	}
+	return a / b
+}
@@ -0,0 +1,193 @@
+package streetview
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Supported image providers
+const (
+	ProviderMapillary = "mapillary"
+	ProviderGoogle    = "google"
+)
+
+// searchRadius is how far from the property an image may be taken, in meters
+const searchRadius = 50
+
+// Image is a street-level photo near a property
+type Image struct {
+	URL  string // the photo itself
+	Link string // the photo in the provider's viewer
+}
+
+// Finder looks up the street-level photo nearest to a point. Mapillary photos
+// need an access token, Google Street View photos an API key; the Google image
+// URL contains the key, so use a key restricted to the Street View Static API.
+type Finder struct {
+	provider string
+	token    string
+	client   *http.Client
+}
+
+// NewFinder creates a finder for a provider, or returns an error when the
+// provider is unknown or has no token
+func NewFinder(provider, token string) (*Finder, error) {
+	if provider != ProviderMapillary && provider != ProviderGoogle {
+		return nil, fmt.Errorf("unknown street image provider %q", provider)
+	}
+	if token == "" {
+		return nil, fmt.Errorf("street image provider %s needs a token", provider)
+	}
+	return &Finder{
+		provider: provider,
+		token:    token,
+		client:   &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+// Nearest returns the photo closest to a point, or nil when the provider has
+// none within the search radius
+func (f *Finder) Nearest(lat, lng float64) (*Image, error) {
+	if f.provider == ProviderGoogle {
+		return f.nearestGoogle(lat, lng)
+	}
+	return f.nearestMapillary(lat, lng)
+}
+
+func (f *Finder) nearestMapillary(lat, lng float64) (*Image, error) {
+	dLat := searchRadius / 111320.0
+	dLng := dLat / math.Cos(lat*math.Pi/180)
+	params := url.Values{
+		"fields": {"id,thumb_1024_url,computed_geometry,geometry"},
+		"bbox":   {fmt.Sprintf("%.6f,%.6f,%.6f,%.6f", lng-dLng, lat-dLat, lng+dLng, lat+dLat)},
+		"limit":  {"50"},
+	}
+	var result struct {
+		Data []struct {
+			ID               string `json:"id"`
+			ThumbURL         string `json:"thumb_1024_url"`
+			ComputedGeometry *point `json:"computed_geometry"`
+			Geometry         *point `json:"geometry"`
+		} `json:"data"`
+	}
+	if err := f.get("https://graph.mapillary.com/images?"+params.Encode(), "OAuth "+f.token, &result); err != nil {
+		return nil, err
+	}
+
+	var nearest *Image
+	best := math.Inf(1)
+	for _, image := range result.Data {
+		location := image.ComputedGeometry
+		if location == nil {
+			location = image.Geometry
+		}
+		if location == nil || len(location.Coordinates) != 2 || image.ThumbURL == "" {
+			continue
+		}
+		if d := distance(lat, lng, location.Coordinates[1], location.Coordinates[0]); d < best {
+			best = d
+			nearest = &Image{
+				URL:  image.ThumbURL,
+				Link: "https://www.mapillary.com/app/?" + url.Values{"pKey": {image.ID}, "focus": {"photo"}}.Encode(),
+			}
+		}
+	}
+	if best > searchRadius {
+		return nil, nil
+	}
+	return nearest, nil
+}
+
+func (f *Finder) nearestGoogle(lat, lng float64) (*Image, error) {
+	params := url.Values{
+		"location": {fmt.Sprintf("%.6f,%.6f", lat, lng)},
+		"radius":   {fmt.Sprint(searchRadius)},
+		"source":   {"outdoor"},
+		"key":      {f.token},
+	}
+	var result struct {
+		Status   string `json:"status"`
+		PanoID   string `json:"pano_id"`
+		Location struct {
+			Lat float64 `json:"lat"`
+			Lng float64 `json:"lng"`
+		} `json:"location"`
+	}
+	if err := f.get("https://maps.googleapis.com/maps/api/streetview/metadata?"+params.Encode(), "", &result); err != nil {
+		return nil, err
+	}
+	switch result.Status {
+	case "OK":
+	case "ZERO_RESULTS", "NOT_FOUND":
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("street view metadata returned status %s", result.Status)
+	}
+
+	// Point the camera from the panorama at the property
+	heading := fmt.Sprintf("%.0f", bearing(result.Location.Lat, result.Location.Lng, lat, lng))
+	image := url.Values{"size": {"640x400"}, "pano": {result.PanoID}, "heading": {heading}, "key": {f.token}}
+	link := url.Values{"api": {"1"}, "map_action": {"pano"}, "pano": {result.PanoID}, "heading": {heading}}
+	return &Image{
+		URL:  "https://maps.googleapis.com/maps/api/streetview?" + image.Encode(),
+		Link: "https://www.google.com/maps/@?" + link.Encode(),
+	}, nil
+}
+
+type point struct {
+	Coordinates []float64 `json:"coordinates"` // longitude, latitude
+}
+
+// get fetches a JSON document, with an Authorization header when given
+func (f *Finder) get(requestURL, authorization string, result interface{}) error {
+	req, err := http.NewRequest("GET", requestURL, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create %s request: %v", f.provider, err)
+	}
+	req.Header.Set("User-Agent", "FundaMental Property Analyzer/1.0")
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to query %s: %v", f.provider, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read %s response: %v", f.provider, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned status %d: %s", f.provider, resp.StatusCode, string(body))
+	}
+	if err := json.Unmarshal(body, result); err != nil {
+		return fmt.Errorf("failed to parse %s response: %v", f.provider, err)
+	}
+	return nil
+}
+
+// distance returns the distance between two points in meters
+func distance(lat1, lng1, lat2, lng2 float64) float64 {
+	const earthRadius = 6371000
+	dLat := (lat2 - lat1) * math.Pi / 180
+	dLng := (lng2 - lng1) * math.Pi / 180
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1*math.Pi/180)*math.Cos(lat2*math.Pi/180)*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * earthRadius * math.Asin(math.Sqrt(a))
+}
+
+// bearing returns the compass direction from the first point to the second in
+// degrees
+func bearing(lat1, lng1, lat2, lng2 float64) float64 {
+	lat1Rad, lat2Rad := lat1*math.Pi/180, lat2*math.Pi/180
+	dLng := (lng2 - lng1) * math.Pi / 180
+	y := math.Sin(dLng) * math.Cos(lat2Rad)
+	x := math.Cos(lat1Rad)*math.Sin(lat2Rad) - math.Sin(lat1Rad)*math.Cos(lat2Rad)*math.Cos(dLng)
+	return math.Mod(math.Atan2(y, x)*180/math.Pi+360, 360)
+}
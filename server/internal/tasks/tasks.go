@@ -0,0 +1,350 @@
+package tasks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"fundamental/server/internal/database"
+	"fundamental/server/internal/errorsink"
+	"fundamental/server/internal/models"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// pollInterval is how often idle workers look for queued tasks that were
+	// not submitted through this manager
+	pollInterval = 5 * time.Second
+
+	// progressInterval limits how often the progress of a task is written
+	progressInterval = time.Second
+)
+
+var (
+	// ErrUnknownKind is returned when submitting a task of an unregistered kind
+	ErrUnknownKind = errors.New("unknown task kind")
+
+	// ErrTaskFinished is returned when cancelling a task that already stopped
+	ErrTaskFinished = errors.New("task has already finished")
+
+	// ErrInvalidParams wraps the error of a kind's Validate function
+	ErrInvalidParams = errors.New("invalid task parameters")
+)
+
+// Func runs a task. The returned result is stored as JSON. Long tasks should
+// return when ctx is cancelled and report their progress through task.
+type Func func(ctx context.Context, task *Task) (interface{}, error)
+
+// Kind is a kind of task that can be submitted
+type Kind struct {
+	Name        string
+	Description string
+	Admin       bool // only submittable with the admin token
+	Run         Func
+
+	// Validate checks the parameters when the task is submitted; optional
+	Validate func(params json.RawMessage) error
+}
+
+// Task is the handle a running task uses to read its parameters and report
+// progress
+type Task struct {
+	ID     int64
+	Params json.RawMessage
+
+	db         database.TaskStore
+	logger     *logrus.Logger
+	mu         sync.Mutex
+	lastUpdate time.Time
+}
+
+// Progress records that done of total steps are done. Updates are written at
+// most once a second, the last step always.
+func (t *Task) Progress(done, total int, message string) {
+	if total <= 0 {
+		return
+	}
+	progress := float64(done) / float64(total)
+	if progress > 1 {
+		progress = 1
+	}
+
+	t.mu.Lock()
+	if done < total && time.Since(t.lastUpdate) < progressInterval {
+		t.mu.Unlock()
+		return
+	}
+	t.lastUpdate = time.Now()
+	t.mu.Unlock()
+
+	if err := t.db.UpdateTaskProgress(t.ID, progress, message); err != nil {
+		t.logger.WithError(err).WithField("task_id", t.ID).Warn("Failed to record task progress")
+	}
+}
+
+// Manager runs queued tasks on a pool of workers. Tasks are persisted, so
+// they can be listed after they finished; tasks that were running when the
+// server stopped are marked as failed on the next start.
+type Manager struct {
+	db      database.TaskStore
+	logger  *logrus.Logger
+	workers int
+
+	kinds   map[string]Kind
+	claimMu sync.Mutex
+
+	mu      sync.Mutex
+	running map[int64]context.CancelFunc
+
+	wake     chan struct{}
+	stopChan chan struct{}
+	wg       sync.WaitGroup
+}
+
+// NewManager creates a manager with the given number of workers
+func NewManager(db database.TaskStore, workers int, logger *logrus.Logger) *Manager {
+	if logger == nil {
+		logger = logrus.New()
+		logger.SetFormatter(&logrus.JSONFormatter{})
+		logger.SetOutput(os.Stdout)
+	}
+	if workers <= 0 {
+		workers = 1
+	}
+	return &Manager{
+		db:       db,
+		logger:   logger,
+		workers:  workers,
+		kinds:    make(map[string]Kind),
+		running:  make(map[int64]context.CancelFunc),
+		wake:     make(chan struct{}, 1),
+		stopChan: make(chan struct{}),
+	}
+}
+
+// Register adds a kind of task. Kinds have to be registered before Start.
+func (m *Manager) Register(kind Kind) {
+	m.kinds[kind.Name] = kind
+}
+
+// Kind returns a registered kind
+func (m *Manager) Kind(name string) (Kind, bool) {
+	kind, ok := m.kinds[name]
+	return kind, ok
+}
+
+// Kinds returns the registered kinds sorted by name
+func (m *Manager) Kinds() []models.TaskKind {
+	kinds := make([]models.TaskKind, 0, len(m.kinds))
+	for _, kind := range m.kinds {
+		kinds = append(kinds, models.TaskKind{
+			Name:        kind.Name,
+			Description: kind.Description,
+			Admin:       kind.Admin,
+		})
+	}
+	sort.Slice(kinds, func(i, j int) bool { return kinds[i].Name < kinds[j].Name })
+	return kinds
+}
+
+// Submit queues a task and wakes an idle worker
+func (m *Manager) Submit(kind string, params json.RawMessage) (*models.Task, error) {
+	k, ok := m.kinds[kind]
+	if !ok {
+		return nil, ErrUnknownKind
+	}
+	if k.Validate != nil {
+		if err := k.Validate(params); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidParams, err)
+		}
+	}
+	task, err := m.db.CreateTask(kind, params)
+	if err != nil {
+		return nil, err
+	}
+	select {
+	case m.wake <- struct{}{}:
+	default:
+	}
+	return task, nil
+}
+
+// Cancel stops a task. A queued task is canceled right away; a running task
+// is asked to stop and is marked canceled when it returns. Returns nil if the
+// task does not exist.
+func (m *Manager) Cancel(id int64) (*models.Task, error) {
+	if _, err := m.db.CancelQueuedTask(id); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	cancel, running := m.running[id]
+	m.mu.Unlock()
+	if running {
+		cancel()
+	}
+
+	task, err := m.db.GetTask(id)
+	if err != nil || task == nil {
+		return task, err
+	}
+	if !running && task.Status != models.TaskCanceled && task.Finished() {
+		return task, ErrTaskFinished
+	}
+	return task, nil
+}
+
+// Start marks interrupted tasks as failed and starts the workers
+func (m *Manager) Start() {
+	if n, err := m.db.FailInterruptedTasks(); err != nil {
+		m.logger.WithError(err).Error("Failed to mark interrupted tasks")
+	} else if n > 0 {
+		m.logger.WithField("tasks", n).Warn("Marked tasks interrupted by a restart as failed")
+	}
+
+	for i := 0; i < m.workers; i++ {
+		m.wg.Add(1)
+		go m.work()
+	}
+	m.logger.WithField("workers", m.workers).Info("Started task workers")
+}
+
+// Stop cancels the running tasks and waits for the workers to return
+func (m *Manager) Stop() {
+	close(m.stopChan)
+	m.mu.Lock()
+	for _, cancel := range m.running {
+		cancel()
+	}
+	m.mu.Unlock()
+	m.wg.Wait()
+}
+
+// work runs queued tasks until the manager stops
+func (m *Manager) work() {
+	defer m.wg.Done()
+	defer errorsink.Recover("tasks")
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		for m.runNext() {
+			select {
+			case <-m.stopChan:
+				return
+			default:
+			}
+		}
+
+		select {
+		case <-m.stopChan:
+			return
+		case <-m.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// runNext claims and runs the oldest queued task. Returns false when no task
+// was queued.
+func (m *Manager) runNext() bool {
+	m.claimMu.Lock()
+	record, err := m.db.ClaimNextTask()
+	m.claimMu.Unlock()
+	if err != nil {
+		m.logger.WithError(err).Error("Failed to claim task")
+		return false
+	}
+	if record == nil {
+		return false
+	}
+
+	// Another worker may be idle while more tasks are queued
+	select {
+	case m.wake <- struct{}{}:
+	default:
+	}
+
+	m.run(record)
+	return true
+}
+
+// run executes a claimed task and stores its outcome
+func (m *Manager) run(record *models.Task) {
+	logger := m.logger.WithFields(logrus.Fields{
+		"task_id": record.ID,
+		"kind":    record.Kind,
+	})
+
+	kind, ok := m.kinds[record.Kind]
+	if !ok {
+		m.finish(record.ID, models.TaskFailed, nil, ErrUnknownKind.Error())
+		logger.Error("Task of unknown kind failed")
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	m.mu.Lock()
+	m.running[record.ID] = cancel
+	m.mu.Unlock()
+	select {
+	case <-m.stopChan:
+		cancel() // claimed while stopping
+	default:
+	}
+	defer func() {
+		m.mu.Lock()
+		delete(m.running, record.ID)
+		m.mu.Unlock()
+	}()
+
+	logger.Info("Starting task")
+	task := &Task{ID: record.ID, Params: record.Params, db: m.db, logger: m.logger}
+	result, err := m.call(ctx, kind.Run, task)
+
+	switch {
+	case ctx.Err() != nil:
+		m.finish(record.ID, models.TaskCanceled, result, "")
+		logger.Info("Task canceled")
+	case err != nil:
+		m.finish(record.ID, models.TaskFailed, result, err.Error())
+		logger.WithError(err).Error("Task failed")
+	default:
+		m.finish(record.ID, models.TaskSucceeded, result, "")
+		logger.Info("Task succeeded")
+	}
+}
+
+// call runs a task function, turning a panic into an error so one broken
+// task does not stop the worker
+func (m *Manager) call(ctx context.Context, run Func, task *Task) (result interface{}, err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = fmt.Errorf("task panicked: %v", recovered)
+		}
+	}()
+	return run(ctx, task)
+}
+
+// finish stores the outcome of a task
+func (m *Manager) finish(id int64, status string, result interface{}, errMsg string) {
+	var resultJSON json.RawMessage
+	if result != nil {
+		encoded, err := json.Marshal(result)
+		if err != nil {
+			m.logger.WithError(err).WithField("task_id", id).Error("Failed to encode task result")
+		} else {
+			resultJSON = encoded
+		}
+	}
+	if err := m.db.FinishTask(id, status, resultJSON, errMsg); err != nil {
+		m.logger.WithError(err).WithField("task_id", id).Error("Failed to record task outcome")
+	}
+}
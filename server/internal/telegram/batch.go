@@ -0,0 +1,192 @@
+package telegram
+
+import (
+	"fundamental/server/internal/database"
+	"fundamental/server/internal/errorsink"
+	"fundamental/server/internal/format"
+	"fundamental/server/internal/i18n"
+	"fundamental/server/internal/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+// outboxSize is the number of notifications that may wait to be sent before
+// queueing more blocks the caller
+const outboxSize = 256
+
+// notification is the message about a new listing and the photos that follow
+// it, composed when the listing is evaluated and sent later from the outbound
+// queue
+type notification struct {
+	config  models.TelegramConfig
+	message string
+
+	photoPath    string // cached thumbnail of the listing
+	photoURL     string // scraped photo, when there is no thumbnail
+	photoCaption string
+
+	lat, lng   float64
+	located    bool
+	mapCaption string
+}
+
+// notificationBatch holds what the notifications of a batch of new listings
+// share: the number format, the translations and the market context, loaded
+// once per district and city instead of once per listing
+type notificationBatch struct {
+	db         database.TelegramStore // nil without a database
+	formatter  *format.Formatter
+	translator *i18n.Translator
+	market     models.MarketReference
+
+	prices       map[string]districtPrices
+	subscription map[string]bool
+	phases       map[string]string
+	service      *Service
+}
+
+// districtPrices are the medians of a district, or why loading them failed
+type districtPrices struct {
+	activeMedian float64
+	activeCount  int
+	soldMedian   float64
+	soldCount    int
+	err          error
+}
+
+// newBatch starts the evaluation of a batch of new listings, loading the
+// notification filters when they are not loaded yet
+func (s *Service) newBatch() *notificationBatch {
+	if s.filters == nil && s.db != nil {
+		if filters, err := s.db.GetTelegramFilters(); err == nil {
+			s.logger.Info("Loading telegram filters before property check")
+			s.filters = filters
+		} else {
+			s.logger.WithError(err).Error("Failed to load telegram filters")
+		}
+	}
+
+	b := &notificationBatch{
+		db:           s.db,
+		formatter:    s.formatter(),
+		translator:   s.translator(),
+		prices:       make(map[string]districtPrices),
+		subscription: make(map[string]bool),
+		phases:       make(map[string]string),
+		service:      s,
+	}
+	if s.db != nil {
+		b.market = s.db.MarketReference()
+	}
+	return b
+}
+
+// districtPrices returns the medians of a district
+func (b *notificationBatch) districtPrices(district string) (districtPrices, error) {
+	prices, ok := b.prices[district]
+	if !ok {
+		prices.activeMedian, prices.activeCount, prices.soldMedian, prices.soldCount, prices.err = b.db.GetDistrictPriceAnalysis(district)
+		b.prices[district] = prices
+	}
+	return prices, prices.err
+}
+
+// subscribed reports whether new listings in a district are notified whatever
+// the filters
+func (b *notificationBatch) subscribed(district string) bool {
+	if b.db == nil || district == "" {
+		return false
+	}
+	subscribed, ok := b.subscription[district]
+	if !ok {
+		var err error
+		if subscribed, err = b.db.IsDistrictSubscribed(district); err != nil {
+			b.service.logger.WithError(err).Error("Failed to look up district subscription")
+		}
+		b.subscription[district] = subscribed
+	}
+	return subscribed
+}
+
+// marketPhase returns the market phase section of a city, or "" without one
+func (b *notificationBatch) marketPhase(city string) string {
+	section, ok := b.phases[city]
+	if !ok {
+		var err error
+		if section, err = b.service.marketPhaseSection(b.formatter, b.translator, city); err != nil {
+			b.service.logger.WithError(err).Error("Failed to get market phase")
+		}
+		b.phases[city] = section
+	}
+	return section
+}
+
+// NotifyNewProperties evaluates a batch of new properties against the
+// notification filters and queues the notifications of those that pass. The
+// market context is loaded once per district and city of the batch; the
+// notifications are sent in order in the background.
+func (s *Service) NotifyNewProperties(properties []map[string]interface{}) error {
+	if !s.config.IsEnabled || len(properties) == 0 {
+		return nil
+	}
+	if err := checkConfig(s.config); err != nil {
+		return err
+	}
+
+	b := s.newBatch()
+	queued := 0
+	for _, property := range properties {
+		if n := s.newPropertyNotification(b, property); n != nil {
+			s.enqueue(n)
+			queued++
+		}
+	}
+	s.logger.WithFields(logrus.Fields{
+		"properties": len(properties),
+		"queued":     queued,
+		"districts":  len(b.prices),
+	}).Info("Queued Telegram notifications for new properties")
+	return nil
+}
+
+// enqueue adds a notification to the outbound queue, starting its sender on
+// first use
+func (s *Service) enqueue(n *notification) {
+	s.outboxOnce.Do(func() {
+		s.outbox = make(chan notification, outboxSize)
+		go s.sendQueued()
+	})
+	s.pending.Add(1)
+	s.outbox <- *n
+}
+
+// Flush blocks until the queued notifications are sent
+func (s *Service) Flush() {
+	s.pending.Wait()
+}
+
+// sendQueued sends the queued notifications one at a time, so the messages
+// of a listing stay together and in order
+func (s *Service) sendQueued() {
+	defer errorsink.Recover("telegram")
+	for n := range s.outbox {
+		if err := s.deliver(&n); err != nil {
+			s.logger.WithError(err).Error("Failed to send Telegram notification")
+		}
+		s.pending.Done()
+	}
+}
+
+// deliver sends the message of a notification and then its photos; failing
+// photos are only logged. Notifications beyond the daily quota are dropped.
+func (s *Service) deliver(n *notification) error {
+	if !s.withinQuota() {
+		return nil
+	}
+	if err := s.sendMessage(&n.config, n.message); err != nil {
+		return err
+	}
+	s.sendListingPhoto(n)
+	s.sendMap(n)
+	return nil
+}
@@ -0,0 +1,75 @@
+package telegram
+
+import (
+	"errors"
+	"fmt"
+	"fundamental/server/internal/models"
+	"html"
+	"strings"
+)
+
+// maxReportedProblems bounds the integrity problems listed in a notification
+const maxReportedProblems = 10
+
+// NotifyDatabaseCorruption alerts that the scheduled integrity check found
+// problems in the database
+func (s *Service) NotifyDatabaseCorruption(problems []string) error {
+	if !s.config.IsEnabled {
+		return nil
+	}
+
+	if s.config.BotToken == "" {
+		return errors.New("Telegram bot token is not configured")
+	}
+
+	if s.config.ChatID == "" {
+		return errors.New("Telegram chat ID is not configured")
+	}
+
+	t := s.translator()
+	var b strings.Builder
+	b.WriteString("<b>⚠️ " + t.T("Database integrity check failed") + "</b>\n\n")
+	b.WriteString(t.Sprintf("The check found %d problem(s):", len(problems)) + "\n")
+	for i, problem := range problems {
+		if i == maxReportedProblems {
+			b.WriteString("• " + t.Sprintf("… and %d more", len(problems)-maxReportedProblems) + "\n")
+			break
+		}
+		fmt.Fprintf(&b, "• <code>%s</code>\n", html.EscapeString(problem))
+	}
+	b.WriteString("\n" + t.T("Restore a recent backup before the damage spreads."))
+
+	return s.SendMessage(b.String())
+}
+
+// NotifyLowScrapeCoverage alerts that a spider run went through less of
+// Funda's results than the threshold, a sign that the parser or the
+// pagination of the spider broke
+func (s *Service) NotifyLowScrapeCoverage(run models.SpiderRun, threshold float64) error {
+	if !s.config.IsEnabled {
+		return nil
+	}
+
+	if s.config.BotToken == "" {
+		return errors.New("Telegram bot token is not configured")
+	}
+
+	if s.config.ChatID == "" {
+		return errors.New("Telegram chat ID is not configured")
+	}
+
+	if run.Coverage == nil || run.ResultCount == nil {
+		return nil
+	}
+
+	t := s.translator()
+	var b strings.Builder
+	b.WriteString("<b>⚠️ " + t.T("Low scrape coverage") + "</b>\n\n")
+	b.WriteString(t.Sprintf("%s spider for %s: %d of %d listings found (%.0f%%).",
+		html.EscapeString(run.SpiderType), html.EscapeString(run.Place), run.Listings, *run.ResultCount,
+		*run.Coverage*100) + "\n")
+	b.WriteString(t.Sprintf("Alerts are sent below %.0f%% coverage.", threshold*100) + "\n")
+	b.WriteString("\n" + t.T("Check the spider for changes to Funda's pages."))
+
+	return s.SendMessage(b.String())
+}
@@ -0,0 +1,55 @@
+package telegram
+
+import (
+	"fmt"
+	"fundamental/server/internal/analytics"
+	"fundamental/server/internal/format"
+	"fundamental/server/internal/i18n"
+	"fundamental/server/internal/models"
+	"html"
+	"strings"
+)
+
+var marketPhaseLabels = map[string]string{
+	models.MarketPhaseSellers:  "Seller's market",
+	models.MarketPhaseBalanced: "Balanced market",
+	models.MarketPhaseBuyers:   "Buyer's market",
+}
+
+// marketPhaseSection formats the market phase of a city and the components
+// behind it, or returns "" when the city has too little data for a phase
+func (s *Service) marketPhaseSection(f *format.Formatter, t *i18n.Translator, city string) (string, error) {
+	indicators, err := s.db.GetMarketIndicators(city, analytics.DefaultWindowDays)
+	if err != nil {
+		return "", err
+	}
+	phase := analytics.MarketPhase(*indicators)
+	if phase.Score == nil {
+		return "", nil
+	}
+
+	score := f.Number(*phase.Score, 2)
+	if *phase.Score >= 0 {
+		score = "+" + score
+	}
+
+	var section strings.Builder
+	section.WriteString(t.T("🌡️ <u>Market</u>") + "\n")
+	fmt.Fprintf(&section, "%s: <b>%s</b> (score %s)", html.EscapeString(city), t.T(marketPhaseLabels[phase.Phase]), score)
+	for _, c := range phase.Components {
+		if c.Value == nil {
+			continue
+		}
+		switch c.Component {
+		case models.MarketComponentInventory:
+			section.WriteString("\n• " + t.Sprintf("Listings on the market: %s in %d days", f.SignedPercent(*c.Value*100, 1), phase.WindowDays))
+		case models.MarketComponentDaysToSell:
+			section.WriteString("\n• " + t.Sprintf("Median days to sell: %s", f.Number(*c.Value, 0)))
+		case models.MarketComponentOverAsking:
+			section.WriteString("\n• " + t.Sprintf("Sold over asking: %s", f.Percent(*c.Value*100, 0)))
+		case models.MarketComponentPriceMomentum:
+			section.WriteString("\n• " + t.Sprintf("Sold price per m²: %s in %d days", f.SignedPercent(*c.Value*100, 1), phase.WindowDays))
+		}
+	}
+	return section.String(), nil
+}
@@ -5,11 +5,21 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"fundamental/server/internal/country"
 	"fundamental/server/internal/database"
+	"fundamental/server/internal/errorsink"
+	"fundamental/server/internal/format"
+	"fundamental/server/internal/i18n"
 	"fundamental/server/internal/models"
+	"fundamental/server/internal/staticmap"
+	"html"
 	"io"
+	"mime/multipart"
 	"net/http"
+	"net/url"
+	"os"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/sirupsen/logrus"
@@ -20,12 +30,22 @@ type Service struct {
 	client  *http.Client
 	config  *models.TelegramConfig
 	filters *models.TelegramFilters
-	db      *database.Database
+	db      database.TelegramStore
+	maps    *staticmap.Renderer
+
+	bidAdvice     bool
+	listingPhotos bool
+
+	// outbox holds the notifications of new listings waiting to be sent
+	outbox     chan notification
+	outboxOnce sync.Once
+	// pending counts the queued notifications not sent yet
+	pending sync.WaitGroup
 }
 
 func NewService(logger *logrus.Logger) *Service {
 	return &Service{
-		logger: logger,
+		logger: errorsink.WithModule(logger, "telegram"),
 		client: &http.Client{
 			Timeout: 10 * time.Second,
 		},
@@ -40,7 +60,30 @@ func (s *Service) UpdateFilters(filters *models.TelegramFilters) {
 	s.filters = filters
 }
 
-func (s *Service) SetDatabase(db *database.Database) {
+// SetStaticMaps attaches a map image of the listing's location to notifications
+func (s *Service) SetStaticMaps(renderer *staticmap.Renderer) {
+	s.maps = renderer
+}
+
+// SetTransport sends the requests to the Telegram API through rt, such as a
+// stub recording them
+func (s *Service) SetTransport(rt http.RoundTripper) {
+	s.client.Transport = rt
+}
+
+// SetBidAdvice adds a suggested bid range to notifications of new listings
+func (s *Service) SetBidAdvice(enabled bool) {
+	s.bidAdvice = enabled
+}
+
+// SetListingPhotos attaches the primary photo of the listing to notifications
+func (s *Service) SetListingPhotos(enabled bool) {
+	s.listingPhotos = enabled
+}
+
+// SetDatabase sets the store notifications read their filters and market
+// context from
+func (s *Service) SetDatabase(db database.TelegramStore) {
 	s.db = db
 	// Load filters from database
 	if filters, err := db.GetTelegramFilters(); err == nil {
@@ -60,106 +103,169 @@ func (s *Service) SetDatabase(db *database.Database) {
 	}
 }
 
-// getPriceAnalysis returns the price analysis for a property
-func (s *Service) getPriceAnalysis(price, livingArea float64, postalCode string) (string, string, error) {
+// withinQuota counts a notification against the daily notification quota and
+// reports whether it may be sent. When the quota cannot be checked the
+// notification is sent.
+func (s *Service) withinQuota() bool {
+	if s.db == nil {
+		return true
+	}
+	allowed, err := s.db.UseNotificationQuota()
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to check notification quota")
+		return true
+	}
+	if !allowed {
+		s.logger.Warn("Daily notification quota used up, dropped notification")
+	}
+	return allowed
+}
+
+// itemDistrict returns the district of a scraped item, from its country and
+// postal code
+func itemDistrict(property map[string]interface{}) string {
+	code, _ := property["country"].(string)
+	postalCode, _ := property["postal_code"].(string)
+	return country.Get(code).District(postalCode)
+}
+
+// getPriceAnalysis returns the price analysis for a property against the
+// medians of its district, loaded once per batch
+func (s *Service) getPriceAnalysis(b *notificationBatch, price, livingArea float64, postalCode, district string) (string, string, error) {
 	if s.db == nil {
 		return "", "", errors.New("database connection not initialized")
 	}
+	f, t := b.formatter, b.translator
 
 	if livingArea <= 0 {
 		return "", "", errors.New("invalid living area")
 	}
 
 	pricePerSqm := price / livingArea
-	district := postalCode[:4]
+	if district == "" {
+		return f.Money(pricePerSqm) + "/m²", t.T("District comparison unavailable"), fmt.Errorf("postal code %q has no district", postalCode)
+	}
 
-	activeMedian, activeCount, soldMedian, soldCount, err := s.db.GetDistrictPriceAnalysis(district)
+	prices, err := b.districtPrices(district)
 	if err != nil {
-		return fmt.Sprintf("€%s/m²", formatNumber(pricePerSqm)), "District comparison unavailable", err
+		return f.Money(pricePerSqm) + "/m²", t.T("District comparison unavailable"), err
 	}
+	activeMedian, activeCount, soldMedian, soldCount := prices.activeMedian, prices.activeCount, prices.soldMedian, prices.soldCount
 
 	// Format the analysis message
 	var analysis strings.Builder
-	analysis.WriteString("📊 <u>District Analysis</u>\n")
+	analysis.WriteString(t.T("📊 <u>District Analysis</u>") + "\n")
 
 	// Compare with active listings
 	if activeMedian > 0 {
 		ratio := pricePerSqm / activeMedian
-		var rating string
-		switch {
-		case ratio <= 0.80:
-			rating = "<b>GREAT</b>"
-		case ratio <= 0.95:
-			rating = "<b>GOOD</b>"
-		case ratio <= 1.05:
-			rating = "<b>NORMAL</b>"
-		case ratio <= 1.20:
-			rating = "<b>BAD</b>"
-		default:
-			rating = "<b>HORRIBLE</b>"
-		}
 		diff := ((ratio - 1) * 100)
-		analysis.WriteString(fmt.Sprintf("Current listings (%d properties):\n%s (%+.1f%% vs. median)\n\n", activeCount, rating, diff))
+		analysis.WriteString(t.Sprintf("Current listings (%d properties):\n%s (%s vs. median)", activeCount, priceRating(t, ratio), f.SignedPercent(diff, 1)))
 	} else {
-		analysis.WriteString("Current listings (0 properties):\nNo active listings for comparison\n\n")
+		analysis.WriteString(t.T("Current listings (0 properties):\nNo active listings for comparison"))
 	}
+	analysis.WriteString("\n\n")
 
 	// Compare with sold properties
 	if soldMedian > 0 {
 		ratio := pricePerSqm / soldMedian
-		var rating string
-		switch {
-		case ratio <= 0.80:
-			rating = "<b>GREAT</b>"
-		case ratio <= 0.95:
-			rating = "<b>GOOD</b>"
-		case ratio <= 1.05:
-			rating = "<b>NORMAL</b>"
-		case ratio <= 1.20:
-			rating = "<b>BAD</b>"
-		default:
-			rating = "<b>HORRIBLE</b>"
-		}
 		diff := ((ratio - 1) * 100)
-		analysis.WriteString(fmt.Sprintf("Past year sales (%d properties):\n%s (%+.1f%% vs. median)", soldCount, rating, diff))
+		analysis.WriteString(t.Sprintf("Past year sales (%d properties):\n%s (%s vs. median)", soldCount, priceRating(t, ratio), f.SignedPercent(diff, 1)))
 	} else {
-		analysis.WriteString("Past year sales (0 properties):\nNo recent sales for comparison")
+		analysis.WriteString(t.T("Past year sales (0 properties):\nNo recent sales for comparison"))
 	}
 
-	return fmt.Sprintf("€%s/m²", formatNumber(pricePerSqm)), analysis.String(), nil
+	return f.Money(pricePerSqm) + "/m²", analysis.String(), nil
 }
 
-// formatNumber adds thousand separators to a number
-func formatNumber(num float64) string {
-	parts := strings.Split(fmt.Sprintf("%.0f", num), ".")
-	intPart := parts[0]
-	var result []byte
-	for i, j := len(intPart)-1, 0; i >= 0; i, j = i-1, j+1 {
-		if j > 0 && j%3 == 0 {
-			result = append([]byte{','}, result...)
+// priceRating rates a price per m² by its ratio to the district median
+func priceRating(t *i18n.Translator, ratio float64) string {
+	var rating string
+	switch {
+	case ratio <= 0.80:
+		rating = "GREAT"
+	case ratio <= 0.95:
+		rating = "GOOD"
+	case ratio <= 1.05:
+		rating = "NORMAL"
+	case ratio <= 1.20:
+		rating = "BAD"
+	default:
+		rating = "HORRIBLE"
+	}
+	return "<b>" + t.T(rating) + "</b>"
+}
+
+var bidFactorLabels = map[string]string{
+	models.BidFactorValuation:     "Estimated value",
+	models.BidFactorDistrictPrice: "District asking price changes",
+	models.BidFactorDaysOnMarket:  "Days on market",
+	models.BidFactorRelisted:      "Republished or relisted",
+}
+
+// bidAdviceSection formats the suggested bid range and the factors behind it
+func bidAdviceSection(f *format.Formatter, t *i18n.Translator, advice *models.BidAdvice) string {
+	var section strings.Builder
+	section.WriteString(t.T("🎯 <u>Suggested Bid</u>") + "\n")
+	section.WriteString(t.Sprintf("%s – %s (%s confidence)", f.Money(float64(advice.BidLow)),
+		f.Money(float64(advice.BidHigh)), t.T(advice.Confidence)))
+	if advice.Estimate != nil {
+		section.WriteString("\n" + t.Sprintf("Estimated value: %s (%d sales)", f.Money(float64(*advice.Estimate)), advice.Comparables))
+	}
+	for _, factor := range advice.Factors {
+		section.WriteString(fmt.Sprintf("\n• %s: %s", t.T(bidFactorLabels[factor.Factor]), f.SignedPercent(factor.Adjustment, 1)))
+	}
+	return section.String()
+}
+
+// formatter returns the number and currency format of the notifications: the
+// locale and currency of the Telegram configuration, falling back to the
+// default format.currency preference
+func (s *Service) formatter() *format.Formatter {
+	settings := format.Settings{Locale: s.config.Locale, Currency: s.config.Currency}
+	if s.db != nil {
+		defaults, err := s.db.GetFormatSettings("")
+		if err != nil {
+			s.logger.WithError(err).Warn("Failed to load the default number format")
 		}
-		result = append([]byte{intPart[i]}, result...)
+		settings = settings.Merge(defaults)
 	}
-	return string(result)
+	return format.New(settings)
+}
+
+// translator returns the translations of the notifications' labels into the
+// language of the Telegram locale; without a locale they are English
+func (s *Service) translator() *i18n.Translator {
+	return i18n.New(s.config.Locale)
 }
 
 // SendMessage sends a message to the configured Telegram chat
 func (s *Service) SendMessage(message string) error {
-	if !s.config.IsEnabled {
-		return nil
-	}
+	return s.sendMessage(s.config, message)
+}
 
-	if s.config.BotToken == "" {
+// checkConfig returns why notifications cannot be sent with a configuration
+func checkConfig(config *models.TelegramConfig) error {
+	if config.BotToken == "" {
 		return errors.New("Telegram bot token is not configured")
 	}
-
-	if s.config.ChatID == "" {
+	if config.ChatID == "" {
 		return errors.New("Telegram chat ID is not configured")
 	}
+	return nil
+}
+
+func (s *Service) sendMessage(config *models.TelegramConfig, message string) error {
+	if !config.IsEnabled {
+		return nil
+	}
+	if err := checkConfig(config); err != nil {
+		return err
+	}
 
-	url := fmt.Sprintf("https://api.telegram.org/bot%s/sendMessage", s.config.BotToken)
+	url := fmt.Sprintf("https://api.telegram.org/bot%s/sendMessage", config.BotToken)
 	payload := map[string]interface{}{
-		"chat_id":    s.config.ChatID,
+		"chat_id":    config.ChatID,
 		"text":       message,
 		"parse_mode": "HTML",
 	}
@@ -169,64 +275,221 @@ func (s *Service) SendMessage(message string) error {
 		return fmt.Errorf("failed to marshal message payload: %v", err)
 	}
 
-	resp, err := http.Post(url, "application/json", bytes.NewBuffer(jsonData))
+	resp, err := s.client.Post(url, "application/json", bytes.NewBuffer(jsonData))
 	if err != nil {
 		return fmt.Errorf("failed to send message to Telegram API: %v", err)
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		switch resp.StatusCode {
-		case http.StatusUnauthorized:
-			return errors.New("invalid bot token - please check your token from @BotFather")
-		case http.StatusBadRequest:
-			return fmt.Errorf("invalid chat ID or message format: %s", string(body))
-		case http.StatusForbidden:
-			return errors.New("bot was blocked by the user or chat")
-		case http.StatusNotFound:
-			return errors.New("bot not found - please check your token from @BotFather")
-		default:
-			return fmt.Errorf("Telegram API error (status %d): %s", resp.StatusCode, string(body))
-		}
+	return apiError(resp)
+}
+
+// SendPhoto sends a PNG or JPEG image with an HTML caption to the configured
+// Telegram chat
+func (s *Service) SendPhoto(photo []byte, caption string) error {
+	return s.sendPhoto(s.config, photo, caption)
+}
+
+func (s *Service) sendPhoto(config *models.TelegramConfig, photo []byte, caption string) error {
+	if !config.IsEnabled {
+		return nil
+	}
+	if err := checkConfig(config); err != nil {
+		return err
 	}
 
-	return nil
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	writer.WriteField("chat_id", config.ChatID)
+	writer.WriteField("caption", caption)
+	writer.WriteField("parse_mode", "HTML")
+	name := "photo.png"
+	if http.DetectContentType(photo) == "image/jpeg" {
+		name = "photo.jpg"
+	}
+	part, err := writer.CreateFormFile("photo", name)
+	if err != nil {
+		return fmt.Errorf("failed to create photo payload: %v", err)
+	}
+	if _, err := part.Write(photo); err != nil {
+		return fmt.Errorf("failed to create photo payload: %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("failed to create photo payload: %v", err)
+	}
+
+	url := fmt.Sprintf("https://api.telegram.org/bot%s/sendPhoto", config.BotToken)
+	resp, err := s.client.Post(url, writer.FormDataContentType(), &body)
+	if err != nil {
+		return fmt.Errorf("failed to send photo to Telegram API: %v", err)
+	}
+	defer resp.Body.Close()
+
+	return apiError(resp)
 }
 
-// NotifyNewProperty sends a notification about a new property
-func (s *Service) NotifyNewProperty(property map[string]interface{}) error {
-	if !s.config.IsEnabled {
+// SendPhotoURL sends the image at a URL with an HTML caption to the configured
+// Telegram chat; Telegram downloads the image itself
+func (s *Service) SendPhotoURL(photoURL, caption string) error {
+	return s.sendPhotoURL(s.config, photoURL, caption)
+}
+
+func (s *Service) sendPhotoURL(config *models.TelegramConfig, photoURL, caption string) error {
+	if !config.IsEnabled {
 		return nil
 	}
+	if err := checkConfig(config); err != nil {
+		return err
+	}
 
-	if s.config.BotToken == "" {
-		return errors.New("Telegram bot token is not configured")
+	endpoint := fmt.Sprintf("https://api.telegram.org/bot%s/sendPhoto", config.BotToken)
+	resp, err := s.client.PostForm(endpoint, url.Values{
+		"chat_id":    {config.ChatID},
+		"photo":      {photoURL},
+		"caption":    {caption},
+		"parse_mode": {"HTML"},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to send photo to Telegram API: %v", err)
 	}
+	defer resp.Body.Close()
 
-	if s.config.ChatID == "" {
-		return errors.New("Telegram chat ID is not configured")
+	return apiError(resp)
+}
+
+// apiError turns an unsuccessful Telegram API response into an error
+func apiError(resp *http.Response) error {
+	if resp.StatusCode == http.StatusOK {
+		return nil
 	}
+	body, _ := io.ReadAll(resp.Body)
+	switch resp.StatusCode {
+	case http.StatusUnauthorized:
+		return errors.New("invalid bot token - please check your token from @BotFather")
+	case http.StatusBadRequest:
+		return fmt.Errorf("invalid chat ID or message format: %s", string(body))
+	case http.StatusForbidden:
+		return errors.New("bot was blocked by the user or chat")
+	case http.StatusNotFound:
+		return errors.New("bot not found - please check your token from @BotFather")
+	default:
+		return fmt.Errorf("Telegram API error (status %d): %s", resp.StatusCode, string(body))
+	}
+}
 
-	// Ensure filters are loaded
-	if s.filters == nil && s.db != nil {
-		if filters, err := s.db.GetTelegramFilters(); err == nil {
-			s.logger.Info("Loading telegram filters before property check")
-			s.filters = filters
-		} else {
-			s.logger.WithError(err).Error("Failed to load telegram filters")
+// coordinates returns the latitude and longitude of a property, if known
+func coordinates(property map[string]interface{}) (float64, float64, bool) {
+	lat, latOK := property["latitude"].(float64)
+	lng, lngOK := property["longitude"].(float64)
+	return lat, lng, latOK && lngOK
+}
+
+// mapLinks returns links that open the property's location in Google Maps and
+// Apple Maps, pinned on its coordinates when known and searched by address
+// otherwise
+func mapLinks(property map[string]interface{}, address string) string {
+	var google, apple string
+	if lat, lng, ok := coordinates(property); ok {
+		point := fmt.Sprintf("%.6f,%.6f", lat, lng)
+		google = "https://www.google.com/maps/search/?" + url.Values{"api": {"1"}, "query": {point}}.Encode()
+		apple = "https://maps.apple.com/?" + url.Values{"ll": {point}, "q": {address}}.Encode()
+	} else {
+		google = "https://www.google.com/maps/search/?" + url.Values{"api": {"1"}, "query": {address}}.Encode()
+		apple = "https://maps.apple.com/?" + url.Values{"q": {address}}.Encode()
+	}
+	return fmt.Sprintf("🗺️ <a href=\"%s\">Google Maps</a> | <a href=\"%s\">Apple Maps</a>",
+		html.EscapeString(google), html.EscapeString(apple))
+}
+
+// streetImageLink returns a link to the street-level photo of the property, or
+// an empty string when it has none
+func streetImageLink(t *i18n.Translator, property map[string]interface{}) string {
+	link, _ := property["street_image_link"].(string)
+	if link == "" {
+		return ""
+	}
+	return fmt.Sprintf("\n📷 <a href=\"%s\">%s</a>", html.EscapeString(link), t.T("Street view"))
+}
+
+// sendMap sends a map image of the listing's location, if it has coordinates
+func (s *Service) sendMap(n *notification) {
+	if s.maps == nil || !n.located {
+		return
+	}
+	image, err := s.maps.Render(n.lat, n.lng)
+	if err != nil {
+		s.logger.WithError(err).Warn("Failed to render map image")
+		return
+	}
+	if err := s.sendPhoto(&n.config, image, n.mapCaption); err != nil {
+		s.logger.WithError(err).Warn("Failed to send map image")
+	}
+}
+
+// sendListingPhoto sends the primary photo of the listing: its cached
+// thumbnail when there is one, the scraped image otherwise
+func (s *Service) sendListingPhoto(n *notification) {
+	if !s.listingPhotos {
+		return
+	}
+	var err error
+	if n.photoPath != "" {
+		var photo []byte
+		if photo, err = os.ReadFile(n.photoPath); err == nil {
+			err = s.sendPhoto(&n.config, photo, n.photoCaption)
 		}
+	} else if n.photoURL != "" {
+		err = s.sendPhotoURL(&n.config, n.photoURL, n.photoCaption)
+	}
+	if err != nil {
+		s.logger.WithError(err).Warn("Failed to send listing photo")
+	}
+}
+
+// NotifyNewProperty sends a notification about a new property right away and
+// returns whether sending its message failed
+func (s *Service) NotifyNewProperty(property map[string]interface{}) error {
+	if !s.config.IsEnabled {
+		return nil
+	}
+	if err := checkConfig(s.config); err != nil {
+		return err
 	}
 
+	n := s.newPropertyNotification(s.newBatch(), property)
+	if n == nil {
+		return nil
+	}
+	return s.deliver(n)
+}
+
+// newPropertyNotification evaluates a new property against the notification
+// filters and composes its notification, or returns nil when it is filtered
+// out
+func (s *Service) newPropertyNotification(b *notificationBatch, property map[string]interface{}) *notification {
 	// Convert property map to Property struct for filter checking
 	prop := &models.Property{
 		Price:      int(property["price"].(float64)),
 		PostalCode: property["postal_code"].(string),
+		District:   itemDistrict(property),
 	}
 
 	// Handle optional fields
-	if energyLabel, ok := property["energy_label"].(string); ok {
-		prop.EnergyLabel = energyLabel
+	for key, field := range map[string]*string{
+		"energy_label":  &prop.EnergyLabel,
+		"city":          &prop.City,
+		"neighborhood":  &prop.Neighborhood,
+		"property_type": &prop.PropertyType,
+		"status":        &prop.Status,
+		"country":       &prop.Country,
+	} {
+		if value, ok := property[key].(string); ok {
+			*field = value
+		}
+	}
+	if yb, ok := property["year_built"].(float64); ok && yb > 0 {
+		yearBuilt := int(yb)
+		prop.YearBuilt = &yearBuilt
 	}
 	if la, ok := property["living_area"].(float64); ok && la > 0 {
 		livingArea := int(la)
@@ -257,9 +520,13 @@ func (s *Service) NotifyNewProperty(property map[string]interface{}) error {
 		}).Debug("Invalid room count")
 	}
 
+	// A new listing in a subscribed district is notified whatever the filters
+	district := prop.District
+	subscribed := b.subscribed(district)
+
 	// Check if property matches filters
-	if s.filters != nil {
-		allowed := s.filters.IsPropertyAllowed(prop)
+	if s.filters != nil && !subscribed {
+		allowed := s.filters.IsPropertyAllowed(prop, b.market)
 		s.logger.WithFields(logrus.Fields{
 			"url":             property["url"],
 			"allowed":         allowed,
@@ -307,22 +574,40 @@ func (s *Service) NotifyNewProperty(property map[string]interface{}) error {
 		postalCode = "Unknown"
 	}
 
+	f, t := b.formatter, b.translator
 	var priceAnalysis string
 
 	// Only attempt price analysis if we have a valid database connection and valid data
 	if s.db != nil && price > 0 && livingArea > 0 && postalCode != "Unknown" {
 		var err error
-		_, priceAnalysis, err = s.getPriceAnalysis(price, livingArea, postalCode)
+		_, priceAnalysis, err = s.getPriceAnalysis(b, price, livingArea, postalCode, district)
 		if err != nil {
 			s.logger.WithError(err).Error("Failed to get price analysis")
-			priceAnalysis = "N/A"
+			priceAnalysis = t.T("N/A")
 		}
 	} else {
-		priceAnalysis = "N/A (price analysis unavailable)"
+		priceAnalysis = t.T("N/A (price analysis unavailable)")
+	}
+
+	if city, ok := property["city"].(string); ok && city != "" && s.db != nil {
+		if market := b.marketPhase(city); market != "" {
+			priceAnalysis += "\n\n" + market
+		}
+	}
+
+	if s.bidAdvice && s.db != nil {
+		if id, ok := property["id"].(int64); ok {
+			advice, err := s.db.GetBidAdvice(id)
+			if err != nil {
+				s.logger.WithError(err).Error("Failed to get bid advice")
+			} else if advice != nil && advice.AskingPrice > 0 {
+				priceAnalysis += "\n\n" + bidAdviceSection(f, t, advice)
+			}
+		}
 	}
 
 	// Format the message with property details
-	title := "<b>New Property Listed!</b>"
+	title := "<b>" + t.T("New Property Listed!") + "</b>"
 	var priceText string
 
 	if property["status"] == "republished" {
@@ -337,9 +622,9 @@ func (s *Service) NotifyNewProperty(property map[string]interface{}) error {
 		}
 
 		if republishCount > 1 {
-			title = fmt.Sprintf("<b>⚡ Property Republished! (%d times)</b>", republishCount)
+			title = "<b>⚡ " + t.Sprintf("Property Republished! (%d times)", republishCount) + "</b>"
 		} else {
-			title = "<b>⚡ Property Republished!</b>"
+			title = "<b>⚡ " + t.T("Property Republished!") + "</b>"
 		}
 
 		// Get previous price if available
@@ -353,23 +638,27 @@ func (s *Service) NotifyNewProperty(property map[string]interface{}) error {
 				} else {
 					arrow = "📉"
 				}
-				priceText = fmt.Sprintf("💰 €%s (%s %+.1f%% from €%s)",
-					formatNumber(price),
+				priceText = "💰 " + t.Sprintf("%s (%s %s from %s)",
+					f.Money(price),
 					arrow,
-					priceDiffPercent,
-					formatNumber(float64(previousPrice)))
+					f.SignedPercent(priceDiffPercent, 1),
+					f.Money(float64(previousPrice)))
 			} else {
-				priceText = fmt.Sprintf("💰 €%s", formatNumber(price))
+				priceText = "💰 " + f.Money(price)
 			}
 		} else {
-			priceText = fmt.Sprintf("💰 €%s", formatNumber(price))
+			priceText = "💰 " + f.Money(price)
 		}
 	} else {
-		priceText = fmt.Sprintf("💰 €%s", formatNumber(price))
+		priceText = "💰 " + f.Money(price)
+	}
+
+	if subscribed {
+		title += "\n📬 " + t.Sprintf("Subscribed district %s", district)
 	}
 
 	// Safely handle year_built and num_rooms
-	var yearBuilt interface{} = "N/A"
+	var yearBuilt interface{} = t.T("N/A")
 	if yb := property["year_built"]; yb != nil {
 		switch v := yb.(type) {
 		case int:
@@ -379,7 +668,7 @@ func (s *Service) NotifyNewProperty(property map[string]interface{}) error {
 		}
 	}
 
-	var numRooms interface{} = "N/A"
+	var numRooms interface{} = t.T("N/A")
 	if nr := property["num_rooms"]; nr != nil {
 		switch v := nr.(type) {
 		case int:
@@ -392,6 +681,7 @@ func (s *Service) NotifyNewProperty(property map[string]interface{}) error {
 	street, _ := property["street"].(string)
 	city, _ := property["city"].(string)
 	url, _ := property["url"].(string)
+	address := strings.Join(strings.Fields(fmt.Sprintf("%s, %s %s", street, postalCode, city)), " ")
 
 	message := fmt.Sprintf(
 		"%s\n\n"+
@@ -399,25 +689,41 @@ func (s *Service) NotifyNewProperty(property map[string]interface{}) error {
 			"📍 %s, %s\n"+
 			"%s\n"+
 			"📐 %v m²\n"+
-			"💵 €%s/m²\n"+
-			"🏗️ Built: %v\n"+
-			"🚪 Rooms: %v\n"+
-			"⚡ Energy label: %v\n\n"+
+			"💵 %s/m²\n"+
+			"🏗️ %s: %v\n"+
+			"🚪 %s: %v\n"+
+			"⚡ %s: %v\n\n"+
 			"%s\n\n"+
-			"🔗 <a href=\"%s\">View on Funda</a>",
+			"🔗 <a href=\"%s\">%s</a>\n"+
+			"%s%s",
 		title,
 		street,
 		city,
 		postalCode,
 		priceText,
 		livingArea,
-		formatNumber(price/livingArea),
-		yearBuilt,
-		numRooms,
-		prop.EnergyLabel,
+		f.Money(price/livingArea),
+		t.T("Built"), yearBuilt,
+		t.T("Rooms"), numRooms,
+		t.T("Energy label"), prop.EnergyLabel,
 		priceAnalysis,
-		url,
+		url, t.T("View on Funda"),
+		mapLinks(property, address),
+		streetImageLink(t, property),
 	)
 
-	return s.SendMessage(message)
+	n := &notification{
+		config:       *s.config,
+		message:      message,
+		photoCaption: "📷 " + html.EscapeString(address),
+		mapCaption: fmt.Sprintf("📍 %s\n%s <a href=\"%s\">%s</a>",
+			html.EscapeString(address), t.T("Map data"), staticmap.AttributionURL, staticmap.Attribution),
+	}
+	n.lat, n.lng, n.located = coordinates(property)
+	if path, _ := property["thumbnail_path"].(string); path != "" {
+		n.photoPath = path
+	} else if images, _ := property["images"].([]string); len(images) > 0 {
+		n.photoURL = images[0]
+	}
+	return n
 }
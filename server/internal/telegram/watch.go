@@ -0,0 +1,59 @@
+package telegram
+
+import (
+	"errors"
+	"fmt"
+	"fundamental/server/internal/models"
+	"html"
+	"strings"
+)
+
+// propertyAddress returns the address of a property on one line
+func propertyAddress(p models.Property) string {
+	return strings.Join(strings.Fields(fmt.Sprintf("%s, %s %s", p.Street, p.PostalCode, p.City)), " ")
+}
+
+// NotifyWatchComparable sends a notification about a home sold near a watched
+// property, with the value it implies for the watched property
+func (s *Service) NotifyWatchComparable(alert models.WatchAlert) error {
+	if !s.config.IsEnabled {
+		return nil
+	}
+
+	if s.config.BotToken == "" {
+		return errors.New("Telegram bot token is not configured")
+	}
+
+	if s.config.ChatID == "" {
+		return errors.New("Telegram chat ID is not configured")
+	}
+
+	f, t := s.formatter(), s.translator()
+	watched, sold := alert.Property, alert.Comparable
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "<b>🔔 %s</b> (%s)\n\n", t.T("Sold near a watched home"), html.EscapeString(alert.WatchlistName))
+	fmt.Fprintf(&b, "🏷️ <a href=\"%s\">%s</a>\n", html.EscapeString(sold.URL), html.EscapeString(propertyAddress(sold)))
+	b.WriteString("💰 " + t.Sprintf("Sold for %s", f.Money(float64(sold.Price))))
+	if sold.LivingArea != nil && *sold.LivingArea > 0 {
+		fmt.Fprintf(&b, " (%d m², %s/m²)", *sold.LivingArea, f.Money(float64(sold.Price)/float64(*sold.LivingArea)))
+	}
+	b.WriteString("\n📏 " + t.Sprintf("%s m from the watched home", f.Number(alert.DistanceMeters, 0)) + "\n\n")
+
+	fmt.Fprintf(&b, "👀 <a href=\"%s\">%s</a>\n", html.EscapeString(watched.URL), html.EscapeString(propertyAddress(watched)))
+	if watched.Price > 0 {
+		b.WriteString("💵 " + t.Sprintf("Asking %s", f.Money(float64(watched.Price))) + "\n")
+	}
+	if alert.ImpliedValue != nil {
+		b.WriteString("📊 " + t.Sprintf("Implied value %s", f.Money(float64(*alert.ImpliedValue))))
+		if alert.ImpliedChange != nil {
+			b.WriteString(" (" + t.Sprintf("%s against the asking price", f.SignedPercent(*alert.ImpliedChange, 1)) + ")")
+		}
+		b.WriteString("\n")
+	}
+
+	if !s.withinQuota() {
+		return nil
+	}
+	return s.SendMessage(b.String())
+}
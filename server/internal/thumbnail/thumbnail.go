@@ -0,0 +1,135 @@
+package thumbnail
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif" // Listing photos are mostly JPEG, but may be any of these
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+const (
+	maxWidth     = 640
+	quality      = 80
+	maxImageSize = 20 << 20 // bytes downloaded at most per image
+)
+
+// Cache downloads the primary photo of a listing and keeps a downscaled JPEG
+// copy of it on disk, one file per property
+type Cache struct {
+	dir    string
+	client *http.Client
+}
+
+// NewCache creates a cache storing thumbnails in dir
+func NewCache(dir string) *Cache {
+	return &Cache{
+		dir:    dir,
+		client: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Path returns where the thumbnail of a property is stored
+func (c *Cache) Path(propertyID int64) string {
+	return filepath.Join(c.dir, strconv.FormatInt(propertyID, 10)+".jpg")
+}
+
+// Fetch downloads an image and stores it as the thumbnail of a property,
+// replacing the previous one. It returns the path of the thumbnail.
+func (c *Cache) Fetch(propertyID int64, imageURL string) (string, error) {
+	req, err := http.NewRequest("GET", imageURL, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create image request: %v", err)
+	}
+	req.Header.Set("User-Agent", "FundaMental Property Analyzer/1.0")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to download image: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("image server returned status %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxImageSize+1))
+	if err != nil {
+		return "", fmt.Errorf("failed to read image: %v", err)
+	}
+	if len(data) > maxImageSize {
+		return "", fmt.Errorf("image is larger than %d bytes", maxImageSize)
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return "", fmt.Errorf("failed to decode image: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, downscale(img, maxWidth), &jpeg.Options{Quality: quality}); err != nil {
+		return "", fmt.Errorf("failed to encode thumbnail: %v", err)
+	}
+
+	// Written next to the thumbnail and renamed over it, so a thumbnail that is
+	// being served is never half written
+	path := c.Path(propertyID)
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create thumbnail directory: %v", err)
+	}
+	tmp, err := os.CreateTemp(c.dir, "thumbnail-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to write thumbnail: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		return "", fmt.Errorf("failed to write thumbnail: %v", err)
+	}
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		tmp.Close()
+		return "", fmt.Errorf("failed to write thumbnail: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return "", fmt.Errorf("failed to write thumbnail: %v", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return "", fmt.Errorf("failed to write thumbnail: %v", err)
+	}
+	return path, nil
+}
+
+// downscale shrinks an image to at most width pixels wide, averaging the
+// source pixels that make up each target pixel. Smaller images are returned
+// as they are.
+func downscale(img image.Image, width int) image.Image {
+	bounds := img.Bounds()
+	if bounds.Dx() <= width {
+		return img
+	}
+	height := max(1, bounds.Dy()*width/bounds.Dx())
+	out := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		y0 := bounds.Min.Y + y*bounds.Dy()/height
+		y1 := max(y0+1, bounds.Min.Y+(y+1)*bounds.Dy()/height)
+		for x := 0; x < width; x++ {
+			x0 := bounds.Min.X + x*bounds.Dx()/width
+			x1 := max(x0+1, bounds.Min.X+(x+1)*bounds.Dx()/width)
+			var r, g, b, a, n uint32
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					pr, pg, pb, pa := img.At(sx, sy).RGBA()
+					r, g, b, a = r+pr, g+pg, b+pb, a+pa
+					n++
+				}
+			}
+			out.Set(x, y, color.RGBA64{uint16(r / n), uint16(g / n), uint16(b / n), uint16(a / n)})
+		}
+	}
+	return out
+}
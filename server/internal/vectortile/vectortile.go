@@ -0,0 +1,188 @@
+// Package vectortile encodes point layers as Mapbox Vector Tiles (version 2.1).
+// Tiles are protocol buffers; the few messages a point layer needs are written
+// by hand, so no protobuf runtime is required. Points are given in tile
+// coordinates, 0 to the extent of the layer from the top left corner; Project
+// converts longitude and latitude into them.
+package vectortile
+
+import (
+	"encoding/binary"
+	"math"
+	"sort"
+
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/maptile"
+)
+
+// DefaultExtent is the number of tile coordinates along a side of a tile
+const DefaultExtent = 4096
+
+// Field numbers and wire types of the vector tile messages
+const (
+	tileLayers = 3
+
+	layerVersion  = 15
+	layerName     = 1
+	layerFeatures = 2
+	layerKeys     = 3
+	layerValues   = 4
+	layerExtent   = 5
+
+	featureID       = 1
+	featureTags     = 2
+	featureType     = 3
+	featureGeometry = 4
+
+	valueString = 1
+	valueDouble = 3
+	valueSint   = 6
+	valueBool   = 7
+
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+
+	geometryPoint = 1
+	commandMoveTo = 1
+)
+
+// Point is a point feature. Properties hold strings, bools, ints and float64s;
+// nil values and other types are left out.
+type Point struct {
+	ID         uint64
+	X, Y       int
+	Properties map[string]interface{}
+}
+
+// Layer is a named layer of point features. A zero extent is DefaultExtent.
+type Layer struct {
+	Name   string
+	Extent int
+	Points []Point
+}
+
+// Project returns the tile coordinates of a longitude and latitude in the tile
+// z/x/y with the given extent. Points outside the tile get coordinates outside
+// 0 to extent, which renderers use for features in the tile buffer.
+func Project(lng, lat float64, z, x, y uint32, extent int) (int, int) {
+	fraction := maptile.Fraction(orb.Point{lng, lat}, maptile.Zoom(z))
+	return int(math.Round((fraction.X() - float64(x)) * float64(extent))),
+		int(math.Round((fraction.Y() - float64(y)) * float64(extent)))
+}
+
+// Encode returns the tile holding the layers
+func Encode(layers ...Layer) []byte {
+	var tile []byte
+	for _, layer := range layers {
+		tile = appendBytes(tile, tileLayers, encodeLayer(layer))
+	}
+	return tile
+}
+
+// encodeLayer encodes a layer, sharing the keys and values of its features
+func encodeLayer(layer Layer) []byte {
+	extent := layer.Extent
+	if extent <= 0 {
+		extent = DefaultExtent
+	}
+
+	var keys []string
+	keyIndex := make(map[string]int)
+	var values [][]byte
+	valueIndex := make(map[string]int)
+
+	var msg []byte
+	msg = appendVarintField(msg, layerVersion, 2)
+	msg = appendBytes(msg, layerName, []byte(layer.Name))
+	for _, point := range layer.Points {
+		names := make([]string, 0, len(point.Properties))
+		for name := range point.Properties {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		var tags []byte
+		for _, name := range names {
+			value, ok := encodeValue(point.Properties[name])
+			if !ok {
+				continue
+			}
+			k, seen := keyIndex[name]
+			if !seen {
+				k = len(keys)
+				keyIndex[name] = k
+				keys = append(keys, name)
+			}
+			v, seen := valueIndex[string(value)]
+			if !seen {
+				v = len(values)
+				valueIndex[string(value)] = v
+				values = append(values, value)
+			}
+			tags = binary.AppendUvarint(tags, uint64(k))
+			tags = binary.AppendUvarint(tags, uint64(v))
+		}
+
+		var geometry []byte
+		geometry = binary.AppendUvarint(geometry, commandMoveTo|1<<3)
+		geometry = binary.AppendUvarint(geometry, zigzag(int64(point.X)))
+		geometry = binary.AppendUvarint(geometry, zigzag(int64(point.Y)))
+
+		var feature []byte
+		if point.ID != 0 {
+			feature = appendVarintField(feature, featureID, point.ID)
+		}
+		if len(tags) > 0 {
+			feature = appendBytes(feature, featureTags, tags)
+		}
+		feature = appendVarintField(feature, featureType, geometryPoint)
+		feature = appendBytes(feature, featureGeometry, geometry)
+		msg = appendBytes(msg, layerFeatures, feature)
+	}
+	for _, key := range keys {
+		msg = appendBytes(msg, layerKeys, []byte(key))
+	}
+	for _, value := range values {
+		msg = appendBytes(msg, layerValues, value)
+	}
+	return appendVarintField(msg, layerExtent, uint64(extent))
+}
+
+// encodeValue encodes a property value as a Value message
+func encodeValue(v interface{}) ([]byte, bool) {
+	switch v := v.(type) {
+	case string:
+		return appendBytes(nil, valueString, []byte(v)), true
+	case bool:
+		b := uint64(0)
+		if v {
+			b = 1
+		}
+		return appendVarintField(nil, valueBool, b), true
+	case int:
+		return appendVarintField(nil, valueSint, zigzag(int64(v))), true
+	case int64:
+		return appendVarintField(nil, valueSint, zigzag(v)), true
+	case float64:
+		msg := binary.AppendUvarint(nil, valueDouble<<3|wireFixed64)
+		return binary.LittleEndian.AppendUint64(msg, math.Float64bits(v)), true
+	}
+	return nil, false
+}
+
+func appendVarintField(b []byte, field int, v uint64) []byte {
+	b = binary.AppendUvarint(b, uint64(field)<<3|wireVarint)
+	return binary.AppendUvarint(b, v)
+}
+
+func appendBytes(b []byte, field int, data []byte) []byte {
+	b = binary.AppendUvarint(b, uint64(field)<<3|wireBytes)
+	b = binary.AppendUvarint(b, uint64(len(data)))
+	return append(b, data...)
+}
+
+// zigzag maps signed integers to unsigned ones so small negative numbers stay
+// short varints
+func zigzag(v int64) uint64 {
+	return uint64(v<<1) ^ uint64(v>>63)
+}
@@ -0,0 +1,190 @@
+package warehouse
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"fundamental/server/config"
+	"fundamental/server/internal/database"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// clickHouseBatchSize is the number of rows sent per insert
+const clickHouseBatchSize = 10000
+
+// clickHouse keeps the copy in a MergeTree table, loaded over the HTTP
+// interface. The properties are loaded into a staging table that is then
+// exchanged with the table queries read.
+type clickHouse struct {
+	url    string
+	table  string
+	client *http.Client
+}
+
+func newClickHouse(cfg *config.Config) (Backend, error) {
+	u, err := url.Parse(cfg.AnalyticsURL)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, errors.New("ANALYTICS_URL must be the http:// or https:// endpoint of ClickHouse for the clickhouse analytics backend")
+	}
+	return &clickHouse{url: cfg.AnalyticsURL, table: cfg.AnalyticsTable, client: &http.Client{Timeout: 5 * time.Minute}}, nil
+}
+
+// query runs a statement with an optional body of input rows and returns the
+// response body
+func (b *clickHouse) query(ctx context.Context, statement string, body io.Reader) ([]byte, error) {
+	u, err := url.Parse(b.url)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ClickHouse URL: %v", err)
+	}
+	params := u.Query()
+	params.Set("query", statement)
+	params.Set("output_format_json_quote_64bit_integers", "0")
+	u.RawQuery = params.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach ClickHouse: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("ClickHouse answered %s: %s", resp.Status, strings.TrimSpace(string(message)))
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// insert sends encoded rows to the staging table
+func (b *clickHouse) insert(ctx context.Context, table string, rows *bytes.Buffer) error {
+	if rows.Len() == 0 {
+		return nil
+	}
+	_, err := b.query(ctx, "INSERT INTO "+table+" FORMAT JSONEachRow", rows)
+	rows.Reset()
+	return err
+}
+
+func (b *clickHouse) Load(ctx context.Context, properties *database.PropertyIterator) (int, error) {
+	staging := b.table + "_load"
+	definitions := make([]string, len(columns))
+	for i, c := range columns {
+		definitions[i] = c.name + " " + c.clickHouse
+	}
+	for _, statement := range []string{
+		"DROP TABLE IF EXISTS " + staging,
+		"CREATE TABLE " + staging + " (" + strings.Join(definitions, ", ") + ") ENGINE = MergeTree ORDER BY id",
+	} {
+		if _, err := b.query(ctx, statement, nil); err != nil {
+			return 0, fmt.Errorf("failed to create staging table: %v", err)
+		}
+	}
+
+	rows := 0
+	var body bytes.Buffer
+	encoder := json.NewEncoder(&body)
+	for properties.Next() {
+		p := properties.Property()
+		row := make(map[string]interface{}, len(columns))
+		for _, c := range columns {
+			value := c.value(&p)
+			switch v := value.(type) {
+			case time.Time:
+				value = clickHouseTime(c, v)
+			case *time.Time:
+				value = nil
+				if v != nil {
+					value = clickHouseTime(c, *v)
+				}
+			}
+			row[c.name] = value
+		}
+		if err := encoder.Encode(row); err != nil {
+			return rows, fmt.Errorf("failed to encode property %d: %v", p.ID, err)
+		}
+		rows++
+		if rows%clickHouseBatchSize == 0 {
+			if err := b.insert(ctx, staging, &body); err != nil {
+				return rows, err
+			}
+		}
+	}
+	if err := properties.Err(); err != nil {
+		return rows, err
+	}
+	if err := b.insert(ctx, staging, &body); err != nil {
+		return rows, err
+	}
+
+	// The first export creates the table before exchanging it
+	for _, statement := range []string{
+		"CREATE TABLE IF NOT EXISTS " + b.table + " AS " + staging,
+		"EXCHANGE TABLES " + staging + " AND " + b.table,
+		"DROP TABLE " + staging,
+	} {
+		if _, err := b.query(ctx, statement, nil); err != nil {
+			return rows, fmt.Errorf("failed to replace analytics table: %v", err)
+		}
+	}
+	return rows, nil
+}
+
+// clickHouseTime formats a time for a Date or DateTime64 column; zero times
+// are null
+func clickHouseTime(c column, t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+	if strings.Contains(c.clickHouse, "DateTime") {
+		return t.UTC().Format("2006-01-02 15:04:05.000")
+	}
+	return t.Format(time.DateOnly)
+}
+
+func (b *clickHouse) Query(ctx context.Context, query string) ([]map[string]interface{}, error) {
+	output, err := b.query(ctx, query+" FORMAT JSONEachRow", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	rows := []map[string]interface{}{}
+	decoder := json.NewDecoder(bytes.NewReader(output))
+	decoder.UseNumber()
+	for decoder.More() {
+		row := map[string]interface{}{}
+		if err := decoder.Decode(&row); err != nil {
+			return nil, fmt.Errorf("failed to decode ClickHouse output: %v", err)
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func (b *clickHouse) Dialect() Dialect {
+	return b
+}
+
+func (b *clickHouse) Format(column, layout string) string {
+	return "formatDateTime(" + column + ", '" + layout + "')"
+}
+
+func (b *clickHouse) Median(expr string) string {
+	return "quantileExact(0.5)(" + expr + ")"
+}
+
+func (b *clickHouse) DaysBetween(from, to string) string {
+	return "dateDiff('day', " + from + ", " + to + ")"
+}
+
+func (b *clickHouse) Quote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return "'" + strings.ReplaceAll(s, "'", `\'`) + "'"
+}
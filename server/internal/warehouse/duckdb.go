@@ -0,0 +1,156 @@
+package warehouse
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"fundamental/server/config"
+	"fundamental/server/internal/database"
+	"fundamental/server/internal/parquet"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// duckDB keeps the copy in a DuckDB file. The properties are written to a
+// Parquet file, which the duckdb command-line tool loads into a new database
+// file that then replaces the old one, so readers never see a partial copy.
+type duckDB struct {
+	binary string
+	path   string
+	table  string
+}
+
+func newDuckDB(cfg *config.Config) (Backend, error) {
+	if cfg.AnalyticsDuckDBPath == "" {
+		return nil, errors.New("ANALYTICS_DUCKDB_PATH is required for the duckdb analytics backend")
+	}
+	return &duckDB{binary: cfg.DuckDBBinary, path: cfg.AnalyticsDuckDBPath, table: cfg.AnalyticsTable}, nil
+}
+
+// run runs SQL statements with the duckdb tool on a database file and returns
+// what they print
+func (b *duckDB) run(ctx context.Context, path string, readOnly bool, statements string) ([]byte, error) {
+	args := []string{"-json"}
+	if readOnly {
+		args = append(args, "-readonly")
+	}
+	args = append(args, path)
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, b.binary, args...)
+	cmd.Stdin = strings.NewReader(statements)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return nil, fmt.Errorf("duckdb failed: %v: %s", err, message)
+		}
+		return nil, fmt.Errorf("duckdb failed: %v", err)
+	}
+	return stdout.Bytes(), nil
+}
+
+func (b *duckDB) Load(ctx context.Context, properties *database.PropertyIterator) (int, error) {
+	dir := filepath.Dir(b.path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return 0, fmt.Errorf("failed to create analytics directory: %v", err)
+	}
+
+	file, err := os.CreateTemp(dir, "analytics-*.parquet")
+	if err != nil {
+		return 0, fmt.Errorf("failed to create Parquet file: %v", err)
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	parquetColumns := make([]parquet.Column, len(columns))
+	for i, c := range columns {
+		parquetColumns[i] = parquet.Column{Name: c.name, Type: c.typ}
+	}
+	buffered := bufio.NewWriter(file)
+	writer := parquet.NewWriter(buffered, parquetColumns, 0)
+
+	rows := 0
+	row := make([]interface{}, len(columns))
+	for properties.Next() {
+		p := properties.Property()
+		for i, c := range columns {
+			row[i] = c.value(&p)
+		}
+		if err := writer.Write(row); err != nil {
+			return rows, fmt.Errorf("failed to write property %d: %v", p.ID, err)
+		}
+		rows++
+	}
+	if err := properties.Err(); err != nil {
+		return rows, err
+	}
+	if err := writer.Close(); err != nil {
+		return rows, fmt.Errorf("failed to write Parquet file: %v", err)
+	}
+	if err := buffered.Flush(); err != nil {
+		return rows, fmt.Errorf("failed to write Parquet file: %v", err)
+	}
+	if err := file.Close(); err != nil {
+		return rows, fmt.Errorf("failed to write Parquet file: %v", err)
+	}
+
+	loading := b.path + ".load"
+	os.Remove(loading)
+	defer os.Remove(loading)
+	statement := "CREATE TABLE " + b.table + " AS SELECT * FROM read_parquet(" + b.Quote(file.Name()) + ");"
+	if _, err := b.run(ctx, loading, false, statement); err != nil {
+		return rows, err
+	}
+	if err := os.Rename(loading, b.path); err != nil {
+		return rows, fmt.Errorf("failed to replace analytics database: %v", err)
+	}
+	return rows, nil
+}
+
+func (b *duckDB) Query(ctx context.Context, query string) ([]map[string]interface{}, error) {
+	if _, err := os.Stat(b.path); errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotExported
+	}
+	output, err := b.run(ctx, b.path, true, query+";")
+	if err != nil {
+		return nil, err
+	}
+
+	// The tool prints nothing for a query without rows
+	rows := []map[string]interface{}{}
+	if len(bytes.TrimSpace(output)) == 0 {
+		return rows, nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader(output))
+	decoder.UseNumber()
+	if err := decoder.Decode(&rows); err != nil {
+		return nil, fmt.Errorf("failed to decode duckdb output: %v", err)
+	}
+	return rows, nil
+}
+
+func (b *duckDB) Dialect() Dialect {
+	return b
+}
+
+func (b *duckDB) Format(column, layout string) string {
+	return "strftime(" + column + ", '" + layout + "')"
+}
+
+func (b *duckDB) Median(expr string) string {
+	return "median(" + expr + ")"
+}
+
+func (b *duckDB) DaysBetween(from, to string) string {
+	return "date_diff('day', " + from + ", " + to + ")"
+}
+
+func (b *duckDB) Quote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
@@ -0,0 +1,185 @@
+package warehouse
+
+import (
+	"context"
+	"fmt"
+	"fundamental/server/internal/database"
+	"fundamental/server/internal/models"
+	"math"
+	"time"
+)
+
+// metric describes how a market metric is aggregated per date bucket, like
+// the metrics of database.GetMarketTimeSeries
+type metric struct {
+	dateColumn string
+	value      func(d Dialect) string
+	condition  string
+}
+
+func count(Dialect) string { return "COUNT(*)" }
+
+// metrics are the market metrics available as time series
+var metrics = map[string]metric{
+	"new_listings": {
+		dateColumn: "listing_date",
+		value:      count,
+	},
+	"sold_count": {
+		dateColumn: "selling_date",
+		value:      count,
+		condition:  "status = 'sold'",
+	},
+	"avg_listing_price": {
+		dateColumn: "listing_date",
+		value:      func(Dialect) string { return "AVG(price)" },
+	},
+	"avg_sold_price": {
+		dateColumn: "selling_date",
+		value:      func(Dialect) string { return "AVG(price)" },
+		condition:  "status = 'sold'",
+	},
+	"avg_sold_price_per_sqm": {
+		dateColumn: "selling_date",
+		value:      func(Dialect) string { return "AVG(CAST(price AS DOUBLE) / NULLIF(living_area, 0))" },
+		condition:  "status = 'sold'",
+	},
+	"avg_days_to_sell": {
+		dateColumn: "selling_date",
+		value: func(d Dialect) string {
+			return "AVG(" + d.DaysBetween("listing_date", "selling_date") + ")"
+		},
+		condition: "status = 'sold' AND listing_date IS NOT NULL AND selling_date_precision = '" + models.DatePrecisionDay + "'",
+	},
+}
+
+// cityCondition matches the properties of a city, or all for an empty city
+func cityCondition(d Dialect, city string) string {
+	if city == "" {
+		return "1 = 1"
+	}
+	return "LOWER(city) = LOWER(" + d.Quote(city) + ")"
+}
+
+// MonthlyTrends answers database.GetMonthlyTrends from the backend. It
+// returns false when queries are not routed to the backend or the query
+// failed, and the caller should use the main database.
+func (w *Warehouse) MonthlyTrends(ctx context.Context, startDate, endDate, city string, byDistrict bool) ([]models.MonthlyTrend, bool) {
+	if !w.routes() {
+		return nil, false
+	}
+	d := w.backend.Dialect()
+
+	district := "''"
+	conditions := "status = 'sold' AND selling_date IS NOT NULL AND price IS NOT NULL AND " + cityCondition(d, city)
+	if byDistrict {
+		district = "district"
+		conditions += " AND district IS NOT NULL"
+	}
+	// Dates are not validated by the handler, so they are quoted
+	if startDate != "" {
+		conditions += " AND selling_date >= " + d.Quote(startDate)
+	}
+	if endDate != "" {
+		conditions += " AND selling_date <= " + d.Quote(endDate)
+	}
+
+	query := `
+        SELECT sale_month, sale_district, COUNT(*) AS volume,
+               ` + d.Median("sale_price") + ` AS median_price,
+               ` + d.Median("sale_price_per_sqm") + ` AS median_price_per_sqm,
+               ` + d.Median("sale_days") + ` AS median_days_to_sell
+        FROM (
+            SELECT ` + d.Format("selling_date", "%Y-%m") + ` AS sale_month,
+                   ` + district + ` AS sale_district,
+                   CAST(price AS DOUBLE) AS sale_price,
+                   CAST(price AS DOUBLE) / NULLIF(living_area, 0) AS sale_price_per_sqm,
+                   CASE WHEN listing_date IS NOT NULL AND listing_date <= selling_date
+                        AND selling_date_precision = '` + models.DatePrecisionDay + `'
+                        THEN ` + d.DaysBetween("listing_date", "selling_date") + ` END AS sale_days
+            FROM ` + w.table + `
+            WHERE ` + conditions + `
+        ) sold
+        GROUP BY sale_month, sale_district
+        ORDER BY sale_month, sale_district`
+
+	rows, err := w.backend.Query(ctx, query)
+	if err != nil {
+		w.fallback(err, "monthly_trends")
+		return nil, false
+	}
+
+	trends := make([]models.MonthlyTrend, 0, len(rows))
+	for _, row := range rows {
+		trend := models.MonthlyTrend{}
+		trend.Month, _ = row["sale_month"].(string)
+		trend.District, _ = row["sale_district"].(string)
+		if volume, ok := number(row["volume"]); ok {
+			trend.Volume = int(volume)
+		}
+		if price, ok := number(row["median_price"]); ok && !math.IsNaN(price) {
+			trend.MedianPrice = math.Round(price)
+		}
+		if price, ok := number(row["median_price_per_sqm"]); ok && !math.IsNaN(price) {
+			trend.MedianPricePerSqm = math.Round(price)
+		}
+		if days, ok := number(row["median_days_to_sell"]); ok && !math.IsNaN(days) {
+			trend.MedianDaysToSell = math.Round(days)
+		}
+		trends = append(trends, trend)
+	}
+	return trends, true
+}
+
+// MarketTimeSeries answers database.GetMarketTimeSeries from the backend,
+// returning false like MonthlyTrends
+func (w *Warehouse) MarketTimeSeries(ctx context.Context, name, interval string, from, to time.Time, city string) ([]models.TimeSeriesPoint, bool) {
+	m, ok := metrics[name]
+	if !w.routes() || !ok {
+		return nil, false
+	}
+	d := w.backend.Dialect()
+
+	format, layout := "%Y-%m-%d", "2006-01-02"
+	if interval == database.IntervalMonth {
+		format, layout = "%Y-%m", "2006-01"
+	}
+	condition := "1 = 1"
+	if m.condition != "" {
+		condition = m.condition
+	}
+
+	query := fmt.Sprintf(`
+        SELECT %s AS bucket, %s AS value
+        FROM %s
+        WHERE price IS NOT NULL
+        AND %s IS NOT NULL
+        AND %s >= %s AND %s <= %s
+        AND %s
+        AND %s
+        GROUP BY bucket
+        ORDER BY bucket`,
+		d.Format(m.dateColumn, format), m.value(d), w.table,
+		m.dateColumn,
+		m.dateColumn, d.Quote(from.Format("2006-01-02")), m.dateColumn, d.Quote(to.Format("2006-01-02")),
+		condition, cityCondition(d, city))
+
+	rows, err := w.backend.Query(ctx, query)
+	if err != nil {
+		w.fallback(err, name)
+		return nil, false
+	}
+
+	points := []models.TimeSeriesPoint{}
+	for _, row := range rows {
+		key, _ := row["bucket"].(string)
+		t, err := time.Parse(layout, key)
+		value, ok := number(row["value"])
+		if err != nil || !ok || math.IsNaN(value) {
+			// Skip malformed dates and buckets without values
+			continue
+		}
+		points = append(points, models.TimeSeriesPoint{Time: t, Value: value})
+	}
+	return points, true
+}
@@ -0,0 +1,243 @@
+// Package warehouse keeps a columnar copy of the properties in an analytical
+// database, so heavy aggregations over the whole history do not compete with
+// the scrapers for the transactional database. The copy is replaced as a
+// whole by every export; the trend and time series endpoints can optionally
+// be answered from it, falling back to the transactional database when the
+// backend fails.
+package warehouse
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"fundamental/server/config"
+	"fundamental/server/internal/database"
+	"fundamental/server/internal/errorsink"
+	"fundamental/server/internal/models"
+	"fundamental/server/internal/parquet"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Backend is an analytical database holding the copy of the properties
+type Backend interface {
+	// Load replaces the copy with the properties of the iterator and returns
+	// the number of rows loaded. Queries see the old copy until it succeeds.
+	Load(ctx context.Context, properties *database.PropertyIterator) (int, error)
+
+	// Query runs a read-only query and returns its rows by column name
+	Query(ctx context.Context, query string) ([]map[string]interface{}, error)
+
+	// Dialect writes the expressions that differ between backends
+	Dialect() Dialect
+}
+
+// Dialect writes the SQL expressions whose syntax differs between backends
+type Dialect interface {
+	// Format returns a date column as text in a strftime layout
+	Format(column, layout string) string
+	// Median returns the median of an expression, ignoring nulls
+	Median(expr string) string
+	// DaysBetween returns the number of days from one date column to another
+	DaysBetween(from, to string) string
+	// Quote returns a string literal
+	Quote(s string) string
+}
+
+// backends create a backend by name from the configuration
+var backends = map[string]func(cfg *config.Config) (Backend, error){
+	models.AnalyticsDuckDB:     newDuckDB,
+	models.AnalyticsClickHouse: newClickHouse,
+}
+
+// ErrNotExported is returned by queries before the first export
+var ErrNotExported = errors.New("properties not exported to the analytics backend yet")
+
+// tableRegex matches the name of the exported table
+var tableRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Warehouse exports the properties to the configured backend and routes
+// queries to it. The methods of a nil Warehouse report that no backend is
+// configured.
+type Warehouse struct {
+	db           *database.Database
+	backend      Backend
+	name         string
+	table        string
+	routeQueries bool
+	logger       *logrus.Logger
+
+	exportMu   sync.Mutex // one export at a time
+	statusMu   sync.Mutex
+	lastExport *models.AnalyticsExport
+}
+
+// New creates the warehouse of the configured backend, or returns nil when
+// ANALYTICS_BACKEND is not set
+func New(db *database.Database, cfg *config.Config, logger *logrus.Logger) (*Warehouse, error) {
+	if cfg.AnalyticsBackend == "" {
+		return nil, nil
+	}
+	create, ok := backends[cfg.AnalyticsBackend]
+	if !ok {
+		return nil, fmt.Errorf("unknown analytics backend %q, must be %s or %s",
+			cfg.AnalyticsBackend, models.AnalyticsDuckDB, models.AnalyticsClickHouse)
+	}
+	if !tableRegex.MatchString(cfg.AnalyticsTable) {
+		return nil, fmt.Errorf("invalid analytics table name %q", cfg.AnalyticsTable)
+	}
+	backend, err := create(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &Warehouse{
+		db:           db,
+		backend:      backend,
+		name:         cfg.AnalyticsBackend,
+		table:        cfg.AnalyticsTable,
+		routeQueries: cfg.AnalyticsRouteQueries,
+		logger:       errorsink.WithModule(logger, "warehouse"),
+	}, nil
+}
+
+// Export replaces the copy in the backend with all properties
+func (w *Warehouse) Export(ctx context.Context) (*models.AnalyticsExport, error) {
+	w.exportMu.Lock()
+	defer w.exportMu.Unlock()
+
+	run := &models.AnalyticsExport{StartedAt: time.Now().UTC()}
+	rows, err := w.load(ctx)
+	finished := time.Now().UTC()
+	run.FinishedAt = &finished
+	run.Rows = rows
+	if err != nil {
+		run.Error = err.Error()
+	}
+
+	w.statusMu.Lock()
+	w.lastExport = run
+	w.statusMu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	w.logger.WithFields(logrus.Fields{
+		"backend": w.name,
+		"rows":    rows,
+		"seconds": finished.Sub(run.StartedAt).Round(time.Millisecond).Seconds(),
+	}).Info("Exported properties to the analytics backend")
+	return run, nil
+}
+
+func (w *Warehouse) load(ctx context.Context) (int, error) {
+	properties, err := w.db.IterateAllProperties(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer properties.Close()
+	return w.backend.Load(ctx, properties)
+}
+
+// Status returns the configured backend and the last export
+func (w *Warehouse) Status() models.AnalyticsStatus {
+	if w == nil {
+		return models.AnalyticsStatus{}
+	}
+	w.statusMu.Lock()
+	defer w.statusMu.Unlock()
+
+	status := models.AnalyticsStatus{Backend: w.name, Table: w.table, RouteQueries: w.routeQueries}
+	if w.lastExport != nil {
+		run := *w.lastExport
+		status.LastExport = &run
+	}
+	return status
+}
+
+// routes reports whether queries are answered by the backend
+func (w *Warehouse) routes() bool {
+	return w != nil && w.routeQueries
+}
+
+// fallback logs a failed query; the caller answers from the transactional
+// database instead
+func (w *Warehouse) fallback(err error, query string) {
+	if errors.Is(err, ErrNotExported) {
+		w.logger.WithField("query", query).Debug("Analytics backend not exported yet, using the main database")
+		return
+	}
+	w.logger.WithError(err).WithField("query", query).Warn("Analytics backend query failed, using the main database")
+}
+
+// column is a column of the exported table
+type column struct {
+	name       string
+	typ        parquet.Type
+	clickHouse string
+	value      func(p *models.Property) interface{}
+}
+
+// text returns nil for an empty string, so it is stored as null
+func text(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// columns are the columns of the exported table, in order
+var columns = []column{
+	{"id", parquet.Int64, "Int64", func(p *models.Property) interface{} { return p.ID }},
+	{"url", parquet.String, "Nullable(String)", func(p *models.Property) interface{} { return text(p.URL) }},
+	{"street", parquet.String, "Nullable(String)", func(p *models.Property) interface{} { return text(p.Street) }},
+	{"neighborhood", parquet.String, "Nullable(String)", func(p *models.Property) interface{} { return text(p.Neighborhood) }},
+	{"property_type", parquet.String, "Nullable(String)", func(p *models.Property) interface{} { return text(p.PropertyType) }},
+	{"city", parquet.String, "Nullable(String)", func(p *models.Property) interface{} { return text(p.City) }},
+	{"postal_code", parquet.String, "Nullable(String)", func(p *models.Property) interface{} { return text(p.PostalCode) }},
+	{"district", parquet.String, "Nullable(String)", func(p *models.Property) interface{} { return text(p.District) }},
+	{"country", parquet.String, "Nullable(String)", func(p *models.Property) interface{} { return text(p.Country) }},
+	{"currency", parquet.String, "Nullable(String)", func(p *models.Property) interface{} { return text(p.Currency) }},
+	{"price", parquet.Int64, "Nullable(Int64)", func(p *models.Property) interface{} {
+		// The main database stores a missing price as null, read as 0
+		if p.Price == 0 {
+			return nil
+		}
+		return p.Price
+	}},
+	{"year_built", parquet.Int64, "Nullable(Int64)", func(p *models.Property) interface{} { return p.YearBuilt }},
+	{"living_area", parquet.Int64, "Nullable(Int64)", func(p *models.Property) interface{} { return p.LivingArea }},
+	{"num_rooms", parquet.Int64, "Nullable(Int64)", func(p *models.Property) interface{} { return p.NumRooms }},
+	{"status", parquet.String, "Nullable(String)", func(p *models.Property) interface{} { return text(p.Status) }},
+	{"listing_date", parquet.Date, "Nullable(Date)", func(p *models.Property) interface{} { return p.ListingDate }},
+	{"selling_date", parquet.Date, "Nullable(Date)", func(p *models.Property) interface{} { return p.SellingDate }},
+	{"selling_date_precision", parquet.String, "Nullable(String)", func(p *models.Property) interface{} { return text(p.SellingDatePrecision) }},
+	{"scraped_at", parquet.Timestamp, "Nullable(DateTime64(3, 'UTC'))", func(p *models.Property) interface{} { return p.ScrapedAt }},
+	{"created_at", parquet.Timestamp, "Nullable(DateTime64(3, 'UTC'))", func(p *models.Property) interface{} { return p.CreatedAt }},
+	{"latitude", parquet.Double, "Nullable(Float64)", func(p *models.Property) interface{} { return p.Latitude }},
+	{"longitude", parquet.Double, "Nullable(Float64)", func(p *models.Property) interface{} { return p.Longitude }},
+	{"energy_label", parquet.String, "Nullable(String)", func(p *models.Property) interface{} { return text(p.EnergyLabel) }},
+	{"canonical_id", parquet.Int64, "Nullable(Int64)", func(p *models.Property) interface{} { return p.CanonicalID }},
+	{"agent_id", parquet.Int64, "Nullable(Int64)", func(p *models.Property) interface{} { return p.AgentID }},
+	{"delisting_reason", parquet.String, "Nullable(String)", func(p *models.Property) interface{} { return text(p.DelistingReason) }},
+	{"delisted_at", parquet.Timestamp, "Nullable(DateTime64(3, 'UTC'))", func(p *models.Property) interface{} { return p.DelistedAt }},
+}
+
+// number reads a numeric query result, which backends return as JSON
+// numbers or, for 64-bit integers, strings
+func number(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	case float64:
+		return v, true
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
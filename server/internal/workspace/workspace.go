@@ -0,0 +1,225 @@
+// Package workspace keeps the workspaces of a multi-tenant server. Every
+// workspace has a SQLite database of its own below WORKSPACES_DIR, holding its
+// properties, saved searches, notification channels and schedules, and its
+// own spiders, scheduler and API router working on it. The main database
+// records which workspaces exist.
+package workspace
+
+import (
+	"fmt"
+	"fundamental/server/config"
+	"fundamental/server/internal/backup"
+	"fundamental/server/internal/database"
+	"fundamental/server/internal/models"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// slugPattern matches a workspace slug, which names its directory and is
+// sent in the X-Workspace header
+var slugPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,39}$`)
+
+// ValidSlug reports whether slug can name a workspace
+func ValidSlug(slug string) bool {
+	return slugPattern.MatchString(slug)
+}
+
+// Builder starts the services of a workspace on its database, such as its
+// spiders and scheduler, and returns the handler serving its API and a
+// function stopping the services
+type Builder func(workspace models.Workspace, db *database.Database, cfg *config.Config) (http.Handler, func(), error)
+
+// instance is an open workspace
+type instance struct {
+	db      *database.Database
+	handler http.Handler
+	stop    func()
+}
+
+// Registry opens the workspaces recorded in the main database and serves
+// their APIs
+type Registry struct {
+	db     *database.Database // the main database, with the workspace records
+	cfg    *config.Config
+	build  Builder
+	logger *logrus.Logger
+
+	mu        sync.Mutex
+	instances map[string]*instance
+}
+
+// NewRegistry creates a registry of the workspaces recorded in db, started
+// with build
+func NewRegistry(db *database.Database, cfg *config.Config, build Builder, logger *logrus.Logger) *Registry {
+	return &Registry{
+		db:        db,
+		cfg:       cfg,
+		build:     build,
+		logger:    logger,
+		instances: make(map[string]*instance),
+	}
+}
+
+// StartAll opens every workspace, so their schedules run from the start. A
+// workspace that fails to open is logged and opened again on its next request.
+func (r *Registry) StartAll() error {
+	workspaces, err := r.db.ListWorkspaces()
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, workspace := range workspaces {
+		if _, err := r.open(workspace); err != nil {
+			r.logger.WithError(err).WithField("workspace", workspace.Slug).Error("Failed to open workspace")
+		}
+	}
+	return nil
+}
+
+// Handler returns the handler serving the API of a workspace, opening the
+// workspace on first use. Returns nil if the workspace does not exist.
+func (r *Registry) Handler(slug string) (http.Handler, error) {
+	inst, err := r.instance(slug)
+	if err != nil || inst == nil {
+		return nil, err
+	}
+	return inst.handler, nil
+}
+
+// Database returns the database of a workspace, opening the workspace on
+// first use. Returns nil if the workspace does not exist.
+func (r *Registry) Database(slug string) (*database.Database, error) {
+	inst, err := r.instance(slug)
+	if err != nil || inst == nil {
+		return nil, err
+	}
+	return inst.db, nil
+}
+
+// instance returns an open workspace, opening it when it is not. Returns nil
+// if the workspace does not exist.
+func (r *Registry) instance(slug string) (*instance, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if inst, ok := r.instances[slug]; ok {
+		return inst, nil
+	}
+
+	workspace, err := r.db.GetWorkspace(slug)
+	if err != nil || workspace == nil {
+		return nil, err
+	}
+	return r.open(*workspace)
+}
+
+// Create records a workspace and creates its database. The slug must be
+// valid, see ValidSlug.
+func (r *Registry) Create(slug, name string) (*models.Workspace, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	workspace, err := r.db.CreateWorkspace(slug, name)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := r.open(*workspace); err != nil {
+		if _, deleteErr := r.db.DeleteWorkspace(slug); deleteErr != nil {
+			r.logger.WithError(deleteErr).WithField("workspace", slug).Error("Failed to remove workspace that failed to open")
+		}
+		return nil, err
+	}
+	r.logger.WithField("workspace", slug).Info("Created workspace")
+	return workspace, nil
+}
+
+// Delete stops a workspace, removes its record and revokes its tokens. With
+// purge the directory of the workspace, with its database, is deleted as
+// well; otherwise it is kept and a workspace created with the same slug
+// picks it up again. Returns false if the workspace does not exist.
+func (r *Registry) Delete(slug string, purge bool) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	deleted, err := r.db.DeleteWorkspace(slug)
+	if err != nil || !deleted {
+		return deleted, err
+	}
+	if inst, ok := r.instances[slug]; ok {
+		inst.close()
+		delete(r.instances, slug)
+	}
+	if purge {
+		if err := os.RemoveAll(r.dir(slug)); err != nil {
+			return true, fmt.Errorf("failed to remove workspace directory: %v", err)
+		}
+	}
+	r.logger.WithFields(logrus.Fields{"workspace": slug, "purged": purge}).Info("Deleted workspace")
+	return true, nil
+}
+
+// Close stops every open workspace, all at once so their shutdowns take as
+// long as the slowest one
+func (r *Registry) Close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var wg sync.WaitGroup
+	for slug, inst := range r.instances {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			inst.close()
+		}()
+		delete(r.instances, slug)
+	}
+	wg.Wait()
+}
+
+// dir is the directory of a workspace
+func (r *Registry) dir(slug string) string {
+	return filepath.Join(r.cfg.WorkspacesDir, slug)
+}
+
+// open opens the database of a workspace, after restoring a backup whose
+// restore was requested, migrates it and starts its services. The caller
+// holds r.mu.
+func (r *Registry) open(workspace models.Workspace) (*instance, error) {
+	cfg := r.cfg.ForWorkspace(workspace.Slug)
+	if err := os.MkdirAll(filepath.Dir(cfg.DatabasePath), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create workspace directory: %v", err)
+	}
+	if _, err := backup.RestorePending(cfg, cfg.DatabasePath, r.logger); err != nil {
+		r.logger.WithError(err).WithField("workspace", workspace.Slug).Error("Failed to restore workspace backup")
+	}
+	db, err := database.OpenFromConfig(cfg, cfg.DatabasePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open workspace database: %v", err)
+	}
+	if err := db.RunMigrations(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to migrate workspace database: %v", err)
+	}
+
+	handler, stop, err := r.build(workspace, db, cfg)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	inst := &instance{db: db, handler: handler, stop: stop}
+	r.instances[workspace.Slug] = inst
+	return inst, nil
+}
+
+// close stops the services of a workspace and closes its database
+func (i *instance) close() {
+	if i.stop != nil {
+		i.stop()
+	}
+	i.db.Close()
+}
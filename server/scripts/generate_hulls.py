@@ -18,9 +18,23 @@ def process_districts(input_data):
     """
     logger.info("Processing district point data from Go app...")
     
-    # Get the project root directory (three levels up from this script)
-    project_root = Path(os.path.dirname(os.path.dirname(os.path.dirname(os.path.abspath(__file__)))))
-    output_path = project_root / 'client' / 'public' / 'district_hulls.geojson'
+    # Write to the output directory set by the Go app, by default the client's
+    # public directory (three levels up from this script)
+    output_dir = os.environ.get('HULLS_OUTPUT_DIR')
+    if not output_dir:
+        project_root = Path(os.path.dirname(os.path.dirname(os.path.dirname(os.path.abspath(__file__)))))
+        output_dir = project_root / 'client' / 'public'
+    output_path = Path(output_dir) / 'district_hulls.geojson'
+
+    # Hull options set through the settings API
+    options = {
+        'algorithm': 'convex',
+        'concavity': 0.5,
+        'buffer_distance': 0.001,
+        'smoothing': 0,
+        **input_data.get('options', {}),
+    }
+    logger.info(f"Generating hulls with {options}")
     
     # Process each district
     hull_features = []
@@ -31,7 +45,13 @@ def process_districts(input_data):
         logger.info(f"Generating hull for district {district}...")
         
         # Generate hull for the district
-        hull_geometry = generate_district_hull(points, buffer_distance=0.001)
+        hull_geometry = generate_district_hull(
+            points,
+            algorithm=options['algorithm'],
+            concavity=options['concavity'],
+            buffer_distance=options['buffer_distance'],
+            smoothing=options['smoothing'],
+        )
         
         if hull_geometry:
             # Create feature with hull geometry
@@ -41,7 +61,7 @@ def process_districts(input_data):
                 'properties': {
                     **feature['properties'],  # Copy all existing properties
                     'geometry_type': 'hull',
-                    'hull_type': 'buffered_convex'
+                    'hull_type': options['algorithm'] if options['buffer_distance'] <= 0 else f"buffered_{options['algorithm']}"
                 }
             }
             hull_features.append(hull_feature)
@@ -56,9 +76,9 @@ def process_districts(input_data):
         'metadata': {
             **input_data.get('metadata', {}),  # Copy existing metadata
             'processing': {
-                'method': 'buffered_convex_hull',
-                'buffer_distance': 0.001,
-                'description': 'Smoothed convex hulls generated from postal district points'
+                'method': f"{options['algorithm']}_hull",
+                **options,
+                'description': f"{options['algorithm'].capitalize()} hulls generated from postal district points"
             }
         }
     }
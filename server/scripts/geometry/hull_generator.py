@@ -1,4 +1,5 @@
 import numpy as np
+import shapely
 from shapely.geometry import MultiPoint, Polygon
 import logging
 
@@ -6,35 +7,73 @@ import logging
 logging.basicConfig(level=logging.INFO)
 logger = logging.getLogger(__name__)
 
-def generate_district_hull(points, buffer_distance=0.001):
+def smooth_ring(coords, passes):
+    """
+    Round the corners of a closed ring with Chaikin's corner cutting: every
+    pass replaces each corner by two points a quarter of the way along its
+    edges.
+
+    Args:
+        coords: List of (x, y) coordinates, the first repeated at the end
+        passes: Number of corner cutting passes
+
+    Returns:
+        The smoothed ring, closed like the input
+    """
+    points = list(coords[:-1])
+    for _ in range(passes):
+        smoothed = []
+        for i, (x0, y0) in enumerate(points):
+            x1, y1 = points[(i + 1) % len(points)]
+            smoothed.append((0.75 * x0 + 0.25 * x1, 0.75 * y0 + 0.25 * y1))
+            smoothed.append((0.25 * x0 + 0.75 * x1, 0.25 * y0 + 0.75 * y1))
+        points = smoothed
+    return points + [points[0]]
+
+def generate_district_hull(points, algorithm='convex', concavity=0.5, buffer_distance=0.001, smoothing=0):
     """
     Generate a hull for a postal district from its points.
-    
+
     Args:
         points: List of [lon, lat] coordinates
+        algorithm: 'convex', or 'concave' for an alpha shape following the
+                   points more closely
+        concavity: Ratio of the concave hull between 0 and 1, where 1 gives
+                   the convex hull (default: 0.5)
         buffer_distance: Distance to buffer the hull (in degrees, default: 0.001)
                       This creates a smoother, more natural looking boundary
-    
+        smoothing: Number of corner cutting passes rounding the hull (default: 0)
+
     Returns:
         GeoJSON-compatible dictionary representing the hull polygon
     """
     try:
         # Convert points to numpy array
         points_array = np.array(points)
-        
-        # Generate the convex hull
-        hull = MultiPoint(points_array).convex_hull
-        
+
+        # Generate the hull
+        if algorithm == 'concave':
+            hull = shapely.concave_hull(MultiPoint(points_array), ratio=concavity)
+        else:
+            hull = MultiPoint(points_array).convex_hull
+
         # Buffer the hull to smooth it
-        smoothed_hull = hull.buffer(buffer_distance)
-        
+        if buffer_distance > 0:
+            hull = hull.buffer(buffer_distance)
+
+        # Points on a line have no area without a buffer
+        if not isinstance(hull, Polygon) or hull.is_empty:
+            logger.warning("Hull is not a polygon")
+            return None
+
         # Convert coordinates to GeoJSON format
-        coords = [[[float(x), float(y)] for x, y in smoothed_hull.exterior.coords]]
-        
+        ring = smooth_ring(list(hull.exterior.coords), smoothing)
+        coords = [[[float(x), float(y)] for x, y in ring]]
+
         return {
             "type": "Polygon",
             "coordinates": coords
         }
     except Exception as e:
         logger.error(f"Error generating hull: {e}")
-        return None 
\ No newline at end of file
+        return None
@@ -0,0 +1,39 @@
+# -*- coding: utf-8 -*-
+
+import json
+
+# Agency pages on Funda live under this path
+AGENT_PATH = '/makelaar/'
+
+
+def extract_agent(response):
+    """Find the listing agent of a listing page.
+
+    Returns the agency name and the URL of its Funda page, either of which may
+    be None. The contact block linking to the agency page comes first, then a
+    JSON-LD `offeredBy` or `seller` entry.
+    """
+    for link in response.css(f'a[href*="{AGENT_PATH}"]'):
+        name = link.attrib.get('title') or ' '.join(link.css('::text').getall())
+        name = ' '.join(name.split())
+        if name:
+            return name, response.urljoin(link.attrib['href']).split('?')[0]
+
+    for script in response.css('script[type="application/ld+json"]::text').getall():
+        try:
+            data = json.loads(script)
+        except json.JSONDecodeError:
+            continue
+        if not isinstance(data, dict):
+            continue
+        offers = data.get('offers')
+        if isinstance(offers, list):
+            offers = offers[0] if offers else None
+        candidates = [data.get('seller')]
+        if isinstance(offers, dict):
+            candidates.insert(0, offers.get('offeredBy'))
+        for agent in candidates:
+            if isinstance(agent, dict) and isinstance(agent.get('name'), str) and agent['name'].strip():
+                url = agent.get('url') if isinstance(agent.get('url'), str) else None
+                return ' '.join(agent['name'].split()), url
+    return None, None
@@ -0,0 +1,36 @@
+# -*- coding: utf-8 -*-
+
+import json
+
+# Funda serves listing photos from its media CDN
+MEDIA_HOST = 'cloud.funda.nl'
+
+
+def extract_image_urls(response):
+    """Collect the photo URLs of a listing page in page order.
+
+    JSON-LD `image` entries come first, then the Open Graph image and the photos
+    of the media gallery. The pipeline drops duplicates and anything that is
+    not an http(s) URL.
+    """
+    urls = []
+    for script in response.css('script[type="application/ld+json"]::text').getall():
+        try:
+            data = json.loads(script)
+        except json.JSONDecodeError:
+            continue
+        if not isinstance(data, dict):
+            continue
+        images = data.get('image')
+        if isinstance(images, (str, dict)):
+            images = [images]
+        for image in images or []:
+            if isinstance(image, dict):
+                image = image.get('contentUrl') or image.get('url')
+            if isinstance(image, str):
+                urls.append(image)
+
+    urls.extend(response.css('meta[property="og:image"]::attr(content)').getall())
+    urls.extend(response.css(f'img[src*="{MEDIA_HOST}"]::attr(src)').getall())
+    urls.extend(response.css(f'img[data-src*="{MEDIA_HOST}"]::attr(data-src)').getall())
+    return [response.urljoin(url.strip()) for url in urls if url and url.strip()]
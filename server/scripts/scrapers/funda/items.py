@@ -1,7 +1,7 @@
 # -*- coding: utf-8 -*-
 
 from dataclasses import dataclass, asdict
-from typing import Optional
+from typing import List, Optional
 from datetime import datetime
 
 @dataclass
@@ -21,6 +21,9 @@ class FundaItem:
     listing_date: Optional[str] = None
     selling_date: Optional[str] = None
     energy_label: Optional[str] = None  # Energy label (A++, A+, A, B, C, D, E, F, G)
+    images: Optional[List[str]] = None  # Photo URLs in page order, primary photo first
+    agent_name: Optional[str] = None  # Listing agent (makelaar)
+    agent_url: Optional[str] = None  # Agent's page on Funda
     scraped_at: str = datetime.now().isoformat()
 
     def to_dict(self) -> dict:
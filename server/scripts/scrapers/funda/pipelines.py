@@ -5,6 +5,9 @@ import json
 import sys
 from dataclasses import asdict
 
+# Photos sent per listing; the backend stores at most this many as well
+MAX_IMAGES = 50
+
 class FundaPipeline:
     def process_item(self, item, spider):
         try:
@@ -46,6 +49,20 @@ class FundaPipeline:
                     spider.logger.warning(f"Could not convert year_built to integer: {item.year_built}")
                     item.year_built = None
 
+            # Keep distinct http(s) photo URLs, primary photo first
+            if item.images is not None:
+                images = []
+                for url in item.images:
+                    if isinstance(url, str) and url.startswith(('http://', 'https://')) and url not in images:
+                        images.append(url)
+                item.images = images[:MAX_IMAGES] or None
+
+            # Collapse whitespace in the agent name, an agent URL needs a name
+            if item.agent_name is not None:
+                item.agent_name = ' '.join(item.agent_name.split()) or None
+            if item.agent_url is not None and (item.agent_name is None or not item.agent_url.startswith(('http://', 'https://'))):
+                item.agent_url = None
+
         except AttributeError as e:
             spider.logger.error(f"AttributeError processing item: {e}")
             
@@ -75,7 +92,10 @@ class JsonMessagePipeline:
             'data': {
                 'status': 'success',
                 'message': 'Spider completed successfully',
-                'total_items': spider.total_items_scraped
+                'total_items': spider.total_items_scraped,
+                # Whether the crawl went through every page of results, rather
+                # than stopping early on known listings or max_pages
+                'results_exhausted': getattr(spider, 'results_exhausted', False)
             }
         }
         print(json.dumps(message), flush=True) 
\ No newline at end of file
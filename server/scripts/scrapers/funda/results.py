@@ -0,0 +1,39 @@
+# -*- coding: utf-8 -*-
+
+import json
+import re
+
+# Listings Funda shows on a page of search results
+PAGE_SIZE = 15
+
+# The heading of the results, such as "1.234 resultaten" or "1,234 results"
+RESULT_COUNT_PATTERN = re.compile(r'([\d.,]+)\s+(?:resultaten|resultaat|results?)\b', re.IGNORECASE)
+
+
+def _count(text):
+    digits = re.sub(r'[.,\s]', '', text)
+    return int(digits) if digits.isdigit() else None
+
+
+def extract_result_count(response):
+    """Find the number of listings Funda reports for a search.
+
+    The JSON-LD `numberOfItems` of the result list comes first, then the count
+    in the heading of the results. Returns None when the page states neither.
+    """
+    for script in response.css('script[type="application/ld+json"]::text').getall():
+        try:
+            data = json.loads(script)
+        except json.JSONDecodeError:
+            continue
+        if isinstance(data, dict) and isinstance(data.get('numberOfItems'), int):
+            return data['numberOfItems']
+
+    for selector in ('h1 ::text', '[data-test-id="search-results-count"] ::text', 'title::text'):
+        text = ' '.join(response.css(selector).getall())
+        match = RESULT_COUNT_PATTERN.search(' '.join(text.split()))
+        if match:
+            count = _count(match.group(1))
+            if count is not None:
+                return count
+    return None
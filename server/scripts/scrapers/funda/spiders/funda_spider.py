@@ -2,6 +2,9 @@ import re
 import scrapy
 from scrapy.http import Request
 from scrapers.funda.items import FundaItem
+from scrapers.funda.images import extract_image_urls
+from scrapers.funda.agents import extract_agent
+from scrapers.funda.results import PAGE_SIZE, extract_result_count
 from scrapers.funda.database import FundaDB  # Import the database module
 import json
 from datetime import datetime
@@ -37,6 +40,8 @@ class FundaSpider(scrapy.Spider):
         self.new_items_found = 0
         self.active_urls = set()  # Track all active URLs for refresh operation
         self.empty_pages_count = 0  # Track consecutive empty pages
+        self.result_count = None  # Listings Funda reports for the search
+        self.results_exhausted = False  # Set when the crawl ran out of result pages
         self.MAX_EMPTY_PAGES = 3  # Stop after this many consecutive empty pages
         self.no_new_listings_count = 0  # Track consecutive pages without new listings
         self.MAX_NO_NEW_LISTINGS = 3  # Stop after this many consecutive pages without new listings
@@ -124,12 +129,29 @@ class FundaSpider(scrapy.Spider):
         self.logger.info(f"Found {len(new_listing_urls)} new listings to process")
         self.logger.info(f"Skipped {len(all_listing_urls) - len(new_listing_urls)} already processed listings")
 
+        # Report the number of listings Funda has for the search once, so the
+        # spider manager can tell how much of it the run covered
+        if self.result_count is None:
+            self.result_count = extract_result_count(response)
+            if self.result_count is not None:
+                print(json.dumps({
+                    'type': 'result_count',
+                    'data': {'total': self.result_count, 'page_size': PAGE_SIZE}
+                }), flush=True)
+
+        # Report the page to the spider manager for live progress
+        print(json.dumps({
+            'type': 'progress',
+            'data': {'page': self.page_count, 'listings': len(all_listing_urls), 'new_listings': len(new_listing_urls)}
+        }), flush=True)
+
         # Check for empty page
         if not all_listing_urls:
             self.empty_pages_count += 1
             self.logger.info(f"Empty page detected. Empty pages count: {self.empty_pages_count}")
             if self.empty_pages_count >= self.MAX_EMPTY_PAGES:
                 self.logger.info(f"Stopping after {self.MAX_EMPTY_PAGES} consecutive empty pages")
+                self.results_exhausted = True
                 return
         else:
             self.empty_pages_count = 0  # Reset counter when we find listings
@@ -440,6 +462,15 @@ class FundaSpider(scrapy.Spider):
             except Exception as e:
                 self.logger.warning(f"Failed to parse listing date from text '{listing_date}': {e}")
 
+        # Extract photo URLs
+        item.images = extract_image_urls(response)
+        self.logger.info(f"Found {len(item.images)} photos")
+
+        # Extract the listing agent
+        item.agent_name, item.agent_url = extract_agent(response)
+        if item.agent_name:
+            self.logger.info(f"Found agent: {item.agent_name}")
+
         # Add scraped timestamp
         item.scraped_at = datetime.utcnow().isoformat()
 
@@ -2,6 +2,9 @@ import re
 import scrapy
 from scrapy.http import Request
 from scrapers.funda.items import FundaItem
+from scrapers.funda.images import extract_image_urls
+from scrapers.funda.agents import extract_agent
+from scrapers.funda.results import PAGE_SIZE, extract_result_count
 from scrapers.funda.database import FundaDB
 import json
 from datetime import datetime
@@ -37,6 +40,8 @@ class FundaSpiderSold(scrapy.Spider):
         self.total_items_scraped = 0
         self.new_items_found = 0
         self.empty_pages_count = 0  # Track consecutive empty pages
+        self.result_count = None  # Listings Funda reports for the search
+        self.results_exhausted = False  # Set when the crawl ran out of result pages
         self.MAX_EMPTY_PAGES = 3  # Stop after this many consecutive empty pages
         
         # Initialize database connection
@@ -123,6 +128,22 @@ class FundaSpiderSold(scrapy.Spider):
         self.logger.info(f"Found {len(all_listing_urls)} total listings on page {self.page_count}")
         self.logger.info(f"Found {len(new_listing_urls)} new listings to process")
         self.logger.info(f"Skipped {len(all_listing_urls) - len(new_listing_urls)} already processed listings")
+
+        # Report the number of listings Funda has for the search once, so the
+        # spider manager can tell how much of it the run covered
+        if self.result_count is None:
+            self.result_count = extract_result_count(response)
+            if self.result_count is not None:
+                print(json.dumps({
+                    'type': 'result_count',
+                    'data': {'total': self.result_count, 'page_size': PAGE_SIZE}
+                }), flush=True)
+
+        # Report the page to the spider manager for live progress
+        print(json.dumps({
+            'type': 'progress',
+            'data': {'page': self.page_count, 'listings': len(all_listing_urls), 'new_listings': len(new_listing_urls)}
+        }), flush=True)
         
         # Check both stopping conditions:
         # 1. Empty pages check
@@ -131,6 +152,7 @@ class FundaSpiderSold(scrapy.Spider):
             self.logger.info(f"Empty page detected. Empty pages count: {self.empty_pages_count}")
             if self.empty_pages_count >= self.MAX_EMPTY_PAGES:
                 self.logger.info(f"Stopping after {self.MAX_EMPTY_PAGES} consecutive empty pages")
+                self.results_exhausted = True
                 return
         else:
             self.empty_pages_count = 0  # Reset counter when we find listings
@@ -327,6 +349,13 @@ class FundaSpiderSold(scrapy.Spider):
                                 item.listing_date = formatted_date
                             elif 'Verkoop' in selector:
                                 item.selling_date = formatted_date
+                        elif 'Verkoop' in selector:
+                            # Some sales only show the month, such as "maart 2024";
+                            # the server stores YYYY-MM with month precision
+                            month_match = re.search(r'(\d{2})\s+(\d{4})', date_text)
+                            if month_match:
+                                month, year = month_match.groups()
+                                item.selling_date = f"{year}-{month}"
                     except Exception as e:
                         self.logger.warning(f"Failed to parse date from text '{date_text}': {e}")
 
@@ -394,6 +423,15 @@ class FundaSpiderSold(scrapy.Spider):
                         self.logger.warning(f"Failed to parse area from text '{area_text}': {e}")
                         continue
 
+        # Extract photo URLs
+        item.images = extract_image_urls(response)
+        self.logger.info(f"Found {len(item.images)} photos")
+
+        # Extract the listing agent
+        item.agent_name, item.agent_url = extract_agent(response)
+        if item.agent_name:
+            self.logger.info(f"Found agent: {item.agent_name}")
+
         self.total_items_scraped += 1
         if self.total_items_scraped % 10 == 0:  # Log progress every 10 items
             self.logger.info(f"Progress: Scraped {self.total_items_scraped} items from {self.page_count} pages")